@@ -0,0 +1,265 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/db"
+)
+
+// archiveFormatVersion is bumped whenever the archive layout changes
+const archiveFormatVersion = 1
+
+const manifestName = "manifest.json"
+
+// manifest describes the contents of a backup archive
+type manifest struct {
+	FormatVersion int             `json:"format_version"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Games         []manifestEntry `json:"games"`
+}
+
+// manifestEntry records one game file with its checksum and row counts
+type manifestEntry struct {
+	GameID string         `json:"game_id"`
+	File   string         `json:"file"`
+	SHA256 string         `json:"sha256"`
+	Rows   map[string]int `json:"rows"`
+}
+
+// backupGames writes every game (or the one named) to a gzip tar archive
+func backupGames(database *db.DB, out io.Writer, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("missing archive path")
+	}
+	path := args[0]
+
+	var gameIDs []string
+	if len(args) > 1 {
+		gameIDs = args[1:]
+	} else {
+		ids, err := database.GetGameList()
+		if err != nil {
+			return err
+		}
+		gameIDs = ids
+	}
+
+	m := manifest{FormatVersion: archiveFormatVersion, CreatedAt: time.Now().UTC()}
+	files := make(map[string][]byte)
+	for _, id := range gameIDs {
+		archive, err := database.ExportGame(id)
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(archive, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		entry := manifestEntry{
+			GameID: id,
+			File:   "games/" + id + ".json",
+			SHA256: checksum(data),
+			Rows:   make(map[string]int),
+		}
+		for table, rows := range archive.Tables {
+			entry.Rows[table] = len(rows)
+		}
+		m.Games = append(m.Games, entry)
+		files[entry.File] = data
+	}
+
+	manifestData, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file so a failed backup never leaves a truncated archive
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	gz := gzip.NewWriter(tmp)
+	tw := tar.NewWriter(gz)
+	if err := writeTarFile(tw, manifestName, manifestData); err != nil {
+		return err
+	}
+	for _, entry := range m.Games {
+		if err := writeTarFile(tw, entry.File, files[entry.File]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Backed up %d game(s) to %s\n", len(m.Games), path)
+	return nil
+}
+
+// restoreGames verifies an archive and then replaces each game it contains
+func restoreGames(database *db.DB, out io.Writer, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("missing archive path")
+	}
+
+	m, archives, err := readBackup(args[0])
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range m.Games {
+		if err := database.ImportGame(archives[entry.GameID]); err != nil {
+			return fmt.Errorf("restore game %s: %w", entry.GameID, err)
+		}
+		fmt.Fprintf(out, "Restored %s\n", entry.GameID)
+	}
+	fmt.Fprintf(out, "Restored %d game(s) from %s\n", len(m.Games), args[0])
+	return nil
+}
+
+// verifyBackup checks an archive without touching the database
+func verifyBackup(out io.Writer, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("missing archive path")
+	}
+
+	m, _, err := readBackup(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Archive OK: format %d, created %s, %d game(s)\n",
+		m.FormatVersion, m.CreatedAt.Format(time.RFC3339), len(m.Games))
+	for _, entry := range m.Games {
+		tables := make([]string, 0, len(entry.Rows))
+		for table, n := range entry.Rows {
+			tables = append(tables, fmt.Sprintf("%s=%d", table, n))
+		}
+		sort.Strings(tables)
+		fmt.Fprintf(out, "  %s  %s\n", entry.GameID, strings.Join(tables, " "))
+	}
+	return nil
+}
+
+// readBackup loads an archive and checks every file against the manifest:
+// checksums, row counts, and that no file is missing or unexpected
+func readBackup(path string) (*manifest, map[string]*db.GameArchive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("not a gzip archive: %w", err)
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("corrupt archive: %w", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("corrupt archive: %w", err)
+		}
+		files[hdr.Name] = data
+	}
+
+	manifestData, ok := files[manifestName]
+	if !ok {
+		return nil, nil, fmt.Errorf("archive has no %s", manifestName)
+	}
+	var m manifest
+	if err := json.Unmarshal(manifestData, &m); err != nil {
+		return nil, nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if m.FormatVersion != archiveFormatVersion {
+		return nil, nil, fmt.Errorf("unsupported archive format %d", m.FormatVersion)
+	}
+
+	archives := make(map[string]*db.GameArchive, len(m.Games))
+	for _, entry := range m.Games {
+		data, ok := files[entry.File]
+		if !ok {
+			return nil, nil, fmt.Errorf("game %s: missing %s", entry.GameID, entry.File)
+		}
+		if sum := checksum(data); sum != entry.SHA256 {
+			return nil, nil, fmt.Errorf("game %s: checksum mismatch (got %s, want %s)", entry.GameID, sum, entry.SHA256)
+		}
+
+		var archive db.GameArchive
+		if err := json.Unmarshal(data, &archive); err != nil {
+			return nil, nil, fmt.Errorf("game %s: %w", entry.GameID, err)
+		}
+		if archive.GameID != entry.GameID {
+			return nil, nil, fmt.Errorf("game %s: file holds game %s", entry.GameID, archive.GameID)
+		}
+		for table, n := range entry.Rows {
+			if len(archive.Tables[table]) != n {
+				return nil, nil, fmt.Errorf("game %s: %s has %d rows, manifest says %d",
+					entry.GameID, table, len(archive.Tables[table]), n)
+			}
+		}
+		archives[entry.GameID] = &archive
+		delete(files, entry.File)
+	}
+
+	delete(files, manifestName)
+	for name := range files {
+		return nil, nil, fmt.Errorf("unexpected file %s in archive", name)
+	}
+
+	return &m, archives, nil
+}
+
+// writeTarFile adds one regular file to a tar archive
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// checksum returns the hex SHA-256 of data
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/db"
+	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+	mw "github.com/qninhdt/world-card-ai-2/server/internal/middleware"
+)
+
+const usage = `Usage: admin [flags] <command> [args]
+
+Store commands (read the database directly):
+  list                       list games with owner and snapshot count
+  show <game>                print the latest saved state and DAG
+  diff <game> [from] [to]    diff two snapshots (default: the last two)
+  backup <archive> [game]    write all games (or one game) to a .tar.gz archive
+  restore <archive>          verify an archive and restore every game in it
+  verify <archive>           check an archive's checksums without restoring
+  telemetry <file> [salt]    write anonymized gameplay traces as JSON lines ("-" for stdout)
+
+Server commands (call the admin API of a running server):
+  save <game>                force-save an in-memory game
+  unload <game>              save a game and drop it from memory
+  recompile <game>           recompile DAG conditions and report failures
+  requeue <game>             re-queue failed generation jobs
+
+Flags:
+`
+
+func main() {
+	dbPath := flag.String("db", envOr("DB_PATH", "game.db"), "SQLite database path")
+	baseURL := flag.String("url", "http://localhost:8080", "server base URL")
+	userID := flag.String("user", "admin", "user ID to sign tokens for (must be in the server's ADMIN_USERS)")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cmd, args := args[0], args[1:]
+
+	var err error
+	switch cmd {
+	case "list", "show", "diff", "backup", "restore", "telemetry":
+		var database *db.DB
+		database, err = db.NewDB(*dbPath)
+		if err != nil {
+			log.Fatalf("Failed to open database: %v", err)
+		}
+		defer database.Close()
+
+		switch cmd {
+		case "list":
+			err = listGames(database, os.Stdout)
+		case "show":
+			err = showGame(database, os.Stdout, args)
+		case "diff":
+			err = diffSnapshots(database, os.Stdout, args)
+		case "backup":
+			err = backupGames(database, os.Stdout, args)
+		case "restore":
+			err = restoreGames(database, os.Stdout, args)
+		case "telemetry":
+			err = exportTelemetry(database, os.Stdout, args)
+		}
+	case "verify":
+		err = verifyBackup(os.Stdout, args)
+	case "save", "unload", "recompile", "requeue":
+		err = callAdmin(*baseURL, *userID, cmd, args, os.Stdout)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalf("%s: %v", cmd, err)
+	}
+}
+
+// envOr returns an env var or a fallback
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// gameArg returns the single game ID argument
+func gameArg(args []string) (string, error) {
+	if len(args) < 1 {
+		return "", fmt.Errorf("missing game ID")
+	}
+	return args[0], nil
+}
+
+// printJSON writes v as indented JSON
+func printJSON(out io.Writer, v interface{}) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// listGames prints every game with its owner and snapshot count
+func listGames(store db.Store, out io.Writer) error {
+	gameIDs, err := store.GetGameList()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "GAME\tOWNER\tSNAPSHOTS\tLAST SAVED")
+	for _, id := range gameIDs {
+		owner, err := store.GetGameOwner(id)
+		if err != nil {
+			owner = "-"
+		}
+		snapshots, _, err := store.ListSnapshots(id, db.Page{})
+		if err != nil {
+			return err
+		}
+		lastSaved := "-"
+		if len(snapshots) > 0 {
+			lastSaved = snapshots[len(snapshots)-1].CreatedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", id, owner, len(snapshots), lastSaved)
+	}
+	return tw.Flush()
+}
+
+// exportTelemetry writes the gameplay dataset to a file, or to out for
+// "-". A salt keeps the hashed IDs stable across exports.
+func exportTelemetry(store db.Store, out io.Writer, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("missing output path")
+	}
+	salt := ""
+	if len(args) > 1 {
+		salt = args[1]
+	}
+
+	if args[0] == "-" {
+		_, err := db.ExportTelemetry(store, out, salt)
+		return err
+	}
+	f, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+	written, err := db.ExportTelemetry(store, f, salt)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "wrote %d traces to %s\n", written, args[0])
+	return nil
+}
+
+// showGame prints the latest saved state, DAG and snapshot list of a game
+func showGame(store db.Store, out io.Writer, args []string) error {
+	gameID, err := gameArg(args)
+	if err != nil {
+		return err
+	}
+
+	state, dag, err := store.LoadGame(gameID)
+	if err != nil {
+		return err
+	}
+	snapshots, _, err := store.ListSnapshots(gameID, db.Page{})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(out, map[string]interface{}{
+		"game_id":   gameID,
+		"snapshots": snapshots,
+		"state":     state,
+		"dag":       dag,
+	})
+}
+
+// diffSnapshots prints the patch ops between two snapshots of a game
+func diffSnapshots(store db.Store, out io.Writer, args []string) error {
+	gameID, err := gameArg(args)
+	if err != nil {
+		return err
+	}
+
+	var fromID, toID int64
+	switch len(args) {
+	case 1:
+		snapshots, _, err := store.ListSnapshots(gameID, db.Page{})
+		if err != nil {
+			return err
+		}
+		if len(snapshots) < 2 {
+			return fmt.Errorf("game %s has %d snapshot(s), need 2", gameID, len(snapshots))
+		}
+		fromID = snapshots[len(snapshots)-2].ID
+		toID = snapshots[len(snapshots)-1].ID
+	case 3:
+		if fromID, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+			return fmt.Errorf("invalid snapshot ID %q", args[1])
+		}
+		if toID, err = strconv.ParseInt(args[2], 10, 64); err != nil {
+			return fmt.Errorf("invalid snapshot ID %q", args[2])
+		}
+	default:
+		return fmt.Errorf("expected <game> or <game> <from> <to>")
+	}
+
+	from, _, err := store.LoadSnapshot(gameID, fromID)
+	if err != nil {
+		return fmt.Errorf("snapshot %d: %w", fromID, err)
+	}
+	to, _, err := store.LoadSnapshot(gameID, toID)
+	if err != nil {
+		return fmt.Errorf("snapshot %d: %w", toID, err)
+	}
+
+	return printJSON(out, map[string]interface{}{
+		"from": fromID,
+		"to":   toID,
+		"ops":  game.DiffStates(from, to),
+	})
+}
+
+// callAdmin invokes an admin endpoint on a running server
+func callAdmin(baseURL, userID, cmd string, args []string, out io.Writer) error {
+	gameID, err := gameArg(args)
+	if err != nil {
+		return err
+	}
+
+	token, err := mw.GenerateToken(userID)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/api/admin/games/"+gameID+"/"+cmd, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	var env struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &env); err != nil {
+		return err
+	}
+	var data interface{}
+	if err := json.Unmarshal(env.Data, &data); err != nil {
+		return err
+	}
+	return printJSON(out, data)
+}
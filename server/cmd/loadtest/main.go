@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// endpointStats collects latencies and errors for one endpoint
+type endpointStats struct {
+	latencies []time.Duration
+	errors    int
+}
+
+// recorder aggregates results across all simulated players
+type recorder struct {
+	mu    sync.Mutex
+	stats map[string]*endpointStats
+}
+
+// record stores one request outcome
+func (r *recorder) record(endpoint string, latency time.Duration, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, exists := r.stats[endpoint]
+	if !exists {
+		s = &endpointStats{}
+		r.stats[endpoint] = s
+	}
+	s.latencies = append(s.latencies, latency)
+	if !ok {
+		s.errors++
+	}
+}
+
+// percentile returns the p-th percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(float64(len(sorted)-1) * p)
+	return sorted[idx]
+}
+
+// report prints per-endpoint latency percentiles and error rates
+func (r *recorder) report(out io.Writer, elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	endpoints := make([]string, 0, len(r.stats))
+	total := 0
+	for name, s := range r.stats {
+		endpoints = append(endpoints, name)
+		total += len(s.latencies)
+	}
+	sort.Strings(endpoints)
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENDPOINT\tREQUESTS\tERRORS\tERROR RATE\tP50\tP95\tP99")
+	for _, name := range endpoints {
+		s := r.stats[name]
+		sorted := append([]time.Duration(nil), s.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f%%\t%v\t%v\t%v\n",
+			name, len(sorted), s.errors,
+			100*float64(s.errors)/float64(len(sorted)),
+			percentile(sorted, 0.50).Round(time.Microsecond),
+			percentile(sorted, 0.95).Round(time.Microsecond),
+			percentile(sorted, 0.99).Round(time.Microsecond))
+	}
+	tw.Flush()
+
+	fmt.Fprintf(out, "\n%d requests in %v (%.1f req/s)\n", total, elapsed.Round(time.Millisecond), float64(total)/elapsed.Seconds())
+}
+
+// envelope is the API response wrapper
+type envelope struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data"`
+	Error   string          `json:"error"`
+}
+
+// player drives one simulated game against the server
+type player struct {
+	id      int
+	baseURL string
+	key     string
+	client  *http.Client
+	rec     *recorder
+	rng     *rand.Rand
+	gameID  string
+}
+
+// call performs a request and records it under the endpoint label
+func (p *player) call(endpoint, method, path string, body interface{}) (*envelope, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, p.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", p.key)
+
+	start := time.Now()
+	resp, err := p.client.Do(req)
+	if err != nil {
+		p.rec.record(endpoint, time.Since(start), false)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	latency := time.Since(start)
+
+	ok := err == nil && resp.StatusCode < 400
+	p.rec.record(endpoint, latency, ok)
+	if !ok {
+		return nil, fmt.Errorf("%s %s: status %d", method, path, resp.StatusCode)
+	}
+
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, err
+	}
+	return &env, nil
+}
+
+// run plays the create, draw, resolve, advance loop
+func (p *player) run(iterations int, seed int64) {
+	env, err := p.call("create", http.MethodPost, "/api/games", map[string]interface{}{"seed": seed})
+	if err != nil {
+		log.Printf("player %d: create failed: %v", p.id, err)
+		return
+	}
+	var info struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(env.Data, &info); err != nil || info.ID == "" {
+		log.Printf("player %d: create returned no game id", p.id)
+		return
+	}
+	p.gameID = info.ID
+	gamePath := "/api/games/" + p.gameID
+
+	for i := 0; i < iterations; i++ {
+		env, err := p.call("draw", http.MethodPost, gamePath+"/draw", nil)
+		if err == nil {
+			var drawn []struct {
+				ID string `json:"id"`
+			}
+			json.Unmarshal(env.Data, &drawn)
+			for _, card := range drawn {
+				direction := "left"
+				if p.rng.Intn(2) == 1 {
+					direction = "right"
+				}
+				p.call("resolve", http.MethodPost, gamePath+"/resolve", map[string]string{
+					"card_id":   card.ID,
+					"direction": direction,
+				})
+			}
+		}
+
+		p.call("advance", http.MethodPost, gamePath+"/advance", nil)
+		p.call("get", http.MethodGet, gamePath, nil)
+	}
+}
+
+func main() {
+	baseURL := flag.String("url", "http://localhost:8080", "server base URL")
+	players := flag.Int("players", 10, "number of concurrent simulated players")
+	iterations := flag.Int("iterations", 20, "draw/resolve/advance loops per player")
+	seed := flag.Int64("seed", 1, "base seed for procedural worlds and choices")
+	key := flag.String("key", os.Getenv("LOADTEST_API_KEY"), "personal API key the players sign in with (default: $LOADTEST_API_KEY)")
+	timeout := flag.Duration("timeout", 10*time.Second, "per-request timeout")
+	flag.Parse()
+
+	if *key == "" {
+		log.Fatalf("A personal API key is required (-key or LOADTEST_API_KEY)")
+	}
+
+	rec := &recorder{stats: make(map[string]*endpointStats)}
+	client := &http.Client{
+		Timeout: *timeout,
+		Transport: &http.Transport{
+			MaxIdleConnsPerHost: *players,
+		},
+	}
+
+	log.Printf("Starting %d players x %d iterations against %s", *players, *iterations, *baseURL)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < *players; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			p := &player{
+				id:      id,
+				baseURL: *baseURL,
+				key:     *key,
+				client:  client,
+				rec:     rec,
+				rng:     rand.New(rand.NewSource(*seed + int64(id))),
+			}
+			p.run(*iterations, *seed+int64(id))
+		}(i)
+	}
+	wg.Wait()
+
+	rec.report(os.Stdout, time.Since(start))
+}
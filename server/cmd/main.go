@@ -1,15 +1,36 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
 	"github.com/qninhdt/world-card-ai-2/server/internal/api"
+	"github.com/qninhdt/world-card-ai-2/server/internal/cluster"
 	"github.com/qninhdt/world-card-ai-2/server/internal/db"
+	mw "github.com/qninhdt/world-card-ai-2/server/internal/middleware"
+	"github.com/qninhdt/world-card-ai-2/server/internal/oauth"
+	"github.com/qninhdt/world-card-ai-2/server/internal/tracing"
 )
 
+// defaultShutdownTimeout bounds how long shutdown waits for requests
+const defaultShutdownTimeout = 30 * time.Second
+
+// defaultGameIdleTimeout is how long a game may go unused before it is
+// saved and evicted from memory
+const defaultGameIdleTimeout = 30 * time.Minute
+
+// tracingFlushTimeout bounds how long exporting the last spans may take
+const tracingFlushTimeout = 5 * time.Second
+
 func main() {
 	// Get configuration from environment
 	port := os.Getenv("PORT")
@@ -17,6 +38,38 @@ func main() {
 		port = "8080"
 	}
 
+	// How long in-flight requests may take to finish once shutdown starts
+	drain := defaultShutdownTimeout
+	if v := os.Getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Fatalf("Invalid SHUTDOWN_TIMEOUT: %q", v)
+		}
+		drain = d
+	}
+
+	// Anyone can sign tokens with the public development secret, so it is
+	// only used when asked for
+	if mw.UsingDefaultSecret() {
+		if os.Getenv("ALLOW_DEV_SECRET") != "true" {
+			log.Fatalf("JWT_SECRET is not set; set it, or ALLOW_DEV_SECRET=true to sign tokens with the public development secret")
+		}
+		log.Printf("JWT_SECRET is not set; tokens are signed with the public development secret")
+	}
+
+	// Export trace spans when TRACING_EXPORTER is set
+	shutdownTracing, err := tracing.SetupFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to configure tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("Failed to flush traces: %v", err)
+		}
+	}()
+
 	dbPath := os.Getenv("DB_PATH")
 	if dbPath == "" {
 		dbPath = "game.db"
@@ -29,14 +82,138 @@ func main() {
 	}
 	defer database.Close()
 
+	// Price LLM calls and enforce spend limits service-wide
+	monitor, err := agents.NewSpendMonitorFromEnv(database)
+	if err != nil {
+		log.Fatalf("Failed to configure spend monitor: %v", err)
+	}
+	agents.SetSpendMonitor(monitor)
+
+	// Keep LLM responses that fail to parse or validate for analytics
+	agents.SetValidationStore(database)
+
 	// Create API server
 	server := api.NewServer(database)
+	defer server.Close()
+
+	// Share games across instances when a cluster is configured
+	ring, err := cluster.NewRingFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure cluster: %v", err)
+	}
+	if ring != nil {
+		if err := server.EnableClustering(ring, os.Getenv("CLUSTER_SECRET")); err != nil {
+			log.Fatalf("Failed to configure cluster: %v", err)
+		}
+		log.Printf("Cluster of %d instances, this one is %s", len(ring.Nodes()), ring.Self())
+	}
+
+	// Replicas sharing a database serialize writes to each game
+	if ring != nil || os.Getenv("GAME_LOCKS") == "true" {
+		server.EnableGameLocks()
+	}
+
+	// Bound concurrent Architect calls
+	if v := os.Getenv("WORLDGEN_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Fatalf("Invalid WORLDGEN_CONCURRENCY: %q", v)
+		}
+		server.SetWorldGenConcurrency(n)
+	}
+
+	// Save and evict idle games so memory follows the active players
+	idle := defaultGameIdleTimeout
+	if v := os.Getenv("GAME_IDLE_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Fatalf("Invalid GAME_IDLE_TIMEOUT: %q", v)
+		}
+		idle = d
+	}
+	maxLoaded := 0
+	if v := os.Getenv("MAX_LOADED_GAMES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatalf("Invalid MAX_LOADED_GAMES: %q", v)
+		}
+		maxLoaded = n
+	}
+	server.EnableEviction(idle, maxLoaded)
+
+	// Override the per-route-group rate limits
+	if v := os.Getenv("RATE_LIMITS"); v != "" {
+		limits, err := mw.ParseRateLimits(v)
+		if err != nil {
+			log.Fatalf("Invalid RATE_LIMITS: %v", err)
+		}
+		for group, limit := range limits {
+			server.SetRateLimit(group, limit)
+		}
+	}
+
+	// Believe X-Forwarded-For only from our own proxies
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		proxies, err := mw.ParseTrustedProxies(v)
+		if err != nil {
+			log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+		}
+		server.TrustProxies(proxies)
+	}
+
+	// Tune how freely the Writer samples each job type
+	if v := os.Getenv("WRITER_SAMPLING"); v != "" {
+		schedule, err := agents.ParseSamplingSchedule(v)
+		if err != nil {
+			log.Fatalf("Invalid WRITER_SAMPLING: %v", err)
+		}
+		agents.SetWriterSampling(schedule)
+		log.Printf("Writer sampling: %s", agents.WriterSampling())
+	}
+
+	// Sign in with the OAuth providers that have credentials
+	if providers := oauth.FromEnv(); len(providers) > 0 {
+		server.EnableOAuth(providers, os.Getenv("OAUTH_CLIENT_URL"))
+		log.Printf("OAuth sign-in with %d providers", len(providers))
+	}
 
 	// Start HTTP server
 	addr := fmt.Sprintf(":%s", port)
-	log.Printf("Starting server on %s", addr)
+	httpServer := &http.Server{Addr: addr, Handler: server}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Starting server on %s", addr)
+		serveErr <- httpServer.ListenAndServe()
+	}()
 
-	if err := http.ListenAndServe(addr, server); err != nil {
+	select {
+	case err := <-serveErr:
 		log.Fatalf("Server error: %v", err)
+	case <-ctx.Done():
+	}
+	stop() // a second signal kills the process
+
+	// Stop accepting connections and let in-flight requests finish, so a
+	// card resolution or week advance is never cut off halfway
+	log.Printf("Shutting down, draining requests for up to %s", drain)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Shutdown did not finish cleanly: %v", err)
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Server error: %v", err)
+	}
+
+	// Games resolved since their last save would otherwise be lost; the
+	// next start restores them from the database on first use
+	saved, failed := server.UnloadGames()
+	if failed > 0 {
+		log.Printf("Failed to save %d games", failed)
 	}
+	log.Printf("Server stopped, %d games saved", saved)
 }
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/db"
+	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+	"github.com/qninhdt/world-card-ai-2/server/internal/validation"
+)
+
+const usage = `Usage: pregen [flags] <theme>...
+
+Generates worlds for each theme until its pool in the world library holds
+-n, so players can start them instantly with {"library": "<theme>"}. Only
+worlds that pass the full validation suite are kept, each with a starter
+deck of its first week's cards so the first draw needs no Writer call.
+The run stops when the
+generation kill switch is on, the daily hard limit is reached, or -budget
+is spent.
+
+Flags:
+`
+
+// generateTimeout bounds one Architect or Writer call
+const generateTimeout = 5 * time.Minute
+
+// errBudgetSpent stops a run once its own budget is used up
+var errBudgetSpent = errors.New("run budget spent")
+
+// generator builds a world schema for a theme
+type generator func(ctx context.Context, theme string) (*agents.WorldGenSchema, error)
+
+// deckWriter writes a week of card definitions for a world's jobs
+type deckWriter func(ctx context.Context, jobs []agents.CardGenJob, worldContext map[string]interface{}) ([]map[string]interface{}, error)
+
+// pregen fills theme pools in the world library
+type pregen struct {
+	store       db.Store
+	generate    generator
+	writeDeck   deckWriter
+	monitor     *agents.SpendMonitor
+	target      int     // worlds each theme's pool should hold
+	budget      float64 // USD this run may spend; 0 means no limit
+	maxFailures int     // failed generations in a row before a theme is skipped
+	out         io.Writer
+}
+
+func main() {
+	dbPath := flag.String("db", envOr("DB_PATH", "game.db"), "SQLite database path")
+	target := flag.Int("n", 10, "worlds each theme's pool should hold")
+	budget := flag.Float64("budget", 0, "most USD this run may spend (0 = no limit beyond SPEND_HARD_LIMIT)")
+	maxFailures := flag.Int("max-failures", 3, "failed generations in a row before a theme is skipped")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	themes := flag.Args()
+	if len(themes) == 0 || *target < 1 || *budget < 0 || *maxFailures < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	for _, theme := range themes {
+		if err := validation.ValidateWorldPrompt(theme); err != nil {
+			log.Fatalf("Invalid theme %q: %v", theme, err)
+		}
+	}
+
+	database, err := db.NewDB(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	// Spend counts toward the same daily limits as the server's
+	monitor, err := agents.NewSpendMonitorFromEnv(database)
+	if err != nil {
+		log.Fatalf("Failed to configure spend monitor: %v", err)
+	}
+	agents.SetSpendMonitor(monitor)
+	agents.SetValidationStore(database)
+
+	p := &pregen{
+		store: database,
+		generate: func(ctx context.Context, theme string) (*agents.WorldGenSchema, error) {
+			return agents.NewArchitectAgent().GenerateWorld(ctx, theme)
+		},
+		writeDeck:   agents.NewWriterAgent().GenerateWeek,
+		monitor:     monitor,
+		target:      *target,
+		budget:      *budget,
+		maxFailures: *maxFailures,
+		out:         os.Stdout,
+	}
+	added, err := p.run(themes)
+	fmt.Fprintf(os.Stdout, "added %d worlds\n", added)
+	if err != nil {
+		log.Fatalf("Stopped: %v", err)
+	}
+}
+
+// envOr returns an environment variable or a fallback
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// run tops up each theme's pool and returns how many worlds it added. A
+// theme that keeps failing is skipped; quota errors end the run.
+func (p *pregen) run(themes []string) (int, error) {
+	pools, err := p.store.LibraryThemes()
+	if err != nil {
+		return 0, err
+	}
+	available := make(map[string]int, len(pools))
+	for _, pool := range pools {
+		available[pool.Theme] = pool.Available
+	}
+
+	startSpend := p.monitor.Status().SpentUSD
+	added := 0
+	for _, theme := range themes {
+		failures := 0
+		for available[theme] < p.target {
+			if err := p.allow(startSpend); err != nil {
+				return added, err
+			}
+
+			world, err := p.prepare(theme)
+			if err != nil {
+				failures++
+				fmt.Fprintf(p.out, "%s: %v\n", theme, err)
+				if failures >= p.maxFailures {
+					fmt.Fprintf(p.out, "%s: skipped after %d failures\n", theme, failures)
+					break
+				}
+				continue
+			}
+			if err := p.store.AddLibraryWorld(theme, world); err != nil {
+				return added, err
+			}
+			failures = 0
+			available[theme]++
+			added++
+			fmt.Fprintf(p.out, "%s: %d/%d\n", theme, available[theme], p.target)
+		}
+	}
+	return added, nil
+}
+
+// allow reports whether another world may be generated: the kill switch is
+// off and this run's budget is not spent
+func (p *pregen) allow(startSpend float64) error {
+	if err := p.monitor.Allow(); err != nil {
+		return err
+	}
+	if p.budget > 0 && p.monitor.Status().SpentUSD-startSpend >= p.budget {
+		return errBudgetSpent
+	}
+	return nil
+}
+
+// generateValid generates a world for a theme and runs every check it must
+// pass to be played
+func (p *pregen) generateValid(theme string) (*agents.WorldGenSchema, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), generateTimeout)
+	defer cancel()
+
+	schema, err := p.generate(ctx, theme)
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		return nil, fmt.Errorf("architect returned no world")
+	}
+	if review := game.ReviewWorld(schema); !review.Valid {
+		return nil, fmt.Errorf("invalid world: %s", strings.Join(review.Errors, "; "))
+	}
+	return schema, nil
+}
+
+// prepare generates a valid world and its starter deck
+func (p *pregen) prepare(theme string) (*db.LibraryWorld, error) {
+	schema, err := p.generateValid(theme)
+	if err != nil {
+		return nil, err
+	}
+	deck, err := p.starterDeck(schema)
+	if err != nil {
+		return nil, err
+	}
+	return &db.LibraryWorld{Schema: schema, Deck: deck}, nil
+}
+
+// starterDeck writes the first week's cards for a world, from the jobs and
+// context a new game of it starts with
+func (p *pregen) starterDeck(schema *agents.WorldGenSchema) ([]map[string]interface{}, error) {
+	engine, err := game.NewGameEngine("pregen", schema)
+	if err != nil {
+		return nil, err
+	}
+	pending := engine.PendingJobs()
+	jobs := make([]agents.CardGenJob, 0, len(pending))
+	for _, job := range pending {
+		jobs = append(jobs, agents.CardGenJob{Type: job.JobType, Context: job.Context})
+	}
+	worldContext := engine.GetGenerationContext()
+	worldContext["preferences"] = db.DefaultUserPreferences().Generation()
+
+	ctx, cancel := context.WithTimeout(context.Background(), generateTimeout)
+	defer cancel()
+
+	deck, err := p.writeDeck(ctx, jobs, worldContext)
+	if err != nil {
+		return nil, fmt.Errorf("starter deck: %w", err)
+	}
+	// Only cards the engine accepts count toward the deck
+	if engine.AddCardsFromDefs(deck) == 0 {
+		return nil, fmt.Errorf("starter deck: writer returned no usable cards")
+	}
+	return deck, nil
+}
@@ -3,8 +3,15 @@ package agents
 import (
 	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
 )
 
 // TestOpenRouterClient tests the OpenRouter client
@@ -49,7 +56,7 @@ func TestOpenRouterClient(t *testing.T) {
 func TestArchitectAgent(t *testing.T) {
 	architect := NewArchitectAgent()
 
-	if architect.client.apiKey == "" {
+	if architect.client.(*OpenRouterClient).apiKey == "" {
 		t.Skip("OPENROUTER_API_KEY not set, skipping integration test")
 	}
 
@@ -82,7 +89,7 @@ func TestArchitectAgent(t *testing.T) {
 func TestWriterAgent(t *testing.T) {
 	writer := NewWriterAgent()
 
-	if writer.client.apiKey == "" {
+	if writer.client.(*OpenRouterClient).apiKey == "" {
 		t.Skip("OPENROUTER_API_KEY not set, skipping integration test")
 	}
 
@@ -204,7 +211,7 @@ func TestWorldGeneration(t *testing.T) {
 	}
 
 	architect := NewArchitectAgent()
-	if architect.client.apiKey == "" {
+	if architect.client.(*OpenRouterClient).apiKey == "" {
 		t.Skip("OPENROUTER_API_KEY not set")
 	}
 
@@ -250,7 +257,7 @@ func TestCardGeneration(t *testing.T) {
 	}
 
 	writer := NewWriterAgent()
-	if writer.client.apiKey == "" {
+	if writer.client.(*OpenRouterClient).apiKey == "" {
 		t.Skip("OPENROUTER_API_KEY not set")
 	}
 
@@ -283,3 +290,578 @@ func TestCardGeneration(t *testing.T) {
 
 	t.Logf("✓ Generated %d cards", len(cards))
 }
+
+// TestDeterministicArchitect tests that seeded worlds are reproducible
+func TestDeterministicArchitect(t *testing.T) {
+	ctx := context.Background()
+
+	a, err := NewDeterministicArchitect(42).GenerateWorld(ctx, "")
+	if err != nil {
+		t.Fatalf("GenerateWorld failed: %v", err)
+	}
+	b, _ := NewDeterministicArchitect(42).GenerateWorld(ctx, "")
+
+	aJSON, _ := json.Marshal(a)
+	bJSON, _ := json.Marshal(b)
+	if string(aJSON) != string(bJSON) {
+		t.Error("Expected same seed to produce the same world")
+	}
+
+	distinct := false
+	for seed := int64(0); seed < 10; seed++ {
+		other, _ := json.Marshal(BuildDeterministicWorld(seed, ""))
+		if string(other) != string(aJSON) {
+			distinct = true
+			break
+		}
+	}
+	if !distinct {
+		t.Error("Expected different seeds to produce different worlds")
+	}
+
+	ids := make(map[string]bool)
+	for _, node := range a.PlotNodes {
+		ids[node.ID] = true
+	}
+	for _, node := range a.PlotNodes {
+		for _, succ := range node.SuccessorIDs {
+			if !ids[succ] {
+				t.Errorf("Node %s has unknown successor %s", node.ID, succ)
+			}
+		}
+	}
+	if len(a.Stats) != 4 || len(a.NPCs) != 3 || len(a.InitialStats) != 4 {
+		t.Errorf("Unexpected world shape: %d stats, %d npcs", len(a.Stats), len(a.NPCs))
+	}
+}
+
+// TestReloadPrompts tests swapping templates at runtime from an override directory
+func TestReloadPrompts(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "writer_system.j2"), []byte("v1 system"), 0644)
+	os.WriteFile(filepath.Join(dir, "writer_user.j2"), []byte("{{ jobs | length }} jobs"), 0644)
+	defer func() {
+		prompts.dir = ""
+		ReloadPrompts("")
+	}()
+
+	result := ReloadPrompts(dir)
+	if len(result.Loaded) != 2 || len(result.Missing) != 3 {
+		t.Errorf("Expected 2 loaded and 3 missing, got %+v", result)
+	}
+
+	// The engine's own rules follow the template
+	system, user := RenderWriterPrompts([]CardGenJob{{Type: "plot"}}, nil)
+	if system != "v1 system\n\n"+writerEngineRules || user != "1 jobs" {
+		t.Errorf("Unexpected render: %q / %q", system, user)
+	}
+
+	os.WriteFile(filepath.Join(dir, "writer_system.j2"), []byte("v2 system"), 0644)
+	if system, _ := RenderWriterPrompts(nil, nil); !strings.HasPrefix(system, "v1 system\n\n") {
+		t.Errorf("Expected cached template before reload, got %q", system)
+	}
+	ReloadPrompts("")
+	if system, _ := RenderWriterPrompts(nil, nil); !strings.HasPrefix(system, "v2 system\n\n") {
+		t.Errorf("Expected reloaded template, got %q", system)
+	}
+
+	os.WriteFile(filepath.Join(dir, "writer_user.j2"), []byte("{{ stat_names }} ({{ 2 * stat_names|length }} death cards)"), 0644)
+	ReloadPrompts("")
+	stats := []map[string]interface{}{{"id": "gold", "name": "Gold"}}
+	if _, user := RenderWriterPrompts(nil, map[string]interface{}{"stats": stats}); user != `[{"id":"gold","name":"Gold"}] (2 death cards)` {
+		t.Errorf("Expected the stat list in the user prompt, got %q", user)
+	}
+
+	// Missing architect templates fall back to the inline prompt
+	system, user = RenderArchitectPrompts("pirates", 4, Preferences{})
+	if !strings.Contains(system, "The Architect") || user != "pirates" {
+		t.Errorf("Expected architect fallback, got %q", user)
+	}
+}
+
+// TestEngineRules tests that the templates shared with the Python game
+// leave out what only this engine runs, and that the rendered prompts add it
+func TestEngineRules(t *testing.T) {
+	goOnly := []string{"random_outcome", "skill_check", "give_item", "add_resource", "spend_resource", "update_affinity", "add_fact", "`affinity`", "stat_multipliers", "arc_id", "exclusive_group", "fallback_condition", "ending_tier"}
+	for _, name := range []string{"writer_system.j2", "writer_user.j2", "architect_system.j2"} {
+		content, err := os.ReadFile(filepath.Join("..", "..", "..", "prompts", name))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		for _, word := range goOnly {
+			if strings.Contains(string(content), word) {
+				t.Errorf("Expected shared %s to leave out %s", name, word)
+			}
+		}
+	}
+
+	writer, _ := RenderWriterPrompts(nil, nil)
+	architect, _ := RenderArchitectPrompts("pirates", 4, Preferences{})
+	for _, word := range goOnly {
+		if !strings.Contains(writer+architect, word) {
+			t.Errorf("Expected the rendered prompts to describe %s", word)
+		}
+	}
+
+	worldContext := map[string]interface{}{
+		"items":     []map[string]interface{}{{"id": "iron_key", "description": "Opens the old gate", "held": 1}},
+		"resources": []map[string]interface{}{{"id": "gold", "description": "Coin", "balance": 20, "bankruptcy": true}},
+	}
+	_, user := RenderWriterPrompts(nil, worldContext)
+	for _, want := range []string{"- iron_key: Opens the old gate (held: 1)", "- gold: Coin (balance: 20; overspending kills the player)"} {
+		if !strings.Contains(user, want) {
+			t.Errorf("Expected the world lists to hold %q, got %q", want, user)
+		}
+	}
+	if strings.Contains(user, "World macros") {
+		t.Error("Expected no macro list for a world without macros")
+	}
+}
+
+// TestRecordAndReplay tests capturing LLM calls and serving them back offline
+func TestRecordAndReplay(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"id":"r1","choices":[{"index":0,"message":{"role":"assistant","content":"hello"}}]}`))
+	}))
+
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir, RecordCapture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &OpenRouterClient{apiKey: "test", baseURL: server.URL, httpClient: server.Client()}
+	client.SetRecorder(recorder)
+
+	newReq := func(content string) *CompletionRequest {
+		return &CompletionRequest{Model: "m", Messages: []Message{{Role: "user", Content: content}}}
+	}
+	if _, err := client.CreateCompletion(context.Background(), newReq("hi")); err != nil {
+		t.Fatalf("Record call failed: %v", err)
+	}
+	server.Close()
+
+	replayer, _ := NewRecorder(dir, RecordReplay)
+	offline := &OpenRouterClient{baseURL: server.URL, httpClient: http.DefaultClient}
+	offline.SetRecorder(replayer)
+
+	resp, err := offline.CreateCompletion(context.Background(), newReq("hi"))
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if resp.Choices[0].Message.Content != "hello" || calls != 1 {
+		t.Errorf("Expected recorded response without new calls, got %q after %d calls", resp.Choices[0].Message.Content, calls)
+	}
+
+	if _, err := offline.CreateCompletion(context.Background(), newReq("other")); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("Expected missing recording error, got %v", err)
+	}
+}
+
+// recordingAlerter collects alerts for tests
+type recordingAlerter struct {
+	alerts chan SpendAlert
+}
+
+func (a *recordingAlerter) Alert(alert SpendAlert) error {
+	a.alerts <- alert
+	return nil
+}
+
+// TestSpendMonitorThresholds tests alerts, the hard limit kill switch and day rollover
+func TestSpendMonitorThresholds(t *testing.T) {
+	alerter := &recordingAlerter{alerts: make(chan SpendAlert, 4)}
+	pricing := PricingTable{"m": {InputPerMTok: 1, OutputPerMTok: 2}}
+	monitor := NewSpendMonitor(pricing, nil, []float64{1}, 3, alerter)
+	day := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	monitor.now = func() time.Time { return day }
+
+	// $1 input + $1 output
+	rec := monitor.Record("m", 1000000, 500000)
+	if rec.CostUSD != 2 {
+		t.Fatalf("Expected cost 2, got %v", rec.CostUSD)
+	}
+	if alert := <-alerter.alerts; alert.Threshold != 1 || alert.HardLimit {
+		t.Errorf("Expected $1 threshold alert, got %+v", alert)
+	}
+	if err := monitor.Allow(); err != nil {
+		t.Errorf("Expected generation allowed below hard limit, got %v", err)
+	}
+
+	monitor.Record("m", 1000000, 0)
+	if alert := <-alerter.alerts; !alert.HardLimit {
+		t.Errorf("Expected hard limit alert, got %+v", alert)
+	}
+	if err := monitor.Allow(); err != ErrGenerationPaused {
+		t.Errorf("Expected ErrGenerationPaused, got %v", err)
+	}
+
+	day = day.Add(24 * time.Hour)
+	if err := monitor.Allow(); err != nil {
+		t.Errorf("Expected hard limit pause to clear on a new day, got %v", err)
+	}
+	if status := monitor.Status(); status.SpentUSD != 0 {
+		t.Errorf("Expected spend reset, got %v", status.SpentUSD)
+	}
+
+	monitor.Pause()
+	day = day.Add(24 * time.Hour)
+	if err := monitor.Allow(); err != ErrGenerationPaused {
+		t.Errorf("Expected manual pause to survive rollover, got %v", err)
+	}
+}
+
+// failureStore collects validation failures for tests
+type failureStore struct {
+	failures []ValidationFailure
+}
+
+func (s *failureStore) RecordValidationFailure(f ValidationFailure) error {
+	s.failures = append(s.failures, f)
+	return nil
+}
+
+// TestValidationTelemetry tests that unusable responses are recorded with
+// their agent, class, model, prompt variant and organization
+func TestValidationTelemetry(t *testing.T) {
+	var content string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if content == "" {
+			w.Write([]byte(`{"id":"r","choices":[]}`))
+			return
+		}
+		data, _ := json.Marshal(content)
+		w.Write([]byte(`{"id":"r","choices":[{"index":0,"message":{"role":"assistant","content":` + string(data) + `}}]}`))
+	}))
+	defer server.Close()
+
+	store := &failureStore{}
+	SetValidationStore(store)
+	defer SetValidationStore(nil)
+
+	client := &OpenRouterClient{apiKey: "test", baseURL: server.URL, httpClient: server.Client()}
+	architect := &ArchitectAgent{client: client}
+	writer := &WriterAgent{client: client}
+	ctx := WithOrg(context.Background(), "org1")
+
+	content = "not json"
+	if _, err := architect.GenerateWorld(ctx, "a world"); err == nil {
+		t.Fatal("Expected invalid JSON to fail")
+	}
+	content = `{"name": "Nowhere"}`
+	if _, err := architect.GenerateWorld(ctx, "a world"); err == nil {
+		t.Fatal("Expected a world without stats to fail")
+	}
+	content = ""
+	if _, err := writer.GenerateCards(context.Background(), []CardGenJob{{Type: "plot"}}, nil); err == nil {
+		t.Fatal("Expected an empty response to fail")
+	}
+
+	// A bad card is skipped; the rest of the batch is kept
+	content = `[{"type": "info", "id": "ok", "title": "T", "description": "D", "character": "c", "source": "s", "priority": 1},
+		{"type": "choice", "id": "bad", "title": "T"}]`
+	cards, err := writer.GenerateCards(context.Background(), []CardGenJob{{Type: "plot"}}, nil)
+	if err != nil || len(cards) != 1 || cards[0].GetID() != "ok" {
+		t.Fatalf("Expected only the valid card, got %v (%v)", cards, err)
+	}
+
+	want := []struct{ agent, class, org string }{
+		{AgentArchitect, FailureInvalidJSON, "org1"},
+		{AgentArchitect, FailureSchema, "org1"},
+		{AgentWriter, FailureEmptyResponse, ""},
+		{AgentWriter, FailureSchema, ""},
+	}
+	if len(store.failures) != len(want) {
+		t.Fatalf("Expected %d failures, got %+v", len(want), store.failures)
+	}
+	for i, w := range want {
+		f := store.failures[i]
+		if f.Agent != w.agent || f.Class != w.class || f.OrgID != w.org {
+			t.Errorf("Failure %d: expected %s/%s/%q, got %s/%s/%q", i, w.agent, w.class, w.org, f.Agent, f.Class, f.OrgID)
+		}
+		if f.Model == "" || len(f.PromptVariant) != 12 || f.Detail == "" {
+			t.Errorf("Failure %d missing model, variant or detail: %+v", i, f)
+		}
+	}
+}
+
+// TestDangerWarnings tests that endangered stats lead the Writer user prompt
+func TestDangerWarnings(t *testing.T) {
+	_, calm := RenderWriterPrompts(nil, map[string]interface{}{"danger_flags": []map[string]interface{}{}})
+	if strings.Contains(calm, "DANGER") {
+		t.Errorf("Expected no warning without danger flags, got %q", calm)
+	}
+
+	flags := []map[string]interface{}{
+		{"stat_id": "health", "name": "Health", "value": 4, "boundary": 0, "distance": 4, "relief": "raise"},
+	}
+	_, user := RenderWriterPrompts(nil, map[string]interface{}{"danger_flags": flags})
+	if !strings.HasPrefix(user, "DANGER") || !strings.Contains(user, "- Health (health) is 4, 4 from 0: raise it") {
+		t.Errorf("Expected a leading warning for health, got %q", user)
+	}
+}
+
+// TestPacingDirective tests that the Director's directive leads the Writer
+// user prompt
+func TestPacingDirective(t *testing.T) {
+	_, steady := RenderWriterPrompts(nil, map[string]interface{}{"pacing": map[string]interface{}{"directive": "steady"}})
+	if strings.Contains(steady, "PACING") {
+		t.Errorf("Expected no directive while steady, got %q", steady)
+	}
+
+	pacing := map[string]interface{}{"directive": "calm_week", "reason": "stats swung 20.0 points per choice recently"}
+	_, user := RenderWriterPrompts(nil, map[string]interface{}{"pacing": pacing})
+	if !strings.HasPrefix(user, "PACING (stats swung 20.0 points per choice recently): Make this a calm week") {
+		t.Errorf("Expected a leading calm week directive, got %q", user)
+	}
+}
+
+// TestRebornDirective tests that the resurrection mechanic's reborn prompt
+// leads the Writer user prompt only on the first day after a death
+func TestRebornDirective(t *testing.T) {
+	snapshot := map[string]interface{}{"resurrection": map[string]interface{}{"reborn_prompt": "Years have passed; introduce the heir."}}
+	_, alive := RenderWriterPrompts(nil, map[string]interface{}{"snapshot": snapshot})
+	if strings.Contains(alive, "REBORN:") {
+		t.Errorf("Expected no reborn directive mid-life, got %q", alive)
+	}
+
+	_, user := RenderWriterPrompts(nil, map[string]interface{}{"snapshot": snapshot, "is_first_day_after_death": true})
+	if !strings.HasPrefix(user, "REBORN: write the [REBORN] card this way instead: Years have passed; introduce the heir.") {
+		t.Errorf("Expected a leading reborn directive, got %q", user)
+	}
+}
+
+// TestJobDirectives tests that jobs only this engine queues are rendered
+// ahead of the Writer user prompt
+func TestJobDirectives(t *testing.T) {
+	if _, user := RenderWriterPrompts([]CardGenJob{{Type: "plot"}}, nil); strings.Contains(user, "MORE JOB CARDS") {
+		t.Errorf("Expected the template to cover plot jobs, got %q", user)
+	}
+
+	jobs := []CardGenJob{
+		{Type: "obituary", Context: map[string]interface{}{
+			"life": 2, "cause_stat": "health", "boundary": "min",
+			"choices": []map[string]interface{}{{"card": "The Duel", "choice": "Accept"}},
+		}},
+		{Type: "memorial", Context: map[string]interface{}{"npc_id": "elder", "name": "Old Mara", "age": 81}},
+		{Type: "successor", Context: map[string]interface{}{"npc_id": "elder", "name": "Old Mara", "appearance": "a stooped healer"}},
+		{Type: "era", Context: map[string]interface{}{
+			"era_id": "iron_age", "name": "The Iron Age", "description": "Forges replace farms",
+			"retired_npcs": []string{"elder"}, "introduced_npcs": []string{"smith"},
+		}},
+		{Type: "interlude", Context: map[string]interface{}{"cause_stat": "health", "boundary": "min", "card_count": 4}},
+		{Type: "lore", Context: map[string]interface{}{"card_id": "lore_npc_elder", "kind": "npc", "name": "Old Mara", "description": "The village healer"}},
+		{Type: "chronicle", Context: map[string]interface{}{
+			"card_id": "chronicle_week_3", "week": 3, "draft": "The granary burned.",
+			"choices": []map[string]interface{}{{"card": "Fire!", "choice": "Flee"}},
+			"trend":   map[string]int{"food": -20},
+		}},
+		{Type: "departure", Context: map[string]interface{}{"npc_id": "smith", "name": "Bran", "affinity": -85}},
+		{Type: "nudge", Context: map[string]interface{}{"node_id": "stranger", "plot_description": "A stranger arrives", "condition": "stats.health < 20"}},
+	}
+	_, user := RenderWriterPrompts(jobs, nil)
+	for _, want := range []string{
+		"MORE JOB CARDS",
+		`- [OBITUARY] 1 INFO card (id MUST be "obituary_2"): A short obituary (2-4 sentences) for life 2, ended by health at min.`,
+		`[{"card":"The Duel","choice":"Accept"}]`,
+		`- [MEMORIAL] 1 INFO card (id MUST start with "memorial_"): Old Mara has died of old age at 81.`,
+		`- [SUCCESSOR] 1 card introducing someone who takes the place of Old Mara (a stooped healer).`,
+		`- [ERA] 1 INFO card (id MUST start with "era_", e.g. "era_iron_age"): The world passes into The Iron Age: Forges replace farms. Mention who has left (["elder"]) and who has arrived (["smith"]).`,
+		`- [INTERLUDE] 4 cards (ids MUST start with "interlude_") set in a liminal space between lives, after a death by health at min.`,
+		`- [LORE] 1 INFO card (id MUST be "lore_npc_elder"): A short encyclopedia entry (2-4 sentences) on the npc "Old Mara" (The village healer)`,
+		`- [CHRONICLE] 1 INFO card (id MUST be "chronicle_week_3"): Rewrite this summary of week 3 as a short chronicle (2-4 sentences) in the world's voice, keeping every fact: "The granary burned.". Choices: [{"card":"Fire!","choice":"Flee"}]. Stat changes: {"food":-20}.`,
+		`- [DEPARTURE] 1 INFO card (id MUST start with "departure_"): Bran (affinity -85) has had enough of the player and leaves for good.`,
+		`- [NUDGE] 1 INFO card (id MUST start with "nudge_"): The story is overdue for this beat: A stranger arrives. Hint at what would bring it about (it fires once stats.health < 20) without forcing it.`,
+	} {
+		if !strings.Contains(user, want) {
+			t.Errorf("Expected %q in the prompt, got %q", want, user)
+		}
+	}
+}
+
+// TestDeltaGuidance tests the difficulty line in the Writer user prompt
+func TestDeltaGuidance(t *testing.T) {
+	difficulty := map[string]interface{}{"level": 1.2, "typical_delta": 12, "max_delta": 30}
+	_, user := RenderWriterPrompts(nil, map[string]interface{}{"difficulty": difficulty})
+	if !strings.HasPrefix(user, "DIFFICULTY (level 1.2): keep most stat changes around ±12 and none beyond ±30.") {
+		t.Errorf("Expected leading delta guidance, got %q", user)
+	}
+}
+
+// TestDeckPrompt tests that the Writer is asked for the deck's common and
+// info card counts
+func TestDeckPrompt(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "writer_user.j2"), []byte("COMMON CARDS (generate exactly {{ common_count }})"), 0644)
+	ReloadPrompts(dir)
+	t.Cleanup(func() { ReloadPrompts("") })
+
+	deck := map[string]interface{}{"size": 10, "common_count": 8, "info_count": 2}
+	_, user := RenderWriterPrompts(nil, map[string]interface{}{"deck": deck})
+	if !strings.HasPrefix(user, "DECK MIX: make exactly 2 of the 8 common cards INFO cards") {
+		t.Errorf("Expected a leading deck mix directive, got %q", user)
+	}
+	if !strings.HasSuffix(user, "generate exactly 8)") {
+		t.Errorf("Expected the deck's common count, got %q", user)
+	}
+
+	deck["info_count"] = 0
+	if _, user := RenderWriterPrompts(nil, map[string]interface{}{"deck": deck}); strings.Contains(user, "DECK MIX") {
+		t.Errorf("Expected no directive when the Writer decides, got %q", user)
+	}
+}
+
+// TestPreferencePrompts tests that the player's language and content rating
+// reach both agents
+func TestPreferencePrompts(t *testing.T) {
+	for lang, want := range map[string]string{"": "English", "vi": "Vietnamese", "pt-BR": "Portuguese (pt-BR)", "tlh": "tlh"} {
+		if got := languageInstruction(lang); got != want {
+			t.Errorf("Expected %q for %q, got %q", want, lang, got)
+		}
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "writer_user.j2"), []byte("LANGUAGE: {{ language_instruction }}"), 0644)
+	os.WriteFile(filepath.Join(dir, "architect_system.j2"), []byte("system"), 0644)
+	os.WriteFile(filepath.Join(dir, "architect_user.j2"), []byte("LANGUAGE: {{ language_instruction }}"), 0644)
+	ReloadPrompts(dir)
+	t.Cleanup(func() { ReloadPrompts("") })
+
+	prefs := Preferences{Language: "vi", ContentRating: RatingEveryone}
+	_, writer := RenderWriterPrompts(nil, map[string]interface{}{"preferences": prefs})
+	_, architect := RenderArchitectPrompts("pirates", 4, prefs)
+	for agent, user := range map[string]string{"writer": writer, "architect": architect} {
+		if !strings.HasPrefix(user, "CONTENT RATING (everyone): ") || !strings.HasSuffix(user, "LANGUAGE: Vietnamese") {
+			t.Errorf("Expected the %s prompt to carry the rating and language, got %q", agent, user)
+		}
+	}
+
+	// Architect calls read the preferences from their context
+	ctx := WithPreferences(context.Background(), prefs)
+	if got := preferencesFrom(ctx); got != prefs {
+		t.Errorf("Expected the preferences back from the context, got %+v", got)
+	}
+	if _, user := RenderArchitectPrompts("pirates", 4, preferencesFrom(context.Background())); user != "LANGUAGE: English" {
+		t.Errorf("Expected English and no rating guidance without preferences, got %q", user)
+	}
+}
+
+// TestSimpleText tests that players who ask for simple text get a
+// plain-language variant requested and decoded
+func TestSimpleText(t *testing.T) {
+	prefs := Preferences{Language: "vi", SimpleText: true}
+	_, user := RenderWriterPrompts(nil, map[string]interface{}{"preferences": prefs})
+	if !strings.Contains(user, "SIMPLE TEXT:") || !strings.Contains(user, "in Vietnamese a 9-year-old can read") {
+		t.Errorf("Expected the simple text directive in the player's language, got %q", user)
+	}
+	if _, user := RenderWriterPrompts(nil, map[string]interface{}{}); strings.Contains(user, "SIMPLE TEXT:") {
+		t.Errorf("Expected no simple text directive by default, got %q", user)
+	}
+
+	data := map[string]interface{}{
+		"type": "info", "id": "storm", "title": "Storm", "character": "narrator", "source": "common", "priority": float64(1),
+		"description":        "A tempest lashes the harbour, scattering the fleet",
+		"simple_description": "A big storm hits the harbour. The ships are blown away.",
+	}
+	card, err := decodeCard(data)
+	if err != nil {
+		t.Fatalf("Failed to decode card: %v", err)
+	}
+	if got := card.(*cards.InfoCard).SimpleDescription; got != data["simple_description"] {
+		t.Errorf("Expected the simple description to be kept, got %q", got)
+	}
+	data["simple_description"] = 3.0
+	if _, err := decodeCard(data); err == nil {
+		t.Error("Expected a non-string simple description to be rejected")
+	}
+}
+
+// TestWriterSampling tests that each batch is sampled by its most
+// constrained job, under the server's and the world's overrides
+func TestWriterSampling(t *testing.T) {
+	t.Cleanup(func() { SetWriterSampling(nil) })
+
+	filler := BuildWriterRequest([]CardGenJob{{Type: "info"}}, map[string]interface{}{})
+	if filler.Temperature != 0.9 || filler.TopP != 0.95 {
+		t.Errorf("Expected filler at 0.9/0.95, got %v/%v", filler.Temperature, filler.TopP)
+	}
+	plot := BuildWriterRequest([]CardGenJob{{Type: "info"}, {Type: "plot"}}, map[string]interface{}{})
+	if plot.Temperature != 0.5 || plot.TopP != 0.9 {
+		t.Errorf("Expected a plot batch at 0.5/0.9, got %v/%v", plot.Temperature, plot.TopP)
+	}
+	if other := BuildWriterRequest([]CardGenJob{{Type: "lore"}}, map[string]interface{}{}); other.Temperature != 0.7 || other.TopP != 0 {
+		t.Errorf("Expected other jobs at the 0.7 default, got %v/%v", other.Temperature, other.TopP)
+	}
+
+	schedule, err := ParseSamplingSchedule("plot=0.3, lore=1.1:0.8")
+	if err != nil {
+		t.Fatalf("ParseSamplingSchedule failed: %v", err)
+	}
+	SetWriterSampling(schedule)
+	if got := WriterSampling().For("plot"); got != (Sampling{Temperature: 0.3, TopP: 0.9}) {
+		t.Errorf("Expected the server override to keep plot's top_p, got %+v", got)
+	}
+
+	// The world's overrides win, and stay out of the prompt
+	world := map[string]interface{}{"sampling": SamplingSchedule{"lore": {Temperature: 0.2}}}
+	req := BuildWriterRequest([]CardGenJob{{Type: "lore"}}, world)
+	if req.Temperature != 0.2 || req.TopP != 0.8 {
+		t.Errorf("Expected the world's lore at 0.2/0.8, got %v/%v", req.Temperature, req.TopP)
+	}
+	if strings.Contains(req.Messages[1].Content, "sampling") {
+		t.Errorf("Expected no sampling in the prompt, got %q", req.Messages[1].Content)
+	}
+
+	for _, spec := range []string{"plot", "plot=hot", "plot=3", "info=0.5:1.5", "=0.5"} {
+		if _, err := ParseSamplingSchedule(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
+
+// TestApplyWorldEdit tests that refinements stay in their section, keep IDs
+// and leave no dangling references
+func TestApplyWorldEdit(t *testing.T) {
+	draft := BuildDeterministicWorld(3, "harbor")
+	edit := func(field string, value interface{}) *WorldEdit {
+		data, _ := json.Marshal(value)
+		return &WorldEdit{Reply: "ok", Changes: map[string]json.RawMessage{field: data}}
+	}
+
+	stats := append([]StatDef(nil), draft.Stats...)
+	stats[0].Name = "Renamed"
+	refined, err := ApplyWorldEdit(draft, edit("stats", stats), "player")
+	if err != nil {
+		t.Fatalf("ApplyWorldEdit failed: %v", err)
+	}
+	if refined.Schema.Stats[0].Name != "Renamed" || draft.Stats[0].Name == "Renamed" || refined.Changed[0] != "stats" {
+		t.Errorf("Expected a renamed copy of the stats, got %+v", refined)
+	}
+	if refined.Schema.Name != draft.Name || len(refined.Schema.NPCs) != len(draft.NPCs) {
+		t.Error("Expected the other fields kept")
+	}
+
+	if _, err := ApplyWorldEdit(draft, edit("stats", stats), "npcs"); err == nil {
+		t.Error("Expected an edit outside its section to be rejected")
+	}
+	rekeyed := append([]StatDef(nil), draft.Stats...)
+	rekeyed[0].ID = "brand_new"
+	if _, err := ApplyWorldEdit(draft, edit("stats", rekeyed), ""); err == nil {
+		t.Error("Expected a stat that kept its name but changed ID to be rejected")
+	}
+	if _, err := ApplyWorldEdit(draft, edit("stats", draft.Stats[1:]), ""); err == nil || !strings.Contains(err.Error(), draft.Stats[0].ID) {
+		t.Errorf("Expected a removed stat still in initial_stats to be rejected, got %v", err)
+	}
+	if _, err := ApplyWorldEdit(draft, &WorldEdit{}, ""); err == nil {
+		t.Error("Expected an empty edit to be rejected")
+	}
+
+	sections, err := SplitSections(draft)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sections[0].ID != "world" || sections[0].Data["name"] != draft.Name {
+		t.Errorf("Expected the world section first with the name, got %+v", sections[0])
+	}
+}
@@ -3,101 +3,30 @@ package agents
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
 )
 
-// loadPrompt reads a Jinja2 template file from the prompts directory
-func loadPrompt(filename string) (string, error) {
-	// Try multiple possible paths
-	possiblePaths := []string{
-		filepath.Join("prompts", filename),
-		filepath.Join("..", "..", "prompts", filename),
-		filepath.Join("../../prompts", filename),
-	}
-
-	for _, path := range possiblePaths {
-		content, err := os.ReadFile(path)
-		if err == nil {
-			return string(content), nil
-		}
-	}
-
-	return "", fmt.Errorf("could not find prompt file: %s", filename)
-}
-
-// renderArchitectPrompts renders the architect system and user prompts
-func renderArchitectPrompts(theme string, statCount int) (systemPrompt, userPrompt string, err error) {
-	systemContent, err := loadPrompt("architect_system.j2")
-	if err != nil {
-		return "", "", err
-	}
-
-	userContent, err := loadPrompt("architect_user.j2")
-	if err != nil {
-		return "", "", err
-	}
-
-	// Simple template rendering for architect_user.j2
-	userPrompt = strings.ReplaceAll(userContent, "{{ language_instruction }}", "English")
-	userPrompt = strings.ReplaceAll(userPrompt, "{{ theme if theme else \"Surprise me with something creative and unique\" }}", theme)
-	userPrompt = strings.ReplaceAll(userPrompt, "{{ stat_count }}", fmt.Sprintf("%d", statCount))
-
-	return systemContent, userPrompt, nil
-}
-
 // ArchitectAgent generates worlds using OpenRouter API
 type ArchitectAgent struct {
-	client *OpenRouterClient
+	client Completer
 }
 
 // NewArchitectAgent creates a new architect agent
 func NewArchitectAgent() *ArchitectAgent {
-	return &ArchitectAgent{
-		client: NewOpenRouterClient(),
-	}
+	return NewArchitectAgentWithClient(NewOpenRouterClient())
+}
+
+// NewArchitectAgentWithClient creates an architect agent calling client
+func NewArchitectAgentWithClient(client Completer) *ArchitectAgent {
+	return &ArchitectAgent{client: client}
 }
 
 // GenerateWorld generates a world from a prompt using Claude via OpenRouter
 func (a *ArchitectAgent) GenerateWorld(ctx context.Context, prompt string) (*WorldGenSchema, error) {
-	systemPrompt, userPrompt, err := renderArchitectPrompts(prompt, 5)
-	if err != nil {
-		// Fallback to inline prompts if template loading fails
-		systemPrompt = `You are The Architect — a world-builder for a card-based survival game similar to Reigns.
-
-Your job is to generate a COMPLETE world. Output it as STREAMING SECTIONS — each section starts with a markdown heading
-(# Creative Title...) followed by a JSON code block.
-
-FORMAT:
-# <Creative thematic title for this section>
-  ` + "`" + `json
-  { ... section data ... }
-  ` + "`" + `
-
-The heading MUST start with a VERB (action word ending in -ing) followed by "..." (e.g. "Forging the Iron Throne...",
-"Summoning the court..."). Do not start with nouns.
-
-Generate these sections IN THIS EXACT ORDER:
-
-SECTION 1 — WORLD CORE:
-SECTION 2 — PLAYER CHARACTER & STATS:
-SECTION 3 — NPCS & RELATIONSHIPS:
-SECTION 4 — TAGS:
-SECTION 5 — STORY DAG:
-SECTION 6 — SEASONS:
-
-CRITICAL RULES:
-- ALL IDs, tags, conditions, traits, and function params must be in ENGLISH (snake_case)
-- Display text (names, descriptions, flavor) in the TARGET LANGUAGE
-- Stats should be thematically tied to the world
-- Conditions are Python expressions evaluated via eval() — keep them simple and safe
-- Generate 12-15 plot nodes total`
-		userPrompt = prompt
-	}
+	systemPrompt, userPrompt := RenderArchitectPrompts(prompt, 5, preferencesFrom(ctx))
 
 	req := &CompletionRequest{
 		Model:     "claude-3-5-sonnet-20241022",
@@ -115,12 +44,17 @@ CRITICAL RULES:
 	}
 
 	resp, err := a.client.CreateCompletion(ctx, req)
+	if errors.Is(err, ErrNoChoices) {
+		recordValidationFailure(ctx, AgentArchitect, FailureEmptyResponse, req, err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to call OpenRouter API: %w", err)
 	}
 
 	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no response from API")
+		err := fmt.Errorf("no response from API")
+		recordValidationFailure(ctx, AgentArchitect, FailureEmptyResponse, req, err)
+		return nil, err
 	}
 
 	responseText := resp.Choices[0].Message.Content
@@ -128,15 +62,41 @@ CRITICAL RULES:
 	// Parse JSON
 	var schema WorldGenSchema
 	if err := json.Unmarshal([]byte(responseText), &schema); err != nil {
+		recordValidationFailure(ctx, AgentArchitect, FailureInvalidJSON, req, err)
 		return nil, fmt.Errorf("failed to parse world schema: %w", err)
 	}
+	if err := checkWorldSchema(&schema); err != nil {
+		recordValidationFailure(ctx, AgentArchitect, FailureSchema, req, err)
+		return nil, fmt.Errorf("invalid world schema: %w", err)
+	}
 
 	return &schema, nil
 }
 
+// checkWorldSchema rejects worlds missing the parts every game needs. The
+// engine validates the rest when the game is created.
+func checkWorldSchema(schema *WorldGenSchema) error {
+	if schema.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	if len(schema.Stats) == 0 {
+		return fmt.Errorf("no stats")
+	}
+	return nil
+}
+
+// ValidateWorld runs the Architect's checks on a schema: the parts every
+// game needs are there and no reference dangles
+func ValidateWorld(schema *WorldGenSchema) error {
+	if err := checkWorldSchema(schema); err != nil {
+		return err
+	}
+	return checkWorldReferences(schema)
+}
+
 // WriterAgent generates cards using OpenRouter API
 type WriterAgent struct {
-	client *OpenRouterClient
+	client Completer
 }
 
 // CardGenJob specifies a card generation job
@@ -147,63 +107,31 @@ type CardGenJob struct {
 
 // NewWriterAgent creates a new writer agent
 func NewWriterAgent() *WriterAgent {
-	return &WriterAgent{
-		client: NewOpenRouterClient(),
-	}
+	return NewWriterAgentWithClient(NewOpenRouterClient())
 }
 
-// GenerateCards generates cards from jobs using Claude via OpenRouter
-func (w *WriterAgent) GenerateCards(ctx context.Context, jobs []CardGenJob, worldContext map[string]interface{}) ([]cards.Card, error) {
-	if len(jobs) == 0 {
-		return []cards.Card{}, nil
-	}
-
-	systemContent, err := loadPrompt("writer_system.j2")
-	if err != nil {
-		// Fallback to inline prompt
-		systemContent = `You are The Writer — a real-time card generator for a card-based survival game similar to Reigns.
-
-You generate cards in BATCHES. Each batch contains a mix of:
-- COMMON cards: everyday events, character interactions, moral dilemmas
-- JOB cards: specific requests (plot events, death messages, reborn messages, welcome messages)
-
-CARD DESIGN RULES:
-1. React to the current situation (stats, tags, ongoing events, current phase)
-2. Present meaningful dilemmas with real tradeoffs — no obviously correct choice
-3. Feature NPCs from the ENABLED NPC list only (use NPC IDs as character field)
-4. Left and right choices should BOTH have downsides
-5. Keep descriptions to 1-3 punchy sentences
-6. Effects are expressed as FUNCTION CALLS (left_calls / right_calls), NOT raw stat dicts
-
-TAG DISCIPLINE:
-- You MUST ONLY use tag IDs from the available_tags list provided in context
-- Tags are permanent world state modifiers — use them sparingly (1-2 per batch at most)
-- 80%+ of choices should use ONLY update_stat calls, no tags`
-	}
-
-	userContent, err := loadPrompt("writer_user.j2")
-	if err != nil {
-		// Fallback to inline prompt
-		userContent = "Generate a batch of cards for the current game state."
-	}
-
-	contextJSON, _ := json.Marshal(worldContext)
+// NewWriterAgentWithClient creates a writer agent calling client
+func NewWriterAgentWithClient(client Completer) *WriterAgent {
+	return &WriterAgent{client: client}
+}
 
-	// Simple template rendering for writer_user.j2
-	userPrompt := strings.ReplaceAll(userContent, "{{ language_instruction }}", "English")
-	userPrompt = strings.ReplaceAll(userPrompt, "{{ world_context }}", fmt.Sprintf("%v", worldContext))
-	userPrompt = strings.ReplaceAll(userPrompt, "{{ stat_names }}", "[]")
-	userPrompt = strings.ReplaceAll(userPrompt, "{{ snapshot | tojson(indent=2) }}", string(contextJSON))
-	userPrompt = strings.ReplaceAll(userPrompt, "{{ common_count }}", "5")
-	userPrompt = strings.ReplaceAll(userPrompt, "{{ jobs | length }}", fmt.Sprintf("%d", len(jobs)))
+// BuildWriterRequest builds the completion request GenerateCards sends for a
+// batch of jobs, sampled by the server's schedule under the world's
+// "sampling" overrides, with client defaults applied
+func BuildWriterRequest(jobs []CardGenJob, worldContext map[string]interface{}) *CompletionRequest {
+	systemPrompt, userPrompt := RenderWriterPrompts(jobs, worldContext)
+	world, _ := worldContext["sampling"].(SamplingSchedule)
+	sampling := WriterSampling().Merge(world).ForJobs(jobs)
 
 	req := &CompletionRequest{
-		Model:     "claude-3-5-sonnet-20241022",
-		MaxTokens: 2048,
+		Model:       "claude-3-5-sonnet-20241022",
+		MaxTokens:   2048,
+		Temperature: sampling.Temperature,
+		TopP:        sampling.TopP,
 		Messages: []Message{
 			{
 				Role:    "system",
-				Content: systemContent,
+				Content: systemPrompt,
 			},
 			{
 				Role:    "user",
@@ -211,14 +139,44 @@ TAG DISCIPLINE:
 			},
 		},
 	}
+	req.ApplyDefaults()
+	return req
+}
+
+// GenerateCards generates cards from jobs using Claude via OpenRouter
+func (w *WriterAgent) GenerateCards(ctx context.Context, jobs []CardGenJob, worldContext map[string]interface{}) ([]cards.Card, error) {
+	if len(jobs) == 0 {
+		return []cards.Card{}, nil
+	}
+	result, _, err := w.generate(ctx, jobs, worldContext)
+	return result, err
+}
+
+// GenerateWeek generates a week's batch as card definitions the engine can
+// insert, calls included. Unlike GenerateCards it calls the model without
+// jobs, as for a new game's opening week.
+func (w *WriterAgent) GenerateWeek(ctx context.Context, jobs []CardGenJob, worldContext map[string]interface{}) ([]map[string]interface{}, error) {
+	_, defs, err := w.generate(ctx, jobs, worldContext)
+	return defs, err
+}
+
+// generate calls the Writer and returns the cards that decode, both as
+// Card objects and as the definitions they came from
+func (w *WriterAgent) generate(ctx context.Context, jobs []CardGenJob, worldContext map[string]interface{}) ([]cards.Card, []map[string]interface{}, error) {
+	req := BuildWriterRequest(jobs, worldContext)
 
 	resp, err := w.client.CreateCompletion(ctx, req)
+	if errors.Is(err, ErrNoChoices) {
+		recordValidationFailure(ctx, AgentWriter, FailureEmptyResponse, req, err)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to call OpenRouter API: %w", err)
+		return nil, nil, fmt.Errorf("failed to call OpenRouter API: %w", err)
 	}
 
 	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no response from API")
+		err := fmt.Errorf("no response from API")
+		recordValidationFailure(ctx, AgentWriter, FailureEmptyResponse, req, err)
+		return nil, nil, err
 	}
 
 	responseText := resp.Choices[0].Message.Content
@@ -226,51 +184,87 @@ TAG DISCIPLINE:
 	// Parse cards
 	var cardData []map[string]interface{}
 	if err := json.Unmarshal([]byte(responseText), &cardData); err != nil {
-		return nil, fmt.Errorf("failed to parse cards: %w", err)
+		recordValidationFailure(ctx, AgentWriter, FailureInvalidJSON, req, err)
+		return nil, nil, fmt.Errorf("failed to parse cards: %w", err)
 	}
 
-	// Convert to Card objects
+	// Convert to Card objects, skipping cards with missing or mistyped fields
 	var result []cards.Card
-	for _, data := range cardData {
-		if cardType, ok := data["type"].(string); ok {
-			if cardType == "choice" {
-				card := &cards.ChoiceCard{
-					ID:          data["id"].(string),
-					Title:       data["title"].(string),
-					Description: data["description"].(string),
-					Character:   data["character"].(string),
-					Source:      data["source"].(string),
-					Priority:    int(data["priority"].(float64)),
-				}
-
-				if leftChoice, ok := data["left_choice"].(map[string]interface{}); ok {
-					card.LeftChoice = &cards.Choice{
-						Label: leftChoice["label"].(string),
-						Calls: []cards.FunctionCall{},
-					}
-				}
-
-				if rightChoice, ok := data["right_choice"].(map[string]interface{}); ok {
-					card.RightChoice = &cards.Choice{
-						Label: rightChoice["label"].(string),
-						Calls: []cards.FunctionCall{},
-					}
-				}
-
-				result = append(result, card)
-			} else {
-				card := &cards.InfoCard{
-					ID:          data["id"].(string),
-					Title:       data["title"].(string),
-					Description: data["description"].(string),
-					Character:   data["character"].(string),
-					Source:      data["source"].(string),
-					Priority:    int(data["priority"].(float64)),
-				}
-				result = append(result, card)
-			}
+	var defs []map[string]interface{}
+	for i, data := range cardData {
+		card, err := decodeCard(data)
+		if err != nil {
+			recordValidationFailure(ctx, AgentWriter, FailureSchema, req, fmt.Errorf("card %d: %w", i, err))
+			continue
+		}
+		if card != nil {
+			result = append(result, card)
+			defs = append(defs, data)
+		}
+	}
+
+	return result, defs, nil
+}
+
+// decodeCard converts one card from the Writer's response. Entries without a
+// type are ignored.
+func decodeCard(data map[string]interface{}) (cards.Card, error) {
+	cardType, ok := data["type"].(string)
+	if !ok {
+		return nil, nil
+	}
+
+	var fields [5]string
+	for i, key := range []string{"id", "title", "description", "character", "source"} {
+		value, ok := data[key].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a string", key)
 		}
+		fields[i] = value
+	}
+	priority, ok := data["priority"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("priority must be a number")
+	}
+	simple, ok := data["simple_description"].(string)
+	if _, present := data["simple_description"]; present && !ok {
+		return nil, fmt.Errorf("simple_description must be a string")
+	}
+
+	if cardType != "choice" {
+		return &cards.InfoCard{
+			ID:                fields[0],
+			Title:             fields[1],
+			Description:       fields[2],
+			SimpleDescription: simple,
+			Character:         fields[3],
+			Source:            fields[4],
+			Priority:          int(priority),
+		}, nil
 	}
 
-	return result, nil
+	card := &cards.ChoiceCard{
+		ID:                fields[0],
+		Title:             fields[1],
+		Description:       fields[2],
+		SimpleDescription: simple,
+		Character:         fields[3],
+		Source:            fields[4],
+		Priority:          int(priority),
+	}
+	for _, side := range []struct {
+		key  string
+		dest **cards.Choice
+	}{{"left_choice", &card.LeftChoice}, {"right_choice", &card.RightChoice}} {
+		choice, ok := data[side.key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		label, ok := choice["label"].(string)
+		if !ok {
+			return nil, fmt.Errorf("%s.label must be a string", side.key)
+		}
+		*side.dest = &cards.Choice{Label: label, Calls: []cards.FunctionCall{}}
+	}
+	return card, nil
 }
@@ -0,0 +1,309 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// themePack holds the procedural building blocks for one kind of world
+type themePack struct {
+	names       []string
+	era         string
+	description string
+	stats       []StatDef
+	tags        []TagDef
+	seasons     []SeasonDef
+	npcs        []NPCDef
+	player      PlayerCharacterDef
+	relations   []string
+	beats       []string // plot beat templates; %s is an NPC name
+}
+
+// themePacks are the worlds a DeterministicArchitect can produce
+var themePacks = []themePack{
+	{
+		names:       []string{"Ashvale", "Thornmere", "Greywatch", "Hollowfen"},
+		era:         "Age of Embers",
+		description: "A fading kingdom where old oaths bind the living.",
+		stats: []StatDef{
+			{ID: "treasury", Name: "Treasury", Description: "Coin in the royal vaults"},
+			{ID: "faith", Name: "Faith", Description: "Devotion of the clergy"},
+			{ID: "army", Name: "Army", Description: "Strength of the guard"},
+			{ID: "people", Name: "People", Description: "Goodwill of the commonfolk"},
+			{ID: "nobility", Name: "Nobility", Description: "Loyalty of the great houses"},
+			{ID: "lore", Name: "Lore", Description: "Knowledge of the old ways"},
+		},
+		tags: []TagDef{
+			{ID: "plague", Name: "Plague", Description: "Sickness spreads through the streets", IsTemp: true},
+			{ID: "crowned", Name: "Crowned", Description: "You wear the crown openly"},
+			{ID: "omen", Name: "Omen", Description: "A comet hangs over the realm", IsTemp: true},
+			{ID: "oathbound", Name: "Oathbound", Description: "You swore the ancient oath"},
+		},
+		seasons: []SeasonDef{
+			{ID: "thaw", Name: "Thaw", Description: "Rivers break their ice"},
+			{ID: "bloom", Name: "Bloom", Description: "Fields run green"},
+			{ID: "harvest", Name: "Harvest", Description: "Granaries fill"},
+			{ID: "frost", Name: "Frost", Description: "The long dark returns"},
+		},
+		npcs: []NPCDef{
+			{EntityDef: EntityDef{ID: "chancellor", Name: "Chancellor Vey"}, Description: "Keeper of the purse", Appearance: "Ink-stained fingers"},
+			{EntityDef: EntityDef{ID: "priestess", Name: "Mother Ilse"}, Description: "Voice of the temple", Appearance: "Grey robes and a silver sun"},
+			{EntityDef: EntityDef{ID: "marshal", Name: "Marshal Kerr"}, Description: "Commander of the guard", Appearance: "Scarred and unsmiling"},
+			{EntityDef: EntityDef{ID: "jester", Name: "Pip"}, Description: "Fool who hears everything", Appearance: "Motley and bells"},
+			{EntityDef: EntityDef{ID: "witch", Name: "The Fen Witch"}, Description: "Hermit of the marsh", Appearance: "Moss in her hair"},
+		},
+		player: PlayerCharacterDef{
+			EntityDef:   EntityDef{ID: "player", Name: "The Young Regent"},
+			Description: "Heir to a throne nobody wanted",
+		},
+		relations: []string{"Wary ally", "Old rival", "Sworn servant", "Secret admirer"},
+		beats: []string{
+			"%s brings word of unrest in the outer villages",
+			"%s demands an audience before the council",
+			"A letter sealed by %s reveals a hidden debt",
+			"%s is accused of treason in the square",
+			"%s uncovers a relic beneath the chapel",
+			"Riders loyal to %s gather at the gates",
+		},
+	},
+	{
+		names:       []string{"Kepler Drift", "Station Meridian", "The Long Haul", "Outpost Vesta"},
+		era:         "Third Expansion",
+		description: "A remote station at the edge of charted space.",
+		stats: []StatDef{
+			{ID: "oxygen", Name: "Oxygen", Description: "Breathable air reserves"},
+			{ID: "morale", Name: "Morale", Description: "Spirit of the crew"},
+			{ID: "credits", Name: "Credits", Description: "Corporate funding"},
+			{ID: "hull", Name: "Hull", Description: "Structural integrity"},
+			{ID: "signal", Name: "Signal", Description: "Contact with home"},
+			{ID: "research", Name: "Research", Description: "Scientific progress"},
+		},
+		tags: []TagDef{
+			{ID: "quarantine", Name: "Quarantine", Description: "Decks are sealed", IsTemp: true},
+			{ID: "mutiny", Name: "Mutiny", Description: "The crew whispers of revolt", IsTemp: true},
+			{ID: "artifact", Name: "Artifact", Description: "You hold the alien artifact"},
+			{ID: "blacklisted", Name: "Blacklisted", Description: "The company has disowned you"},
+		},
+		seasons: []SeasonDef{
+			{ID: "perihelion", Name: "Perihelion", Description: "Solar storms batter the hull"},
+			{ID: "transit", Name: "Transit", Description: "Supply ships arrive"},
+			{ID: "aphelion", Name: "Aphelion", Description: "Cold and silent orbit"},
+			{ID: "eclipse", Name: "Eclipse", Description: "Sensors go dark"},
+		},
+		npcs: []NPCDef{
+			{EntityDef: EntityDef{ID: "engineer", Name: "Chief Okafor"}, Description: "Keeps the reactor alive", Appearance: "Grease and headlamp"},
+			{EntityDef: EntityDef{ID: "doctor", Name: "Dr. Salme"}, Description: "Station physician", Appearance: "Tired eyes, steady hands"},
+			{EntityDef: EntityDef{ID: "liaison", Name: "Liaison Crane"}, Description: "Company representative", Appearance: "Immaculate uniform"},
+			{EntityDef: EntityDef{ID: "pilot", Name: "Rook"}, Description: "Shuttle pilot with debts", Appearance: "Flight jacket, cocky grin"},
+			{EntityDef: EntityDef{ID: "ai", Name: "HALE"}, Description: "Station intelligence", Appearance: "A soft amber light"},
+		},
+		player: PlayerCharacterDef{
+			EntityDef:   EntityDef{ID: "player", Name: "The Commander"},
+			Description: "Newly promoted and already in over their head",
+		},
+		relations: []string{"Trusted crewmate", "Company watchdog", "Reluctant friend", "Creditor"},
+		beats: []string{
+			"%s reports a breach on the lower decks",
+			"%s intercepts a transmission nobody sent",
+			"%s requests authority to override protocol",
+			"A crate addressed to %s arrives unmarked",
+			"%s goes missing during a routine shift",
+			"%s proposes abandoning the station",
+		},
+	},
+	{
+		names:       []string{"Saltcrown Isles", "The Brine Reach", "Gullhaven", "Port Marrow"},
+		era:         "Golden Age of Sail",
+		description: "A lawless archipelago ruled by whoever holds the harbour.",
+		stats: []StatDef{
+			{ID: "gold", Name: "Gold", Description: "Plunder in the hold"},
+			{ID: "crew", Name: "Crew", Description: "Loyalty of your sailors"},
+			{ID: "infamy", Name: "Infamy", Description: "How feared your flag is"},
+			{ID: "ship", Name: "Ship", Description: "Condition of your vessel"},
+			{ID: "rum", Name: "Rum", Description: "Stores that keep spirits up"},
+			{ID: "charts", Name: "Charts", Description: "Knowledge of the sea lanes"},
+		},
+		tags: []TagDef{
+			{ID: "wanted", Name: "Wanted", Description: "The navy hunts you"},
+			{ID: "storm", Name: "Storm", Description: "A gale rages", IsTemp: true},
+			{ID: "cursed_coin", Name: "Cursed Coin", Description: "You carry the drowned king's coin"},
+			{ID: "pardoned", Name: "Pardoned", Description: "You hold a letter of marque"},
+		},
+		seasons: []SeasonDef{
+			{ID: "trade_winds", Name: "Trade Winds", Description: "Merchant fleets sail"},
+			{ID: "doldrums", Name: "Doldrums", Description: "Becalmed seas"},
+			{ID: "hurricane", Name: "Hurricane Season", Description: "Only fools set sail"},
+			{ID: "fog", Name: "Fogtide", Description: "Ghost ships are sighted"},
+		},
+		npcs: []NPCDef{
+			{EntityDef: EntityDef{ID: "quartermaster", Name: "Mags"}, Description: "Your quartermaster", Appearance: "One eye, two cutlasses"},
+			{EntityDef: EntityDef{ID: "governor", Name: "Governor Pell"}, Description: "Crown's man in the islands", Appearance: "Powdered wig"},
+			{EntityDef: EntityDef{ID: "smuggler", Name: "Silk Tamsin"}, Description: "Runs the black market", Appearance: "Rings on every finger"},
+			{EntityDef: EntityDef{ID: "priest", Name: "Brother Kel"}, Description: "Drowned god's preacher", Appearance: "Seaweed rosary"},
+			{EntityDef: EntityDef{ID: "rival", Name: "Captain Blackwake"}, Description: "Your oldest rival", Appearance: "Tar-black beard"},
+		},
+		player: PlayerCharacterDef{
+			EntityDef:   EntityDef{ID: "player", Name: "The Captain"},
+			Description: "Won a ship in a card game and means to keep it",
+		},
+		relations: []string{"Loyal mate", "Sworn enemy", "Business partner", "Old flame"},
+		beats: []string{
+			"%s sells you a map to a sunken wreck",
+			"%s calls a parley at the harbour",
+			"%s is caught stealing from the hold",
+			"A bounty on %s is nailed to the tavern door",
+			"%s claims the drowned god has spoken",
+			"%s's fleet is spotted on the horizon",
+		},
+	},
+}
+
+// DeterministicArchitect builds worlds procedurally from a seed without calling
+// an LLM. The same seed always yields the same world.
+type DeterministicArchitect struct {
+	seed int64
+}
+
+// NewDeterministicArchitect creates a deterministic architect for a seed
+func NewDeterministicArchitect(seed int64) *DeterministicArchitect {
+	return &DeterministicArchitect{seed: seed}
+}
+
+// GenerateWorld builds a world from the architect's seed. The prompt is only
+// used as flavor text in the world description.
+func (a *DeterministicArchitect) GenerateWorld(ctx context.Context, prompt string) (*WorldGenSchema, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return BuildDeterministicWorld(a.seed, prompt), nil
+}
+
+// BuildDeterministicWorld builds a procedural world from a seed
+func BuildDeterministicWorld(seed int64, prompt string) *WorldGenSchema {
+	rng := rand.New(rand.NewSource(seed))
+	pack := themePacks[rng.Intn(len(themePacks))]
+
+	description := pack.description
+	if prompt = strings.TrimSpace(prompt); prompt != "" {
+		description = fmt.Sprintf("%s (%s)", description, prompt)
+	}
+
+	schema := &WorldGenSchema{
+		Name:         pack.names[rng.Intn(len(pack.names))],
+		Era:          pack.era,
+		Description:  description,
+		Seasons:      append([]SeasonDef(nil), pack.seasons...),
+		PlayerChar:   pack.player,
+		InitialStats: make(map[string]int),
+		InitialTags:  []string{},
+	}
+
+	// Pick 4 stats and 3 NPCs from the pack
+	for _, i := range rng.Perm(len(pack.stats))[:4] {
+		stat := pack.stats[i]
+		schema.Stats = append(schema.Stats, stat)
+		schema.InitialStats[stat.ID] = 40 + rng.Intn(21)
+	}
+	for _, i := range rng.Perm(len(pack.npcs))[:3] {
+		npc := pack.npcs[i]
+		schema.NPCs = append(schema.NPCs, npc)
+		schema.Relationships = append(schema.Relationships, RelationshipDef{
+			From:        pack.player.ID,
+			To:          npc.ID,
+			Description: pack.relations[rng.Intn(len(pack.relations))],
+		})
+	}
+	schema.Tags = append([]TagDef(nil), pack.tags...)
+
+	schema.Arcs = []ArcDef{
+		{ID: "rising", Title: "Rising Tension", Theme: "Trouble gathers"},
+		{ID: "reckoning", Title: "The Reckoning", Theme: "Every choice comes due"},
+	}
+	schema.PlotNodes = buildDeterministicPlot(rng, pack, schema)
+	return schema
+}
+
+// buildDeterministicPlot builds a linear rising arc that forks into two
+// exclusive branches, each leading to an ending, plus one secret ending
+func buildDeterministicPlot(rng *rand.Rand, pack themePack, schema *WorldGenSchema) []PlotNodeDef {
+	beats := rng.Perm(len(pack.beats))
+	beatCount := 3 + rng.Intn(2)
+
+	nodes := make([]PlotNodeDef, 0, beatCount+5)
+	prev := ""
+	for i := 0; i < beatCount; i++ {
+		npc := schema.NPCs[rng.Intn(len(schema.NPCs))]
+		stat := schema.Stats[rng.Intn(len(schema.Stats))]
+		node := PlotNodeDef{
+			ID:              fmt.Sprintf("beat_%d", i+1),
+			PlotDescription: fmt.Sprintf(pack.beats[beats[i]], npc.Name),
+			Condition:       fmt.Sprintf("elapsed_days >= %d", (i+1)*7+rng.Intn(7)),
+			Calls: []FunctionCall{
+				{Name: "update_stat", Params: map[string]interface{}{"stat_id": stat.ID, "delta": float64(rng.Intn(21) - 10)}},
+			},
+			ArcID: "rising",
+		}
+		if prev != "" {
+			node.PredecessorIDs = []string{prev}
+			nodes[len(nodes)-1].SuccessorIDs = []string{node.ID}
+		}
+		nodes = append(nodes, node)
+		prev = node.ID
+	}
+
+	// Fork on the player's strongest resource
+	pivot := schema.Stats[rng.Intn(len(schema.Stats))]
+	secretTag := schema.Tags[rng.Intn(len(schema.Tags))]
+	nodes[len(nodes)-1].SuccessorIDs = []string{"triumph", "downfall"}
+
+	nodes = append(nodes,
+		PlotNodeDef{
+			ID:              "triumph",
+			PlotDescription: fmt.Sprintf("Your %s carries the day", strings.ToLower(pivot.Name)),
+			Condition:       fmt.Sprintf("stats.%s >= 60", pivot.ID),
+			PredecessorIDs:  []string{prev},
+			SuccessorIDs:    []string{"ending_glory"},
+			ArcID:           "reckoning",
+			ExclusiveGroup:  "fate",
+		},
+		PlotNodeDef{
+			ID:              "downfall",
+			PlotDescription: fmt.Sprintf("Your %s fails you when it matters most", strings.ToLower(pivot.Name)),
+			Condition:       fmt.Sprintf("stats.%s < 40", pivot.ID),
+			PredecessorIDs:  []string{prev},
+			SuccessorIDs:    []string{"ending_ruin", "ending_hidden"},
+			ArcID:           "reckoning",
+			ExclusiveGroup:  "fate",
+		},
+		PlotNodeDef{
+			ID:              "ending_glory",
+			PlotDescription: fmt.Sprintf("%s remembers you as its saviour", schema.Name),
+			Condition:       "true",
+			IsEnding:        true,
+			EndingTier:      "good",
+			PredecessorIDs:  []string{"triumph"},
+			ArcID:           "reckoning",
+		},
+		PlotNodeDef{
+			ID:              "ending_ruin",
+			PlotDescription: fmt.Sprintf("%s falls, and your name with it", schema.Name),
+			Condition:       fmt.Sprintf("!tags.%s", secretTag.ID),
+			IsEnding:        true,
+			EndingTier:      "common",
+			PredecessorIDs:  []string{"downfall"},
+			ArcID:           "reckoning",
+		},
+		PlotNodeDef{
+			ID:              "ending_hidden",
+			PlotDescription: fmt.Sprintf("The %s was the key all along", strings.ToLower(secretTag.Name)),
+			Condition:       fmt.Sprintf("tags.%s", secretTag.ID),
+			IsEnding:        true,
+			EndingTier:      "secret",
+			PredecessorIDs:  []string{"downfall"},
+			ArcID:           "reckoning",
+		},
+	)
+	return nodes
+}
@@ -0,0 +1,270 @@
+package agents
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// The prompt templates are shared with the Python game, so they only
+// describe what it can run. The rules below cover what only this engine
+// supports and are added to the rendered prompts.
+
+// architectEngineRules extends the architect system prompt
+const architectEngineRules = `ENGINE EXTENSIONS — this engine supports more than the sections above describe. These rules add to them:
+
+SECTION 1 — WORLD CORE:
+- resurrection_mechanic must be one of "reincarnation", "time_loop", "heir" or "ghost". It decides what a death resets,
+so pick the one that fits the world:
+  - ` + "`reincarnation`" + `: reborn next season; stats reset, NPCs forgotten, events end.
+  - ` + "`time_loop`" + `: the same season restarts at day 1 with the world as it was then; only knowledge tags survive.
+  - ` + "`heir`" + `: the player returns as their own descendant years later. Add
+  ` + "`\"dynasty\": {\"years_later\": 20, \"inheritance\": 0.5}`" + ` (years skipped per death, share of each stat passed down).
+  - ` + "`ghost`" + `: the player rises as a ghost at the moment of death; only stats reset.
+- resurrection_flavor is the display text describing it.
+
+SECTION 2 — PLAYER CHARACTER & STATS:
+- Optionally mark 1 stat ` + "`\"hidden\": true`" + ` for mystery-driven worlds (e.g. suspicion). The player cannot see it until a
+` + "`reveal_stat`" + ` call (params: ` + "`stat_id`" + `) shows it; conditions and the Writer always see it.
+- For economy-themed worlds, optionally add ` + "`\"resources\"`" + ` (` + "`[{\"id\": \"gold\", \"name\": \"Gold\", \"description\": \"Coin in the purse\", \"initial\": 20}]`" + `): unbounded balances, unlike the 0-100 stats. Resource IDs must differ from stat IDs.
+Set ` + "`\"bankruptcy\": true`" + ` on a resource whose overspending should end the life.
+
+SECTION 3 — NPCS & RELATIONSHIPS:
+- Optionally give NPCs an ` + "`\"age\"`" + ` in years; aged NPCs grow older every in-game year. Add ` + "`\"mortality_rules\"`" + `
+(e.g. ` + "`[{\"min_age\": 60, \"chance\": 0.1}, {\"min_age\": 75, \"chance\": 0.3}]`" + `) so the elderly can die of natural causes.
+- Optionally give NPCs a starting ` + "`\"affinity\"`" + ` from -100 (hatred) to 100 (devotion) toward the player; it defaults
+to 0. An NPC whose affinity falls to -80 leaves for good.
+
+SECTION 4 — TAGS:
+- For time_loop worlds, mark 3-5 tags ` + "`\"knowledge\": true`" + ` (secrets the player learns, e.g. "knows_password"); they
+survive every loop reset.
+- Optionally add ` + "`\"items\"`" + ` (` + "`[{\"id\": \"iron_key\", \"name\": \"Iron Key\", \"description\": \"Opens the old gate\"}]`" + `) for
+collectible artifacts the player can hold several of, and ` + "`\"initial_items\"`" + ` (` + "`{\"iron_key\": 1}`" + `). Use items, not
+tags, for things that are counted, found, spent or lost.
+
+SECTION 5 — STORY DAG:
+- Conditions may also use ` + "`items`" + ` (dict of counts), ` + "`resources`" + ` (dict of balances), ` + "`affinity`" + ` (dict of NPC
+affinity, -100 to 100) and the function ` + "`has_item(\"item_id\")`" + `.
+- Calls may also use ` + "`reveal_stat`, `random_outcome`, `skill_check`, `give_item`, `remove_item`, `has_item`, `add_resource`, `spend_resource`" + ` and ` + "`update_affinity`" + `.
+- Optionally group the plot into 2-4 story arcs (chapters): add a top-level ` + "`\"arcs\"`" + ` list
+(` + "`[{\"id\": \"uprising\", \"title\": \"The Uprising\", \"theme\": \"...\"}]`" + `) and set each node's ` + "`\"arc_id\"`" + `. Every arc needs at
+least one node.
+- Rival branches share an ` + "`\"exclusive_group\"`" + `: once one fires, the others in its group are pruned, along with the nodes
+only they lead to. Use it for paths that rule each other out (siding with the crown or the rebels).
+- A node the story should not wait on forever may set ` + "`\"deadline\": {\"week\": 6, \"action\": \"nudge\"}`" + ` (weeks count from the
+start of the game). Past its week, ` + "`nudge`" + ` (the default) has the Writer hint at what would bring it about, and ` + "`loosen`" + `
+switches the node to its ` + "`\"fallback_condition\"`" + `, which it needs and which must be easier to meet than ` + "`condition`" + `.
+- Endings may set ` + "`\"ending_tier\"`" + `: ` + "`common`" + ` (the default), ` + "`good`" + ` for hard-won endings or ` + "`secret`" + ` for hidden
+ones, whose description players only see once they reach them. Give the world at least 2 endings of different tiers and
+at most 1 secret one.
+
+SECTION 6 — SEASONS:
+- Seasons may set ` + "`\"stat_multipliers\"`" + ` to scale gains of a stat during the season, e.g. ` + "`{\"food\": 2}`" + ` in a harvest
+season. Values from 0 to 3; losses are unaffected. Use at most 1-2 per season.
+
+After SECTION 6, add these optional sections in this order:
+
+SECTION 7 — MACROS (optional):
+` + "```json" + `
+{
+"macros": [
+{
+"id": "host_feast",
+"description": "Throw a feast for the court",
+"calls": [
+{"name": "update_stat", "params": {"stat_id": "gold", "delta": -10}},
+{"name": "update_stat", "params": {"stat_id": "morale", "delta": 15}},
+{"name": "add_tag", "params": {"tag_id": "feast_host"}}
+]
+}
+]
+}
+` + "```" + `
+- 0-5 macros for compound effects that recur in this world. The Writer calls them by id like any function.
+- Macro IDs are snake_case and must not reuse a built-in function name. A macro may call another macro, but never
+itself.
+
+SECTION 8 — PRESSURE RULES (optional):
+` + "```json" + `
+{
+"pressure_rules": [
+{
+"id": "famine",
+"description": "A starving court loses heart",
+"condition": "stats['food'] < 20",
+"calls": [{"name": "update_stat", "params": {"stat_id": "morale", "delta": -5}}],
+"interval": "week"
+}
+]
+}
+` + "```" + `
+- 2-4 rules that link stats into the world's physics. While ` + "`condition`" + ` holds, ` + "`calls`" + ` run once per ` + "`interval`" + `
+("day" or "week").
+- Keep deltas small (-5 to 5); these rules add steady pressure, not sudden swings.
+
+SECTION 9 — ERAS (optional, for multi-generation worlds):
+` + "```json" + `
+{
+"eras": [
+{"id": "founding", "name": "Era Name", "description": "Era flavor"},
+{
+"id": "empire",
+"name": "Era Name",
+"description": "Era flavor",
+"condition": "year >= 3",
+"retire_npcs": ["npc_id"],
+"introduce_npcs": ["npc_id"]
+}
+]
+}
+` + "```" + `
+- 2-3 eras in order. The first era has no condition; each later ` + "`condition`" + ` moves the world out of the previous era.
+- Use ` + "`year`" + ` or ` + "`elapsed_days`" + ` in transitions so eras span several in-game years.
+- NPCs in ` + "`introduce_npcs`" + ` stay hidden until their era begins; ` + "`retire_npcs`" + ` leave the cast for good.`
+
+// writerEngineRules extends the writer system prompt
+const writerEngineRules = `ENGINE EXTENSIONS — this engine supports more than the rules above. These add to them:
+
+MORE FUNCTION CALLS:
+- ` + "`advance_phase`" + `: {"event_id": "..."} — move a phase event on to its next phase
+- ` + "`add_fact`" + `: {"fact_id": "snake_case_id", "text": "..."} — record a lasting story fact (e.g. "bridge_destroyed"); facts are not tags and do not affect gameplay
+- ` + "`retract_fact`" + `: {"fact_id": "..."} — forget a fact that no longer holds (e.g. the bridge was rebuilt)
+- ` + "`reveal_stat`" + `: {"stat_id": "..."} — show a hidden stat to the player
+- ` + "`update_affinity`" + `: {"npc_id": "...", "delta": N} — change how an NPC feels about the player (-50 to 50 per call; affinity runs -100 to 100, and an NPC who falls to -80 leaves for good)
+Example: {"name": "update_affinity", "params": {"npc_id": "chancellor", "delta": -15}}
+- ` + "`random_outcome`" + `: {"branches": [{"label": "...", "weight": N, "calls": [...]}, ...]} — the game rolls one branch by weight
+Example: {"name": "random_outcome", "params": {"branches": [{"label": "caught", "weight": 30, "calls": [{"name": "add_tag", "params": {"tag_id": "wanted"}}]}, {"label": "escaped", "weight": 70, "calls": [{"name": "update_stat", "params": {"treasury": 20}}]}]}}
+Use it for genuinely risky choices (heists, duels, gambles) and hint at the odds in the choice label; most choices stay certain
+- ` + "`skill_check`" + `: {"stat_id": "...", "target": N, "success": [...], "failure": [...]} — d20 plus a stat modifier (-5 at 0, +5 at 100) must reach target (5 easy, 10 fair, 15 hard, 20 heroic)
+Example: {"name": "skill_check", "params": {"stat_id": "military", "target": 12, "success": [{"name": "update_stat", "params": {"treasury": 15}}], "failure": [{"name": "update_stat", "params": {"military": -10}}]}}
+- The world's items, resources and macros, when it has any, are listed in the request with the calls that use them
+
+CHOICE REQUIREMENTS:
+- A choice may set ` + "`requires`" + ` to a condition (same syntax as plot conditions) that must hold for the player to pick it
+Example: a bribe option with "requires": "stats['treasury'] >= 30"
+- Use it for options that cost resources the player may not have; the other choice must never have a requirement
+- A choice may also carry an ` + "`unlocked`" + ` choice with its own ` + "`requires`" + `; once that holds, the unlocked choice replaces it
+Example: "left_choice": {"label": "Knock", "calls": [...], "unlocked": {"label": "Whisper the password", "requires": "'knows_password' in tags", "calls": [...]}}
+
+PLOT TIMING:
+- A plot card may set ` + "`reveal_window`" + ` to the days of the week it should appear on, so a major beat builds up instead of opening the week
+Example: "reveal_window": {"earliest": 4, "latest": 6}
+- Leave it out for cards that can come any day
+- A card written for a plot job sets ` + "`plot_node`" + ` to the job's node_id
+
+CONTINUITY:
+- The snapshot's ` + "`facts`" + ` list what has already happened in the story; never contradict them (a dead NPC cannot speak, a destroyed bridge cannot be crossed)
+- Record lasting consequences with ` + "`add_fact`" + ` rather than a tag
+- A card may set ` + "`assumes`" + ` to the facts its story depends on, with "!" for facts that must not hold
+Example: "assumes": ["mayor_dead", "!bridge_destroyed"]
+
+SNAPSHOT:
+- Stats in hidden_stats are invisible to the player: hint at them in the narrative, never name their values. Use reveal_stat only at a dramatic turning point
+- Respect the world's pressure_rules in the snapshot: active rules are already hurting the player, so write cards that react to them
+- Prefer a world macro over spelling out the same calls by hand
+- NPCs marked deceased are dead: mention them only in memories, never as the character field
+- NPCs marked departed have left the player: mention them only as absent, never as the character field
+- Each NPC's affinity (-100 to 100) is how they feel about the player: let warm NPCs help and cold ones scheme, and use update_affinity when a choice wins or loses them
+- In time loops (snapshot loop > 0) the player remembers past loops: revisit earlier scenes and give knowledge tags unlocked choices
+- If the snapshot has era_info, write in that era's voice and technology
+- The current season's stat_multipliers already scale gains; write the base delta, not the multiplied one`
+
+// worldListsDirective lists the world's items, resources and macros with
+// the calls that use them, or returns "" for a world without any
+func worldListsDirective(worldContext map[string]interface{}) string {
+	var b strings.Builder
+	if items, _ := worldContext["items"].([]map[string]interface{}); len(items) > 0 {
+		b.WriteString("World items (give_item / remove_item with item_id and optional count; has_item runs \"then\" or \"else\" calls):\n")
+		for _, item := range items {
+			fmt.Fprintf(&b, "- %v: %v (held: %v)\n", item["id"], item["description"], item["held"])
+		}
+	}
+	if resources, _ := worldContext["resources"].([]map[string]interface{}); len(resources) > 0 {
+		b.WriteString("World resources (add_resource / spend_resource with resource_id and a whole amount; gate costly choices with requires, e.g. resources['gold'] >= 10):\n")
+		for _, resource := range resources {
+			fmt.Fprintf(&b, "- %v: %v (balance: %v", resource["id"], resource["description"], resource["balance"])
+			if bankruptcy, _ := resource["bankruptcy"].(bool); bankruptcy {
+				b.WriteString("; overspending kills the player")
+			}
+			b.WriteString(")\n")
+		}
+	}
+	if macros, _ := worldContext["macros"].([]map[string]interface{}); len(macros) > 0 {
+		b.WriteString("World macros (call by id with empty params; each expands into its listed calls):\n")
+		for _, macro := range macros {
+			fmt.Fprintf(&b, "- %v: %v\n", macro["id"], macro["description"])
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// jobInstructions renders the Writer's instruction for each job only this
+// engine queues, from the job's context
+var jobInstructions = map[string]func(ctx map[string]interface{}) string{
+	"obituary": func(ctx map[string]interface{}) string {
+		return fmt.Sprintf("[OBITUARY] 1 INFO card (id MUST be \"obituary_%v\"): A short obituary (2-4 sentences) for life %v, ended by %v at %v. Recall the choices that defined it: %s. Give the life closure. source='obituary'.",
+			ctx["life"], ctx["life"], ctx["cause_stat"], ctx["boundary"], jobJSON(ctx["choices"]))
+	},
+	"memorial": func(ctx map[string]interface{}) string {
+		return fmt.Sprintf("[MEMORIAL] 1 INFO card (id MUST start with \"memorial_\"): %v has died of old age at %v. A short, moving farewell. source='info'.",
+			ctx["name"], ctx["age"])
+	},
+	"successor": func(ctx map[string]interface{}) string {
+		return fmt.Sprintf("[SUCCESSOR] 1 card introducing someone who takes the place of %v (%v). Add a \"new_npc\" field: {\"id\": \"snake_case\", \"name\": \"...\", \"description\": \"...\", \"appearance\": \"...\", \"age\": N}. The id must be new. source='plot'.",
+			ctx["name"], ctx["appearance"])
+	},
+	"era": func(ctx map[string]interface{}) string {
+		return fmt.Sprintf("[ERA] 1 INFO card (id MUST start with \"era_\", e.g. \"era_%v\"): The world passes into %v: %v. Mention who has left (%s) and who has arrived (%s). source='info'.",
+			ctx["era_id"], ctx["name"], ctx["description"], jobJSON(ctx["retired_npcs"]), jobJSON(ctx["introduced_npcs"]))
+	},
+	"interlude": func(ctx map[string]interface{}) string {
+		count, ok := ctx["card_count"]
+		if !ok {
+			count = 3
+		}
+		return fmt.Sprintf("[INTERLUDE] %v cards (ids MUST start with \"interlude_\") set in a liminal space between lives, after a death by %v at %v. Every card but the last is an INFO card; the last is a choice card whose two options are the player's meta-choice for the next life. Its calls may only use update_stat, add_tag, remove_tag and reveal_stat, and shape how the next life starts. source='interlude'.",
+			count, ctx["cause_stat"], ctx["boundary"])
+	},
+	"lore": func(ctx map[string]interface{}) string {
+		return fmt.Sprintf("[LORE] 1 INFO card (id MUST be \"%v\"): A short encyclopedia entry (2-4 sentences) on the %v \"%v\" (%v), written as in-world lore that deepens it without spoiling what is to come. source='lore'.",
+			ctx["card_id"], ctx["kind"], ctx["name"], ctx["description"])
+	},
+	"chronicle": func(ctx map[string]interface{}) string {
+		return fmt.Sprintf("[CHRONICLE] 1 INFO card (id MUST be \"%v\"): Rewrite this summary of week %v as a short chronicle (2-4 sentences) in the world's voice, keeping every fact: %q. Choices: %s. Stat changes: %s. source='chronicle'.",
+			ctx["card_id"], ctx["week"], fmt.Sprint(ctx["draft"]), jobJSON(ctx["choices"]), jobJSON(ctx["trend"]))
+	},
+	"departure": func(ctx map[string]interface{}) string {
+		return fmt.Sprintf("[DEPARTURE] 1 INFO card (id MUST start with \"departure_\"): %v (affinity %v) has had enough of the player and leaves for good. A bitter or sorrowful parting that shows why. source='info'.",
+			ctx["name"], ctx["affinity"])
+	},
+	"nudge": func(ctx map[string]interface{}) string {
+		return fmt.Sprintf("[NUDGE] 1 INFO card (id MUST start with \"nudge_\"): The story is overdue for this beat: %v. Hint at what would bring it about (it fires once %v) without forcing it. source='info'.",
+			ctx["plot_description"], ctx["condition"])
+	},
+}
+
+// jobJSON renders a job context value as JSON for the Writer
+func jobJSON(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "null"
+	}
+	return string(data)
+}
+
+// jobDirectives lists the batch's jobs that only this engine queues, one
+// card request each, or returns "" when there are none
+func jobDirectives(jobs []CardGenJob) string {
+	var b strings.Builder
+	for _, job := range jobs {
+		render, ok := jobInstructions[job.Type]
+		if !ok {
+			continue
+		}
+		if b.Len() == 0 {
+			b.WriteString("MORE JOB CARDS (generate these as well as the JOB CARDS below):\n")
+		}
+		b.WriteString("- " + render(job.Context) + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
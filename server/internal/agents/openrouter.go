@@ -4,11 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // OpenRouterClient handles communication with OpenRouter API
@@ -16,8 +21,26 @@ type OpenRouterClient struct {
 	apiKey     string
 	baseURL    string
 	httpClient *http.Client
+	recorder   *Recorder // optional record/replay of every call
+}
+
+// Completer sends completion requests. OpenRouterClient is the one agents
+// use by default; tests substitute their own.
+type Completer interface {
+	CreateCompletion(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error)
 }
 
+// CompleterFunc adapts a function to a Completer
+type CompleterFunc func(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error)
+
+// CreateCompletion calls f
+func (f CompleterFunc) CreateCompletion(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	return f(ctx, req)
+}
+
+// ErrNoChoices is returned when the API answers without a completion
+var ErrNoChoices = errors.New("no choices in response")
+
 // NewOpenRouterClient creates a new OpenRouter client
 func NewOpenRouterClient() *OpenRouterClient {
 	apiKey := os.Getenv("OPENROUTER_API_KEY")
@@ -31,9 +54,26 @@ func NewOpenRouterClient() *OpenRouterClient {
 		httpClient: &http.Client{
 			Timeout: 60 * time.Second,
 		},
+		recorder: defaultRecorder(),
 	}
 }
 
+// defaultRecorder builds the recorder configured by the environment; a bad
+// configuration is logged and recording stays off
+func defaultRecorder() *Recorder {
+	recorder, err := NewRecorderFromEnv()
+	if err != nil {
+		log.Printf("LLM recording disabled: %v", err)
+		return nil
+	}
+	return recorder
+}
+
+// SetRecorder attaches a recorder to the client (nil disables it)
+func (c *OpenRouterClient) SetRecorder(recorder *Recorder) {
+	c.recorder = recorder
+}
+
 // Message represents a chat message
 type Message struct {
 	Role    string `json:"role"`
@@ -45,6 +85,7 @@ type CompletionRequest struct {
 	Model       string    `json:"model"`
 	Messages    []Message `json:"messages"`
 	Temperature float64   `json:"temperature,omitempty"`
+	TopP        float64   `json:"top_p,omitempty"`
 	MaxTokens   int       `json:"max_tokens,omitempty"`
 }
 
@@ -70,19 +111,50 @@ type CompletionResponse struct {
 	} `json:"error"`
 }
 
-// CreateCompletion calls the OpenRouter API
-func (c *OpenRouterClient) CreateCompletion(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
-	if c.apiKey == "" {
-		return nil, fmt.Errorf("OPENROUTER_API_KEY not set")
-	}
-
-	// Set defaults
+// ApplyDefaults fills in unset sampling parameters
+func (req *CompletionRequest) ApplyDefaults() {
 	if req.Temperature == 0 {
 		req.Temperature = 0.7
 	}
 	if req.MaxTokens == 0 {
 		req.MaxTokens = 2048
 	}
+}
+
+// CreateCompletion calls the OpenRouter API, traced as part of ctx
+func (c *OpenRouterClient) CreateCompletion(ctx context.Context, req *CompletionRequest) (resp *CompletionResponse, err error) {
+	ctx, span := tracing.Start(ctx, "openrouter.CreateCompletion")
+	defer tracing.End(span, &err)
+
+	resp, err = c.createCompletion(ctx, req)
+	span.SetAttributes(attribute.String("llm.model", req.Model))
+	if resp != nil {
+		span.SetAttributes(
+			attribute.Int("llm.prompt_tokens", resp.Usage.PromptTokens),
+			attribute.Int("llm.completion_tokens", resp.Usage.CompletionTokens))
+	}
+	return resp, err
+}
+
+// createCompletion calls the OpenRouter API, or replays a recorded answer
+func (c *OpenRouterClient) createCompletion(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	req.ApplyDefaults()
+
+	// Replay never touches the network
+	if c.recorder != nil && c.recorder.Mode() == RecordReplay {
+		return c.recorder.Load(req)
+	}
+
+	if c.apiKey == "" {
+		return nil, fmt.Errorf("OPENROUTER_API_KEY not set")
+	}
+
+	monitor := GetSpendMonitor()
+	if monitor != nil {
+		if err := monitor.Allow(); err != nil {
+			return nil, err
+		}
+	}
 
 	// Marshal request
 	body, err := json.Marshal(req)
@@ -131,7 +203,17 @@ func (c *OpenRouterClient) CreateCompletion(ctx context.Context, req *Completion
 	}
 
 	if len(completionResp.Choices) == 0 {
-		return nil, fmt.Errorf("no choices in response")
+		return nil, ErrNoChoices
+	}
+
+	if monitor != nil {
+		monitor.Record(req.Model, completionResp.Usage.PromptTokens, completionResp.Usage.CompletionTokens)
+	}
+
+	if c.recorder != nil && c.recorder.Mode() == RecordCapture {
+		if err := c.recorder.Save(req, &completionResp); err != nil {
+			log.Printf("Failed to record LLM response: %v", err)
+		}
 	}
 
 	return &completionResp, nil
@@ -0,0 +1,96 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Content ratings, from mildest
+const (
+	RatingEveryone = "everyone"
+	RatingTeen     = "teen"
+	RatingMature   = "mature"
+)
+
+// ContentRatings tells the agents what each rating allows
+var ContentRatings = map[string]string{
+	RatingEveryone: "Keep everything suitable for all ages: no graphic violence, gore, sexual content, drug use or strong language. Danger stays gentle and death happens off-screen.",
+	RatingTeen:     "Keep content suitable for teenagers: peril and violence may appear but never graphically, with no sexual content and only mild language.",
+	RatingMature:   "Mature themes are allowed: violence, dark subject matter and strong language may appear when the story calls for it. Nothing sexual.",
+}
+
+// languageNames are the display names of common languages, so prompts name
+// the language rather than its code
+var languageNames = map[string]string{
+	"de": "German",
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"id": "Indonesian",
+	"it": "Italian",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"pt": "Portuguese",
+	"ru": "Russian",
+	"th": "Thai",
+	"vi": "Vietnamese",
+	"zh": "Chinese",
+}
+
+// Preferences are the player settings that shape generated text
+type Preferences struct {
+	Language      string `json:"language,omitempty"`       // BCP 47 tag; "" means English
+	ContentRating string `json:"content_rating,omitempty"` // a key of ContentRatings; "" means no guidance
+	SimpleText    bool   `json:"simple_text,omitempty"`    // ask for a plain-language variant of each card
+}
+
+type preferencesContextKey struct{}
+
+// WithPreferences attaches a player's preferences to an agent call
+func WithPreferences(ctx context.Context, prefs Preferences) context.Context {
+	return context.WithValue(ctx, preferencesContextKey{}, prefs)
+}
+
+// preferencesFrom returns the preferences attached to ctx, if any
+func preferencesFrom(ctx context.Context) Preferences {
+	prefs, _ := ctx.Value(preferencesContextKey{}).(Preferences)
+	return prefs
+}
+
+// languageInstruction names the language generated text is written in
+func languageInstruction(lang string) string {
+	if lang == "" {
+		return "English"
+	}
+	base, _, _ := strings.Cut(lang, "-")
+	name, ok := languageNames[strings.ToLower(base)]
+	if !ok {
+		return lang
+	}
+	if base != lang {
+		return fmt.Sprintf("%s (%s)", name, lang)
+	}
+	return name
+}
+
+// ratingGuidance tells an agent what the content rating allows, or returns
+// "" without one
+func ratingGuidance(rating string) string {
+	instruction, ok := ContentRatings[rating]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("CONTENT RATING (%s): %s", rating, instruction)
+}
+
+// simpleTextDirective asks the Writer for a plain-language variant of every
+// card, or returns "" when the player did not ask for one
+func simpleTextDirective(prefs Preferences) string {
+	if !prefs.SimpleText {
+		return ""
+	}
+	return fmt.Sprintf("SIMPLE TEXT: Give every card a \"simple_description\" next to its description. "+
+		"It tells the same thing in %s a 9-year-old can read: short sentences, common words, no idioms or metaphors, "+
+		"and names spelled out instead of pronouns where it helps.", languageInstruction(prefs.Language))
+}
@@ -0,0 +1,55 @@
+package agents
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ModelPrice is the USD price per million tokens for a model
+type ModelPrice struct {
+	InputPerMTok  float64 `json:"input_per_mtok"`
+	OutputPerMTok float64 `json:"output_per_mtok"`
+}
+
+// PricingTable maps model IDs to prices
+type PricingTable map[string]ModelPrice
+
+// DefaultPricing covers the models the agents use out of the box
+func DefaultPricing() PricingTable {
+	return PricingTable{
+		"claude-3-5-sonnet-20241022": {InputPerMTok: 3, OutputPerMTok: 15},
+		"claude-3-5-haiku-20241022":  {InputPerMTok: 0.8, OutputPerMTok: 4},
+		"claude-3-opus-20240229":     {InputPerMTok: 15, OutputPerMTok: 75},
+	}
+}
+
+// LoadPricingTable reads a JSON pricing table and merges it over the defaults
+func LoadPricingTable(path string) (PricingTable, error) {
+	table := DefaultPricing()
+	if path == "" {
+		return table, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pricing table: %w", err)
+	}
+	var overrides PricingTable
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse pricing table: %w", err)
+	}
+	for model, price := range overrides {
+		table[model] = price
+	}
+	return table, nil
+}
+
+// Cost returns the USD cost of a call; unknown models cost 0 and report false
+func (t PricingTable) Cost(model string, promptTokens, completionTokens int) (float64, bool) {
+	price, ok := t[model]
+	if !ok {
+		return 0, false
+	}
+	return (float64(promptTokens)*price.InputPerMTok + float64(completionTokens)*price.OutputPerMTok) / 1e6, true
+}
@@ -0,0 +1,389 @@
+package agents
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PromptFiles are the templates the agents render
+var PromptFiles = []string{
+	"architect_system.j2",
+	"architect_user.j2",
+	"architect_refine.j2",
+	"writer_system.j2",
+	"writer_user.j2",
+}
+
+// fallbackArchitectSystem is used when architect templates cannot be loaded
+const fallbackArchitectSystem = `You are The Architect — a world-builder for a card-based survival game similar to Reigns.
+
+Your job is to generate a COMPLETE world. Output it as STREAMING SECTIONS — each section starts with a markdown heading
+(# Creative Title...) followed by a JSON code block.
+
+FORMAT:
+# <Creative thematic title for this section>
+  ` + "`" + `json
+  { ... section data ... }
+  ` + "`" + `
+
+The heading MUST start with a VERB (action word ending in -ing) followed by "..." (e.g. "Forging the Iron Throne...",
+"Summoning the court..."). Do not start with nouns.
+
+Generate these sections IN THIS EXACT ORDER:
+
+SECTION 1 — WORLD CORE:
+SECTION 2 — PLAYER CHARACTER & STATS:
+SECTION 3 — NPCS & RELATIONSHIPS:
+SECTION 4 — TAGS:
+SECTION 5 — STORY DAG:
+SECTION 6 — SEASONS:
+
+CRITICAL RULES:
+- ALL IDs, tags, conditions, traits, and function params must be in ENGLISH (snake_case)
+- Display text (names, descriptions, flavor) in the TARGET LANGUAGE
+- Stats should be thematically tied to the world
+- Conditions are Python expressions evaluated via eval() — keep them simple and safe
+- Generate 12-15 plot nodes total`
+
+// fallbackArchitectRefine is used when architect_refine.j2 cannot be loaded
+const fallbackArchitectRefine = `You are The Architect, revising a world draft for a card-based survival game similar to Reigns.
+
+The user reviews the draft section by section and asks for changes. Each request comes with the CURRENT DRAFT as JSON.
+Make the smallest edit that satisfies the request and leave everything else as it is.
+
+Answer with JSON only:
+{"reply": "<one or two sentences telling the user what you changed>", "changes": {"<top-level field>": <its complete new value>}}
+
+RULES:
+- Only include the top-level fields you change, each with its COMPLETE new value (e.g. the whole "npcs" array)
+- NEVER change the ID of anything that stays; renaming changes "name" only
+- New entities get new snake_case English IDs; removed entities must not be referenced anywhere
+- When you remove IDs, update every field that references them (relationships, initial_stats, initial_tags, plot node links)
+- Display text in {{ language_instruction }}; IDs, conditions and function params in English`
+
+// fallbackWriterSystem is used when writer_system.j2 cannot be loaded
+const fallbackWriterSystem = `You are The Writer — a real-time card generator for a card-based survival game similar to Reigns.
+
+You generate cards in BATCHES. Each batch contains a mix of:
+- COMMON cards: everyday events, character interactions, moral dilemmas
+- JOB cards: specific requests (plot events, death messages, reborn messages, welcome messages)
+
+CARD DESIGN RULES:
+1. React to the current situation (stats, tags, ongoing events, current phase)
+2. Present meaningful dilemmas with real tradeoffs — no obviously correct choice
+3. Feature NPCs from the ENABLED NPC list only (use NPC IDs as character field)
+4. Left and right choices should BOTH have downsides
+5. Keep descriptions to 1-3 punchy sentences
+6. Effects are expressed as FUNCTION CALLS (left_calls / right_calls), NOT raw stat dicts
+
+TAG DISCIPLINE:
+- You MUST ONLY use tag IDs from the available_tags list provided in context
+- Tags are permanent world state modifiers — use them sparingly (1-2 per batch at most)
+- 80%+ of choices should use ONLY update_stat calls, no tags`
+
+// fallbackWriterUser is used when writer_user.j2 cannot be loaded
+const fallbackWriterUser = "Generate a batch of cards for the current game state."
+
+// promptCache holds loaded templates so they are read from disk once and
+// can be swapped at runtime by ReloadPrompts
+type promptCache struct {
+	mu        sync.RWMutex
+	dir       string // override directory; empty searches the default paths
+	templates map[string]string
+}
+
+var prompts = &promptCache{
+	dir:       os.Getenv("PROMPT_DIR"),
+	templates: make(map[string]string),
+}
+
+// PromptReload reports the outcome of ReloadPrompts
+type PromptReload struct {
+	Dir     string   `json:"dir"`
+	Loaded  []string `json:"loaded"`
+	Missing []string `json:"missing"`
+}
+
+// promptPaths returns the candidate paths for a template
+func promptPaths(dir, filename string) []string {
+	if dir != "" {
+		return []string{filepath.Join(dir, filename)}
+	}
+	// Try multiple possible paths
+	return []string{
+		filepath.Join("prompts", filename),
+		filepath.Join("..", "..", "prompts", filename),
+		filepath.Join("../../prompts", filename),
+	}
+}
+
+// readPrompt reads a template from disk
+func readPrompt(dir, filename string) (string, error) {
+	for _, path := range promptPaths(dir, filename) {
+		content, err := os.ReadFile(path)
+		if err == nil {
+			return string(content), nil
+		}
+	}
+	return "", fmt.Errorf("could not find prompt file: %s", filename)
+}
+
+// loadPrompt returns a Jinja2 template, reading it from disk on first use
+func loadPrompt(filename string) (string, error) {
+	prompts.mu.RLock()
+	content, ok := prompts.templates[filename]
+	dir := prompts.dir
+	prompts.mu.RUnlock()
+	if ok {
+		return content, nil
+	}
+
+	content, err := readPrompt(dir, filename)
+	if err != nil {
+		return "", err
+	}
+
+	prompts.mu.Lock()
+	defer prompts.mu.Unlock()
+	if prompts.dir == dir {
+		prompts.templates[filename] = content
+	}
+	return content, nil
+}
+
+// ReloadPrompts re-reads all templates from disk without a restart. A
+// non-empty dir becomes the new override directory. Templates that cannot
+// be found are dropped, so the agents fall back to their inline prompts.
+func ReloadPrompts(dir string) *PromptReload {
+	prompts.mu.Lock()
+	defer prompts.mu.Unlock()
+
+	if dir != "" {
+		prompts.dir = dir
+	}
+
+	result := &PromptReload{
+		Dir:     prompts.dir,
+		Loaded:  make([]string, 0, len(PromptFiles)),
+		Missing: make([]string, 0),
+	}
+	templates := make(map[string]string, len(PromptFiles))
+	for _, filename := range PromptFiles {
+		content, err := readPrompt(prompts.dir, filename)
+		if err != nil {
+			result.Missing = append(result.Missing, filename)
+			continue
+		}
+		templates[filename] = content
+		result.Loaded = append(result.Loaded, filename)
+	}
+	sort.Strings(result.Loaded)
+	prompts.templates = templates
+	return result
+}
+
+// RenderArchitectPrompts renders the architect system and user prompts in
+// the player's language and content rating, falling back to the inline
+// prompt if the templates are missing
+func RenderArchitectPrompts(theme string, statCount int, prefs Preferences) (systemPrompt, userPrompt string) {
+	systemPrompt, userPrompt = fallbackArchitectSystem, theme
+
+	systemContent, systemErr := loadPrompt("architect_system.j2")
+	userContent, userErr := loadPrompt("architect_user.j2")
+	if systemErr == nil && userErr == nil {
+		// Simple template rendering for architect_user.j2
+		systemPrompt = systemContent
+		userPrompt = strings.ReplaceAll(userContent, "{{ language_instruction }}", languageInstruction(prefs.Language))
+		userPrompt = strings.ReplaceAll(userPrompt, "{{ theme if theme else \"Surprise me with something creative and unique\" }}", theme)
+		userPrompt = strings.ReplaceAll(userPrompt, "{{ stat_count }}", fmt.Sprintf("%d", statCount))
+	}
+	systemPrompt += "\n\n" + architectEngineRules
+
+	if guidance := ratingGuidance(prefs.ContentRating); guidance != "" {
+		userPrompt = guidance + "\n\n" + userPrompt
+	}
+	return systemPrompt, userPrompt
+}
+
+// RenderRefinePrompt renders the system prompt for revising a world draft
+// in the player's language and content rating
+func RenderRefinePrompt(prefs Preferences) string {
+	content, err := loadPrompt("architect_refine.j2")
+	if err != nil {
+		content = fallbackArchitectRefine
+	}
+	prompt := strings.ReplaceAll(content, "{{ language_instruction }}", languageInstruction(prefs.Language))
+	if guidance := ratingGuidance(prefs.ContentRating); guidance != "" {
+		prompt += "\n\n" + guidance
+	}
+	return prompt
+}
+
+// RenderWriterPrompts renders the writer system and user prompts for a
+// batch of jobs, falling back to inline prompts if the templates are missing
+func RenderWriterPrompts(jobs []CardGenJob, worldContext map[string]interface{}) (systemPrompt, userPrompt string) {
+	systemPrompt, err := loadPrompt("writer_system.j2")
+	if err != nil {
+		systemPrompt = fallbackWriterSystem
+	}
+	systemPrompt += "\n\n" + writerEngineRules
+
+	userContent, err := loadPrompt("writer_user.j2")
+	if err != nil {
+		userContent = fallbackWriterUser
+	}
+
+	// Sampling tunes the request, not the prompt
+	if _, ok := worldContext["sampling"]; ok {
+		prompted := make(map[string]interface{}, len(worldContext))
+		for key, value := range worldContext {
+			prompted[key] = value
+		}
+		delete(prompted, "sampling")
+		worldContext = prompted
+	}
+
+	contextJSON, _ := json.Marshal(worldContext)
+	stats, _ := worldContext["stats"].([]map[string]interface{})
+	if stats == nil {
+		stats = make([]map[string]interface{}, 0)
+	}
+	statsJSON, _ := json.Marshal(stats)
+
+	prefs, _ := worldContext["preferences"].(Preferences)
+
+	// Simple template rendering for writer_user.j2
+	userPrompt = strings.ReplaceAll(userContent, "{{ language_instruction }}", languageInstruction(prefs.Language))
+	userPrompt = strings.ReplaceAll(userPrompt, "{{ world_context }}", fmt.Sprintf("%v", worldContext))
+	userPrompt = strings.ReplaceAll(userPrompt, "{{ stat_names }}", string(statsJSON))
+	userPrompt = strings.ReplaceAll(userPrompt, "{{ 2 * stat_names|length }}", fmt.Sprintf("%d", 2*len(stats)))
+	userPrompt = strings.ReplaceAll(userPrompt, "{{ snapshot | tojson(indent=2) }}", string(contextJSON))
+	deck, _ := worldContext["deck"].(map[string]interface{})
+	userPrompt = strings.ReplaceAll(userPrompt, "{{ common_count }}", fmt.Sprintf("%v", commonCount(deck)))
+	userPrompt = strings.ReplaceAll(userPrompt, "{{ jobs | length }}", fmt.Sprintf("%d", len(jobs)))
+
+	// The engine's own jobs and the world's items, resources and macros lead
+	// the template; simple text, rating, deck mix, difficulty, rebirth,
+	// pacing and warnings go before them so the model cannot miss them
+	if directives := jobDirectives(jobs); directives != "" {
+		userPrompt = directives + "\n\n" + userPrompt
+	}
+	if lists := worldListsDirective(worldContext); lists != "" {
+		userPrompt = lists + "\n\n" + userPrompt
+	}
+	if directive := simpleTextDirective(prefs); directive != "" {
+		userPrompt = directive + "\n\n" + userPrompt
+	}
+	if guidance := ratingGuidance(prefs.ContentRating); guidance != "" {
+		userPrompt = guidance + "\n\n" + userPrompt
+	}
+	if directive := infoCardDirective(deck); directive != "" {
+		userPrompt = directive + "\n\n" + userPrompt
+	}
+	difficulty, _ := worldContext["difficulty"].(map[string]interface{})
+	if guidance := deltaGuidance(difficulty); guidance != "" {
+		userPrompt = guidance + "\n\n" + userPrompt
+	}
+	if directive := rebornDirective(worldContext); directive != "" {
+		userPrompt = directive + "\n\n" + userPrompt
+	}
+	pacing, _ := worldContext["pacing"].(map[string]interface{})
+	if directive := pacingDirective(pacing); directive != "" {
+		userPrompt = directive + "\n\n" + userPrompt
+	}
+	flags, _ := worldContext["danger_flags"].([]map[string]interface{})
+	if warnings := dangerWarnings(flags); warnings != "" {
+		userPrompt = warnings + "\n\n" + userPrompt
+	}
+
+	return systemPrompt, userPrompt
+}
+
+// defaultCommonCount is asked for when the context has no deck
+const defaultCommonCount = 5
+
+// commonCount returns how many common cards the deck asks for
+func commonCount(deck map[string]interface{}) interface{} {
+	if count, ok := deck["common_count"]; ok {
+		return count
+	}
+	return defaultCommonCount
+}
+
+// infoCardDirective tells the Writer how many common cards should be info
+// cards, or returns "" when the world leaves it to the Writer
+func infoCardDirective(deck map[string]interface{}) string {
+	count, _ := deck["info_count"].(int)
+	if count <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("DECK MIX: make exactly %d of the %v common cards INFO cards (no choices) as breathers between decisions.",
+		count, commonCount(deck))
+}
+
+// deltaGuidance tells the Writer how large stat changes should be at the
+// game's difficulty, or returns "" without one
+func deltaGuidance(difficulty map[string]interface{}) string {
+	typical, ok := difficulty["typical_delta"]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("DIFFICULTY (level %v): keep most stat changes around ±%v and none beyond ±%v.",
+		difficulty["level"], typical, difficulty["max_delta"])
+}
+
+// rebornDirective tells the Writer how the resurrection mechanic narrates
+// the return on the first day after a death, or returns "" otherwise
+func rebornDirective(worldContext map[string]interface{}) string {
+	if afterDeath, _ := worldContext["is_first_day_after_death"].(bool); !afterDeath {
+		return ""
+	}
+	snapshot, _ := worldContext["snapshot"].(map[string]interface{})
+	resurrection, _ := snapshot["resurrection"].(map[string]interface{})
+	prompt, _ := resurrection["reborn_prompt"].(string)
+	if prompt == "" {
+		return ""
+	}
+	return "REBORN: write the [REBORN] card this way instead: " + prompt
+}
+
+// pacingInstructions tells the Writer how to follow each Director
+// directive; "steady" needs none
+var pacingInstructions = map[string]string{
+	"calm_week":    "Make this a calm week: mostly small stat changes, quiet slice-of-life moments and room to recover. Hold back new threats.",
+	"escalate":     "Escalate: raise the stakes, push the active plot forward and offer choices with real consequences.",
+	"comic_relief": "Add comic relief: include at least one light-hearted or absurd card, with a surprise that rewards reading it.",
+}
+
+// pacingDirective renders the Director's pacing directive for the Writer,
+// or returns "" when there is nothing to adjust
+func pacingDirective(pacing map[string]interface{}) string {
+	directive, _ := pacing["directive"].(string)
+	instruction, ok := pacingInstructions[directive]
+	if !ok {
+		return ""
+	}
+	reason, _ := pacing["reason"].(string)
+	return fmt.Sprintf("PACING (%s): %s", reason, instruction)
+}
+
+// dangerWarnings tells the Writer which stats are about to kill the player
+// and asks for a way out of each, or returns "" when none are in danger
+func dangerWarnings(flags []map[string]interface{}) string {
+	if len(flags) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("DANGER - these stats are close to killing the player:\n")
+	for _, flag := range flags {
+		fmt.Fprintf(&b, "- %v (%v) is %v, %v from %v: %s it\n",
+			flag["name"], flag["stat_id"], flag["value"], flag["distance"], flag["boundary"], flag["relief"])
+	}
+	b.WriteString("Offer at least one card in this batch with a choice that moves each of these stats away from its boundary, so no death is unavoidable.")
+	return b.String()
+}
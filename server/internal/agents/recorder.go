@@ -0,0 +1,127 @@
+package agents
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RecordMode selects how a Recorder treats LLM calls
+type RecordMode string
+
+const (
+	RecordOff     RecordMode = ""       // call the API, store nothing
+	RecordCapture RecordMode = "record" // call the API and store every pair
+	RecordReplay  RecordMode = "replay" // serve stored responses, never call the API
+)
+
+// Recording is one stored request/response pair
+type Recording struct {
+	Key      string              `json:"key"`
+	Request  *CompletionRequest  `json:"request"`
+	Response *CompletionResponse `json:"response"`
+}
+
+// Recorder persists LLM interactions as one JSON file per request, keyed by
+// a hash of the request, so games can be replayed without the API
+type Recorder struct {
+	dir  string
+	mode RecordMode
+}
+
+// NewRecorder creates a recorder storing pairs in dir
+func NewRecorder(dir string, mode RecordMode) (*Recorder, error) {
+	switch mode {
+	case RecordOff, RecordCapture, RecordReplay:
+	default:
+		return nil, fmt.Errorf("unknown record mode: %s", mode)
+	}
+	if mode == RecordCapture {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create record dir: %w", err)
+		}
+	}
+	return &Recorder{dir: dir, mode: mode}, nil
+}
+
+// NewRecorderFromEnv configures a recorder from LLM_RECORD_MODE and
+// LLM_RECORD_DIR (default "recordings"). It returns nil when recording is off.
+func NewRecorderFromEnv() (*Recorder, error) {
+	mode := RecordMode(os.Getenv("LLM_RECORD_MODE"))
+	if mode == RecordOff {
+		return nil, nil
+	}
+	dir := os.Getenv("LLM_RECORD_DIR")
+	if dir == "" {
+		dir = "recordings"
+	}
+	return NewRecorder(dir, mode)
+}
+
+// Mode returns the recorder's mode
+func (r *Recorder) Mode() RecordMode {
+	return r.mode
+}
+
+// RequestKey hashes a request into a stable recording key
+func RequestKey(req *CompletionRequest) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// path returns the file holding a recording
+func (r *Recorder) path(key string) string {
+	return filepath.Join(r.dir, key+".json")
+}
+
+// Load returns the recorded response for a request
+func (r *Recorder) Load(req *CompletionRequest) (*CompletionResponse, error) {
+	key, err := RequestKey(req)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(r.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no recorded response for request %s", key)
+		}
+		return nil, err
+	}
+
+	var rec Recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("failed to parse recording %s: %w", key, err)
+	}
+	if rec.Response == nil {
+		return nil, fmt.Errorf("recording %s has no response", key)
+	}
+	return rec.Response, nil
+}
+
+// Save stores a request/response pair, replacing any earlier recording
+func (r *Recorder) Save(req *CompletionRequest, resp *CompletionResponse) error {
+	key, err := RequestKey(req)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(Recording{Key: key, Request: req, Response: resp}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write then rename so a crash never leaves a truncated recording
+	tmp := r.path(key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path(key))
+}
@@ -0,0 +1,404 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxRefinementHistory bounds the earlier turns sent with a refinement
+const maxRefinementHistory = 10
+
+// WorldSection is one part of a world schema reviewed on its own, in the
+// order the Architect writes them
+type WorldSection struct {
+	ID     string   `json:"id"`
+	Title  string   `json:"title"`
+	Fields []string `json:"fields"` // top-level schema fields it covers
+}
+
+// WorldSections split a schema for review. Every top-level field belongs
+// to exactly one section.
+var WorldSections = []WorldSection{
+	{ID: "world", Title: "World core", Fields: []string{"name", "era", "description", "eras", "resurrection_mechanic", "resurrection_flavor", "dynasty", "mortality_rules", "difficulty", "deck", "sampling"}},
+	{ID: "player", Title: "Player character & stats", Fields: []string{"player_character", "stats", "initial_stats", "resources", "pressure_rules"}},
+	{ID: "npcs", Title: "NPCs & relationships", Fields: []string{"npcs", "relationships"}},
+	{ID: "tags", Title: "Tags & items", Fields: []string{"tags", "initial_tags", "items", "initial_items"}},
+	{ID: "story", Title: "Story DAG", Fields: []string{"plot_nodes", "arcs", "macros"}},
+	{ID: "seasons", Title: "Seasons", Fields: []string{"seasons", "calendar"}},
+}
+
+// FindWorldSection returns the section with an ID, or nil
+func FindWorldSection(id string) *WorldSection {
+	for i := range WorldSections {
+		if WorldSections[i].ID == id {
+			return &WorldSections[i]
+		}
+	}
+	return nil
+}
+
+// SectionView is a section of a schema with its fields' values
+type SectionView struct {
+	WorldSection
+	Data map[string]interface{} `json:"data"`
+}
+
+// SplitSections returns a schema section by section
+func SplitSections(schema *WorldGenSchema) ([]SectionView, error) {
+	fields, err := schemaFields(schema)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]SectionView, 0, len(WorldSections))
+	for _, section := range WorldSections {
+		view := SectionView{WorldSection: section, Data: make(map[string]interface{})}
+		for _, field := range section.Fields {
+			raw, ok := fields[field]
+			if !ok {
+				continue
+			}
+			var value interface{}
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return nil, err
+			}
+			view.Data[field] = value
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// schemaFields returns a schema's top-level fields as raw JSON
+func schemaFields(schema *WorldGenSchema) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// RefinementTurn is one exchange of a world draft's conversation
+type RefinementTurn struct {
+	Message string    `json:"message"`           // what the user asked for
+	Section string    `json:"section,omitempty"` // the section the edit was confined to
+	Reply   string    `json:"reply"`             // the Architect's summary of what it changed
+	Changed []string  `json:"changed"`           // top-level fields it replaced
+	At      time.Time `json:"at"`
+}
+
+// WorldEdit is the Architect's answer to a refinement: the top-level fields
+// it replaces, each with its complete new value
+type WorldEdit struct {
+	Reply   string                     `json:"reply"`
+	Changes map[string]json.RawMessage `json:"changes"`
+}
+
+// Refinement is a draft after an edit was applied
+type Refinement struct {
+	Schema  *WorldGenSchema
+	Reply   string
+	Changed []string // sorted
+}
+
+// RefineWorld asks the Architect for a targeted edit of a draft, confined
+// to a section unless section is "". history is the draft's conversation
+// so far, oldest first.
+func (a *ArchitectAgent) RefineWorld(ctx context.Context, draft *WorldGenSchema, history []RefinementTurn, message, section string) (*Refinement, error) {
+	if section != "" && FindWorldSection(section) == nil {
+		return nil, fmt.Errorf("unknown section %q", section)
+	}
+	draftJSON, err := json.Marshal(draft)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &CompletionRequest{
+		Model:     "claude-3-5-sonnet-20241022",
+		MaxTokens: 4096,
+		Messages:  []Message{{Role: "system", Content: RenderRefinePrompt(preferencesFrom(ctx))}},
+	}
+	if len(history) > maxRefinementHistory {
+		history = history[len(history)-maxRefinementHistory:]
+	}
+	for _, turn := range history {
+		req.Messages = append(req.Messages,
+			Message{Role: "user", Content: refinementRequest(turn.Message, turn.Section)},
+			Message{Role: "assistant", Content: turn.Reply},
+		)
+	}
+	req.Messages = append(req.Messages, Message{
+		Role:    "user",
+		Content: fmt.Sprintf("CURRENT DRAFT:\n%s\n\n%s", draftJSON, refinementRequest(message, section)),
+	})
+
+	resp, err := a.client.CreateCompletion(ctx, req)
+	if errors.Is(err, ErrNoChoices) {
+		recordValidationFailure(ctx, AgentArchitect, FailureEmptyResponse, req, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to call OpenRouter API: %w", err)
+	}
+
+	var edit WorldEdit
+	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &edit); err != nil {
+		recordValidationFailure(ctx, AgentArchitect, FailureInvalidJSON, req, err)
+		return nil, fmt.Errorf("failed to parse world edit: %w", err)
+	}
+	refined, err := ApplyWorldEdit(draft, &edit, section)
+	if err != nil {
+		recordValidationFailure(ctx, AgentArchitect, FailureSchema, req, err)
+		return nil, fmt.Errorf("invalid world edit: %w", err)
+	}
+	return refined, nil
+}
+
+// refinementRequest phrases a user's message for the Architect
+func refinementRequest(message, section string) string {
+	if section == "" {
+		return "REQUEST: " + message
+	}
+	s := FindWorldSection(section)
+	return fmt.Sprintf("REQUEST (only change the %s section: %s): %s", s.Title, strings.Join(s.Fields, ", "), message)
+}
+
+// ApplyWorldEdit replaces the fields an edit changes and checks the result:
+// changes stay inside section unless it is "", IDs that remain keep
+// their meaning, and no reference is left dangling
+func ApplyWorldEdit(draft *WorldGenSchema, edit *WorldEdit, section string) (*Refinement, error) {
+	if len(edit.Changes) == 0 {
+		return nil, fmt.Errorf("no changes")
+	}
+	allowed := make(map[string]bool)
+	for _, s := range WorldSections {
+		if section == "" || s.ID == section {
+			for _, field := range s.Fields {
+				allowed[field] = true
+			}
+		}
+	}
+
+	fields, err := schemaFields(draft)
+	if err != nil {
+		return nil, err
+	}
+	changed := make([]string, 0, len(edit.Changes))
+	for field, value := range edit.Changes {
+		if !allowed[field] {
+			return nil, fmt.Errorf("field %q is outside the section", field)
+		}
+		fields[field] = value
+		changed = append(changed, field)
+	}
+	sort.Strings(changed)
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var refined WorldGenSchema
+	if err := json.Unmarshal(data, &refined); err != nil {
+		return nil, err
+	}
+	if err := checkWorldSchema(&refined); err != nil {
+		return nil, err
+	}
+	if err := checkStableIDs(draft, &refined); err != nil {
+		return nil, err
+	}
+	// A draft the Architect left with dangling references is not held
+	// against the edit
+	if checkWorldReferences(draft) == nil {
+		if err := checkWorldReferences(&refined); err != nil {
+			return nil, err
+		}
+	}
+	return &Refinement{Schema: &refined, Reply: edit.Reply, Changed: changed}, nil
+}
+
+// namedIDs maps the display name of each entity of a kind to its ID
+type namedIDs struct {
+	kind  string
+	names func(schema *WorldGenSchema) map[string]string
+}
+
+// stableKinds are the entities whose IDs a refinement must keep
+var stableKinds = []namedIDs{
+	{"stat", func(s *WorldGenSchema) map[string]string {
+		m := make(map[string]string)
+		for _, stat := range s.Stats {
+			m[stat.Name] = stat.ID
+		}
+		return m
+	}},
+	{"tag", func(s *WorldGenSchema) map[string]string {
+		m := make(map[string]string)
+		for _, tag := range s.Tags {
+			m[tag.Name] = tag.ID
+		}
+		return m
+	}},
+	{"item", func(s *WorldGenSchema) map[string]string {
+		m := make(map[string]string)
+		for _, item := range s.Items {
+			m[item.Name] = item.ID
+		}
+		return m
+	}},
+	{"resource", func(s *WorldGenSchema) map[string]string {
+		m := make(map[string]string)
+		for _, resource := range s.Resources {
+			m[resource.Name] = resource.ID
+		}
+		return m
+	}},
+	{"NPC", func(s *WorldGenSchema) map[string]string {
+		m := make(map[string]string)
+		for _, npc := range s.NPCs {
+			m[npc.Name] = npc.ID
+		}
+		return m
+	}},
+	{"season", func(s *WorldGenSchema) map[string]string {
+		m := make(map[string]string)
+		for _, season := range s.Seasons {
+			m[season.Name] = season.ID
+		}
+		return m
+	}},
+	{"arc", func(s *WorldGenSchema) map[string]string {
+		m := make(map[string]string)
+		for _, arc := range s.Arcs {
+			m[arc.Title] = arc.ID
+		}
+		return m
+	}},
+	{"era", func(s *WorldGenSchema) map[string]string {
+		m := make(map[string]string)
+		for _, era := range s.Eras {
+			m[era.Name] = era.ID
+		}
+		return m
+	}},
+}
+
+// checkStableIDs rejects an edit that gave an entity a new ID: the player
+// character keeps its ID, and an entity that kept its name may not have
+// traded its ID for one the draft did not have
+func checkStableIDs(before, after *WorldGenSchema) error {
+	if before.PlayerChar.ID != "" && after.PlayerChar.ID != before.PlayerChar.ID {
+		return fmt.Errorf("player character ID changed from %s to %s", before.PlayerChar.ID, after.PlayerChar.ID)
+	}
+	for _, kind := range stableKinds {
+		old := kind.names(before)
+		oldIDs := make(map[string]bool, len(old))
+		for _, id := range old {
+			oldIDs[id] = true
+		}
+		for name, id := range kind.names(after) {
+			if was, ok := old[name]; ok && was != id && !oldIDs[id] {
+				return fmt.Errorf("%s %q changed ID from %s to %s", kind.kind, name, was, id)
+			}
+		}
+	}
+	return nil
+}
+
+// checkWorldReferences rejects a schema whose references name missing stats,
+// tags, items, NPCs, plot nodes or arcs, or whose resources clash with stats
+func checkWorldReferences(schema *WorldGenSchema) error {
+	stats := make(map[string]bool, len(schema.Stats))
+	for _, stat := range schema.Stats {
+		stats[stat.ID] = true
+	}
+	tags := make(map[string]bool, len(schema.Tags))
+	for _, tag := range schema.Tags {
+		tags[tag.ID] = true
+	}
+	items := make(map[string]bool, len(schema.Items))
+	for _, item := range schema.Items {
+		items[item.ID] = true
+	}
+	entities := map[string]bool{schema.PlayerChar.ID: true}
+	for _, npc := range schema.NPCs {
+		entities[npc.ID] = true
+	}
+	nodes := make(map[string]bool, len(schema.PlotNodes))
+	for _, node := range schema.PlotNodes {
+		nodes[node.ID] = true
+	}
+	arcs := make(map[string]bool, len(schema.Arcs))
+	for _, arc := range schema.Arcs {
+		arcs[arc.ID] = true
+	}
+
+	for id := range schema.InitialStats {
+		if !stats[id] {
+			return fmt.Errorf("initial_stats names unknown stat %s", id)
+		}
+	}
+	for _, id := range schema.InitialTags {
+		if !tags[id] {
+			return fmt.Errorf("initial_tags names unknown tag %s", id)
+		}
+	}
+	for id, count := range schema.InitialItems {
+		if !items[id] {
+			return fmt.Errorf("initial_items names unknown item %s", id)
+		}
+		if count < 0 {
+			return fmt.Errorf("initial_items gives a negative count of %s", id)
+		}
+	}
+	for _, resource := range schema.Resources {
+		if stats[resource.ID] {
+			return fmt.Errorf("resource %s shares its ID with a stat", resource.ID)
+		}
+		if resource.Initial < 0 {
+			return fmt.Errorf("resource %s starts with a negative balance", resource.ID)
+		}
+	}
+	for _, npc := range schema.NPCs {
+		if npc.Affinity < -100 || npc.Affinity > 100 {
+			return fmt.Errorf("NPC %s affinity out of range: %d", npc.ID, npc.Affinity)
+		}
+	}
+	for _, rel := range schema.Relationships {
+		if !entities[rel.From] || !entities[rel.To] {
+			return fmt.Errorf("relationship %s -> %s names an unknown character", rel.From, rel.To)
+		}
+	}
+	for _, season := range schema.Seasons {
+		for id := range season.StatMultipliers {
+			if !stats[id] {
+				return fmt.Errorf("season %s names unknown stat %s", season.ID, id)
+			}
+		}
+	}
+	for _, era := range schema.Eras {
+		for _, id := range append(append([]string(nil), era.RetireNPCs...), era.IntroduceNPCs...) {
+			if !entities[id] {
+				return fmt.Errorf("era %s names unknown NPC %s", era.ID, id)
+			}
+		}
+	}
+	for _, node := range schema.PlotNodes {
+		for _, id := range append(append([]string(nil), node.PredecessorIDs...), node.SuccessorIDs...) {
+			if !nodes[id] {
+				return fmt.Errorf("plot node %s links unknown node %s", node.ID, id)
+			}
+		}
+		if node.ArcID != "" && !arcs[node.ArcID] {
+			return fmt.Errorf("plot node %s names unknown arc %s", node.ID, node.ArcID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package agents
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SamplingDefault is the schedule entry for job types without their own
+const SamplingDefault = "default"
+
+// Sampling is how freely the Writer samples for a job type. Zero leaves a
+// parameter to the one below it: the world's schedule falls back to the
+// server's, and the server's to the client default.
+type Sampling struct {
+	Temperature float64 `json:"temperature,omitempty"` // 0-2
+	TopP        float64 `json:"top_p,omitempty"`       // 0-1
+}
+
+// SamplingSchedule maps Writer job types, or SamplingDefault, to how
+// freely their cards are written
+type SamplingSchedule map[string]Sampling
+
+// DefaultSamplingSchedule keeps plot and death cards close to the story and
+// lets common filler wander
+var DefaultSamplingSchedule = SamplingSchedule{
+	SamplingDefault: {Temperature: 0.7},
+	"plot":          {Temperature: 0.5, TopP: 0.9},
+	"memorial":      {Temperature: 0.5, TopP: 0.9},
+	"obituary":      {Temperature: 0.5, TopP: 0.9},
+	"successor":     {Temperature: 0.5, TopP: 0.9},
+	"departure":     {Temperature: 0.5, TopP: 0.9},
+	"nudge":         {Temperature: 0.5, TopP: 0.9},
+	"info":          {Temperature: 0.9, TopP: 0.95},
+}
+
+var (
+	writerSamplingMu sync.RWMutex
+	writerSampling   = DefaultSamplingSchedule
+)
+
+// SetWriterSampling overrides the server's schedule, entry by entry, on
+// top of DefaultSamplingSchedule
+func SetWriterSampling(schedule SamplingSchedule) {
+	writerSamplingMu.Lock()
+	defer writerSamplingMu.Unlock()
+	writerSampling = DefaultSamplingSchedule.Merge(schedule)
+}
+
+// WriterSampling returns the server's schedule
+func WriterSampling() SamplingSchedule {
+	writerSamplingMu.RLock()
+	defer writerSamplingMu.RUnlock()
+	return writerSampling
+}
+
+// Validate checks every entry is in range
+func (s SamplingSchedule) Validate() error {
+	for jobType, sampling := range s {
+		if jobType == "" {
+			return fmt.Errorf("sampling: empty job type")
+		}
+		if sampling.Temperature < 0 || sampling.Temperature > 2 {
+			return fmt.Errorf("sampling: %s temperature must be between 0 and 2: %v", jobType, sampling.Temperature)
+		}
+		if sampling.TopP < 0 || sampling.TopP > 1 {
+			return fmt.Errorf("sampling: %s top_p must be between 0 and 1: %v", jobType, sampling.TopP)
+		}
+	}
+	return nil
+}
+
+// Merge returns s with the non-zero parameters of overrides on top
+func (s SamplingSchedule) Merge(overrides SamplingSchedule) SamplingSchedule {
+	merged := make(SamplingSchedule, len(s)+len(overrides))
+	for jobType, sampling := range s {
+		merged[jobType] = sampling
+	}
+	for jobType, override := range overrides {
+		sampling := merged[jobType]
+		if override.Temperature != 0 {
+			sampling.Temperature = override.Temperature
+		}
+		if override.TopP != 0 {
+			sampling.TopP = override.TopP
+		}
+		merged[jobType] = sampling
+	}
+	return merged
+}
+
+// For returns the sampling of a job type, with SamplingDefault filling in
+// what it leaves unset
+func (s SamplingSchedule) For(jobType string) Sampling {
+	sampling := s[jobType]
+	if sampling.Temperature == 0 {
+		sampling.Temperature = s[SamplingDefault].Temperature
+	}
+	if sampling.TopP == 0 {
+		sampling.TopP = s[SamplingDefault].TopP
+	}
+	return sampling
+}
+
+// ForJobs returns the sampling of a batch. Its cards are written in one
+// call, so the most constrained job sets each parameter.
+func (s SamplingSchedule) ForJobs(jobs []CardGenJob) Sampling {
+	if len(jobs) == 0 {
+		return s.For(SamplingDefault)
+	}
+	batch := s.For(jobs[0].Type)
+	for _, job := range jobs[1:] {
+		sampling := s.For(job.Type)
+		if sampling.Temperature < batch.Temperature {
+			batch.Temperature = sampling.Temperature
+		}
+		if sampling.TopP != 0 && (batch.TopP == 0 || sampling.TopP < batch.TopP) {
+			batch.TopP = sampling.TopP
+		}
+	}
+	return batch
+}
+
+// ParseSamplingSchedule reads a schedule written as comma-separated
+// job=temperature or job=temperature:top_p entries, e.g.
+// "plot=0.4:0.9,info=1.0"
+func ParseSamplingSchedule(spec string) (SamplingSchedule, error) {
+	schedule := make(SamplingSchedule)
+	for _, field := range strings.Split(spec, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		jobType, value, ok := strings.Cut(field, "=")
+		if !ok || jobType == "" {
+			return nil, fmt.Errorf("entry %q must be job=temperature[:top_p]", field)
+		}
+		temperature, topP, hasTopP := strings.Cut(value, ":")
+		var sampling Sampling
+		var err error
+		if sampling.Temperature, err = strconv.ParseFloat(temperature, 64); err != nil {
+			return nil, fmt.Errorf("invalid temperature in %q", field)
+		}
+		if hasTopP {
+			if sampling.TopP, err = strconv.ParseFloat(topP, 64); err != nil {
+				return nil, fmt.Errorf("invalid top_p in %q", field)
+			}
+		}
+		schedule[jobType] = sampling
+	}
+	return schedule, schedule.Validate()
+}
+
+// String writes the schedule the way ParseSamplingSchedule reads it
+func (s SamplingSchedule) String() string {
+	jobTypes := make([]string, 0, len(s))
+	for jobType := range s {
+		jobTypes = append(jobTypes, jobType)
+	}
+	sort.Strings(jobTypes)
+
+	fields := make([]string, 0, len(jobTypes))
+	for _, jobType := range jobTypes {
+		field := fmt.Sprintf("%s=%g", jobType, s[jobType].Temperature)
+		if s[jobType].TopP != 0 {
+			field += fmt.Sprintf(":%g", s[jobType].TopP)
+		}
+		fields = append(fields, field)
+	}
+	return strings.Join(fields, ",")
+}
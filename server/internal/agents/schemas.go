@@ -11,6 +11,7 @@ type StatDef struct {
 	ID          string `json:"id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	Hidden      bool   `json:"hidden,omitempty"` // visible to conditions and the Writer only, until revealed
 }
 
 // EntityDef is a base entity definition
@@ -30,6 +31,8 @@ type NPCDef struct {
 	EntityDef
 	Description string `json:"description"`
 	Appearance  string `json:"appearance"`
+	Age         int    `json:"age,omitempty"`      // 0 means the NPC does not age
+	Affinity    int    `json:"affinity,omitempty"` // how the NPC feels about the player at the start, -100 to 100
 }
 
 // RelationshipDef defines a relationship between entities
@@ -45,38 +48,158 @@ type TagDef struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	IsTemp      bool   `json:"is_temp"`
+	Knowledge   bool   `json:"knowledge,omitempty"` // survives time-loop resets
 }
 
-// SeasonDef defines a season
-type SeasonDef struct {
+// ItemDef defines a collectible item the player can hold several of
+type ItemDef struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ResourceDef defines an unbounded resource such as gold or supplies,
+// counted apart from the 0-100 stats
+type ResourceDef struct {
 	ID          string `json:"id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	Initial     int    `json:"initial,omitempty"`    // balance each life starts with
+	Bankruptcy  bool   `json:"bankruptcy,omitempty"` // spending more than the balance kills the player
+}
+
+// SeasonDef defines a season
+type SeasonDef struct {
+	ID              string             `json:"id"`
+	Name            string             `json:"name"`
+	Description     string             `json:"description"`
+	StatMultipliers map[string]float64 `json:"stat_multipliers,omitempty"` // scales gains of a stat during the season
 }
 
 // PlotNodeDef defines a story plot node
 type PlotNodeDef struct {
-	ID               string          `json:"id"`
-	PlotDescription  string          `json:"plot_description"`
-	Condition        string          `json:"condition"`
-	Calls            []FunctionCall  `json:"calls"`
-	IsEnding         bool            `json:"is_ending"`
-	PredecessorIDs   []string        `json:"predecessor_ids"`
-	SuccessorIDs     []string        `json:"successor_ids"`
+	ID              string         `json:"id"`
+	PlotDescription string         `json:"plot_description"`
+	Condition       string         `json:"condition"`
+	Calls           []FunctionCall `json:"calls"`
+	IsEnding        bool           `json:"is_ending"`
+	PredecessorIDs  []string       `json:"predecessor_ids"`
+	SuccessorIDs    []string       `json:"successor_ids"`
+	ArcID           string         `json:"arc_id,omitempty"`
+	Deadline        *DeadlineDef   `json:"deadline,omitempty"`
+	ExclusiveGroup  string         `json:"exclusive_group,omitempty"`
+	EndingTier      string         `json:"ending_tier,omitempty"` // "common" | "good" | "secret"
+}
+
+// DeadlineDef declares that a plot node should fire by a given week
+type DeadlineDef struct {
+	Week              int    `json:"week"`
+	Action            string `json:"action"` // "nudge" | "loosen"
+	FallbackCondition string `json:"fallback_condition,omitempty"`
+}
+
+// ArcDef defines a story arc (chapter) that groups plot nodes
+type ArcDef struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Theme string `json:"theme"`
+}
+
+// MacroDef defines a reusable compound function the Writer can call by ID
+type MacroDef struct {
+	ID          string         `json:"id"`
+	Description string         `json:"description"`
+	Calls       []FunctionCall `json:"calls"`
+}
+
+// PressureRuleDef links stats: while Condition holds, Calls run every
+// Interval ("day" or "week", default "week")
+type PressureRuleDef struct {
+	ID          string         `json:"id"`
+	Description string         `json:"description"`
+	Condition   string         `json:"condition"`
+	Calls       []FunctionCall `json:"calls"`
+	Interval    string         `json:"interval,omitempty"`
+}
+
+// EraDef is one age of a multi-era world. Condition moves the world from
+// the previous era into this one (the first era has none).
+type EraDef struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Description   string   `json:"description"`
+	Condition     string   `json:"condition,omitempty"`
+	RetireNPCs    []string `json:"retire_npcs,omitempty"`
+	IntroduceNPCs []string `json:"introduce_npcs,omitempty"`
+}
+
+// MortalityRuleDef gives NPCs aged MinAge or more a Chance (0-1) each
+// in-game year of dying of natural causes
+type MortalityRuleDef struct {
+	MinAge int     `json:"min_age"`
+	Chance float64 `json:"chance"`
+}
+
+// DynastyDef tunes heir resurrection: the heir arrives YearsLater years
+// after the death and keeps Inheritance (0-1) of each stat's distance from 50
+type DynastyDef struct {
+	YearsLater  int     `json:"years_later"`
+	Inheritance float64 `json:"inheritance"`
+}
+
+// DifficultyDef bounds the difficulty balancer. Level 1 plays as designed;
+// Min (0.25-1) and Max (1-2) limit how far it may move.
+type DifficultyDef struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// DeckDef shapes a world's week decks. Size defaults to a card per day of
+// the week; CommonShare (0-1) is the least share of the deck kept for
+// common cards however many Writer jobs are queued; one common card in
+// InfoEvery is an info card, and 0 leaves that to the Writer.
+type DeckDef struct {
+	Size        int     `json:"size,omitempty"`
+	CommonShare float64 `json:"common_share,omitempty"`
+	InfoEvery   int     `json:"info_every,omitempty"`
+}
+
+// CalendarDef shapes a world's calendar. DaysPerWeek defaults to 7,
+// DaysPerSeason (a whole number of weeks) to 4 weeks, and SeasonsPerYear to
+// the number of Seasons, or 4 without any.
+type CalendarDef struct {
+	DaysPerWeek    int `json:"days_per_week,omitempty"`
+	DaysPerSeason  int `json:"days_per_season,omitempty"`
+	SeasonsPerYear int `json:"seasons_per_year,omitempty"`
 }
 
 // WorldGenSchema is the complete world generation output
 type WorldGenSchema struct {
-	Name          string                 `json:"name"`
-	Era           string                 `json:"era"`
-	Description   string                 `json:"description"`
-	Stats         []StatDef              `json:"stats"`
-	Tags          []TagDef               `json:"tags"`
-	Seasons       []SeasonDef            `json:"seasons"`
-	PlayerChar    PlayerCharacterDef     `json:"player_character"`
-	NPCs          []NPCDef               `json:"npcs"`
-	Relationships []RelationshipDef      `json:"relationships"`
-	PlotNodes     []PlotNodeDef          `json:"plot_nodes"`
-	InitialStats  map[string]int         `json:"initial_stats"`
-	InitialTags   []string               `json:"initial_tags"`
+	Name                 string             `json:"name"`
+	Era                  string             `json:"era"`
+	Description          string             `json:"description"`
+	Stats                []StatDef          `json:"stats"`
+	Tags                 []TagDef           `json:"tags"`
+	Items                []ItemDef          `json:"items,omitempty"`
+	Resources            []ResourceDef      `json:"resources,omitempty"`
+	Seasons              []SeasonDef        `json:"seasons"`
+	PlayerChar           PlayerCharacterDef `json:"player_character"`
+	NPCs                 []NPCDef           `json:"npcs"`
+	Relationships        []RelationshipDef  `json:"relationships"`
+	PlotNodes            []PlotNodeDef      `json:"plot_nodes"`
+	Arcs                 []ArcDef           `json:"arcs,omitempty"`
+	Macros               []MacroDef         `json:"macros,omitempty"`
+	PressureRules        []PressureRuleDef  `json:"pressure_rules,omitempty"`
+	Eras                 []EraDef           `json:"eras,omitempty"`
+	MortalityRules       []MortalityRuleDef `json:"mortality_rules,omitempty"`
+	ResurrectionMechanic string             `json:"resurrection_mechanic,omitempty"`
+	ResurrectionFlavor   string             `json:"resurrection_flavor,omitempty"`
+	Dynasty              *DynastyDef        `json:"dynasty,omitempty"`
+	Difficulty           *DifficultyDef     `json:"difficulty,omitempty"`
+	Deck                 *DeckDef           `json:"deck,omitempty"`
+	Calendar             *CalendarDef       `json:"calendar,omitempty"`
+	Sampling             SamplingSchedule   `json:"sampling,omitempty"` // overrides the server's Writer sampling by job type
+	InitialStats         map[string]int     `json:"initial_stats"`
+	InitialTags          []string           `json:"initial_tags"`
+	InitialItems         map[string]int     `json:"initial_items,omitempty"` // item counts the player starts with
 }
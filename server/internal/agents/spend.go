@@ -0,0 +1,337 @@
+package agents
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrGenerationPaused is returned for LLM calls while the kill switch is on
+var ErrGenerationPaused = errors.New("generation is paused")
+
+// UsageRecord is the token usage and cost of one LLM call
+type UsageRecord struct {
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	CostUSD          float64   `json:"cost_usd"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// UsageStore persists usage records and sums spend over them
+type UsageStore interface {
+	RecordUsage(rec UsageRecord) error
+	SpendSince(since time.Time) (float64, error)
+}
+
+// SpendAlert describes a crossed spend threshold
+type SpendAlert struct {
+	Day       string  `json:"day"` // UTC date, YYYY-MM-DD
+	Threshold float64 `json:"threshold_usd"`
+	Spent     float64 `json:"spent_usd"`
+	HardLimit bool    `json:"hard_limit"` // true when generation was paused
+}
+
+// Alerter delivers spend alerts
+type Alerter interface {
+	Alert(alert SpendAlert) error
+}
+
+// LogAlerter writes alerts to the standard logger
+type LogAlerter struct{}
+
+// Alert implements Alerter
+func (LogAlerter) Alert(alert SpendAlert) error {
+	log.Printf("SPEND ALERT: $%.2f spent on %s (threshold $%.2f, paused: %v)", alert.Spent, alert.Day, alert.Threshold, alert.HardLimit)
+	return nil
+}
+
+// WebhookAlerter posts alerts as JSON to a URL
+type WebhookAlerter struct {
+	URL    string
+	Client *http.Client
+}
+
+// Alert implements Alerter
+func (a *WebhookAlerter) Alert(alert SpendAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	client := a.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Post(a.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// EmailAlerter sends alerts through an SMTP server
+type EmailAlerter struct {
+	Addr string // host:port
+	From string
+	To   []string
+	Auth smtp.Auth
+}
+
+// Alert implements Alerter
+func (a *EmailAlerter) Alert(alert SpendAlert) error {
+	subject := fmt.Sprintf("World Card AI spend alert: $%.2f on %s", alert.Spent, alert.Day)
+	body := fmt.Sprintf("Daily LLM spend crossed $%.2f and is now $%.2f.\r\nGeneration paused: %v\r\n", alert.Threshold, alert.Spent, alert.HardLimit)
+	msg := "From: " + a.From + "\r\n" +
+		"To: " + strings.Join(a.To, ", ") + "\r\n" +
+		"Subject: " + subject + "\r\n\r\n" + body
+	return smtp.SendMail(a.Addr, a.Auth, a.From, a.To, []byte(msg))
+}
+
+// SpendStatus is a snapshot of the monitor for admin views
+type SpendStatus struct {
+	Day        string    `json:"day"`
+	SpentUSD   float64   `json:"spent_usd"`
+	Thresholds []float64 `json:"thresholds_usd"`
+	HardLimit  float64   `json:"hard_limit_usd"`
+	Paused     bool      `json:"paused"`
+	PausedBy   string    `json:"paused_by,omitempty"` // "manual" or "hard_limit"
+}
+
+// SpendMonitor prices every LLM call, tracks daily spend, fires alerts when
+// thresholds are crossed and holds the service-wide generation kill switch
+type SpendMonitor struct {
+	pricing    PricingTable
+	store      UsageStore
+	thresholds []float64 // ascending
+	hardLimit  float64   // 0 disables the automatic kill switch
+	alerters   []Alerter
+
+	mu       sync.Mutex
+	day      string
+	spent    float64
+	fired    map[float64]bool
+	paused   bool
+	pausedBy string
+	now      func() time.Time
+}
+
+// NewSpendMonitor creates a monitor. store may be nil to keep spend in memory.
+func NewSpendMonitor(pricing PricingTable, store UsageStore, thresholds []float64, hardLimit float64, alerters ...Alerter) *SpendMonitor {
+	sorted := append([]float64(nil), thresholds...)
+	sort.Float64s(sorted)
+	if len(alerters) == 0 {
+		alerters = []Alerter{LogAlerter{}}
+	}
+	return &SpendMonitor{
+		pricing:    pricing,
+		store:      store,
+		thresholds: sorted,
+		hardLimit:  hardLimit,
+		alerters:   alerters,
+		fired:      make(map[float64]bool),
+		now:        time.Now,
+	}
+}
+
+// NewSpendMonitorFromEnv builds a monitor from PRICING_FILE,
+// SPEND_ALERT_THRESHOLDS (comma-separated USD), SPEND_HARD_LIMIT,
+// SPEND_ALERT_WEBHOOK and SPEND_ALERT_EMAIL (with SMTP_ADDR and SMTP_FROM)
+func NewSpendMonitorFromEnv(store UsageStore) (*SpendMonitor, error) {
+	pricing, err := LoadPricingTable(os.Getenv("PRICING_FILE"))
+	if err != nil {
+		return nil, err
+	}
+
+	var thresholds []float64
+	for _, field := range strings.Split(os.Getenv("SPEND_ALERT_THRESHOLDS"), ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid spend threshold %q", field)
+		}
+		thresholds = append(thresholds, v)
+	}
+
+	var hardLimit float64
+	if v := os.Getenv("SPEND_HARD_LIMIT"); v != "" {
+		if hardLimit, err = strconv.ParseFloat(v, 64); err != nil {
+			return nil, fmt.Errorf("invalid SPEND_HARD_LIMIT %q", v)
+		}
+	}
+
+	alerters := []Alerter{LogAlerter{}}
+	if url := os.Getenv("SPEND_ALERT_WEBHOOK"); url != "" {
+		alerters = append(alerters, &WebhookAlerter{URL: url})
+	}
+	if to := os.Getenv("SPEND_ALERT_EMAIL"); to != "" {
+		alerters = append(alerters, &EmailAlerter{
+			Addr: os.Getenv("SMTP_ADDR"),
+			From: os.Getenv("SMTP_FROM"),
+			To:   strings.Split(to, ","),
+		})
+	}
+
+	return NewSpendMonitor(pricing, store, thresholds, hardLimit, alerters...), nil
+}
+
+// rollover resets the daily counters when the UTC day changes. Spend is
+// reloaded from the store so restarts keep counting. Caller must hold m.mu.
+func (m *SpendMonitor) rollover() {
+	now := m.now().UTC()
+	day := now.Format("2006-01-02")
+	if day == m.day {
+		return
+	}
+
+	m.day = day
+	m.spent = 0
+	m.fired = make(map[float64]bool)
+	if m.pausedBy == "hard_limit" {
+		m.paused = false
+		m.pausedBy = ""
+	}
+	if m.store != nil {
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		if spent, err := m.store.SpendSince(start); err == nil {
+			m.spent = spent
+		} else {
+			log.Printf("Failed to load today's spend: %v", err)
+		}
+	}
+	m.checkThresholds()
+}
+
+// Allow returns ErrGenerationPaused while the kill switch is on
+func (m *SpendMonitor) Allow() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rollover()
+	if m.paused {
+		return ErrGenerationPaused
+	}
+	return nil
+}
+
+// Record prices a call, stores its usage and fires any crossed alerts
+func (m *SpendMonitor) Record(model string, promptTokens, completionTokens int) UsageRecord {
+	cost, known := m.pricing.Cost(model, promptTokens, completionTokens)
+	if !known {
+		log.Printf("No price for model %s; recording zero cost", model)
+	}
+	rec := UsageRecord{
+		Model:            model,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		CostUSD:          cost,
+		CreatedAt:        m.now().UTC(),
+	}
+
+	if m.store != nil {
+		if err := m.store.RecordUsage(rec); err != nil {
+			log.Printf("Failed to record LLM usage: %v", err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollover()
+	m.spent += cost
+	m.checkThresholds()
+	return rec
+}
+
+// checkThresholds fires each crossed threshold once per day and trips the
+// kill switch at the hard limit. Caller must hold m.mu.
+func (m *SpendMonitor) checkThresholds() {
+	for _, threshold := range m.thresholds {
+		if m.spent >= threshold && !m.fired[threshold] {
+			m.fired[threshold] = true
+			m.send(SpendAlert{Day: m.day, Threshold: threshold, Spent: m.spent})
+		}
+	}
+
+	if m.hardLimit > 0 && m.spent >= m.hardLimit && !m.paused {
+		m.paused = true
+		m.pausedBy = "hard_limit"
+		m.send(SpendAlert{Day: m.day, Threshold: m.hardLimit, Spent: m.spent, HardLimit: true})
+	}
+}
+
+// send delivers an alert to every alerter without blocking the caller
+func (m *SpendMonitor) send(alert SpendAlert) {
+	for _, alerter := range m.alerters {
+		go func(a Alerter) {
+			if err := a.Alert(alert); err != nil {
+				log.Printf("Failed to deliver spend alert: %v", err)
+			}
+		}(alerter)
+	}
+}
+
+// Pause turns the kill switch on
+func (m *SpendMonitor) Pause() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.paused = true
+	m.pausedBy = "manual"
+}
+
+// Resume turns the kill switch off until the hard limit trips it again
+func (m *SpendMonitor) Resume() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.paused = false
+	m.pausedBy = ""
+}
+
+// Status returns today's spend and the kill switch state
+func (m *SpendMonitor) Status() SpendStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rollover()
+	return SpendStatus{
+		Day:        m.day,
+		SpentUSD:   m.spent,
+		Thresholds: append([]float64(nil), m.thresholds...),
+		HardLimit:  m.hardLimit,
+		Paused:     m.paused,
+		PausedBy:   m.pausedBy,
+	}
+}
+
+var (
+	spendMonitorMu sync.RWMutex
+	spendMonitor   *SpendMonitor
+)
+
+// SetSpendMonitor installs the service-wide monitor used by every client
+func SetSpendMonitor(m *SpendMonitor) {
+	spendMonitorMu.Lock()
+	defer spendMonitorMu.Unlock()
+	spendMonitor = m
+}
+
+// GetSpendMonitor returns the service-wide monitor, or nil if none is set
+func GetSpendMonitor() *SpendMonitor {
+	spendMonitorMu.RLock()
+	defer spendMonitorMu.RUnlock()
+	return spendMonitor
+}
@@ -0,0 +1,133 @@
+package agents
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+)
+
+// Agents whose responses are validated
+const (
+	AgentArchitect = "architect"
+	AgentWriter    = "writer"
+	AgentEditor    = "editor"
+)
+
+// Failure classes for LLM responses that could not be used
+const (
+	FailureEmptyResponse         = "empty_response"         // no choices in the completion
+	FailureInvalidJSON           = "invalid_json"           // the content did not parse
+	FailureSchema                = "schema"                 // parsed, but missing or mistyped fields
+	FailureTagMapped             = "tag_mapped"             // unknown tag mapped to the nearest known tag
+	FailureTagRejected           = "tag_rejected"           // unknown tag with no close match; card dropped
+	FailureContradictionRepaired = "contradiction_repaired" // card contradicted the story; the Editor fixed it
+	FailureContradictionRejected = "contradiction_rejected" // card contradicted the story; card dropped
+)
+
+// maxFailureDetail caps the error text stored with a failure
+const maxFailureDetail = 500
+
+// ValidationFailure is one LLM response that failed to parse or validate
+type ValidationFailure struct {
+	Agent         string    `json:"agent"`
+	Class         string    `json:"class"`
+	Model         string    `json:"model"`
+	PromptVariant string    `json:"prompt_variant"` // hash of the system prompt
+	OrgID         string    `json:"org_id,omitempty"`
+	Detail        string    `json:"detail"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ValidationFailureCount aggregates failures sharing an agent, class, model
+// and prompt variant
+type ValidationFailureCount struct {
+	Agent         string    `json:"agent"`
+	Class         string    `json:"class"`
+	Model         string    `json:"model"`
+	PromptVariant string    `json:"prompt_variant"`
+	Count         int       `json:"count"`
+	LastSeen      time.Time `json:"last_seen"`
+}
+
+// ValidationStore persists validation failures
+type ValidationStore interface {
+	RecordValidationFailure(f ValidationFailure) error
+}
+
+var (
+	validationStoreMu sync.RWMutex
+	validationStore   ValidationStore
+)
+
+// SetValidationStore installs the service-wide store for validation
+// failures. Failures are only logged while none is set.
+func SetValidationStore(store ValidationStore) {
+	validationStoreMu.Lock()
+	defer validationStoreMu.Unlock()
+	validationStore = store
+}
+
+// PromptVariant identifies the prompt a response was generated from, so a
+// regression can be traced to a template change
+func PromptVariant(systemPrompt string) string {
+	sum := sha256.Sum256([]byte(systemPrompt))
+	return hex.EncodeToString(sum[:6])
+}
+
+// orgContextKey carries the organization an LLM call is made for
+type orgContextKey struct{}
+
+// WithOrg attributes the LLM calls made with ctx to an organization
+func WithOrg(ctx context.Context, orgID string) context.Context {
+	if orgID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, orgContextKey{}, orgID)
+}
+
+// recordValidationFailure logs a failed response and stores it when a
+// store is installed
+func recordValidationFailure(ctx context.Context, agent, class string, req *CompletionRequest, cause error) {
+	f := ValidationFailure{
+		Agent:     agent,
+		Class:     class,
+		Model:     req.Model,
+		Detail:    cause.Error(),
+		CreatedAt: time.Now().UTC(),
+	}
+	if len(req.Messages) > 0 && req.Messages[0].Role == "system" {
+		f.PromptVariant = PromptVariant(req.Messages[0].Content)
+	}
+	if orgID, ok := ctx.Value(orgContextKey{}).(string); ok {
+		f.OrgID = orgID
+	}
+	RecordValidationFailure(f)
+}
+
+// RecordValidationFailure logs a failure found after a response was
+// parsed, such as by the game engine, and stores it when a store is
+// installed
+func RecordValidationFailure(f ValidationFailure) {
+	if f.CreatedAt.IsZero() {
+		f.CreatedAt = time.Now().UTC()
+	}
+	if len(f.Detail) > maxFailureDetail {
+		f.Detail = f.Detail[:maxFailureDetail]
+	}
+
+	log.Printf("LLM %s response failed validation (%s, model %s, prompt %s): %s",
+		f.Agent, f.Class, f.Model, f.PromptVariant, f.Detail)
+
+	validationStoreMu.RLock()
+	store := validationStore
+	validationStoreMu.RUnlock()
+	if store == nil {
+		return
+	}
+	if err := store.RecordValidationFailure(f); err != nil {
+		log.Printf("Failed to record validation failure: %v", err)
+	}
+}
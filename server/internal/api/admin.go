@@ -0,0 +1,298 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/qninhdt/world-card-ai-2/server/internal/db"
+	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+	"github.com/qninhdt/world-card-ai-2/server/internal/validation"
+)
+
+// adminSaveGame writes a snapshot of an in-memory game regardless of owner
+func (s *Server) adminSaveGame(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	engine, ok := s.games.Get(gameID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Game not loaded")
+		return
+	}
+
+	state, dag, err := engine.SaveCopy()
+	if err == nil {
+		err = s.db.SaveGame(gameID, state, dag)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to save game")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    "Game saved",
+	})
+}
+
+// adminUnloadGame saves a game and drops it from memory; the next request
+// for it restores the saved snapshot
+func (s *Server) adminUnloadGame(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	// The eviction hook saves the game first
+	loaded, err := s.games.Evict(gameID)
+	if !loaded {
+		writeError(w, http.StatusNotFound, "Game not loaded")
+		return
+	}
+	if errors.Is(err, ErrGameInUse) {
+		writeError(w, http.StatusConflict, "Game is in use, retry shortly")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to save game")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    "Game unloaded",
+	})
+}
+
+// adminListLoadedGames reports the in-memory games and registry metrics
+func (s *Server) adminListLoadedGames(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"stats": s.games.Stats(),
+			"games": s.games.Loaded(),
+		},
+	})
+}
+
+// adminRecompileDAG recompiles all plot conditions of a game
+func (s *Server) adminRecompileDAG(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	engine, ok := s.games.GetOrLoad(gameID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	dag := engine.GetDAG()
+	failures := make(map[string]string)
+	for nodeID, err := range dag.Recompile() {
+		failures[nodeID] = err.Error()
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"nodes":    len(dag.GetAllNodes()),
+			"failures": failures,
+		},
+	})
+}
+
+// adminRequeueJobs moves a game's failed generation jobs back into its queue
+func (s *Server) adminRequeueJobs(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	engine, ok := s.games.GetOrLoad(gameID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	failed, err := s.db.GetFailedJobs(gameID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load failed jobs")
+		return
+	}
+
+	jobs := make([]*game.CardGenJob, 0, len(failed))
+	ids := make([]int64, 0, len(failed))
+	for _, f := range failed {
+		jobs = append(jobs, f.Job)
+		ids = append(ids, f.ID)
+	}
+
+	if err := s.db.DeleteFailedJobs(ids); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to clear failed jobs")
+		return
+	}
+	engine.RequeueJobs(jobs)
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"requeued": len(jobs),
+			"pending":  engine.PendingJobCount(),
+		},
+	})
+}
+
+// dumpUsageLimit is how many recent LLM calls a debug dump includes
+const dumpUsageLimit = 50
+
+// adminPatchState applies a JSON merge patch to a game's whitelisted state
+// fields, so designers can tweak a live game without editing the database
+func (s *Server) adminPatchState(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	engine, ok := s.games.GetOrLoad(gameID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	before := s.watchChange(gameID, engine)
+	fields, err := engine.PatchState(patch)
+	var patchErr *game.PatchError
+	if errors.As(err, &patchErr) {
+		writeError(w, http.StatusUnprocessableEntity, "Invalid patch: "+patchErr.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to patch state")
+		return
+	}
+	s.autosave(r, gameID, engine)
+	s.publishChange(gameID, engine, before)
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"patched": fields,
+			"version": engine.GetVersion(),
+			"state":   engine.GetState(),
+		},
+	})
+}
+
+// adminDumpGame returns a diagnostic bundle of a game for bug reports: the
+// engine's full internals, saved snapshots, failed generation jobs and recent
+// LLM calls. ?format=zip wraps it as dump.json in a zip archive.
+func (s *Server) adminDumpGame(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	engine, ok := s.games.GetOrLoad(gameID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	internals, err := engine.Dump()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to dump game")
+		return
+	}
+	snapshots, _, err := s.db.ListSnapshots(gameID, db.Page{})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load snapshots")
+		return
+	}
+	failedJobs, err := s.db.GetFailedJobs(gameID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load failed jobs")
+		return
+	}
+	usage, err := s.db.RecentUsage(dumpUsageLimit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load LLM usage")
+		return
+	}
+
+	bundle, err := json.MarshalIndent(map[string]interface{}{
+		"game_id":      gameID,
+		"generated_at": time.Now().UTC(),
+		"engine":       json.RawMessage(internals),
+		"snapshots":    snapshots,
+		"failed_jobs":  failedJobs,
+		"llm_usage":    usage, // service-wide; calls are not tagged with a game
+	}, "", "  ")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to dump game")
+		return
+	}
+
+	name := "game-" + gameID + "-dump"
+	if r.URL.Query().Get("format") != "zip" {
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.json"`)
+		w.WriteHeader(http.StatusOK)
+		w.Write(bundle)
+		return
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.Create("dump.json")
+	if err == nil {
+		_, err = f.Write(bundle)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to dump game")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.zip"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// adminExportTelemetry downloads an anonymized gameplay trace of every
+// saved game as JSON lines
+func (s *Server) adminExportTelemetry(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if _, err := db.ExportTelemetry(s.db, &buf, ""); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to export telemetry")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="telemetry.jsonl"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
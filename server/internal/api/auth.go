@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/qninhdt/world-card-ai-2/server/internal/db"
+	mw "github.com/qninhdt/world-card-ai-2/server/internal/middleware"
+	"github.com/qninhdt/world-card-ai-2/server/internal/validation"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AuthToken is the response of signup, login and refresh. Send the token as
+// "Authorization: Bearer <token>"; trade the refresh token for a new pair
+// before it expires.
+type AuthToken struct {
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	SessionID        string    `json:"session_id"`
+	User             *db.User  `json:"user"`
+}
+
+// dummyPasswordHash is compared against on logins for unknown usernames, so
+// they take as long as a wrong password and do not reveal which names exist
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// signup registers an account and logs it in
+func (s *Server) signup(w http.ResponseWriter, r *http.Request) {
+	var req SignupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validation.ValidateUsername(req.Username); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validation.ValidatePassword(req.Password); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create account")
+		return
+	}
+
+	user, err := s.db.CreateUser(req.Username, string(hash))
+	if errors.Is(err, db.ErrUsernameTaken) {
+		writeError(w, http.StatusConflict, "Username already taken")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create account")
+		return
+	}
+
+	s.writeAuthToken(w, r, http.StatusCreated, user)
+}
+
+// login exchanges a username and password for a token
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, hash, err := s.db.GetUserByUsername(req.Username)
+	if err != nil {
+		// SECURITY FIX: Unknown usernames cost a bcrypt comparison too and
+		// get the same error as a wrong password
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(req.Password))
+		writeError(w, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(req.Password)) != nil {
+		writeError(w, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+
+	s.writeAuthToken(w, r, http.StatusOK, user)
+}
+
+// writeAuthToken starts a session for user and answers with its tokens
+func (s *Server) writeAuthToken(w http.ResponseWriter, r *http.Request, status int, user *db.User) {
+	auth, err := s.startSession(r, user)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to issue token")
+		return
+	}
+
+	writeJSON(w, status, Response{
+		Success: true,
+		Data:    auth,
+	})
+}
+
+// usesPersonalKey reports whether a request was made with a personal API key
+func usesPersonalKey(r *http.Request) bool {
+	caller := mw.CallerFrom(r.Context())
+	return caller != nil && caller.APIKey
+}
+
+// requireSessionToken rejects API key requests, so a leaked key cannot mint
+// or revoke keys
+func requireSessionToken(w http.ResponseWriter, r *http.Request) bool {
+	if getKeyOrgID(r) != "" || usesPersonalKey(r) {
+		writeError(w, http.StatusForbidden, "API keys are managed with a session token")
+		return false
+	}
+	return true
+}
+
+// listUserAPIKeys lists the caller's personal API keys
+func (s *Server) listUserAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !requireSessionToken(w, r) {
+		return
+	}
+
+	page, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	keys, next, err := s.db.ListUserAPIKeys(getUserID(r), page)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list API keys")
+		return
+	}
+
+	writePage(w, keys, next)
+}
+
+// createUserAPIKey issues a personal API key; the secret is only returned
+// here
+func (s *Server) createUserAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !requireSessionToken(w, r) {
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validation.ValidateName(req.Name); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	key, info, err := s.db.CreateUserAPIKey(getUserID(r), req.Name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"key":     key,
+			"api_key": info,
+		},
+	})
+}
+
+// revokeUserAPIKey disables one of the caller's personal API keys
+func (s *Server) revokeUserAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !requireSessionToken(w, r) {
+		return
+	}
+
+	keyID := chi.URLParam(r, "key")
+	if err := validation.ValidateOrgID(keyID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	if err := s.db.RevokeUserAPIKey(getUserID(r), keyID); err != nil {
+		writeError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    "API key revoked",
+	})
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/qninhdt/world-card-ai-2/server/internal/cluster"
+)
+
+// forwardedHeader marks a request another instance already forwarded, so
+// instances with different views of the ring cannot bounce it forever. It
+// names the sending instance, and clusterSignatureHeader proves it.
+const forwardedHeader = "X-Cluster-Forwarded"
+
+// clusterSignatureHeader carries "<unix time>:<hex HMAC-SHA256>" of a
+// forwarded request, keyed with the cluster secret
+const clusterSignatureHeader = "X-Cluster-Signature"
+
+// forwardMaxAge is how old a forwarded request's signature may be, allowing
+// for clock skew between instances
+const forwardMaxAge = time.Minute
+
+// EnableClustering makes the server forward requests for games another
+// instance owns to that instance. Forwarded requests are signed with the
+// secret, which every instance must share.
+func (s *Server) EnableClustering(ring *cluster.Ring, secret string) error {
+	if secret == "" {
+		return errors.New("a cluster secret is required")
+	}
+	proxies := make(map[string]*httputil.ReverseProxy)
+	for _, node := range ring.Nodes() {
+		if node == ring.Self() {
+			continue
+		}
+		target, err := url.Parse(node)
+		if err != nil {
+			return err
+		}
+		proxy := httputil.NewSingleHostReverseProxy(target)
+		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			writeError(w, http.StatusBadGateway, "Game owner unavailable")
+		}
+		proxies[node] = proxy
+	}
+
+	s.cluster = ring
+	s.clusterSecret = []byte(secret)
+	s.proxies = proxies
+	return nil
+}
+
+// signForward computes the signature of a request forwarded by sender at ts
+func (s *Server) signForward(sender, method, uri string, ts int64) string {
+	mac := hmac.New(sha256.New, s.clusterSecret)
+	mac.Write([]byte(sender + "\n" + method + "\n" + uri + "\n" + strconv.FormatInt(ts, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// fromPeer reports whether a request was forwarded by another instance of
+// the ring: it names a member and carries a fresh, valid signature
+func (s *Server) fromPeer(r *http.Request) bool {
+	sender := r.Header.Get(forwardedHeader)
+	if s.cluster == nil || sender == "" || sender == s.cluster.Self() {
+		return false
+	}
+	member := false
+	for _, node := range s.cluster.Nodes() {
+		member = member || node == sender
+	}
+	stamp, signature, ok := strings.Cut(r.Header.Get(clusterSignatureHeader), ":")
+	if !member || !ok {
+		return false
+	}
+	ts, err := strconv.ParseInt(stamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := time.Since(time.Unix(ts, 0)); age > forwardMaxAge || age < -forwardMaxAge {
+		return false
+	}
+	want := s.signForward(sender, r.Method, r.URL.RequestURI(), ts)
+	return hmac.Equal([]byte(signature), []byte(want))
+}
+
+// gameIDFromPath returns the game ID of /api/{version}/games/{id}/... and
+// /api/{version}/admin/games/{id}/... paths
+func gameIDFromPath(path string) string {
+	route := stripVersion(path)
+	if route == path {
+		return ""
+	}
+	rest, ok := strings.CutPrefix(route, "/games/")
+	if !ok {
+		if rest, ok = strings.CutPrefix(route, "/admin/games/"); !ok {
+			return ""
+		}
+	}
+	id, _, _ := strings.Cut(rest, "/")
+	return id
+}
+
+// forwardIfRemote proxies a request to the instance owning the game and
+// reports whether it did
+func (s *Server) forwardIfRemote(w http.ResponseWriter, r *http.Request, gameID string) bool {
+	if s.cluster == nil || gameID == "" || r.Header.Get(forwardedHeader) != "" {
+		return false
+	}
+	owner := s.cluster.Owner(gameID)
+	proxy, ok := s.proxies[owner]
+	if !ok {
+		return false
+	}
+
+	ts := time.Now().Unix()
+	r.Header.Set(forwardedHeader, s.cluster.Self())
+	r.Header.Set(clusterSignatureHeader, strconv.FormatInt(ts, 10)+":"+s.signForward(s.cluster.Self(), r.Method, r.URL.RequestURI(), ts))
+	proxy.ServeHTTP(w, r)
+	return true
+}
+
+// clusterMiddleware forwards game requests to the instance that owns the
+// game, so each engine lives in exactly one instance's memory. Forwarding
+// headers a peer did not sign are dropped, so a client cannot make an
+// instance serve a game it does not own.
+func (s *Server) clusterMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.fromPeer(r) {
+			r.Header.Del(forwardedHeader)
+			r.Header.Del(clusterSignatureHeader)
+		}
+		if s.forwardIfRemote(w, r, gameIDFromPath(r.URL.Path)) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newGameID returns a game ID this instance owns, so a new game starts on
+// the instance that created it
+func (s *Server) newGameID() string {
+	for {
+		gameID := uuid.New().String()
+		if s.cluster == nil || s.cluster.IsLocal(gameID) {
+			return gameID
+		}
+	}
+}
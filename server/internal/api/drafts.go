@@ -0,0 +1,431 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+	"github.com/qninhdt/world-card-ai-2/server/internal/validation"
+)
+
+const (
+	draftRetention = 24 * time.Hour // drafts untouched this long are dropped
+	maxDraftTurns  = 50             // refinements a draft may take
+)
+
+// Draft statuses. A draft is ready once it passes the full validation
+// suite; any refinement sends it back for review.
+const (
+	DraftStatusDraft = "draft"
+	DraftStatusReady = "ready"
+)
+
+var (
+	// ErrDraftNotFound is returned for unknown or expired draft IDs
+	ErrDraftNotFound = errors.New("draft not found")
+	// ErrDraftBusy is returned while a draft is being refined or started
+	ErrDraftBusy = errors.New("draft is busy")
+	// ErrDraftFull is returned when a draft has taken maxDraftTurns
+	// refinements
+	ErrDraftFull = errors.New("draft has reached its refinement limit")
+	// ErrDraftInvalid is returned when finalizing a draft that fails
+	// validation
+	ErrDraftInvalid = errors.New("draft does not pass validation")
+	// ErrDraftNotReady is returned when starting the game of a draft that
+	// was not finalized
+	ErrDraftNotReady = errors.New("draft is not ready, finalize it first")
+)
+
+// WorldRefiner applies a refinement message to a world draft, given the
+// draft's conversation so far
+type WorldRefiner func(ctx context.Context, draft *agents.WorldGenSchema, history []agents.RefinementTurn, message, section string) (*agents.Refinement, error)
+
+// architectRefiner refines drafts with the Architect agent
+func architectRefiner(ctx context.Context, draft *agents.WorldGenSchema, history []agents.RefinementTurn, message, section string) (*agents.Refinement, error) {
+	return agents.NewArchitectAgent().RefineWorld(ctx, draft, history, message, section)
+}
+
+// WorldDraft is a generated world under review, with its refinement
+// conversation
+type WorldDraft struct {
+	ID        string                  `json:"id"`
+	UserID    string                  `json:"-"`
+	OrgID     string                  `json:"org_id,omitempty"` // the game lands in this organization
+	Prompt    string                  `json:"prompt"`
+	Status    string                  `json:"status"` // "draft" | "ready"
+	Schema    *agents.WorldGenSchema  `json:"schema"`
+	Review    *game.WorldReview       `json:"review"` // of the current schema
+	Sections  []agents.SectionView    `json:"sections"`
+	Turns     []agents.RefinementTurn `json:"turns"` // oldest first
+	CreatedAt time.Time               `json:"created_at"`
+	UpdatedAt time.Time               `json:"updated_at"`
+
+	busy bool // a refinement or game start is in progress
+}
+
+// DraftStore keeps world drafts in memory while their owners refine them
+type DraftStore struct {
+	mu     sync.Mutex
+	drafts map[string]*WorldDraft
+	refine WorldRefiner
+}
+
+// NewDraftStore creates a draft store refining drafts with refine
+func NewDraftStore(refine WorldRefiner) *DraftStore {
+	return &DraftStore{drafts: make(map[string]*WorldDraft), refine: refine}
+}
+
+// Create keeps a generated world as a new draft and returns its ID
+func (d *DraftStore) Create(userID, orgID, prompt string, schema *agents.WorldGenSchema) (string, error) {
+	if schema == nil {
+		return "", errors.New("architect returned no world")
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.prune()
+
+	now := time.Now()
+	draft := &WorldDraft{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		OrgID:     orgID,
+		Prompt:    prompt,
+		Status:    DraftStatusDraft,
+		Schema:    schema,
+		Review:    game.ReviewWorld(schema),
+		Turns:     make([]agents.RefinementTurn, 0),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	d.drafts[draft.ID] = draft
+	return draft.ID, nil
+}
+
+// Get returns a draft
+func (d *DraftStore) Get(draftID string) (WorldDraft, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.prune()
+
+	draft, ok := d.drafts[draftID]
+	if !ok {
+		return WorldDraft{}, false
+	}
+	return d.view(draft), true
+}
+
+// Refine applies a refinement message to a draft and records the turn. The
+// draft is locked against other refinements while the refiner runs; a
+// refinement that fails leaves the draft as it was.
+func (d *DraftStore) Refine(ctx context.Context, draftID, message, section string) (WorldDraft, error) {
+	draft, err := d.claim(draftID)
+	if err != nil {
+		return WorldDraft{}, err
+	}
+	if len(draft.Turns) >= maxDraftTurns {
+		d.release(draft)
+		return WorldDraft{}, ErrDraftFull
+	}
+	history := append([]agents.RefinementTurn(nil), draft.Turns...)
+
+	refined, err := d.refine(ctx, draft.Schema, history, message, section)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	draft.busy = false
+	if err != nil {
+		return WorldDraft{}, err
+	}
+	draft.Schema = refined.Schema
+	draft.Status = DraftStatusDraft
+	draft.Review = game.ReviewWorld(refined.Schema)
+	draft.UpdatedAt = time.Now()
+	draft.Turns = append(draft.Turns, agents.RefinementTurn{
+		Message: message,
+		Section: section,
+		Reply:   refined.Reply,
+		Changed: refined.Changed,
+		At:      draft.UpdatedAt,
+	})
+	return d.view(draft), nil
+}
+
+// Finalize runs the full validation suite on a draft and marks it ready
+// when it passes. A draft that fails stays a draft, with the failures in
+// its review.
+func (d *DraftStore) Finalize(draftID string) (WorldDraft, error) {
+	draft, err := d.claim(draftID)
+	if err != nil {
+		return WorldDraft{}, err
+	}
+	review := game.ReviewWorld(draft.Schema)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	draft.busy = false
+	draft.Review = review
+	draft.UpdatedAt = time.Now()
+	if !review.Valid {
+		draft.Status = DraftStatusDraft
+		return d.view(draft), ErrDraftInvalid
+	}
+	draft.Status = DraftStatusReady
+	return d.view(draft), nil
+}
+
+// Delete drops a draft, unless it is busy
+func (d *DraftStore) Delete(draftID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	draft, ok := d.drafts[draftID]
+	if !ok {
+		return ErrDraftNotFound
+	}
+	if draft.busy {
+		return ErrDraftBusy
+	}
+	delete(d.drafts, draftID)
+	return nil
+}
+
+// claim marks a draft busy for the caller
+func (d *DraftStore) claim(draftID string) (*WorldDraft, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	draft, ok := d.drafts[draftID]
+	if !ok {
+		return nil, ErrDraftNotFound
+	}
+	if draft.busy {
+		return nil, ErrDraftBusy
+	}
+	draft.busy = true
+	return draft, nil
+}
+
+// release clears a draft claimed with claim
+func (d *DraftStore) release(draft *WorldDraft) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	draft.busy = false
+}
+
+// finish drops a draft claimed with claim once its game has started
+func (d *DraftStore) finish(draft *WorldDraft) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.drafts, draft.ID)
+}
+
+// view copies a draft for callers, with its schema split into sections.
+// Caller must hold d.mu.
+func (d *DraftStore) view(draft *WorldDraft) WorldDraft {
+	v := *draft
+	v.Turns = append([]agents.RefinementTurn(nil), draft.Turns...)
+	v.Sections, _ = agents.SplitSections(draft.Schema)
+	return v
+}
+
+// prune forgets drafts untouched for longer than draftRetention, unless
+// they are busy. Caller must hold d.mu.
+func (d *DraftStore) prune() {
+	cutoff := time.Now().Add(-draftRetention)
+	for id, draft := range d.drafts {
+		if !draft.busy && draft.UpdatedAt.Before(cutoff) {
+			delete(d.drafts, id)
+		}
+	}
+}
+
+// RefineDraftRequest is the request body for POST /api/drafts/{draft}/messages
+type RefineDraftRequest struct {
+	Message string `json:"message"`           // e.g. "rename the villain"
+	Section string `json:"section,omitempty"` // confine the edit to one section
+}
+
+// ownedDraft looks up the draft in the URL for its owner, writing an error
+// response when it cannot
+func (s *Server) ownedDraft(w http.ResponseWriter, r *http.Request) (WorldDraft, bool) {
+	draftID := chi.URLParam(r, "draft")
+	if err := validation.ValidateDraftID(draftID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid draft ID")
+		return WorldDraft{}, false
+	}
+
+	userID := getUserID(r)
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "Missing user ID")
+		return WorldDraft{}, false
+	}
+
+	// Other users' drafts are reported as missing
+	draft, ok := s.drafts.Get(draftID)
+	if !ok || draft.UserID != userID {
+		writeError(w, http.StatusNotFound, "Draft not found")
+		return WorldDraft{}, false
+	}
+	return draft, true
+}
+
+// writeDraftError answers a failed draft operation
+func writeDraftError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, ErrDraftNotFound):
+		writeError(w, http.StatusNotFound, "Draft not found")
+	case errors.Is(err, ErrDraftBusy), errors.Is(err, ErrDraftFull), errors.Is(err, ErrDraftNotReady):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, "Failed to "+action)
+	}
+}
+
+// getDraft returns a draft section by section, with its conversation
+func (s *Server) getDraft(w http.ResponseWriter, r *http.Request) {
+	draft, ok := s.ownedDraft(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, Response{Success: true, Data: draft})
+}
+
+// refineDraft asks the Architect for a targeted edit of a draft. An edit
+// that renames IDs, leaves the section or breaks references is rejected
+// and the draft is left as it was.
+func (s *Server) refineDraft(w http.ResponseWriter, r *http.Request) {
+	draft, ok := s.ownedDraft(w, r)
+	if !ok {
+		return
+	}
+
+	var req RefineDraftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if err := validation.ValidateRefinement(req.Message); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Section != "" && agents.FindWorldSection(req.Section) == nil {
+		writeError(w, http.StatusBadRequest, "Unknown section")
+		return
+	}
+
+	ctx := agents.WithPreferences(agents.WithOrg(r.Context(), draft.OrgID), s.userPreferences(draft.UserID))
+	ctx, cancel := context.WithTimeout(ctx, worldGenTimeout)
+	defer cancel()
+
+	refined, err := s.drafts.Refine(ctx, draft.ID, req.Message, req.Section)
+	switch {
+	case errors.Is(err, ErrDraftNotFound), errors.Is(err, ErrDraftBusy), errors.Is(err, ErrDraftFull):
+		writeDraftError(w, err, "refine draft")
+		return
+	case err != nil:
+		writeError(w, http.StatusBadGateway, "Failed to refine draft: "+err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{Success: true, Data: refined})
+}
+
+// finalizeDraft validates a draft for play. A draft that fails is answered
+// with 422 and its review.
+func (s *Server) finalizeDraft(w http.ResponseWriter, r *http.Request) {
+	draft, ok := s.ownedDraft(w, r)
+	if !ok {
+		return
+	}
+	finalized, err := s.drafts.Finalize(draft.ID)
+	switch {
+	case errors.Is(err, ErrDraftInvalid):
+		// Report the review so the owner can see what to fix
+		writeJSON(w, http.StatusUnprocessableEntity, Response{
+			Success: false,
+			Data:    finalized,
+			Error:   "Draft does not pass validation",
+		})
+		return
+	case err != nil:
+		writeDraftError(w, err, "finalize draft")
+		return
+	}
+	writeJSON(w, http.StatusOK, Response{Success: true, Data: finalized})
+}
+
+// startDraftGame starts the game of a ready draft, in the week draw mode,
+// and drops the draft. An organization draft needs a free game slot.
+func (s *Server) startDraftGame(w http.ResponseWriter, r *http.Request) {
+	view, ok := s.ownedDraft(w, r)
+	if !ok {
+		return
+	}
+
+	if view.OrgID != "" {
+		role, err := s.orgRole(r, view.OrgID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to check membership")
+			return
+		}
+		if role == "" {
+			writeError(w, http.StatusForbidden, "Access denied")
+			return
+		}
+
+		s.orgsMu.Lock()
+		defer s.orgsMu.Unlock()
+		if !s.checkOrgGameSlot(w, view.OrgID) {
+			return
+		}
+	}
+
+	draft, err := s.drafts.claim(view.ID)
+	if err != nil {
+		writeDraftError(w, err, "start game")
+		return
+	}
+	if draft.Status != DraftStatusReady {
+		s.drafts.release(draft)
+		writeDraftError(w, ErrDraftNotReady, "start game")
+		return
+	}
+	engine, err := s.startGame(&newGame{schema: draft.Schema, drawMode: game.DrawModeWeek}, draft.UserID)
+	if err == nil && draft.OrgID != "" {
+		err = s.db.AssignGameToOrg(engine.ID, draft.OrgID, draft.UserID)
+	}
+	if err != nil {
+		s.drafts.release(draft)
+		writeError(w, http.StatusInternalServerError, "Failed to create game")
+		return
+	}
+	s.drafts.finish(draft)
+
+	writeJSON(w, http.StatusCreated, Response{
+		Success: true,
+		Data:    engine.GetGameInfo(),
+	})
+}
+
+// deleteDraft discards a draft
+func (s *Server) deleteDraft(w http.ResponseWriter, r *http.Request) {
+	draft, ok := s.ownedDraft(w, r)
+	if !ok {
+		return
+	}
+	if err := s.drafts.Delete(draft.ID); err != nil {
+		writeDraftError(w, err, "delete draft")
+		return
+	}
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    "Draft deleted",
+	})
+}
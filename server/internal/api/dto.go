@@ -0,0 +1,103 @@
+package api
+
+import (
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/db"
+	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+)
+
+// CreateGameRequest is the request body for POST /api/games and
+// POST /api/orgs/{org}/games. Send a schema, or a seed for a procedural
+// world.
+type CreateGameRequest struct {
+	Schema *agents.WorldGenSchema `json:"schema,omitempty"`
+	Seed   *int64                 `json:"seed,omitempty"`
+	Theme  string                 `json:"theme,omitempty"` // flavors a seeded world
+
+	// Library starts from a pre-generated world of this theme; see
+	// GET /api/worlds/library
+	Library string `json:"library,omitempty"`
+
+	// DrawMode is "week" (default) or "daily"
+	DrawMode string `json:"draw_mode,omitempty"`
+
+	// RNGSeed fixes the game's chance rolls, deck order and plot
+	// tie-breaks, so the same world and choices replay the same run
+	RNGSeed *int64 `json:"rng_seed,omitempty"`
+}
+
+// GameList is a page of the caller's games from GET /api/games
+type GameList struct {
+	Games  []db.GameSummary `json:"games"`
+	Total  int              `json:"total"` // games across all pages
+	Limit  int              `json:"limit"`
+	Offset int              `json:"offset"`
+}
+
+// ResolveCardRequest is the request body for POST /api/games/{id}/resolve
+type ResolveCardRequest struct {
+	CardID    string `json:"card_id"`
+	Direction string `json:"direction"` // "left" or "right"
+}
+
+// ResolveBatchRequest is the request body for POST /api/games/{id}/batch
+type ResolveBatchRequest struct {
+	Steps []game.BatchStep `json:"steps"`
+}
+
+// VerifySessionRequest is the request body for POST /api/games/{id}/verify
+type VerifySessionRequest struct {
+	Actions []game.OfflineAction `json:"actions"`
+	State   *game.ClaimedState   `json:"state"`
+}
+
+// ResurrectRequest is the request body for POST /api/games/{id}/resurrect
+type ResurrectRequest struct {
+	TempTags map[string]bool `json:"temp_tags,omitempty"`
+	Loadout  string          `json:"loadout,omitempty"` // reborn card swipe, optional
+}
+
+// SignupRequest is the request body for POST /api/auth/signup
+type SignupRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// LoginRequest is the request body for POST /api/auth/login
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// RefreshRequest is the request body for POST /api/auth/refresh
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// CreateOrgRequest is the request body for POST /api/orgs
+type CreateOrgRequest struct {
+	Name string `json:"name"`
+}
+
+// AddOrgMemberRequest is the request body for POST /api/orgs/{org}/members
+type AddOrgMemberRequest struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role,omitempty"` // defaults to member
+}
+
+// CreateAPIKeyRequest is the request body for POST /api/orgs/{org}/keys
+// and POST /api/me/keys
+type CreateAPIKeyRequest struct {
+	Name string `json:"name"`
+}
+
+// SetOrgQuotaRequest is the request body for PUT /api/admin/orgs/{org}/quota
+type SetOrgQuotaRequest struct {
+	MaxGames   int `json:"max_games"`
+	MaxMembers int `json:"max_members"`
+}
+
+// ReloadPromptsRequest is the request body for POST /api/admin/prompts/reload
+type ReloadPromptsRequest struct {
+	Dir string `json:"dir,omitempty"` // optional override directory
+}
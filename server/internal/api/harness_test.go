@@ -0,0 +1,189 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/db"
+	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+	mw "github.com/qninhdt/world-card-ai-2/server/internal/middleware"
+)
+
+// testAdmin is the user listed in ADMIN_USERS during tests
+const testAdmin = "admin"
+
+// testServer is the full router on a private in-memory database. Worlds come
+// from the deterministic builder, so no test calls an LLM.
+type testServer struct {
+	t *testing.T
+	*Server
+	handler http.Handler // wraps the router when set, e.g. to inject faults
+}
+
+// testResponse is a decoded response envelope
+type testResponse struct {
+	Status     int
+	Success    bool            `json:"success"`
+	Data       json.RawMessage `json:"data"`
+	Error      string          `json:"error"`
+	NextCursor string          `json:"next_cursor"`
+	Header     http.Header     `json:"-"`
+	Body       []byte          `json:"-"`
+}
+
+// newTestServer boots the router on a fresh in-memory SQLite database
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	t.Setenv("ADMIN_USERS", testAdmin)
+
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	database, err := db.NewDB(fmt.Sprintf("file:%s?mode=memory&cache=shared", name))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	// Rate limits only fire where a test sets them
+	server := NewServer(database)
+	t.Cleanup(server.Close)
+	for _, group := range mw.RouteGroups {
+		server.SetRateLimit(group, mw.RateLimit{})
+	}
+	return &testServer{t: t, Server: server}
+}
+
+// token signs an access token for a fresh login session of user
+func (ts *testServer) token(user string) string {
+	ts.t.Helper()
+	_, session, err := ts.db.CreateSession(user, "test", mw.RefreshTokenTTL)
+	if err != nil {
+		ts.t.Fatalf("Failed to create session: %v", err)
+	}
+	token, err := mw.GenerateSessionToken(user, session.ID)
+	if err != nil {
+		ts.t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+// request sends a request as user ("" for none). Headers are "Name: value"
+// pairs.
+func (ts *testServer) request(method, path, user string, body interface{}, headers ...string) *testResponse {
+	ts.t.Helper()
+
+	var reader *bytes.Reader
+	switch b := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case string:
+		reader = bytes.NewReader([]byte(b))
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			ts.t.Fatalf("Failed to encode body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if user != "" {
+		req.Header.Set("Authorization", "Bearer "+ts.token(user))
+	}
+	for _, h := range headers {
+		name, value, _ := strings.Cut(h, ": ")
+		req.Header.Set(name, value)
+	}
+
+	rec := httptest.NewRecorder()
+	if ts.handler != nil {
+		ts.handler.ServeHTTP(rec, req)
+	} else {
+		ts.ServeHTTP(rec, req)
+	}
+
+	res := &testResponse{Status: rec.Code, Header: rec.Header(), Body: rec.Body.Bytes()}
+	if strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+		// Middleware errors are plain text; those leave the envelope empty
+		json.Unmarshal(res.Body, res)
+	}
+	return res
+}
+
+// expect fails the test unless the response has the given status
+func (ts *testServer) expect(res *testResponse, status int) *testResponse {
+	ts.t.Helper()
+	if res.Status != status {
+		ts.t.Fatalf("Expected status %d, got %d: %s", status, res.Status, res.Body)
+	}
+	return res
+}
+
+// decode unmarshals a response's data
+func (ts *testServer) decode(res *testResponse, v interface{}) {
+	ts.t.Helper()
+	if err := json.Unmarshal(res.Data, v); err != nil {
+		ts.t.Fatalf("Failed to decode %s: %v", res.Data, err)
+	}
+}
+
+// createGame starts a public game, owned by the "public" user, and
+// returns its ID. The tutorial is turned off so draws only hold the deck.
+func (ts *testServer) createGame() string {
+	ts.t.Helper()
+	ts.expect(ts.request(http.MethodPatch, "/api/me/preferences", "public", map[string]bool{"tutorial_done": true}), http.StatusOK)
+	res := ts.expect(ts.request(http.MethodPost, "/api/games", "public", map[string]interface{}{"seed": 7}), http.StatusCreated)
+	var info struct {
+		ID string `json:"id"`
+	}
+	ts.decode(res, &info)
+	return info.ID
+}
+
+// engine returns a loaded game's engine
+func (ts *testServer) engine(gameID string) *game.GameEngine {
+	ts.t.Helper()
+	engine, ok := ts.games.Get(gameID)
+	if !ok {
+		ts.t.Fatalf("Game %s is not loaded", gameID)
+	}
+	return engine
+}
+
+// addCards puts choice cards in a game's deck, since the Writer does not run
+// in tests. Each left choice costs 5 of the returned stat.
+func (ts *testServer) addCards(gameID string, ids ...string) string {
+	ts.t.Helper()
+	stat := ts.statID(gameID)
+	defs := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		defs = append(defs, map[string]interface{}{
+			"id":    id,
+			"title": "Card " + id,
+			"left_choice": map[string]interface{}{
+				"label": "Left",
+				"calls": []interface{}{map[string]interface{}{"name": "update_stat", "params": map[string]interface{}{"stat_id": stat, "delta": float64(-5)}}},
+			},
+			"right_choice": map[string]interface{}{"label": "Right"},
+		})
+	}
+	if added := ts.engine(gameID).AddCardsFromDefs(defs); added != len(ids) {
+		ts.t.Fatalf("Expected %d cards added, got %d", len(ids), added)
+	}
+	return stat
+}
+
+// statID returns the ID of one of a game's visible stats
+func (ts *testServer) statID(gameID string) string {
+	ts.t.Helper()
+	for id := range ts.engine(gameID).GetPlayerState().Stats {
+		return id
+	}
+	ts.t.Fatal("Game has no visible stats")
+	return ""
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
+	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+	"github.com/qninhdt/world-card-ai-2/server/internal/render"
+	"github.com/qninhdt/world-card-ai-2/server/internal/validation"
+)
+
+// maxCachedImages bounds the card image cache
+const maxCachedImages = 256
+
+// imageCache keeps rendered card images by the hash of what they show
+type imageCache struct {
+	mu     sync.Mutex
+	images map[string][]byte
+	order  []string // oldest first
+}
+
+// newImageCache creates an empty image cache
+func newImageCache() *imageCache {
+	return &imageCache{images: make(map[string][]byte)}
+}
+
+// get returns a cached image
+func (c *imageCache) get(hash string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	img, ok := c.images[hash]
+	return img, ok
+}
+
+// put caches an image, evicting the oldest when full
+func (c *imageCache) put(hash string, img []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.images[hash]; ok {
+		return
+	}
+	if len(c.order) >= maxCachedImages {
+		delete(c.images, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.images[hash] = img
+	c.order = append(c.order, hash)
+}
+
+// renderCard returns what a card image shows, with hidden stats left out
+func renderCard(engine *game.GameEngine, card cards.Card) render.Card {
+	view := render.Card{
+		Title:       card.GetTitle(),
+		Description: card.GetDescription(),
+		Character:   card.GetCharacter(),
+	}
+	if choiceCard, ok := card.(*cards.ChoiceCard); ok {
+		if choiceCard.LeftChoice != nil {
+			view.Left = &render.Choice{Label: choiceCard.LeftChoice.Label, Deltas: engine.ChoiceDeltas(choiceCard.LeftChoice)}
+		}
+		if choiceCard.RightChoice != nil {
+			view.Right = &render.Choice{Label: choiceCard.RightChoice.Label, Deltas: engine.ChoiceDeltas(choiceCard.RightChoice)}
+		}
+	}
+	return view
+}
+
+// getCardImage renders a card as a shareable PNG
+func (s *Server) getCardImage(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+	cardID := chi.URLParam(r, "cardId")
+
+	// SECURITY FIX: Validate game ID format
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	if err := validation.ValidateCardID(cardID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid card ID")
+		return
+	}
+
+	// SECURITY FIX: Check game ownership
+	if !s.checkGameOwnership(w, r, gameID) {
+		return
+	}
+
+	engine, ok := s.games.GetOrLoad(gameID)
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	card := engine.FindCard(cardID)
+	if card == nil {
+		writeError(w, http.StatusNotFound, "Card not found")
+		return
+	}
+
+	view := renderCard(engine, card)
+	data, err := json.Marshal(view)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to render card")
+		return
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	img, ok := s.images.get(hash)
+	if !ok {
+		if img, err = render.PNG(view); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to render card")
+			return
+		}
+		s.images.put(hash, img)
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("ETag", `"`+hash+`"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(img)
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+	"github.com/qninhdt/world-card-ai-2/server/internal/validation"
+)
+
+// SetGameStatusRequest is the request body for POST /api/games/{id}/status
+type SetGameStatusRequest struct {
+	Status string `json:"status"` // "active" | "paused" | "ended" | "archived"
+}
+
+// writeStatusError answers an action the game's status does not allow,
+// reporting whether err was one
+func writeStatusError(w http.ResponseWriter, err error) bool {
+	var statusErr *game.StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	writeJSON(w, http.StatusConflict, Response{
+		Success: false,
+		Data:    map[string]interface{}{"status": statusErr.Status},
+		Error:   "Game is " + string(statusErr.Status),
+	})
+	return true
+}
+
+// setGameStatus pauses, resumes, ends, archives or unarchives a game
+func (s *Server) setGameStatus(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+
+	// SECURITY FIX: Validate game ID format
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	// SECURITY FIX: Check game ownership
+	if !s.checkGameOwnership(w, r, gameID) {
+		return
+	}
+
+	var req SetGameStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	status, err := game.ParseGameStatus(req.Status)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	engine, ok := s.games.GetOrLoad(gameID)
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	before := s.watchChange(gameID, engine)
+	err = engine.SetStatus(status)
+	var transitionErr *game.TransitionError
+	if errors.As(err, &transitionErr) {
+		writeError(w, http.StatusConflict, transitionErr.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to change status")
+		return
+	}
+	s.autosave(r, gameID, engine)
+	s.publishChange(gameID, engine, before)
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    engine.GetGameInfo(),
+	})
+}
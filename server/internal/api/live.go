@@ -0,0 +1,311 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+	"github.com/qninhdt/world-card-ai-2/server/internal/validation"
+)
+
+// Live game events pushed over /api/games/{id}/ws
+const (
+	EventState        = "state"         // blackboard diff since the last state event
+	EventCardsDrawn   = "cards_drawn"   // cards dealt by draw or interlude
+	EventCardResolved = "card_resolved" // a card choice and its result
+	EventStats        = "stats"         // visible stats after a change
+	EventDeath        = "death"         // the current life ended
+	EventPlotFired    = "plot_fired"    // a plot node fired
+	EventGameDeleted  = "game_deleted"  // the game was deleted or archived; the socket closes
+)
+
+const (
+	liveBuffer       = 64               // queued events per socket before it is dropped
+	livePingInterval = 30 * time.Second // keepalive ping
+	liveIdleTimeout  = 75 * time.Second // close if the client sends nothing, pongs included
+	liveWriteTimeout = 10 * time.Second
+)
+
+// GameEvent is one message pushed to a game's sockets
+type GameEvent struct {
+	Type    string      `json:"type"`
+	Version int64       `json:"version"` // blackboard version after the change
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// liveHub fans game events out to the sockets watching each game
+type liveHub struct {
+	mu   sync.Mutex
+	subs map[string]map[chan GameEvent]struct{} // by game ID
+}
+
+// newLiveHub creates a hub with no sockets
+func newLiveHub() *liveHub {
+	return &liveHub{subs: make(map[string]map[chan GameEvent]struct{})}
+}
+
+// subscribe registers a socket for a game's events
+func (h *liveHub) subscribe(gameID string) chan GameEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan GameEvent, liveBuffer)
+	if h.subs[gameID] == nil {
+		h.subs[gameID] = make(map[chan GameEvent]struct{})
+	}
+	h.subs[gameID][ch] = struct{}{}
+	return ch
+}
+
+// unsubscribe removes a socket, closing its channel if publish has not
+func (h *liveHub) unsubscribe(gameID string, ch chan GameEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[gameID][ch]; ok {
+		delete(h.subs[gameID], ch)
+		close(ch)
+	}
+	if len(h.subs[gameID]) == 0 {
+		delete(h.subs, gameID)
+	}
+}
+
+// watched reports whether any socket follows a game
+func (h *liveHub) watched(gameID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs[gameID]) > 0
+}
+
+// publish queues events for every socket of a game. A socket too slow to
+// keep up is dropped; its channel closes and the client resyncs on
+// reconnect.
+func (h *liveHub) publish(gameID string, events ...GameEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs[gameID] {
+		if !queueEvents(ch, events) {
+			delete(h.subs[gameID], ch)
+			close(ch)
+		}
+	}
+	if len(h.subs[gameID]) == 0 {
+		delete(h.subs, gameID)
+	}
+}
+
+// queueEvents sends events without blocking, reporting whether all fit
+func queueEvents(ch chan GameEvent, events []GameEvent) bool {
+	for _, event := range events {
+		select {
+		case ch <- event:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// watchChange captures the player view before a mutation, or nil when no
+// socket follows the game and there is nothing to publish
+func (s *Server) watchChange(gameID string, engine *game.GameEngine) *game.GlobalBlackboard {
+	if !s.live.watched(gameID) {
+		return nil
+	}
+	return engine.GetPlayerState()
+}
+
+// publishChange pushes a mutation's events to the game's sockets, then the
+// stat, death and plot events it caused and a state diff. before is the
+// result of watchChange.
+func (s *Server) publishChange(gameID string, engine *game.GameEngine, before *game.GlobalBlackboard, events ...GameEvent) {
+	if before == nil {
+		return
+	}
+	after := engine.GetPlayerState()
+
+	if changes := statChanges(before.Stats, after.Stats); len(changes) > 0 {
+		events = append(events, GameEvent{Type: EventStats, Data: map[string]interface{}{
+			"stats":   after.Stats,
+			"changes": changes,
+		}})
+	}
+	if before.IsAlive && !after.IsAlive {
+		events = append(events, GameEvent{Type: EventDeath, Data: map[string]interface{}{
+			"cause":        after.DeathCause,
+			"turn":         after.DeathTurn,
+			"current_life": after.CurrentLife,
+		}})
+	}
+	if after.PendingPlotNodeID != "" && after.PendingPlotNodeID != before.PendingPlotNodeID {
+		events = append(events, GameEvent{Type: EventPlotFired, Data: map[string]interface{}{
+			"node_id": after.PendingPlotNodeID,
+		}})
+	}
+	// Each socket fills in its own diff
+	events = append(events, GameEvent{Type: EventState})
+
+	for i := range events {
+		events[i].Version = after.Version
+	}
+	s.live.publish(gameID, events...)
+}
+
+// statChanges returns the deltas between two sets of visible stats
+func statChanges(before, after map[string]int) map[string]int {
+	changes := make(map[string]int)
+	for id, value := range after {
+		if delta := value - before[id]; delta != 0 {
+			changes[id] = delta
+		}
+	}
+	for id, value := range before {
+		if _, ok := after[id]; !ok && value != 0 {
+			changes[id] = -value
+		}
+	}
+	return changes
+}
+
+// liveTokenFromProtocol lets browsers, which cannot set headers on a
+// WebSocket, pass their bearer token as the subprotocol after
+// bearerProtocol: new WebSocket(url, ["bearer", token]). Unlike a query
+// parameter, it never reaches access logs.
+func liveTokenFromProtocol(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := protocolToken(r.Header); token != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// protocolToken returns the subprotocol offered after bearerProtocol, or ""
+func protocolToken(h http.Header) string {
+	var offered []string
+	for _, value := range h.Values("Sec-WebSocket-Protocol") {
+		for _, part := range strings.Split(value, ",") {
+			offered = append(offered, strings.TrimSpace(part))
+		}
+	}
+	for i := 0; i+1 < len(offered); i++ {
+		if offered[i] == bearerProtocol {
+			return offered[i+1]
+		}
+	}
+	return ""
+}
+
+// gameSocket streams a game's changes over a WebSocket. The first message
+// is a state diff since ?since= (the full state when omitted); every
+// mutation after that pushes its events followed by a new diff.
+func (s *Server) gameSocket(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+
+	// SECURITY FIX: Validate game ID format
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	// SECURITY FIX: Check game ownership
+	if !s.checkGameOwnership(w, r, gameID) {
+		return
+	}
+
+	since := int64(-1)
+	if v := r.URL.Query().Get("since"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || parsed < 0 {
+			writeError(w, http.StatusBadRequest, "Invalid since version")
+			return
+		}
+		since = parsed
+	}
+
+	if _, ok := s.games.GetOrLoad(gameID); !ok {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	events := s.live.subscribe(gameID)
+	defer s.live.unsubscribe(gameID, events)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- conn.readFrames(liveIdleTimeout, liveWriteTimeout)
+	}()
+
+	ping := time.NewTicker(livePingInterval)
+	defer ping.Stop()
+
+	// send writes an event, filling in the state diff since the last one
+	send := func(event GameEvent) error {
+		if event.Type == EventState {
+			engine, ok := s.games.GetOrLoad(gameID)
+			if !ok {
+				return errors.New("game not found")
+			}
+			diff := engine.Diff(since)
+			if !diff.Full && len(diff.Ops) == 0 {
+				return nil
+			}
+			since = diff.ToVersion
+			event.Version = diff.ToVersion
+			event.Data = diff
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		return conn.writeText(data, liveWriteTimeout)
+	}
+
+	if err := send(GameEvent{Type: EventState}); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				conn.writeClose(wsCloseTryAgainLater, "too slow, reconnect with since", liveWriteTimeout)
+				return
+			}
+			if err := send(event); err != nil {
+				conn.writeClose(wsCloseInternal, "", liveWriteTimeout)
+				return
+			}
+			if event.Type == EventGameDeleted {
+				conn.writeClose(wsCloseNormal, "game deleted", liveWriteTimeout)
+				return
+			}
+		case <-ping.C:
+			if err := conn.writeFrame(wsOpPing, nil, liveWriteTimeout); err != nil {
+				return
+			}
+		case err := <-done:
+			var closeErr *wsCloseError
+			if !errors.As(err, &closeErr) && !errors.Is(err, io.EOF) {
+				log.Printf("Game %s socket closed: %v", gameID, err)
+			}
+			return
+		}
+	}
+}
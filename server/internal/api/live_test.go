@@ -0,0 +1,242 @@
+package api
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSocket is the client end of a game socket
+type testSocket struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// dialSocket opens /api/games/{id}/ws as user, returning the HTTP response
+// when the upgrade is refused
+func dialSocket(t *testing.T, ts *testServer, srv *httptest.Server, gameID, user string) (*testSocket, *http.Response) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	path := "/api/games/" + gameID + "/ws"
+	key := make([]byte, 16)
+	rand.Read(key)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+	if user != "" {
+		req.Header.Set("Sec-WebSocket-Protocol", bearerProtocol+", "+ts.token(user))
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key))
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("Failed to send handshake: %v", err)
+	}
+
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatalf("Failed to read handshake: %v", err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		return nil, res
+	}
+	if user != "" && res.Header.Get("Sec-WebSocket-Protocol") != bearerProtocol {
+		t.Fatalf("Expected the %q subprotocol, got %q", bearerProtocol, res.Header.Get("Sec-WebSocket-Protocol"))
+	}
+	return &testSocket{t: t, conn: conn, br: br}, res
+}
+
+// readFrame reads one server frame
+func (s *testSocket) readFrame() (byte, []byte) {
+	s.t.Helper()
+	s.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var header [2]byte
+	if _, err := io.ReadFull(s.br, header[:]); err != nil {
+		s.t.Fatalf("Failed to read frame: %v", err)
+	}
+	if header[1]&0x80 != 0 {
+		s.t.Fatal("Server frames must not be masked")
+	}
+	length := int(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(s.br, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(s.br, ext[:])
+		length = int(binary.BigEndian.Uint64(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(s.br, payload); err != nil {
+		s.t.Fatalf("Failed to read payload: %v", err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+// next reads the next event, skipping keepalive pings
+func (s *testSocket) next() GameEvent {
+	s.t.Helper()
+	for {
+		opcode, payload := s.readFrame()
+		if opcode == wsOpPing {
+			continue
+		}
+		if opcode != wsOpText {
+			s.t.Fatalf("Expected a text frame, got opcode %d", opcode)
+		}
+		var event GameEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			s.t.Fatalf("Failed to decode event %s: %v", payload, err)
+		}
+		return event
+	}
+}
+
+// expectEvents reads events and fails unless their types match
+func (s *testSocket) expectEvents(types ...string) []GameEvent {
+	s.t.Helper()
+	events := make([]GameEvent, 0, len(types))
+	for _, want := range types {
+		event := s.next()
+		if event.Type != want {
+			s.t.Fatalf("Expected %s event, got %s: %v", want, event.Type, event.Data)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// send writes a masked client frame
+func (s *testSocket) send(opcode byte, payload []byte) {
+	s.t.Helper()
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := s.conn.Write(frame); err != nil {
+		s.t.Fatalf("Failed to send frame: %v", err)
+	}
+}
+
+func TestGameSocket(t *testing.T) {
+	ts := newTestServer(t)
+	srv := httptest.NewServer(ts)
+	defer srv.Close()
+
+	gameID := ts.createGame()
+	stat := ts.addCards(gameID, "live_a")
+
+	t.Run("Auth", func(t *testing.T) {
+		if _, res := dialSocket(t, ts, srv, gameID, ""); res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected 401 without a token, got %d", res.StatusCode)
+		}
+		if _, res := dialSocket(t, ts, srv, gameID, "mallory"); res.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected 403 for another user, got %d", res.StatusCode)
+		}
+		ts.expect(ts.request(http.MethodGet, "/api/games/"+gameID+"/ws", "public", nil), http.StatusUpgradeRequired)
+	})
+
+	socket, res := dialSocket(t, ts, srv, gameID, "public")
+	if socket == nil {
+		t.Fatalf("Upgrade refused with status %d", res.StatusCode)
+	}
+
+	// The first message is the full state
+	first := socket.expectEvents(EventState)[0]
+	var initial struct {
+		Full bool `json:"full"`
+	}
+	data, _ := json.Marshal(first.Data)
+	json.Unmarshal(data, &initial)
+	if !initial.Full {
+		t.Fatalf("Expected a full first state, got %s", data)
+	}
+
+	socket.send(wsOpPing, []byte("hi"))
+	if opcode, payload := socket.readFrame(); opcode != wsOpPong || string(payload) != "hi" {
+		t.Fatalf("Expected pong echoing the ping, got opcode %d %q", opcode, payload)
+	}
+
+	ts.expect(ts.request(http.MethodPost, "/api/games/"+gameID+"/draw", "public", nil), http.StatusOK)
+	// Drawing leaves the blackboard alone, so no state diff follows
+	drawn := socket.expectEvents(EventCardsDrawn)
+	if drawn[0].Version != ts.engine(gameID).GetVersion() {
+		t.Errorf("Expected version %d, got %d", ts.engine(gameID).GetVersion(), drawn[0].Version)
+	}
+
+	ts.expect(ts.request(http.MethodPost, "/api/games/"+gameID+"/resolve", "public",
+		map[string]string{"card_id": "live_a", "direction": "left"}), http.StatusOK)
+	resolved := socket.expectEvents(EventCardResolved, EventStats, EventState)
+	if resolved[2].Version != ts.engine(gameID).GetVersion() {
+		t.Errorf("Expected state version %d, got %d", ts.engine(gameID).GetVersion(), resolved[2].Version)
+	}
+	var stats struct {
+		Changes map[string]int `json:"changes"`
+	}
+	data, _ = json.Marshal(resolved[1].Data)
+	json.Unmarshal(data, &stats)
+	if stats.Changes[stat] != -5 {
+		t.Errorf("Expected %s to change by -5, got %v", stat, stats.Changes)
+	}
+
+	socket.send(wsOpClose, binary.BigEndian.AppendUint16(nil, wsCloseNormal))
+	if opcode, _ := socket.readFrame(); opcode != wsOpClose {
+		t.Fatalf("Expected the close to be echoed, got opcode %d", opcode)
+	}
+
+	// The hub forgets a closed socket
+	deadline := time.Now().Add(5 * time.Second)
+	for ts.live.watched(gameID) {
+		if time.Now().After(deadline) {
+			t.Fatal("Socket still subscribed after close")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLiveHubDropsSlowSockets(t *testing.T) {
+	hub := newLiveHub()
+	slow := hub.subscribe("g")
+	fast := hub.subscribe("g")
+
+	for i := 0; i < liveBuffer; i++ {
+		hub.publish("g", GameEvent{Type: EventState})
+		<-fast
+	}
+	hub.publish("g", GameEvent{Type: EventState})
+
+	for range slow {
+	}
+	if _, ok := <-fast; !ok {
+		t.Fatal("Expected the socket that kept up to stay subscribed")
+	}
+	if !hub.watched("g") {
+		t.Fatal("Expected the game to still be watched")
+	}
+	hub.unsubscribe("g", fast)
+	hub.unsubscribe("g", slow) // already dropped; must not panic
+	if hub.watched("g") {
+		t.Fatal("Expected no sockets left")
+	}
+}
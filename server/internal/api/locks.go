@@ -0,0 +1,120 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Game mutation lock timing; variables so tests can shorten them
+var (
+	gameLockTTL     = 30 * time.Second      // frees games of crashed replicas; renewed while a request runs
+	gameLockWait    = 2 * time.Second       // how long a request waits for a busy game
+	gameLockBackoff = 25 * time.Millisecond // between attempts
+)
+
+// EnableGameLocks makes every mutating game request hold the game's lock
+// in the shared store, so replicas cannot interleave writes to one game
+func (s *Server) EnableGameLocks() {
+	s.gameLocks = true
+}
+
+// gameLockMiddleware serializes mutating requests for a game across replicas.
+// Reads are not locked. Once the lock is held, a copy of the game another
+// replica has saved over since is dropped, so the request works on the
+// latest save.
+//
+// It goes after authentication. With checkAccess, callers who cannot access
+// the game are passed on unlocked for the handler to reject, so nobody can
+// hold another player's game busy.
+func (s *Server) gameLockMiddleware(checkAccess bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gameID := gameIDFromPath(r.URL.Path)
+			if !s.gameLocks || gameID == "" || r.Method == http.MethodGet || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if checkAccess && !s.canAccessGame(r, gameID) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			holder := uuid.New().String()
+			deadline := time.Now().Add(gameLockWait)
+			for {
+				acquired, err := s.db.AcquireGameLock(gameID, holder, gameLockTTL)
+				if err != nil {
+					writeError(w, http.StatusInternalServerError, "Failed to lock game")
+					return
+				}
+				if acquired {
+					break
+				}
+				if time.Now().After(deadline) {
+					writeError(w, http.StatusConflict, "Game is busy, retry shortly")
+					return
+				}
+				time.Sleep(gameLockBackoff)
+			}
+			stop := s.renewGameLock(gameID, holder)
+			defer func() {
+				stop()
+				if err := s.db.ReleaseGameLock(gameID, holder); err != nil {
+					log.Printf("Failed to release lock of game %s: %v", gameID, err)
+				}
+			}()
+
+			s.dropStaleGame(gameID)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// renewGameLock extends a held lock every third of its TTL, so a slow
+// request such as an LLM call keeps it, until stop is called
+func (s *Server) renewGameLock(gameID, holder string) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(gameLockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				renewed, err := s.db.AcquireGameLock(gameID, holder, gameLockTTL)
+				if err != nil || !renewed {
+					log.Printf("Failed to renew lock of game %s: %v", gameID, err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	// Wait for a renewal in flight, so it cannot retake a released lock
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// dropStaleGame drops the in-memory copy of a game if another replica has
+// saved a newer version, so the next lookup restores the latest save.
+// Caller must hold the game's lock.
+func (s *Server) dropStaleGame(gameID string) {
+	engine, ok := s.games.Peek(gameID)
+	if !ok {
+		return
+	}
+	saved, err := s.db.LatestGameVersion(gameID)
+	if err != nil {
+		return
+	}
+	// A copy ahead of the store holds this replica's own unsaved changes
+	if saved > engine.GetVersion() {
+		s.games.Remove(gameID)
+	}
+}
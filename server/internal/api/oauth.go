@@ -0,0 +1,272 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/qninhdt/world-card-ai-2/server/internal/db"
+	mw "github.com/qninhdt/world-card-ai-2/server/internal/middleware"
+	"github.com/qninhdt/world-card-ai-2/server/internal/oauth"
+)
+
+// oauthNonceCookie ties a sign-in to the browser that started it
+const oauthNonceCookie = "oauth_nonce"
+
+// maxUsernameAttempts bounds the numbered usernames tried for a new OAuth
+// account before a random suffix is used
+const maxUsernameAttempts = 20
+
+// maxOAuthUsernameLength leaves room for a suffix within the username limit
+const maxOAuthUsernameLength = 24
+
+// EnableOAuth turns on sign-in with providers. With clientURL set, callbacks
+// redirect there with the outcome in the URL fragment instead of answering
+// with JSON.
+func (s *Server) EnableOAuth(providers map[string]oauth.Provider, clientURL string) {
+	s.oauthProviders = providers
+	s.oauthClientURL = clientURL
+}
+
+// listOAuthProviders lists the providers players can sign in with
+func (s *Server) listOAuthProviders(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(s.oauthProviders))
+	for name := range s.oauthProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    names,
+	})
+}
+
+// oauthProvider returns the provider named in the route
+func (s *Server) oauthProvider(w http.ResponseWriter, r *http.Request) (oauth.Provider, bool) {
+	provider, ok := s.oauthProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Unknown provider")
+		return nil, false
+	}
+	return provider, true
+}
+
+// startOAuth sends the browser to a provider's sign-in page. A session token
+// in ?token= links the identity to that account instead of signing in; the
+// query is redacted from access logs, since a redirect cannot carry headers.
+func (s *Server) startOAuth(w http.ResponseWriter, r *http.Request) {
+	provider, ok := s.oauthProvider(w, r)
+	if !ok {
+		return
+	}
+
+	var linkUser string
+	if token := r.URL.Query().Get("token"); token != "" {
+		claims, err := mw.VerifyToken(token, s.db.ResolveSession)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, "Invalid token")
+			return
+		}
+		linkUser = claims.UserID
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to start sign-in")
+		return
+	}
+	state, err := mw.GenerateOAuthState(provider.Name(), hex.EncodeToString(nonce), linkUser)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to start sign-in")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthNonceCookie,
+		Value:    hex.EncodeToString(nonce),
+		Path:     "/api",
+		MaxAge:   int(mw.OAuthStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode, // sent on the provider's top-level redirect back
+	})
+	http.Redirect(w, r, provider.AuthCodeURL(state), http.StatusFound)
+}
+
+// oauthCallback finishes a sign-in: the provider's account signs in to the
+// account it is linked to, creating one on first use, or is linked to the
+// account that started the flow
+func (s *Server) oauthCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := s.oauthProvider(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		s.oauthError(w, r, http.StatusUnauthorized, "Sign-in was cancelled")
+		return
+	}
+
+	// SECURITY FIX: The state must be ours, for this provider, and come
+	// back to the browser that started the sign-in
+	state, err := mw.ParseOAuthState(query.Get("state"))
+	cookie, cookieErr := r.Cookie(oauthNonceCookie)
+	if err != nil || cookieErr != nil || state.Provider != provider.Name() ||
+		subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state.Nonce)) != 1 {
+		s.oauthError(w, r, http.StatusBadRequest, "Invalid sign-in state")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oauthNonceCookie, Path: "/api", MaxAge: -1})
+
+	identity, err := provider.Identify(r.Context(), query.Get("code"))
+	if err != nil {
+		log.Printf("OAuth sign-in failed: %v", err)
+		s.oauthError(w, r, http.StatusBadGateway, "Failed to verify sign-in")
+		return
+	}
+	linked := db.Identity{Provider: identity.Provider, Subject: identity.Subject, Email: identity.Email}
+
+	if state.LinkUser != "" {
+		err := s.db.LinkIdentity(state.LinkUser, linked)
+		switch {
+		case errors.Is(err, db.ErrIdentityTaken):
+			s.oauthError(w, r, http.StatusConflict, "Identity already linked to another account")
+		case errors.Is(err, db.ErrProviderLinked):
+			s.oauthError(w, r, http.StatusConflict, "Another account at this provider is already linked")
+		case err != nil:
+			s.oauthError(w, r, http.StatusInternalServerError, "Failed to link identity")
+		case s.oauthClientURL != "":
+			s.redirectToClient(w, r, url.Values{"linked": {provider.Name()}})
+		default:
+			writeJSON(w, http.StatusOK, Response{Success: true, Data: linked})
+		}
+		return
+	}
+
+	user, err := s.db.GetUserByIdentity(linked.Provider, linked.Subject)
+	if errors.Is(err, db.ErrIdentityNotFound) {
+		user, err = s.createOAuthUser(identity.Login, linked)
+	}
+	if err != nil {
+		s.oauthError(w, r, http.StatusInternalServerError, "Failed to sign in")
+		return
+	}
+
+	if s.oauthClientURL != "" {
+		auth, err := s.startSession(r, user)
+		if err != nil {
+			s.oauthError(w, r, http.StatusInternalServerError, "Failed to issue token")
+			return
+		}
+		s.redirectToClient(w, r, url.Values{"token": {auth.Token}, "refresh_token": {auth.RefreshToken}})
+		return
+	}
+	s.writeAuthToken(w, r, http.StatusOK, user)
+}
+
+// createOAuthUser creates the account of an identity signing in for the
+// first time, named after its login and numbered when the name is taken
+func (s *Server) createOAuthUser(login string, identity db.Identity) (*db.User, error) {
+	base := oauthUsername(login)
+	for i := 1; i <= maxUsernameAttempts; i++ {
+		name := base
+		if i > 1 {
+			name = fmt.Sprintf("%s-%d", base, i)
+		}
+		user, err := s.db.CreateUserWithIdentity(name, identity)
+		if !errors.Is(err, db.ErrUsernameTaken) {
+			return user, err
+		}
+	}
+
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	return s.db.CreateUserWithIdentity(base+"-"+hex.EncodeToString(suffix), identity)
+}
+
+// oauthUsername turns a provider login into a valid username
+func oauthUsername(login string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.', r == '-':
+			return r
+		case r == ' ':
+			return '_'
+		}
+		return -1
+	}, login)
+	if len(name) > maxOAuthUsernameLength {
+		name = name[:maxOAuthUsernameLength]
+	}
+	if len(name) < 3 {
+		return "player"
+	}
+	return name
+}
+
+// oauthError reports a failed sign-in to the web client, or as JSON
+func (s *Server) oauthError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if s.oauthClientURL != "" {
+		s.redirectToClient(w, r, url.Values{"error": {message}})
+		return
+	}
+	writeError(w, status, message)
+}
+
+// redirectToClient sends the browser back to the web client with values in
+// the fragment, which browsers do not send to servers or in Referer headers
+func (s *Server) redirectToClient(w http.ResponseWriter, r *http.Request, values url.Values) {
+	http.Redirect(w, r, s.oauthClientURL+"#"+values.Encode(), http.StatusFound)
+}
+
+// listIdentities lists the provider accounts linked to the caller
+func (s *Server) listIdentities(w http.ResponseWriter, r *http.Request) {
+	identities, err := s.db.ListIdentities(getUserID(r))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list identities")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    identities,
+	})
+}
+
+// unlinkIdentity removes the caller's account at a provider, keeping at
+// least one way to sign in
+func (s *Server) unlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	if !requireSessionToken(w, r) {
+		return
+	}
+
+	err := s.db.UnlinkIdentity(getUserID(r), chi.URLParam(r, "provider"))
+	if errors.Is(err, db.ErrIdentityNotFound) {
+		writeError(w, http.StatusNotFound, "Identity not linked")
+		return
+	}
+	if errors.Is(err, db.ErrLastSignIn) {
+		writeError(w, http.StatusConflict, "Link another provider first; this is your only way to sign in")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to unlink identity")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    "Identity unlinked",
+	})
+}
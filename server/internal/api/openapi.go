@@ -0,0 +1,368 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
+	"github.com/qninhdt/world-card-ai-2/server/internal/db"
+	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+)
+
+// apiParam documents a query parameter
+type apiParam struct {
+	Name        string
+	Type        string // JSON schema type
+	Description string
+}
+
+// Query parameters shared by several routes
+var (
+	paramCursor = apiParam{"cursor", "string", "Cursor from the previous page's next_cursor"}
+	paramLimit  = apiParam{"limit", "integer", "Page size"}
+	paramOffset = apiParam{"offset", "integer", "Items to skip"}
+	paramSince  = apiParam{"since", "integer", "Blackboard version to diff from"}
+)
+
+// apiOperation documents one route for the OpenAPI document
+type apiOperation struct {
+	Summary  string
+	Public   bool        // no credentials required
+	Query    []apiParam  // query parameters
+	Request  interface{} // request body type, nil for none
+	Response interface{} // type of the envelope's data, nil for any JSON
+	Status   int         // success status; 0 means 200
+}
+
+// apiOperations documents every v1 route, keyed by method and chi pattern
+// within the version. Routes missing here are still listed, without a
+// summary or schemas.
+var apiOperations = map[string]apiOperation{
+	"GET /openapi.json": {Summary: "This OpenAPI document", Public: true},
+
+	"POST /auth/signup":                    {Summary: "Create an account and log in", Public: true, Request: SignupRequest{}, Response: AuthToken{}, Status: http.StatusCreated},
+	"POST /auth/login":                     {Summary: "Log in with a username and password", Public: true, Request: LoginRequest{}, Response: AuthToken{}},
+	"POST /auth/refresh":                   {Summary: "Trade a refresh token for a new token pair; reusing one ends its session", Public: true, Request: RefreshRequest{}, Response: AuthToken{}},
+	"POST /auth/logout":                    {Summary: "End the current session, or all of yours with ?all=true", Query: []apiParam{{"all", "boolean", "End every session"}}},
+	"GET /me":                              {Summary: "Get your profile", Response: Profile{}},
+	"GET /me/sessions":                     {Summary: "List your active sessions", Response: []db.Session{}},
+	"DELETE /me/sessions/{session}":        {Summary: "End one of your sessions"},
+	"GET /auth/oauth":                      {Summary: "List the OAuth providers you can sign in with", Public: true, Response: []string{}},
+	"GET /auth/oauth/{provider}":           {Summary: "Redirect to a provider's sign-in page", Public: true, Query: []apiParam{{"token", "string", "Session token of an account to link the identity to"}}, Status: http.StatusFound},
+	"GET /auth/oauth/{provider}/callback":  {Summary: "Finish an OAuth sign-in or link", Public: true, Query: []apiParam{{"code", "string", "Authorization code"}, {"state", "string", "State from the redirect"}}, Response: AuthToken{}},
+	"GET /me/preferences":                  {Summary: "Get your preferences", Response: db.UserPreferences{}},
+	"PATCH /me/preferences":                {Summary: "Change your preferences; fields left out keep their value", Request: db.UserPreferences{}, Response: db.UserPreferences{}},
+	"GET /me/keys":                         {Summary: "List your personal API keys", Query: []apiParam{paramCursor, paramLimit}, Response: []db.APIKey{}},
+	"POST /me/keys":                        {Summary: "Create a personal API key (shown once)", Request: CreateAPIKeyRequest{}, Status: http.StatusCreated},
+	"DELETE /me/keys/{key}":                {Summary: "Revoke a personal API key"},
+	"GET /me/identities":                   {Summary: "List your linked OAuth identities", Response: []db.Identity{}},
+	"DELETE /me/identities/{provider}":     {Summary: "Unlink an OAuth identity, unless it is your only way to sign in"},
+	"POST /games":                          {Summary: "Create a game from a schema or a seed", Request: CreateGameRequest{}, Status: http.StatusCreated},
+	"GET /shared/{token}/recap":            {Summary: "Public recap of a shared game", Public: true, Response: recap{}},
+	"GET /games":                           {Summary: "List your games", Query: []apiParam{{"sort", "string", "last_played, created or name"}, paramLimit, paramOffset}, Response: GameList{}},
+	"GET /games/{id}":                      {Summary: "Get a game's current state"},
+	"DELETE /games/{id}":                   {Summary: "Delete a game, or archive it with ?archive=true", Query: []apiParam{{"archive", "boolean", "Archive instead of deleting"}}},
+	"POST /games/{id}/save":                {Summary: "Save a game"},
+	"POST /games/{id}/status":              {Summary: "Pause, resume, end, archive or unarchive a game", Request: SetGameStatusRequest{}},
+	"POST /games/{id}/draw":                {Summary: "Draw cards from the deck", Response: []cards.Card{}},
+	"POST /games/{id}/resolve":             {Summary: "Resolve a drawn card", Request: ResolveCardRequest{}, Response: cards.ExecuteResult{}},
+	"POST /games/{id}/batch":               {Summary: "Resolve several cards atomically", Request: ResolveBatchRequest{}, Response: game.BatchResult{}},
+	"POST /games/{id}/verify":              {Summary: "Replay and verify an offline session", Request: VerifySessionRequest{}, Response: game.Verification{}},
+	"POST /games/{id}/advance":             {Summary: "Advance to the next week"},
+	"GET /games/{id}/dag":                  {Summary: "Get the plot graph"},
+	"GET /games/{id}/cards/{cardId}/image": {Summary: "Get a card's image"},
+	"POST /games/{id}/interlude":           {Summary: "Draw the interlude between lives", Response: []cards.Card{}},
+	"POST /games/{id}/resurrect":           {Summary: "Start the next life", Request: ResurrectRequest{}},
+	"POST /games/{id}/undo":                {Summary: "Take back the last action; 409 when there is none"},
+	"POST /games/{id}/redo":                {Summary: "Apply the last undone action again; 409 when there is none"},
+	"GET /games/{id}/history":              {Summary: "Game info, state and a page of saved snapshots", Query: []apiParam{paramCursor, paramLimit}},
+	"GET /games/{id}/diff":                 {Summary: "State changes since a version", Query: []apiParam{paramSince}, Response: game.StateDiff{}},
+	"GET /games/{id}/endings":              {Summary: "List the endings reached"},
+	"GET /games/{id}/glossary":             {Summary: "Stats, tags, NPCs and seasons with descriptions", Response: game.Glossary{}},
+	"GET /games/{id}/codex":                {Summary: "Unlocked NPCs, tags and arcs with their lore", Response: []game.CodexEntry{}},
+	"POST /games/{id}/share":               {Summary: "Create a public recap link"},
+	"DELETE /games/{id}/share":             {Summary: "Revoke a game's recap links"},
+	"GET /games/{id}/ws":                   {Summary: "WebSocket of live game events", Query: []apiParam{paramSince, {"token", "string", "Bearer token, for browsers"}}},
+
+	"POST /worlds":        {Summary: "Queue a world generation", Request: SubmitWorldRequest{}, Response: WorldGenJob{}, Status: http.StatusAccepted},
+	"GET /worlds/library": {Summary: "List the themes with pre-generated worlds ready to play", Response: []db.LibraryTheme{}},
+	"GET /worlds/{job}":   {Summary: "Get a world generation job", Response: WorldGenJob{}},
+	"DELETE /jobs/{id}":   {Summary: "Cancel a world generation job", Response: WorldGenJob{}},
+
+	"GET /drafts/{draft}":           {Summary: "Get a world draft section by section, with its conversation", Response: WorldDraft{}},
+	"POST /drafts/{draft}/messages": {Summary: "Ask the Architect to refine a world draft", Request: RefineDraftRequest{}, Response: WorldDraft{}},
+	"POST /drafts/{draft}/finalize": {Summary: "Validate a world draft and mark it ready to play", Response: WorldDraft{}},
+	"POST /drafts/{draft}/game":     {Summary: "Start the game of a ready world draft", Status: http.StatusCreated},
+	"DELETE /drafts/{draft}":        {Summary: "Discard a world draft"},
+
+	"POST /orgs":                        {Summary: "Create an organization", Request: CreateOrgRequest{}, Response: db.Organization{}, Status: http.StatusCreated},
+	"GET /orgs":                         {Summary: "List your organizations", Query: []apiParam{paramCursor, paramLimit}, Response: []db.Organization{}},
+	"GET /orgs/{org}":                   {Summary: "Get an organization and your role"},
+	"GET /orgs/{org}/members":           {Summary: "List an organization's members", Query: []apiParam{paramCursor, paramLimit}, Response: []db.OrgMember{}},
+	"POST /orgs/{org}/members":          {Summary: "Add or update a member", Request: AddOrgMemberRequest{}},
+	"DELETE /orgs/{org}/members/{user}": {Summary: "Remove a member"},
+	"GET /orgs/{org}/keys":              {Summary: "List an organization's API keys", Query: []apiParam{paramCursor, paramLimit}, Response: []db.APIKey{}},
+	"POST /orgs/{org}/keys":             {Summary: "Create an API key; the key is shown once", Request: CreateAPIKeyRequest{}, Status: http.StatusCreated},
+	"DELETE /orgs/{org}/keys/{key}":     {Summary: "Revoke an API key"},
+	"GET /orgs/{org}/games":             {Summary: "List an organization's games", Query: []apiParam{paramCursor, paramLimit}},
+	"POST /orgs/{org}/games":            {Summary: "Create a game in an organization", Request: CreateGameRequest{}, Status: http.StatusCreated},
+	"GET /orgs/{org}/analytics":         {Summary: "Organization usage totals", Response: db.OrgAnalytics{}},
+
+	"GET /admin/games":                         {Summary: "List games in memory"},
+	"POST /admin/games/{id}/save":              {Summary: "Save any game"},
+	"POST /admin/games/{id}/unload":            {Summary: "Save and unload a game from memory"},
+	"POST /admin/games/{id}/recompile":         {Summary: "Recompile a game's plot conditions"},
+	"POST /admin/games/{id}/requeue":           {Summary: "Requeue a game's failed generation jobs"},
+	"GET /admin/games/{id}/prompt":             {Summary: "Render an agent's prompts for a game", Query: []apiParam{{"agent", "string", "writer or architect"}, {"theme", "string", "Architect theme"}}},
+	"GET /admin/games/{id}/generation-preview": {Summary: "The Writer request a game would send"},
+	"GET /admin/games/{id}/dump":               {Summary: "Dump a game's internals", Query: []apiParam{{"format", "string", "zip for an archive"}}},
+	"PATCH /admin/games/{id}/state":            {Summary: "Patch a live game's state with a JSON merge patch"},
+	"POST /admin/prompts/reload":               {Summary: "Reload prompt templates", Request: ReloadPromptsRequest{}, Response: agents.PromptReload{}},
+	"GET /admin/spend":                         {Summary: "LLM spend and limits", Response: agents.SpendStatus{}},
+	"GET /admin/llm/failures":                  {Summary: "LLM validation failures", Query: []apiParam{{"hours", "integer", "Window in hours"}}},
+	"GET /admin/telemetry/export":              {Summary: "Export anonymized gameplay traces as JSON lines"},
+	"POST /admin/generation/pause":             {Summary: "Pause LLM generation", Response: agents.SpendStatus{}},
+	"POST /admin/generation/resume":            {Summary: "Resume LLM generation", Response: agents.SpendStatus{}},
+	"PUT /admin/orgs/{org}/quota":              {Summary: "Set an organization's quota", Request: SetOrgQuotaRequest{}, Response: db.Organization{}},
+}
+
+// pathParamPattern matches the {name} parameters of a chi route
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// getOpenAPI serves the OpenAPI 3 document for the API
+func (s *Server) getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	doc, err := s.openAPIDocument()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to build API document")
+		return
+	}
+	writeJSON(w, http.StatusOK, doc)
+}
+
+// openAPIDocument describes every registered route, with schemas built from
+// the request and response types in apiOperations
+func (s *Server) openAPIDocument() (map[string]interface{}, error) {
+	builder := &schemaBuilder{components: map[string]interface{}{
+		"Response": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"success":     map[string]interface{}{"type": "boolean"},
+				"data":        map[string]interface{}{},
+				"error":       map[string]interface{}{"type": "string"},
+				"next_cursor": map[string]interface{}{"type": "string"},
+			},
+		},
+	}}
+
+	paths := make(map[string]interface{})
+	err := chi.Walk(s.router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		route = stripVersion(route)
+		op := apiOperations[method+" "+route]
+		item, _ := paths[route].(map[string]interface{})
+		if item == nil {
+			item = make(map[string]interface{})
+			paths[route] = item
+		}
+		item[strings.ToLower(method)] = builder.operation(route, op)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "World Card AI API",
+			"version": "1.0.0",
+		},
+		"servers": []interface{}{map[string]interface{}{"url": "/api/" + APIVersion1}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": builder.components,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKey":     map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+	}, nil
+}
+
+// operation describes one route
+func (b *schemaBuilder) operation(route string, op apiOperation) map[string]interface{} {
+	// The first path segment groups related routes
+	tag := strings.SplitN(strings.TrimPrefix(route, "/"), "/", 2)[0]
+	result := map[string]interface{}{"tags": []string{tag}}
+	if op.Summary != "" {
+		result["summary"] = op.Summary
+	}
+
+	params := make([]interface{}, 0)
+	for _, match := range pathParamPattern.FindAllStringSubmatch(route, -1) {
+		params = append(params, map[string]interface{}{
+			"name": match[1], "in": "path", "required": true,
+			"schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, p := range op.Query {
+		params = append(params, map[string]interface{}{
+			"name": p.Name, "in": "query", "description": p.Description,
+			"schema": map[string]interface{}{"type": p.Type},
+		})
+	}
+	if len(params) > 0 {
+		result["parameters"] = params
+	}
+
+	if op.Request != nil {
+		result["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": b.schema(reflect.TypeOf(op.Request))},
+			},
+		}
+	}
+
+	envelope := interface{}(map[string]interface{}{"$ref": "#/components/schemas/Response"})
+	if op.Response != nil {
+		envelope = map[string]interface{}{"allOf": []interface{}{
+			envelope,
+			map[string]interface{}{"properties": map[string]interface{}{
+				"data": b.schema(reflect.TypeOf(op.Response)),
+			}},
+		}}
+	}
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	result["responses"] = map[string]interface{}{
+		strconv.Itoa(status): map[string]interface{}{
+			"description": http.StatusText(status),
+			"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": envelope}},
+		},
+		"default": map[string]interface{}{
+			"description": "Error",
+			"content": map[string]interface{}{"application/json": map[string]interface{}{
+				"schema": map[string]interface{}{"$ref": "#/components/schemas/Response"},
+			}},
+		},
+	}
+
+	switch {
+	case op.Public:
+		result["security"] = []interface{}{}
+	case tag == "admin":
+		result["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+	default:
+		result["security"] = []interface{}{
+			map[string]interface{}{"bearerAuth": []string{}},
+			map[string]interface{}{"apiKey": []string{}},
+		}
+	}
+	return result
+}
+
+// schemaBuilder converts Go types to JSON schemas, collecting named structs
+// as components
+type schemaBuilder struct {
+	components map[string]interface{}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema returns the schema of t, or a reference to its component
+func (b *schemaBuilder) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		name := componentName(t)
+		if _, ok := b.components[name]; !ok {
+			// Reserve the name first so recursive types terminate
+			b.components[name] = map[string]interface{}{}
+			b.components[name] = b.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	// Interfaces hold any JSON
+	return map[string]interface{}{}
+}
+
+// structSchema describes a struct's JSON fields, flattening embedded structs
+func (b *schemaBuilder) structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	b.addFields(t, properties)
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+// addFields adds a struct's JSON fields to properties
+func (b *schemaBuilder) addFields(t reflect.Type, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				b.addFields(embedded, properties)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = b.schema(field.Type)
+	}
+}
+
+// componentName names a struct's schema: bare for this package's types,
+// package-qualified for the rest so names from different packages do not
+// collide
+func componentName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if pkg == reflect.TypeOf(Response{}).PkgPath() {
+		return t.Name()
+	}
+	return pkg[strings.LastIndex(pkg, "/")+1:] + "." + t.Name()
+}
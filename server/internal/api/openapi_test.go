@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// TestOpenAPI tests the OpenAPI document served at /api/openapi.json
+func TestOpenAPI(t *testing.T) {
+	ts := newTestServer(t)
+
+	res := ts.expect(ts.request(http.MethodGet, "/api/openapi.json", "", nil), http.StatusOK)
+	var doc struct {
+		OpenAPI    string                                       `json:"openapi"`
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(res.Body, &doc); err != nil {
+		t.Fatalf("Failed to decode document: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("Expected OpenAPI 3.0.3, got %q", doc.OpenAPI)
+	}
+
+	// Every route is in the document and documented
+	chi.Walk(ts.router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		route = stripVersion(route)
+		if _, ok := apiOperations[method+" "+route]; !ok {
+			t.Errorf("Route %s %s is not in apiOperations", method, route)
+		}
+		if _, ok := doc.Paths[route][map[string]string{
+			"GET": "get", "POST": "post", "PUT": "put", "PATCH": "patch", "DELETE": "delete",
+		}[method]]; !ok {
+			t.Errorf("Route %s %s is missing from the document", method, route)
+		}
+		return nil
+	})
+	for key := range apiOperations {
+		found := false
+		chi.Walk(ts.router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+			found = found || method+" "+stripVersion(route) == key
+			return nil
+		})
+		if !found {
+			t.Errorf("apiOperations documents %s, which is not routed", key)
+		}
+	}
+
+	resolve := doc.Paths["/games/{id}/resolve"]["post"]
+	body, _ := json.Marshal(resolve["requestBody"])
+	if string(body) != `{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/ResolveCardRequest"}}},"required":true}` {
+		t.Errorf("Unexpected resolve request body: %s", body)
+	}
+	if _, ok := doc.Components.Schemas["ResolveCardRequest"].Properties["card_id"]; !ok {
+		t.Errorf("Expected card_id in ResolveCardRequest, got %v", doc.Components.Schemas["ResolveCardRequest"])
+	}
+
+	// Embedded structs are flattened
+	player := doc.Components.Schemas["agents.PlayerCharacterDef"].Properties
+	if _, ok := player["id"]; !ok {
+		t.Errorf("Expected the embedded id in agents.PlayerCharacterDef, got %v", player)
+	}
+
+	if security, _ := doc.Paths["/auth/login"]["post"]["security"].([]interface{}); security == nil || len(security) != 0 {
+		t.Errorf("Expected logging in to need no credentials, got %v", doc.Paths["/auth/login"]["post"]["security"])
+	}
+	if security, _ := doc.Paths["/games"]["get"]["security"].([]interface{}); len(security) != 2 {
+		t.Errorf("Expected listing games to accept a token or an API key, got %v", security)
+	}
+}
@@ -0,0 +1,534 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/qninhdt/world-card-ai-2/server/internal/db"
+	mw "github.com/qninhdt/world-card-ai-2/server/internal/middleware"
+	"github.com/qninhdt/world-card-ai-2/server/internal/validation"
+)
+
+// roleAPIKey is the access level of a request made with an org API key:
+// it can use the org's games but cannot manage members or keys
+const roleAPIKey = "api_key"
+
+// getKeyOrgID returns the organization of an API key request, or ""
+func getKeyOrgID(r *http.Request) string {
+	if caller := mw.CallerFrom(r.Context()); caller != nil {
+		return caller.OrgID
+	}
+	return ""
+}
+
+// orgRole returns the caller's access level in an organization, or "" if
+// they have none
+func (s *Server) orgRole(r *http.Request, orgID string) (string, error) {
+	if keyOrg := getKeyOrgID(r); keyOrg != "" {
+		if keyOrg == orgID {
+			return roleAPIKey, nil
+		}
+		return "", nil
+	}
+	return s.db.GetOrgRole(orgID, getUserID(r))
+}
+
+// requireOrgAccess validates the {org} URL parameter and checks that the
+// caller belongs to it. With manage set, only owners and admins pass.
+func (s *Server) requireOrgAccess(w http.ResponseWriter, r *http.Request, manage bool) (string, string, bool) {
+	orgID := chi.URLParam(r, "org")
+	if err := validation.ValidateOrgID(orgID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid organization ID")
+		return "", "", false
+	}
+
+	role, err := s.orgRole(r, orgID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to check membership")
+		return "", "", false
+	}
+	if role == "" {
+		writeError(w, http.StatusForbidden, "Access denied")
+		return "", "", false
+	}
+	if manage && role != db.RoleOwner && role != db.RoleAdmin {
+		writeError(w, http.StatusForbidden, "Organization admin access required")
+		return "", "", false
+	}
+	return orgID, role, true
+}
+
+// canAccessOrgGame reports whether the caller belongs to the organization
+// that owns a game
+func (s *Server) canAccessOrgGame(r *http.Request, gameID string) (bool, error) {
+	orgID, err := s.db.GetGameOrg(gameID)
+	if err != nil || orgID == "" {
+		return false, err
+	}
+	role, err := s.orgRole(r, orgID)
+	return role != "", err
+}
+
+// createOrg creates an organization owned by the caller
+func (s *Server) createOrg(w http.ResponseWriter, r *http.Request) {
+	if getKeyOrgID(r) != "" {
+		writeError(w, http.StatusForbidden, "API keys cannot create organizations")
+		return
+	}
+
+	var req CreateOrgRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validation.ValidateName(req.Name); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	org, err := s.db.CreateOrg(req.Name, getUserID(r))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create organization")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, Response{
+		Success: true,
+		Data:    org,
+	})
+}
+
+// listOrgs lists the organizations the caller belongs to
+func (s *Server) listOrgs(w http.ResponseWriter, r *http.Request) {
+	page, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	var (
+		orgs []db.Organization
+		next int64
+		err  error
+	)
+	if keyOrg := getKeyOrgID(r); keyOrg != "" {
+		// An organization key sees only its own organization, on one page
+		orgs = []db.Organization{}
+		if page.After == 0 {
+			var org *db.Organization
+			if org, err = s.db.GetOrg(keyOrg); err == nil {
+				orgs = append(orgs, *org)
+			}
+		}
+	} else {
+		orgs, next, err = s.db.GetUserOrgs(getUserID(r), page)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list organizations")
+		return
+	}
+
+	writePage(w, orgs, next)
+}
+
+// getOrg returns an organization and the caller's role in it
+func (s *Server) getOrg(w http.ResponseWriter, r *http.Request) {
+	orgID, role, ok := s.requireOrgAccess(w, r, false)
+	if !ok {
+		return
+	}
+
+	org, err := s.db.GetOrg(orgID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Organization not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"organization": org,
+			"role":         role,
+		},
+	})
+}
+
+// listOrgMembers lists an organization's members
+func (s *Server) listOrgMembers(w http.ResponseWriter, r *http.Request) {
+	orgID, _, ok := s.requireOrgAccess(w, r, false)
+	if !ok {
+		return
+	}
+
+	page, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	members, next, err := s.db.GetOrgMembers(orgID, page)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list members")
+		return
+	}
+
+	writePage(w, members, next)
+}
+
+// addOrgMember adds a user to an organization or changes their role
+func (s *Server) addOrgMember(w http.ResponseWriter, r *http.Request) {
+	orgID, role, ok := s.requireOrgAccess(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req AddOrgMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Role == "" {
+		req.Role = db.RoleMember
+	}
+	if err := validation.ValidateUserID(req.UserID); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validation.ValidateOrgRole(req.Role); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.orgsMu.Lock()
+	defer s.orgsMu.Unlock()
+
+	current, err := s.db.GetOrgRole(orgID, req.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to add member")
+		return
+	}
+	// Only owners may create owners or change an owner's role
+	if role != db.RoleOwner && (req.Role == db.RoleOwner || current == db.RoleOwner) {
+		writeError(w, http.StatusForbidden, "Only owners can manage owners")
+		return
+	}
+
+	if current == db.RoleOwner && req.Role != db.RoleOwner {
+		members, _, err := s.db.GetOrgMembers(orgID, db.Page{})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to add member")
+			return
+		}
+		owners := 0
+		for _, m := range members {
+			if m.Role == db.RoleOwner {
+				owners++
+			}
+		}
+		if owners == 1 {
+			writeError(w, http.StatusBadRequest, "Cannot demote the last owner")
+			return
+		}
+	}
+
+	if current == "" {
+		org, err := s.db.GetOrg(orgID)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "Organization not found")
+			return
+		}
+		members, _, err := s.db.GetOrgMembers(orgID, db.Page{})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to add member")
+			return
+		}
+		if org.MaxMembers > 0 && len(members) >= org.MaxMembers {
+			writeError(w, http.StatusForbidden, "Organization member quota reached")
+			return
+		}
+	}
+
+	if err := s.db.AddOrgMember(orgID, req.UserID, req.Role); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to add member")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: map[string]string{
+			"user_id": req.UserID,
+			"role":    req.Role,
+		},
+	})
+}
+
+// removeOrgMember removes a user from an organization
+func (s *Server) removeOrgMember(w http.ResponseWriter, r *http.Request) {
+	orgID, role, ok := s.requireOrgAccess(w, r, true)
+	if !ok {
+		return
+	}
+
+	userID := chi.URLParam(r, "user")
+	if err := validation.ValidateUserID(userID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	s.orgsMu.Lock()
+	defer s.orgsMu.Unlock()
+
+	members, _, err := s.db.GetOrgMembers(orgID, db.Page{})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to remove member")
+		return
+	}
+	owners, target := 0, ""
+	for _, m := range members {
+		if m.Role == db.RoleOwner {
+			owners++
+		}
+		if m.UserID == userID {
+			target = m.Role
+		}
+	}
+	if target == "" {
+		writeError(w, http.StatusNotFound, "Member not found")
+		return
+	}
+	if target == db.RoleOwner {
+		if role != db.RoleOwner {
+			writeError(w, http.StatusForbidden, "Only owners can manage owners")
+			return
+		}
+		if owners == 1 {
+			writeError(w, http.StatusBadRequest, "Cannot remove the last owner")
+			return
+		}
+	}
+
+	if err := s.db.RemoveOrgMember(orgID, userID); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to remove member")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    "Member removed",
+	})
+}
+
+// listAPIKeys lists an organization's API keys without their secrets
+func (s *Server) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	orgID, _, ok := s.requireOrgAccess(w, r, true)
+	if !ok {
+		return
+	}
+
+	page, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	keys, next, err := s.db.ListAPIKeys(orgID, page)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list API keys")
+		return
+	}
+
+	writePage(w, keys, next)
+}
+
+// createAPIKey issues an API key; the secret is only returned here
+func (s *Server) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	orgID, _, ok := s.requireOrgAccess(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validation.ValidateName(req.Name); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	key, info, err := s.db.CreateAPIKey(orgID, req.Name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"key":     key,
+			"api_key": info,
+		},
+	})
+}
+
+// revokeAPIKey disables an API key
+func (s *Server) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	orgID, _, ok := s.requireOrgAccess(w, r, true)
+	if !ok {
+		return
+	}
+
+	keyID := chi.URLParam(r, "key")
+	if err := validation.ValidateOrgID(keyID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	if err := s.db.RevokeAPIKey(orgID, keyID); err != nil {
+		writeError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    "API key revoked",
+	})
+}
+
+// listOrgGames lists the games created under an organization
+func (s *Server) listOrgGames(w http.ResponseWriter, r *http.Request) {
+	orgID, _, ok := s.requireOrgAccess(w, r, false)
+	if !ok {
+		return
+	}
+
+	page, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	gameIDs, next, err := s.db.GetOrgGames(orgID, page)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list games")
+		return
+	}
+
+	writePage(w, gameIDs, next)
+}
+
+// createOrgGame creates a game under an organization, within its quota
+func (s *Server) createOrgGame(w http.ResponseWriter, r *http.Request) {
+	orgID, _, ok := s.requireOrgAccess(w, r, false)
+	if !ok {
+		return
+	}
+
+	req, ok := decodeNewGame(w, r)
+	if !ok {
+		return
+	}
+
+	s.orgsMu.Lock()
+	defer s.orgsMu.Unlock()
+
+	if !s.checkOrgGameSlot(w, orgID) {
+		return
+	}
+
+	userID := getUserID(r)
+	engine, err := s.startGame(req, userID)
+	if err != nil {
+		writeStartGameError(w, err)
+		return
+	}
+	if err := s.db.AssignGameToOrg(engine.ID, orgID, userID); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create game")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, Response{
+		Success: true,
+		Data:    engine.GetGameInfo(),
+	})
+}
+
+// checkOrgGameSlot reports whether an organization has room for another
+// game, counting the world generations that hold a slot. Caller must hold
+// s.orgsMu.
+func (s *Server) checkOrgGameSlot(w http.ResponseWriter, orgID string) bool {
+	org, err := s.db.GetOrg(orgID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Organization not found")
+		return false
+	}
+	if org.MaxGames == 0 {
+		return true
+	}
+
+	// Reservations first: a job finishing in between is then counted twice
+	// rather than not at all
+	reserved := s.worldGen.Reserved(orgID)
+	gameIDs, _, err := s.db.GetOrgGames(orgID, db.Page{})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create game")
+		return false
+	}
+	if len(gameIDs)+reserved >= org.MaxGames {
+		writeError(w, http.StatusForbidden, "Organization game quota reached")
+		return false
+	}
+	return true
+}
+
+// getOrgAnalytics returns usage totals across an organization's games
+func (s *Server) getOrgAnalytics(w http.ResponseWriter, r *http.Request) {
+	orgID, _, ok := s.requireOrgAccess(w, r, false)
+	if !ok {
+		return
+	}
+
+	analytics, err := s.db.GetOrgAnalytics(orgID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load analytics")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    analytics,
+	})
+}
+
+// adminSetOrgQuota sets an organization's game and member limits
+func (s *Server) adminSetOrgQuota(w http.ResponseWriter, r *http.Request) {
+	orgID := chi.URLParam(r, "org")
+	if err := validation.ValidateOrgID(orgID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid organization ID")
+		return
+	}
+
+	var req SetOrgQuotaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.MaxGames < 0 || req.MaxMembers < 0 {
+		writeError(w, http.StatusBadRequest, "Quotas must be zero (unlimited) or positive")
+		return
+	}
+
+	if err := s.db.SetOrgQuota(orgID, req.MaxGames, req.MaxMembers); err != nil {
+		writeError(w, http.StatusNotFound, "Organization not found")
+		return
+	}
+
+	org, err := s.db.GetOrg(orgID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load organization")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    org,
+	})
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/db"
+)
+
+// TestOrganizations tests org membership, roles, API keys and shared games
+func TestOrganizations(t *testing.T) {
+	ts := newTestServer(t)
+
+	ts.expect(ts.request(http.MethodPost, "/api/orgs", "alice", map[string]string{"name": ""}), http.StatusBadRequest)
+	var org struct {
+		ID string `json:"id"`
+	}
+	ts.decode(ts.expect(ts.request(http.MethodPost, "/api/orgs", "alice", map[string]string{"name": "Guild"}), http.StatusCreated), &org)
+	base := "/api/orgs/" + org.ID
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	ts.decode(ts.expect(ts.request(http.MethodPost, base+"/games", "alice", map[string]interface{}{"seed": 3}), http.StatusCreated), &created)
+	gameID := created.ID
+
+	// Outsiders see neither the org nor its games
+	ts.expect(ts.request(http.MethodGet, base, "bob", nil), http.StatusForbidden)
+	ts.expect(ts.request(http.MethodGet, "/api/games/"+gameID, "bob", nil), http.StatusForbidden)
+	ts.expect(ts.request(http.MethodPost, base+"/games", "bob", map[string]interface{}{"seed": 3}), http.StatusForbidden)
+
+	// Members share the org's games but cannot manage it
+	ts.expect(ts.request(http.MethodPost, base+"/members", "alice", map[string]string{"user_id": "bob", "role": "member"}), http.StatusOK)
+	ts.expect(ts.request(http.MethodGet, "/api/games/"+gameID, "bob", nil), http.StatusOK)
+	ts.expect(ts.request(http.MethodPost, base+"/keys", "bob", map[string]string{"name": "ci"}), http.StatusForbidden)
+	ts.expect(ts.request(http.MethodPost, base+"/members", "bob", map[string]string{"user_id": "carol", "role": "member"}), http.StatusForbidden)
+
+	var members []map[string]interface{}
+	ts.decode(ts.expect(ts.request(http.MethodGet, base+"/members", "bob", nil), http.StatusOK), &members)
+	if len(members) != 2 {
+		t.Errorf("Expected 2 members, got %d", len(members))
+	}
+
+	// API keys act for the org
+	var key struct {
+		Key string `json:"key"`
+		ID  string `json:"id"`
+	}
+	ts.decode(ts.expect(ts.request(http.MethodPost, base+"/keys", "alice", map[string]string{"name": "ci"}), http.StatusCreated), &key)
+	ts.expect(ts.request(http.MethodGet, "/api/games/"+gameID, "", nil, "X-API-Key: "+key.Key), http.StatusOK)
+	ts.expect(ts.request(http.MethodPost, "/api/orgs", "", map[string]string{"name": "Other"}, "X-API-Key: "+key.Key), http.StatusForbidden)
+
+	var games []string
+	ts.decode(ts.expect(ts.request(http.MethodGet, base+"/games", "", nil, "X-API-Key: "+key.Key), http.StatusOK), &games)
+	if len(games) != 1 || games[0] != gameID {
+		t.Errorf("Expected the org's game, got %v", games)
+	}
+	ts.expect(ts.request(http.MethodGet, base+"/analytics", "alice", nil), http.StatusOK)
+
+	// Removed members lose access
+	ts.expect(ts.request(http.MethodDelete, base+"/members/bob", "alice", nil), http.StatusOK)
+	ts.expect(ts.request(http.MethodGet, "/api/games/"+gameID, "bob", nil), http.StatusForbidden)
+
+	// Quotas cap the org's games
+	ts.expect(ts.request(http.MethodPut, "/api/admin/orgs/"+org.ID+"/quota", testAdmin, map[string]int{"max_games": 1}), http.StatusOK)
+	ts.expect(ts.request(http.MethodPost, base+"/games", "alice", map[string]interface{}{"seed": 4}), http.StatusForbidden)
+}
+
+// TestAdminGames tests the admin game management endpoints
+func TestAdminGames(t *testing.T) {
+	ts := newTestServer(t)
+	gameID := ts.createGame()
+	base := "/api/admin/games/" + gameID
+
+	ts.expect(ts.request(http.MethodPost, base+"/save", testAdmin, nil), http.StatusOK)
+	ts.expect(ts.request(http.MethodPost, base+"/recompile", testAdmin, nil), http.StatusOK)
+	ts.expect(ts.request(http.MethodPost, base+"/requeue", testAdmin, nil), http.StatusOK)
+	ts.expect(ts.request(http.MethodGet, base+"/prompt?agent=writer", testAdmin, nil), http.StatusOK)
+
+	stat := ts.statID(gameID)
+	ts.expect(ts.request(http.MethodPatch, base+"/state", "public", map[string]interface{}{"day": 3}), http.StatusForbidden)
+	ts.expect(ts.request(http.MethodPatch, base+"/state", testAdmin, map[string]interface{}{"rng_seed": 1}), http.StatusUnprocessableEntity)
+	res := ts.expect(ts.request(http.MethodPatch, base+"/state", testAdmin, map[string]interface{}{
+		"stats": map[string]int{stat: 12},
+	}), http.StatusOK)
+	var patched struct {
+		Patched []string `json:"patched"`
+	}
+	ts.decode(res, &patched)
+	if len(patched.Patched) != 1 || ts.engine(gameID).GetState().Stats[stat] != 12 {
+		t.Errorf("Expected %s patched to 12, got %s", stat, res.Data)
+	}
+	// The patch is autosaved, so a crash keeps it
+	crashed := &testServer{t: t, Server: NewServer(ts.db)}
+	t.Cleanup(crashed.Close)
+	ts.expect(crashed.request(http.MethodGet, "/api/games/"+gameID, "public", nil), http.StatusOK)
+	if got := crashed.engine(gameID).GetState().Stats[stat]; got != 12 {
+		t.Errorf("Expected the patched %s to survive a crash, got %d", stat, got)
+	}
+
+	res = ts.expect(ts.request(http.MethodGet, base+"/dump", testAdmin, nil), http.StatusOK)
+	var dump struct {
+		GameID string                 `json:"game_id"`
+		Engine map[string]interface{} `json:"engine"`
+	}
+	if err := json.Unmarshal(res.Body, &dump); err != nil || dump.GameID != gameID || dump.Engine["state"] == nil {
+		t.Errorf("Expected a dump of the game, got %s", res.Body)
+	}
+	res = ts.expect(ts.request(http.MethodGet, base+"/dump?format=zip", testAdmin, nil), http.StatusOK)
+	if res.Header.Get("Content-Type") != "application/zip" {
+		t.Errorf("Expected a zip, got %s", res.Header.Get("Content-Type"))
+	}
+
+	// Unloading saves the game; the next request restores it
+	ts.expect(ts.request(http.MethodPost, base+"/unload", testAdmin, nil), http.StatusOK)
+	ts.expect(ts.request(http.MethodPost, base+"/unload", testAdmin, nil), http.StatusNotFound)
+	ts.expect(ts.request(http.MethodPost, base+"/save", testAdmin, nil), http.StatusNotFound)
+	ts.expect(ts.request(http.MethodGet, "/api/games/"+gameID, "public", nil), http.StatusOK)
+
+	var loaded struct {
+		Stats RegistryStats `json:"stats"`
+		Games []LoadedGame  `json:"games"`
+	}
+	ts.decode(ts.expect(ts.request(http.MethodGet, "/api/admin/games", testAdmin, nil), http.StatusOK), &loaded)
+	if loaded.Stats.Loaded != 1 || loaded.Stats.Loads != 1 || loaded.Stats.Evictions != 1 || len(loaded.Games) != 1 {
+		t.Errorf("Expected one restored game after one eviction, got %+v", loaded)
+	}
+}
+
+// TestTelemetryExport tests that exported traces keep choice text only for
+// players who opted in to telemetry
+func TestTelemetryExport(t *testing.T) {
+	ts := newTestServer(t)
+	gameID := ts.createGame()
+	base := "/api/games/" + gameID
+	stat := ts.addCards(gameID, "telemetry_a")
+	ts.expect(ts.request(http.MethodPost, base+"/draw", "public", nil), http.StatusOK)
+	ts.expect(ts.request(http.MethodPost, base+"/resolve", "public",
+		map[string]string{"card_id": "telemetry_a", "direction": "left"}), http.StatusOK)
+	ts.expect(ts.request(http.MethodPost, "/api/admin/games/"+gameID+"/save", testAdmin, nil), http.StatusOK)
+
+	export := func() db.TelemetryTrace {
+		t.Helper()
+		res := ts.expect(ts.request(http.MethodGet, "/api/admin/telemetry/export", testAdmin, nil), http.StatusOK)
+		var trace db.TelemetryTrace
+		if err := json.Unmarshal(res.Body, &trace); err != nil {
+			t.Fatalf("Expected one trace, got %s", res.Body)
+		}
+		return trace
+	}
+
+	ts.expect(ts.request(http.MethodGet, "/api/admin/telemetry/export", "public", nil), http.StatusForbidden)
+	trace := export()
+	if trace.Game == gameID || trace.Player == "" || trace.Player == "public" || trace.OptedIn {
+		t.Errorf("Expected hashed IDs and no opt-in, got %+v", trace)
+	}
+	if len(trace.Choices) != 1 || trace.Choices[0].Direction != "left" || trace.Choices[0].StatChanges[stat] != -5 {
+		t.Fatalf("Expected the left choice costing 5 %s, got %+v", stat, trace.Choices)
+	}
+	if trace.Choices[0].CardID != "" || trace.Choices[0].Choice != "" {
+		t.Errorf("Expected no card text without opt-in, got %+v", trace.Choices[0])
+	}
+
+	ts.expect(ts.request(http.MethodPatch, "/api/me/preferences", "public", map[string]bool{"telemetry": true}), http.StatusOK)
+	trace = export()
+	if !trace.OptedIn || len(trace.Choices) != 1 || trace.Choices[0].CardID != "telemetry_a" || trace.Choices[0].Choice != "Left" {
+		t.Errorf("Expected card text after opt-in, got %+v", trace)
+	}
+}
+
+// TestValidationFailureAnalytics tests that LLM validation failures show up
+// in org analytics and the admin view
+func TestValidationFailureAnalytics(t *testing.T) {
+	ts := newTestServer(t)
+
+	var org struct {
+		ID string `json:"id"`
+	}
+	ts.decode(ts.expect(ts.request(http.MethodPost, "/api/orgs", "alice", map[string]string{"name": "Guild"}), http.StatusCreated), &org)
+
+	now := time.Now().UTC()
+	for _, f := range []agents.ValidationFailure{
+		{Agent: agents.AgentArchitect, Class: agents.FailureInvalidJSON, Model: "m", PromptVariant: "v1", OrgID: org.ID, CreatedAt: now},
+		{Agent: agents.AgentArchitect, Class: agents.FailureInvalidJSON, Model: "m", PromptVariant: "v1", OrgID: org.ID, CreatedAt: now},
+		{Agent: agents.AgentWriter, Class: agents.FailureSchema, Model: "m", PromptVariant: "v2", CreatedAt: now},
+		{Agent: agents.AgentWriter, Class: agents.FailureSchema, Model: "m", PromptVariant: "v2", CreatedAt: now.Add(-48 * time.Hour)},
+	} {
+		if err := ts.db.RecordValidationFailure(f); err != nil {
+			t.Fatalf("Failed to record failure: %v", err)
+		}
+	}
+
+	var analytics struct {
+		Failures []agents.ValidationFailureCount `json:"llm_validation_failures_last_7_days"`
+	}
+	ts.decode(ts.expect(ts.request(http.MethodGet, "/api/orgs/"+org.ID+"/analytics", "alice", nil), http.StatusOK), &analytics)
+	if len(analytics.Failures) != 1 || analytics.Failures[0].Count != 2 || analytics.Failures[0].Agent != agents.AgentArchitect {
+		t.Fatalf("Expected the org's two architect failures, got %+v", analytics.Failures)
+	}
+	if analytics.Failures[0].LastSeen.IsZero() {
+		t.Error("Expected a last seen time")
+	}
+
+	var admin struct {
+		Failures []agents.ValidationFailureCount `json:"failures"`
+	}
+	ts.decode(ts.expect(ts.request(http.MethodGet, "/api/admin/llm/failures", testAdmin, nil), http.StatusOK), &admin)
+	if len(admin.Failures) != 2 || admin.Failures[1].Count != 1 {
+		t.Fatalf("Expected both groups from the last day, got %+v", admin.Failures)
+	}
+	ts.decode(ts.expect(ts.request(http.MethodGet, "/api/admin/llm/failures?hours=72", testAdmin, nil), http.StatusOK), &admin)
+	if admin.Failures[0].Count != 2 || admin.Failures[1].Count != 2 {
+		t.Fatalf("Expected the older failure within 72 hours, got %+v", admin.Failures)
+	}
+	ts.expect(ts.request(http.MethodGet, "/api/admin/llm/failures?hours=0", testAdmin, nil), http.StatusBadRequest)
+	ts.expect(ts.request(http.MethodGet, "/api/admin/llm/failures", "alice", nil), http.StatusForbidden)
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/db"
+)
+
+// Page sizes of list endpoints
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// parsePage reads the cursor and limit query parameters and writes an error
+// if they are invalid. The cursor is the opaque position of the previous
+// page's last item.
+func parsePage(w http.ResponseWriter, r *http.Request) (db.Page, bool) {
+	page := db.Page{Limit: defaultPageLimit}
+	if raw := r.URL.Query().Get("cursor"); raw != "" {
+		after, err := decodeCursor(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid cursor")
+			return page, false
+		}
+		page.After = after
+	}
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxPageLimit))
+			return page, false
+		}
+		page.Limit = limit
+	}
+	return page, true
+}
+
+// parseOffsetPage reads the limit and offset query parameters of endpoints
+// paged by position and writes an error if they are invalid
+func parseOffsetPage(w http.ResponseWriter, r *http.Request) (limit, offset int, ok bool) {
+	page, ok := parsePage(w, r)
+	if !ok {
+		return 0, 0, false
+	}
+	if raw := r.URL.Query().Get("offset"); raw != "" {
+		var err error
+		if offset, err = strconv.Atoi(raw); err != nil || offset < 0 {
+			writeError(w, http.StatusBadRequest, "offset must be a non-negative integer")
+			return 0, 0, false
+		}
+	}
+	return page.Limit, offset, true
+}
+
+// encodeCursor returns the cursor of the page after a position, or "" when
+// there is none
+func encodeCursor(next int64) string {
+	if next == 0 {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(next, 10)))
+}
+
+// decodeCursor returns the position a cursor continues after
+func decodeCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	after, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || after < 1 {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	return after, nil
+}
+
+// writePage writes a page of items, with the cursor of the next page in the
+// response envelope
+func writePage[T any](w http.ResponseWriter, items []T, next int64) {
+	writeJSON(w, http.StatusOK, Response{
+		Success:    true,
+		Data:       items,
+		NextCursor: encodeCursor(next),
+	})
+}
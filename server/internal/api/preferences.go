@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
+	"github.com/qninhdt/world-card-ai-2/server/internal/db"
+	"github.com/qninhdt/world-card-ai-2/server/internal/validation"
+)
+
+// getPreferences returns the caller's settings
+func (s *Server) getPreferences(w http.ResponseWriter, r *http.Request) {
+	if getKeyOrgID(r) != "" {
+		writeError(w, http.StatusForbidden, "API keys have no preferences")
+		return
+	}
+
+	prefs, err := s.db.GetUserPreferences(getUserID(r))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load preferences")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    prefs,
+	})
+}
+
+// updatePreferences changes the settings present in the body and keeps
+// the rest
+func (s *Server) updatePreferences(w http.ResponseWriter, r *http.Request) {
+	if getKeyOrgID(r) != "" {
+		writeError(w, http.StatusForbidden, "API keys have no preferences")
+		return
+	}
+
+	userID := getUserID(r)
+	prefs, err := s.db.GetUserPreferences(userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load preferences")
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(prefs); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// SECURITY FIX: Language and rating are written into LLM prompts
+	if err := validation.ValidateLanguage(prefs.Language); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validation.ValidateContentRating(prefs.ContentRating); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validation.ValidateHintLevel(prefs.HintLevel); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := s.db.SetUserPreferences(userID, prefs); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to save preferences")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    prefs,
+	})
+}
+
+// ownerPreferences returns the generation settings of a game's owner, or
+// none when they cannot be loaded
+func (s *Server) ownerPreferences(gameID string) agents.Preferences {
+	owner, err := s.db.GetGameOwner(gameID)
+	if err != nil {
+		return agents.Preferences{}
+	}
+	return s.userPreferences(owner)
+}
+
+// userPreferences returns a user's generation settings, or none when they
+// cannot be loaded
+func (s *Server) userPreferences(userID string) agents.Preferences {
+	prefs, err := s.db.GetUserPreferences(userID)
+	if err != nil {
+		return agents.Preferences{}
+	}
+	return prefs.Generation()
+}
+
+// hidesHints reports whether the caller turned plot hints off
+func (s *Server) hidesHints(r *http.Request) bool {
+	prefs, err := s.db.GetUserPreferences(getUserID(r))
+	return err == nil && prefs.HintLevel == db.HintsNone
+}
+
+// cardsForReader returns drawn cards as the caller reads them: with the
+// plain-language descriptions when they turned simple text on
+func (s *Server) cardsForReader(r *http.Request, drawn []cards.Card) []cards.Card {
+	prefs, err := s.db.GetUserPreferences(getUserID(r))
+	if err != nil || !prefs.SimpleText {
+		return drawn
+	}
+	shown := make([]cards.Card, len(drawn))
+	for i, card := range drawn {
+		shown[i] = cards.Simplified(card)
+	}
+	return shown
+}
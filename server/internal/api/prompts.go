@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+	"github.com/qninhdt/world-card-ai-2/server/internal/validation"
+)
+
+// writerJobs converts a game's queued jobs to Writer jobs
+func writerJobs(engine *game.GameEngine) []agents.CardGenJob {
+	pending := engine.PendingJobs()
+	jobs := make([]agents.CardGenJob, 0, len(pending))
+	for _, job := range pending {
+		jobs = append(jobs, agents.CardGenJob{Type: job.JobType, Context: job.Context})
+	}
+	return jobs
+}
+
+// adminReloadPrompts re-reads prompt templates from disk
+func (s *Server) adminReloadPrompts(w http.ResponseWriter, r *http.Request) {
+	var req ReloadPromptsRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    agents.ReloadPrompts(req.Dir),
+	})
+}
+
+// adminRenderPrompt renders an agent's prompts for a game's current context
+// without calling the model
+func (s *Server) adminRenderPrompt(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	engine, ok := s.games.GetOrLoad(gameID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	var systemPrompt, userPrompt string
+	switch agent := r.URL.Query().Get("agent"); agent {
+	case "", "writer":
+		systemPrompt, userPrompt = agents.RenderWriterPrompts(writerJobs(engine), s.generationContext(gameID, engine))
+	case "architect":
+		systemPrompt, userPrompt = agents.RenderArchitectPrompts(r.URL.Query().Get("theme"), len(engine.GetState().Stats), s.ownerPreferences(gameID))
+	default:
+		writeError(w, http.StatusBadRequest, "Unknown agent")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"system_prompt": systemPrompt,
+			"user_prompt":   userPrompt,
+		},
+	})
+}
+
+// adminGenerationPreview returns the exact Writer request that the game's
+// current job queue would produce, without spending tokens
+func (s *Server) adminGenerationPreview(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	engine, ok := s.games.GetOrLoad(gameID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	jobs := writerJobs(engine)
+	req := agents.BuildWriterRequest(jobs, s.generationContext(gameID, engine))
+
+	prompts := make(map[string]string, len(req.Messages))
+	for _, msg := range req.Messages {
+		prompts[msg.Role] = msg.Content
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"jobs":          jobs,
+			"would_call":    len(jobs) > 0, // GenerateCards skips empty batches
+			"system_prompt": prompts["system"],
+			"user_prompt":   prompts["user"],
+			"request":       req,
+		},
+	})
+}
+
+// generationContext is a game's Writer context with its owner's language
+// and content rating
+func (s *Server) generationContext(gameID string, engine *game.GameEngine) map[string]interface{} {
+	ctx := engine.GetGenerationContext()
+	ctx["preferences"] = s.ownerPreferences(gameID)
+	return ctx
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/qninhdt/world-card-ai-2/server/internal/db"
+	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+	"github.com/qninhdt/world-card-ai-2/server/internal/story"
+	"github.com/qninhdt/world-card-ai-2/server/internal/validation"
+)
+
+// Recap limits
+const (
+	maxRecapPoints = 200 // stat chart samples
+	maxRecapCards  = 10  // key cards of the last life
+)
+
+// recapPoint is one sample of the recap's stat chart
+type recapPoint struct {
+	Day    int            `json:"day"`
+	Season int            `json:"season"`
+	Year   int            `json:"year_in_game"`
+	Life   int            `json:"life"`
+	Stats  map[string]int `json:"stats"`
+}
+
+// recapDeath is one life's end
+type recapDeath struct {
+	Life     int    `json:"life"`
+	Cause    string `json:"cause"`
+	Day      int    `json:"day"`
+	Season   int    `json:"season"`
+	Year     int    `json:"year_in_game"`
+	Obituary string `json:"obituary,omitempty"`
+}
+
+// recapCard is a choice that shaped the last life
+type recapCard struct {
+	Title  string `json:"title"`
+	Choice string `json:"choice"`
+}
+
+// recapEnding is the ending a run reached, without its plot text
+type recapEnding struct {
+	ID   string `json:"id"`
+	Tier string `json:"tier"`
+}
+
+// recap is the public, spoiler-trimmed story of a run: no hidden stats,
+// tags or plot text
+type recap struct {
+	World    string       `json:"world"`
+	Era      string       `json:"era"`
+	Player   string       `json:"player"`
+	Lives    int          `json:"lives"`
+	Days     int          `json:"days"`
+	IsAlive  bool         `json:"is_alive"`
+	KeyCards []recapCard  `json:"key_cards"`
+	Chart    []recapPoint `json:"chart"`
+	Deaths   []recapDeath `json:"deaths"`
+	Ending   *recapEnding `json:"ending,omitempty"`
+}
+
+// buildRecap derives a run's recap from its saved history and current state
+func buildRecap(engine *game.GameEngine, history []db.StatPoint) *recap {
+	state := engine.GetPlayerState()
+
+	r := &recap{
+		World:    state.WorldName,
+		Era:      state.Era,
+		Player:   state.PlayerChar.Name,
+		Lives:    state.LifeNumber,
+		Days:     state.GetElapsedDays(),
+		IsAlive:  state.IsAlive,
+		KeyCards: make([]recapCard, 0, maxRecapCards),
+		Chart:    make([]recapPoint, 0, maxRecapPoints),
+		Deaths:   make([]recapDeath, 0),
+	}
+
+	log := state.LifeLog
+	if len(log) > maxRecapCards {
+		log = log[len(log)-maxRecapCards:]
+	}
+	for _, entry := range log {
+		r.KeyCards = append(r.KeyCards, recapCard{Title: entry.Title, Choice: entry.Choice})
+	}
+
+	// Sample the chart evenly; hidden stats stay out of it
+	step := 1
+	if len(history) > maxRecapPoints {
+		step = (len(history) + maxRecapPoints - 1) / maxRecapPoints
+	}
+	for i := 0; i < len(history); i += step {
+		p := history[i]
+		stats := make(map[string]int, len(state.Stats))
+		for id := range state.Stats {
+			if value, ok := p.Stats[id]; ok {
+				stats[id] = value
+			}
+		}
+		r.Chart = append(r.Chart, recapPoint{Day: p.Day, Season: p.Season, Year: p.Year, Life: p.CurrentLife, Stats: stats})
+	}
+
+	// A life's first saved state after it ended records its death
+	died := make(map[int]bool)
+	for _, p := range history {
+		if p.IsAlive || p.DeathCause == "" || died[p.CurrentLife] {
+			continue
+		}
+		died[p.CurrentLife] = true
+		death := recapDeath{Life: p.CurrentLife, Cause: p.DeathCause, Day: p.Day, Season: p.Season, Year: p.Year}
+		if state.LastDeath != nil && state.LastDeath.LifeNumber == p.CurrentLife {
+			death.Obituary = state.LastDeath.Obituary
+		}
+		r.Deaths = append(r.Deaths, death)
+	}
+
+	if ending := engine.CheckEnding(); ending != nil {
+		r.Ending = &recapEnding{ID: ending.ID, Tier: story.NormalizeEndingTier(ending.EndingTier)}
+	}
+	return r
+}
+
+// shareGame issues a public recap token for a game
+func (s *Server) shareGame(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+
+	// SECURITY FIX: Validate game ID format
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	// SECURITY FIX: Check game ownership
+	if !s.checkGameOwnership(w, r, gameID) {
+		return
+	}
+
+	token, err := s.db.CreateShareToken(gameID, getUserID(r))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to share game")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"token": token,
+			"recap": "/api/" + APIVersion1 + "/shared/" + token + "/recap",
+		},
+	})
+}
+
+// unshareGame revokes every public recap token of a game
+func (s *Server) unshareGame(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+
+	// SECURITY FIX: Validate game ID format
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	// SECURITY FIX: Check game ownership
+	if !s.checkGameOwnership(w, r, gameID) {
+		return
+	}
+
+	if err := s.db.RevokeShareTokens(gameID); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to revoke share links")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    "Share links revoked",
+	})
+}
+
+// getSharedRecap returns the public recap of a shared game
+func (s *Server) getSharedRecap(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	if !strings.HasPrefix(token, db.ShareTokenPrefix) || len(token) > 64 {
+		writeError(w, http.StatusNotFound, "Recap not found")
+		return
+	}
+
+	gameID, err := s.db.ResolveShareToken(token)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Recap not found")
+		return
+	}
+
+	// The owning instance holds the live engine
+	if s.forwardIfRemote(w, r, gameID) {
+		return
+	}
+
+	engine, ok := s.games.GetOrLoad(gameID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Recap not found")
+		return
+	}
+
+	history, err := s.db.GetStatHistory(gameID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load history")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    buildRecap(engine, history),
+	})
+}
@@ -0,0 +1,354 @@
+package api
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+)
+
+// ErrGameInUse is returned when evicting a game a request is still using
+var ErrGameInUse = errors.New("game is in use")
+
+// GameLoader restores a game that is not in memory
+type GameLoader func(gameID string) (*game.GameEngine, error)
+
+// EvictHook runs before a game leaves memory; an error keeps it loaded
+type EvictHook func(gameID string, engine *game.GameEngine) error
+
+// RegistryStats are the registry's gauges and counters
+type RegistryStats struct {
+	Loaded       int   `json:"loaded"`        // games in memory now
+	Hits         int64 `json:"hits"`          // lookups served from memory
+	Loads        int64 `json:"loads"`         // games restored from the store
+	LoadFailures int64 `json:"load_failures"` // lookups of games that could not be restored
+	Evictions    int64 `json:"evictions"`
+}
+
+// LoadedGame is a game in memory and when it was last used
+type LoadedGame struct {
+	ID         string    `json:"id"`
+	LastAccess time.Time `json:"last_access"`
+}
+
+// registeredGame is a registry entry
+type registeredGame struct {
+	engine     *game.GameEngine
+	lastAccess time.Time
+}
+
+// GameRegistry holds the in-memory game engines, restoring games on first
+// use and tracking how they are used
+type GameRegistry struct {
+	mu        sync.Mutex
+	games     map[string]*registeredGame
+	pins      map[string]int  // requests in flight per game
+	evicting  map[string]bool // games whose eviction hooks are running
+	load      GameLoader
+	hooks     []EvictHook
+	stats     RegistryStats
+	maxLoaded int // 0 for no limit
+}
+
+// NewGameRegistry creates an empty registry that restores games with load
+func NewGameRegistry(load GameLoader) *GameRegistry {
+	return &GameRegistry{
+		games:    make(map[string]*registeredGame),
+		pins:     make(map[string]int),
+		evicting: make(map[string]bool),
+		load:     load,
+	}
+}
+
+// OnEvict adds a hook run before every eviction
+func (g *GameRegistry) OnEvict(hook EvictHook) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.hooks = append(g.hooks, hook)
+}
+
+// SetMaxLoaded caps the games kept in memory; past it, adding a game evicts
+// the least recently used. 0 removes the cap.
+func (g *GameRegistry) SetMaxLoaded(n int) {
+	g.mu.Lock()
+	g.maxLoaded = n
+	victims := g.overflowVictims("")
+	g.mu.Unlock()
+	g.evictVictims(victims)
+}
+
+// Get returns a game only if it is in memory
+func (g *GameRegistry) Get(gameID string) (*game.GameEngine, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	entry, ok := g.games[gameID]
+	if !ok {
+		return nil, false
+	}
+	entry.lastAccess = time.Now()
+	g.stats.Hits++
+	return entry.engine, true
+}
+
+// Peek returns a game only if it is in memory, without counting as an
+// access, so listings do not keep idle games loaded
+func (g *GameRegistry) Peek(gameID string) (*game.GameEngine, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	entry, ok := g.games[gameID]
+	if !ok {
+		return nil, false
+	}
+	return entry.engine, true
+}
+
+// GetOrLoad returns a game, restoring it from the store if it is not in
+// memory
+func (g *GameRegistry) GetOrLoad(gameID string) (*game.GameEngine, bool) {
+	if engine, ok := g.Get(gameID); ok {
+		return engine, true
+	}
+
+	// Load outside the lock; the store may be slow
+	engine, err := g.load(gameID)
+
+	g.mu.Lock()
+	if err != nil {
+		g.stats.LoadFailures++
+		g.mu.Unlock()
+		return nil, false
+	}
+	// Another request may have restored it meanwhile
+	if entry, ok := g.games[gameID]; ok {
+		entry.lastAccess = time.Now()
+		g.stats.Hits++
+		g.mu.Unlock()
+		return entry.engine, true
+	}
+	g.games[gameID] = &registeredGame{engine: engine, lastAccess: time.Now()}
+	g.stats.Loads++
+	victims := g.overflowVictims(gameID)
+	g.mu.Unlock()
+
+	g.evictVictims(victims)
+	return engine, true
+}
+
+// Put registers a newly created game
+func (g *GameRegistry) Put(gameID string, engine *game.GameEngine) {
+	g.mu.Lock()
+	g.games[gameID] = &registeredGame{engine: engine, lastAccess: time.Now()}
+	victims := g.overflowVictims(gameID)
+	g.mu.Unlock()
+	g.evictVictims(victims)
+}
+
+// Pin keeps a game from being evicted until the returned unpin is called,
+// so an engine a request is changing is never saved and dropped midway.
+// A game may be pinned before it is loaded.
+func (g *GameRegistry) Pin(gameID string) (unpin func()) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.pins[gameID]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			if g.pins[gameID]--; g.pins[gameID] <= 0 {
+				delete(g.pins, gameID)
+			}
+		})
+	}
+}
+
+// Evict runs the eviction hooks and drops a game from memory. It reports
+// false if the game was not loaded, and ErrGameInUse while a request has it
+// pinned.
+func (g *GameRegistry) Evict(gameID string) (bool, error) {
+	g.mu.Lock()
+	entry, ok := g.games[gameID]
+	if !ok {
+		g.mu.Unlock()
+		return false, nil
+	}
+	if g.pins[gameID] > 0 || g.evicting[gameID] {
+		g.mu.Unlock()
+		return true, ErrGameInUse
+	}
+	victim := g.claim(gameID, entry)
+	g.mu.Unlock()
+	return true, g.evictVictim(victim)
+}
+
+// Remove drops a game without running the eviction hooks, for games that
+// no longer exist in the store or were saved over elsewhere. It reports
+// false if the game was not loaded.
+func (g *GameRegistry) Remove(gameID string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.games[gameID]; !ok {
+		return false
+	}
+	delete(g.games, gameID)
+	return true
+}
+
+// victim is a game picked for eviction, with the entry and access time it
+// had when picked
+type victim struct {
+	gameID     string
+	entry      *registeredGame
+	lastAccess time.Time
+}
+
+// claim marks a game as being evicted so no other eviction picks it.
+// Caller must hold g.mu.
+func (g *GameRegistry) claim(gameID string, entry *registeredGame) victim {
+	g.evicting[gameID] = true
+	return victim{gameID: gameID, entry: entry, lastAccess: entry.lastAccess}
+}
+
+// evictable reports whether a game may be picked for eviction: no request
+// has it pinned and no other eviction has it. Caller must hold g.mu.
+func (g *GameRegistry) evictable(gameID string) bool {
+	return g.pins[gameID] == 0 && !g.evicting[gameID]
+}
+
+// evictVictim runs the eviction hooks outside the lock, since they save to
+// the store, then drops the game unless a request used it meanwhile or the
+// hooks failed
+func (g *GameRegistry) evictVictim(v victim) error {
+	var err error
+	for _, hook := range g.evictHooks() {
+		if err = hook(v.gameID, v.entry.engine); err != nil {
+			break
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.evicting, v.gameID)
+	if err != nil {
+		return err
+	}
+	if g.games[v.gameID] != v.entry || g.pins[v.gameID] > 0 || v.entry.lastAccess.After(v.lastAccess) {
+		return ErrGameInUse
+	}
+	delete(g.games, v.gameID)
+	g.stats.Evictions++
+	return nil
+}
+
+// evictVictims evicts games picked under the lock, returning the IDs of
+// those that left memory
+func (g *GameRegistry) evictVictims(victims []victim) []string {
+	evicted := make([]string, 0, len(victims))
+	for _, v := range victims {
+		if err := g.evictVictim(v); err == nil {
+			evicted = append(evicted, v.gameID)
+		}
+	}
+	return evicted
+}
+
+// evictHooks returns the hooks to run on eviction
+func (g *GameRegistry) evictHooks() []EvictHook {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.hooks
+}
+
+// EvictIdle evicts every game unused for longer than maxIdle and returns
+// their IDs. Games in use or whose hooks fail stay loaded.
+func (g *GameRegistry) EvictIdle(maxIdle time.Duration) []string {
+	g.mu.Lock()
+	cutoff := time.Now().Add(-maxIdle)
+	victims := make([]victim, 0)
+	for gameID, entry := range g.games {
+		if entry.lastAccess.After(cutoff) || !g.evictable(gameID) {
+			continue
+		}
+		victims = append(victims, g.claim(gameID, entry))
+	}
+	g.mu.Unlock()
+
+	evicted := g.evictVictims(victims)
+	sort.Strings(evicted)
+	return evicted
+}
+
+// overflowVictims picks the least recently used games, other than keep
+// and those in use, whose eviction brings the registry back under its
+// cap. Caller must hold g.mu and evict them after unlocking.
+func (g *GameRegistry) overflowVictims(keep string) []victim {
+	// Games already being evicted count as gone
+	excess := len(g.games) - len(g.evicting) - g.maxLoaded
+	if g.maxLoaded <= 0 || excess <= 0 {
+		return nil
+	}
+	candidates := make([]string, 0, len(g.games))
+	for gameID := range g.games {
+		if gameID != keep && g.evictable(gameID) {
+			candidates = append(candidates, gameID)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return g.games[candidates[i]].lastAccess.Before(g.games[candidates[j]].lastAccess)
+	})
+
+	victims := make([]victim, 0)
+	for _, gameID := range candidates[:min(len(candidates), excess)] {
+		victims = append(victims, g.claim(gameID, g.games[gameID]))
+	}
+	return victims
+}
+
+// StartEvictor evicts games unused for longer than maxIdle every interval
+// until stop is called
+func (g *GameRegistry) StartEvictor(maxIdle, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				g.EvictIdle(maxIdle)
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// Stats returns the registry's current gauges and counters
+func (g *GameRegistry) Stats() RegistryStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stats := g.stats
+	stats.Loaded = len(g.games)
+	return stats
+}
+
+// Loaded lists the games in memory, least recently used first
+func (g *GameRegistry) Loaded() []LoadedGame {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	games := make([]LoadedGame, 0, len(g.games))
+	for gameID, entry := range g.games {
+		games = append(games, LoadedGame{ID: gameID, LastAccess: entry.lastAccess})
+	}
+	sort.Slice(games, func(i, j int) bool { return games[i].LastAccess.Before(games[j].LastAccess) })
+	return games
+}
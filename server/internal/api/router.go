@@ -1,66 +1,276 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
 	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/google/uuid"
 	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/cluster"
 	"github.com/qninhdt/world-card-ai-2/server/internal/db"
 	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+	"github.com/qninhdt/world-card-ai-2/server/internal/story"
 	mw "github.com/qninhdt/world-card-ai-2/server/internal/middleware"
+	"github.com/qninhdt/world-card-ai-2/server/internal/oauth"
+	"github.com/qninhdt/world-card-ai-2/server/internal/tracing"
 	"github.com/qninhdt/world-card-ai-2/server/internal/validation"
 )
 
+// rateLimitSweepInterval is how often idle rate limit clients are forgotten
+const rateLimitSweepInterval = time.Minute
+
+// gameEvictInterval is how often idle games are saved and evicted
+const gameEvictInterval = time.Minute
+
 // Server handles HTTP requests
 type Server struct {
 	router      chi.Router
-	db          *db.DB
-	games       map[string]*game.GameEngine
-	gamesMu     sync.RWMutex
+	db          db.Store
+	games       *GameRegistry
+	orgsMu      sync.Mutex // serializes quota checks with the writes they guard
 	rateLimiter *mw.RateLimiter
+	stopSweeper func()
+	stopEvictor func() // set by EnableEviction
+	images      *imageCache // rendered card images
+	live        *liveHub    // sockets following games
+	worldGen    *WorldGenQueue
+	drafts      *DraftStore // worlds being refined before their game starts
+
+	// Set by EnableClustering; nil on a single instance
+	cluster       *cluster.Ring
+	clusterSecret []byte // signs requests forwarded between instances
+	proxies       map[string]*httputil.ReverseProxy // by owner base URL
+
+	gameLocks bool // set by EnableGameLocks
+
+	autosaves gameMutexes // orders each game's autosaves so an older state never lands last
+
+	// Set by EnableOAuth
+	oauthProviders map[string]oauth.Provider // by name
+	oauthClientURL string
 }
 
 // NewServer creates a new API server
-func NewServer(database *db.DB) *Server {
+func NewServer(database db.Store) *Server {
 	s := &Server{
 		router:      chi.NewRouter(),
 		db:          database,
-		games:       make(map[string]*game.GameEngine),
 		rateLimiter: mw.NewRateLimiter(),
+		images:      newImageCache(),
+		live:        newLiveHub(),
 	}
+	s.stopSweeper = s.rateLimiter.StartSweeper(rateLimitSweepInterval)
+	s.games = NewGameRegistry(s.loadGame)
+	s.drafts = NewDraftStore(architectRefiner)
+	s.worldGen = NewWorldGenQueue(defaultWorldGenConcurrency, architectGenerator, s.createGeneratedGame, s.drafts.Create)
+	// Games leave memory only once saved
+	s.games.OnEvict(func(gameID string, engine *game.GameEngine) error {
+		state, dag, err := engine.SaveCopy()
+		if err != nil {
+			return err
+		}
+		return s.db.SaveGame(gameID, state, dag)
+	})
 
 	s.setupRoutes()
 	return s
 }
 
+// Close stops the server's background work
+func (s *Server) Close() {
+	s.stopSweeper()
+	if s.stopEvictor != nil {
+		s.stopEvictor()
+	}
+}
+
+// EnableEviction bounds the games kept in memory: games unused for maxIdle
+// are saved and evicted (0 keeps them), and past maxLoaded games the least
+// recently used is (0 for no cap). Evicted games are restored on their next
+// request.
+func (s *Server) EnableEviction(maxIdle time.Duration, maxLoaded int) {
+	if s.stopEvictor != nil {
+		s.stopEvictor()
+		s.stopEvictor = nil
+	}
+	if maxIdle > 0 {
+		s.stopEvictor = s.games.StartEvictor(maxIdle, min(gameEvictInterval, maxIdle))
+	}
+	s.games.SetMaxLoaded(maxLoaded)
+}
+
+// UnloadGames saves every game in memory and drops it, so the next start
+// restores each from its latest state. It returns how many games were saved
+// and how many failed to save and stayed loaded.
+func (s *Server) UnloadGames() (saved, failed int) {
+	saved = len(s.games.EvictIdle(0))
+	return saved, s.games.Stats().Loaded
+}
+
+// SetRateLimit changes the limit of a route group (see mw.RouteGroups)
+func (s *Server) SetRateLimit(group string, limit mw.RateLimit) {
+	s.rateLimiter.SetLimit(group, limit)
+}
+
+// TrustProxies sets the proxies whose X-Forwarded-For names the client for
+// rate limits
+func (s *Server) TrustProxies(proxies []*net.IPNet) {
+	s.rateLimiter.SetTrustedProxies(proxies)
+}
+
 // setupRoutes configures all API routes
 func (s *Server) setupRoutes() {
+	s.router.Use(mw.RedactQueryMiddleware(mw.SecretQueryParams...))
 	s.router.Use(middleware.Logger)
+	s.router.Use(tracing.Middleware)
 	s.router.Use(middleware.Recoverer)
 	s.router.Use(middleware.SetHeader("Content-Type", "application/json"))
-	s.router.Use(s.rateLimiter.Middleware)
 	s.router.Use(mw.SecurityHeadersMiddleware)
 	s.router.Use(mw.MaxBodySizeMiddleware(1024 * 1024)) // 1MB max
+	s.router.Use(s.negotiateVersion)
+	// Per address, before any work that runs ahead of authentication
+	s.router.Use(s.rateLimiter.Limit(mw.RouteGroupIP))
+	s.router.Use(s.clusterMiddleware)
+	s.router.Use(s.gamePinMiddleware)
+
+	s.router.Route("/api/"+APIVersion1, s.routesV1)
+}
+
+// routesV1 registers the v1 API. A response shape change ships as a new
+// version mounted beside it, reusing the handlers that did not change.
+func (s *Server) routesV1(r chi.Router) {
+	limit := s.rateLimiter.Limit
 
 	// Public endpoint (no auth required)
-	s.router.Post("/api/games", s.createGame)
-
-	// Protected endpoints (auth required)
-	s.router.Group(func(r chi.Router) {
-		r.Use(mw.AuthMiddleware)
-		r.Get("/api/games", s.listGames)
-		r.Get("/api/games/{id}", s.getGame)
-		r.Post("/api/games/{id}/save", s.saveGame)
-		r.Post("/api/games/{id}/draw", s.drawCards)
-		r.Post("/api/games/{id}/resolve", s.resolveCard)
-		r.Post("/api/games/{id}/advance", s.advanceWeek)
-		r.Get("/api/games/{id}/dag", s.getDAG)
-		r.Post("/api/games/{id}/resurrect", s.resurrect)
-		r.Get("/api/games/{id}/history", s.getHistory)
+	r.Group(func(r chi.Router) {
+		r.Use(limit(mw.RouteGroupDefault))
+		r.Get("/openapi.json", s.getOpenAPI)
+		r.Post("/auth/signup", s.signup)
+		r.Post("/auth/login", s.login)
+		r.Post("/auth/refresh", s.refreshSession)
+		r.Get("/auth/oauth", s.listOAuthProviders)
+		r.Get("/auth/oauth/{provider}", s.startOAuth)
+		r.Get("/auth/oauth/{provider}/callback", s.oauthCallback)
+		r.Get("/shared/{token}/recap", s.getSharedRecap)
+	})
+
+	// Protected endpoints (auth required), limited per user by cost
+	r.Group(func(r chi.Router) {
+		r.Use(mw.APIKeyAuthMiddleware(s.db.ResolveAPIKey, s.db.ResolveUserAPIKey, s.db.ResolveSession))
+		r.Use(s.gameLockMiddleware(true))
+
+		r.Group(func(r chi.Router) {
+			r.Use(limit(mw.RouteGroupDefault))
+			r.Get("/me", s.getMe)
+			r.Post("/auth/logout", s.logout)
+			r.Get("/me/sessions", s.listSessions)
+			r.Delete("/me/sessions/{session}", s.revokeSession)
+			r.Get("/me/preferences", s.getPreferences)
+			r.Patch("/me/preferences", s.updatePreferences)
+			r.Get("/me/keys", s.listUserAPIKeys)
+			r.Post("/me/keys", s.createUserAPIKey)
+			r.Delete("/me/keys/{key}", s.revokeUserAPIKey)
+			r.Get("/me/identities", s.listIdentities)
+			r.Delete("/me/identities/{provider}", s.unlinkIdentity)
+			r.Get("/games", s.listGames)
+			r.Get("/games/{id}", s.getGame)
+			r.Delete("/games/{id}", s.deleteGame)
+			r.Post("/games/{id}/save", s.saveGame)
+			r.Post("/games/{id}/status", s.setGameStatus)
+			r.Get("/games/{id}/dag", s.getDAG)
+			r.Get("/games/{id}/history", s.getHistory)
+			r.Get("/games/{id}/diff", s.getDiff)
+			r.Get("/games/{id}/endings", s.getEndings)
+			r.Get("/games/{id}/glossary", s.getGlossary)
+			r.Get("/games/{id}/codex", s.getCodex)
+			r.Post("/games/{id}/share", s.shareGame)
+			r.Delete("/games/{id}/share", s.unshareGame)
+
+			r.Get("/worlds/library", s.listLibrary)
+			r.Get("/worlds/{job}", s.getWorldJob)
+			r.Delete("/jobs/{id}", s.cancelJob)
+			r.Get("/drafts/{draft}", s.getDraft)
+			r.Post("/drafts/{draft}/finalize", s.finalizeDraft)
+			r.Delete("/drafts/{draft}", s.deleteDraft)
+
+			r.Post("/orgs", s.createOrg)
+			r.Get("/orgs", s.listOrgs)
+			r.Get("/orgs/{org}", s.getOrg)
+			r.Get("/orgs/{org}/members", s.listOrgMembers)
+			r.Post("/orgs/{org}/members", s.addOrgMember)
+			r.Delete("/orgs/{org}/members/{user}", s.removeOrgMember)
+			r.Get("/orgs/{org}/keys", s.listAPIKeys)
+			r.Post("/orgs/{org}/keys", s.createAPIKey)
+			r.Delete("/orgs/{org}/keys/{key}", s.revokeAPIKey)
+			r.Get("/orgs/{org}/games", s.listOrgGames)
+			r.Get("/orgs/{org}/analytics", s.getOrgAnalytics)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(limit(mw.RouteGroupPlay))
+			r.Post("/games/{id}/draw", s.drawCards)
+			r.Post("/games/{id}/resolve", s.resolveCard)
+			r.Post("/games/{id}/batch", s.resolveBatch)
+			r.Post("/games/{id}/verify", s.verifyOfflineSession)
+			r.Post("/games/{id}/interlude", s.drawInterlude)
+			r.Post("/games/{id}/resurrect", s.resurrect)
+			r.Post("/games/{id}/undo", s.undoAction)
+			r.Post("/games/{id}/redo", s.redoAction)
+		})
+
+		// Worlds, weeks and images cost the most to produce
+		r.Group(func(r chi.Router) {
+			r.Use(limit(mw.RouteGroupGenerate))
+			r.Post("/games", s.createGame)
+			r.Post("/games/{id}/advance", s.advanceWeek)
+			r.Get("/games/{id}/cards/{cardId}/image", s.getCardImage)
+			r.Post("/worlds", s.submitWorld)
+			r.Post("/drafts/{draft}/messages", s.refineDraft)
+			r.Post("/drafts/{draft}/game", s.startDraftGame)
+			r.Post("/orgs/{org}/games", s.createOrgGame)
+		})
+	})
+
+	// Live game channel; browsers pass their token as a WebSocket subprotocol
+	r.Group(func(r chi.Router) {
+		r.Use(liveTokenFromProtocol)
+		r.Use(mw.APIKeyAuthMiddleware(s.db.ResolveAPIKey, s.db.ResolveUserAPIKey, s.db.ResolveSession))
+		r.Use(limit(mw.RouteGroupDefault))
+		r.Get("/games/{id}/ws", s.gameSocket)
+	})
+
+	// Admin endpoints (ADMIN_USERS only)
+	r.Group(func(r chi.Router) {
+		r.Use(mw.AdminMiddleware(s.db.ResolveSession))
+		r.Use(s.gameLockMiddleware(false))
+		r.Use(limit(mw.RouteGroupDefault))
+		r.Get("/admin/games", s.adminListLoadedGames)
+		r.Post("/admin/games/{id}/save", s.adminSaveGame)
+		r.Post("/admin/games/{id}/unload", s.adminUnloadGame)
+		r.Post("/admin/games/{id}/recompile", s.adminRecompileDAG)
+		r.Post("/admin/games/{id}/requeue", s.adminRequeueJobs)
+		r.Get("/admin/games/{id}/prompt", s.adminRenderPrompt)
+		r.Get("/admin/games/{id}/generation-preview", s.adminGenerationPreview)
+		r.Get("/admin/games/{id}/dump", s.adminDumpGame)
+		r.Patch("/admin/games/{id}/state", s.adminPatchState)
+		r.Post("/admin/prompts/reload", s.adminReloadPrompts)
+		r.Get("/admin/spend", s.adminGetSpend)
+		r.Get("/admin/llm/failures", s.adminGetValidationFailures)
+		r.Get("/admin/telemetry/export", s.adminExportTelemetry)
+		r.Post("/admin/generation/pause", s.adminPauseGeneration)
+		r.Post("/admin/generation/resume", s.adminResumeGeneration)
+		r.Put("/admin/orgs/{org}/quota", s.adminSetOrgQuota)
 	})
 }
 
@@ -71,9 +281,10 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 // Response wraps API responses
 type Response struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success    bool        `json:"success"`
+	Data       interface{} `json:"data,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	NextCursor string      `json:"next_cursor,omitempty"` // list endpoints: cursor of the next page
 }
 
 // writeJSON writes a JSON response
@@ -94,74 +305,288 @@ func writeError(w http.ResponseWriter, status int, message string) {
 	})
 }
 
-// getUserID extracts user ID from context
+// getUserID returns the user the request acts as, or "" on public routes
 func getUserID(r *http.Request) string {
-	userID, ok := r.Context().Value("user_id").(string)
-	if !ok {
+	caller := mw.CallerFrom(r.Context())
+	if caller == nil {
 		return ""
 	}
-	return userID
+	return caller.UserID
+}
+
+// loadGame restores a game from its latest saved snapshot
+func (s *Server) loadGame(gameID string) (*game.GameEngine, error) {
+	state, dag, err := s.db.LoadGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+	return game.LoadGameEngine(gameID, state, dag), nil
+}
+
+// gamePinMiddleware keeps a game in memory while a request for it runs, so
+// eviction never saves and drops an engine midway through a change.
+// Unloading a game is itself an eviction and is not pinned.
+func (s *Server) gamePinMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gameID := gameIDFromPath(r.URL.Path)
+		if gameID == "" || strings.HasSuffix(r.URL.Path, "/unload") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		unpin := s.games.Pin(gameID)
+		defer unpin()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// autosave writes a game through to the store after an action, so a crash
+// loses at most the action in flight. The action already happened, so a
+// failed save is only logged; the next action or eviction saves again.
+func (s *Server) autosave(r *http.Request, gameID string, engine *game.GameEngine) {
+	unlock := s.autosaves.lock(gameID)
+	defer unlock()
+
+	// Save a copy, since other requests may change the game meanwhile
+	state, dag, err := engine.SaveCopy()
+	if err != nil {
+		log.Printf("Failed to autosave game %s: %v", gameID, err)
+		return
+	}
+
+	// The client hanging up must not abort the save
+	ctx := context.WithoutCancel(r.Context())
+	if err := s.db.AutosaveGame(ctx, gameID, state, dag); err != nil {
+		log.Printf("Failed to autosave game %s: %v", gameID, err)
+	}
+}
+
+// gameMutexes holds a mutex per game, so work on one game never waits on
+// another's. A game's mutex is dropped once nobody holds or waits for it.
+type gameMutexes struct {
+	mu    sync.Mutex
+	games map[string]*gameMutex
+}
+
+type gameMutex struct {
+	sync.Mutex
+	refs int // holders and waiters
+}
+
+// lock blocks until the game's mutex is held, returning its unlock
+func (m *gameMutexes) lock(gameID string) (unlock func()) {
+	m.mu.Lock()
+	if m.games == nil {
+		m.games = make(map[string]*gameMutex)
+	}
+	gm := m.games[gameID]
+	if gm == nil {
+		gm = &gameMutex{}
+		m.games[gameID] = gm
+	}
+	gm.refs++
+	m.mu.Unlock()
+
+	gm.Lock()
+	return func() {
+		gm.Unlock()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if gm.refs--; gm.refs == 0 {
+			delete(m.games, gameID)
+		}
+	}
 }
 
 // checkGameOwnership verifies user owns the game
 func (s *Server) checkGameOwnership(w http.ResponseWriter, r *http.Request, gameID string) bool {
-	userID := getUserID(r)
-	if userID == "" {
+	if getUserID(r) == "" {
 		writeError(w, http.StatusUnauthorized, "Missing user ID")
 		return false
 	}
-
-	isOwner, err := s.db.IsGameOwner(gameID, userID)
-	if err != nil || !isOwner {
+	if !s.canAccessGame(r, gameID) {
 		writeError(w, http.StatusForbidden, "Access denied")
 		return false
 	}
 	return true
 }
 
+// canAccessGame reports whether the caller owns a game or shares it
+// through the game's organization
+func (s *Server) canAccessGame(r *http.Request, gameID string) bool {
+	userID := getUserID(r)
+	if userID == "" {
+		return false
+	}
+	isOwner, err := s.db.IsGameOwner(gameID, userID)
+	if err == nil && !isOwner {
+		// Members and API keys of the game's organization share its games
+		isOwner, err = s.canAccessOrgGame(r, gameID)
+	}
+	return err == nil && isOwner
+}
+
 // createGame creates a new game
 func (s *Server) createGame(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Schema *agents.WorldGenSchema `json:"schema"`
+	// SECURITY FIX: The caller owns the game. An API key would leave it
+	// outside its organization's quota, so keys use the organization route.
+	if getKeyOrgID(r) != "" {
+		writeError(w, http.StatusForbidden, "API keys create games through /api/orgs/{org}/games")
+		return
+	}
+
+	req, ok := decodeNewGame(w, r)
+	if !ok {
+		return
 	}
 
+	engine, err := s.startGame(req, getUserID(r))
+	if err != nil {
+		writeStartGameError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, Response{
+		Success: true,
+		Data:    engine.GetGameInfo(),
+	})
+}
+
+// newGame is a decoded create-game request
+type newGame struct {
+	schema   *agents.WorldGenSchema
+	library  string                   // theme to claim a pre-generated world from when schema is nil
+	deck     []map[string]interface{} // the claimed world's starter deck
+	drawMode game.DrawMode
+	rngSeed  *int64 // nil seeds the game from the clock
+}
+
+// decodeNewGame reads a create-game request body and resolves its schema
+// and draw mode
+func decodeNewGame(w http.ResponseWriter, r *http.Request) (*newGame, bool) {
+	var req CreateGameRequest
+
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "Invalid request body")
-		return
+		return nil, false
+	}
+
+	drawMode, err := game.ParseDrawMode(req.DrawMode)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid draw mode")
+		return nil, false
+	}
+
+	// A seed without a schema builds a procedural world (no LLM cost)
+	if req.Schema == nil && req.Seed != nil {
+		req.Schema = agents.BuildDeterministicWorld(*req.Seed, req.Theme)
+	}
+
+	// A library theme is claimed once the game is sure to start
+	if req.Schema == nil && req.Library != "" {
+		if err := validation.ValidateWorldPrompt(req.Library); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid library theme")
+			return nil, false
+		}
+		return &newGame{library: req.Library, drawMode: drawMode, rngSeed: req.RNGSeed}, true
 	}
 
 	if req.Schema == nil {
 		writeError(w, http.StatusBadRequest, "Missing schema")
+		return nil, false
+	}
+	return &newGame{schema: req.Schema, drawMode: drawMode, rngSeed: req.RNGSeed}, true
+}
+
+// writeStartGameError writes the response for a game that failed to start
+func writeStartGameError(w http.ResponseWriter, err error) {
+	if errors.Is(err, db.ErrLibraryEmpty) {
+		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
+	writeError(w, http.StatusInternalServerError, "Failed to create game")
+}
 
-	// SECURITY FIX: Generate server-side game ID (don't trust client)
-	gameID := uuid.New().String()
+// startGame creates a game engine for a new game, registers it and records
+// its owner
+func (s *Server) startGame(req *newGame, ownerID string) (*game.GameEngine, error) {
+	if req.schema == nil {
+		world, err := s.db.ClaimLibraryWorld(req.library)
+		if err != nil {
+			return nil, err
+		}
+		req.schema = world.Schema
+		req.deck = world.Deck
+	}
 
-	engine, err := game.NewGameEngine(gameID, req.Schema)
+	// SECURITY FIX: Generate server-side game ID (don't trust client)
+	gameID := s.newGameID()
+
+	var engine *game.GameEngine
+	var err error
+	if req.rngSeed != nil {
+		engine, err = game.NewSeededGameEngine(gameID, req.schema, *req.rngSeed)
+	} else {
+		engine, err = game.NewGameEngine(gameID, req.schema)
+	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to create game")
-		return
+		return nil, err
+	}
+	if req.drawMode != game.DrawModeWeek {
+		engine.SetDrawMode(req.drawMode)
 	}
 
-	s.gamesMu.Lock()
-	s.games[gameID] = engine
-	s.gamesMu.Unlock()
+	// Starter decks were written for default preferences, so only owners
+	// who kept them start from one; the rest wait for the Writer
+	if len(req.deck) > 0 && s.userPreferences(ownerID) == db.DefaultUserPreferences().Generation() {
+		engine.AddCardsFromDefs(req.deck)
+	}
 
-	// SECURITY FIX: Save game ownership (for public endpoint, use empty user ID)
-	// In production, you might want to require auth for game creation
-	if err := s.db.SaveGameOwnership(gameID, "public"); err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to save game")
-		return
+	s.games.Put(gameID, engine)
+
+	// Owners who asked for polished chronicles get them in new games
+	if owner, err := s.db.GetUserPreferences(ownerID); err == nil {
+		if owner.PolishedChronicle {
+			engine.PolishChronicles()
+		}
+		// and those who asked for the Editor have Writer batches checked
+		if owner.StoryEditor {
+			engine.EnableEditor()
+		}
 	}
 
-	writeJSON(w, http.StatusCreated, Response{
-		Success: true,
-		Data:    engine.GetGameInfo(),
-	})
+	// A player's first game opens with the tutorial, shown only once
+	prefs := s.pendingTutorial(ownerID)
+	if err := s.db.SaveGameOwnership(gameID, ownerID); err != nil {
+		return nil, err
+	}
+	if prefs != nil {
+		engine.StartTutorial()
+		prefs.TutorialDone = true
+		if err := s.db.SetUserPreferences(ownerID, prefs); err != nil {
+			log.Printf("Failed to mark the tutorial done for %s: %v", ownerID, err)
+		}
+	}
+	return engine, nil
 }
 
-// listGames lists all games owned by the user
+// pendingTutorial returns a user's preferences when they have no games yet
+// and the tutorial is not done, or nil. API keys never get it.
+func (s *Server) pendingTutorial(userID string) *db.UserPreferences {
+	if strings.HasPrefix(userID, mw.OrgKeyUserPrefix) {
+		return nil
+	}
+	games, err := s.db.GetUserGames(userID)
+	if err != nil || len(games) > 0 {
+		return nil
+	}
+	prefs, err := s.db.GetUserPreferences(userID)
+	if err != nil || prefs.TutorialDone {
+		return nil
+	}
+	return prefs
+}
+
+// listGames lists summaries of the user's games, a page at a time
 func (s *Server) listGames(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
 	if userID == "" {
@@ -169,15 +594,48 @@ func (s *Server) listGames(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	gameIDs, err := s.db.GetUserGames(userID)
+	opts := db.GameListOptions{Sort: r.URL.Query().Get("sort")}
+	if opts.Sort != "" && !db.ValidGameSort(opts.Sort) {
+		writeError(w, http.StatusBadRequest, "sort must be last_played, created or name")
+		return
+	}
+	var ok bool
+	if opts.Limit, opts.Offset, ok = parseOffsetPage(w, r); !ok {
+		return
+	}
+
+	games, total, err := s.db.ListUserGames(userID, opts)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to list games")
 		return
 	}
 
+	// Games in memory may have moved on since their last save
+	for i := range games {
+		engine, ok := s.games.Peek(games[i].GameID)
+		if !ok {
+			continue
+		}
+		state := engine.GetPlayerState()
+		games[i].WorldName = state.WorldName
+		games[i].Era = state.Era
+		games[i].Day = state.Day
+		games[i].Season = state.Season
+		games[i].CurrentLife = state.CurrentLife
+		games[i].IsAlive = state.IsAlive
+		if state.UpdatedAt.After(games[i].LastPlayedAt) {
+			games[i].LastPlayedAt = state.UpdatedAt
+		}
+	}
+
 	writeJSON(w, http.StatusOK, Response{
 		Success: true,
-		Data:    gameIDs,
+		Data: GameList{
+			Games:  games,
+			Total:  total,
+			Limit:  opts.Limit,
+			Offset: opts.Offset,
+		},
 	})
 }
 
@@ -196,9 +654,7 @@ func (s *Server) getGame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.gamesMu.RLock()
-	engine, ok := s.games[gameID]
-	s.gamesMu.RUnlock()
+	engine, ok := s.games.GetOrLoad(gameID)
 
 	if !ok {
 		writeError(w, http.StatusNotFound, "Game not found")
@@ -209,7 +665,7 @@ func (s *Server) getGame(w http.ResponseWriter, r *http.Request) {
 		Success: true,
 		Data: map[string]interface{}{
 			"info":  engine.GetGameInfo(),
-			"state": engine.GetState(),
+			"state": engine.GetPlayerState(),
 		},
 	})
 }
@@ -229,16 +685,18 @@ func (s *Server) saveGame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.gamesMu.RLock()
-	engine, ok := s.games[gameID]
-	s.gamesMu.RUnlock()
+	engine, ok := s.games.GetOrLoad(gameID)
 
 	if !ok {
 		writeError(w, http.StatusNotFound, "Game not found")
 		return
 	}
 
-	if err := s.db.SaveGame(gameID, engine.GetState(), engine.GetDAG()); err != nil {
+	state, dag, err := engine.SaveCopy()
+	if err == nil {
+		err = s.db.SaveGameContext(r.Context(), gameID, state, dag)
+	}
+	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to save game")
 		return
 	}
@@ -249,6 +707,67 @@ func (s *Server) saveGame(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// deleteGame deletes a game and all its rows, or with ?archive=true
+// soft-deletes it, keeping its latest state in the database
+func (s *Server) deleteGame(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+
+	// SECURITY FIX: Validate game ID format
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	// SECURITY FIX: Check game ownership
+	if !s.checkGameOwnership(w, r, gameID) {
+		return
+	}
+
+	archive := false
+	if v := r.URL.Query().Get("archive"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid archive flag")
+			return
+		}
+		archive = parsed
+	}
+
+	if archive {
+		// Archive the latest state, not the last save
+		if engine, ok := s.games.Get(gameID); ok {
+			state, dag, err := engine.SaveCopy()
+			if err == nil {
+				err = s.db.SaveGame(gameID, state, dag)
+			}
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to save game")
+				return
+			}
+		}
+		if err := s.db.ArchiveGame(gameID); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to archive game")
+			return
+		}
+	} else if err := s.db.DeleteGame(gameID); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to delete game")
+		return
+	}
+
+	// Drop the engine only now, so a concurrent request cannot restore the
+	// game from rows about to go away
+	s.games.Remove(gameID)
+	s.live.publish(gameID, GameEvent{Type: EventGameDeleted, Data: map[string]interface{}{"archived": archive}})
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"id":       gameID,
+			"archived": archive,
+		},
+	})
+}
+
 // drawCards draws cards for the week
 func (s *Server) drawCards(w http.ResponseWriter, r *http.Request) {
 	gameID := chi.URLParam(r, "id")
@@ -264,24 +783,31 @@ func (s *Server) drawCards(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.gamesMu.RLock()
-	engine, ok := s.games[gameID]
-	s.gamesMu.RUnlock()
+	engine, ok := s.games.GetOrLoad(gameID)
 
 	if !ok {
 		writeError(w, http.StatusNotFound, "Game not found")
 		return
 	}
 
+	before := s.watchChange(gameID, engine)
 	cards, err := engine.DrawCards(7)
+	if writeStatusError(w, err) {
+		return
+	}
+	if errors.Is(err, game.ErrWeekOver) {
+		writeError(w, http.StatusConflict, "Week is over, advance to the next week")
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to draw cards")
 		return
 	}
+	s.publishChange(gameID, engine, before, GameEvent{Type: EventCardsDrawn, Data: cards})
 
 	writeJSON(w, http.StatusOK, Response{
 		Success: true,
-		Data:    cards,
+		Data:    s.cardsForReader(r, cards),
 	})
 }
 
@@ -300,10 +826,7 @@ func (s *Server) resolveCard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req struct {
-		CardID    string `json:"card_id"`
-		Direction string `json:"direction"`
-	}
+	var req ResolveCardRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "Invalid request body")
@@ -321,20 +844,207 @@ func (s *Server) resolveCard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.gamesMu.RLock()
-	engine, ok := s.games[gameID]
-	s.gamesMu.RUnlock()
+	engine, ok := s.games.GetOrLoad(gameID)
 
 	if !ok {
 		writeError(w, http.StatusNotFound, "Game not found")
 		return
 	}
 
-	result, err := engine.ResolveCard(req.CardID, req.Direction)
+	before := s.watchChange(gameID, engine)
+	result, err := engine.ResolveCardContext(r.Context(), req.CardID, req.Direction)
+	if writeStatusError(w, err) {
+		return
+	}
+	var reqErr *game.RequirementError
+	if errors.As(err, &reqErr) {
+		writeError(w, http.StatusUnprocessableEntity, "Requirement not met: "+reqErr.Requires)
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusBadRequest, "Failed to resolve card")
 		return
 	}
+	s.autosave(r, gameID, engine)
+	if s.hidesHints(r) {
+		result.PendingPlots = nil
+	}
+	s.publishChange(gameID, engine, before, GameEvent{Type: EventCardResolved, Data: map[string]interface{}{
+		"card_id": req.CardID,
+		"result":  result,
+	}})
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// resolveBatch applies choices queued offline, all or nothing
+func (s *Server) resolveBatch(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+
+	// SECURITY FIX: Validate game ID format
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	// SECURITY FIX: Check game ownership
+	if !s.checkGameOwnership(w, r, gameID) {
+		return
+	}
+
+	var req ResolveBatchRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if len(req.Steps) == 0 || len(req.Steps) > game.MaxBatchSteps {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("A batch must have 1 to %d steps", game.MaxBatchSteps))
+		return
+	}
+
+	// SECURITY FIX: Validate card IDs and directions
+	for _, step := range req.Steps {
+		if err := validation.ValidateCardID(step.CardID); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid card ID")
+			return
+		}
+		if err := validation.ValidateDirection(step.Direction); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid direction")
+			return
+		}
+	}
+
+	engine, ok := s.games.GetOrLoad(gameID)
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	before := s.watchChange(gameID, engine)
+	result, err := engine.ResolveBatch(req.Steps)
+	if writeStatusError(w, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to resolve batch")
+		return
+	}
+
+	if !result.Applied {
+		// Report every step so the client can replay from the failure
+		status := http.StatusUnprocessableEntity
+		if result.Steps[result.FailedStep].Conflict {
+			status = http.StatusConflict
+		}
+		writeJSON(w, status, Response{
+			Success: false,
+			Data:    result,
+			Error:   fmt.Sprintf("Step %d failed; no steps were applied", result.FailedStep),
+		})
+		return
+	}
+
+	s.autosave(r, gameID, engine)
+
+	hideHints := s.hidesHints(r)
+	resolved := make([]GameEvent, 0, len(result.Steps))
+	for _, step := range result.Steps {
+		if hideHints && step.Result != nil {
+			step.Result.PendingPlots = nil
+		}
+		resolved = append(resolved, GameEvent{Type: EventCardResolved, Data: map[string]interface{}{
+			"card_id": step.CardID,
+			"result":  step.Result,
+		}})
+	}
+	s.publishChange(gameID, engine, before, resolved...)
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// verifyOfflineSession replays a decision log from an embedded engine and
+// syncs it only if it reaches the claimed state
+func (s *Server) verifyOfflineSession(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+
+	// SECURITY FIX: Validate game ID format
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	// SECURITY FIX: Check game ownership
+	if !s.checkGameOwnership(w, r, gameID) {
+		return
+	}
+
+	var req VerifySessionRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if len(req.Actions) == 0 || len(req.Actions) > game.MaxOfflineActions || req.State == nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("A decision log of 1 to %d actions and a claimed state are required", game.MaxOfflineActions))
+		return
+	}
+
+	// SECURITY FIX: Validate card IDs and directions
+	for _, action := range req.Actions {
+		switch action.Action {
+		case game.OfflineAdvance:
+		case game.OfflineResolve:
+			if err := validation.ValidateCardID(action.CardID); err != nil {
+				writeError(w, http.StatusBadRequest, "Invalid card ID")
+				return
+			}
+			if err := validation.ValidateDirection(action.Direction); err != nil {
+				writeError(w, http.StatusBadRequest, "Invalid direction")
+				return
+			}
+		default:
+			writeError(w, http.StatusBadRequest, "Invalid action")
+			return
+		}
+	}
+
+	engine, ok := s.games.GetOrLoad(gameID)
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	before := s.watchChange(gameID, engine)
+	result, err := engine.VerifyOfflineSession(req.Actions, req.State)
+	if writeStatusError(w, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to verify session")
+		return
+	}
+
+	if !result.Accepted {
+		writeJSON(w, http.StatusConflict, Response{
+			Success: false,
+			Data:    result,
+			Error:   "Offline session rejected",
+		})
+		return
+	}
+	s.autosave(r, gameID, engine)
+	s.publishChange(gameID, engine, before)
 
 	writeJSON(w, http.StatusOK, Response{
 		Success: true,
@@ -357,19 +1067,32 @@ func (s *Server) advanceWeek(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.gamesMu.RLock()
-	engine, ok := s.games[gameID]
-	s.gamesMu.RUnlock()
+	engine, ok := s.games.GetOrLoad(gameID)
 
 	if !ok {
 		writeError(w, http.StatusNotFound, "Game not found")
 		return
 	}
 
-	if err := engine.AdvanceWeek(); err != nil {
+	before := s.watchChange(gameID, engine)
+	if err := engine.AdvanceWeekContext(r.Context()); err != nil {
+		if writeStatusError(w, err) {
+			return
+		}
 		writeError(w, http.StatusInternalServerError, "Failed to advance week")
 		return
 	}
+	s.autosave(r, gameID, engine)
+	s.publishChange(gameID, engine, before)
+
+	// Remember reached endings across games of the same world. The week has
+	// already advanced and been saved, so a failure here must not fail it.
+	if ending := engine.CheckEnding(); ending != nil {
+		worldKey := engine.GetState().WorldKey
+		if err := s.db.RecordEndingUnlock(getUserID(r), worldKey, ending.ID, story.NormalizeEndingTier(ending.EndingTier), gameID); err != nil {
+			log.Printf("Failed to record ending %s of game %s: %v", ending.ID, gameID, err)
+		}
+	}
 
 	writeJSON(w, http.StatusOK, Response{
 		Success: true,
@@ -392,9 +1115,7 @@ func (s *Server) getDAG(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.gamesMu.RLock()
-	engine, ok := s.games[gameID]
-	s.gamesMu.RUnlock()
+	engine, ok := s.games.GetOrLoad(gameID)
 
 	if !ok {
 		writeError(w, http.StatusNotFound, "Game not found")
@@ -408,6 +1129,50 @@ func (s *Server) getDAG(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// drawInterlude deals the cards of the scene between two lives
+func (s *Server) drawInterlude(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+
+	// SECURITY FIX: Validate game ID format
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	// SECURITY FIX: Check game ownership
+	if !s.checkGameOwnership(w, r, gameID) {
+		return
+	}
+
+	engine, ok := s.games.GetOrLoad(gameID)
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	if !engine.InInterlude() {
+		writeError(w, http.StatusConflict, "No interlude in progress")
+		return
+	}
+
+	before := s.watchChange(gameID, engine)
+	cards, err := engine.DrawInterlude()
+	if writeStatusError(w, err) {
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to draw interlude")
+		return
+	}
+	s.publishChange(gameID, engine, before, GameEvent{Type: EventCardsDrawn, Data: cards})
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    s.cardsForReader(r, cards),
+	})
+}
+
 // resurrect resurrects the player
 func (s *Server) resurrect(w http.ResponseWriter, r *http.Request) {
 	gameID := chi.URLParam(r, "id")
@@ -423,28 +1188,92 @@ func (s *Server) resurrect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req struct {
-		TempTags map[string]bool `json:"temp_tags"`
-	}
+	var req ResurrectRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	s.gamesMu.RLock()
-	engine, ok := s.games[gameID]
-	s.gamesMu.RUnlock()
+	if req.Loadout != "" {
+		if err := validation.ValidateDirection(req.Loadout); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid loadout")
+			return
+		}
+	}
+
+	engine, ok := s.games.GetOrLoad(gameID)
 
 	if !ok {
 		writeError(w, http.StatusNotFound, "Game not found")
 		return
 	}
 
-	if err := engine.Resurrect(req.TempTags); err != nil {
+	before := s.watchChange(gameID, engine)
+	if err := engine.Resurrect(req.TempTags, req.Loadout); err != nil {
+		if writeStatusError(w, err) {
+			return
+		}
 		writeError(w, http.StatusInternalServerError, "Failed to resurrect")
 		return
 	}
+	s.autosave(r, gameID, engine)
+	s.publishChange(gameID, engine, before)
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    engine.GetGameInfo(),
+	})
+}
+
+// undoAction takes back the game's last action
+func (s *Server) undoAction(w http.ResponseWriter, r *http.Request) {
+	s.timeTravel(w, r, (*game.GameEngine).Undo, game.ErrNothingToUndo)
+}
+
+// redoAction applies the game's last undone action again
+func (s *Server) redoAction(w http.ResponseWriter, r *http.Request) {
+	s.timeTravel(w, r, (*game.GameEngine).Redo, game.ErrNothingToRedo)
+}
+
+// timeTravel moves a game through its undo history with step, answering
+// 409 when step has nowhere to go
+func (s *Server) timeTravel(w http.ResponseWriter, r *http.Request, step func(*game.GameEngine) error, nowhere error) {
+	gameID := chi.URLParam(r, "id")
+
+	// SECURITY FIX: Validate game ID format
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	// SECURITY FIX: Check game ownership
+	if !s.checkGameOwnership(w, r, gameID) {
+		return
+	}
+
+	engine, ok := s.games.GetOrLoad(gameID)
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	before := s.watchChange(gameID, engine)
+	err := step(engine)
+	if writeStatusError(w, err) {
+		return
+	}
+	if errors.Is(err, nowhere) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to restore game")
+		return
+	}
+	s.autosave(r, gameID, engine)
+	s.publishChange(gameID, engine, before)
 
 	writeJSON(w, http.StatusOK, Response{
 		Success: true,
@@ -467,20 +1296,169 @@ func (s *Server) getHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.gamesMu.RLock()
-	engine, ok := s.games[gameID]
-	s.gamesMu.RUnlock()
+	page, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	engine, ok := s.games.GetOrLoad(gameID)
 
 	if !ok {
 		writeError(w, http.StatusNotFound, "Game not found")
 		return
 	}
 
+	snapshots, next, err := s.db.ListSnapshots(gameID, page)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load history")
+		return
+	}
+
 	writeJSON(w, http.StatusOK, Response{
 		Success: true,
 		Data: map[string]interface{}{
 			"game_info": engine.GetGameInfo(),
-			"state":     engine.GetState(),
+			"state":     engine.GetPlayerState(),
+			"snapshots": snapshots,
+		},
+		NextCursor: encodeCursor(next),
+	})
+}
+
+// getDiff returns blackboard changes since a client-known version
+func (s *Server) getDiff(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	if !s.checkGameOwnership(w, r, gameID) {
+		return
+	}
+
+	since, err := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+	if err != nil || since < 0 {
+		writeError(w, http.StatusBadRequest, "Invalid since version")
+		return
+	}
+
+	engine, ok := s.games.GetOrLoad(gameID)
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    engine.Diff(since),
+	})
+}
+
+// getEndings returns the world's ending collection for the caller
+func (s *Server) getEndings(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	if !s.checkGameOwnership(w, r, gameID) {
+		return
+	}
+
+	engine, ok := s.games.GetOrLoad(gameID)
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	unlocks, err := s.db.GetEndingUnlocks(getUserID(r), engine.GetState().WorldKey)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load endings")
+		return
+	}
+
+	unlocked := make(map[string]bool, len(unlocks))
+	for _, u := range unlocks {
+		unlocked[u.EndingID] = true
+	}
+
+	collection := engine.GetEndingCollection(unlocked)
+	unlockedCount := 0
+	for _, entry := range collection {
+		if entry.Unlocked {
+			unlockedCount++
+		}
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"world_key": engine.GetState().WorldKey,
+			"endings":   collection,
+			"unlocked":  unlockedCount,
+			"total":     len(collection),
 		},
 	})
 }
+
+// getGlossary returns the world's stats, tags, NPCs and seasons for tooltips
+func (s *Server) getGlossary(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+
+	// SECURITY FIX: Validate game ID format
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	// SECURITY FIX: Check game ownership
+	if !s.checkGameOwnership(w, r, gameID) {
+		return
+	}
+
+	engine, ok := s.games.GetOrLoad(gameID)
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    engine.GetGlossary(),
+	})
+}
+
+// getCodex returns the NPCs, tags and arcs the player has unlocked
+func (s *Server) getCodex(w http.ResponseWriter, r *http.Request) {
+	gameID := chi.URLParam(r, "id")
+
+	// SECURITY FIX: Validate game ID format
+	if err := validation.ValidateGameID(gameID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid game ID")
+		return
+	}
+
+	// SECURITY FIX: Check game ownership
+	if !s.checkGameOwnership(w, r, gameID) {
+		return
+	}
+
+	engine, ok := s.games.GetOrLoad(gameID)
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    engine.GetCodex(),
+	})
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/qninhdt/world-card-ai-2/server/internal/db"
+	mw "github.com/qninhdt/world-card-ai-2/server/internal/middleware"
+	"github.com/qninhdt/world-card-ai-2/server/internal/validation"
+)
+
+// Profile is the response of GET /api/me
+type Profile struct {
+	User       *db.User `json:"user"`
+	GamesCount int      `json:"games_count"`          // games the user owns
+	SessionID  string   `json:"session_id,omitempty"` // the session the request was made in
+}
+
+// startSession opens a session for user on the requesting device and signs
+// its first access token
+func (s *Server) startSession(r *http.Request, user *db.User) (*AuthToken, error) {
+	refresh, session, err := s.db.CreateSession(user.ID, r.UserAgent(), mw.RefreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	return sessionToken(user, refresh, session)
+}
+
+// sessionToken signs an access token for a session and pairs it with the
+// session's refresh token
+func sessionToken(user *db.User, refresh string, session *db.Session) (*AuthToken, error) {
+	token, err := mw.GenerateSessionToken(user.ID, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthToken{
+		Token:            token,
+		ExpiresAt:        time.Now().Add(mw.AccessTokenTTL).UTC(),
+		RefreshToken:     refresh,
+		RefreshExpiresAt: session.ExpiresAt,
+		SessionID:        session.ID,
+		User:             user,
+	}, nil
+}
+
+// refreshSession trades a refresh token for a new access and refresh token
+func (s *Server) refreshSession(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.RefreshToken == "" {
+		writeError(w, http.StatusBadRequest, "Refresh token is required")
+		return
+	}
+
+	userID, refresh, session, err := s.db.RefreshSession(req.RefreshToken, mw.RefreshTokenTTL)
+	if errors.Is(err, db.ErrSessionExpired) {
+		writeError(w, http.StatusUnauthorized, "Invalid refresh token")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to refresh session")
+		return
+	}
+	user, err := s.db.GetUser(userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to refresh session")
+		return
+	}
+	auth, err := sessionToken(user, refresh, session)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to issue token")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    auth,
+	})
+}
+
+// logout ends the session the request was made in, or every session of the
+// caller with ?all=true
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	caller := mw.CallerFrom(r.Context())
+	if r.URL.Query().Get("all") == "true" {
+		if !requireSessionToken(w, r) {
+			return
+		}
+		n, err := s.db.RevokeUserSessions(caller.UserID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to log out")
+			return
+		}
+		writeJSON(w, http.StatusOK, Response{
+			Success: true,
+			Data:    map[string]int{"revoked": n},
+		})
+		return
+	}
+
+	if caller.SessionID == "" {
+		writeError(w, http.StatusBadRequest, "Not signed in with a session token")
+		return
+	}
+	if err := s.db.RevokeSession(caller.UserID, caller.SessionID); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    map[string]int{"revoked": 1},
+	})
+}
+
+// listSessions lists the caller's active sessions
+func (s *Server) listSessions(w http.ResponseWriter, r *http.Request) {
+	if !requireSessionToken(w, r) {
+		return
+	}
+
+	sessions, err := s.db.ListSessions(getUserID(r))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to list sessions")
+		return
+	}
+	current := mw.CallerFrom(r.Context()).SessionID
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    sessions,
+	})
+}
+
+// revokeSession ends one of the caller's sessions, signing that device out
+func (s *Server) revokeSession(w http.ResponseWriter, r *http.Request) {
+	if !requireSessionToken(w, r) {
+		return
+	}
+
+	// SECURITY FIX: Validate session ID
+	sessionID := chi.URLParam(r, "session")
+	if err := validation.ValidateSessionID(sessionID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	err := s.db.RevokeSession(getUserID(r), sessionID)
+	if errors.Is(err, db.ErrSessionNotFound) {
+		writeError(w, http.StatusNotFound, "Session not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    "Session revoked",
+	})
+}
+
+// getMe returns the caller's profile
+func (s *Server) getMe(w http.ResponseWriter, r *http.Request) {
+	caller := mw.CallerFrom(r.Context())
+	if caller.OrgID != "" {
+		writeError(w, http.StatusForbidden, "Organization API keys have no profile")
+		return
+	}
+
+	user, err := s.db.GetUser(caller.UserID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	games, err := s.db.GetUserGames(caller.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to count games")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: Profile{
+			User:       user,
+			GamesCount: len(games),
+			SessionID:  caller.SessionID,
+		},
+	})
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+)
+
+// spendMonitor writes an error and returns nil if no monitor is installed
+func spendMonitor(w http.ResponseWriter) *agents.SpendMonitor {
+	monitor := agents.GetSpendMonitor()
+	if monitor == nil {
+		writeError(w, http.StatusServiceUnavailable, "Spend tracking is not enabled")
+	}
+	return monitor
+}
+
+// adminGetSpend returns today's LLM spend and the kill switch state
+func (s *Server) adminGetSpend(w http.ResponseWriter, r *http.Request) {
+	monitor := spendMonitor(w)
+	if monitor == nil {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    monitor.Status(),
+	})
+}
+
+// adminPauseGeneration turns the service-wide generation kill switch on
+func (s *Server) adminPauseGeneration(w http.ResponseWriter, r *http.Request) {
+	monitor := spendMonitor(w)
+	if monitor == nil {
+		return
+	}
+
+	monitor.Pause()
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    monitor.Status(),
+	})
+}
+
+// adminResumeGeneration turns the generation kill switch off
+func (s *Server) adminResumeGeneration(w http.ResponseWriter, r *http.Request) {
+	monitor := spendMonitor(w)
+	if monitor == nil {
+		return
+	}
+
+	monitor.Resume()
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    monitor.Status(),
+	})
+}
+
+// adminGetValidationFailures returns the LLM responses that failed to parse
+// or validate over the last ?hours= (default 24), grouped by agent, failure
+// class, model and prompt variant
+func (s *Server) adminGetValidationFailures(w http.ResponseWriter, r *http.Request) {
+	hours := 24
+	if v := r.URL.Query().Get("hours"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > 24*90 {
+			writeError(w, http.StatusBadRequest, "hours must be between 1 and 2160")
+			return
+		}
+		hours = parsed
+	}
+
+	since := time.Now().Add(-time.Duration(hours) * time.Hour)
+	counts, err := s.db.ValidationFailureCounts("", since)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load validation failures")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"since":    since.UTC(),
+			"failures": counts,
+		},
+	})
+}
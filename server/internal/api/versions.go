@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// API versions. Each is mounted at /api/{version}; a response shape change
+// ships as a new version while older clients keep the one they were built
+// against.
+const (
+	APIVersion1 = "v1"
+
+	// defaultAPIVersion serves unversioned /api/ paths without an
+	// API-Version header. It stays at v1 so clients from before versioning
+	// keep working when newer versions ship.
+	defaultAPIVersion = APIVersion1
+
+	// versionHeader asks for a version on unversioned paths, and reports
+	// the version that served every API response
+	versionHeader = "API-Version"
+)
+
+// apiVersions are the versions this server mounts
+var apiVersions = map[string]bool{APIVersion1: true}
+
+// negotiateVersion serves unversioned /api/... paths from the version named
+// in the API-Version header, or the default, by rewriting them to
+// /api/{version}/.... Unknown versions are rejected.
+func (s *Server) negotiateVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, "/api/")
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		segment, _, _ := strings.Cut(rest, "/")
+		if apiVersions[segment] {
+			w.Header().Set(versionHeader, segment)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if isVersionSegment(segment) {
+			writeError(w, http.StatusNotFound, "Unsupported API version")
+			return
+		}
+
+		version := r.Header.Get(versionHeader)
+		if version == "" {
+			version = defaultAPIVersion
+		}
+		if !apiVersions[version] {
+			writeError(w, http.StatusBadRequest, "Unsupported API version")
+			return
+		}
+
+		r.URL.Path = "/api/" + version + "/" + rest
+		if r.URL.RawPath != "" {
+			r.URL.RawPath = "/api/" + version + "/" + strings.TrimPrefix(r.URL.RawPath, "/api/")
+		}
+		w.Header().Set(versionHeader, version)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isVersionSegment reports whether a path segment names an API version,
+// such as v2
+func isVersionSegment(segment string) bool {
+	digits, ok := strings.CutPrefix(segment, "v")
+	if !ok || digits == "" {
+		return false
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// stripVersion removes the /api/{version} prefix of a versioned path,
+// leaving the route within the version
+func stripVersion(path string) string {
+	rest, ok := strings.CutPrefix(path, "/api/")
+	if !ok {
+		return path
+	}
+	version, route, ok := strings.Cut(rest, "/")
+	if !ok || !apiVersions[version] {
+		return path
+	}
+	return "/" + route
+}
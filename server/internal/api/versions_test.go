@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestAPIVersions tests versioned paths and negotiation for unversioned ones
+func TestAPIVersions(t *testing.T) {
+	ts := newTestServer(t)
+	gameID := ts.createGame()
+
+	res := ts.expect(ts.request(http.MethodGet, "/api/v1/games/"+gameID, "public", nil), http.StatusOK)
+	if got := res.Header.Get(versionHeader); got != APIVersion1 {
+		t.Errorf("Expected a versioned path to report v1, got %q", got)
+	}
+
+	// Unversioned paths serve the default, or the version asked for
+	res = ts.expect(ts.request(http.MethodGet, "/api/games/"+gameID, "public", nil), http.StatusOK)
+	if got := res.Header.Get(versionHeader); got != APIVersion1 {
+		t.Errorf("Expected an unversioned path to be served by v1, got %q", got)
+	}
+	ts.expect(ts.request(http.MethodGet, "/api/games/"+gameID, "public", nil, "API-Version: v1"), http.StatusOK)
+	ts.expect(ts.request(http.MethodGet, "/api/games/"+gameID, "public", nil, "API-Version: v9"), http.StatusBadRequest)
+	ts.expect(ts.request(http.MethodGet, "/api/v9/games/"+gameID, "public", nil), http.StatusNotFound)
+
+	// Middleware that reads the game ID sees it behind the version
+	for _, path := range []string{"/api/v1/games/" + gameID + "/save", "/api/v1/admin/games/" + gameID + "/save"} {
+		if got := gameIDFromPath(path); got != gameID {
+			t.Errorf("Expected game ID from %s, got %q", path, got)
+		}
+	}
+	if got := gameIDFromPath("/api/games/" + gameID); got != "" {
+		t.Errorf("Expected no game ID from an unnegotiated path, got %q", got)
+	}
+
+	// Share links point at the versioned recap
+	var share struct {
+		Recap string `json:"recap"`
+	}
+	ts.decode(ts.expect(ts.request(http.MethodPost, "/api/v1/games/"+gameID+"/share", "public", nil), http.StatusCreated), &share)
+	ts.expect(ts.request(http.MethodGet, share.Recap, "", nil), http.StatusOK)
+	if !strings.HasPrefix(share.Recap, "/api/v1/shared/") {
+		t.Errorf("Expected a versioned recap link, got %q", share.Recap)
+	}
+}
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A minimal RFC 6455 server: enough to push JSON text frames to a client
+// and answer its control frames. Data frames from the client are ignored.
+
+// websocketGUID is appended to the client key to derive the accept header
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC65B11"
+
+// bearerProtocol is the subprotocol a browser offers, followed by its token
+const bearerProtocol = "bearer"
+
+// Frame opcodes
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+// Close status codes
+const (
+	wsCloseNormal        = 1000
+	wsCloseProtocol      = 1002
+	wsCloseTooBig        = 1009
+	wsCloseInternal      = 1011
+	wsCloseTryAgainLater = 1013
+)
+
+// wsMaxClientPayload caps a frame from the client; it has nothing to send
+// but control frames
+const wsMaxClientPayload = 4096
+
+// wsConn is an upgraded WebSocket connection
+type wsConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+	wmu  sync.Mutex // one frame written at a time
+}
+
+// isWebSocketUpgrade reports whether a request asks for a WebSocket
+func isWebSocketUpgrade(r *http.Request) bool {
+	return headerHasToken(r.Header, "Connection", "upgrade") &&
+		headerHasToken(r.Header, "Upgrade", "websocket")
+}
+
+// headerHasToken reports whether a comma-separated header lists token
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradeWebSocket completes the opening handshake and takes over the
+// connection. On failure an error response has already been written.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if r.Method != http.MethodGet || !isWebSocketUpgrade(r) {
+		writeError(w, http.StatusUpgradeRequired, "WebSocket upgrade required")
+		return nil, errors.New("not a websocket request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		writeError(w, http.StatusBadRequest, "Unsupported WebSocket version")
+		return nil, errors.New("unsupported websocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		writeError(w, http.StatusBadRequest, "Invalid WebSocket key")
+		return nil, errors.New("invalid websocket key")
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "WebSocket not supported")
+		return nil, err
+	}
+	// Deadlines set by the HTTP server no longer apply
+	conn.SetDeadline(time.Time{})
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	handshake := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n"
+	// Browsers drop a socket whose offered subprotocol is not echoed
+	if headerHasToken(r.Header, "Sec-WebSocket-Protocol", bearerProtocol) {
+		handshake += "Sec-WebSocket-Protocol: " + bearerProtocol + "\r\n"
+	}
+	handshake += "\r\n"
+	if _, err := conn.Write([]byte(handshake)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, br: rw.Reader}, nil
+}
+
+// writeFrame sends one unfragmented, unmasked frame
+func (c *wsConn) writeFrame(opcode byte, payload []byte, timeout time.Duration) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | opcode // FIN
+	switch n := len(payload); {
+	case n <= 125:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(timeout))
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// writeText sends a text message
+func (c *wsConn) writeText(data []byte, timeout time.Duration) error {
+	return c.writeFrame(wsOpText, data, timeout)
+}
+
+// writeClose sends a close frame with a status code and reason
+func (c *wsConn) writeClose(code int, reason string, timeout time.Duration) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	return c.writeFrame(wsOpClose, append(payload, reason...), timeout)
+}
+
+// Close closes the underlying connection
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
+
+// wsCloseError is returned by readFrames when the connection ends with a
+// close frame
+type wsCloseError struct {
+	Code int
+}
+
+func (e *wsCloseError) Error() string {
+	return fmt.Sprintf("websocket closed with status %d", e.Code)
+}
+
+// readFrames reads client frames until the connection closes, answering
+// pings and echoing the close handshake. Each frame must arrive within
+// idle of the previous one.
+func (c *wsConn) readFrames(idle, writeTimeout time.Duration) error {
+	var header [2]byte
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(idle))
+		if _, err := io.ReadFull(c.br, header[:]); err != nil {
+			return err
+		}
+		opcode := header[0] & 0x0F
+		masked := header[1]&0x80 != 0
+		length := uint64(header[1] & 0x7F)
+
+		switch length {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+				return err
+			}
+			length = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+				return err
+			}
+			length = binary.BigEndian.Uint64(ext[:])
+		}
+
+		// Clients must mask every frame
+		if !masked {
+			c.writeClose(wsCloseProtocol, "frames must be masked", writeTimeout)
+			return &wsCloseError{Code: wsCloseProtocol}
+		}
+		if length > wsMaxClientPayload {
+			c.writeClose(wsCloseTooBig, "frame too large", writeTimeout)
+			return &wsCloseError{Code: wsCloseTooBig}
+		}
+
+		var mask [4]byte
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return err
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			return err
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+
+		switch opcode {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload, writeTimeout); err != nil {
+				return err
+			}
+		case wsOpClose:
+			code := wsCloseNormal
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			c.writeClose(code, "", writeTimeout)
+			return &wsCloseError{Code: code}
+		case wsOpPong, wsOpText, wsOpBinary, wsOpContinuation:
+			// Pongs only refresh the deadline; client messages are not used
+		default:
+			c.writeClose(wsCloseProtocol, "unknown opcode", writeTimeout)
+			return &wsCloseError{Code: wsCloseProtocol}
+		}
+	}
+}
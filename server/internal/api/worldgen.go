@@ -0,0 +1,480 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+	"github.com/qninhdt/world-card-ai-2/server/internal/validation"
+)
+
+// World generation job states
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+const (
+	defaultWorldGenConcurrency = 2
+	worldGenTimeout            = 5 * time.Minute // one Architect call
+	worldGenRetention          = time.Hour       // finished jobs stay visible this long
+)
+
+var (
+	// ErrGenerationInFlight is returned when a user already has a world
+	// being generated
+	ErrGenerationInFlight = errors.New("a world generation is already in progress")
+	// ErrJobFinished is returned when cancelling a job that already ended
+	ErrJobFinished = errors.New("job already finished")
+	// ErrJobNotFound is returned for unknown or expired job IDs
+	ErrJobNotFound = errors.New("job not found")
+)
+
+// WorldGenerator builds a world schema from a prompt
+type WorldGenerator func(ctx context.Context, prompt string) (*agents.WorldGenSchema, error)
+
+// WorldCreator starts a game from a generated world and returns its ID.
+// orgID is empty for personal games.
+type WorldCreator func(userID, orgID string, schema *agents.WorldGenSchema) (string, error)
+
+// WorldDrafter keeps a generated world as a draft to refine before its
+// game starts, and returns the draft's ID
+type WorldDrafter func(userID, orgID, prompt string, schema *agents.WorldGenSchema) (string, error)
+
+// WorldGenJob is the record of one queued world generation
+type WorldGenJob struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"-"`
+	OrgID      string     `json:"org_id,omitempty"` // the game lands in this organization
+	Prompt     string     `json:"prompt"`
+	Status     string     `json:"status"`
+	Position   int        `json:"position,omitempty"` // 1-based place in the queue while queued
+	Draft      bool       `json:"draft,omitempty"`    // the world becomes a draft rather than a game
+	GameID     string     `json:"game_id,omitempty"`  // set when done
+	DraftID    string     `json:"draft_id,omitempty"` // set when a draft job is done
+	Error      string     `json:"error,omitempty"`    // set when failed
+	Refunded   bool       `json:"refunded,omitempty"` // cancelling released the org game slot it held
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	cancel   context.CancelFunc // set while running
+	starting bool               // the world is generated and its game or draft is being created
+	prefs    agents.Preferences // the user's language and content rating
+}
+
+// WorldGenQueue runs Architect calls a few at a time, in arrival order,
+// with at most one job in flight per user
+type WorldGenQueue struct {
+	mu            sync.Mutex
+	jobs          map[string]*WorldGenJob
+	pending       []*WorldGenJob    // queued jobs, oldest first
+	inFlight      map[string]string // user ID -> queued or running job ID
+	running       int               // generator calls not yet returned
+	maxConcurrent int
+	generate      WorldGenerator
+	create        WorldCreator
+	draft         WorldDrafter
+}
+
+// NewWorldGenQueue creates a queue running up to maxConcurrent generations
+func NewWorldGenQueue(maxConcurrent int, generate WorldGenerator, create WorldCreator, draft WorldDrafter) *WorldGenQueue {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	return &WorldGenQueue{
+		jobs:          make(map[string]*WorldGenJob),
+		inFlight:      make(map[string]string),
+		maxConcurrent: maxConcurrent,
+		generate:      generate,
+		create:        create,
+		draft:         draft,
+	}
+}
+
+// architectGenerator generates worlds with the Architect agent
+func architectGenerator(ctx context.Context, prompt string) (*agents.WorldGenSchema, error) {
+	return agents.NewArchitectAgent().GenerateWorld(ctx, prompt)
+}
+
+// SetMaxConcurrent changes how many generations may run at once
+func (q *WorldGenQueue) SetMaxConcurrent(n int) {
+	if n < 1 {
+		n = 1
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.maxConcurrent = n
+	q.dispatch()
+}
+
+// Submit queues a world generation for a user, written in their language
+// and content rating. A draft job keeps the world for refinement instead of
+// starting its game. A job with an orgID holds one of the organization's
+// game slots until it finishes.
+func (q *WorldGenQueue) Submit(userID, orgID, prompt string, draft bool, prefs agents.Preferences) (WorldGenJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prune()
+
+	if _, ok := q.inFlight[userID]; ok {
+		return WorldGenJob{}, ErrGenerationInFlight
+	}
+
+	job := &WorldGenJob{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		OrgID:     orgID,
+		Prompt:    prompt,
+		Draft:     draft,
+		Status:    JobQueued,
+		CreatedAt: time.Now(),
+		prefs:     prefs,
+	}
+	q.jobs[job.ID] = job
+	q.pending = append(q.pending, job)
+	q.inFlight[userID] = job.ID
+	q.dispatch()
+	return q.view(job), nil
+}
+
+// Get returns a job with its current queue position
+func (q *WorldGenQueue) Get(jobID string) (WorldGenJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prune()
+
+	job, ok := q.jobs[jobID]
+	if !ok {
+		return WorldGenJob{}, false
+	}
+	return q.view(job), true
+}
+
+// Cancel stops a queued or running job. A running Architect call is
+// aborted through its context.
+func (q *WorldGenQueue) Cancel(jobID string) (WorldGenJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[jobID]
+	if !ok {
+		return WorldGenJob{}, ErrJobNotFound
+	}
+
+	switch job.Status {
+	case JobQueued:
+		for i, pending := range q.pending {
+			if pending == job {
+				q.pending = append(q.pending[:i], q.pending[i+1:]...)
+				break
+			}
+		}
+	case JobRunning:
+		// Too late once the world's game or draft is being created
+		if job.starting {
+			return q.view(job), ErrJobFinished
+		}
+		// The slot frees once the generator returns
+		job.cancel()
+	default:
+		return q.view(job), ErrJobFinished
+	}
+	q.finish(job, JobCancelled)
+	job.Refunded = job.OrgID != ""
+	return q.view(job), nil
+}
+
+// Reserved counts an organization's queued and running jobs, each holding
+// a game slot
+func (q *WorldGenQueue) Reserved(orgID string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := 0
+	for _, job := range q.jobs {
+		if job.OrgID == orgID && (job.Status == JobQueued || job.Status == JobRunning) {
+			n++
+		}
+	}
+	return n
+}
+
+// dispatch starts queued jobs while slots are free. Caller must hold q.mu.
+func (q *WorldGenQueue) dispatch() {
+	for q.running < q.maxConcurrent && len(q.pending) > 0 {
+		job := q.pending[0]
+		q.pending = q.pending[1:]
+
+		ctx := agents.WithPreferences(agents.WithOrg(context.Background(), job.OrgID), job.prefs)
+		ctx, cancel := context.WithTimeout(ctx, worldGenTimeout)
+		now := time.Now()
+		job.Status = JobRunning
+		job.StartedAt = &now
+		job.cancel = cancel
+		q.running++
+		go q.run(ctx, job)
+	}
+}
+
+// run generates one world and starts its game, or keeps it as a draft.
+// Starting the game writes to the store, so only job state is touched
+// under q.mu.
+func (q *WorldGenQueue) run(ctx context.Context, job *WorldGenJob) {
+	schema, err := q.generate(ctx, job.Prompt)
+
+	q.mu.Lock()
+	q.running--
+	job.cancel()
+	// Cancelled jobs were finished by Cancel
+	cancelled := job.Status != JobRunning
+	if !cancelled && err == nil {
+		job.starting = true
+	}
+	q.dispatch()
+	q.mu.Unlock()
+	if cancelled {
+		return
+	}
+
+	// message is what clients see if this step fails
+	var gameID, draftID, message string
+	switch {
+	case err != nil:
+		message = worldGenFailedMessage(err)
+	case job.Draft:
+		draftID, err = q.draft(job.UserID, job.OrgID, job.Prompt, schema)
+		message = "Failed to save the draft"
+	default:
+		gameID, err = q.create(job.UserID, job.OrgID, schema)
+		message = "Failed to start the game"
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err != nil {
+		// The cause can hold internal details, so clients get a summary
+		log.Printf("World generation job %s failed: %v", job.ID, err)
+		job.Error = message
+		q.finish(job, JobFailed)
+		return
+	}
+	job.GameID, job.DraftID = gameID, draftID
+	q.finish(job, JobDone)
+}
+
+// worldGenFailedMessage summarizes an Architect failure for clients
+func worldGenFailedMessage(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "World generation timed out"
+	}
+	return "World generation failed"
+}
+
+// finish records a job's final state. Caller must hold q.mu.
+func (q *WorldGenQueue) finish(job *WorldGenJob, status string) {
+	now := time.Now()
+	job.Status = status
+	job.FinishedAt = &now
+	if q.inFlight[job.UserID] == job.ID {
+		delete(q.inFlight, job.UserID)
+	}
+}
+
+// view copies a job for callers, filling in its queue position. Caller must
+// hold q.mu.
+func (q *WorldGenQueue) view(job *WorldGenJob) WorldGenJob {
+	v := *job
+	v.cancel = nil
+	if job.Status == JobQueued {
+		for i, pending := range q.pending {
+			if pending == job {
+				v.Position = i + 1
+				break
+			}
+		}
+	}
+	return v
+}
+
+// prune forgets jobs that finished longer ago than worldGenRetention.
+// Caller must hold q.mu.
+func (q *WorldGenQueue) prune() {
+	cutoff := time.Now().Add(-worldGenRetention)
+	for id, job := range q.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(cutoff) {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// createGeneratedGame starts a game from a generated world for its
+// requester, in the week draw mode. An organization's quota was checked when
+// the job was queued.
+func (s *Server) createGeneratedGame(userID, orgID string, schema *agents.WorldGenSchema) (string, error) {
+	if schema == nil {
+		return "", fmt.Errorf("architect returned no world")
+	}
+	engine, err := s.startGame(&newGame{schema: schema, drawMode: game.DrawModeWeek}, userID)
+	if err != nil {
+		return "", err
+	}
+	if orgID != "" {
+		if err := s.db.AssignGameToOrg(engine.ID, orgID, userID); err != nil {
+			return "", err
+		}
+	}
+	return engine.ID, nil
+}
+
+// SetWorldGenConcurrency sets how many Architect calls may run at once
+func (s *Server) SetWorldGenConcurrency(n int) {
+	s.worldGen.SetMaxConcurrent(n)
+}
+
+// SubmitWorldRequest is the request body for POST /api/worlds
+type SubmitWorldRequest struct {
+	Prompt string `json:"prompt"`
+	OrgID  string `json:"org_id,omitempty"` // create the game in this organization
+	Draft  bool   `json:"draft,omitempty"`  // keep the world as a draft to refine first
+}
+
+// submitWorld queues an Architect call for a new world. The game, or a
+// draft of its world, is created for the requester when the job completes.
+func (s *Server) submitWorld(w http.ResponseWriter, r *http.Request) {
+	userID := getUserID(r)
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "Missing user ID")
+		return
+	}
+
+	var req SubmitWorldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if err := validation.ValidateWorldPrompt(req.Prompt); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.OrgID != "" {
+		if err := validation.ValidateOrgID(req.OrgID); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid organization ID")
+			return
+		}
+		role, err := s.orgRole(r, req.OrgID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to check membership")
+			return
+		}
+		if role == "" {
+			writeError(w, http.StatusForbidden, "Access denied")
+			return
+		}
+
+		// Hold the lock until the job has taken its slot
+		s.orgsMu.Lock()
+		defer s.orgsMu.Unlock()
+		if !s.checkOrgGameSlot(w, req.OrgID) {
+			return
+		}
+	}
+
+	job, err := s.worldGen.Submit(userID, req.OrgID, req.Prompt, req.Draft, s.userPreferences(userID))
+	if errors.Is(err, ErrGenerationInFlight) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to queue world generation")
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, Response{Success: true, Data: job})
+}
+
+// listLibrary lists the themes with pre-generated worlds waiting, which
+// POST /api/games starts instantly with "library"
+func (s *Server) listLibrary(w http.ResponseWriter, r *http.Request) {
+	themes, err := s.db.LibraryThemes()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load the world library")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{
+		Success: true,
+		Data:    themes,
+	})
+}
+
+// worldJob looks up the job in the URL for its owner, writing an error
+// response when it cannot
+func (s *Server) worldJob(w http.ResponseWriter, r *http.Request, param string) (WorldGenJob, bool) {
+	jobID := chi.URLParam(r, param)
+	if err := validation.ValidateJobID(jobID); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid job ID")
+		return WorldGenJob{}, false
+	}
+
+	userID := getUserID(r)
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "Missing user ID")
+		return WorldGenJob{}, false
+	}
+
+	// Other users' jobs are reported as missing
+	job, ok := s.worldGen.Get(jobID)
+	if !ok || job.UserID != userID {
+		writeError(w, http.StatusNotFound, "Job not found")
+		return WorldGenJob{}, false
+	}
+	return job, true
+}
+
+// getWorldJob reports a world generation job's status and queue position
+func (s *Server) getWorldJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.worldJob(w, r, "job")
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, Response{Success: true, Data: job})
+}
+
+// cancelJob cancels a queued or running generation job. A running
+// Architect call is aborted through its context, and an organization job
+// gives back the game slot it held.
+func (s *Server) cancelJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.worldJob(w, r, "id")
+	if !ok {
+		return
+	}
+
+	job, err := s.worldGen.Cancel(job.ID)
+	switch {
+	case errors.Is(err, ErrJobFinished):
+		writeError(w, http.StatusConflict, "Job already finished")
+		return
+	case errors.Is(err, ErrJobNotFound):
+		writeError(w, http.StatusNotFound, "Job not found")
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "Failed to cancel job")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Response{Success: true, Data: job})
+}
@@ -0,0 +1,385 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/db"
+)
+
+// fakeArchitect stands in for the Architect: each call blocks until the test
+// releases its prompt or the job is cancelled
+type fakeArchitect struct {
+	started chan string
+	release map[string]chan struct{}
+}
+
+func newFakeArchitect(prompts ...string) *fakeArchitect {
+	f := &fakeArchitect{started: make(chan string, len(prompts)), release: make(map[string]chan struct{})}
+	for _, prompt := range prompts {
+		f.release[prompt] = make(chan struct{})
+	}
+	return f
+}
+
+func (f *fakeArchitect) generate(ctx context.Context, prompt string) (*agents.WorldGenSchema, error) {
+	f.started <- prompt
+	select {
+	case <-f.release[prompt]:
+		return agents.BuildDeterministicWorld(1, prompt), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// expectStarted fails unless the next Architect call is for prompt
+func (f *fakeArchitect) expectStarted(t *testing.T, prompt string) {
+	t.Helper()
+	select {
+	case got := <-f.started:
+		if got != prompt {
+			t.Fatalf("Expected %q to start, got %q", prompt, got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected %q to start", prompt)
+	}
+}
+
+// expectIdle fails if another Architect call starts
+func (f *fakeArchitect) expectIdle(t *testing.T) {
+	t.Helper()
+	select {
+	case got := <-f.started:
+		t.Fatalf("Expected no call to start, got %q", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestWorldGenQueue tests the concurrency and per-user limits, queue
+// positions, cancellation and game creation
+func TestWorldGenQueue(t *testing.T) {
+	ts := newTestServer(t)
+	architect := newFakeArchitect("a", "b", "c", "d")
+	ts.worldGen = NewWorldGenQueue(2, architect.generate, ts.createGeneratedGame, ts.drafts.Create)
+
+	submit := func(user, prompt string) WorldGenJob {
+		t.Helper()
+		var job WorldGenJob
+		ts.decode(ts.expect(ts.request(http.MethodPost, "/api/worlds", user, map[string]string{"prompt": prompt}), http.StatusAccepted), &job)
+		return job
+	}
+	get := func(user, jobID string) WorldGenJob {
+		t.Helper()
+		var job WorldGenJob
+		ts.decode(ts.expect(ts.request(http.MethodGet, "/api/worlds/"+jobID, user, nil), http.StatusOK), &job)
+		return job
+	}
+	await := func(user, jobID, status string) WorldGenJob {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			job := get(user, jobID)
+			if job.Status == status {
+				return job
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected job %s to be %s, got %s", jobID, status, job.Status)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	t.Run("Validation", func(t *testing.T) {
+		ts.expect(ts.request(http.MethodPost, "/api/worlds", "", map[string]string{"prompt": "x"}), http.StatusUnauthorized)
+		ts.expect(ts.request(http.MethodPost, "/api/worlds", "alice", map[string]string{"prompt": "  "}), http.StatusBadRequest)
+		ts.expect(ts.request(http.MethodGet, "/api/worlds/bad!id", "alice", nil), http.StatusBadRequest)
+		ts.expect(ts.request(http.MethodGet, "/api/worlds/missing", "alice", nil), http.StatusNotFound)
+	})
+
+	a := submit("alice", "a")
+	architect.expectStarted(t, "a")
+	b := submit("bob", "b")
+	architect.expectStarted(t, "b")
+
+	// Both slots are busy, so later jobs wait in order
+	c := submit("carol", "c")
+	d := submit("dave", "d")
+	architect.expectIdle(t)
+	if c.Status != JobQueued || c.Position != 1 || d.Position != 2 {
+		t.Fatalf("Expected c and d queued at 1 and 2, got %s/%d and %s/%d", c.Status, c.Position, d.Status, d.Position)
+	}
+
+	t.Run("PerUserLimit", func(t *testing.T) {
+		ts.expect(ts.request(http.MethodPost, "/api/worlds", "alice", map[string]string{"prompt": "again"}), http.StatusConflict)
+	})
+
+	t.Run("OwnerOnly", func(t *testing.T) {
+		ts.expect(ts.request(http.MethodGet, "/api/worlds/"+a.ID, "bob", nil), http.StatusNotFound)
+		ts.expect(ts.request(http.MethodDelete, "/api/jobs/"+a.ID, "bob", nil), http.StatusNotFound)
+	})
+
+	t.Run("CancelQueued", func(t *testing.T) {
+		var job WorldGenJob
+		ts.decode(ts.expect(ts.request(http.MethodDelete, "/api/jobs/"+c.ID, "carol", nil), http.StatusOK), &job)
+		if job.Status != JobCancelled {
+			t.Fatalf("Expected cancelled, got %s", job.Status)
+		}
+		if job := get("dave", d.ID); job.Position != 1 {
+			t.Fatalf("Expected d to move up to 1, got %d", job.Position)
+		}
+		ts.expect(ts.request(http.MethodDelete, "/api/jobs/"+c.ID, "carol", nil), http.StatusConflict)
+		architect.expectIdle(t)
+	})
+
+	t.Run("CancelRunning", func(t *testing.T) {
+		ts.expect(ts.request(http.MethodDelete, "/api/jobs/"+b.ID, "bob", nil), http.StatusOK)
+		// The freed slot goes to the next job in line
+		architect.expectStarted(t, "d")
+		if job := get("bob", b.ID); job.Status != JobCancelled || job.GameID != "" {
+			t.Fatalf("Expected b cancelled without a game, got %s %q", job.Status, job.GameID)
+		}
+		// Bob may queue again straight away
+		submit("bob", "b2")
+		ts.expect(ts.request(http.MethodDelete, "/api/jobs/"+b.ID, "bob", nil), http.StatusConflict)
+	})
+
+	t.Run("Done", func(t *testing.T) {
+		close(architect.release["a"])
+		job := await("alice", a.ID, JobDone)
+		if job.GameID == "" {
+			t.Fatal("Expected a game ID")
+		}
+		ts.expect(ts.request(http.MethodGet, "/api/games/"+job.GameID, "alice", nil), http.StatusOK)
+		ts.expect(ts.request(http.MethodGet, "/api/games/"+job.GameID, "bob", nil), http.StatusForbidden)
+		// Alice's slot is free again
+		submit("alice", "next")
+	})
+}
+
+// TestCancelJobRefundsOrgQuota tests that an organization's world job holds
+// a game slot until it is cancelled
+func TestCancelJobRefundsOrgQuota(t *testing.T) {
+	ts := newTestServer(t)
+	architect := newFakeArchitect("guild")
+	ts.worldGen = NewWorldGenQueue(1, architect.generate, ts.createGeneratedGame, ts.drafts.Create)
+
+	var org struct {
+		ID string `json:"id"`
+	}
+	ts.decode(ts.expect(ts.request(http.MethodPost, "/api/orgs", "alice", map[string]string{"name": "Guild"}), http.StatusCreated), &org)
+	ts.expect(ts.request(http.MethodPut, "/api/admin/orgs/"+org.ID+"/quota", testAdmin, map[string]int{"max_games": 1}), http.StatusOK)
+	ts.expect(ts.request(http.MethodPost, "/api/orgs/"+org.ID+"/members", "alice", map[string]string{"user_id": "bob", "role": "member"}), http.StatusOK)
+
+	ts.expect(ts.request(http.MethodPost, "/api/worlds", "mallory", map[string]string{"prompt": "x", "org_id": org.ID}), http.StatusForbidden)
+
+	var job WorldGenJob
+	ts.decode(ts.expect(ts.request(http.MethodPost, "/api/worlds", "alice", map[string]string{"prompt": "guild", "org_id": org.ID}), http.StatusAccepted), &job)
+	architect.expectStarted(t, "guild")
+
+	// The running job holds the only slot
+	ts.expect(ts.request(http.MethodPost, "/api/worlds", "bob", map[string]string{"prompt": "guild", "org_id": org.ID}), http.StatusForbidden)
+	ts.expect(ts.request(http.MethodPost, "/api/orgs/"+org.ID+"/games", "bob", map[string]interface{}{"seed": 1}), http.StatusForbidden)
+
+	var cancelled WorldGenJob
+	ts.decode(ts.expect(ts.request(http.MethodDelete, "/api/jobs/"+job.ID, "alice", nil), http.StatusOK), &cancelled)
+	if cancelled.Status != JobCancelled || !cancelled.Refunded {
+		t.Fatalf("Expected a refunded cancellation, got %s refunded=%v", cancelled.Status, cancelled.Refunded)
+	}
+	ts.expect(ts.request(http.MethodPost, "/api/orgs/"+org.ID+"/games", "bob", map[string]interface{}{"seed": 1}), http.StatusCreated)
+}
+
+// TestWorldGenStartsGamesUnlocked tests that creating a generated world's
+// game leaves the queue free, and that the job can no longer be cancelled
+func TestWorldGenStartsGamesUnlocked(t *testing.T) {
+	ts := newTestServer(t)
+	architect := newFakeArchitect("slow")
+	creating, resume := make(chan struct{}), make(chan struct{})
+	create := func(userID, orgID string, schema *agents.WorldGenSchema) (string, error) {
+		close(creating)
+		<-resume
+		return ts.createGeneratedGame(userID, orgID, schema)
+	}
+	ts.worldGen = NewWorldGenQueue(1, architect.generate, create, ts.drafts.Create)
+
+	var job WorldGenJob
+	ts.decode(ts.expect(ts.request(http.MethodPost, "/api/worlds", "alice", map[string]string{"prompt": "slow"}), http.StatusAccepted), &job)
+	architect.expectStarted(t, "slow")
+	close(architect.release["slow"])
+	<-creating
+
+	// Both requests would wait on the store write if it held the queue lock
+	ts.expect(ts.request(http.MethodGet, "/api/worlds/"+job.ID, "alice", nil), http.StatusOK)
+	ts.expect(ts.request(http.MethodDelete, "/api/jobs/"+job.ID, "alice", nil), http.StatusConflict)
+	close(resume)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status != JobDone {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the job to finish, got %s: %s", job.Status, job.Error)
+		}
+		time.Sleep(10 * time.Millisecond)
+		ts.decode(ts.expect(ts.request(http.MethodGet, "/api/worlds/"+job.ID, "alice", nil), http.StatusOK), &job)
+	}
+	if job.GameID == "" {
+		t.Error("Expected a game ID")
+	}
+}
+
+// fakeRefine stands in for the Architect's refinements: "rename" renames
+// the first NPC and "rekey" gives it a new ID under the same name
+func fakeRefine(ctx context.Context, draft *agents.WorldGenSchema, history []agents.RefinementTurn, message, section string) (*agents.Refinement, error) {
+	npcs := append([]agents.NPCDef(nil), draft.NPCs...)
+	switch message {
+	case "rename":
+		npcs[0].Name = "Renamed"
+	case "rekey":
+		npcs[0].ID = "new_id"
+	}
+	changes, _ := json.Marshal(npcs)
+	edit := &agents.WorldEdit{Reply: "Done: " + message, Changes: map[string]json.RawMessage{"npcs": changes}}
+	return agents.ApplyWorldEdit(draft, edit, section)
+}
+
+// TestWorldDrafts tests generating a world as a draft, refining it turn by
+// turn and starting its game
+func TestWorldDrafts(t *testing.T) {
+	ts := newTestServer(t)
+	architect := newFakeArchitect("draft")
+	ts.drafts = NewDraftStore(fakeRefine)
+	ts.worldGen = NewWorldGenQueue(1, architect.generate, ts.createGeneratedGame, ts.drafts.Create)
+
+	var job WorldGenJob
+	ts.decode(ts.expect(ts.request(http.MethodPost, "/api/worlds", "alice", map[string]interface{}{"prompt": "draft", "draft": true}), http.StatusAccepted), &job)
+	architect.expectStarted(t, "draft")
+	close(architect.release["draft"])
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status != JobDone {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the draft job to finish, got %s", job.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+		ts.decode(ts.expect(ts.request(http.MethodGet, "/api/worlds/"+job.ID, "alice", nil), http.StatusOK), &job)
+	}
+	if job.DraftID == "" || job.GameID != "" {
+		t.Fatalf("Expected a draft and no game, got draft %q and game %q", job.DraftID, job.GameID)
+	}
+
+	path := "/api/drafts/" + job.DraftID
+	var draft WorldDraft
+	ts.decode(ts.expect(ts.request(http.MethodGet, path, "alice", nil), http.StatusOK), &draft)
+	if len(draft.Sections) != len(agents.WorldSections) || len(draft.Turns) != 0 {
+		t.Fatalf("Expected %d sections and no turns, got %d and %d", len(agents.WorldSections), len(draft.Sections), len(draft.Turns))
+	}
+	npcID := draft.Schema.NPCs[0].ID
+	ts.expect(ts.request(http.MethodGet, path, "bob", nil), http.StatusNotFound)
+	ts.expect(ts.request(http.MethodPost, path+"/messages", "alice", map[string]string{"message": "rename", "section": "moons"}), http.StatusBadRequest)
+	ts.expect(ts.request(http.MethodPost, path+"/messages", "alice", map[string]string{"message": " "}), http.StatusBadRequest)
+
+	// A targeted edit keeps the NPC's ID and is recorded as a turn
+	ts.decode(ts.expect(ts.request(http.MethodPost, path+"/messages", "alice", map[string]string{"message": "rename", "section": "npcs"}), http.StatusOK), &draft)
+	if draft.Schema.NPCs[0].Name != "Renamed" || draft.Schema.NPCs[0].ID != npcID {
+		t.Errorf("Expected %s renamed in place, got %+v", npcID, draft.Schema.NPCs[0])
+	}
+	if len(draft.Turns) != 1 || draft.Turns[0].Reply != "Done: rename" || draft.Turns[0].Changed[0] != "npcs" {
+		t.Errorf("Expected the rename recorded, got %+v", draft.Turns)
+	}
+
+	// Edits outside the section or changing IDs leave the draft as it was
+	ts.expect(ts.request(http.MethodPost, path+"/messages", "alice", map[string]string{"message": "rename", "section": "tags"}), http.StatusBadGateway)
+	ts.expect(ts.request(http.MethodPost, path+"/messages", "alice", map[string]string{"message": "rekey"}), http.StatusBadGateway)
+	ts.decode(ts.expect(ts.request(http.MethodGet, path, "alice", nil), http.StatusOK), &draft)
+	if len(draft.Turns) != 1 || draft.Schema.NPCs[0].ID != npcID {
+		t.Errorf("Expected rejected edits to change nothing, got %d turns and %s", len(draft.Turns), draft.Schema.NPCs[0].ID)
+	}
+
+	// Only a finalized draft starts a game, and refining it needs another
+	// review
+	ts.expect(ts.request(http.MethodPost, path+"/game", "alice", nil), http.StatusConflict)
+	ts.decode(ts.expect(ts.request(http.MethodPost, path+"/finalize", "alice", nil), http.StatusOK), &draft)
+	if draft.Status != DraftStatusReady || !draft.Review.Valid || draft.Review.Graph == nil {
+		t.Fatalf("Expected a ready draft with a DAG preview, got %s and %+v", draft.Status, draft.Review)
+	}
+	ts.decode(ts.expect(ts.request(http.MethodPost, path+"/messages", "alice", map[string]string{"message": "rename"}), http.StatusOK), &draft)
+	if draft.Status != DraftStatusDraft {
+		t.Errorf("Expected a refined draft to need review again, got %s", draft.Status)
+	}
+	ts.expect(ts.request(http.MethodPost, path+"/game", "alice", nil), http.StatusConflict)
+	ts.expect(ts.request(http.MethodPost, path+"/finalize", "alice", nil), http.StatusOK)
+
+	// Starting the game uses the refined world and drops the draft
+	res := ts.expect(ts.request(http.MethodPost, path+"/game", "alice", nil), http.StatusCreated)
+	var info struct {
+		ID string `json:"id"`
+	}
+	ts.decode(res, &info)
+	ts.expect(ts.request(http.MethodGet, "/api/games/"+info.ID, "alice", nil), http.StatusOK)
+	ts.expect(ts.request(http.MethodGet, path, "alice", nil), http.StatusNotFound)
+}
+
+// TestWorldLibrary tests that pre-generated worlds are listed by theme,
+// each starts one game, and a starter deck is in the game's first draw
+func TestWorldLibrary(t *testing.T) {
+	ts := newTestServer(t)
+	theme := "a drowned kingdom"
+	deck := []map[string]interface{}{{
+		"id":           "harbor_bell",
+		"title":        "The Harbor Bell",
+		"description":  "The bell rings though the tide is out.",
+		"character":    "",
+		"source":       "common",
+		"priority":     1,
+		"left_choice":  map[string]interface{}{"label": "Answer it", "calls": []interface{}{}},
+		"right_choice": map[string]interface{}{"label": "Stay inside", "calls": []interface{}{}},
+	}}
+	for seed := int64(1); seed <= 2; seed++ {
+		world := &db.LibraryWorld{Schema: agents.BuildDeterministicWorld(seed, theme)}
+		if seed == 1 {
+			world.Deck = deck
+		}
+		if err := ts.db.AddLibraryWorld(theme, world); err != nil {
+			t.Fatalf("Failed to add a library world: %v", err)
+		}
+	}
+
+	var themes []struct {
+		Theme     string `json:"theme"`
+		Available int    `json:"available"`
+		Prepared  int    `json:"prepared"`
+	}
+	ts.decode(ts.expect(ts.request(http.MethodGet, "/api/worlds/library", "public", nil), http.StatusOK), &themes)
+	if len(themes) != 1 || themes[0].Theme != theme || themes[0].Available != 2 || themes[0].Prepared != 1 {
+		t.Fatalf("Expected two worlds of %q, one prepared, got %+v", theme, themes)
+	}
+
+	// Only the world pooled with a starter deck deals its card
+	ts.expect(ts.request(http.MethodPatch, "/api/me/preferences", "public", map[string]bool{"tutorial_done": true}), http.StatusOK)
+	body := map[string]string{"library": theme}
+	for _, wantBell := range []bool{true, false} {
+		var info struct {
+			ID string `json:"id"`
+		}
+		ts.decode(ts.expect(ts.request(http.MethodPost, "/api/games", "public", body), http.StatusCreated), &info)
+		var drawn []struct {
+			ID string `json:"id"`
+		}
+		ts.decode(ts.expect(ts.request(http.MethodPost, "/api/games/"+info.ID+"/draw", "public", nil), http.StatusOK), &drawn)
+		gotBell := false
+		for _, card := range drawn {
+			gotBell = gotBell || card.ID == "harbor_bell"
+		}
+		if gotBell != wantBell {
+			t.Errorf("Expected the starter card drawn: %v, got %+v", wantBell, drawn)
+		}
+	}
+	ts.expect(ts.request(http.MethodPost, "/api/games", "public", body), http.StatusNotFound)
+	ts.expect(ts.request(http.MethodPost, "/api/games", "public", map[string]string{"library": " "}), http.StatusBadRequest)
+
+	ts.decode(ts.expect(ts.request(http.MethodGet, "/api/worlds/library", "public", nil), http.StatusOK), &themes)
+	if len(themes) != 0 {
+		t.Errorf("Expected an empty library, got %+v", themes)
+	}
+}
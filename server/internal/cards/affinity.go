@@ -0,0 +1,41 @@
+package cards
+
+import (
+	"fmt"
+)
+
+// maxAffinityDelta bounds how far one call moves an NPC's affinity
+const maxAffinityDelta = 50
+
+// updateAffinity moves how an NPC feels about the player, e.g. after the
+// player sides with them:
+//
+//	{"name": "update_affinity", "params": {"npc_id": "chancellor", "delta": 15}}
+//
+// An NPC whose affinity falls too low leaves the game for good.
+func (e *ActionExecutor) updateAffinity(params map[string]interface{}, result *ExecuteResult) (*ExecuteResult, error) {
+	npcID, ok := params["npc_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("update_affinity: missing npc_id")
+	}
+
+	// SECURITY FIX: Validate NPC exists
+	before, exists := e.state.NPCAffinity(npcID)
+	if !exists {
+		return nil, fmt.Errorf("update_affinity: invalid npc_id: %s", npcID)
+	}
+
+	value, ok := params["delta"].(float64)
+	if !ok || value != float64(int(value)) {
+		return nil, fmt.Errorf("update_affinity: invalid delta")
+	}
+	if value < -maxAffinityDelta || value > maxAffinityDelta {
+		return nil, fmt.Errorf("update_affinity: delta out of range: %v", value)
+	}
+
+	e.state.UpdateAffinity(npcID, int(value))
+	after, _ := e.state.NPCAffinity(npcID)
+
+	result.AddAffinityChanges(map[string]int{npcID: after - before})
+	return result, nil
+}
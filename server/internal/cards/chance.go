@@ -0,0 +1,158 @@
+package cards
+
+import (
+	"fmt"
+)
+
+// Roll records a chance-based branch the executor took
+type Roll struct {
+	Call    string  `json:"call"`             // executor function that rolled
+	Outcome string  `json:"outcome"`          // label of the branch taken
+	Value   float64 `json:"value"`            // raw roll in [0, 1)
+	Total   int     `json:"total,omitempty"`  // skill_check: die plus stat modifier
+	Target  int     `json:"target,omitempty"` // skill_check: total needed to succeed
+}
+
+// skillDie is the die a skill_check rolls
+const skillDie = 20
+
+// skillModifier turns a 0-100 stat into a -5..+5 check modifier
+func skillModifier(stat int) int {
+	return (stat - 50) / 10
+}
+
+// outcomeBranch is one weighted branch of a random_outcome call
+type outcomeBranch struct {
+	Label  string
+	Weight float64
+	Calls  []FunctionCall
+}
+
+// randomOutcome picks one weighted branch with the game's RNG and runs its
+// calls, e.g. a heist that fails 30% of the time:
+//
+//	{"name": "random_outcome", "params": {"branches": [
+//	  {"label": "caught", "weight": 30, "calls": [...]},
+//	  {"label": "escaped", "weight": 70, "calls": [...]}]}}
+func (e *ActionExecutor) randomOutcome(params map[string]interface{}, result *ExecuteResult) (*ExecuteResult, error) {
+	raw, ok := params["branches"].([]interface{})
+	if !ok || len(raw) == 0 {
+		return nil, fmt.Errorf("random_outcome: missing branches")
+	}
+
+	branches := make([]outcomeBranch, 0, len(raw))
+	total := 0.0
+	for i, item := range raw {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("random_outcome: invalid branch %d", i)
+		}
+		weight, ok := m["weight"].(float64)
+		if !ok || weight <= 0 {
+			return nil, fmt.Errorf("random_outcome: branch %d needs a positive weight", i)
+		}
+		label, _ := m["label"].(string)
+		if label == "" {
+			label = fmt.Sprintf("branch_%d", i)
+		}
+		calls, err := parseCalls(m["calls"])
+		if err != nil {
+			return nil, fmt.Errorf("random_outcome: branch %s: %w", label, err)
+		}
+		branches = append(branches, outcomeBranch{Label: label, Weight: weight, Calls: calls})
+		total += weight
+	}
+
+	value := e.state.Roll()
+	picked := branches[len(branches)-1]
+	acc := 0.0
+	for _, b := range branches {
+		acc += b.Weight
+		if value*total < acc {
+			picked = b
+			break
+		}
+	}
+
+	result.Rolls = append(result.Rolls, Roll{Call: "random_outcome", Outcome: picked.Label, Value: value})
+	if err := e.executeNested(picked.Calls, result); err != nil {
+		return nil, fmt.Errorf("random_outcome: %s: %w", picked.Label, err)
+	}
+	return result, nil
+}
+
+// parseCalls converts decoded JSON into function calls
+func parseCalls(raw interface{}) ([]FunctionCall, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("calls must be a list")
+	}
+
+	calls := make([]FunctionCall, 0, len(items))
+	for i, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid call %d", i)
+		}
+		name, ok := m["name"].(string)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("call %d: missing name", i)
+		}
+		params, _ := m["params"].(map[string]interface{})
+		calls = append(calls, FunctionCall{Name: name, Params: params})
+	}
+	return calls, nil
+}
+
+// skillCheck rolls a d20 plus a modifier from a stat against a target and
+// runs the success or failure calls, e.g. persuading the guard:
+//
+//	{"name": "skill_check", "params": {"stat_id": "charisma", "target": 12,
+//	  "success": [...], "failure": [...]}}
+func (e *ActionExecutor) skillCheck(params map[string]interface{}, result *ExecuteResult) (*ExecuteResult, error) {
+	statID, ok := params["stat_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("skill_check: missing stat_id")
+	}
+
+	// SECURITY FIX: Validate stat exists
+	if _, exists := e.state.GetStats()[statID]; !exists {
+		return nil, fmt.Errorf("skill_check: invalid stat_id: %s", statID)
+	}
+
+	target, ok := params["target"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("skill_check: invalid target")
+	}
+	if target < 1 || target > skillDie+5 {
+		return nil, fmt.Errorf("skill_check: target out of range: %v", target)
+	}
+
+	success, err := parseCalls(params["success"])
+	if err != nil {
+		return nil, fmt.Errorf("skill_check: success: %w", err)
+	}
+	failure, err := parseCalls(params["failure"])
+	if err != nil {
+		return nil, fmt.Errorf("skill_check: failure: %w", err)
+	}
+
+	value := e.state.Roll()
+	total := 1 + int(value*skillDie) + skillModifier(e.state.GetStat(statID))
+
+	roll := Roll{Call: "skill_check", Outcome: "failure", Value: value, Total: total, Target: int(target)}
+	calls := failure
+	if total >= int(target) {
+		roll.Outcome = "success"
+		calls = success
+	}
+
+	result.Rolls = append(result.Rolls, roll)
+	if err := e.executeNested(calls, result); err != nil {
+		return nil, fmt.Errorf("skill_check: %s: %w", roll.Outcome, err)
+	}
+	return result, nil
+}
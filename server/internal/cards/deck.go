@@ -1,29 +1,66 @@
 package cards
 
 import (
+	"math/rand"
 	"sort"
 )
 
+// RevealWindow holds a card back until a day of the week and has it dealt
+// by another at the latest, so plot beats land mid-week. Days count from 1;
+// a zero bound is open.
+type RevealWindow struct {
+	Earliest int `json:"earliest,omitempty"`
+	Latest   int `json:"latest,omitempty"`
+}
+
+// opens reports whether a card with the window may be dealt on day
+func (w *RevealWindow) opens(day int) bool {
+	return w == nil || day >= w.Earliest
+}
+
+// due reports whether a card with the window must be dealt on day
+func (w *RevealWindow) due(day int) bool {
+	return w != nil && w.Latest > 0 && day >= w.Latest
+}
+
+// revealWindow returns a card's reveal window, nil for cards dealt any day
+func revealWindow(card Card) *RevealWindow {
+	switch c := card.(type) {
+	case *ChoiceCard:
+		return c.RevealWindow
+	case *InfoCard:
+		return c.RevealWindow
+	}
+	return nil
+}
+
 // WeightedDeque is a priority-based card deck
 type WeightedDeque struct {
 	cards    []Card
 	capacity int
+	rng      *rand.Rand // orders cards of equal priority; nil leaves them as sorted
 }
 
-// NewWeightedDeque creates a new deck with given capacity
-func NewWeightedDeque(capacity int) *WeightedDeque {
+// NewWeightedDeque creates a new deck with given capacity. Cards of equal
+// priority are placed by rng, so a seeded rng always deals them alike.
+func NewWeightedDeque(capacity int, rng *rand.Rand) *WeightedDeque {
 	return &WeightedDeque{
 		cards:    make([]Card, 0, capacity),
 		capacity: capacity,
+		rng:      rng,
 	}
 }
 
 // Insert adds a card to the deck, maintaining priority order
 func (d *WeightedDeque) Insert(card Card) {
-	d.cards = append(d.cards, card)
-	sort.Slice(d.cards, func(i, j int) bool {
-		return d.cards[i].GetPriority() > d.cards[j].GetPriority()
-	})
+	if d.rng != nil {
+		d.insertAmongPeers(card)
+	} else {
+		d.cards = append(d.cards, card)
+		sort.Slice(d.cards, func(i, j int) bool {
+			return d.cards[i].GetPriority() > d.cards[j].GetPriority()
+		})
+	}
 
 	// Evict lowest priority cards if over capacity
 	for len(d.cards) > d.capacity {
@@ -31,6 +68,19 @@ func (d *WeightedDeque) Insert(card Card) {
 	}
 }
 
+// insertAmongPeers inserts a card at a random place among the cards of its
+// priority, keeping the deck in priority order
+func (d *WeightedDeque) insertAmongPeers(card Card) {
+	priority := card.GetPriority()
+	first := sort.Search(len(d.cards), func(i int) bool { return d.cards[i].GetPriority() <= priority })
+	last := sort.Search(len(d.cards), func(i int) bool { return d.cards[i].GetPriority() < priority })
+	at := first + d.rng.Intn(last-first+1)
+
+	d.cards = append(d.cards, nil)
+	copy(d.cards[at+1:], d.cards[at:])
+	d.cards[at] = card
+}
+
 // evictLowestPriority removes the lowest priority card
 // Never evicts plot/event/tree/story cards
 func (d *WeightedDeque) evictLowestPriority() {
@@ -63,6 +113,39 @@ func (d *WeightedDeque) DrawN(n int) []Card {
 	return result
 }
 
+// DrawForDay draws the card for a day of the week. A card whose reveal
+// window closes that day comes first, and cards whose window has not opened
+// are passed over. The reservation is soft: when only held-back cards are
+// left, the next one is dealt early rather than leaving the day empty.
+func (d *WeightedDeque) DrawForDay(day int) Card {
+	pick := -1
+	for i := len(d.cards) - 1; i >= 0; i-- {
+		window := revealWindow(d.cards[i])
+		if window.due(day) {
+			pick = i
+			break
+		}
+		if pick < 0 && window.opens(day) {
+			pick = i
+		}
+	}
+	if pick < 0 {
+		return d.Draw()
+	}
+	card := d.cards[pick]
+	d.cards = append(d.cards[:pick], d.cards[pick+1:]...)
+	return card
+}
+
+// DrawDays draws the cards for n days starting at firstDay
+func (d *WeightedDeque) DrawDays(firstDay, n int) []Card {
+	result := make([]Card, 0, n)
+	for day := firstDay; day < firstDay+n && len(d.cards) > 0; day++ {
+		result = append(result, d.DrawForDay(day))
+	}
+	return result
+}
+
 // Peek returns the next card without removing it
 func (d *WeightedDeque) Peek() Card {
 	if len(d.cards) == 0 {
@@ -81,6 +164,11 @@ func (d *WeightedDeque) Clear() {
 	d.cards = make([]Card, 0, d.capacity)
 }
 
+// Restore replaces the deck's cards with cards, as returned by GetAll
+func (d *WeightedDeque) Restore(cards []Card) {
+	d.cards = append(make([]Card, 0, d.capacity), cards...)
+}
+
 // GetAll returns all cards in the deck
 func (d *WeightedDeque) GetAll() []Card {
 	result := make([]Card, len(d.cards))
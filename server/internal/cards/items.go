@@ -0,0 +1,98 @@
+package cards
+
+import (
+	"fmt"
+)
+
+// maxItemDelta bounds how many of an item one call gives or takes
+const maxItemDelta = 10
+
+// itemParams reads the item_id and count of an item call, checking that
+// the world defines the item. The count defaults to 1.
+func (e *ActionExecutor) itemParams(call string, params map[string]interface{}) (string, int, error) {
+	itemID, ok := params["item_id"].(string)
+	if !ok {
+		return "", 0, fmt.Errorf("%s: missing item_id", call)
+	}
+
+	// SECURITY FIX: Validate item exists
+	if _, exists := e.state.ItemCount(itemID); !exists {
+		return "", 0, fmt.Errorf("%s: invalid item_id: %s", call, itemID)
+	}
+
+	count := 1
+	if raw, ok := params["count"]; ok {
+		value, ok := raw.(float64)
+		if !ok || value != float64(int(value)) {
+			return "", 0, fmt.Errorf("%s: invalid count", call)
+		}
+		if value < 1 || value > maxItemDelta {
+			return "", 0, fmt.Errorf("%s: count out of range: %v", call, value)
+		}
+		count = int(value)
+	}
+	return itemID, count, nil
+}
+
+// giveItem adds to the player's count of an item, e.g. finding a key:
+//
+//	{"name": "give_item", "params": {"item_id": "iron_key"}}
+func (e *ActionExecutor) giveItem(params map[string]interface{}, result *ExecuteResult) (*ExecuteResult, error) {
+	itemID, count, err := e.itemParams("give_item", params)
+	if err != nil {
+		return nil, err
+	}
+
+	before, _ := e.state.ItemCount(itemID)
+	e.state.GiveItem(itemID, count)
+	after, _ := e.state.ItemCount(itemID)
+
+	result.AddItemChanges(map[string]int{itemID: after - before})
+	return result, nil
+}
+
+// removeItem takes from the player's count of an item; taking more than
+// the player holds leaves none
+func (e *ActionExecutor) removeItem(params map[string]interface{}, result *ExecuteResult) (*ExecuteResult, error) {
+	itemID, count, err := e.itemParams("remove_item", params)
+	if err != nil {
+		return nil, err
+	}
+
+	before, _ := e.state.ItemCount(itemID)
+	e.state.RemoveItem(itemID, count)
+	after, _ := e.state.ItemCount(itemID)
+
+	result.AddItemChanges(map[string]int{itemID: after - before})
+	return result, nil
+}
+
+// hasItem runs the "then" calls when the player holds at least count of an
+// item and the "else" calls otherwise, e.g. a locked door:
+//
+//	{"name": "has_item", "params": {"item_id": "iron_key",
+//	  "then": [...], "else": [...]}}
+func (e *ActionExecutor) hasItem(params map[string]interface{}, result *ExecuteResult) (*ExecuteResult, error) {
+	itemID, count, err := e.itemParams("has_item", params)
+	if err != nil {
+		return nil, err
+	}
+
+	then, err := parseCalls(params["then"])
+	if err != nil {
+		return nil, fmt.Errorf("has_item: then: %w", err)
+	}
+	otherwise, err := parseCalls(params["else"])
+	if err != nil {
+		return nil, fmt.Errorf("has_item: else: %w", err)
+	}
+
+	branch, calls := "else", otherwise
+	if held, _ := e.state.ItemCount(itemID); held >= count {
+		branch, calls = "then", then
+	}
+	if err := e.executeNested(calls, result); err != nil {
+		return nil, fmt.Errorf("has_item: %s: %w", branch, err)
+	}
+	return result, nil
+}
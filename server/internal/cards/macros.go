@@ -0,0 +1,95 @@
+package cards
+
+import (
+	"fmt"
+	"sort"
+)
+
+// maxCallDepth bounds how deeply macros and chance branches may nest
+const maxCallDepth = 4
+
+// builtinFunctions are executor functions that macros may not shadow
+var builtinFunctions = map[string]bool{
+	"update_stat":     true,
+	"add_tag":         true,
+	"remove_tag":      true,
+	"enable_npc":      true,
+	"disable_npc":     true,
+	"advance_time":    true,
+	"reveal_stat":     true,
+	"random_outcome":  true,
+	"skill_check":     true,
+	"give_item":       true,
+	"remove_item":     true,
+	"has_item":        true,
+	"add_resource":    true,
+	"spend_resource":  true,
+	"update_affinity": true,
+}
+
+// Macro is a world-defined compound function that the executor expands
+// into its calls (e.g. host_feast = gold -10, morale +15, add_tag feast_host)
+type Macro struct {
+	ID          string         `json:"id"`
+	Description string         `json:"description"`
+	Calls       []FunctionCall `json:"calls"`
+}
+
+// ValidateMacros checks that macros have calls, do not shadow built-in
+// functions and do not call themselves directly or indirectly
+func ValidateMacros(macros map[string]Macro) error {
+	ids := make([]string, 0, len(macros))
+	for id := range macros {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if id == "" {
+			return fmt.Errorf("macro with empty id")
+		}
+		if builtinFunctions[id] {
+			return fmt.Errorf("macro %s shadows a built-in function", id)
+		}
+		if len(macros[id].Calls) == 0 {
+			return fmt.Errorf("macro %s has no calls", id)
+		}
+	}
+
+	// Depth-first search for cycles through macro-to-macro calls
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(macros))
+	var visit func(id string, depth int) error
+	visit = func(id string, depth int) error {
+		switch state[id] {
+		case visiting:
+			return fmt.Errorf("macro %s calls itself", id)
+		case done:
+			return nil
+		}
+		if depth > maxCallDepth {
+			return fmt.Errorf("macro %s nests deeper than %d levels", id, maxCallDepth)
+		}
+		state[id] = visiting
+		for _, call := range macros[id].Calls {
+			if _, ok := macros[call.Name]; ok {
+				if err := visit(call.Name, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+		state[id] = done
+		return nil
+	}
+
+	for _, id := range ids {
+		if err := visit(id, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -29,15 +29,17 @@ type FunctionCall struct {
 
 // ChoiceCard represents a card with left/right choices
 type ChoiceCard struct {
-	ID          string         `json:"id"`
-	Title       string         `json:"title"`
-	Description string         `json:"description"`
-	Character   string         `json:"character"`
-	Source      string         `json:"source"`
-	Priority    int            `json:"priority"`
-	LeftChoice  *Choice        `json:"left_choice"`
-	RightChoice *Choice        `json:"right_choice"`
-	TreeCards   []Card         `json:"tree_cards,omitempty"`
+	ID                string        `json:"id"`
+	Title             string        `json:"title"`
+	Description       string        `json:"description"`
+	SimpleDescription string        `json:"simple_description,omitempty"` // plain-language variant for players who ask for it
+	Character         string        `json:"character"`
+	Source            string        `json:"source"`
+	Priority          int           `json:"priority"`
+	LeftChoice        *Choice       `json:"left_choice"`
+	RightChoice       *Choice       `json:"right_choice"`
+	TreeCards         []Card        `json:"tree_cards,omitempty"`
+	RevealWindow      *RevealWindow `json:"reveal_window,omitempty"` // days of the week the deck deals it on
 }
 
 // Choice represents a single choice option
@@ -45,17 +47,21 @@ type Choice struct {
 	Label        string         `json:"label"`
 	Calls        []FunctionCall `json:"calls"`
 	TreeCards    []Card         `json:"tree_cards,omitempty"`
+	Requires     string         `json:"requires,omitempty"` // condition checked before the choice resolves
+	Unlocked     *Choice        `json:"unlocked,omitempty"` // replaces this choice once its Requires holds
 }
 
 // InfoCard represents a read-only information card
 type InfoCard struct {
-	ID          string `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Character   string `json:"character"`
-	Source      string `json:"source"`
-	Priority    int    `json:"priority"`
-	NextCards   []Card `json:"next_cards,omitempty"`
+	ID                string        `json:"id"`
+	Title             string        `json:"title"`
+	Description       string        `json:"description"`
+	SimpleDescription string        `json:"simple_description,omitempty"` // plain-language variant for players who ask for it
+	Character         string        `json:"character"`
+	Source            string        `json:"source"`
+	Priority          int           `json:"priority"`
+	NextCards         []Card        `json:"next_cards,omitempty"`
+	RevealWindow      *RevealWindow `json:"reveal_window,omitempty"` // days of the week the deck deals it on
 }
 
 // Implement Card interface for ChoiceCard
@@ -75,3 +81,26 @@ func (c *InfoCard) GetCharacter() string   { return c.Character }
 func (c *InfoCard) GetSource() string      { return c.Source }
 func (c *InfoCard) GetPriority() int       { return c.Priority }
 func (c *InfoCard) IsChoiceCard() bool     { return false }
+
+// Simplified returns the card as shown to a player who asked for simple
+// text: a copy whose description is the plain-language variant. Cards
+// without one are returned unchanged.
+func Simplified(card Card) Card {
+	switch c := card.(type) {
+	case *ChoiceCard:
+		if c.SimpleDescription == "" {
+			return card
+		}
+		cp := *c
+		cp.Description = c.SimpleDescription
+		return &cp
+	case *InfoCard:
+		if c.SimpleDescription == "" {
+			return card
+		}
+		cp := *c
+		cp.Description = c.SimpleDescription
+		return &cp
+	}
+	return card
+}
@@ -0,0 +1,92 @@
+package cards
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata/golden")
+
+// TestGoldenCards tests that card models keep their saved form
+func TestGoldenCards(t *testing.T) {
+	tests := []struct {
+		name    string
+		card    Card
+		decoded Card
+	}{
+		{
+			name: "choice_card",
+			card: &ChoiceCard{
+				ID:          "smith_offer",
+				Title:       "The Smith's Offer",
+				Description: "Bram offers to mend your blade for a price",
+				Character:   "smith",
+				Source:      "common",
+				Priority:    PriorityCommon,
+				LeftChoice: &Choice{
+					Label: "Refuse",
+					Calls: []FunctionCall{{Name: "update_stat", Params: map[string]interface{}{"stat_id": "health", "delta": -5}}},
+				},
+				RightChoice: &Choice{
+					Label: "Pay him",
+					Calls: []FunctionCall{
+						{Name: "update_stat", Params: map[string]interface{}{"stat_id": "gold", "delta": -10}},
+						{Name: "add_tag", Params: map[string]interface{}{"tag_id": "armed"}},
+					},
+				},
+			},
+			decoded: &ChoiceCard{},
+		},
+		{
+			name: "info_card",
+			card: &InfoCard{
+				ID:          "welcome",
+				Title:       "Welcome",
+				Description: "The river town wakes",
+				Character:   "narrator",
+				Source:      "welcome",
+				Priority:    PriorityStory,
+			},
+			decoded: &InfoCard{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.MarshalIndent(tt.card, "", "  ")
+			if err != nil {
+				t.Fatalf("Failed to marshal card: %v", err)
+			}
+
+			path := filepath.Join("testdata", "golden", tt.name+".json")
+			if *updateGolden {
+				if err := os.WriteFile(path, append(got, '\n'), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read golden file: %v", err)
+			}
+			want = bytes.TrimSpace(want)
+			if !bytes.Equal(got, want) {
+				t.Errorf("Card does not match %s (run go test -update if the change is intended)\ngot:\n%s", path, got)
+			}
+
+			if err := json.Unmarshal(want, tt.decoded); err != nil {
+				t.Fatalf("Failed to unmarshal card: %v", err)
+			}
+			again, err := json.MarshalIndent(tt.decoded, "", "  ")
+			if err != nil {
+				t.Fatalf("Failed to re-marshal card: %v", err)
+			}
+			if !bytes.Equal(again, want) {
+				t.Errorf("Card changed after a round trip\ngot:\n%s", again)
+			}
+		})
+	}
+}
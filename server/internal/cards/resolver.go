@@ -2,13 +2,98 @@ package cards
 
 import (
 	"fmt"
+	"math"
 )
 
+// Limits on facts established by add_fact
+const (
+	maxFactID   = 64
+	maxFactText = 200
+)
+
+// validFactID reports whether id is a snake_case fact ID such as
+// "bridge_destroyed"
+func validFactID(id string) bool {
+	if id == "" || len(id) > maxFactID {
+		return false
+	}
+	for _, r := range id {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
 // ExecuteResult contains the result of executing a card action
 type ExecuteResult struct {
-	StatChanges map[string]int
-	TreeCards   []Card
-	Direction   string // "left" or "right"
+	StatChanges     map[string]int
+	ItemChanges     map[string]int // item count deltas from give_item and remove_item
+	ResourceChanges map[string]int // resource balance deltas from add_resource and spend_resource
+	AffinityChanges map[string]int // NPC affinity deltas from update_affinity
+	TreeCards       []Card
+	Direction       string     // "left" or "right"
+	PendingPlots    []string   // plot nodes whose conditions became true
+	Interrupts      []Card     // cards dealt at once by event phases the choice began
+	Rolls           []Roll     // chance branches taken, in order
+	Modifiers       []Modifier // multipliers already applied to StatChanges
+}
+
+// Modifier records a multiplier applied to a stat change, e.g. food ×2 in
+// the harvest season
+type Modifier struct {
+	StatID     string  `json:"stat_id"`
+	Multiplier float64 `json:"multiplier"`
+	Source     string  `json:"source"` // season name
+}
+
+// AddModifier records a modifier once per stat and source
+func (r *ExecuteResult) AddModifier(m Modifier) {
+	for _, existing := range r.Modifiers {
+		if existing.StatID == m.StatID && existing.Source == m.Source {
+			return
+		}
+	}
+	r.Modifiers = append(r.Modifiers, m)
+}
+
+// AddItemChanges merges item count deltas into the result
+func (r *ExecuteResult) AddItemChanges(changes map[string]int) {
+	for id, delta := range changes {
+		if r.ItemChanges == nil {
+			r.ItemChanges = make(map[string]int)
+		}
+		r.ItemChanges[id] += delta
+		if r.ItemChanges[id] == 0 {
+			delete(r.ItemChanges, id)
+		}
+	}
+}
+
+// AddResourceChanges merges resource balance deltas into the result
+func (r *ExecuteResult) AddResourceChanges(changes map[string]int) {
+	for id, delta := range changes {
+		if r.ResourceChanges == nil {
+			r.ResourceChanges = make(map[string]int)
+		}
+		r.ResourceChanges[id] += delta
+		if r.ResourceChanges[id] == 0 {
+			delete(r.ResourceChanges, id)
+		}
+	}
+}
+
+// AddAffinityChanges merges NPC affinity deltas into the result
+func (r *ExecuteResult) AddAffinityChanges(changes map[string]int) {
+	for id, delta := range changes {
+		if r.AffinityChanges == nil {
+			r.AffinityChanges = make(map[string]int)
+		}
+		r.AffinityChanges[id] += delta
+		if r.AffinityChanges[id] == 0 {
+			delete(r.AffinityChanges, id)
+		}
+	}
 }
 
 // StateUpdater is an interface for updating game state
@@ -24,11 +109,27 @@ type StateUpdater interface {
 	AdvanceDay()
 	GetTags() map[string]bool
 	GetStats() map[string]int
+	GetMacro(id string) (*Macro, bool)
+	RevealStat(id string)
+	AdvanceEventPhase(id string) bool
+	EstablishFact(id, text string)
+	RetractFact(id string)
+	Roll() float64 // next value in [0, 1) from the game's seeded RNG
+	StatMultiplier(id string) (float64, string)
+	ItemCount(id string) (int, bool) // count held; false when the world has no such item
+	GiveItem(id string, count int)
+	RemoveItem(id string, count int)
+	ResourceBalance(id string) (int, bool) // balance; false when the world has no such resource
+	AddResource(id string, amount int)
+	SpendResource(id string, amount int)
+	NPCAffinity(id string) (int, bool) // affinity; false when there is no such NPC
+	UpdateAffinity(id string, delta int)
 }
 
 // ActionExecutor executes AI-generated function calls against game state
 type ActionExecutor struct {
 	state StateUpdater
+	depth int // current nesting level of macros and chance branches
 }
 
 // NewActionExecutor creates a new executor
@@ -66,7 +167,35 @@ func (e *ActionExecutor) Execute(call map[string]interface{}) (*ExecuteResult, e
 		return e.disableNPC(params, result)
 	case "advance_time":
 		return e.advanceTime(params, result)
+	case "reveal_stat":
+		return e.revealStat(params, result)
+	case "advance_phase":
+		return e.advancePhase(params, result)
+	case "add_fact":
+		return e.addFact(params, result)
+	case "retract_fact":
+		return e.retractFact(params, result)
+	case "random_outcome":
+		return e.randomOutcome(params, result)
+	case "skill_check":
+		return e.skillCheck(params, result)
+	case "give_item":
+		return e.giveItem(params, result)
+	case "remove_item":
+		return e.removeItem(params, result)
+	case "has_item":
+		return e.hasItem(params, result)
+	case "add_resource":
+		return e.addResource(params, result)
+	case "spend_resource":
+		return e.spendResource(params, result)
+	case "update_affinity":
+		return e.updateAffinity(params, result)
 	default:
+		// World-defined macros expand into their calls
+		if macro, ok := e.state.GetMacro(name); ok {
+			return e.expandMacro(macro, result)
+		}
 		// Silently ignore unknown functions (events handled separately)
 		return result, nil
 	}
@@ -89,12 +218,59 @@ func (e *ActionExecutor) ExecuteMultiple(calls []map[string]interface{}) (*Execu
 		for stat, delta := range res.StatChanges {
 			result.StatChanges[stat] += delta
 		}
+		result.AddItemChanges(res.ItemChanges)
+		result.AddResourceChanges(res.ResourceChanges)
+		result.AddAffinityChanges(res.AffinityChanges)
 		result.TreeCards = append(result.TreeCards, res.TreeCards...)
+		result.Rolls = append(result.Rolls, res.Rolls...)
+		for _, m := range res.Modifiers {
+			result.AddModifier(m)
+		}
 	}
 
 	return result, nil
 }
 
+// expandMacro runs a macro's calls in order and merges their results
+func (e *ActionExecutor) expandMacro(macro *Macro, result *ExecuteResult) (*ExecuteResult, error) {
+	if err := e.executeNested(macro.Calls, result); err != nil {
+		return nil, fmt.Errorf("%s: %w", macro.ID, err)
+	}
+	return result, nil
+}
+
+// executeNested runs calls one level deeper and merges their results
+func (e *ActionExecutor) executeNested(calls []FunctionCall, result *ExecuteResult) error {
+	if e.depth >= maxCallDepth {
+		return fmt.Errorf("nesting too deep")
+	}
+	e.depth++
+	defer func() { e.depth-- }()
+
+	for _, call := range calls {
+		res, err := e.Execute(map[string]interface{}{
+			"name":   call.Name,
+			"params": call.Params,
+		})
+		if err != nil {
+			return err
+		}
+		for stat, delta := range res.StatChanges {
+			result.StatChanges[stat] += delta
+		}
+		result.AddItemChanges(res.ItemChanges)
+		result.AddResourceChanges(res.ResourceChanges)
+		result.AddAffinityChanges(res.AffinityChanges)
+		result.TreeCards = append(result.TreeCards, res.TreeCards...)
+		result.Rolls = append(result.Rolls, res.Rolls...)
+		for _, m := range res.Modifiers {
+			result.AddModifier(m)
+		}
+	}
+
+	return nil
+}
+
 func (e *ActionExecutor) updateStat(params map[string]interface{}, result *ExecuteResult) (*ExecuteResult, error) {
 	statID, ok := params["stat_id"].(string)
 	if !ok {
@@ -117,8 +293,17 @@ func (e *ActionExecutor) updateStat(params map[string]interface{}, result *Execu
 		return nil, fmt.Errorf("update_stat: delta out of range: %v", delta)
 	}
 
+	// Seasons may scale gains, e.g. harvest doubles food gains
+	change := int(delta)
+	if change > 0 {
+		if mult, source := e.state.StatMultiplier(statID); mult != 1 {
+			change = int(math.Round(float64(change) * mult))
+			result.AddModifier(Modifier{StatID: statID, Multiplier: mult, Source: source})
+		}
+	}
+
 	oldVal := e.state.GetStat(statID)
-	e.state.UpdateStat(statID, int(delta))
+	e.state.UpdateStat(statID, change)
 	newVal := e.state.GetStat(statID)
 
 	result.StatChanges[statID] = newVal - oldVal
@@ -199,3 +384,63 @@ func (e *ActionExecutor) advanceTime(params map[string]interface{}, result *Exec
 
 	return result, nil
 }
+
+func (e *ActionExecutor) revealStat(params map[string]interface{}, result *ExecuteResult) (*ExecuteResult, error) {
+	statID, ok := params["stat_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("reveal_stat: missing stat_id")
+	}
+
+	// SECURITY FIX: Validate stat exists
+	if _, exists := e.state.GetStats()[statID]; !exists {
+		return nil, fmt.Errorf("reveal_stat: invalid stat_id: %s", statID)
+	}
+
+	e.state.RevealStat(statID)
+	return result, nil
+}
+
+func (e *ActionExecutor) advancePhase(params map[string]interface{}, result *ExecuteResult) (*ExecuteResult, error) {
+	eventID, ok := params["event_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("advance_phase: missing event_id")
+	}
+
+	if !e.state.AdvanceEventPhase(eventID) {
+		return nil, fmt.Errorf("advance_phase: no phase event in progress: %s", eventID)
+	}
+	return result, nil
+}
+
+func (e *ActionExecutor) addFact(params map[string]interface{}, result *ExecuteResult) (*ExecuteResult, error) {
+	factID, ok := params["fact_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("add_fact: missing fact_id")
+	}
+
+	// SECURITY FIX: Fact IDs are written into Writer prompts
+	if !validFactID(factID) {
+		return nil, fmt.Errorf("add_fact: invalid fact_id: %q", factID)
+	}
+	text, _ := params["text"].(string)
+	if len(text) > maxFactText {
+		text = text[:maxFactText]
+	}
+
+	e.state.EstablishFact(factID, text)
+	return result, nil
+}
+
+func (e *ActionExecutor) retractFact(params map[string]interface{}, result *ExecuteResult) (*ExecuteResult, error) {
+	factID, ok := params["fact_id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("retract_fact: missing fact_id")
+	}
+
+	if !validFactID(factID) {
+		return nil, fmt.Errorf("retract_fact: invalid fact_id: %q", factID)
+	}
+
+	e.state.RetractFact(factID)
+	return result, nil
+}
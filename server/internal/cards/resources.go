@@ -0,0 +1,65 @@
+package cards
+
+import (
+	"fmt"
+)
+
+// maxResourceDelta bounds how much of a resource one call adds or spends
+const maxResourceDelta = 1_000_000
+
+// resourceParams reads the resource_id and amount of a resource call,
+// checking that the world defines the resource
+func (e *ActionExecutor) resourceParams(call string, params map[string]interface{}) (string, int, error) {
+	resourceID, ok := params["resource_id"].(string)
+	if !ok {
+		return "", 0, fmt.Errorf("%s: missing resource_id", call)
+	}
+
+	// SECURITY FIX: Validate resource exists
+	if _, exists := e.state.ResourceBalance(resourceID); !exists {
+		return "", 0, fmt.Errorf("%s: invalid resource_id: %s", call, resourceID)
+	}
+
+	value, ok := params["amount"].(float64)
+	if !ok || value != float64(int(value)) {
+		return "", 0, fmt.Errorf("%s: invalid amount", call)
+	}
+	if value < 1 || value > maxResourceDelta {
+		return "", 0, fmt.Errorf("%s: amount out of range: %v", call, value)
+	}
+	return resourceID, int(value), nil
+}
+
+// addResource adds to the player's balance of a resource, e.g. a reward:
+//
+//	{"name": "add_resource", "params": {"resource_id": "gold", "amount": 30}}
+func (e *ActionExecutor) addResource(params map[string]interface{}, result *ExecuteResult) (*ExecuteResult, error) {
+	resourceID, amount, err := e.resourceParams("add_resource", params)
+	if err != nil {
+		return nil, err
+	}
+
+	before, _ := e.state.ResourceBalance(resourceID)
+	e.state.AddResource(resourceID, amount)
+	after, _ := e.state.ResourceBalance(resourceID)
+
+	result.AddResourceChanges(map[string]int{resourceID: after - before})
+	return result, nil
+}
+
+// spendResource takes from the player's balance of a resource. Spending
+// more than the balance empties it, or bankrupts the player when the
+// resource has the bankruptcy rule.
+func (e *ActionExecutor) spendResource(params map[string]interface{}, result *ExecuteResult) (*ExecuteResult, error) {
+	resourceID, amount, err := e.resourceParams("spend_resource", params)
+	if err != nil {
+		return nil, err
+	}
+
+	before, _ := e.state.ResourceBalance(resourceID)
+	e.state.SpendResource(resourceID, amount)
+	after, _ := e.state.ResourceBalance(resourceID)
+
+	result.AddResourceChanges(map[string]int{resourceID: after - before})
+	return result, nil
+}
@@ -0,0 +1,103 @@
+// Package cluster decides which server instance owns which game, so
+// several instances can share the stateful engine layer.
+package cluster
+
+import (
+	"fmt"
+	"hash/crc32"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// virtualNodes is how many points each instance gets on the ring; more
+// points spread games more evenly
+const virtualNodes = 128
+
+// Ring is a consistent-hashing ring of server instances. Adding or removing
+// an instance only moves the games that hashed to its points.
+type Ring struct {
+	self   string
+	nodes  []string
+	points []uint32
+	owners map[uint32]string
+}
+
+// NewRing builds a ring from instance base URLs. self must be one of them.
+func NewRing(self string, nodes []string) (*Ring, error) {
+	r := &Ring{self: strings.TrimRight(self, "/"), owners: make(map[uint32]string)}
+
+	seen := make(map[string]bool)
+	for _, node := range nodes {
+		node = strings.TrimRight(strings.TrimSpace(node), "/")
+		if node == "" || seen[node] {
+			continue
+		}
+		if u, err := url.Parse(node); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid cluster node %q", node)
+		}
+		seen[node] = true
+		r.nodes = append(r.nodes, node)
+
+		for i := 0; i < virtualNodes; i++ {
+			point := crc32.ChecksumIEEE([]byte(node + "#" + strconv.Itoa(i)))
+			// On the rare collision the smaller URL wins, so every
+			// instance builds the same ring
+			if owner, ok := r.owners[point]; ok && owner < node {
+				continue
+			}
+			if _, ok := r.owners[point]; !ok {
+				r.points = append(r.points, point)
+			}
+			r.owners[point] = node
+		}
+	}
+	if !seen[r.self] {
+		return nil, fmt.Errorf("cluster node list does not include this instance (%s)", r.self)
+	}
+
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	sort.Strings(r.nodes)
+	return r, nil
+}
+
+// NewRingFromEnv builds a ring from CLUSTER_NODES (comma-separated base URLs)
+// and CLUSTER_SELF. It returns nil when clustering is not configured.
+func NewRingFromEnv() (*Ring, error) {
+	nodes := os.Getenv("CLUSTER_NODES")
+	if strings.TrimSpace(nodes) == "" {
+		return nil, nil
+	}
+	self := os.Getenv("CLUSTER_SELF")
+	if self == "" {
+		return nil, fmt.Errorf("CLUSTER_SELF is required with CLUSTER_NODES")
+	}
+	return NewRing(self, strings.Split(nodes, ","))
+}
+
+// Owner returns the base URL of the instance that owns a key
+func (r *Ring) Owner(key string) string {
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+// IsLocal reports whether this instance owns a key
+func (r *Ring) IsLocal(key string) bool {
+	return r.Owner(key) == r.self
+}
+
+// Self returns this instance's base URL
+func (r *Ring) Self() string {
+	return r.self
+}
+
+// Nodes returns every instance's base URL, sorted
+func (r *Ring) Nodes() []string {
+	return append([]string(nil), r.nodes...)
+}
@@ -0,0 +1,77 @@
+package cluster
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestRingOwnership tests that every instance agrees on owners and that
+// games spread across instances
+func TestRingOwnership(t *testing.T) {
+	nodes := []string{"http://a:8080", "http://b:8080", "http://c:8080"}
+	rings := make([]*Ring, 0, len(nodes))
+	for _, self := range nodes {
+		ring, err := NewRing(self, nodes)
+		if err != nil {
+			t.Fatalf("Failed to build ring: %v", err)
+		}
+		rings = append(rings, ring)
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("game-%d", i)
+		owner := rings[0].Owner(key)
+		counts[owner]++
+
+		local := 0
+		for _, ring := range rings {
+			if ring.Owner(key) != owner {
+				t.Fatalf("Instances disagree on the owner of %s", key)
+			}
+			if ring.IsLocal(key) {
+				local++
+			}
+		}
+		if local != 1 {
+			t.Fatalf("Expected exactly one instance to own %s, got %d", key, local)
+		}
+	}
+	for _, node := range nodes {
+		if counts[node] < 500 {
+			t.Errorf("Expected games to spread evenly, got %v", counts)
+		}
+	}
+}
+
+// TestRingRebalance tests that adding an instance only moves games to it
+func TestRingRebalance(t *testing.T) {
+	before, err := NewRing("http://a:8080", []string{"http://a:8080", "http://b:8080"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := NewRing("http://a:8080", []string{"http://a:8080", "http://b:8080", "http://c:8080"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("game-%d", i)
+		if owner := after.Owner(key); owner != before.Owner(key) && owner != "http://c:8080" {
+			t.Fatalf("Expected %s to stay put or move to the new instance, moved to %s", key, owner)
+		}
+	}
+}
+
+// TestNewRingValidation tests rejected configurations
+func TestNewRingValidation(t *testing.T) {
+	if _, err := NewRing("http://x:8080", []string{"http://a:8080"}); err == nil {
+		t.Error("Expected an error when self is not in the node list")
+	}
+	if _, err := NewRing("http://a:8080", []string{"http://a:8080", "not a url"}); err == nil {
+		t.Error("Expected an error for an invalid node URL")
+	}
+	if _, err := NewRing("http://a:8080/", []string{" http://a:8080 ", "http://a:8080"}); err != nil {
+		t.Errorf("Expected URLs to be normalized, got %v", err)
+	}
+}
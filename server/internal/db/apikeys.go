@@ -0,0 +1,130 @@
+package db
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// userKeyPrefix marks personal API keys, as apiKeyPrefix does organization
+// keys
+const userKeyPrefix = "wcu_"
+
+// CreateUserAPIKey issues a personal API key that acts as its user and
+// returns the plaintext key along with its stored description
+func (db *DB) CreateUserAPIKey(userID, name string) (string, *APIKey, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	key := userKeyPrefix + hex.EncodeToString(secret)
+
+	info := &APIKey{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Prefix:    key[:len(userKeyPrefix)+8],
+		CreatedAt: time.Now().UTC(),
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, err := db.conn.Exec(`
+		INSERT INTO api_keys (id, user_id, name, key_prefix, key_hash, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, info.ID, userID, info.Name, info.Prefix, hashAPIKey(key), info.CreatedAt)
+	if err != nil {
+		return "", nil, err
+	}
+	return key, info, nil
+}
+
+// ListUserAPIKeys returns a page of a user's personal API keys, including
+// revoked ones, oldest first, and the position the next page starts after
+func (db *DB) ListUserAPIKeys(userID string, page Page) ([]APIKey, int64, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.Query(`
+		SELECT rowid, id, name, key_prefix, created_at, last_used_at, revoked
+		FROM api_keys
+		WHERE user_id = ? AND rowid > ?
+		ORDER BY rowid
+		LIMIT ?
+	`, userID, page.After, page.limit())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	keys := make([]APIKey, 0)
+	var positions []int64
+	for rows.Next() {
+		var (
+			k        APIKey
+			position int64
+			lastUsed sql.NullTime
+			revoked  int
+		)
+		if err := rows.Scan(&position, &k.ID, &k.Name, &k.Prefix, &k.CreatedAt, &lastUsed, &revoked); err != nil {
+			return nil, 0, err
+		}
+		if lastUsed.Valid {
+			k.LastUsedAt = &lastUsed.Time
+		}
+		k.Revoked = intToBool(revoked)
+		keys = append(keys, k)
+		positions = append(positions, position)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	keys, next := trimPage(keys, positions, page)
+	return keys, next, nil
+}
+
+// RevokeUserAPIKey disables one of a user's personal API keys
+func (db *DB) RevokeUserAPIKey(userID, keyID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	result, err := db.conn.Exec(`
+		UPDATE api_keys SET revoked = 1 WHERE user_id = ? AND id = ?
+	`, userID, keyID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("API key %s not found", keyID)
+	}
+	return nil
+}
+
+// ResolveUserAPIKey returns the user an active personal API key belongs to
+// and records its use
+func (db *DB) ResolveUserAPIKey(key string) (string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	hash := hashAPIKey(key)
+
+	var userID string
+	err := db.conn.QueryRow(`
+		SELECT user_id FROM api_keys WHERE key_hash = ? AND revoked = 0
+	`, hash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("unknown or revoked API key")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.conn.Exec(`
+		UPDATE api_keys SET last_used_at = ? WHERE key_hash = ?
+	`, time.Now().UTC(), hash)
+	return userID, err
+}
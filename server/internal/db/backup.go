@@ -0,0 +1,277 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/tracing"
+)
+
+// GameArchive holds every row belonging to one game, keyed by table name.
+// Rows are stored as column maps so archives survive added columns.
+type GameArchive struct {
+	GameID string                              `json:"game_id"`
+	Tables map[string][]map[string]interface{} `json:"tables"`
+}
+
+// tableColumn describes one column from PRAGMA table_info
+type tableColumn struct {
+	name       string
+	columnType string
+	pk         int
+}
+
+// queryer runs queries on the connection or inside a transaction
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// tableColumns returns the columns of a table
+func tableColumns(conn queryer, table string) ([]tableColumn, error) {
+	rows, err := conn.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []tableColumn
+	for rows.Next() {
+		var (
+			cid, notNull int
+			col          tableColumn
+			defaultValue sql.NullString
+		)
+		if err := rows.Scan(&cid, &col.name, &col.columnType, &notNull, &defaultValue, &col.pk); err != nil {
+			return nil, err
+		}
+		columns = append(columns, col)
+	}
+	return columns, rows.Err()
+}
+
+// gameTables returns each table holding per-game rows with the column that
+// references the game. The games table itself is keyed by id.
+func gameTables(conn queryer) (map[string]string, error) {
+	rows, err := conn.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tables := map[string]string{"games": "id"}
+	for _, name := range names {
+		columns, err := tableColumns(conn, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, col := range columns {
+			if col.name == "game_id" {
+				tables[name] = "game_id"
+			}
+		}
+	}
+	return tables, nil
+}
+
+// ExportGame reads every row that belongs to a game
+func (db *DB) ExportGame(gameID string) (*GameArchive, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	tables, err := gameTables(db.conn)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &GameArchive{GameID: gameID, Tables: make(map[string][]map[string]interface{})}
+	for table, keyColumn := range tables {
+		rows, err := db.conn.Query(fmt.Sprintf("SELECT * FROM %s WHERE %s = ? ORDER BY rowid", table, keyColumn), gameID)
+		if err != nil {
+			return nil, err
+		}
+		records, err := scanRecords(rows)
+		if err != nil {
+			return nil, err
+		}
+		if table == "game_states" {
+			if err := plainSnapshotRecords(records); err != nil {
+				return nil, err
+			}
+		}
+		if len(records) > 0 {
+			archive.Tables[table] = records
+		}
+	}
+
+	if len(archive.Tables["games"]) == 0 {
+		return nil, fmt.Errorf("game %s not found", gameID)
+	}
+	return archive, nil
+}
+
+// scanRecords reads all rows into column maps and closes rows
+func scanRecords(rows *sql.Rows) ([]map[string]interface{}, error) {
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+
+		record := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			switch v := values[i].(type) {
+			case []byte:
+				record[col] = string(v)
+			case time.Time:
+				record[col] = v.UTC().Format("2006-01-02 15:04:05.999999999")
+			default:
+				record[col] = v
+			}
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// plainSnapshotRecords decompresses exported game_states rows so archives
+// hold readable JSON whatever format the rows were saved in
+func plainSnapshotRecords(records []map[string]interface{}) error {
+	for _, record := range records {
+		format, _ := record["format"].(string)
+		if format == SnapshotPlain {
+			continue
+		}
+		for _, col := range snapshotBlobColumns {
+			value, ok := record[col].(string)
+			if !ok {
+				continue
+			}
+			data, err := decodeSnapshot(format, []byte(value))
+			if err != nil {
+				return fmt.Errorf("decode %s: %w", col, err)
+			}
+			record[col] = string(data)
+		}
+		record["format"] = SnapshotPlain
+	}
+	return nil
+}
+
+// ImportGame replaces a game's rows with those from an archive in one
+// transaction. Auto-increment IDs are reassigned; columns the current
+// schema does not know are dropped.
+func (db *DB) ImportGame(archive *GameArchive) (err error) {
+	_, span := tracing.Start(context.Background(), "db.ImportGame")
+	defer tracing.End(span, &err)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	span.AddEvent("locked")
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Read the schema inside the transaction, so it runs on the same
+	// connection as the writes
+	tables, err := gameTables(tx)
+	if err != nil {
+		return err
+	}
+	for table := range archive.Tables {
+		if _, ok := tables[table]; !ok {
+			return fmt.Errorf("archive table %s does not exist in this database", table)
+		}
+	}
+
+	// Child tables first, the games row last, so foreign keys stay valid
+	for table, keyColumn := range tables {
+		if table == "games" {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s = ?", table, keyColumn), archive.GameID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM games WHERE id = ?", archive.GameID); err != nil {
+		return err
+	}
+
+	order := make([]string, 0, len(archive.Tables))
+	for table := range archive.Tables {
+		if table != "games" {
+			order = append(order, table)
+		}
+	}
+	sort.Strings(order)
+	order = append([]string{"games"}, order...)
+
+	for _, table := range order {
+		columns, err := tableColumns(tx, table)
+		if err != nil {
+			return err
+		}
+		for _, record := range archive.Tables[table] {
+			if err := insertRecord(tx, table, columns, record); err != nil {
+				return fmt.Errorf("restore %s: %w", table, err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// insertRecord inserts one archived row, skipping auto-increment keys
+func insertRecord(tx *sql.Tx, table string, columns []tableColumn, record map[string]interface{}) error {
+	var (
+		names        []string
+		placeholders []string
+		values       []interface{}
+	)
+	for _, col := range columns {
+		if col.pk == 1 && strings.EqualFold(col.columnType, "INTEGER") {
+			continue
+		}
+		value, ok := record[col.name]
+		if !ok {
+			continue
+		}
+		names = append(names, col.name)
+		placeholders = append(placeholders, "?")
+		values = append(values, value)
+	}
+
+	_, err := tx.Exec(fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) VALUES (%s)",
+		table, strings.Join(names, ", "), strings.Join(placeholders, ", ")), values...)
+	return err
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+)
+
+// Snapshot formats, recorded per game_states row in the format column
+const (
+	SnapshotPlain   = ""        // JSON text; rows written before compression
+	SnapshotDeflate = "deflate" // DEFLATE-compressed JSON
+)
+
+// snapshotFormat is the format SaveGame writes new rows in
+const snapshotFormat = SnapshotDeflate
+
+// snapshotBlobColumns are the game_states columns a snapshot format applies to
+var snapshotBlobColumns = []string{"stats_json", "tags_json", "events_json", "dag_json", "state_json"}
+
+// encodeSnapshot converts a JSON column value into a snapshot format
+func encodeSnapshot(format string, data []byte) ([]byte, error) {
+	switch format {
+	case SnapshotPlain:
+		return data, nil
+	case SnapshotDeflate:
+		var buf bytes.Buffer
+		w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unknown snapshot format %q", format)
+}
+
+// decodeSnapshot returns the JSON held by a column value in a snapshot format
+func decodeSnapshot(format string, data []byte) ([]byte, error) {
+	switch format {
+	case SnapshotPlain:
+		return data, nil
+	case SnapshotDeflate:
+		r := flate.NewReader(bytes.NewReader(data))
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+	return nil, fmt.Errorf("unknown snapshot format %q", format)
+}
@@ -0,0 +1,50 @@
+package db
+
+import "time"
+
+// EndingUnlock records that a user reached an ending in a world
+type EndingUnlock struct {
+	EndingID   string    `json:"ending_id"`
+	Tier       string    `json:"tier"`
+	GameID     string    `json:"game_id"`
+	UnlockedAt time.Time `json:"unlocked_at"`
+}
+
+// RecordEndingUnlock stores an ending unlock; repeat unlocks keep the first
+func (db *DB) RecordEndingUnlock(userID, worldKey, endingID, tier, gameID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, err := db.conn.Exec(`
+		INSERT OR IGNORE INTO ending_unlocks (user_id, world_key, ending_id, tier, game_id)
+		VALUES (?, ?, ?, ?, ?)
+	`, userID, worldKey, endingID, tier, gameID)
+	return err
+}
+
+// GetEndingUnlocks returns all endings a user has unlocked in a world
+func (db *DB) GetEndingUnlocks(userID, worldKey string) ([]EndingUnlock, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.Query(`
+		SELECT ending_id, tier, game_id, unlocked_at
+		FROM ending_unlocks
+		WHERE user_id = ? AND world_key = ?
+		ORDER BY unlocked_at
+	`, userID, worldKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	unlocks := make([]EndingUnlock, 0)
+	for rows.Next() {
+		var u EndingUnlock
+		if err := rows.Scan(&u.EndingID, &u.Tier, &u.GameID, &u.UnlockedAt); err != nil {
+			return nil, err
+		}
+		unlocks = append(unlocks, u)
+	}
+	return unlocks, rows.Err()
+}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Identity errors
+var (
+	ErrIdentityNotFound = errors.New("identity not linked")
+	ErrIdentityTaken    = errors.New("identity linked to another account")
+	ErrProviderLinked   = errors.New("another account at this provider is already linked")
+	ErrLastSignIn       = errors.New("account has no other way to sign in")
+)
+
+// Identity is an account at an OAuth provider linked to a user
+type Identity struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"` // the provider's account ID
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetUserByIdentity returns the user an identity is linked to
+func (db *DB) GetUserByIdentity(provider, subject string) (*User, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var user User
+	err := db.conn.QueryRow(`
+		SELECT u.id, u.username, u.created_at
+		FROM user_identities i JOIN users u ON u.id = i.user_id
+		WHERE i.provider = ? AND i.subject = ?
+	`, provider, subject).Scan(&user.ID, &user.Username, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrIdentityNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateUserWithIdentity registers an account without a password that signs
+// in with identity
+func (db *DB) CreateUserWithIdentity(username string, identity Identity) (*User, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user := &User{
+		ID:        uuid.New().String(),
+		Username:  username,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// An empty hash never matches, so the account has no password
+	_, err = tx.Exec(`
+		INSERT INTO users (id, username, password_hash, created_at) VALUES (?, ?, '', ?)
+	`, user.ID, user.Username, user.CreatedAt)
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return nil, ErrUsernameTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := insertIdentity(tx, user.ID, identity, user.CreatedAt); err != nil {
+		return nil, err
+	}
+	return user, tx.Commit()
+}
+
+// LinkIdentity lets a user sign in with identity too. Linking an identity
+// the user already has does nothing.
+func (db *DB) LinkIdentity(userID string, identity Identity) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var owner string
+	err := db.conn.QueryRow(`
+		SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?
+	`, identity.Provider, identity.Subject).Scan(&owner)
+	switch {
+	case err == nil && owner == userID:
+		return nil
+	case err == nil:
+		return ErrIdentityTaken
+	case err != sql.ErrNoRows:
+		return err
+	}
+	return insertIdentity(db.conn, userID, identity, time.Now().UTC())
+}
+
+// insertIdentity links an identity to a user
+func insertIdentity(conn interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}, userID string, identity Identity, linkedAt time.Time) error {
+	_, err := conn.Exec(`
+		INSERT INTO user_identities (provider, subject, user_id, email, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, identity.Provider, identity.Subject, userID, identity.Email, linkedAt)
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return ErrProviderLinked
+	}
+	return err
+}
+
+// ListIdentities returns the identities linked to a user
+func (db *DB) ListIdentities(userID string) ([]Identity, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.Query(`
+		SELECT provider, subject, email, created_at
+		FROM user_identities
+		WHERE user_id = ?
+		ORDER BY created_at, provider
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []Identity{}
+	for rows.Next() {
+		var identity Identity
+		if err := rows.Scan(&identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt); err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+// UnlinkIdentity removes a user's identity at provider, unless it is the
+// only way left to sign in
+func (db *DB) UnlinkIdentity(userID, provider string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var hash string
+	var linked, others int
+	err := db.conn.QueryRow(`
+		SELECT u.password_hash,
+			(SELECT COUNT(*) FROM user_identities WHERE user_id = u.id AND provider = ?),
+			(SELECT COUNT(*) FROM user_identities WHERE user_id = u.id AND provider != ?)
+		FROM users u WHERE u.id = ?
+	`, provider, provider, userID).Scan(&hash, &linked, &others)
+	if err == sql.ErrNoRows || (err == nil && linked == 0) {
+		return ErrIdentityNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if hash == "" && others == 0 {
+		return ErrLastSignIn
+	}
+
+	_, err = db.conn.Exec(`DELETE FROM user_identities WHERE user_id = ? AND provider = ?`, userID, provider)
+	return err
+}
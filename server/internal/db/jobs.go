@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+	"github.com/qninhdt/world-card-ai-2/server/internal/tracing"
+)
+
+// FailedJob is a card generation job that the Writer could not complete
+type FailedJob struct {
+	ID       int64            `json:"id"`
+	GameID   string           `json:"game_id"`
+	Job      *game.CardGenJob `json:"job"`
+	Error    string           `json:"error"`
+	FailedAt time.Time        `json:"failed_at"`
+}
+
+// RecordFailedJob stores a job so it can be re-queued later
+func (db *DB) RecordFailedJob(gameID string, job *game.CardGenJob, cause error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	jobJSON, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.conn.Exec(`
+		INSERT INTO failed_jobs (game_id, job_json, error)
+		VALUES (?, ?, ?)
+	`, gameID, jobJSON, cause.Error())
+	return err
+}
+
+// GetFailedJobs returns a game's failed jobs, oldest first
+func (db *DB) GetFailedJobs(gameID string) ([]FailedJob, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.Query(`
+		SELECT id, game_id, job_json, error, failed_at
+		FROM failed_jobs
+		WHERE game_id = ?
+		ORDER BY id
+	`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]FailedJob, 0)
+	for rows.Next() {
+		var (
+			f       FailedJob
+			jobJSON string
+		)
+		if err := rows.Scan(&f.ID, &f.GameID, &jobJSON, &f.Error, &f.FailedAt); err != nil {
+			return nil, err
+		}
+		f.Job = &game.CardGenJob{}
+		if err := json.Unmarshal([]byte(jobJSON), f.Job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, f)
+	}
+	return jobs, rows.Err()
+}
+
+// DeleteFailedJobs removes failed jobs by ID
+func (db *DB) DeleteFailedJobs(ids []int64) (err error) {
+	_, span := tracing.Start(context.Background(), "db.DeleteFailedJobs")
+	defer tracing.End(span, &err)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	span.AddEvent("locked")
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		if _, err := tx.Exec("DELETE FROM failed_jobs WHERE id = ?", id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
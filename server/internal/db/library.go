@@ -0,0 +1,117 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+)
+
+// ErrLibraryEmpty is returned when a theme has no pre-generated world left
+var ErrLibraryEmpty = errors.New("no pre-generated world for this theme")
+
+// LibraryTheme is how many pre-generated worlds of a theme are waiting
+type LibraryTheme struct {
+	Theme     string `json:"theme"`
+	Available int    `json:"available"`
+	Prepared  int    `json:"prepared"` // worlds with a starter deck
+}
+
+// LibraryWorld is a pre-generated world with the Writer's cards for its
+// first week, as card definitions; Deck is empty for worlds pooled without
+// one
+type LibraryWorld struct {
+	Schema *agents.WorldGenSchema
+	Deck   []map[string]interface{}
+}
+
+// AddLibraryWorld stores a pre-generated world in a theme's pool
+func (db *DB) AddLibraryWorld(theme string, world *LibraryWorld) error {
+	schema, err := json.Marshal(world.Schema)
+	if err != nil {
+		return err
+	}
+	deck := ""
+	if len(world.Deck) > 0 {
+		data, err := json.Marshal(world.Deck)
+		if err != nil {
+			return err
+		}
+		deck = string(data)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, err = db.conn.Exec(`
+		INSERT INTO world_library (theme, schema_json, deck_json) VALUES (?, ?, ?)
+	`, theme, string(schema), deck)
+	return err
+}
+
+// ClaimLibraryWorld takes the oldest world from a theme's pool, so each
+// pre-generated world starts one game
+func (db *DB) ClaimLibraryWorld(theme string) (*LibraryWorld, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		id           int64
+		schema, deck string
+	)
+	err = tx.QueryRow(`
+		SELECT id, schema_json, deck_json FROM world_library WHERE theme = ? ORDER BY id LIMIT 1
+	`, theme).Scan(&id, &schema, &deck)
+	if err == sql.ErrNoRows {
+		return nil, ErrLibraryEmpty
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("DELETE FROM world_library WHERE id = ?", id); err != nil {
+		return nil, err
+	}
+
+	world := &LibraryWorld{}
+	if err := json.Unmarshal([]byte(schema), &world.Schema); err != nil {
+		return nil, err
+	}
+	if deck != "" {
+		if err := json.Unmarshal([]byte(deck), &world.Deck); err != nil {
+			return nil, err
+		}
+	}
+	return world, tx.Commit()
+}
+
+// LibraryThemes returns each theme with worlds waiting, by name
+func (db *DB) LibraryThemes() ([]LibraryTheme, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.Query(`
+		SELECT theme, COUNT(*), COUNT(NULLIF(deck_json, ''))
+		FROM world_library GROUP BY theme ORDER BY theme
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	themes := make([]LibraryTheme, 0)
+	for rows.Next() {
+		var t LibraryTheme
+		if err := rows.Scan(&t.Theme, &t.Available, &t.Prepared); err != nil {
+			return nil, err
+		}
+		themes = append(themes, t)
+	}
+	return themes, rows.Err()
+}
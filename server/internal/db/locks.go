@@ -0,0 +1,36 @@
+package db
+
+import "time"
+
+// AcquireGameLock takes a game's mutation lock for holder, or extends it if
+// holder already has it. The lock expires after ttl so a crashed replica
+// cannot hold a game forever. It reports false if someone else holds it.
+func (db *DB) AcquireGameLock(gameID, holder string, ttl time.Duration) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	now := time.Now()
+	res, err := db.conn.Exec(`
+		INSERT INTO game_locks (game_id, holder, expires_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(game_id) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+		WHERE game_locks.holder = excluded.holder OR game_locks.expires_at <= ?
+	`, gameID, holder, now.Add(ttl).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ReleaseGameLock frees a game's mutation lock if holder still has it
+func (db *DB) ReleaseGameLock(gameID, holder string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, err := db.conn.Exec(`DELETE FROM game_locks WHERE game_id = ? AND holder = ?`, gameID, holder)
+	return err
+}
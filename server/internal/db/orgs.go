@@ -0,0 +1,490 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/tracing"
+)
+
+// Organization roles, from most to least privileged
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// apiKeyPrefix marks organization API keys so they are easy to spot in logs
+const apiKeyPrefix = "wca_"
+
+// Organization groups users, games and API keys under one billing account.
+// A zero quota means unlimited.
+type Organization struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	MaxGames   int       `json:"max_games"`
+	MaxMembers int       `json:"max_members"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// OrgMember is a user's membership in an organization
+type OrgMember struct {
+	UserID   string    `json:"user_id"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// APIKey describes an organization or personal API key. The key itself is
+// only returned once, when it is created; the database keeps its hash.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	Revoked    bool       `json:"revoked"`
+}
+
+// OrgAnalytics summarizes activity across an organization's games
+type OrgAnalytics struct {
+	Games           int `json:"games"`
+	GamesLast7Days  int `json:"games_last_7_days"`
+	Members         int `json:"members"`
+	ActiveAPIKeys   int `json:"active_api_keys"`
+	Saves           int `json:"saves"`
+	LivesPlayed     int `json:"lives_played"`
+	EndingsUnlocked int `json:"endings_unlocked"`
+
+	// LLM responses for the organization's world generations that failed
+	// to parse or validate
+	ValidationFailures []agents.ValidationFailureCount `json:"llm_validation_failures_last_7_days"`
+}
+
+// hashAPIKey returns the stored form of an API key
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateOrg creates an organization with ownerID as its first owner
+func (db *DB) CreateOrg(name, ownerID string) (_ *Organization, err error) {
+	_, span := tracing.Start(context.Background(), "db.CreateOrg")
+	defer tracing.End(span, &err)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	span.AddEvent("locked")
+
+	org := &Organization{
+		ID:        uuid.New().String(),
+		Name:      name,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO organizations (id, name, created_at) VALUES (?, ?, ?)
+	`, org.ID, org.Name, org.CreatedAt); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO org_members (org_id, user_id, role) VALUES (?, ?, ?)
+	`, org.ID, ownerID, RoleOwner); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// GetOrg returns an organization by ID
+func (db *DB) GetOrg(orgID string) (*Organization, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var org Organization
+	err := db.conn.QueryRow(`
+		SELECT id, name, max_games, max_members, created_at
+		FROM organizations WHERE id = ?
+	`, orgID).Scan(&org.ID, &org.Name, &org.MaxGames, &org.MaxMembers, &org.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("organization %s not found", orgID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// GetUserOrgs returns a page of the organizations a user belongs to, oldest
+// first, and the position the next page starts after
+func (db *DB) GetUserOrgs(userID string, page Page) ([]Organization, int64, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.Query(`
+		SELECT o.rowid, o.id, o.name, o.max_games, o.max_members, o.created_at
+		FROM organizations o
+		JOIN org_members m ON m.org_id = o.id
+		WHERE m.user_id = ? AND o.rowid > ?
+		ORDER BY o.rowid
+		LIMIT ?
+	`, userID, page.After, page.limit())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	orgs := make([]Organization, 0)
+	var positions []int64
+	for rows.Next() {
+		var (
+			org      Organization
+			position int64
+		)
+		if err := rows.Scan(&position, &org.ID, &org.Name, &org.MaxGames, &org.MaxMembers, &org.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		orgs = append(orgs, org)
+		positions = append(positions, position)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	orgs, next := trimPage(orgs, positions, page)
+	return orgs, next, nil
+}
+
+// SetOrgQuota sets an organization's game and member limits (0 = unlimited)
+func (db *DB) SetOrgQuota(orgID string, maxGames, maxMembers int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	result, err := db.conn.Exec(`
+		UPDATE organizations SET max_games = ?, max_members = ? WHERE id = ?
+	`, maxGames, maxMembers, orgID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("organization %s not found", orgID)
+	}
+	return nil
+}
+
+// AddOrgMember adds a user to an organization or changes their role
+func (db *DB) AddOrgMember(orgID, userID, role string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, err := db.conn.Exec(`
+		INSERT INTO org_members (org_id, user_id, role) VALUES (?, ?, ?)
+		ON CONFLICT (org_id, user_id) DO UPDATE SET role = excluded.role
+	`, orgID, userID, role)
+	return err
+}
+
+// RemoveOrgMember removes a user from an organization
+func (db *DB) RemoveOrgMember(orgID, userID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, err := db.conn.Exec(`
+		DELETE FROM org_members WHERE org_id = ? AND user_id = ?
+	`, orgID, userID)
+	return err
+}
+
+// GetOrgMembers returns a page of an organization's members, oldest first,
+// and the position the next page starts after
+func (db *DB) GetOrgMembers(orgID string, page Page) ([]OrgMember, int64, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.Query(`
+		SELECT rowid, user_id, role, joined_at
+		FROM org_members
+		WHERE org_id = ? AND rowid > ?
+		ORDER BY rowid
+		LIMIT ?
+	`, orgID, page.After, page.limit())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	members := make([]OrgMember, 0)
+	var positions []int64
+	for rows.Next() {
+		var (
+			m        OrgMember
+			position int64
+		)
+		if err := rows.Scan(&position, &m.UserID, &m.Role, &m.JoinedAt); err != nil {
+			return nil, 0, err
+		}
+		members = append(members, m)
+		positions = append(positions, position)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	members, next := trimPage(members, positions, page)
+	return members, next, nil
+}
+
+// GetOrgRole returns a user's role in an organization, or "" if they are
+// not a member
+func (db *DB) GetOrgRole(orgID, userID string) (string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var role string
+	err := db.conn.QueryRow(`
+		SELECT role FROM org_members WHERE org_id = ? AND user_id = ?
+	`, orgID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// CreateAPIKey issues a new API key for an organization and returns the
+// plaintext key along with its stored description
+func (db *DB) CreateAPIKey(orgID, name string) (string, *APIKey, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+
+	info := &APIKey{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Prefix:    key[:len(apiKeyPrefix)+8],
+		CreatedAt: time.Now().UTC(),
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, err := db.conn.Exec(`
+		INSERT INTO org_api_keys (id, org_id, name, key_prefix, key_hash, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, info.ID, orgID, info.Name, info.Prefix, hashAPIKey(key), info.CreatedAt)
+	if err != nil {
+		return "", nil, err
+	}
+	return key, info, nil
+}
+
+// ListAPIKeys returns a page of an organization's API keys, including
+// revoked ones, oldest first, and the position the next page starts after
+func (db *DB) ListAPIKeys(orgID string, page Page) ([]APIKey, int64, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.Query(`
+		SELECT rowid, id, name, key_prefix, created_at, last_used_at, revoked
+		FROM org_api_keys
+		WHERE org_id = ? AND rowid > ?
+		ORDER BY rowid
+		LIMIT ?
+	`, orgID, page.After, page.limit())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	keys := make([]APIKey, 0)
+	var positions []int64
+	for rows.Next() {
+		var (
+			k        APIKey
+			position int64
+			lastUsed sql.NullTime
+			revoked  int
+		)
+		if err := rows.Scan(&position, &k.ID, &k.Name, &k.Prefix, &k.CreatedAt, &lastUsed, &revoked); err != nil {
+			return nil, 0, err
+		}
+		if lastUsed.Valid {
+			k.LastUsedAt = &lastUsed.Time
+		}
+		k.Revoked = intToBool(revoked)
+		keys = append(keys, k)
+		positions = append(positions, position)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	keys, next := trimPage(keys, positions, page)
+	return keys, next, nil
+}
+
+// RevokeAPIKey disables an organization's API key
+func (db *DB) RevokeAPIKey(orgID, keyID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	result, err := db.conn.Exec(`
+		UPDATE org_api_keys SET revoked = 1 WHERE org_id = ? AND id = ?
+	`, orgID, keyID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("API key %s not found", keyID)
+	}
+	return nil
+}
+
+// ResolveAPIKey returns the organization an active API key belongs to and
+// records its use
+func (db *DB) ResolveAPIKey(key string) (string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	hash := hashAPIKey(key)
+
+	var orgID string
+	err := db.conn.QueryRow(`
+		SELECT org_id FROM org_api_keys WHERE key_hash = ? AND revoked = 0
+	`, hash).Scan(&orgID)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("unknown or revoked API key")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.conn.Exec(`
+		UPDATE org_api_keys SET last_used_at = ? WHERE key_hash = ?
+	`, time.Now().UTC(), hash)
+	return orgID, err
+}
+
+// AssignGameToOrg records that a game was created under an organization
+func (db *DB) AssignGameToOrg(gameID, orgID, createdBy string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, err := db.conn.Exec(`
+		INSERT OR REPLACE INTO org_games (game_id, org_id, created_by)
+		VALUES (?, ?, ?)
+	`, gameID, orgID, createdBy)
+	return err
+}
+
+// GetGameOrg returns the organization a game belongs to, or "" if none
+func (db *DB) GetGameOrg(gameID string) (string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var orgID string
+	err := db.conn.QueryRow(`
+		SELECT org_id FROM org_games WHERE game_id = ?
+	`, gameID).Scan(&orgID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return orgID, err
+}
+
+// GetOrgGames returns a page of the IDs of an organization's games, oldest
+// first, and the position the next page starts after
+func (db *DB) GetOrgGames(orgID string, page Page) ([]string, int64, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.Query(`
+		SELECT rowid, game_id FROM org_games WHERE org_id = ? AND rowid > ? AND `+notArchived+`
+		ORDER BY rowid
+		LIMIT ?
+	`, orgID, page.After, page.limit())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	gameIDs := make([]string, 0)
+	var positions []int64
+	for rows.Next() {
+		var (
+			id       string
+			position int64
+		)
+		if err := rows.Scan(&position, &id); err != nil {
+			return nil, 0, err
+		}
+		gameIDs = append(gameIDs, id)
+		positions = append(positions, position)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	gameIDs, next := trimPage(gameIDs, positions, page)
+	return gameIDs, next, nil
+}
+
+// GetOrgAnalytics aggregates usage across an organization's games
+func (db *DB) GetOrgAnalytics(orgID string) (*OrgAnalytics, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var a OrgAnalytics
+	since := time.Now().UTC().AddDate(0, 0, -7)
+
+	queries := []struct {
+		dest  *int
+		query string
+		args  []interface{}
+	}{
+		{&a.Games, `SELECT COUNT(*) FROM org_games WHERE org_id = ?`, []interface{}{orgID}},
+		{&a.GamesLast7Days, `SELECT COUNT(*) FROM org_games WHERE org_id = ? AND created_at >= ?`, []interface{}{orgID, since}},
+		{&a.Members, `SELECT COUNT(*) FROM org_members WHERE org_id = ?`, []interface{}{orgID}},
+		{&a.ActiveAPIKeys, `SELECT COUNT(*) FROM org_api_keys WHERE org_id = ? AND revoked = 0`, []interface{}{orgID}},
+		{&a.Saves, `
+			SELECT COUNT(*) FROM game_states s
+			JOIN org_games g ON g.game_id = s.game_id
+			WHERE g.org_id = ?`, []interface{}{orgID}},
+		{&a.LivesPlayed, `
+			SELECT COALESCE(SUM(lives), 0) FROM (
+				SELECT MAX(s.current_life) AS lives FROM game_states s
+				JOIN org_games g ON g.game_id = s.game_id
+				WHERE g.org_id = ?
+				GROUP BY s.game_id
+			)`, []interface{}{orgID}},
+		{&a.EndingsUnlocked, `
+			SELECT COUNT(*) FROM ending_unlocks e
+			JOIN org_games g ON g.game_id = e.game_id
+			WHERE g.org_id = ?`, []interface{}{orgID}},
+	}
+
+	for _, q := range queries {
+		if err := db.conn.QueryRow(q.query, q.args...).Scan(q.dest); err != nil {
+			return nil, err
+		}
+	}
+
+	failures, err := db.validationFailureCounts(orgID, since)
+	if err != nil {
+		return nil, err
+	}
+	a.ValidationFailures = failures
+	return &a, nil
+}
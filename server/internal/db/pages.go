@@ -0,0 +1,27 @@
+package db
+
+// Page selects part of a list ordered oldest first. Lists are keyed by
+// row position, so a page can continue after its last item even once that
+// item is deleted. The zero Page selects the whole list.
+type Page struct {
+	After int64 // position of the previous page's last item; 0 for the first page
+	Limit int   // 0 for no limit
+}
+
+// limit returns the LIMIT of a page query, fetching one extra row to tell
+// whether another page follows; -1 is no limit in SQLite
+func (p Page) limit() int {
+	if p.Limit <= 0 {
+		return -1
+	}
+	return p.Limit + 1
+}
+
+// trimPage drops the extra row of a page query and returns the position to
+// continue after, 0 on the last page. positions holds each item's position.
+func trimPage[T any](items []T, positions []int64, page Page) ([]T, int64) {
+	if page.Limit <= 0 || len(items) <= page.Limit {
+		return items, 0
+	}
+	return items[:page.Limit], positions[page.Limit-1]
+}
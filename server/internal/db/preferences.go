@@ -0,0 +1,119 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+)
+
+// Hint levels
+const (
+	HintsNone   = "none"   // no pending plot hints after a choice
+	HintsNormal = "normal" // the default
+)
+
+// UserPreferences are the settings that follow a user across games. Users
+// who never saved any get DefaultUserPreferences.
+type UserPreferences struct {
+	Language          string `json:"language"`           // BCP 47 tag generated text is written in
+	ContentRating     string `json:"content_rating"`     // see agents.ContentRatings
+	HintLevel         string `json:"hint_level"`         // none or normal
+	SimpleText        bool   `json:"simple_text"`        // show plain-language card text where generated
+	PolishedChronicle bool   `json:"polished_chronicle"` // have the Writer polish week chronicles in new games
+	StoryEditor       bool   `json:"story_editor"`       // check Writer batches for contradictions in new games
+	TutorialDone      bool   `json:"tutorial_done"`      // set once the tutorial was shown; set it to skip
+	NotifyEmail       bool   `json:"notify_email"`
+	NotifyPush        bool   `json:"notify_push"`
+	Telemetry         bool   `json:"telemetry"` // opt in to narrative text in gameplay dataset exports
+}
+
+// DefaultUserPreferences returns the settings of a user who saved none
+func DefaultUserPreferences() *UserPreferences {
+	return &UserPreferences{
+		Language:      "en",
+		ContentRating: agents.RatingTeen,
+		HintLevel:     HintsNormal,
+	}
+}
+
+// Generation returns the settings that shape generated text
+func (p *UserPreferences) Generation() agents.Preferences {
+	return agents.Preferences{Language: p.Language, ContentRating: p.ContentRating, SimpleText: p.SimpleText}
+}
+
+// preferenceColumns are added to user_preferences as settings are
+// introduced, so older databases gain them on start
+var preferenceColumns = []struct{ name, def string }{
+	{"language", "TEXT NOT NULL DEFAULT 'en'"},
+	{"content_rating", "TEXT NOT NULL DEFAULT 'teen'"},
+	{"hint_level", "TEXT NOT NULL DEFAULT 'normal'"},
+	{"simple_text", "INTEGER NOT NULL DEFAULT 0"},
+	{"polished_chronicle", "INTEGER NOT NULL DEFAULT 0"},
+	{"story_editor", "INTEGER NOT NULL DEFAULT 0"},
+	{"tutorial_done", "INTEGER NOT NULL DEFAULT 0"},
+	{"notify_email", "INTEGER NOT NULL DEFAULT 0"},
+	{"notify_push", "INTEGER NOT NULL DEFAULT 0"},
+	{"telemetry", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// migratePreferences adds missing setting columns to user_preferences
+func (db *DB) migratePreferences() error {
+	for _, col := range preferenceColumns {
+		if err := db.addColumnIfMissing("user_preferences", col.name, col.def); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetUserPreferences returns a user's settings
+func (db *DB) GetUserPreferences(userID string) (*UserPreferences, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	prefs := DefaultUserPreferences()
+	var simpleText, chronicle, editor, tutorialDone, notifyEmail, notifyPush, telemetry int
+	err := db.conn.QueryRow(`
+		SELECT language, content_rating, hint_level, simple_text, polished_chronicle, story_editor, tutorial_done, notify_email, notify_push, telemetry
+		FROM user_preferences WHERE user_id = ?
+	`, userID).Scan(&prefs.Language, &prefs.ContentRating, &prefs.HintLevel, &simpleText, &chronicle, &editor, &tutorialDone, &notifyEmail, &notifyPush, &telemetry)
+	if err == sql.ErrNoRows {
+		return prefs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	prefs.SimpleText = intToBool(simpleText)
+	prefs.PolishedChronicle = intToBool(chronicle)
+	prefs.StoryEditor = intToBool(editor)
+	prefs.TutorialDone = intToBool(tutorialDone)
+	prefs.NotifyEmail = intToBool(notifyEmail)
+	prefs.NotifyPush = intToBool(notifyPush)
+	prefs.Telemetry = intToBool(telemetry)
+	return prefs, nil
+}
+
+// SetUserPreferences replaces a user's settings
+func (db *DB) SetUserPreferences(userID string, prefs *UserPreferences) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, err := db.conn.Exec(`
+		INSERT INTO user_preferences (user_id, language, content_rating, hint_level, simple_text, polished_chronicle, story_editor, tutorial_done, notify_email, notify_push, telemetry, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			language = excluded.language,
+			content_rating = excluded.content_rating,
+			hint_level = excluded.hint_level,
+			simple_text = excluded.simple_text,
+			polished_chronicle = excluded.polished_chronicle,
+			story_editor = excluded.story_editor,
+			tutorial_done = excluded.tutorial_done,
+			notify_email = excluded.notify_email,
+			notify_push = excluded.notify_push,
+			telemetry = excluded.telemetry,
+			updated_at = excluded.updated_at
+	`, userID, prefs.Language, prefs.ContentRating, prefs.HintLevel, prefs.SimpleText, prefs.PolishedChronicle, prefs.StoryEditor, prefs.TutorialDone, prefs.NotifyEmail, prefs.NotifyPush, prefs.Telemetry, time.Now().UTC())
+	return err
+}
@@ -0,0 +1,203 @@
+package db
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// refreshTokenPrefix marks refresh tokens, as userKeyPrefix does API keys
+const refreshTokenPrefix = "wcr_"
+
+// maxUserAgentLength caps the user agent stored to describe a session
+const maxUserAgentLength = 200
+
+// Session errors
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionExpired  = errors.New("session expired or revoked")
+)
+
+// Session is a login on one device. Its refresh token is only handed out
+// when the session is created or refreshed; a used one is replaced.
+type Session struct {
+	ID          string    `json:"id"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	RefreshedAt time.Time `json:"refreshed_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Current     bool      `json:"current,omitempty"` // the session listing them
+}
+
+// newRefreshToken returns a fresh refresh token
+func newRefreshToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return refreshTokenPrefix + hex.EncodeToString(secret), nil
+}
+
+// CreateSession starts a session for a user lasting ttl from its last
+// refresh, and returns its refresh token
+func (db *DB) CreateSession(userID, userAgent string, ttl time.Duration) (string, *Session, error) {
+	token, err := newRefreshToken()
+	if err != nil {
+		return "", nil, err
+	}
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	now := time.Now().UTC()
+	session := &Session{
+		ID:          uuid.New().String(),
+		UserAgent:   userAgent,
+		CreatedAt:   now,
+		RefreshedAt: now,
+		ExpiresAt:   now.Add(ttl),
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, err = db.conn.Exec(`
+		INSERT INTO sessions (id, user_id, refresh_hash, user_agent, created_at, refreshed_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, session.ID, userID, hashAPIKey(token), session.UserAgent, session.CreatedAt, session.RefreshedAt, session.ExpiresAt)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, session, nil
+}
+
+// RefreshSession trades a refresh token for a new one and extends its
+// session by ttl, returning the session's user. A token that was already
+// traded in means it leaked, so its session is revoked.
+func (db *DB) RefreshSession(refreshToken string, ttl time.Duration) (string, string, *Session, error) {
+	next, err := newRefreshToken()
+	if err != nil {
+		return "", "", nil, err
+	}
+	hash := hashAPIKey(refreshToken)
+	now := time.Now().UTC()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var userID string
+	var session Session
+	err = db.conn.QueryRow(`
+		SELECT id, user_id, user_agent, created_at
+		FROM sessions
+		WHERE refresh_hash = ? AND revoked_at IS NULL AND expires_at > ?
+	`, hash, now).Scan(&session.ID, &userID, &session.UserAgent, &session.CreatedAt)
+	if err == sql.ErrNoRows {
+		// SECURITY FIX: Replaying a traded-in token ends the session for
+		// both the thief and the owner
+		_, err = db.conn.Exec(`
+			UPDATE sessions SET revoked_at = ? WHERE previous_hash = ? AND revoked_at IS NULL
+		`, now, hash)
+		if err != nil {
+			return "", "", nil, err
+		}
+		return "", "", nil, ErrSessionExpired
+	}
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	session.RefreshedAt = now
+	session.ExpiresAt = now.Add(ttl)
+	_, err = db.conn.Exec(`
+		UPDATE sessions SET refresh_hash = ?, previous_hash = ?, refreshed_at = ?, expires_at = ?
+		WHERE id = ?
+	`, hashAPIKey(next), hash, session.RefreshedAt, session.ExpiresAt, session.ID)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return userID, next, &session, nil
+}
+
+// ResolveSession returns the user of a session that is neither expired nor
+// revoked
+func (db *DB) ResolveSession(sessionID string) (string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var userID string
+	err := db.conn.QueryRow(`
+		SELECT user_id FROM sessions WHERE id = ? AND revoked_at IS NULL AND expires_at > ?
+	`, sessionID, time.Now().UTC()).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "", ErrSessionExpired
+	}
+	if err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+// ListSessions returns a user's active sessions, most recently refreshed
+// first
+func (db *DB) ListSessions(userID string) ([]Session, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.Query(`
+		SELECT id, user_agent, created_at, refreshed_at, expires_at
+		FROM sessions
+		WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ?
+		ORDER BY refreshed_at DESC, id
+	`, userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(&s.ID, &s.UserAgent, &s.CreatedAt, &s.RefreshedAt, &s.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeSession ends one of a user's sessions; its tokens stop working at
+// once
+func (db *DB) RevokeSession(userID, sessionID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	res, err := db.conn.Exec(`
+		UPDATE sessions SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL
+	`, time.Now().UTC(), sessionID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeUserSessions ends every session of a user, returning how many
+func (db *DB) RevokeUserSessions(userID string) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	res, err := db.conn.Exec(`
+		UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL
+	`, time.Now().UTC(), userID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ShareTokenPrefix marks public share tokens, like apiKeyPrefix for API keys
+const ShareTokenPrefix = "wcs_"
+
+// StatPoint is one saved state of a game's stats, for charts
+type StatPoint struct {
+	Day         int            `json:"day"`
+	Season      int            `json:"season"`
+	Year        int            `json:"year_in_game"`
+	CurrentLife int            `json:"current_life"`
+	IsAlive     bool           `json:"is_alive"`
+	DeathCause  string         `json:"death_cause,omitempty"`
+	Stats       map[string]int `json:"stats"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+// CreateShareToken issues a public token for a game's recap. Only the hash
+// is stored, so the token is returned once.
+func (db *DB) CreateShareToken(gameID, userID string) (string, error) {
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := ShareTokenPrefix + hex.EncodeToString(secret)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, err := db.conn.Exec(`
+		INSERT INTO game_shares (token_hash, game_id, created_by, created_at)
+		VALUES (?, ?, ?, ?)
+	`, hashAPIKey(token), gameID, userID, time.Now().UTC())
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ResolveShareToken returns the game a share token points to
+func (db *DB) ResolveShareToken(token string) (string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var gameID string
+	err := db.conn.QueryRow(`
+		SELECT game_id FROM game_shares WHERE token_hash = ? AND `+notArchived+`
+	`, hashAPIKey(token)).Scan(&gameID)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("unknown or revoked share token")
+	}
+	return gameID, err
+}
+
+// RevokeShareTokens removes every share token of a game
+func (db *DB) RevokeShareTokens(gameID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, err := db.conn.Exec(`DELETE FROM game_shares WHERE game_id = ?`, gameID)
+	return err
+}
+
+// GetStatHistory returns the stats of every saved state of a game, oldest
+// first
+func (db *DB) GetStatHistory(gameID string) ([]StatPoint, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.Query(`
+		SELECT day, season, year_in_game, current_life, is_alive, death_cause, stats_json, format, created_at
+		FROM game_states
+		WHERE game_id = ?
+		ORDER BY id
+	`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := make([]StatPoint, 0)
+	for rows.Next() {
+		var (
+			p          StatPoint
+			isAlive    int
+			deathCause sql.NullString
+			statsJSON  []byte
+			format     string
+		)
+		if err := rows.Scan(&p.Day, &p.Season, &p.Year, &p.CurrentLife, &isAlive, &deathCause, &statsJSON, &format, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		statsJSON, err := decodeSnapshot(format, statsJSON)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(statsJSON, &p.Stats); err != nil {
+			return nil, err
+		}
+		p.IsAlive = intToBool(isAlive)
+		p.DeathCause = deathCause.String
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+	"github.com/qninhdt/world-card-ai-2/server/internal/story"
+)
+
+// Snapshot describes one saved state of a game
+type Snapshot struct {
+	ID          int64     `json:"id"`
+	Day         int       `json:"day"`
+	Season      int       `json:"season"`
+	Year        int       `json:"year_in_game"`
+	IsAlive     bool      `json:"is_alive"`
+	CurrentLife int       `json:"current_life"`
+	Autosave    bool      `json:"autosave,omitempty"` // the latest autosave, replaced by the next save
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ListSnapshots returns a page of a game's saved states, oldest first, and
+// the ID the next page starts after
+func (db *DB) ListSnapshots(gameID string, page Page) ([]Snapshot, int64, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.Query(`
+		SELECT id, day, season, year_in_game, is_alive, current_life, autosave, created_at
+		FROM game_states
+		WHERE game_id = ? AND id > ?
+		ORDER BY id
+		LIMIT ?
+	`, gameID, page.After, page.limit())
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	snapshots := make([]Snapshot, 0)
+	var ids []int64
+	for rows.Next() {
+		var (
+			s                 Snapshot
+			isAlive, autosave int
+		)
+		if err := rows.Scan(&s.ID, &s.Day, &s.Season, &s.Year, &isAlive, &s.CurrentLife, &autosave, &s.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		s.IsAlive = intToBool(isAlive)
+		s.Autosave = intToBool(autosave)
+		snapshots = append(snapshots, s)
+		ids = append(ids, s.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	snapshots, next := trimPage(snapshots, ids, page)
+	return snapshots, next, nil
+}
+
+// LoadSnapshot loads a specific saved state of a game
+func (db *DB) LoadSnapshot(gameID string, snapshotID int64) (*game.GlobalBlackboard, *story.MacroDAG, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	row := db.conn.QueryRow(`
+		SELECT `+snapshotColumns+`
+		FROM game_states
+		WHERE game_id = ? AND id = ?
+	`, gameID, snapshotID)
+	return scanSnapshot(row)
+}
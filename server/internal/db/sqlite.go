@@ -1,13 +1,16 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"sync"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/qninhdt/world-card-ai-2/server/internal/game"
 	"github.com/qninhdt/world-card-ai-2/server/internal/story"
+	"github.com/qninhdt/world-card-ai-2/server/internal/tracing"
 )
 
 // DB wraps database operations
@@ -102,13 +105,225 @@ func (db *DB) migrate() error {
 		FOREIGN KEY (game_id) REFERENCES games(id) ON DELETE CASCADE
 	);
 
+	CREATE TABLE IF NOT EXISTS ending_unlocks (
+		user_id TEXT NOT NULL,
+		world_key TEXT NOT NULL,
+		ending_id TEXT NOT NULL,
+		tier TEXT NOT NULL,
+		game_id TEXT NOT NULL,
+		unlocked_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, world_key, ending_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS failed_jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		game_id TEXT NOT NULL,
+		job_json TEXT NOT NULL,
+		error TEXT NOT NULL,
+		failed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS llm_usage (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		model TEXT NOT NULL,
+		prompt_tokens INTEGER NOT NULL,
+		completion_tokens INTEGER NOT NULL,
+		cost_usd REAL NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS llm_validation_failures (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		agent TEXT NOT NULL,
+		class TEXT NOT NULL,
+		model TEXT NOT NULL,
+		prompt_variant TEXT NOT NULL,
+		org_id TEXT NOT NULL DEFAULT '',
+		detail TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS users (
+		id TEXT PRIMARY KEY,
+		username TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		password_hash TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS sessions (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		refresh_hash TEXT NOT NULL UNIQUE,
+		previous_hash TEXT,
+		user_agent TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		refreshed_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		revoked_at DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS user_identities (
+		provider TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		email TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (provider, subject),
+		UNIQUE (user_id, provider)
+	);
+
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		key_prefix TEXT NOT NULL,
+		key_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_used_at DATETIME,
+		revoked INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS user_preferences (
+		user_id TEXT PRIMARY KEY,
+		updated_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS organizations (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		max_games INTEGER NOT NULL DEFAULT 0,
+		max_members INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS org_members (
+		org_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		role TEXT NOT NULL,
+		joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (org_id, user_id),
+		FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS org_api_keys (
+		id TEXT PRIMARY KEY,
+		org_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		key_prefix TEXT NOT NULL,
+		key_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_used_at DATETIME,
+		revoked INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS org_games (
+		game_id TEXT PRIMARY KEY,
+		org_id TEXT NOT NULL,
+		created_by TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS game_shares (
+		token_hash TEXT PRIMARY KEY,
+		game_id TEXT NOT NULL,
+		created_by TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (game_id) REFERENCES games(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS world_library (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		theme TEXT NOT NULL,
+		schema_json TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS game_locks (
+		game_id TEXT PRIMARY KEY,
+		holder TEXT NOT NULL,
+		expires_at INTEGER NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
+	CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+	CREATE INDEX IF NOT EXISTS idx_sessions_previous_hash ON sessions(previous_hash);
 	CREATE INDEX IF NOT EXISTS idx_game_states_game_id ON game_states(game_id);
+	CREATE INDEX IF NOT EXISTS idx_failed_jobs_game_id ON failed_jobs(game_id);
+	CREATE INDEX IF NOT EXISTS idx_llm_usage_created_at ON llm_usage(created_at);
+	CREATE INDEX IF NOT EXISTS idx_llm_validation_failures_created_at ON llm_validation_failures(created_at);
 	CREATE INDEX IF NOT EXISTS idx_dag_nodes_game_id ON dag_nodes(game_id);
 	CREATE INDEX IF NOT EXISTS idx_dag_edges_game_id ON dag_edges(game_id);
 	CREATE INDEX IF NOT EXISTS idx_game_ownership_user_id ON game_ownership(user_id);
+	CREATE INDEX IF NOT EXISTS idx_org_members_user_id ON org_members(user_id);
+	CREATE INDEX IF NOT EXISTS idx_org_api_keys_org_id ON org_api_keys(org_id);
+	CREATE INDEX IF NOT EXISTS idx_org_games_org_id ON org_games(org_id);
+	CREATE INDEX IF NOT EXISTS idx_game_shares_game_id ON game_shares(game_id);
+	CREATE INDEX IF NOT EXISTS idx_world_library_theme ON world_library(theme);
 	`
 
-	_, err := db.conn.Exec(schema)
+	if _, err := db.conn.Exec(schema); err != nil {
+		return err
+	}
+
+	// Full blackboard snapshot; older rows only have the split columns
+	if err := db.addColumnIfMissing("game_states", "state_json", "TEXT"); err != nil {
+		return err
+	}
+	// How the JSON columns are stored; older rows are plain text
+	if err := db.addColumnIfMissing("game_states", "format", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	// The latest autosave is replaced rather than kept
+	if err := db.addColumnIfMissing("game_states", "autosave", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	// Blackboard version of each snapshot, so replicas can tell a stale copy
+	if err := db.addColumnIfMissing("game_states", "version", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	// Soft-deleted games keep their rows but leave every listing
+	if err := db.addColumnIfMissing("games", "archived_at", "DATETIME"); err != nil {
+		return err
+	}
+	// Starter decks; worlds pooled before them have none
+	if err := db.addColumnIfMissing("world_library", "deck_json", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	return db.migratePreferences()
+}
+
+// notArchived filters a query on a table with a game_id column down to
+// games that are not archived
+const notArchived = `NOT EXISTS (SELECT 1 FROM games g WHERE g.id = game_id AND g.archived_at IS NOT NULL)`
+
+// addColumnIfMissing adds a column to an existing table
+func (db *DB) addColumnIfMissing(table, column, columnType string) error {
+	rows, err := db.conn.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, colType    string
+			defaultValue     sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.conn.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, columnType))
 	return err
 }
 
@@ -131,7 +346,7 @@ func (db *DB) GetGameOwner(gameID string) (string, error) {
 
 	var userID string
 	err := db.conn.QueryRow(`
-		SELECT user_id FROM game_ownership WHERE game_id = ?
+		SELECT user_id FROM game_ownership WHERE game_id = ? AND `+notArchived+`
 	`, gameID).Scan(&userID)
 
 	if err != nil {
@@ -155,7 +370,8 @@ func (db *DB) GetUserGames(userID string) ([]string, error) {
 	defer db.mu.RUnlock()
 
 	rows, err := db.conn.Query(`
-		SELECT game_id FROM game_ownership WHERE user_id = ? ORDER BY created_at DESC
+		SELECT game_id FROM game_ownership WHERE user_id = ? AND `+notArchived+`
+		ORDER BY created_at DESC
 	`, userID)
 	if err != nil {
 		return nil, err
@@ -176,8 +392,31 @@ func (db *DB) GetUserGames(userID string) ([]string, error) {
 
 // SaveGame saves a game and its state
 func (db *DB) SaveGame(gameID string, state *game.GlobalBlackboard, dag *story.MacroDAG) error {
+	return db.SaveGameContext(context.Background(), gameID, state, dag)
+}
+
+// SaveGameContext saves a game and its state, traced as part of ctx. The
+// save is not cancelled with ctx.
+func (db *DB) SaveGameContext(ctx context.Context, gameID string, state *game.GlobalBlackboard, dag *story.MacroDAG) error {
+	return db.saveGame(ctx, "db.SaveGame", gameID, state, dag, false)
+}
+
+// AutosaveGame saves a game after an action. Only the latest autosave is
+// kept: it replaces the previous one unless a save came since, and the next
+// save replaces it in turn, so the history only grows with saves.
+func (db *DB) AutosaveGame(ctx context.Context, gameID string, state *game.GlobalBlackboard, dag *story.MacroDAG) error {
+	return db.saveGame(ctx, "db.AutosaveGame", gameID, state, dag, true)
+}
+
+// saveGame writes a game's state, replacing the latest snapshot if it was
+// an autosave
+func (db *DB) saveGame(ctx context.Context, spanName, gameID string, state *game.GlobalBlackboard, dag *story.MacroDAG, autosave bool) (err error) {
+	_, span := tracing.Start(ctx, spanName)
+	defer tracing.End(span, &err)
+
 	db.mu.Lock()
 	defer db.mu.Unlock()
+	span.AddEvent("locked")
 
 	tx, err := db.conn.Begin()
 	if err != nil {
@@ -200,15 +439,46 @@ func (db *DB) SaveGame(gameID string, state *game.GlobalBlackboard, dag *story.M
 	tagsJSON, _ := json.Marshal(state.Tags)
 	eventsJSON, _ := json.Marshal(state.Events)
 	dagJSON, _ := json.Marshal(dag)
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	blobs := [][]byte{statsJSON, tagsJSON, eventsJSON, dagJSON, stateJSON}
+	for i := range blobs {
+		if blobs[i], err = encodeSnapshot(snapshotFormat, blobs[i]); err != nil {
+			return err
+		}
+	}
 
-	// Insert game state
-	_, err = tx.Exec(`
-		INSERT INTO game_states (
-			game_id, day, season, year_in_game, stats_json, tags_json, events_json, dag_json,
-			is_alive, current_life, death_cause, death_turn
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, gameID, state.Day, state.Season, state.Year, statsJSON, tagsJSON, eventsJSON, dagJSON,
-		boolToInt(state.IsAlive), state.CurrentLife, state.DeathCause, state.DeathTurn)
+	// Replace a trailing autosave, or add a snapshot
+	var latestID int64
+	var latestAutosave int
+	err = tx.QueryRow(`
+		SELECT id, autosave FROM game_states WHERE game_id = ? ORDER BY id DESC LIMIT 1
+	`, gameID).Scan(&latestID, &latestAutosave)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if latestAutosave == 1 {
+		_, err = tx.Exec(`
+			UPDATE game_states SET
+				day = ?, season = ?, year_in_game = ?, stats_json = ?, tags_json = ?, events_json = ?, dag_json = ?,
+				is_alive = ?, current_life = ?, death_cause = ?, death_turn = ?, state_json = ?, format = ?,
+				autosave = ?, version = ?, created_at = CURRENT_TIMESTAMP
+			WHERE id = ?
+		`, state.Day, state.Season, state.Year, blobs[0], blobs[1], blobs[2], blobs[3],
+			boolToInt(state.IsAlive), state.CurrentLife, state.DeathCause, state.DeathTurn, blobs[4], snapshotFormat,
+			boolToInt(autosave), state.Version, latestID)
+	} else {
+		_, err = tx.Exec(`
+			INSERT INTO game_states (
+				game_id, day, season, year_in_game, stats_json, tags_json, events_json, dag_json,
+				is_alive, current_life, death_cause, death_turn, state_json, format, autosave, version
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, gameID, state.Day, state.Season, state.Year, blobs[0], blobs[1], blobs[2], blobs[3],
+			boolToInt(state.IsAlive), state.CurrentLife, state.DeathCause, state.DeathTurn, blobs[4], snapshotFormat,
+			boolToInt(autosave), state.Version)
+	}
 	if err != nil {
 		return err
 	}
@@ -239,52 +509,96 @@ func (db *DB) LoadGame(gameID string) (*game.GlobalBlackboard, *story.MacroDAG,
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
-	// Load latest game state
+	row := db.conn.QueryRow(`
+		SELECT `+snapshotColumns+`
+		FROM game_states
+		WHERE game_id = ?
+		ORDER BY id DESC
+		LIMIT 1
+	`, gameID)
+	return scanSnapshot(row)
+}
+
+// LatestGameVersion returns the blackboard version of a game's latest
+// snapshot, or sql.ErrNoRows if it was never saved
+func (db *DB) LatestGameVersion(gameID string) (int64, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var version int64
+	err := db.conn.QueryRow(`
+		SELECT version FROM game_states WHERE game_id = ? ORDER BY id DESC LIMIT 1
+	`, gameID).Scan(&version)
+	return version, err
+}
+
+// snapshotColumns are the game_states columns read by scanSnapshot
+const snapshotColumns = `day, season, year_in_game, stats_json, tags_json, events_json, dag_json,
+		       is_alive, current_life, death_cause, death_turn, state_json, format`
+
+// scanSnapshot decodes a game_states row selected with snapshotColumns
+func scanSnapshot(row *sql.Row) (*game.GlobalBlackboard, *story.MacroDAG, error) {
 	var (
 		day, season, yearInGame, isAlive, currentLife, deathTurn int
-		statsJSON, tagsJSON, eventsJSON, dagJSON                 string
+		statsJSON, tagsJSON, eventsJSON, dagJSON, stateJSON      []byte
 		deathCause                                               sql.NullString
+		format                                                   string
 	)
 
-	err := db.conn.QueryRow(`
-		SELECT day, season, year_in_game, stats_json, tags_json, events_json, dag_json,
-		       is_alive, current_life, death_cause, death_turn
-		FROM game_states
-		WHERE game_id = ?
-		ORDER BY created_at DESC
-		LIMIT 1
-	`, gameID).Scan(&day, &season, &yearInGame, &statsJSON, &tagsJSON, &eventsJSON, &dagJSON,
-		&isAlive, &currentLife, &deathCause, &deathTurn)
-
+	err := row.Scan(&day, &season, &yearInGame, &statsJSON, &tagsJSON, &eventsJSON, &dagJSON,
+		&isAlive, &currentLife, &deathCause, &deathTurn, &stateJSON, &format)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	// Decompress the JSON columns
+	for _, blob := range []*[]byte{&statsJSON, &tagsJSON, &eventsJSON, &dagJSON, &stateJSON} {
+		if *blob, err = decodeSnapshot(format, *blob); err != nil {
+			return nil, nil, fmt.Errorf("decode snapshot: %w", err)
+		}
+	}
+
 	// Deserialize state
 	state := &game.GlobalBlackboard{}
-	if err := json.Unmarshal([]byte(statsJSON), &state.Stats); err != nil {
-		return nil, nil, err
-	}
-	if err := json.Unmarshal([]byte(tagsJSON), &state.Tags); err != nil {
-		return nil, nil, err
-	}
-	if err := json.Unmarshal([]byte(eventsJSON), &state.Events); err != nil {
-		return nil, nil, err
-	}
+	if len(stateJSON) > 0 {
+		if err := json.Unmarshal(stateJSON, state); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		// Legacy rows only carry the split columns
+		if err := json.Unmarshal(statsJSON, &state.Stats); err != nil {
+			return nil, nil, err
+		}
+		if err := json.Unmarshal(tagsJSON, &state.Tags); err != nil {
+			return nil, nil, err
+		}
+		var rawEvents map[string]json.RawMessage
+		if err := json.Unmarshal(eventsJSON, &rawEvents); err != nil {
+			return nil, nil, err
+		}
+		state.Events = make(map[string]game.Event, len(rawEvents))
+		for id, raw := range rawEvents {
+			event, err := game.UnmarshalEvent(raw)
+			if err != nil {
+				return nil, nil, err
+			}
+			state.Events[id] = event
+		}
 
-	state.Day = day
-	state.Season = season
-	state.Year = yearInGame
-	state.IsAlive = intToBool(isAlive)
-	state.CurrentLife = currentLife
-	if deathCause.Valid {
-		state.DeathCause = deathCause.String
+		state.Day = day
+		state.Season = season
+		state.Year = yearInGame
+		state.IsAlive = intToBool(isAlive)
+		state.CurrentLife = currentLife
+		if deathCause.Valid {
+			state.DeathCause = deathCause.String
+		}
+		state.DeathTurn = deathTurn
 	}
-	state.DeathTurn = deathTurn
 
 	// Deserialize DAG
 	dag := story.NewMacroDAG()
-	if err := json.Unmarshal([]byte(dagJSON), dag); err != nil {
+	if err := json.Unmarshal(dagJSON, dag); err != nil {
 		return nil, nil, err
 	}
 
@@ -314,13 +628,60 @@ func (db *DB) GetGameList() ([]string, error) {
 	return gameIDs, rows.Err()
 }
 
-// DeleteGame deletes a game and all its data
-func (db *DB) DeleteGame(gameID string) error {
+// keptOnDelete are per-game tables whose rows outlive the game: unlocked
+// endings belong to the player's collection
+var keptOnDelete = map[string]bool{"ending_unlocks": true}
+
+// DeleteGame deletes a game and all its data in one transaction. Foreign
+// keys are not enforced, so every table referencing the game is cleared.
+func (db *DB) DeleteGame(gameID string) (err error) {
+	_, span := tracing.Start(context.Background(), "db.DeleteGame")
+	defer tracing.End(span, &err)
+
 	db.mu.Lock()
 	defer db.mu.Unlock()
+	span.AddEvent("locked")
 
-	_, err := db.conn.Exec("DELETE FROM games WHERE id = ?", gameID)
-	return err
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	tables, err := gameTables(tx)
+	if err != nil {
+		return err
+	}
+
+	for table, keyColumn := range tables {
+		if keptOnDelete[table] {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s = ?", table, keyColumn), gameID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ArchiveGame soft-deletes a saved game. Its rows are kept, but it no longer
+// has an owner, appears in listings or resolves share links.
+func (db *DB) ArchiveGame(gameID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	res, err := db.conn.Exec(`
+		UPDATE games SET archived_at = CURRENT_TIMESTAMP WHERE id = ? AND archived_at IS NULL
+	`, gameID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("game %s not found or already archived", gameID)
+	}
+	return nil
 }
 
 // Helper functions
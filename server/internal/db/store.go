@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+	"github.com/qninhdt/world-card-ai-2/server/internal/story"
+)
+
+// Store is the persistence interface used by the API server and admin tools
+type Store interface {
+	// Games and snapshots
+	SaveGame(gameID string, state *game.GlobalBlackboard, dag *story.MacroDAG) error
+	SaveGameContext(ctx context.Context, gameID string, state *game.GlobalBlackboard, dag *story.MacroDAG) error
+	AutosaveGame(ctx context.Context, gameID string, state *game.GlobalBlackboard, dag *story.MacroDAG) error
+	LoadGame(gameID string) (*game.GlobalBlackboard, *story.MacroDAG, error)
+	LatestGameVersion(gameID string) (int64, error)
+	GetGameList() ([]string, error)
+	DeleteGame(gameID string) error
+	ArchiveGame(gameID string) error
+	ListSnapshots(gameID string, page Page) ([]Snapshot, int64, error)
+	LoadSnapshot(gameID string, snapshotID int64) (*game.GlobalBlackboard, *story.MacroDAG, error)
+
+	// Mutation locks shared by replicas
+	AcquireGameLock(gameID, holder string, ttl time.Duration) (bool, error)
+	ReleaseGameLock(gameID, holder string) error
+
+	// Accounts
+	CreateUser(username, passwordHash string) (*User, error)
+	GetUser(userID string) (*User, error)
+	GetUserByUsername(username string) (*User, string, error)
+	CreateSession(userID, userAgent string, ttl time.Duration) (string, *Session, error)
+	RefreshSession(refreshToken string, ttl time.Duration) (string, string, *Session, error)
+	ResolveSession(sessionID string) (string, error)
+	ListSessions(userID string) ([]Session, error)
+	RevokeSession(userID, sessionID string) error
+	RevokeUserSessions(userID string) (int, error)
+	GetUserByIdentity(provider, subject string) (*User, error)
+	CreateUserWithIdentity(username string, identity Identity) (*User, error)
+	LinkIdentity(userID string, identity Identity) error
+	ListIdentities(userID string) ([]Identity, error)
+	UnlinkIdentity(userID, provider string) error
+	GetUserPreferences(userID string) (*UserPreferences, error)
+	SetUserPreferences(userID string, prefs *UserPreferences) error
+	CreateUserAPIKey(userID, name string) (string, *APIKey, error)
+	ListUserAPIKeys(userID string, page Page) ([]APIKey, int64, error)
+	RevokeUserAPIKey(userID, keyID string) error
+	ResolveUserAPIKey(key string) (string, error)
+
+	// Ownership
+	SaveGameOwnership(gameID, userID string) error
+	GetGameOwner(gameID string) (string, error)
+	IsGameOwner(gameID, userID string) (bool, error)
+	GetUserGames(userID string) ([]string, error)
+	ListUserGames(userID string, opts GameListOptions) ([]GameSummary, int, error)
+
+	// Endings
+	RecordEndingUnlock(userID, worldKey, endingID, tier, gameID string) error
+	GetEndingUnlocks(userID, worldKey string) ([]EndingUnlock, error)
+
+	// Pre-generated worlds
+	AddLibraryWorld(theme string, world *LibraryWorld) error
+	ClaimLibraryWorld(theme string) (*LibraryWorld, error)
+	LibraryThemes() ([]LibraryTheme, error)
+
+	// Public recaps
+	CreateShareToken(gameID, userID string) (string, error)
+	ResolveShareToken(token string) (string, error)
+	RevokeShareTokens(gameID string) error
+	GetStatHistory(gameID string) ([]StatPoint, error)
+
+	// Generation jobs
+	RecordFailedJob(gameID string, job *game.CardGenJob, cause error) error
+	GetFailedJobs(gameID string) ([]FailedJob, error)
+	DeleteFailedJobs(ids []int64) error
+
+	// Organizations
+	CreateOrg(name, ownerID string) (*Organization, error)
+	GetOrg(orgID string) (*Organization, error)
+	GetUserOrgs(userID string, page Page) ([]Organization, int64, error)
+	SetOrgQuota(orgID string, maxGames, maxMembers int) error
+	AddOrgMember(orgID, userID, role string) error
+	RemoveOrgMember(orgID, userID string) error
+	GetOrgMembers(orgID string, page Page) ([]OrgMember, int64, error)
+	GetOrgRole(orgID, userID string) (string, error)
+	CreateAPIKey(orgID, name string) (string, *APIKey, error)
+	ListAPIKeys(orgID string, page Page) ([]APIKey, int64, error)
+	RevokeAPIKey(orgID, keyID string) error
+	ResolveAPIKey(key string) (string, error)
+	AssignGameToOrg(gameID, orgID, createdBy string) error
+	GetGameOrg(gameID string) (string, error)
+	GetOrgGames(orgID string, page Page) ([]string, int64, error)
+	GetOrgAnalytics(orgID string) (*OrgAnalytics, error)
+
+	// LLM usage
+	agents.UsageStore
+	RecentUsage(limit int) ([]agents.UsageRecord, error)
+	agents.ValidationStore
+	ValidationFailureCounts(orgID string, since time.Time) ([]agents.ValidationFailureCount, error)
+
+	Close() error
+}
+
+var _ Store = (*DB)(nil)
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Game list orderings
+const (
+	SortLastPlayed = "last_played" // most recently saved first
+	SortCreated    = "created"     // newest first
+	SortName       = "name"        // world name, A to Z
+)
+
+// gameSortOrders maps each ordering to its ORDER BY clause. Game IDs break
+// ties so pages do not overlap.
+var gameSortOrders = map[string]string{
+	SortLastPlayed: "COALESCE(s.created_at, o.created_at) DESC, o.game_id",
+	SortCreated:    "o.created_at DESC, o.game_id",
+	SortName:       "g.name IS NULL, g.name COLLATE NOCASE, o.game_id",
+}
+
+// GameSummary describes a game for listings, from its latest save
+type GameSummary struct {
+	GameID       string    `json:"game_id"`
+	WorldName    string    `json:"world_name"`
+	Era          string    `json:"era"`
+	Day          int       `json:"day"`
+	Season       int       `json:"season"`
+	CurrentLife  int       `json:"current_life"`
+	IsAlive      bool      `json:"is_alive"`
+	Saved        bool      `json:"saved"` // false until the game's first save
+	CreatedAt    time.Time `json:"created_at"`
+	LastPlayedAt time.Time `json:"last_played_at"` // latest save, or creation
+}
+
+// GameListOptions selects a page of a game listing
+type GameListOptions struct {
+	Sort   string // one of the Sort constants; empty means SortLastPlayed
+	Limit  int
+	Offset int
+}
+
+// ValidGameSort reports whether sort names a game list ordering
+func ValidGameSort(sort string) bool {
+	_, ok := gameSortOrders[sort]
+	return ok
+}
+
+// ListUserGames returns a page of summaries of a user's games and the total
+// number of games
+func (db *DB) ListUserGames(userID string, opts GameListOptions) ([]GameSummary, int, error) {
+	if opts.Sort == "" {
+		opts.Sort = SortLastPlayed
+	}
+	order, ok := gameSortOrders[opts.Sort]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown sort: %s", opts.Sort)
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var total int
+	err := db.conn.QueryRow(`
+		SELECT COUNT(*) FROM game_ownership WHERE user_id = ? AND `+notArchived+`
+	`, userID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.conn.Query(`
+		SELECT o.game_id, g.name, g.era, s.day, s.season, s.current_life, s.is_alive,
+		       o.created_at, s.created_at
+		FROM game_ownership o
+		LEFT JOIN games g ON g.id = o.game_id
+		LEFT JOIN game_states s ON s.id = (
+			SELECT MAX(id) FROM game_states WHERE game_id = o.game_id
+		)
+		WHERE o.user_id = ? AND g.archived_at IS NULL
+		ORDER BY `+order+`
+		LIMIT ? OFFSET ?
+	`, userID, opts.Limit, opts.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	summaries := make([]GameSummary, 0)
+	for rows.Next() {
+		var (
+			sum                      GameSummary
+			name, era                sql.NullString
+			day, season, life, alive sql.NullInt64
+			savedAt                  sql.NullTime
+		)
+		if err := rows.Scan(&sum.GameID, &name, &era, &day, &season, &life, &alive,
+			&sum.CreatedAt, &savedAt); err != nil {
+			return nil, 0, err
+		}
+		sum.WorldName = name.String
+		sum.Era = era.String
+		sum.Day = int(day.Int64)
+		sum.Season = int(season.Int64)
+		sum.CurrentLife = int(life.Int64)
+		sum.IsAlive = alive.Int64 != 0
+		sum.Saved = savedAt.Valid
+		sum.LastPlayedAt = sum.CreatedAt
+		if savedAt.Valid {
+			sum.LastPlayedAt = savedAt.Time
+		}
+		summaries = append(summaries, sum)
+	}
+	return summaries, total, rows.Err()
+}
@@ -0,0 +1,193 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+)
+
+// TelemetryTrace is one game's gameplay for balancing analysis. IDs are
+// replaced by salted hashes and world names, obituaries and other
+// generated text are left out; only players who opted in to telemetry
+// have their cards' text in Choices.
+type TelemetryTrace struct {
+	Game       string            `json:"game"`   // salted hash of the game ID
+	Player     string            `json:"player"` // salted hash of the owner's user ID; empty when unowned
+	World      string            `json:"world"`  // world key, shared by games of one schema
+	OptedIn    bool              `json:"opted_in"`
+	Mechanic   string            `json:"resurrection_mechanic"`
+	Status     string            `json:"status"`
+	Lives      int               `json:"lives"`
+	Days       int               `json:"days"`
+	IsAlive    bool              `json:"is_alive"`
+	Trajectory []TelemetryPoint  `json:"trajectory"`
+	Deaths     []TelemetryDeath  `json:"deaths"`
+	Choices    []TelemetryChoice `json:"choices"`
+}
+
+// TelemetryPoint is the stats of one saved state
+type TelemetryPoint struct {
+	Day    int            `json:"day"`
+	Season int            `json:"season"`
+	Year   int            `json:"year_in_game"`
+	Life   int            `json:"life"`
+	Stats  map[string]int `json:"stats"`
+}
+
+// TelemetryDeath is one life's end
+type TelemetryDeath struct {
+	Life      int    `json:"life"`
+	CauseStat string `json:"cause_stat"`
+	Day       int    `json:"day"`
+	Season    int    `json:"season"`
+	Year      int    `json:"year_in_game"`
+}
+
+// TelemetryChoice is one choice a player made. The card and choice text
+// are only kept for players who opted in.
+type TelemetryChoice struct {
+	Life        int            `json:"life"`
+	Day         int            `json:"day"`
+	Season      int            `json:"season"`
+	Year        int            `json:"year_in_game"`
+	Direction   string         `json:"direction,omitempty"`
+	StatChanges map[string]int `json:"stat_changes,omitempty"`
+	CardID      string         `json:"card_id,omitempty"`
+	Title       string         `json:"title,omitempty"`
+	Choice      string         `json:"choice,omitempty"`
+}
+
+// ExportTelemetry writes a trace of every saved game as JSON lines and
+// returns how many it wrote. IDs are hashed with salt; an empty salt draws
+// a random one, so traces cannot be linked across exports.
+func ExportTelemetry(store Store, out io.Writer, salt string) (int, error) {
+	if salt == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return 0, err
+		}
+		salt = hex.EncodeToString(b)
+	}
+
+	gameIDs, err := store.GetGameList()
+	if err != nil {
+		return 0, err
+	}
+	enc := json.NewEncoder(out)
+	written := 0
+	for _, gameID := range gameIDs {
+		trace, err := telemetryTrace(store, gameID, salt)
+		if err != nil {
+			return written, err
+		}
+		if err := enc.Encode(trace); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, nil
+}
+
+// telemetryTrace builds the trace of one saved game
+func telemetryTrace(store Store, gameID, salt string) (*TelemetryTrace, error) {
+	state, _, err := store.LoadGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+	history, err := store.GetStatHistory(gameID)
+	if err != nil {
+		return nil, err
+	}
+
+	trace := &TelemetryTrace{
+		Game:       anonymize(salt, gameID),
+		World:      state.WorldKey,
+		Mechanic:   state.ResurrectionMechanic,
+		Status:     string(state.Status),
+		Lives:      state.LifeNumber,
+		Days:       state.GetElapsedDays(),
+		IsAlive:    state.IsAlive,
+		Trajectory: make([]TelemetryPoint, 0, len(history)),
+		Deaths:     make([]TelemetryDeath, 0),
+		Choices:    make([]TelemetryChoice, 0),
+	}
+	if trace.Status == "" {
+		trace.Status = string(game.StatusActive)
+	}
+	if owner, err := store.GetGameOwner(gameID); err == nil && owner != "" {
+		trace.Player = anonymize(salt, owner)
+		if prefs, err := store.GetUserPreferences(owner); err == nil {
+			trace.OptedIn = prefs.Telemetry
+		}
+	}
+
+	// A life's first saved state after it ended records its death
+	died := make(map[int]bool)
+	for _, p := range history {
+		trace.Trajectory = append(trace.Trajectory, TelemetryPoint{Day: p.Day, Season: p.Season, Year: p.Year, Life: p.CurrentLife, Stats: p.Stats})
+		if p.IsAlive || p.DeathCause == "" || died[p.CurrentLife] {
+			continue
+		}
+		died[p.CurrentLife] = true
+		trace.Deaths = append(trace.Deaths, TelemetryDeath{Life: p.CurrentLife, CauseStat: p.DeathCause, Day: p.Day, Season: p.Season, Year: p.Year})
+	}
+
+	// Each life's choices are in the life log of its last saved state
+	lastOfLife := make(map[int]int64)
+	snapshots, _, err := store.ListSnapshots(gameID, Page{})
+	if err != nil {
+		return nil, err
+	}
+	for _, snap := range snapshots {
+		if snap.CurrentLife < state.CurrentLife {
+			lastOfLife[snap.CurrentLife] = snap.ID
+		}
+	}
+	lives := make([]int, 0, len(lastOfLife))
+	for life := range lastOfLife {
+		lives = append(lives, life)
+	}
+	sort.Ints(lives)
+	for _, life := range lives {
+		past, _, err := store.LoadSnapshot(gameID, lastOfLife[life])
+		if err != nil {
+			return nil, err
+		}
+		trace.Choices = appendChoices(trace.Choices, past.LifeLog, life, trace.OptedIn)
+	}
+	trace.Choices = appendChoices(trace.Choices, state.LifeLog, state.CurrentLife, trace.OptedIn)
+	return trace, nil
+}
+
+// appendChoices adds a life's logged choices to a trace, with their text
+// only when the player opted in
+func appendChoices(choices []TelemetryChoice, log []game.LifeEntry, life int, optedIn bool) []TelemetryChoice {
+	for _, entry := range log {
+		choice := TelemetryChoice{
+			Life:        life,
+			Day:         entry.Day,
+			Season:      entry.Season,
+			Year:        entry.Year,
+			Direction:   entry.Direction,
+			StatChanges: entry.StatChanges,
+		}
+		if optedIn {
+			choice.CardID = entry.CardID
+			choice.Title = entry.Title
+			choice.Choice = entry.Choice
+		}
+		choices = append(choices, choice)
+	}
+	return choices
+}
+
+// anonymize replaces an ID with a salted hash
+func anonymize(salt, id string) string {
+	sum := sha256.Sum256([]byte(salt + ":" + id))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+)
+
+// RecordUsage stores the token usage and cost of one LLM call
+func (db *DB) RecordUsage(rec agents.UsageRecord) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, err := db.conn.Exec(`
+		INSERT INTO llm_usage (model, prompt_tokens, completion_tokens, cost_usd, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, rec.Model, rec.PromptTokens, rec.CompletionTokens, rec.CostUSD, rec.CreatedAt.UTC())
+	return err
+}
+
+// SpendSince sums the cost of all LLM calls made at or after since
+func (db *DB) SpendSince(since time.Time) (float64, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var total float64
+	err := db.conn.QueryRow(`
+		SELECT COALESCE(SUM(cost_usd), 0) FROM llm_usage WHERE created_at >= ?
+	`, since.UTC()).Scan(&total)
+	return total, err
+}
+
+// RecentUsage returns the most recent LLM calls, newest first
+func (db *DB) RecentUsage(limit int) ([]agents.UsageRecord, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	rows, err := db.conn.Query(`
+		SELECT model, prompt_tokens, completion_tokens, cost_usd, created_at
+		FROM llm_usage
+		ORDER BY id DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make([]agents.UsageRecord, 0)
+	for rows.Next() {
+		var rec agents.UsageRecord
+		if err := rows.Scan(&rec.Model, &rec.PromptTokens, &rec.CompletionTokens, &rec.CostUSD, &rec.CreatedAt); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// RecordValidationFailure stores an LLM response that failed to parse or
+// validate
+func (db *DB) RecordValidationFailure(f agents.ValidationFailure) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, err := db.conn.Exec(`
+		INSERT INTO llm_validation_failures (agent, class, model, prompt_variant, org_id, detail, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, f.Agent, f.Class, f.Model, f.PromptVariant, f.OrgID, f.Detail, f.CreatedAt.UTC())
+	return err
+}
+
+// ValidationFailureCounts groups the validation failures recorded at or
+// after since by agent, class, model and prompt variant, most frequent
+// first. An empty orgID counts failures service-wide.
+func (db *DB) ValidationFailureCounts(orgID string, since time.Time) ([]agents.ValidationFailureCount, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.validationFailureCounts(orgID, since)
+}
+
+// validationFailureCounts implements ValidationFailureCounts. Caller must
+// hold db.mu.
+func (db *DB) validationFailureCounts(orgID string, since time.Time) ([]agents.ValidationFailureCount, error) {
+	rows, err := db.conn.Query(`
+		SELECT agent, class, model, prompt_variant, COUNT(*), MAX(created_at)
+		FROM llm_validation_failures
+		WHERE created_at >= ? AND (? = '' OR org_id = ?)
+		GROUP BY agent, class, model, prompt_variant
+		ORDER BY COUNT(*) DESC, agent, class
+	`, since.UTC(), orgID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make([]agents.ValidationFailureCount, 0)
+	for rows.Next() {
+		var (
+			c        agents.ValidationFailureCount
+			lastSeen string
+		)
+		if err := rows.Scan(&c.Agent, &c.Class, &c.Model, &c.PromptVariant, &c.Count, &lastSeen); err != nil {
+			return nil, err
+		}
+		if c.LastSeen, err = parseSQLiteTime(lastSeen); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// parseSQLiteTime parses a timestamp the driver wrote. Aggregates such as
+// MAX(created_at) come back as text rather than time.Time.
+func parseSQLiteTime(value string) (time.Time, error) {
+	value = strings.TrimSuffix(value, "Z")
+	for _, format := range sqlite3.SQLiteTimestampFormats {
+		if t, err := time.ParseInLocation(format, value, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", value)
+}
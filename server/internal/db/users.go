@@ -0,0 +1,84 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrUsernameTaken is returned when signing up with a username already in
+// use (usernames are compared case-insensitively)
+var ErrUsernameTaken = errors.New("username already taken")
+
+// User is a registered account. Its password hash is only handed out by
+// GetUserByUsername, for login.
+type User struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateUser registers an account with an already hashed password
+func (db *DB) CreateUser(username, passwordHash string) (*User, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user := &User{
+		ID:        uuid.New().String(),
+		Username:  username,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	_, err := db.conn.Exec(`
+		INSERT INTO users (id, username, password_hash, created_at) VALUES (?, ?, ?, ?)
+	`, user.ID, user.Username, passwordHash, user.CreatedAt)
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return nil, ErrUsernameTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetUser returns an account by ID
+func (db *DB) GetUser(userID string) (*User, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var user User
+	err := db.conn.QueryRow(`
+		SELECT id, username, created_at FROM users WHERE id = ?
+	`, userID).Scan(&user.ID, &user.Username, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUserByUsername returns an account and its password hash
+func (db *DB) GetUserByUsername(username string) (*User, string, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var user User
+	var hash string
+	err := db.conn.QueryRow(`
+		SELECT id, username, password_hash, created_at FROM users WHERE username = ?
+	`, username).Scan(&user.ID, &user.Username, &hash, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, "", fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return &user, hash, nil
+}
@@ -7,12 +7,14 @@ type DeathInfo struct {
 	LifeNumber int              `json:"life_number"`
 	Tags      map[string]bool   `json:"tags"`
 	Stats     map[string]int    `json:"stats"`
+	Obituary  string            `json:"obituary,omitempty"` // Writer's summary of the life
 }
 
 // GameState is an interface for game state operations
 type GameState interface {
 	GetElapsedDays() int
 	GetStats() map[string]int
+	SetStat(id string, value int)
 	GetTags() map[string]bool
 	GetNPCIDs() []string
 	DisableNPC(id string)
@@ -24,49 +26,76 @@ type GameState interface {
 	SetYear(year int)
 	SetDay(day int)
 	SetTags(tags map[string]bool)
+	ClearItems()
+	ResetResources()
+	BankruptResource() string
 	SetCurrentLife(life int)
+	AdvanceToNextSeason()
+	GetResurrectionMechanic() string
+	GetDynasty() (years int, inheritance float64)
+	AgeYears(years int)
+	InheritRelationships()
+	BeginLife(tags map[string]bool)
+	RestoreLoop() map[string]bool
+	KnowledgeTags() map[string]bool
 }
 
 // DeathLoop handles death detection and resurrection
 type DeathLoop struct {
-	state GameState
+	state    GameState
+	mechanic Mechanic
 }
 
-// NewDeathLoop creates a new death loop
+// NewDeathLoop creates a new death loop using the world's resurrection mechanic
 func NewDeathLoop(state GameState) *DeathLoop {
-	return &DeathLoop{state: state}
+	return &DeathLoop{state: state, mechanic: MechanicFor(state.GetResurrectionMechanic())}
 }
 
-// CheckDeath detects when any stat hits 0 or 100
+// Mechanic returns the resurrection mechanic in use
+func (dl *DeathLoop) Mechanic() Mechanic {
+	return dl.mechanic
+}
+
+// CheckDeath detects when any stat hits 0 or 100, or the player goes
+// bankrupt in a resource with the bankruptcy rule; the resource is then the
+// cause
 func (dl *DeathLoop) CheckDeath() (*DeathInfo, bool) {
 	stats := dl.state.GetStats()
 	for statID, value := range stats {
 		if value <= 0 || value >= 100 {
-			deathInfo := &DeathInfo{
-				CauseStat:  statID,
-				Turn:       dl.state.GetElapsedDays(),
-				LifeNumber: 1, // Will be set by caller
-				Tags:       make(map[string]bool),
-				Stats:      make(map[string]int),
-			}
+			return dl.die(statID, stats), true
+		}
+	}
+	if resourceID := dl.state.BankruptResource(); resourceID != "" {
+		return dl.die(resourceID, stats), true
+	}
 
-			// Copy current state
-			for k, v := range dl.state.GetTags() {
-				deathInfo.Tags[k] = v
-			}
-			for k, v := range stats {
-				deathInfo.Stats[k] = v
-			}
+	return nil, false
+}
 
-			dl.state.SetIsAlive(false)
-			dl.state.SetDeathCause(statID)
-			dl.state.SetDeathTurn(dl.state.GetElapsedDays())
+// die ends the current life with cause and returns its death info
+func (dl *DeathLoop) die(cause string, stats map[string]int) *DeathInfo {
+	deathInfo := &DeathInfo{
+		CauseStat:  cause,
+		Turn:       dl.state.GetElapsedDays(),
+		LifeNumber: 1, // Will be set by caller
+		Tags:       make(map[string]bool),
+		Stats:      make(map[string]int),
+	}
 
-			return deathInfo, true
-		}
+	// Copy current state
+	for k, v := range dl.state.GetTags() {
+		deathInfo.Tags[k] = v
+	}
+	for k, v := range stats {
+		deathInfo.Stats[k] = v
 	}
 
-	return nil, false
+	dl.state.SetIsAlive(false)
+	dl.state.SetDeathCause(cause)
+	dl.state.SetDeathTurn(dl.state.GetElapsedDays())
+
+	return deathInfo
 }
 
 // Resurrect resets world for new life
@@ -81,39 +110,5 @@ func (dl *DeathLoop) Resurrect(tempTags map[string]bool) {
 		}
 	}
 
-	// Reset stats to 50
-	stats := dl.state.GetStats()
-	for statID := range stats {
-		stats[statID] = 50
-	}
-
-	// Clear NPC appearances
-	for _, npcID := range dl.state.GetNPCIDs() {
-		dl.state.DisableNPC(npcID)
-	}
-
-	// Clear events
-	dl.state.ClearEvents()
-
-	// Advance to next season
-	season := 0 // Will be set by caller
-	year := 0   // Will be set by caller
-	season++
-	if season > 3 {
-		season = 0
-		year++
-	}
-
-	dl.state.SetSeason(season)
-	dl.state.SetYear(year)
-	dl.state.SetDay(1)
-
-	// Reset tags to karma only
-	dl.state.SetTags(karmaTags)
-
-	// Update life counter
-	dl.state.SetCurrentLife(1) // Will be incremented by caller
-	dl.state.SetIsAlive(true)
-	dl.state.SetDeathCause("")
-	dl.state.SetDeathTurn(0)
+	dl.state.BeginLife(dl.mechanic.Reset(dl.state, karmaTags))
 }
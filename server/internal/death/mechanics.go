@@ -0,0 +1,138 @@
+package death
+
+import "math"
+
+// Resurrection mechanics the Architect can choose per world
+const (
+	MechanicReincarnation = "reincarnation"
+	MechanicTimeLoop      = "time_loop"
+	MechanicHeir          = "heir"
+	MechanicGhost         = "ghost"
+)
+
+// Mechanic resets the world for a new life in its own way
+type Mechanic interface {
+	// Name returns the mechanic's schema name
+	Name() string
+	// Reset applies the mechanic's state-reset rules and returns the tags
+	// the next life starts with, given the karma kept from this one
+	Reset(state GameState, karma map[string]bool) map[string]bool
+	// RebornPrompt tells the Writer how to narrate the return
+	RebornPrompt() string
+}
+
+var mechanics = map[string]Mechanic{
+	MechanicReincarnation: reincarnation{},
+	MechanicTimeLoop:      timeLoop{},
+	MechanicHeir:          heir{},
+	MechanicGhost:         ghost{},
+}
+
+// NormalizeMechanic maps a schema value to a known mechanic name, falling
+// back to reincarnation for empty or free-text values
+func NormalizeMechanic(name string) string {
+	return MechanicFor(name).Name()
+}
+
+// MechanicFor returns the mechanic registered under name, or reincarnation
+func MechanicFor(name string) Mechanic {
+	if m, ok := mechanics[name]; ok {
+		return m
+	}
+	return reincarnation{}
+}
+
+// resetStats puts every stat back at 50
+func resetStats(state GameState) {
+	for statID := range state.GetStats() {
+		state.SetStat(statID, 50)
+	}
+}
+
+// reincarnation is the classic rebirth: a fresh body next season, with the
+// cast forgotten, every item lost, resources back at their starting
+// balance and every event over
+type reincarnation struct{}
+
+func (reincarnation) Name() string { return MechanicReincarnation }
+
+func (reincarnation) Reset(state GameState, karma map[string]bool) map[string]bool {
+	resetStats(state)
+	for _, npcID := range state.GetNPCIDs() {
+		state.DisableNPC(npcID)
+	}
+	state.ClearItems()
+	state.ResetResources()
+	state.ClearEvents()
+	state.AdvanceToNextSeason()
+	return karma
+}
+
+func (reincarnation) RebornPrompt() string {
+	return "Mystical resurrection from the latest death. Describe waking up in a new life."
+}
+
+// timeLoop rewinds the world, items and resources included, to day 1 of the
+// same season.
+// Only knowledge tags survive, so the player can act on what past loops
+// taught them.
+type timeLoop struct{}
+
+func (timeLoop) Name() string { return MechanicTimeLoop }
+
+func (timeLoop) Reset(state GameState, karma map[string]bool) map[string]bool {
+	knowledge := state.KnowledgeTags()
+	tags := state.RestoreLoop()
+	for tagID := range knowledge {
+		tags[tagID] = true
+	}
+	state.ClearEvents()
+	state.SetDay(1)
+	return tags
+}
+
+func (timeLoop) RebornPrompt() string {
+	return "The same morning begins again. The player remembers the last loop; nobody else does."
+}
+
+// heir continues as the player's descendant years later, who keeps the
+// items as heirlooms and inherits the resources, debts written off
+type heir struct{}
+
+func (heir) Name() string { return MechanicHeir }
+
+func (heir) Reset(state GameState, karma map[string]bool) map[string]bool {
+	years, inheritance := state.GetDynasty()
+
+	// The heir inherits part of each stat's distance from 50
+	for statID, value := range state.GetStats() {
+		state.SetStat(statID, 50+int(math.Round(float64(value-50)*inheritance)))
+	}
+
+	state.AgeYears(years)
+	state.SetDay(1)
+	state.InheritRelationships()
+	state.ClearEvents()
+	return karma
+}
+
+func (heir) RebornPrompt() string {
+	return "Years have passed; introduce the player as the heir of the one who died. Mention inherited bonds and who is gone."
+}
+
+// ghost lingers in the same world: time, NPCs and events carry on, but a
+// ghost holds no items and starts its resources over
+type ghost struct{}
+
+func (ghost) Name() string { return MechanicGhost }
+
+func (ghost) Reset(state GameState, karma map[string]bool) map[string]bool {
+	resetStats(state)
+	state.ClearItems()
+	state.ResetResources()
+	return karma
+}
+
+func (ghost) RebornPrompt() string {
+	return "The player rises as a ghost in the very moment of death. The living world goes on around them."
+}
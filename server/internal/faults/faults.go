@@ -0,0 +1,272 @@
+// Package faults injects failures on demand so tests can drive the server's
+// error paths: LLM timeouts, malformed model output, database errors and
+// slow responses. The server never wires it in; tests wrap the router, the
+// store and agent clients with it.
+package faults
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/db"
+	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+	"github.com/qninhdt/world-card-ai-2/server/internal/story"
+)
+
+// Kind is a failure an Injector can produce
+type Kind string
+
+const (
+	LLMTimeout    Kind = "llm-timeout"    // the next LLM call times out
+	MalformedJSON Kind = "malformed-json" // the next LLM call answers with text that is not JSON
+	SlowLLM       Kind = "slow-llm"       // the next LLM call is delayed
+	DBError       Kind = "db-error"       // the next game save, load or ownership call fails
+	Slow          Kind = "slow"           // the next request is delayed before it is handled
+)
+
+// Header arms faults, written as for Parse, when a request carries it
+const Header = "X-Inject-Fault"
+
+// defaultDelay is how long slow faults wait when the spec gives no duration
+const defaultDelay = 100 * time.Millisecond
+
+// malformedResponse is a completion whose content is cut off mid-JSON
+const malformedResponse = `{"choices": [{"message": {"role": "assistant", "content": "{\"name\": \"unterminated"}, "finish_reason": "length"}]}`
+
+// ErrInjected wraps every injected error
+var ErrInjected = errors.New("injected fault")
+
+// Fault is one armed failure
+type Fault struct {
+	Kind  Kind
+	Delay time.Duration // for slow faults
+}
+
+// Parse reads a comma-separated spec such as "llm-timeout,slow=250ms,db-error"
+func Parse(spec string) ([]Fault, error) {
+	var faults []Fault
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, delay, hasDelay := strings.Cut(part, "=")
+		fault := Fault{Kind: Kind(name)}
+		switch fault.Kind {
+		case LLMTimeout, MalformedJSON, DBError:
+			if hasDelay {
+				return nil, fmt.Errorf("%s takes no duration", name)
+			}
+		case SlowLLM, Slow:
+			fault.Delay = defaultDelay
+			if hasDelay {
+				d, err := time.ParseDuration(delay)
+				if err != nil || d < 0 {
+					return nil, fmt.Errorf("invalid duration for %s: %q", name, delay)
+				}
+				fault.Delay = d
+			}
+		default:
+			return nil, fmt.Errorf("unknown fault: %s", name)
+		}
+		faults = append(faults, fault)
+	}
+	return faults, nil
+}
+
+// Injector holds armed faults until the call they target consumes them,
+// each once, in the order they were armed
+type Injector struct {
+	mu      sync.Mutex
+	pending []Fault
+	fired   map[Kind]int
+}
+
+// New creates an injector with nothing armed
+func New() *Injector {
+	return &Injector{fired: make(map[Kind]int)}
+}
+
+// NewFromSpec creates an injector armed from a config spec (see Parse)
+func NewFromSpec(spec string) (*Injector, error) {
+	faults, err := Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	inj := New()
+	inj.Arm(faults...)
+	return inj, nil
+}
+
+// Arm queues faults
+func (inj *Injector) Arm(faults ...Fault) {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+	inj.pending = append(inj.pending, faults...)
+}
+
+// Reset disarms every pending fault
+func (inj *Injector) Reset() {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+	inj.pending = nil
+}
+
+// Pending returns how many faults are still armed
+func (inj *Injector) Pending() int {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+	return len(inj.pending)
+}
+
+// Fired returns how many faults of a kind have been consumed
+func (inj *Injector) Fired(kind Kind) int {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+	return inj.fired[kind]
+}
+
+// take consumes the oldest armed fault of one of the kinds
+func (inj *Injector) take(kinds ...Kind) (Fault, bool) {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+	for i, fault := range inj.pending {
+		for _, kind := range kinds {
+			if fault.Kind == kind {
+				inj.pending = append(inj.pending[:i], inj.pending[i+1:]...)
+				inj.fired[kind]++
+				return fault, true
+			}
+		}
+	}
+	return Fault{}, false
+}
+
+// wait sleeps for d or until ctx is done
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Middleware arms the faults named in a request's Header, then delays the
+// request if a slow fault is armed. A bad header is rejected with 400.
+func Middleware(inj *Injector) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if spec := r.Header.Get(Header); spec != "" {
+				faults, err := Parse(spec)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				inj.Arm(faults...)
+			}
+			if fault, ok := inj.take(Slow); ok {
+				if err := wait(r.Context(), fault.Delay); err != nil {
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Client wraps an agent client so armed LLM faults fire on its calls
+func Client(inj *Injector, next agents.Completer) agents.Completer {
+	return agents.CompleterFunc(func(ctx context.Context, req *agents.CompletionRequest) (*agents.CompletionResponse, error) {
+		fault, ok := inj.take(LLMTimeout, MalformedJSON, SlowLLM)
+		if !ok {
+			return next.CreateCompletion(ctx, req)
+		}
+		switch fault.Kind {
+		case LLMTimeout:
+			return nil, fmt.Errorf("%w: %w", ErrInjected, context.DeadlineExceeded)
+		case MalformedJSON:
+			var resp agents.CompletionResponse
+			if err := json.Unmarshal([]byte(malformedResponse), &resp); err != nil {
+				return nil, err
+			}
+			return &resp, nil
+		default:
+			if err := wait(ctx, fault.Delay); err != nil {
+				return nil, err
+			}
+			return next.CreateCompletion(ctx, req)
+		}
+	})
+}
+
+// Store wraps a store so armed database faults fail its game save, load
+// and ownership calls; every other call passes through
+func Store(inj *Injector, next db.Store) db.Store {
+	return &store{Store: next, inj: inj}
+}
+
+// store is a db.Store with database faults
+type store struct {
+	db.Store
+	inj *Injector
+}
+
+// fail returns an injected error if a database fault is armed
+func (s *store) fail(op string) error {
+	if _, ok := s.inj.take(DBError); ok {
+		return fmt.Errorf("%s: %w", op, ErrInjected)
+	}
+	return nil
+}
+
+func (s *store) SaveGame(gameID string, state *game.GlobalBlackboard, dag *story.MacroDAG) error {
+	if err := s.fail("save game"); err != nil {
+		return err
+	}
+	return s.Store.SaveGame(gameID, state, dag)
+}
+
+func (s *store) SaveGameContext(ctx context.Context, gameID string, state *game.GlobalBlackboard, dag *story.MacroDAG) error {
+	if err := s.fail("save game"); err != nil {
+		return err
+	}
+	return s.Store.SaveGameContext(ctx, gameID, state, dag)
+}
+
+func (s *store) AutosaveGame(ctx context.Context, gameID string, state *game.GlobalBlackboard, dag *story.MacroDAG) error {
+	if err := s.fail("autosave game"); err != nil {
+		return err
+	}
+	return s.Store.AutosaveGame(ctx, gameID, state, dag)
+}
+
+func (s *store) LoadGame(gameID string) (*game.GlobalBlackboard, *story.MacroDAG, error) {
+	if err := s.fail("load game"); err != nil {
+		return nil, nil, err
+	}
+	return s.Store.LoadGame(gameID)
+}
+
+func (s *store) SaveGameOwnership(gameID, userID string) error {
+	if err := s.fail("save ownership"); err != nil {
+		return err
+	}
+	return s.Store.SaveGameOwnership(gameID, userID)
+}
+
+func (s *store) GetGameOwner(gameID string) (string, error) {
+	if err := s.fail("get owner"); err != nil {
+		return "", err
+	}
+	return s.Store.GetGameOwner(gameID)
+}
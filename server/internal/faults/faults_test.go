@@ -0,0 +1,71 @@
+package faults
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+)
+
+// TestParse tests reading fault specs
+func TestParse(t *testing.T) {
+	got, err := Parse(" llm-timeout, slow=250ms,,slow-llm ")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []Fault{{Kind: LLMTimeout}, {Kind: Slow, Delay: 250 * time.Millisecond}, {Kind: SlowLLM, Delay: defaultDelay}}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected fault %d to be %v, got %v", i, want[i], got[i])
+		}
+	}
+
+	for _, spec := range []string{"meteor", "db-error=1s", "slow=soon", "slow=-1s"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
+
+// TestClient tests that armed LLM faults fire once each, in order, and
+// leave other kinds for their own targets
+func TestClient(t *testing.T) {
+	inj, err := NewFromSpec("db-error,malformed-json,llm-timeout")
+	if err != nil {
+		t.Fatalf("NewFromSpec failed: %v", err)
+	}
+	calls := 0
+	client := Client(inj, agents.CompleterFunc(func(ctx context.Context, req *agents.CompletionRequest) (*agents.CompletionResponse, error) {
+		calls++
+		return &agents.CompletionResponse{}, nil
+	}))
+	req := &agents.CompletionRequest{}
+
+	resp, err := client.CreateCompletion(context.Background(), req)
+	if err != nil || len(resp.Choices) != 1 || resp.Choices[0].Message.Content == "" {
+		t.Fatalf("Expected a malformed completion first, got %+v, %v", resp, err)
+	}
+	if _, err := client.CreateCompletion(context.Background(), req); !errors.Is(err, ErrInjected) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected an injected timeout second, got %v", err)
+	}
+	if _, err := client.CreateCompletion(context.Background(), req); err != nil || calls != 1 {
+		t.Fatalf("Expected the third call to reach the model, got %v after %d calls", err, calls)
+	}
+	if inj.Pending() != 1 || inj.Fired(MalformedJSON) != 1 || inj.Fired(LLMTimeout) != 1 {
+		t.Errorf("Expected only the database fault left, got %d pending", inj.Pending())
+	}
+
+	// A slow call gives up with its caller
+	inj.Reset()
+	inj.Arm(Fault{Kind: SlowLLM, Delay: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.CreateCompletion(ctx, req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the slow call to end with its context, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package game
+
+import (
+	"sort"
+	"time"
+)
+
+// Affinity runs from -100, hatred, to 100, devotion
+const (
+	minAffinity = -100
+	maxAffinity = 100
+
+	// departureAffinity is the affinity at or below which an NPC leaves the
+	// player for good
+	departureAffinity = -80
+)
+
+// NPCAffinity returns how an NPC feels about the player, and whether the
+// NPC exists
+func (s *GlobalBlackboard) NPCAffinity(id string) (int, bool) {
+	npc, ok := s.NPCs[id]
+	if !ok {
+		return 0, false
+	}
+	return npc.Affinity, true
+}
+
+// UpdateAffinity moves an NPC's affinity, clamped to -100..100. An NPC
+// whose affinity falls to departureAffinity leaves: it is disabled and
+// cannot be enabled again.
+func (s *GlobalBlackboard) UpdateAffinity(id string, delta int) {
+	npc, ok := s.NPCs[id]
+	if !ok {
+		return
+	}
+	npc.Affinity = max(minAffinity, min(maxAffinity, npc.Affinity+delta))
+	if npc.Affinity <= departureAffinity && !npc.Deceased && !npc.Departed {
+		npc.Departed = true
+		npc.Enabled = false
+	}
+	s.NPCs[id] = npc
+	s.UpdatedAt = time.Now()
+}
+
+// affinities returns every NPC's affinity by ID, for conditions such as
+// "affinity.chancellor < -20"
+func (s *GlobalBlackboard) affinities() map[string]int {
+	affinities := make(map[string]int, len(s.NPCs))
+	for id, npc := range s.NPCs {
+		affinities[id] = npc.Affinity
+	}
+	return affinities
+}
+
+// departedNPCs returns the IDs of NPCs who have left the player
+func (s *GlobalBlackboard) departedNPCs() map[string]bool {
+	departed := make(map[string]bool)
+	for id, npc := range s.NPCs {
+		if npc.Departed {
+			departed[id] = true
+		}
+	}
+	return departed
+}
+
+// watchDepartures snapshots the departed NPCs and returns a function that
+// queues farewells for those who leave before it runs, for code that runs
+// calls such as update_affinity. Caller must hold e.mu.
+func (e *GameEngine) watchDepartures() (queue func()) {
+	before := e.state.departedNPCs()
+	return func() { e.queueDepartures(before) }
+}
+
+// queueDepartures asks the Writer for a farewell card for each NPC that
+// left since before was taken. Caller must hold e.mu.
+func (e *GameEngine) queueDepartures(before map[string]bool) {
+	ids := make([]string, 0)
+	for id := range e.state.departedNPCs() {
+		if !before[id] {
+			ids = append(ids, id)
+		}
+	}
+	// Queue in a stable order so the Writer sees the same batch every run
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		npc := e.state.NPCs[id]
+		e.jobQueue.Enqueue(&CardGenJob{
+			JobType: "departure",
+			Context: map[string]interface{}{
+				"npc_id":   npc.ID,
+				"name":     npc.Name,
+				"affinity": npc.Affinity,
+			},
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+)
+
+// MortalityRule gives enabled NPCs aged MinAge or more a Chance each
+// in-game year of dying of natural causes
+type MortalityRule struct {
+	MinAge int     `json:"min_age"`
+	Chance float64 `json:"chance"`
+}
+
+// newMortalityRules converts and validates schema mortality rules, sorted
+// by ascending MinAge
+func newMortalityRules(defs []agents.MortalityRuleDef) ([]MortalityRule, error) {
+	rules := make([]MortalityRule, 0, len(defs))
+	for _, def := range defs {
+		if def.MinAge <= 0 {
+			return nil, fmt.Errorf("mortality rule: min_age must be positive, got %d", def.MinAge)
+		}
+		if def.Chance < 0 || def.Chance > 1 {
+			return nil, fmt.Errorf("mortality rule for age %d: chance out of range: %v", def.MinAge, def.Chance)
+		}
+		rules = append(rules, MortalityRule{MinAge: def.MinAge, Chance: def.Chance})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].MinAge < rules[j].MinAge })
+	return rules, nil
+}
+
+// deathChance returns the yearly chance of dying at an age
+func (s *GlobalBlackboard) deathChance(age int) float64 {
+	chance := 0.0
+	for _, rule := range s.MortalityRules {
+		if age >= rule.MinAge {
+			chance = rule.Chance
+		}
+	}
+	return chance
+}
+
+// ageNPCs adds a year to every aging NPC and rolls natural deaths. Each death
+// queues a memorial card and a successor job. Caller must hold e.mu.
+func (e *GameEngine) ageNPCs() {
+	ids := make([]string, 0, len(e.state.NPCs))
+	for id := range e.state.NPCs {
+		ids = append(ids, id)
+	}
+	// Roll in a stable order so a seed always gives the same deaths
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		npc := e.state.NPCs[id]
+		if npc.Age == 0 || npc.Deceased {
+			continue
+		}
+		npc.Age++
+
+		chance := e.state.deathChance(npc.Age)
+		if npc.Enabled && chance > 0 && e.state.Roll() < chance {
+			npc.Deceased = true
+			npc.Enabled = false
+			e.noteDeathFact(npc)
+			e.queueSuccession(npc)
+		}
+		e.state.NPCs[id] = npc
+	}
+}
+
+// queueSuccession asks the Writer for a memorial card and a successor NPC
+func (e *GameEngine) queueSuccession(npc NPC) {
+	e.jobQueue.Enqueue(&CardGenJob{
+		JobType: "memorial",
+		Context: map[string]interface{}{
+			"npc_id": npc.ID,
+			"name":   npc.Name,
+			"age":    npc.Age,
+		},
+	})
+	e.jobQueue.Enqueue(&CardGenJob{
+		JobType: "successor",
+		Context: map[string]interface{}{
+			"npc_id":     npc.ID,
+			"name":       npc.Name,
+			"appearance": npc.Appearance,
+		},
+	})
+}
+
+// addSuccessorNPC registers an NPC introduced by a Writer card's new_npc
+// field. Caller must hold e.mu.
+func (e *GameEngine) addSuccessorNPC(def map[string]interface{}) bool {
+	id, _ := def["id"].(string)
+	name, _ := def["name"].(string)
+	if id == "" || name == "" {
+		return false
+	}
+	if _, exists := e.state.NPCs[id]; exists {
+		return false
+	}
+
+	description, _ := def["description"].(string)
+	appearance, _ := def["appearance"].(string)
+	age := 0
+	if a, ok := def["age"].(float64); ok && a > 0 {
+		age = int(a)
+	}
+	e.state.NPCs[id] = NPC{
+		ID:          id,
+		Name:        name,
+		Description: description,
+		Appearance:  appearance,
+		Enabled:     true,
+		Age:         age,
+	}
+	return true
+}
@@ -0,0 +1,193 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
+)
+
+// MaxBatchSteps bounds how many queued resolutions one batch may carry
+const MaxBatchSteps = 50
+
+// BatchStep is one resolution queued by a client while offline
+type BatchStep struct {
+	CardID    string `json:"card_id"`
+	Direction string `json:"direction"`
+	// ExpectedVersion is the blackboard version the client saw before this
+	// step; 0 skips the check
+	ExpectedVersion int64 `json:"expected_version,omitempty"`
+}
+
+// BatchStepResult reports how one step of a batch went
+type BatchStepResult struct {
+	Index   int                  `json:"index"`
+	CardID  string               `json:"card_id"`
+	Applied bool                 `json:"applied"`
+	Version int64                `json:"version"` // blackboard version after the step
+	Result  *cards.ExecuteResult `json:"result,omitempty"`
+	Error   string               `json:"error,omitempty"`
+	// Conflict is set when the step was queued against another version
+	Conflict bool `json:"conflict,omitempty"`
+}
+
+// BatchResult is the outcome of a batch. Steps are applied all or nothing:
+// on failure nothing is kept and FailedStep points at the first bad step.
+type BatchResult struct {
+	Applied    bool              `json:"applied"`
+	FailedStep int               `json:"failed_step"` // -1 when every step applied
+	Version    int64             `json:"version"`
+	Steps      []BatchStepResult `json:"steps"`
+}
+
+// VersionConflictError is returned when a step was queued against a
+// different blackboard version than the current one
+type VersionConflictError struct {
+	Expected int64
+	Actual   int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("version conflict: expected %d, at %d", e.Expected, e.Actual)
+}
+
+// checkpoint is what a rolled-back batch or an undo puts back
+type checkpoint struct {
+	state      []byte
+	dag        []byte
+	deck       []cards.Card
+	drawnCards []cards.Card
+	immediate  []cards.Card
+	jobs       []*CardGenJob
+	awaiting   bool
+	version    int64
+}
+
+// checkpoint saves everything resolving cards or advancing weeks can change.
+// Caller must hold e.mu.
+func (e *GameEngine) checkpoint() (*checkpoint, error) {
+	state, err := json.Marshal(e.state)
+	if err != nil {
+		return nil, err
+	}
+	dag, err := json.Marshal(e.dag)
+	if err != nil {
+		return nil, err
+	}
+	cp := &checkpoint{
+		state:      state,
+		dag:        dag,
+		deck:       e.deck.GetAll(),
+		drawnCards: append([]cards.Card(nil), e.drawnCards...),
+		jobs:       e.jobQueue.Peek(),
+		awaiting:   e.awaitingResurrection,
+		version:    e.state.Version,
+	}
+	for elem := e.immediateDeque.Front(); elem != nil; elem = elem.Next() {
+		cp.immediate = append(cp.immediate, elem.Value.(cards.Card))
+	}
+	return cp, nil
+}
+
+// restore rolls the engine back to a checkpoint, dropping the versions
+// recorded since. Caller must hold e.mu.
+func (e *GameEngine) restore(cp *checkpoint) error {
+	if err := e.apply(cp); err != nil {
+		return err
+	}
+	e.versions.truncate(cp.version)
+	return nil
+}
+
+// apply puts the engine back as it was at a checkpoint, version included.
+// Caller must hold e.mu.
+func (e *GameEngine) apply(cp *checkpoint) error {
+	state := &GlobalBlackboard{}
+	if err := json.Unmarshal(cp.state, state); err != nil {
+		return err
+	}
+	// The death loop holds the blackboard pointer, so restore in place
+	if err := json.Unmarshal(cp.dag, e.dag); err != nil {
+		return err
+	}
+	*e.state = *state
+	e.deck.Restore(cp.deck)
+	e.drawnCards = append(make([]cards.Card, 0, len(cp.drawnCards)), cp.drawnCards...)
+	e.immediateDeque.Init()
+	for _, card := range cp.immediate {
+		e.immediateDeque.PushBack(card)
+	}
+	e.jobQueue.Drain()
+	for _, job := range cp.jobs {
+		e.jobQueue.Enqueue(job)
+	}
+	e.awaitingResurrection = cp.awaiting
+	return nil
+}
+
+// ResolveBatch resolves queued choices in order under a single lock. Each
+// step records its own version; if any step fails the blackboard, drawn
+// cards and version history are restored to how they were before the batch.
+func (e *GameEngine) ResolveBatch(steps []BatchStep) (*BatchResult, error) {
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("batch is empty")
+	}
+	if len(steps) > MaxBatchSteps {
+		return nil, fmt.Errorf("batch has %d steps, at most %d allowed", len(steps), MaxBatchSteps)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.checkActive(); err != nil {
+		return nil, err
+	}
+	saved, err := e.checkpoint()
+	if err != nil {
+		return nil, err
+	}
+	baseVersion := e.state.Version
+
+	result := &BatchResult{FailedStep: -1, Steps: make([]BatchStepResult, 0, len(steps))}
+	for i, step := range steps {
+		report := BatchStepResult{Index: i, CardID: step.CardID}
+
+		var res *cards.ExecuteResult
+		if step.ExpectedVersion != 0 && step.ExpectedVersion != e.state.Version {
+			err = &VersionConflictError{Expected: step.ExpectedVersion, Actual: e.state.Version}
+		} else {
+			res, err = e.resolveCard(step.CardID, step.Direction)
+		}
+		if err != nil {
+			report.Error = err.Error()
+			_, report.Conflict = err.(*VersionConflictError)
+			report.Version = e.state.Version
+			result.Steps = append(result.Steps, report)
+			result.FailedStep = i
+			break
+		}
+
+		e.recordVersion()
+		report.Applied = true
+		report.Result = res
+		report.Version = e.state.Version
+		result.Steps = append(result.Steps, report)
+	}
+
+	if result.FailedStep >= 0 {
+		// Roll back every step; the reports say what would have happened
+		if err := e.restore(saved); err != nil {
+			return nil, err
+		}
+		for i := range result.Steps {
+			result.Steps[i].Applied = false
+		}
+		result.Version = baseVersion
+		return result, nil
+	}
+
+	e.history.push(saved)
+	result.Applied = true
+	result.Version = e.state.Version
+	return result, nil
+}
@@ -0,0 +1,94 @@
+package game
+
+import (
+	"fmt"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+)
+
+// The default in-game calendar, for worlds that do not shape their own.
+// Week decks, pressure rules and chronicles follow the week; seasons and
+// years follow from it.
+const (
+	DaysPerWeek    = 7
+	WeeksPerSeason = 4
+	SeasonsPerYear = 4
+	DaysPerSeason  = DaysPerWeek * WeeksPerSeason
+	DaysPerYear    = DaysPerSeason * SeasonsPerYear
+)
+
+// Bounds of a world's calendar
+const (
+	maxDaysPerWeek    = 14
+	maxDaysPerSeason  = 120
+	maxSeasonsPerYear = 12
+)
+
+// Calendar is a world's calendar. Seasons are a whole number of weeks, so
+// every season starts a week.
+type Calendar struct {
+	DaysPerWeek    int `json:"days_per_week"`
+	DaysPerSeason  int `json:"days_per_season"`
+	SeasonsPerYear int `json:"seasons_per_year"`
+}
+
+// defaultCalendar is the calendar of worlds without one
+var defaultCalendar = Calendar{DaysPerWeek: DaysPerWeek, DaysPerSeason: DaysPerSeason, SeasonsPerYear: SeasonsPerYear}
+
+// newCalendar fills in defaults and validates the schema's calendar for a
+// world with seasons season definitions
+func newCalendar(def *agents.CalendarDef, seasons int) (*Calendar, error) {
+	calendar := defaultCalendar
+	if seasons > 0 {
+		calendar.SeasonsPerYear = seasons
+	}
+	if def == nil {
+		return &calendar, nil
+	}
+	if def.DaysPerWeek != 0 {
+		calendar.DaysPerWeek = def.DaysPerWeek
+		calendar.DaysPerSeason = def.DaysPerWeek * WeeksPerSeason
+	}
+	if def.DaysPerSeason != 0 {
+		calendar.DaysPerSeason = def.DaysPerSeason
+	}
+	if def.SeasonsPerYear != 0 {
+		if seasons > 0 && def.SeasonsPerYear != seasons {
+			return nil, fmt.Errorf("calendar: seasons_per_year is %d but %d seasons are defined", def.SeasonsPerYear, seasons)
+		}
+		calendar.SeasonsPerYear = def.SeasonsPerYear
+	}
+
+	if calendar.DaysPerWeek < 1 || calendar.DaysPerWeek > maxDaysPerWeek {
+		return nil, fmt.Errorf("calendar: days_per_week must be between 1 and %d: %d", maxDaysPerWeek, calendar.DaysPerWeek)
+	}
+	if calendar.DaysPerSeason < 1 || calendar.DaysPerSeason > maxDaysPerSeason {
+		return nil, fmt.Errorf("calendar: days_per_season must be between 1 and %d: %d", maxDaysPerSeason, calendar.DaysPerSeason)
+	}
+	if calendar.DaysPerSeason%calendar.DaysPerWeek != 0 {
+		return nil, fmt.Errorf("calendar: days_per_season must be a whole number of %d-day weeks: %d", calendar.DaysPerWeek, calendar.DaysPerSeason)
+	}
+	if calendar.SeasonsPerYear < 1 || calendar.SeasonsPerYear > maxSeasonsPerYear {
+		return nil, fmt.Errorf("calendar: seasons_per_year must be between 1 and %d: %d", maxSeasonsPerYear, calendar.SeasonsPerYear)
+	}
+	return &calendar, nil
+}
+
+// WeeksPerSeason returns how many weeks make a season
+func (c *Calendar) WeeksPerSeason() int {
+	return c.DaysPerSeason / c.DaysPerWeek
+}
+
+// DaysPerYear returns how many days make a year
+func (c *Calendar) DaysPerYear() int {
+	return c.DaysPerSeason * c.SeasonsPerYear
+}
+
+// calendar returns the world's calendar, or the default for games saved
+// before it existed
+func (s *GlobalBlackboard) calendar() *Calendar {
+	if s.Calendar == nil {
+		return &defaultCalendar
+	}
+	return s.Calendar
+}
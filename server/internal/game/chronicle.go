@@ -0,0 +1,149 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
+)
+
+// chronicleSource marks Writer cards that polish a week's chronicle
+const chronicleSource = "chronicle"
+
+// maxChronicleChoices caps the choices a chronicle names; the rest are
+// counted
+const maxChronicleChoices = 3
+
+// PolishChronicles makes week chronicles ask the Writer to rewrite their
+// template text
+func (e *GameEngine) PolishChronicles() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	defer e.recordVersion()
+
+	e.state.PolishChronicle = true
+}
+
+// beginWeek starts the chronicle of a new week from the current stats.
+// Caller must hold e.mu.
+func (e *GameEngine) beginWeek() {
+	e.state.WeekLog = nil
+	e.state.WeekStartStats = make(map[string]int, len(e.state.Stats))
+	for id, value := range e.state.Stats {
+		e.state.WeekStartStats[id] = value
+	}
+}
+
+// chronicleWeek sums up the week that just ended in an info card on top of
+// the next week's deck and starts the next one. Caller must hold e.mu,
+// before the days left in the week advance.
+func (e *GameEngine) chronicleWeek() {
+	week := (e.state.GetElapsedDays()-e.state.DaysPlayed)/e.state.calendar().DaysPerWeek + 1
+	card := &cards.InfoCard{
+		ID:          fmt.Sprintf("chronicle_week_%d", week),
+		Title:       fmt.Sprintf("Chronicle of Week %d", week),
+		Description: e.chronicleText(),
+		Source:      chronicleSource,
+	}
+	e.immediateDeque.PushFront(card)
+
+	if e.state.PolishChronicle {
+		choices := make([]map[string]interface{}, 0, len(e.state.WeekLog))
+		for _, entry := range e.state.WeekLog {
+			choices = append(choices, map[string]interface{}{"card": entry.Title, "choice": entry.Choice})
+		}
+		e.jobQueue.Enqueue(&CardGenJob{
+			JobType: "chronicle",
+			Context: map[string]interface{}{
+				"card_id": card.ID,
+				"week":    week,
+				"draft":   card.Description,
+				"choices": choices,
+				"trend":   e.weekTrend(),
+			},
+		})
+	}
+	e.beginWeek()
+}
+
+// chronicleText writes the template chronicle of the week so far. Caller
+// must hold e.mu.
+func (e *GameEngine) chronicleText() string {
+	var text strings.Builder
+	log := e.state.WeekLog
+	if len(log) == 0 {
+		text.WriteString("A quiet week passed without a decision.")
+	} else {
+		named := make([]string, 0, maxChronicleChoices)
+		for _, entry := range log[max(len(log)-maxChronicleChoices, 0):] {
+			named = append(named, fmt.Sprintf("%q at %s", entry.Choice, entry.Title))
+		}
+		fmt.Fprintf(&text, "This week you chose %s", strings.Join(named, ", "))
+		if rest := len(log) - len(named); rest > 0 {
+			fmt.Fprintf(&text, ", among %d other decisions", rest)
+		}
+		text.WriteString(".")
+	}
+
+	trend := e.weekTrend()
+	if trend == nil {
+		return text.String()
+	}
+	if len(trend) == 0 {
+		text.WriteString(" Nothing changed for better or worse.")
+		return text.String()
+	}
+	for _, stat := range e.buildStatList() {
+		id := stat["id"].(string)
+		delta, ok := trend[id]
+		if !ok {
+			continue
+		}
+		if delta > 0 {
+			fmt.Fprintf(&text, " %s rose by %d.", stat["name"], delta)
+		} else {
+			fmt.Fprintf(&text, " %s fell by %d.", stat["name"], -delta)
+		}
+	}
+	return text.String()
+}
+
+// weekTrend returns how each visible stat changed since the week began,
+// leaving out the unchanged ones, or nil when a new life began this week.
+// Caller must hold e.mu.
+func (e *GameEngine) weekTrend() map[string]int {
+	if e.state.WeekStartStats == nil {
+		return nil
+	}
+	trend := make(map[string]int)
+	for id, value := range e.state.Stats {
+		start, ok := e.state.WeekStartStats[id]
+		if !ok || e.state.HiddenStats[id] {
+			continue
+		}
+		if delta := value - start; delta != 0 {
+			trend[id] = delta
+		}
+	}
+	return trend
+}
+
+// addChronicle replaces a chronicle's template text with the Writer's
+// while it waits to be drawn, reporting whether it was accepted
+func (e *GameEngine) addChronicle(cardDef map[string]interface{}) bool {
+	id, _ := cardDef["id"].(string)
+	text, _ := cardDef["description"].(string)
+	if text == "" {
+		return false
+	}
+	for elem := e.immediateDeque.Front(); elem != nil; elem = elem.Next() {
+		if card, ok := elem.Value.(*cards.InfoCard); ok && card.Source == chronicleSource && card.ID == id {
+			card.Description = text
+			if title, _ := cardDef["title"].(string); title != "" {
+				card.Title = title
+			}
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package game
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
+)
+
+// Codex entry kinds
+const (
+	CodexNPC = "npc"
+	CodexTag = "tag"
+	CodexArc = "arc"
+)
+
+// loreSource marks Writer cards that carry a codex entry's lore
+const loreSource = "lore"
+
+// CodexEntry is something the player has come across. Lore stays empty
+// until the Writer answers the entry's lore job.
+type CodexEntry struct {
+	Kind        string `json:"kind"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Lore        string `json:"lore,omitempty"`
+	Day         int    `json:"day"`
+	Season      int    `json:"season"`
+	Year        int    `json:"year_in_game"`
+}
+
+// GetCodex returns the unlocked codex entries, oldest first
+func (e *GameEngine) GetCodex() []CodexEntry {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]CodexEntry{}, e.state.Codex...)
+}
+
+// codexUnlocked reports whether an entry is in the codex. Caller must hold
+// e.mu.
+func (e *GameEngine) codexUnlocked(kind, id string) bool {
+	for _, entry := range e.state.Codex {
+		if entry.Kind == kind && entry.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// unlockCodex adds an entry on its first appearance and asks the Writer
+// for its lore. Caller must hold e.mu.
+func (e *GameEngine) unlockCodex(kind, id, name, description string) {
+	if id == "" || e.codexUnlocked(kind, id) {
+		return
+	}
+	if name == "" {
+		name = id
+	}
+	e.state.Codex = append(e.state.Codex, CodexEntry{
+		Kind:        kind,
+		ID:          id,
+		Name:        name,
+		Description: description,
+		Day:         e.state.Day,
+		Season:      e.state.Season,
+		Year:        e.state.Year,
+	})
+	e.jobQueue.Enqueue(&CardGenJob{
+		JobType: "lore",
+		Context: map[string]interface{}{
+			"card_id":     loreCardID(kind, id),
+			"kind":        kind,
+			"name":        name,
+			"description": description,
+		},
+	})
+}
+
+// loreCardID is the ID the Writer gives an entry's lore card
+func loreCardID(kind, id string) string {
+	return "lore_" + kind + "_" + id
+}
+
+// noteAppearances counts the NPCs on drawn cards and unlocks those the
+// player meets for the first time. Caller must hold e.mu.
+func (e *GameEngine) noteAppearances(drawn []cards.Card) {
+	for _, card := range drawn {
+		npc, ok := e.state.NPCs[card.GetCharacter()]
+		if !ok {
+			continue
+		}
+		npc.AppearanceCount++
+		e.state.NPCs[npc.ID] = npc
+		e.unlockCodex(CodexNPC, npc.ID, npc.Name, npc.Description)
+	}
+}
+
+// refreshCodex unlocks active tags and arcs with a fired plot node that
+// are not in the codex yet. Caller must hold e.mu.
+func (e *GameEngine) refreshCodex() {
+	defs := make(map[string]map[string]interface{}, len(e.state.TagDefs))
+	for _, def := range e.state.TagDefs {
+		if id, ok := def["id"].(string); ok {
+			defs[id] = def
+		}
+	}
+	active := make([]string, 0, len(e.state.Tags))
+	for id, on := range e.state.Tags {
+		if on {
+			active = append(active, id)
+		}
+	}
+	sort.Strings(active)
+	for _, id := range active {
+		entry := definitionEntry(defs[id])
+		e.unlockCodex(CodexTag, id, entry.Name, entry.Description)
+	}
+
+	started := make(map[string]bool)
+	for _, node := range e.dag.GetAllNodes() {
+		if node.IsFired && node.ArcID != "" {
+			started[node.ArcID] = true
+		}
+	}
+	for _, arc := range e.dag.GetArcs() {
+		if started[arc.ID] {
+			e.unlockCodex(CodexArc, arc.ID, arc.Title, arc.Theme)
+		}
+	}
+}
+
+// addLore stores the Writer's lore on its codex entry, reporting whether it
+// was accepted
+func (e *GameEngine) addLore(cardDef map[string]interface{}) bool {
+	id, _ := cardDef["id"].(string)
+	lore, _ := cardDef["description"].(string)
+	if !strings.HasPrefix(id, "lore_") || lore == "" {
+		return false
+	}
+	for i := range e.state.Codex {
+		entry := &e.state.Codex[i]
+		if loreCardID(entry.Kind, entry.ID) == id && entry.Lore == "" {
+			entry.Lore = lore
+			return true
+		}
+	}
+	return false
+}
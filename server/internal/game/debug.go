@@ -0,0 +1,45 @@
+package game
+
+import (
+	"encoding/json"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
+	"github.com/qninhdt/world-card-ai-2/server/internal/story"
+)
+
+// DebugDump is everything the engine holds for one game, hidden state
+// included, for attaching to bug reports
+type DebugDump struct {
+	State                *GlobalBlackboard `json:"state"`
+	DAG                  *story.MacroDAG   `json:"dag"`
+	DrawnCards           []cards.Card      `json:"drawn_cards"`
+	ImmediateDeque       []cards.Card      `json:"immediate_deque"`
+	Deck                 []cards.Card      `json:"deck"`
+	Jobs                 []*CardGenJob     `json:"jobs"`
+	AwaitingResurrection bool              `json:"awaiting_resurrection"`
+	FirstWeekStarted     bool              `json:"first_week_started"`
+	RetainedVersions     []int64           `json:"retained_versions"` // versions Diff can still serve
+}
+
+// Dump serializes the engine's internals. It encodes under the engine lock
+// so the dump is a consistent moment of the game.
+func (e *GameEngine) Dump() ([]byte, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	dump := &DebugDump{
+		State:                e.state,
+		DAG:                  e.dag,
+		DrawnCards:           append([]cards.Card{}, e.drawnCards...),
+		ImmediateDeque:       make([]cards.Card, 0, e.immediateDeque.Len()),
+		Deck:                 e.deck.GetAll(),
+		Jobs:                 e.jobQueue.Peek(),
+		AwaitingResurrection: e.awaitingResurrection,
+		FirstWeekStarted:     e.firstWeekStarted,
+		RetainedVersions:     append([]int64{}, e.versions.order...),
+	}
+	for elem := e.immediateDeque.Front(); elem != nil; elem = elem.Next() {
+		dump.ImmediateDeque = append(dump.ImmediateDeque, elem.Value.(cards.Card))
+	}
+	return json.Marshal(dump)
+}
@@ -0,0 +1,127 @@
+package game
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
+)
+
+// DeckConfig is how a world's week decks are made up
+type DeckConfig struct {
+	Size        int     `json:"size"`                 // cards per week
+	CommonShare float64 `json:"common_share"`         // least share of the deck kept for common cards
+	InfoEvery   int     `json:"info_every,omitempty"` // one common card in this many is an info card; 0 leaves it to the Writer
+}
+
+// newDeckConfig fills in defaults and validates the schema's deck. A week
+// deck holds a card per day of the week by default and at most a card per
+// day of the season.
+func newDeckConfig(def *agents.DeckDef, calendar *Calendar) (*DeckConfig, error) {
+	deck := &DeckConfig{Size: calendar.DaysPerWeek}
+	if def == nil {
+		return deck, nil
+	}
+	if def.Size != 0 {
+		deck.Size = def.Size
+	}
+	deck.CommonShare = def.CommonShare
+	deck.InfoEvery = def.InfoEvery
+	if deck.Size < 1 || deck.Size > calendar.DaysPerSeason {
+		return nil, fmt.Errorf("deck: size must be between 1 and %d: %d", calendar.DaysPerSeason, def.Size)
+	}
+	if deck.CommonShare < 0 || deck.CommonShare > 1 {
+		return nil, fmt.Errorf("deck: common_share must be between 0 and 1: %v", def.CommonShare)
+	}
+	if deck.InfoEvery < 0 {
+		return nil, fmt.Errorf("deck: info_every must not be negative: %d", def.InfoEvery)
+	}
+	return deck, nil
+}
+
+// deckConfig returns the world's deck, or the default for games saved
+// before it existed
+func (s *GlobalBlackboard) deckConfig() *DeckConfig {
+	if s.Deck == nil {
+		deck, _ := newDeckConfig(nil, s.calendar())
+		return deck
+	}
+	return s.Deck
+}
+
+// GetWeekDeckSize returns how many cards to generate for a week deck
+func (e *GameEngine) GetWeekDeckSize() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.state.deckConfig().Size
+}
+
+// GetCommonCount returns how many common cards to generate
+func (e *GameEngine) GetCommonCount() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.commonCount()
+}
+
+// GetInfoCount returns how many of the common cards should be info cards
+func (e *GameEngine) GetInfoCount() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.infoCount(e.commonCount())
+}
+
+// commonCount fills the week deck left after queued jobs with common cards,
+// keeping at least the world's common share and always one. Caller must
+// hold e.mu.
+func (e *GameEngine) commonCount() int {
+	deck := e.state.deckConfig()
+	reserved := int(math.Ceil(float64(deck.Size) * deck.CommonShare))
+	return max(deck.Size-e.jobQueue.Count(), reserved, 1)
+}
+
+// infoCount returns how many of common cards should be info cards. Info
+// cards are breathers, so harder games space them out by the difficulty
+// level. Caller must hold e.mu.
+func (e *GameEngine) infoCount(common int) int {
+	deck := e.state.deckConfig()
+	if deck.InfoEvery == 0 {
+		return 0
+	}
+	level := e.state.currentDifficulty().Level
+	every := max(int(math.Round(float64(deck.InfoEvery)*math.Max(level, 1))), 1)
+	return common / every
+}
+
+// parseRevealWindow reads a card's reveal window. Windows outside the week
+// or closing before they open are dropped, leaving the card dealt any day.
+func parseRevealWindow(raw interface{}, daysPerWeek int) *cards.RevealWindow {
+	def, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	earliest, _ := def["earliest"].(float64)
+	latest, _ := def["latest"].(float64)
+	window := &cards.RevealWindow{Earliest: int(earliest), Latest: int(latest)}
+	if window.Earliest < 0 || window.Earliest > daysPerWeek || window.Latest < 0 || window.Latest > daysPerWeek {
+		return nil
+	}
+	if window.Latest > 0 && window.Latest < window.Earliest {
+		return nil
+	}
+	if window.Earliest <= 1 && window.Latest == 0 {
+		return nil
+	}
+	return window
+}
+
+// buildDeckContext tells the Writer how to fill the next week deck.
+// Caller must hold e.mu.
+func (e *GameEngine) buildDeckContext() map[string]interface{} {
+	common := e.commonCount()
+	return map[string]interface{}{
+		"size":         e.state.deckConfig().Size,
+		"common_count": common,
+		"info_count":   e.infoCount(common),
+	}
+}
@@ -0,0 +1,218 @@
+package game
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// maxDiffSnapshots bounds how many past versions the engine can diff against
+const maxDiffSnapshots = 64
+
+// Patch operation names (a subset of RFC 6902)
+const (
+	PatchOpAdd     = "add"
+	PatchOpRemove  = "remove"
+	PatchOpReplace = "replace"
+)
+
+// PatchOp is a single JSON-patch-like change to the blackboard
+type PatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// StateDiff is the delta between two blackboard versions
+type StateDiff struct {
+	FromVersion int64     `json:"from_version"`
+	ToVersion   int64     `json:"to_version"`
+	Full        bool      `json:"full"` // true when the client must replace its whole copy
+	Ops         []PatchOp `json:"ops"`
+}
+
+// versionLog keeps flattened blackboard snapshots keyed by version
+type versionLog struct {
+	snapshots map[int64]map[string]interface{}
+	order     []int64
+}
+
+// newVersionLog creates an empty version log
+func newVersionLog() *versionLog {
+	return &versionLog{
+		snapshots: make(map[int64]map[string]interface{}),
+		order:     make([]int64, 0, maxDiffSnapshots),
+	}
+}
+
+// latest returns the most recent snapshot, or nil
+func (l *versionLog) latest() map[string]interface{} {
+	if len(l.order) == 0 {
+		return nil
+	}
+	return l.snapshots[l.order[len(l.order)-1]]
+}
+
+// put stores a snapshot, evicting the oldest when full
+func (l *versionLog) put(version int64, snapshot map[string]interface{}) {
+	if _, exists := l.snapshots[version]; !exists {
+		l.order = append(l.order, version)
+	}
+	l.snapshots[version] = snapshot
+	for len(l.order) > maxDiffSnapshots {
+		delete(l.snapshots, l.order[0])
+		l.order = l.order[1:]
+	}
+}
+
+// truncate drops every snapshot newer than version
+func (l *versionLog) truncate(version int64) {
+	kept := l.order[:0]
+	for _, v := range l.order {
+		if v > version {
+			delete(l.snapshots, v)
+			continue
+		}
+		kept = append(kept, v)
+	}
+	l.order = kept
+}
+
+// flattenState converts the blackboard into a generic JSON document,
+// dropping bookkeeping fields that change on every write
+func flattenState(state *GlobalBlackboard) map[string]interface{} {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return map[string]interface{}{}
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return map[string]interface{}{}
+	}
+	delete(doc, "version")
+	delete(doc, "updated_at")
+	return doc
+}
+
+// recordVersion bumps the state version if the blackboard changed since the
+// last recorded snapshot. Caller must hold e.mu.
+func (e *GameEngine) recordVersion() {
+	snapshot := flattenState(e.state)
+	if prev := e.versions.latest(); prev != nil && reflect.DeepEqual(prev, snapshot) {
+		return
+	}
+	if e.versions.latest() != nil {
+		e.state.Version++
+	}
+	e.versions.put(e.state.Version, snapshot)
+}
+
+// GetVersion returns the current blackboard version
+func (e *GameEngine) GetVersion() int64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.state.Version
+}
+
+// Diff returns the blackboard changes since the given version. If that version
+// is no longer retained (or is from the future), a full replacement is returned.
+func (e *GameEngine) Diff(sinceVersion int64) *StateDiff {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.recordVersion()
+	current := e.versions.latest()
+
+	diff := &StateDiff{
+		FromVersion: sinceVersion,
+		ToVersion:   e.state.Version,
+		Ops:         make([]PatchOp, 0),
+	}
+
+	// Clients only see stats that were visible at each version, so a
+	// revealed stat shows up as an add
+	current = playerView(current)
+
+	base, ok := e.versions.snapshots[sinceVersion]
+	if !ok || sinceVersion > e.state.Version {
+		diff.Full = true
+		diff.Ops = append(diff.Ops, PatchOp{Op: PatchOpReplace, Path: "", Value: current})
+		return diff
+	}
+
+	diff.Ops = diffDocuments("", playerView(base), current, diff.Ops)
+	return diff
+}
+
+// hideStats returns a copy of a flattened blackboard without the stats it
+// marks as hidden
+func playerView(doc map[string]interface{}) map[string]interface{} {
+	view := make(map[string]interface{}, len(doc))
+	for k, v := range doc {
+		view[k] = v
+	}
+	delete(view, "hidden_stats")
+	delete(view, "rng_seed")
+
+	hidden, _ := doc["hidden_stats"].(map[string]interface{})
+	if stats, ok := doc["stats"].(map[string]interface{}); ok && len(hidden) > 0 {
+		visible := make(map[string]interface{}, len(stats))
+		for id, v := range stats {
+			if _, isHidden := hidden[id]; !isHidden {
+				visible[id] = v
+			}
+		}
+		view["stats"] = visible
+	}
+	return view
+}
+
+// diffDocuments appends the ops that turn a into b. Nested objects are
+// descended into; arrays and scalars are replaced wholesale.
+func diffDocuments(prefix string, a, b map[string]interface{}, ops []PatchOp) []PatchOp {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		path := prefix + "/" + escapePointer(k)
+		av, inA := a[k]
+		bv, inB := b[k]
+
+		switch {
+		case !inB:
+			ops = append(ops, PatchOp{Op: PatchOpRemove, Path: path})
+		case !inA:
+			ops = append(ops, PatchOp{Op: PatchOpAdd, Path: path, Value: bv})
+		default:
+			am, aIsMap := av.(map[string]interface{})
+			bm, bIsMap := bv.(map[string]interface{})
+			if aIsMap && bIsMap {
+				ops = diffDocuments(path, am, bm, ops)
+			} else if !reflect.DeepEqual(av, bv) {
+				ops = append(ops, PatchOp{Op: PatchOpReplace, Path: path, Value: bv})
+			}
+		}
+	}
+	return ops
+}
+
+// escapePointer escapes a key for use in a JSON pointer
+func escapePointer(key string) string {
+	key = strings.ReplaceAll(key, "~", "~0")
+	return strings.ReplaceAll(key, "/", "~1")
+}
+
+// DiffStates returns the ops that turn one blackboard into another, e.g. to
+// compare two saved snapshots
+func DiffStates(from, to *GlobalBlackboard) []PatchOp {
+	return diffDocuments("", flattenState(from), flattenState(to), make([]PatchOp, 0))
+}
@@ -0,0 +1,95 @@
+package game
+
+import (
+	"testing"
+)
+
+// TestDiffNoChanges tests that an unchanged engine produces no ops
+func TestDiffNoChanges(t *testing.T) {
+	engine, _ := NewGameEngine("test-game", createTestSchema())
+
+	diff := engine.Diff(engine.GetVersion())
+
+	if diff.Full {
+		t.Error("Expected incremental diff")
+	}
+	if len(diff.Ops) != 0 {
+		t.Errorf("Expected no ops, got %d", len(diff.Ops))
+	}
+}
+
+// TestDiffStatAndTagChanges tests ops for stat and tag mutations
+func TestDiffStatAndTagChanges(t *testing.T) {
+	engine, _ := NewGameEngine("test-game", createTestSchema())
+	base := engine.GetVersion()
+
+	engine.mu.Lock()
+	engine.state.UpdateStat("mana", -10)
+	engine.state.AddTag("tag2")
+	engine.state.RemoveTag("tag1")
+	engine.recordVersion()
+	engine.mu.Unlock()
+
+	if engine.GetVersion() != base+1 {
+		t.Fatalf("Expected version %d, got %d", base+1, engine.GetVersion())
+	}
+
+	diff := engine.Diff(base)
+	ops := make(map[string]PatchOp)
+	for _, op := range diff.Ops {
+		ops[op.Path] = op
+	}
+
+	if op, ok := ops["/stats/mana"]; !ok || op.Op != PatchOpReplace || op.Value != float64(40) {
+		t.Errorf("Expected replace of /stats/mana to 40, got %+v", op)
+	}
+	if op, ok := ops["/tags/tag2"]; !ok || op.Op != PatchOpAdd {
+		t.Errorf("Expected add of /tags/tag2, got %+v", op)
+	}
+	if op, ok := ops["/tags/tag1"]; !ok || op.Op != PatchOpRemove {
+		t.Errorf("Expected remove of /tags/tag1, got %+v", op)
+	}
+}
+
+// TestDiffUnknownVersion tests that an unknown version yields a full resync
+func TestDiffUnknownVersion(t *testing.T) {
+	engine, _ := NewGameEngine("test-game", createTestSchema())
+
+	diff := engine.Diff(engine.GetVersion() + 5)
+
+	if !diff.Full {
+		t.Error("Expected full diff for unknown version")
+	}
+	if len(diff.Ops) != 1 || diff.Ops[0].Path != "" {
+		t.Errorf("Expected single root replace, got %+v", diff.Ops)
+	}
+}
+
+// TestRecordVersionSkipsNoop tests that version does not bump without changes
+func TestRecordVersionSkipsNoop(t *testing.T) {
+	engine, _ := NewGameEngine("test-game", createTestSchema())
+	base := engine.GetVersion()
+
+	engine.mu.Lock()
+	engine.recordVersion()
+	engine.mu.Unlock()
+
+	if engine.GetVersion() != base {
+		t.Errorf("Expected version %d, got %d", base, engine.GetVersion())
+	}
+}
+
+// TestDiffStates tests diffing two standalone blackboards
+func TestDiffStates(t *testing.T) {
+	from := NewGlobalBlackboard(createTestSchema())
+	to := NewGlobalBlackboard(createTestSchema())
+	to.UpdateStat("health", -20)
+	to.Version = from.Version + 3
+	to.CreatedAt = from.CreatedAt
+	to.RNGSeed = from.RNGSeed
+
+	ops := DiffStates(from, to)
+	if len(ops) != 1 || ops[0].Path != "/stats/health" || ops[0].Op != PatchOpReplace {
+		t.Errorf("Expected single health replace, got %+v", ops)
+	}
+}
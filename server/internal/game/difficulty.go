@@ -0,0 +1,162 @@
+package game
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+)
+
+// Difficulty defaults and limits
+const (
+	defaultMinDifficulty = 0.5
+	defaultMaxDifficulty = 1.5
+	minDifficultyBound   = 0.25
+	maxDifficultyBound   = 2.0
+
+	// difficultyStep is how far one week's rebalance moves the level
+	difficultyStep = 0.1
+
+	// difficultyWindow is how many days of deaths the balancer remembers
+	difficultyWindow = 56
+
+	// baseTypicalDelta and baseMaxDelta are the stat changes the Writer is
+	// asked to stay around and within at level 1
+	baseTypicalDelta = 10
+	baseMaxDelta     = 25
+
+	// driftPerLevel is the weekly stat drift one full level away from 1
+	// adds: positive levels push stats toward danger, negative ones pull
+	// them back toward 50
+	driftPerLevel = 6
+)
+
+// Difficulty is a game's self-adjusting challenge level. At 1 the game
+// plays as designed; the balancer moves it within Min and Max.
+type Difficulty struct {
+	Level     float64 `json:"level"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+	DeathDays []int   `json:"death_days,omitempty"` // elapsed days of recent deaths
+}
+
+// newDifficulty fills in defaults and validates the schema's difficulty
+// bounds
+func newDifficulty(def *agents.DifficultyDef) (*Difficulty, error) {
+	difficulty := &Difficulty{Level: 1, Min: defaultMinDifficulty, Max: defaultMaxDifficulty}
+	if def == nil {
+		return difficulty, nil
+	}
+	if def.Min != 0 {
+		difficulty.Min = def.Min
+	}
+	if def.Max != 0 {
+		difficulty.Max = def.Max
+	}
+	if difficulty.Min < minDifficultyBound || difficulty.Min > 1 {
+		return nil, fmt.Errorf("difficulty: min out of range: %v", def.Min)
+	}
+	if difficulty.Max < 1 || difficulty.Max > maxDifficultyBound {
+		return nil, fmt.Errorf("difficulty: max out of range: %v", def.Max)
+	}
+	return difficulty, nil
+}
+
+// difficulty returns the game's difficulty for changing, creating the
+// default for games saved before it existed
+func (s *GlobalBlackboard) difficulty() *Difficulty {
+	if s.Difficulty == nil {
+		s.Difficulty, _ = newDifficulty(nil)
+	}
+	return s.Difficulty
+}
+
+// currentDifficulty returns the game's difficulty, or the default for games
+// saved before it existed, without changing the blackboard
+func (s *GlobalBlackboard) currentDifficulty() *Difficulty {
+	if s.Difficulty == nil {
+		d, _ := newDifficulty(nil)
+		return d
+	}
+	return s.Difficulty
+}
+
+// TypicalDelta is the stat change the Writer should aim for
+func (d *Difficulty) TypicalDelta() int {
+	return int(math.Round(baseTypicalDelta * d.Level))
+}
+
+// MaxDelta is the largest stat change the Writer should write
+func (d *Difficulty) MaxDelta() int {
+	return int(math.Round(baseMaxDelta * d.Level))
+}
+
+// Drift is how many points each stat moves every week: away from 50 when
+// positive, toward it when negative
+func (d *Difficulty) Drift() int {
+	return int((d.Level - 1) * driftPerLevel)
+}
+
+// logDeath remembers a death for the balancer
+func (s *GlobalBlackboard) logDeath() {
+	d := s.difficulty()
+	d.DeathDays = append(d.DeathDays, s.GetElapsedDays())
+}
+
+// balanceDifficulty applies the week's stat drift, then moves the level a
+// step down after repeated deaths or wild swings, or a step up after a
+// quiet stretch without dying. Caller must hold e.mu.
+func (e *GameEngine) balanceDifficulty() {
+	d := e.state.difficulty()
+	e.applyDrift(d.Drift())
+
+	now := e.state.GetElapsedDays()
+	recent := d.DeathDays[:0]
+	for _, day := range d.DeathDays {
+		if now-day <= difficultyWindow {
+			recent = append(recent, day)
+		}
+	}
+	d.DeathDays = recent
+
+	volatility := e.state.pacingSignals().Volatility
+	switch {
+	case len(recent) >= 2 || volatility >= highVolatility:
+		d.Level -= difficultyStep
+	case len(recent) == 0 && now >= difficultyWindow && volatility < highVolatility/2:
+		d.Level += difficultyStep
+	}
+	d.Level = math.Round(math.Max(d.Min, math.Min(d.Max, d.Level))*100) / 100
+}
+
+// applyDrift moves every stat by drift points. Pressure stops at the
+// danger margin, so drift alone never kills; relief stops at 50. Caller
+// must hold e.mu.
+func (e *GameEngine) applyDrift(drift int) {
+	if drift == 0 || !e.state.IsAlive {
+		return
+	}
+	for id, value := range e.state.Stats {
+		switch {
+		case drift > 0 && value > 50 && value < 100-statDangerMargin:
+			e.state.Stats[id] = min(value+drift, 100-statDangerMargin)
+		case drift > 0 && value < 50 && value > statDangerMargin:
+			e.state.Stats[id] = max(value-drift, statDangerMargin)
+		case drift < 0 && value > 50:
+			e.state.Stats[id] = max(value+drift, 50)
+		case drift < 0 && value < 50:
+			e.state.Stats[id] = min(value-drift, 50)
+		}
+	}
+}
+
+// buildDifficulty describes the difficulty for the Writer
+func (e *GameEngine) buildDifficulty() map[string]interface{} {
+	d := e.state.currentDifficulty()
+	return map[string]interface{}{
+		"level":         d.Level,
+		"typical_delta": d.TypicalDelta(),
+		"max_delta":     d.MaxDelta(),
+		"weekly_drift":  d.Drift(),
+	}
+}
@@ -0,0 +1,146 @@
+package game
+
+import (
+	"fmt"
+	"math"
+)
+
+// Pacing directives the Director gives the Writer
+const (
+	PacingSteady      = "steady"       // nothing to correct
+	PacingCalmWeek    = "calm_week"    // let the player catch their breath
+	PacingEscalate    = "escalate"     // raise the stakes or move the plot
+	PacingComicRelief = "comic_relief" // lighten the mood
+)
+
+const (
+	// maxPacingLog bounds how many choices the Director looks back over
+	maxPacingLog = 12
+
+	// highVolatility is the mean stat swing per choice above which the
+	// story needs a quieter stretch
+	highVolatility = 15.0
+
+	// lowVolatility is the mean swing below which a run of choices counts
+	// as uneventful
+	lowVolatility = 3.0
+
+	// plotDrought is how many days may pass without a plot beat before the
+	// Director asks for escalation
+	plotDrought = 28
+
+	// autopilotStreak is how many choices in a row on the same side suggest
+	// the player has stopped reading the cards
+	autopilotStreak = 6
+)
+
+// PacingBeat is one resolved choice as the Director sees it
+type PacingBeat struct {
+	Direction string `json:"direction"`  // "left" or "right"
+	StatSwing int    `json:"stat_swing"` // sum of absolute stat changes
+	Day       int    `json:"day"`        // elapsed days when chosen
+}
+
+// PacingSignals are the measurements behind a directive
+type PacingSignals struct {
+	Choices       int     `json:"choices"`         // recent choices considered
+	Volatility    float64 `json:"volatility"`      // mean stat swing per choice
+	DaysSincePlot int     `json:"days_since_plot"` // days since a plot node fired
+	SideStreak    int     `json:"side_streak"`     // latest choices made on the same side
+}
+
+// Direction is the Director's pacing advice for the next Writer batch
+type Direction struct {
+	Directive string        `json:"directive"`
+	Reason    string        `json:"reason"`
+	Signals   PacingSignals `json:"signals"`
+}
+
+// LogBeat records a resolved choice for the Director
+func (s *GlobalBlackboard) LogBeat(direction string, statChanges map[string]int) {
+	swing := 0
+	for _, delta := range statChanges {
+		if delta < 0 {
+			delta = -delta
+		}
+		swing += delta
+	}
+	s.Pacing = append(s.Pacing, PacingBeat{
+		Direction: direction,
+		StatSwing: swing,
+		Day:       s.GetElapsedDays(),
+	})
+	if len(s.Pacing) > maxPacingLog {
+		s.Pacing = append([]PacingBeat(nil), s.Pacing[len(s.Pacing)-maxPacingLog:]...)
+	}
+}
+
+// markPlotBeat notes that a plot node just fired. Caller must hold e.mu.
+func (e *GameEngine) markPlotBeat() {
+	e.state.LastPlotBeat = e.state.GetElapsedDays()
+}
+
+// pacingSignals measures recent play from the blackboard
+func (s *GlobalBlackboard) pacingSignals() PacingSignals {
+	signals := PacingSignals{
+		Choices:       len(s.Pacing),
+		DaysSincePlot: max(s.GetElapsedDays()-s.LastPlotBeat, 0), // a time loop can rewind the clock
+	}
+	if len(s.Pacing) == 0 {
+		return signals
+	}
+
+	total := 0
+	for _, beat := range s.Pacing {
+		total += beat.StatSwing
+	}
+	signals.Volatility = math.Round(float64(total)/float64(len(s.Pacing))*10) / 10
+
+	last := s.Pacing[len(s.Pacing)-1].Direction
+	for i := len(s.Pacing) - 1; i >= 0 && s.Pacing[i].Direction == last; i-- {
+		signals.SideStreak++
+	}
+	return signals
+}
+
+// direct picks a pacing directive with simple rules, checked in order:
+// wild stat swings call for calm, a fresh death for comic relief, a long
+// wait for the plot or an uneventful run for escalation, and a player on
+// autopilot for something lighter and unexpected. Caller must hold e.mu.
+func (e *GameEngine) direct() Direction {
+	signals := e.state.pacingSignals()
+	direction := Direction{Directive: PacingSteady, Reason: "pacing is on track", Signals: signals}
+
+	switch {
+	case signals.Volatility >= highVolatility:
+		direction.Directive = PacingCalmWeek
+		direction.Reason = fmt.Sprintf("stats swung %.1f points per choice recently", signals.Volatility)
+	case e.state.IsFirstDayAfterDeath:
+		direction.Directive = PacingComicRelief
+		direction.Reason = "the player has just died"
+	case signals.DaysSincePlot >= plotDrought:
+		direction.Directive = PacingEscalate
+		direction.Reason = fmt.Sprintf("no plot beat for %d days", signals.DaysSincePlot)
+	case signals.Choices >= maxPacingLog/2 && signals.Volatility <= lowVolatility:
+		direction.Directive = PacingEscalate
+		direction.Reason = fmt.Sprintf("recent choices barely moved the stats (%.1f per choice)", signals.Volatility)
+	case signals.SideStreak >= autopilotStreak:
+		direction.Directive = PacingComicRelief
+		direction.Reason = fmt.Sprintf("the player chose %s %d times in a row", e.state.Pacing[len(e.state.Pacing)-1].Direction, signals.SideStreak)
+	}
+	return direction
+}
+
+// context renders a direction for the Writer context
+func (d Direction) context() map[string]interface{} {
+	return map[string]interface{}{
+		"directive": d.Directive,
+		"reason":    d.Reason,
+		"signals": map[string]interface{}{
+			"choices":         d.Signals.Choices,
+			"volatility":      d.Signals.Volatility,
+			"days_since_plot": d.Signals.DaysSincePlot,
+			"side_streak":     d.Signals.SideStreak,
+		},
+	}
+}
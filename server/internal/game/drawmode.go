@@ -0,0 +1,85 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
+)
+
+// DrawMode is how a game deals its week deck
+type DrawMode string
+
+// Draw modes
+const (
+	DrawModeWeek  DrawMode = "week"  // the whole week's hand at once; advancing the week passes its days
+	DrawModeDaily DrawMode = "daily" // one card a day; resolving it passes the day
+)
+
+// ErrWeekOver is returned when a daily game draws after the last day of the
+// week; the week must be advanced first
+var ErrWeekOver = errors.New("week is over")
+
+// ParseDrawMode validates a requested draw mode; empty means the week mode
+func ParseDrawMode(mode string) (DrawMode, error) {
+	switch DrawMode(mode) {
+	case "", DrawModeWeek:
+		return DrawModeWeek, nil
+	case DrawModeDaily:
+		return DrawModeDaily, nil
+	}
+	return "", fmt.Errorf("unknown draw mode %q", mode)
+}
+
+// drawMode returns the game's draw mode, the week mode for games saved
+// before it existed
+func (s *GlobalBlackboard) drawMode() DrawMode {
+	if s.DrawMode == "" {
+		return DrawModeWeek
+	}
+	return s.DrawMode
+}
+
+// SetDrawMode chooses how the game deals its cards. It is meant for new
+// games, before the first draw.
+func (e *GameEngine) SetDrawMode(mode DrawMode) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	defer e.recordVersion()
+
+	e.state.DrawMode = mode
+}
+
+// GetDrawMode returns how the game deals its cards
+func (e *GameEngine) GetDrawMode() DrawMode {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.state.drawMode()
+}
+
+// drawDay draws the day's card, or returns it again while it is
+// unresolved. Onboarding cards come first and do not take a day. Caller
+// must hold e.mu.
+func (e *GameEngine) drawDay() (drawn []cards.Card, repeat bool, err error) {
+	if len(e.drawnCards) > 0 {
+		return e.drawnCards, true, nil
+	}
+	if e.state.DaysPlayed >= e.state.calendar().DaysPerWeek {
+		return nil, false, ErrWeekOver
+	}
+	e.drawnCards = e.drawTutorial(1)
+	if len(e.drawnCards) == 0 {
+		e.drawnCards = e.deck.DrawDays(e.state.DaysPlayed+1, 1)
+	}
+	return e.drawnCards, false, nil
+}
+
+// playDay passes the day of a resolved card in daily games. Caller must
+// hold e.mu.
+func (e *GameEngine) playDay() {
+	if e.state.drawMode() != DrawModeDaily {
+		return
+	}
+	e.advanceDay()
+	e.state.DaysPlayed++
+}
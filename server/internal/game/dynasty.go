@@ -0,0 +1,70 @@
+package game
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+)
+
+// Dynasty defaults
+const (
+	defaultHeirYears       = 20
+	defaultHeirInheritance = 0.5
+	maxHeirYears           = 100
+)
+
+// Dynasty tunes heir resurrection: the heir arrives YearsLater years after
+// the death and keeps Inheritance of each stat's distance from 50
+type Dynasty struct {
+	YearsLater  int     `json:"years_later"`
+	Inheritance float64 `json:"inheritance"`
+}
+
+// newDynasty fills in defaults and validates the schema's dynasty settings
+func newDynasty(def *agents.DynastyDef) (*Dynasty, error) {
+	dynasty := &Dynasty{YearsLater: defaultHeirYears, Inheritance: defaultHeirInheritance}
+	if def == nil {
+		return dynasty, nil
+	}
+	if def.YearsLater != 0 {
+		dynasty.YearsLater = def.YearsLater
+	}
+	if def.Inheritance != 0 {
+		dynasty.Inheritance = def.Inheritance
+	}
+	if dynasty.YearsLater < 1 || dynasty.YearsLater > maxHeirYears {
+		return nil, fmt.Errorf("dynasty: years_later out of range: %d", def.YearsLater)
+	}
+	if dynasty.Inheritance < 0 || dynasty.Inheritance > 1 {
+		return nil, fmt.Errorf("dynasty: inheritance out of range: %v", def.Inheritance)
+	}
+	return dynasty, nil
+}
+
+// GetDynasty returns the heir's years skipped and stat inheritance
+func (s *GlobalBlackboard) GetDynasty() (int, float64) {
+	if s.Dynasty == nil {
+		return defaultHeirYears, defaultHeirInheritance
+	}
+	return s.Dynasty.YearsLater, s.Dynasty.Inheritance
+}
+
+// InheritRelationships drops bonds with dead NPCs and marks the player's
+// remaining bonds as inherited by the heir
+func (s *GlobalBlackboard) InheritRelationships() {
+	relationships := make([]map[string]interface{}, 0, len(s.Relationships))
+	for _, rel := range s.Relationships {
+		from, _ := rel["from"].(string)
+		to, _ := rel["to"].(string)
+		if s.NPCs[from].Deceased || s.NPCs[to].Deceased {
+			continue
+		}
+		if from == s.PlayerChar.ID || to == s.PlayerChar.ID {
+			rel["inherited"] = true
+		}
+		relationships = append(relationships, rel)
+	}
+	s.Relationships = relationships
+	s.UpdatedAt = time.Now()
+}
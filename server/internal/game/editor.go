@@ -0,0 +1,168 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+)
+
+// EnableEditor makes every Writer batch pass the Editor before its cards
+// are added
+func (e *GameEngine) EnableEditor() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	defer e.recordVersion()
+
+	e.state.StoryEditor = true
+}
+
+// editBatch checks a Writer batch against the game's canonical facts and
+// returns the cards to add. A card spoken by an absent NPC is handed to
+// the narrator and calls that revive the dead are dropped; a card that
+// narrates a plot beat that has not happened, assumes a fact that does not
+// hold or removes a tag the player does not have is rejected. Caller must
+// hold e.mu.
+func (e *GameEngine) editBatch(cardDefs []map[string]interface{}) []map[string]interface{} {
+	// Tags and facts the batch adds itself may be relied on by its other
+	// cards
+	tags := make(map[string]bool, len(e.state.Tags))
+	for id, active := range e.state.Tags {
+		tags[id] = active
+	}
+	facts := make(map[string]bool, len(e.state.Facts))
+	for id := range e.state.Facts {
+		facts[id] = true
+	}
+	for _, cardDef := range cardDefs {
+		for _, choice := range cardChoices(cardDef) {
+			for _, call := range choiceCalls(choice) {
+				switch name, _ := call["name"].(string); name {
+				case "add_tag":
+					if tagID, ok := callParam(call, "tag_id"); ok {
+						tags[tagID] = true
+					}
+				case "add_fact":
+					if factID, ok := callParam(call, "fact_id"); ok {
+						facts[factID] = true
+					}
+				}
+			}
+		}
+	}
+
+	kept := make([]map[string]interface{}, 0, len(cardDefs))
+	for _, cardDef := range cardDefs {
+		if err := e.editCard(cardDef, tags, facts); err != nil {
+			agents.RecordValidationFailure(agents.ValidationFailure{
+				Agent:  agents.AgentEditor,
+				Class:  agents.FailureContradictionRejected,
+				Detail: fmt.Sprintf("card %v: %v", cardDef["id"], err),
+			})
+			continue
+		}
+		kept = append(kept, cardDef)
+	}
+	return kept
+}
+
+// editCard repairs the contradictions in a card that can be repaired and
+// returns the first one that cannot. Caller must hold e.mu.
+func (e *GameEngine) editCard(cardDef map[string]interface{}, tags, facts map[string]bool) error {
+	if nodeID, _ := cardDef["plot_node"].(string); nodeID != "" {
+		node := e.dag.GetNode(nodeID)
+		if node == nil || !node.IsFired {
+			return fmt.Errorf("plot node %q has not fired", nodeID)
+		}
+	}
+
+	// "!fact" assumes the fact does not hold
+	assumes, _ := cardDef["assumes"].([]interface{})
+	for _, raw := range assumes {
+		assumed, _ := raw.(string)
+		factID, negated := strings.CutPrefix(assumed, "!")
+		if factID != "" && facts[factID] == negated {
+			return fmt.Errorf("assumes %q, which contradicts the established facts", assumed)
+		}
+	}
+
+	for _, choice := range cardChoices(cardDef) {
+		for _, call := range choiceCalls(choice) {
+			if name, _ := call["name"].(string); name == "remove_tag" {
+				if tagID, ok := callParam(call, "tag_id"); ok && !tags[tagID] {
+					return fmt.Errorf("removes tag %q the player does not have", tagID)
+				}
+			}
+		}
+	}
+
+	if character, _ := cardDef["character"].(string); character != "" {
+		if npc, ok := e.state.NPCs[character]; ok && !npc.Enabled {
+			cardDef["character"] = "narrator"
+			e.noteRepair(cardDef, fmt.Sprintf("absent NPC %q handed to the narrator", character))
+		}
+	}
+
+	for _, choice := range cardChoices(cardDef) {
+		calls, _ := choice["calls"].([]interface{})
+		kept := calls[:0]
+		for _, raw := range calls {
+			call, _ := raw.(map[string]interface{})
+			if name, _ := call["name"].(string); name == "enable_npc" {
+				if npcID, ok := callParam(call, "npc_id"); ok && e.state.NPCs[npcID].Deceased {
+					e.noteRepair(cardDef, fmt.Sprintf("enable_npc of deceased NPC %q dropped", npcID))
+					continue
+				}
+				if npcID, ok := callParam(call, "npc_id"); ok && e.state.NPCs[npcID].Departed {
+					e.noteRepair(cardDef, fmt.Sprintf("enable_npc of departed NPC %q dropped", npcID))
+					continue
+				}
+			}
+			kept = append(kept, raw)
+		}
+		if calls != nil {
+			choice["calls"] = kept
+		}
+	}
+	return nil
+}
+
+// noteRepair records a contradiction the Editor repaired
+func (e *GameEngine) noteRepair(cardDef map[string]interface{}, detail string) {
+	agents.RecordValidationFailure(agents.ValidationFailure{
+		Agent:  agents.AgentEditor,
+		Class:  agents.FailureContradictionRepaired,
+		Detail: fmt.Sprintf("card %v: %s", cardDef["id"], detail),
+	})
+}
+
+// cardChoices returns the choices of a card definition, unlocked ones
+// included
+func cardChoices(cardDef map[string]interface{}) []map[string]interface{} {
+	choices := make([]map[string]interface{}, 0, 2)
+	for _, key := range []string{"left_choice", "right_choice"} {
+		for choice, _ := cardDef[key].(map[string]interface{}); choice != nil; choice, _ = choice["unlocked"].(map[string]interface{}) {
+			choices = append(choices, choice)
+		}
+	}
+	return choices
+}
+
+// choiceCalls returns the calls of a choice definition
+func choiceCalls(choice map[string]interface{}) []map[string]interface{} {
+	raw, _ := choice["calls"].([]interface{})
+	calls := make([]map[string]interface{}, 0, len(raw))
+	for _, c := range raw {
+		if call, ok := c.(map[string]interface{}); ok {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// callParam returns a string parameter of a call definition
+func callParam(call map[string]interface{}, key string) (string, bool) {
+	params, _ := call["params"].(map[string]interface{})
+	value, ok := params[key].(string)
+	return value, ok && value != ""
+}
@@ -0,0 +1,60 @@
+package game
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/story"
+)
+
+// EndingEntry describes one ending in a world's ending collection
+type EndingEntry struct {
+	ID          string `json:"id"`
+	Tier        string `json:"tier"`
+	Description string `json:"description,omitempty"` // hidden for locked secret endings
+	Unlocked    bool   `json:"unlocked"`
+}
+
+// WorldKey returns a stable identifier for a world schema, so games created
+// from the same schema can share an ending collection
+func WorldKey(schema *agents.WorldGenSchema) string {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// GetEndingCollection lists the world's endings, marking those in unlocked.
+// Secret endings keep their description hidden until unlocked.
+func (e *GameEngine) GetEndingCollection(unlocked map[string]bool) []EndingEntry {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	entries := make([]EndingEntry, 0)
+	for _, node := range e.dag.GetEndingNodes() {
+		tier := story.NormalizeEndingTier(node.EndingTier)
+		entry := EndingEntry{
+			ID:       node.ID,
+			Tier:     tier,
+			Unlocked: unlocked[node.ID] || node.IsFired,
+		}
+		if entry.Unlocked || tier != story.EndingTierSecret {
+			entry.Description = node.PlotDescription
+		}
+		entries = append(entries, entry)
+	}
+
+	tierOrder := map[string]int{story.EndingTierCommon: 0, story.EndingTierGood: 1, story.EndingTierSecret: 2}
+	sort.Slice(entries, func(i, j int) bool {
+		if tierOrder[entries[i].Tier] != tierOrder[entries[j].Tier] {
+			return tierOrder[entries[i].Tier] < tierOrder[entries[j].Tier]
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries
+}
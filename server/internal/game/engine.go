@@ -2,7 +2,10 @@ package game
 
 import (
 	"container/list"
+	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -10,6 +13,8 @@ import (
 	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
 	"github.com/qninhdt/world-card-ai-2/server/internal/death"
 	"github.com/qninhdt/world-card-ai-2/server/internal/story"
+	"github.com/qninhdt/world-card-ai-2/server/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // GameEngine orchestrates the entire game loop
@@ -24,16 +29,111 @@ type GameEngine struct {
 	immediateDeque   *list.List // cards shown before deck
 	awaitingResurrection bool
 	firstWeekStarted bool
+	versions         *versionLog
+	history          *undoHistory // actions that can be taken back
+	conditions       *story.ConditionCache // plot and event condition results, kept until what they read changes
 	mu               sync.RWMutex
 }
 
 // NewGameEngine creates a new game from a world schema
 func NewGameEngine(id string, schema *agents.WorldGenSchema) (*GameEngine, error) {
+	return NewSeededGameEngine(id, schema, time.Now().UnixNano())
+}
+
+// NewSeededGameEngine creates a new game whose chance rolls, deck order and
+// plot tie-breaks all follow seed, so the same schema and choices replay
+// the same run
+func NewSeededGameEngine(id string, schema *agents.WorldGenSchema, seed int64) (*GameEngine, error) {
 	state := NewGlobalBlackboard(schema)
+	state.RNGSeed = seed
+	state.WorldKey = WorldKey(schema)
+	if err := cards.ValidateMacros(state.Macros); err != nil {
+		return nil, err
+	}
+	itemDefs, items, err := newItemDefs(schema.Items, schema.InitialItems)
+	if err != nil {
+		return nil, err
+	}
+	if len(itemDefs) > 0 {
+		state.ItemDefs = itemDefs
+		state.Items = items
+	}
+	resourceDefs, resources, err := newResourceDefs(schema.Resources, schema.Stats)
+	if err != nil {
+		return nil, err
+	}
+	if len(resourceDefs) > 0 {
+		state.ResourceDefs = resourceDefs
+		state.Resources = resources
+	}
+	rules, err := newPressureRules(schema.PressureRules)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) > 0 {
+		state.PressureRules = rules
+	}
+	if err := validateStatMultipliers(schema); err != nil {
+		return nil, err
+	}
+	if state.Difficulty, err = newDifficulty(schema.Difficulty); err != nil {
+		return nil, err
+	}
+	if state.Calendar, err = newCalendar(schema.Calendar, len(schema.Seasons)); err != nil {
+		return nil, err
+	}
+	if state.Deck, err = newDeckConfig(schema.Deck, state.Calendar); err != nil {
+		return nil, err
+	}
+	if err := schema.Sampling.Validate(); err != nil {
+		return nil, err
+	}
+	state.Sampling = schema.Sampling
+	mortality, err := newMortalityRules(schema.MortalityRules)
+	if err != nil {
+		return nil, err
+	}
+	if len(mortality) > 0 {
+		state.MortalityRules = mortality
+	}
+	state.ResurrectionMechanic = death.NormalizeMechanic(state.ResurrectionMechanic)
+	if state.ResurrectionMechanic == death.MechanicHeir {
+		if state.Dynasty, err = newDynasty(schema.Dynasty); err != nil {
+			return nil, err
+		}
+	}
+	eras, err := newEras(schema.Eras, state.NPCs)
+	if err != nil {
+		return nil, err
+	}
+	if state.ResurrectionMechanic == death.MechanicTimeLoop {
+		state.MarkLoopStart()
+	}
+	state.LoadoutPool = newLoadouts(schema)
+	if len(eras) > 0 {
+		state.Eras = eras
+		state.Era = eras[0].Name
+		// NPCs of later eras wait offstage until their era begins
+		for _, era := range eras[1:] {
+			for _, npcID := range era.IntroduceNPCs {
+				state.DisableNPC(npcID)
+			}
+		}
+	}
 	dag := story.NewMacroDAG()
 
+	// Register story arcs
+	for _, arcDef := range schema.Arcs {
+		if err := dag.AddArc(&story.Arc{ID: arcDef.ID, Title: arcDef.Title, Theme: arcDef.Theme}); err != nil {
+			return nil, err
+		}
+	}
+
 	// Build DAG from schema
 	for _, nodeDef := range schema.PlotNodes {
+		if nodeDef.ArcID != "" && dag.GetArc(nodeDef.ArcID) == nil {
+			return nil, fmt.Errorf("node %s references unknown arc %s", nodeDef.ID, nodeDef.ArcID)
+		}
 		node := &story.PlotNode{
 			ID:              nodeDef.ID,
 			PlotDescription: nodeDef.PlotDescription,
@@ -41,6 +141,22 @@ func NewGameEngine(id string, schema *agents.WorldGenSchema) (*GameEngine, error
 			Calls:           nodeDef.Calls,
 			IsEnding:        nodeDef.IsEnding,
 			IsFired:         false,
+			ArcID:           nodeDef.ArcID,
+			ExclusiveGroup:  nodeDef.ExclusiveGroup,
+		}
+		if nodeDef.IsEnding {
+			node.EndingTier = story.NormalizeEndingTier(nodeDef.EndingTier)
+		}
+		if nodeDef.Deadline != nil {
+			action := nodeDef.Deadline.Action
+			if action == "" {
+				action = story.DeadlineActionNudge
+			}
+			node.Deadline = &story.SoftDeadline{
+				Week:              nodeDef.Deadline.Week,
+				Action:            action,
+				FallbackCondition: nodeDef.Deadline.FallbackCondition,
+			}
 		}
 		if err := dag.AddNode(node); err != nil {
 			return nil, err
@@ -60,28 +176,38 @@ func NewGameEngine(id string, schema *agents.WorldGenSchema) (*GameEngine, error
 		ID:             id,
 		state:          state,
 		dag:            dag,
-		deck:           cards.NewWeightedDeque(7),
-		deathLoop:      death.NewDeathLoop(state),
+		deck:           cards.NewWeightedDeque(state.deckConfig().Size, state.Rand()),
 		jobQueue:       NewJobQueue(),
 		drawnCards:     make([]cards.Card, 0),
 		immediateDeque: list.New(),
+		versions:       newVersionLog(),
+		history:        newUndoHistory(DefaultUndoDepth),
+		conditions:     story.NewConditionCache(),
 	}
+	engine.deathLoop = engine.newDeathLoop()
+	engine.beginWeek()
+	engine.recordVersion()
 
 	return engine, nil
 }
 
 // LoadGameEngine loads an existing game
 func LoadGameEngine(id string, state *GlobalBlackboard, dag *story.MacroDAG) *GameEngine {
-	return &GameEngine{
+	engine := &GameEngine{
 		ID:             id,
 		state:          state,
 		dag:            dag,
-		deck:           cards.NewWeightedDeque(7),
-		deathLoop:      death.NewDeathLoop(state),
+		deck:           cards.NewWeightedDeque(state.deckConfig().Size, state.Rand()),
 		jobQueue:       NewJobQueue(),
 		drawnCards:     make([]cards.Card, 0),
 		immediateDeque: list.New(),
+		versions:       newVersionLog(),
+		history:        newUndoHistory(DefaultUndoDepth),
+		conditions:     story.NewConditionCache(),
 	}
+	engine.deathLoop = engine.newDeathLoop()
+	engine.recordVersion()
+	return engine
 }
 
 // GetState returns the current game state
@@ -91,6 +217,19 @@ func (e *GameEngine) GetState() *GlobalBlackboard {
 	return e.state
 }
 
+// GetPlayerState returns the blackboard as the player may see it, with
+// hidden stats and the RNG seed removed
+func (e *GameEngine) GetPlayerState() *GlobalBlackboard {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	view := *e.state
+	view.Stats = e.state.VisibleStats()
+	view.HiddenStats = nil
+	view.RNGSeed = 0
+	return &view
+}
+
 // GetDAG returns the story DAG
 func (e *GameEngine) GetDAG() *story.MacroDAG {
 	e.mu.RLock()
@@ -98,41 +237,186 @@ func (e *GameEngine) GetDAG() *story.MacroDAG {
 	return e.dag
 }
 
+// SaveCopy returns copies of the state and DAG taken under the engine lock,
+// so a save can serialize them while requests keep changing the game
+func (e *GameEngine) SaveCopy() (*GlobalBlackboard, *story.MacroDAG, error) {
+	e.mu.RLock()
+	stateJSON, err := json.Marshal(e.state)
+	if err != nil {
+		e.mu.RUnlock()
+		return nil, nil, err
+	}
+	dagJSON, err := json.Marshal(e.dag)
+	e.mu.RUnlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	state := &GlobalBlackboard{}
+	if err := json.Unmarshal(stateJSON, state); err != nil {
+		return nil, nil, err
+	}
+	dag := story.NewMacroDAG()
+	if err := json.Unmarshal(dagJSON, dag); err != nil {
+		return nil, nil, err
+	}
+	return state, dag, nil
+}
+
 // DrawCard draws a single card (from immediate deque first, then deck)
 func (e *GameEngine) DrawCard() cards.Card {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	var card cards.Card
 	if e.immediateDeque.Len() > 0 {
 		elem := e.immediateDeque.Front()
 		e.immediateDeque.Remove(elem)
-		return elem.Value.(cards.Card)
+		card = elem.Value.(cards.Card)
+	} else {
+		card = e.deck.Draw()
 	}
 
-	return e.deck.Draw()
+	if card != nil {
+		e.unlockChoices(card)
+		e.noteAppearances([]cards.Card{card})
+		e.refreshCodex()
+	}
+	return card
 }
 
-// DrawCards draws cards for the week
+// DrawCards draws cards for the week. Daily games draw only the day's
+// card, whatever the count.
 func (e *GameEngine) DrawCards(count int) ([]cards.Card, error) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	e.drawnCards = e.deck.DrawN(count)
+	if err := e.checkActive(); err != nil {
+		return nil, err
+	}
+	cp := e.undoPoint()
+	if e.state.drawMode() == DrawModeDaily {
+		drawn, repeat, err := e.drawDay()
+		if err != nil || repeat {
+			return drawn, err
+		}
+	} else {
+		// Onboarding cards come before the week's deck
+		e.drawnCards = e.drawTutorial(count)
+		if remaining := count - len(e.drawnCards); remaining > 0 {
+			e.drawnCards = append(e.drawnCards, e.deck.DrawDays(1, remaining)...)
+		}
+	}
+	for _, card := range e.drawnCards {
+		e.unlockChoices(card)
+	}
+	e.noteAppearances(e.drawnCards)
+	e.refreshCodex()
+	e.history.push(cp)
 	return e.drawnCards, nil
 }
 
-// IsWeekOver returns true if the deck is empty and no immediate cards
+// IsWeekOver returns true if the deck is empty and no immediate cards, or
+// a daily game has played every day of the week
 func (e *GameEngine) IsWeekOver() bool {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
+	return e.isWeekOver()
+}
+
+// isWeekOver reports whether the week is done. Caller must hold e.mu.
+func (e *GameEngine) isWeekOver() bool {
+	if e.state.DaysPlayed >= e.state.calendar().DaysPerWeek {
+		return true
+	}
 	return e.deck.Size() == 0 && e.immediateDeque.Len() == 0
 }
 
+// FindCard returns a card the player has drawn or has yet to draw
+func (e *GameEngine) FindCard(cardID string) cards.Card {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, card := range e.drawnCards {
+		if card.GetID() == cardID {
+			return card
+		}
+	}
+	for elem := e.immediateDeque.Front(); elem != nil; elem = elem.Next() {
+		if card := elem.Value.(cards.Card); card.GetID() == cardID {
+			return card
+		}
+	}
+	for _, card := range e.deck.GetAll() {
+		if card.GetID() == cardID {
+			return card
+		}
+	}
+	return nil
+}
+
+// ChoiceDeltas sums the certain stat changes of a choice's update_stat
+// calls, leaving out hidden stats
+func (e *GameEngine) ChoiceDeltas(choice *cards.Choice) map[string]int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	deltas := make(map[string]int)
+	if choice == nil {
+		return deltas
+	}
+	for _, call := range choice.Calls {
+		if call.Name != "update_stat" {
+			continue
+		}
+		statID, _ := call.Params["stat_id"].(string)
+		delta, _ := call.Params["delta"].(float64)
+		if _, ok := e.state.Stats[statID]; !ok || e.state.HiddenStats[statID] || delta == 0 {
+			continue
+		}
+		deltas[statID] += int(delta)
+	}
+	return deltas
+}
+
+// RequirementError rejects a choice whose requirement does not hold
+type RequirementError struct {
+	Label    string
+	Requires string
+}
+
+func (e *RequirementError) Error() string {
+	return fmt.Sprintf("choice %q requires %s", e.Label, e.Requires)
+}
+
 // ResolveCard executes a card choice
 func (e *GameEngine) ResolveCard(cardID string, direction string) (*cards.ExecuteResult, error) {
+	return e.ResolveCardContext(context.Background(), cardID, direction)
+}
+
+// ResolveCardContext executes a card choice, traced as part of ctx
+func (e *GameEngine) ResolveCardContext(ctx context.Context, cardID string, direction string) (result *cards.ExecuteResult, err error) {
+	_, span := tracing.Start(ctx, "game.ResolveCard",
+		attribute.String("game.id", e.ID), attribute.String("card.id", cardID), attribute.String("card.direction", direction))
+	defer tracing.End(span, &err)
+
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	defer e.recordVersion()
+	span.AddEvent("locked")
+
+	if err := e.checkActive(); err != nil {
+		return nil, err
+	}
+	cp := e.undoPoint()
+	if result, err = e.resolveCard(cardID, direction); err == nil {
+		e.history.push(cp)
+	}
+	return result, err
+}
 
+// resolveCard resolves a drawn card. Caller must hold e.mu.
+func (e *GameEngine) resolveCard(cardID string, direction string) (*cards.ExecuteResult, error) {
 	// Find the card
 	var targetCard cards.Card
 	var cardIndex int = -1
@@ -169,6 +453,36 @@ func (e *GameEngine) ResolveCard(cardID string, direction string) (*cards.Execut
 			return nil, fmt.Errorf("choice not found for direction: %s", direction)
 		}
 
+		// SECURITY FIX: Enforce choice requirements server-side
+		if choice.Requires != "" {
+			met, err := story.EvaluateExpression(choice.Requires, e.buildConditionState())
+			if err != nil || !met {
+				return nil, &RequirementError{Label: choice.Label, Requires: choice.Requires}
+			}
+		}
+
+		// Onboarding cards only teach the swipe
+		if isTutorialCard(choiceCard) {
+			e.drawnCards = append(e.drawnCards[:cardIndex], e.drawnCards[cardIndex+1:]...)
+			e.state.UpdatedAt = time.Now()
+			return result, nil
+		}
+
+		// Meta-choices between lives shape the next life, not this one
+		if e.isInterludeCard(choiceCard) {
+			if err := e.chooseBoon(choice); err != nil {
+				return nil, err
+			}
+			e.drawnCards = append(e.drawnCards[:cardIndex], e.drawnCards[cardIndex+1:]...)
+			e.state.UpdatedAt = time.Now()
+			return result, nil
+		}
+
+		e.state.LogChoice(targetCard, choice, direction)
+		tagsBefore := e.state.GetTags()
+		phasesBefore := e.eventPhases()
+		departedBefore := e.state.departedNPCs()
+
 		// Execute function calls
 		executor := cards.NewActionExecutor(e.state)
 		for _, call := range choice.Calls {
@@ -183,11 +497,32 @@ func (e *GameEngine) ResolveCard(cardID string, direction string) (*cards.Execut
 			for stat, delta := range res.StatChanges {
 				result.StatChanges[stat] += delta
 			}
+			result.AddItemChanges(res.ItemChanges)
+			result.AddResourceChanges(res.ResourceChanges)
+			result.AddAffinityChanges(res.AffinityChanges)
 			result.TreeCards = append(result.TreeCards, res.TreeCards...)
+			result.Rolls = append(result.Rolls, res.Rolls...)
+			for _, m := range res.Modifiers {
+				result.AddModifier(m)
+			}
 		}
+		e.state.LogRolls(cardID, result.Rolls)
+		e.state.LogBeat(direction, result.StatChanges)
+		e.state.logChoiceEffects(result.StatChanges)
 
 		// Add tree cards
 		result.TreeCards = append(result.TreeCards, choice.TreeCards...)
+
+		// Phases the choice began may interrupt the week at once
+		e.queueInterruptions(phasesBefore)
+		e.queueDepartures(departedBefore)
+
+		// Surface plot nodes that this choice just unlocked
+		changed := changedPaths(result, tagsBefore, e.state.Tags)
+		for _, node := range e.dag.PendingHints(changed, e.buildConditionState()) {
+			result.PendingPlots = append(result.PendingPlots, node.ID)
+		}
+		e.refreshCodex()
 	} else if infoCard, ok := targetCard.(*cards.InfoCard); ok {
 		// Info cards don't have choices, just add next cards
 		result.TreeCards = append(result.TreeCards, infoCard.NextCards...)
@@ -196,27 +531,129 @@ func (e *GameEngine) ResolveCard(cardID string, direction string) (*cards.Execut
 	// SECURITY FIX: Remove card from drawn cards to prevent re-resolution
 	e.drawnCards = append(e.drawnCards[:cardIndex], e.drawnCards[cardIndex+1:]...)
 
+	// Interruptions are dealt ahead of the rest of the hand
+	if interrupts := e.drawInterruptions(); len(interrupts) > 0 {
+		result.Interrupts = interrupts
+		e.drawnCards = append(interrupts, e.drawnCards...)
+		for _, card := range interrupts {
+			e.unlockChoices(card)
+		}
+		e.noteAppearances(interrupts)
+	}
+
+	// Hidden stats change silently
+	for statID := range e.state.HiddenStats {
+		delete(result.StatChanges, statID)
+	}
+	modifiers := result.Modifiers[:0]
+	for _, m := range result.Modifiers {
+		if !e.state.HiddenStats[m.StatID] {
+			modifiers = append(modifiers, m)
+		}
+	}
+	result.Modifiers = modifiers
+
+	// A daily game's day passes with its card; interruptions take none
+	if !isInterruption(targetCard) {
+		e.playDay()
+	}
+
 	e.state.UpdatedAt = time.Now()
 	return result, nil
 }
 
+// changedPaths lists condition-state paths touched by a resolution
+func changedPaths(result *cards.ExecuteResult, tagsBefore, tagsAfter map[string]bool) []string {
+	paths := make([]string, 0, len(result.StatChanges)+len(result.ItemChanges)+len(result.ResourceChanges))
+	for statID, delta := range result.StatChanges {
+		if delta != 0 {
+			paths = append(paths, "stats."+statID)
+		}
+	}
+	for itemID := range result.ItemChanges {
+		paths = append(paths, "items."+itemID)
+	}
+	for resourceID := range result.ResourceChanges {
+		paths = append(paths, "resources."+resourceID)
+	}
+	for npcID := range result.AffinityChanges {
+		paths = append(paths, "affinity."+npcID)
+	}
+	for tagID := range tagsBefore {
+		if !tagsAfter[tagID] {
+			paths = append(paths, "tags."+tagID)
+		}
+	}
+	for tagID := range tagsAfter {
+		if !tagsBefore[tagID] {
+			paths = append(paths, "tags."+tagID)
+		}
+	}
+	return paths
+}
+
 // AdvanceWeek advances the game by one week
 func (e *GameEngine) AdvanceWeek() error {
+	return e.AdvanceWeekContext(context.Background())
+}
+
+// AdvanceWeekContext advances the game by one week, traced as part of ctx
+func (e *GameEngine) AdvanceWeekContext(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "game.AdvanceWeek", attribute.String("game.id", e.ID))
+	defer tracing.End(span, &err)
+
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	defer e.recordVersion()
+	span.AddEvent("locked")
 
-	// Advance 7 days
-	for i := 0; i < 7; i++ {
-		e.state.AdvanceDay()
+	if err := e.checkActive(); err != nil {
+		return err
+	}
+	cp := e.undoPoint()
+	if err = e.advanceWeekContext(ctx); err == nil {
+		e.history.push(cp)
 	}
+	return err
+}
 
-	// Check plot conditions
-	if err := e.checkPlotConditions(); err != nil {
+// advanceWeek advances the game by one week. Caller must hold e.mu.
+func (e *GameEngine) advanceWeek() error {
+	return e.advanceWeekContext(context.Background())
+}
+
+// advanceWeekContext advances the game by one week, tracing its slow steps
+// as part of ctx. Caller must hold e.mu.
+func (e *GameEngine) advanceWeekContext(ctx context.Context) error {
+	// Sum up the week before its days pass
+	e.chronicleWeek()
+
+	// Advance the days of the week not yet played one card at a time
+	_, span := tracing.Start(ctx, "game.advanceDays")
+	for i := e.state.DaysPlayed; i < e.state.calendar().DaysPerWeek; i++ {
+		e.advanceDay()
+	}
+	e.state.DaysPlayed = 0
+	span.End()
+
+	// Escalate storylines that missed their soft deadline, then check plot conditions
+	_, span = tracing.Start(ctx, "game.checkPlotConditions")
+	err := e.escalateOverduePlots()
+	if err == nil {
+		err = e.checkPlotConditions()
+	}
+	tracing.End(span, &err)
+	if err != nil {
 		return err
 	}
 
 	// Check events
+	_, span = tracing.Start(ctx, "game.checkEvents")
 	e.checkEvents()
+	span.End()
+
+	// Drift stats and adjust difficulty to how the game is going
+	e.balanceDifficulty()
 
 	// Check death
 	if deathInfo, isDead := e.deathLoop.CheckDeath(); isDead {
@@ -234,19 +671,25 @@ func (e *GameEngine) AdvanceWeek() error {
 func (e *GameEngine) checkPlotConditions() error {
 	conditionState := e.buildConditionState()
 
-	activatable, err := e.dag.GetActivatableNodes(conditionState)
+	activatable, err := e.dag.GetActivatableNodesCached(conditionState, e.conditions)
 	if err != nil {
 		return err
 	}
 
 	if len(activatable) > 0 {
-		// Fire the first activatable node
+		// Fire one activatable node, the game's RNG breaking ties
 		node := activatable[0]
+		if len(activatable) > 1 {
+			node = activatable[e.state.Rand().Intn(len(activatable))]
+		}
 		if _, err := e.dag.FireNode(node.ID); err != nil {
 			return err
 		}
+		e.markPlotBeat()
+		e.notePlotFact(node.ID, node.PlotDescription)
 
 		// Execute node calls
+		defer e.watchDepartures()()
 		executor := cards.NewActionExecutor(e.state)
 		for _, call := range node.Calls {
 			callMap := map[string]interface{}{
@@ -264,9 +707,34 @@ func (e *GameEngine) checkPlotConditions() error {
 	return nil
 }
 
+// escalateOverduePlots handles nodes past their soft deadline: "loosen" nodes
+// switch to their fallback condition, "nudge" nodes queue a hinting info card
+func (e *GameEngine) escalateOverduePlots() error {
+	currentWeek := e.state.GetElapsedDays()/e.state.calendar().DaysPerWeek + 1
+
+	for _, node := range e.dag.GetOverdueNodes(currentWeek) {
+		if _, err := e.dag.EscalateDeadline(node.ID); err != nil {
+			return err
+		}
+		if node.Deadline.Action == story.DeadlineActionLoosen {
+			continue
+		}
+		e.jobQueue.Enqueue(&CardGenJob{
+			JobType: "nudge",
+			Context: map[string]interface{}{
+				"node_id":          node.ID,
+				"plot_description": node.PlotDescription,
+				"condition":        node.Condition,
+			},
+		})
+	}
+	return nil
+}
+
 // checkEvents checks and removes expired events
 func (e *GameEngine) checkEvents() {
 	toRemove := make([]string, 0)
+	conditionState := e.buildConditionState()
 
 	for eventID, event := range e.state.Events {
 		switch ev := event.(type) {
@@ -275,8 +743,10 @@ func (e *GameEngine) checkEvents() {
 				toRemove = append(toRemove, eventID)
 			}
 		case *ConditionEvent:
-			conditionState := e.buildConditionState()
-			if result, err := e.dag.CheckCondition(eventID, conditionState); err == nil && result {
+			if ev.EndCondition == "" {
+				continue
+			}
+			if result, err := story.EvaluateCached(e.conditions, "event:"+eventID, ev.EndCondition, conditionState); err == nil && result {
 				toRemove = append(toRemove, eventID)
 			}
 		case *PhaseEvent:
@@ -299,7 +769,11 @@ func (e *GameEngine) checkEvents() {
 func (e *GameEngine) GetAllEventsForDisplay() []map[string]interface{} {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
+	return e.eventsForDisplay()
+}
 
+// eventsForDisplay is GetAllEventsForDisplay without locking
+func (e *GameEngine) eventsForDisplay() []map[string]interface{} {
 	var eventsDisplay []map[string]interface{}
 	for _, event := range e.state.Events {
 		display := map[string]interface{}{
@@ -319,13 +793,23 @@ func (e *GameEngine) GetGenerationContext() map[string]interface{} {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
+	stats := e.buildStatList()
 	return map[string]interface{}{
 		"is_season_start":         e.state.Day == 1,
 		"is_first_day_after_death": e.state.IsFirstDayAfterDeath,
 		"snapshot":                e.buildSnapshot(),
 		"dag_context":             e.dag.GetWriterContext(),
-		"ongoing_events":          e.GetAllEventsForDisplay(),
+		"ongoing_events":          e.eventsForDisplay(),
+		"stats":                   stats,
+		"danger_flags":            buildDangerFlags(stats),
+		"pacing":                  e.direct().context(),
+		"difficulty":              e.buildDifficulty(),
+		"deck":                    e.buildDeckContext(),
 		"available_tags":          e.buildAvailableTags(),
+		"items":                   e.buildItemList(),
+		"resources":               e.buildResourceList(),
+		"macros":                  e.buildMacroList(),
+		"sampling":                e.state.Sampling,
 		"season": map[string]interface{}{
 			"name":        e.getCurrentSeasonName(),
 			"description": e.getCurrentSeasonDescription(),
@@ -343,16 +827,22 @@ func (e *GameEngine) buildSnapshot() map[string]interface{} {
 			"name":        npc.Name,
 			"enabled":     npc.Enabled,
 			"appearances": npc.AppearanceCount,
+			"age":         npc.Age,
+			"deceased":    npc.Deceased,
+			"affinity":    npc.Affinity,
+			"departed":    npc.Departed,
 		})
 	}
 
 	relationshipList := make([]map[string]interface{}, 0)
 	// Add relationships from state
 	for _, rel := range e.state.Relationships {
+		inherited, _ := rel["inherited"].(bool)
 		relationshipList = append(relationshipList, map[string]interface{}{
 			"a":            rel["from"],
 			"b":            rel["to"],
 			"relationship": rel["description"],
+			"inherited":    inherited,
 		})
 	}
 
@@ -371,14 +861,119 @@ func (e *GameEngine) buildSnapshot() map[string]interface{} {
 		"week":         e.state.WeekInSeason(),
 		"life":         e.state.LifeNumber,
 		"stats":        e.state.Stats,
+		"hidden_stats": e.buildHiddenStatList(),
 		"tags":         tagList,
+		"items":        e.state.GetItems(),
+		"resources":    e.state.GetResources(),
 		"karma":        e.state.Karma,
 		"player": map[string]interface{}{
 			"name": e.state.PlayerChar.Name,
 		},
-		"npcs":          npcList,
-		"relationships": relationshipList,
+		"resurrection": map[string]interface{}{
+			"mechanic":      e.deathLoop.Mechanic().Name(),
+			"flavor":        e.state.ResurrectionFlavor,
+			"reborn_prompt": e.deathLoop.Mechanic().RebornPrompt(),
+		},
+		"npcs":           npcList,
+		"relationships":  relationshipList,
+		"pressure_rules": e.buildPressureRules(),
+		"era_info":       e.buildEraContext(),
+		"loop":           e.state.LoopCount,
+		"knowledge":      e.buildKnowledgeList(),
+		"facts":          e.buildFactList(),
+	}
+}
+
+// buildHiddenStatList returns the IDs of stats the player cannot see yet,
+// so the Writer can hint at them without naming values
+func (e *GameEngine) buildHiddenStatList() []string {
+	hidden := make([]string, 0, len(e.state.HiddenStats))
+	for id := range e.state.HiddenStats {
+		hidden = append(hidden, id)
+	}
+	sort.Strings(hidden)
+	return hidden
+}
+
+// statDangerMargin is how close to 0 or 100 a stat must be for the Writer
+// to treat it as life-threatening
+const statDangerMargin = 15
+
+// buildStatList describes each stat for the Writer: its display name and
+// description, current value and how close it is to killing the player.
+// Stats follow the schema's order; games saved before stat definitions were
+// kept fall back to their IDs, sorted.
+func (e *GameEngine) buildStatList() []map[string]interface{} {
+	ids := make([]string, 0, len(e.state.Stats))
+	defs := make(map[string]map[string]interface{}, len(e.state.StatDefs))
+	for _, def := range e.state.StatDefs {
+		id, _ := def["id"].(string)
+		if _, ok := e.state.Stats[id]; ok {
+			ids = append(ids, id)
+			defs[id] = def
+		}
 	}
+	extra := make([]string, 0)
+	for id := range e.state.Stats {
+		if _, ok := defs[id]; !ok {
+			extra = append(extra, id)
+		}
+	}
+	sort.Strings(extra)
+	ids = append(ids, extra...)
+
+	stats := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		value := e.state.Stats[id]
+		name, description := id, ""
+		if def, ok := defs[id]; ok {
+			if n, _ := def["name"].(string); n != "" {
+				name = n
+			}
+			description, _ = def["description"].(string)
+		}
+
+		// The player dies when a stat reaches either bound
+		boundary, distance := 0, value
+		if 100-value < value {
+			boundary, distance = 100, 100-value
+		}
+		stats = append(stats, map[string]interface{}{
+			"id":               id,
+			"name":             name,
+			"description":      description,
+			"value":            value,
+			"hidden":           e.state.HiddenStats[id],
+			"nearest_boundary": boundary,
+			"distance":         distance,
+			"in_danger":        distance <= statDangerMargin,
+		})
+	}
+	return stats
+}
+
+// buildDangerFlags picks the stats in danger out of a stat list and says
+// which way each must move to pull the player back from death
+func buildDangerFlags(stats []map[string]interface{}) []map[string]interface{} {
+	flags := make([]map[string]interface{}, 0)
+	for _, stat := range stats {
+		if inDanger, _ := stat["in_danger"].(bool); !inDanger {
+			continue
+		}
+		relief := "raise"
+		if stat["nearest_boundary"] == 100 {
+			relief = "lower"
+		}
+		flags = append(flags, map[string]interface{}{
+			"stat_id":  stat["id"],
+			"name":     stat["name"],
+			"value":    stat["value"],
+			"boundary": stat["nearest_boundary"],
+			"distance": stat["distance"],
+			"relief":   relief,
+		})
+	}
+	return flags
 }
 
 // buildAvailableTags returns list of available tags
@@ -394,13 +989,30 @@ func (e *GameEngine) buildAvailableTags() []map[string]interface{} {
 	return tags
 }
 
+// buildMacroList returns the world's macros, sorted by ID, so the Writer
+// can call compound effects by name
+func (e *GameEngine) buildMacroList() []map[string]interface{} {
+	ids := make([]string, 0, len(e.state.Macros))
+	for id := range e.state.Macros {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	macros := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		macro := e.state.Macros[id]
+		macros = append(macros, map[string]interface{}{
+			"id":          macro.ID,
+			"description": macro.Description,
+			"calls":       macro.Calls,
+		})
+	}
+	return macros
+}
+
 // getCurrentSeasonName returns the current season name
 func (e *GameEngine) getCurrentSeasonName() string {
-	seasonNames := []string{"Spring", "Summer", "Autumn", "Winter"}
-	if e.state.Season >= 0 && e.state.Season < len(seasonNames) {
-		return seasonNames[e.state.Season]
-	}
-	return "Unknown"
+	return e.state.SeasonName()
 }
 
 // getCurrentSeasonDescription returns the current season description
@@ -414,31 +1026,50 @@ func (e *GameEngine) getCurrentSeasonDescription() string {
 	return ""
 }
 
-// GetWeekDeckSize returns how many cards to generate for a week deck
-func (e *GameEngine) GetWeekDeckSize() int {
-	return 7
-}
-
-// GetCommonCount returns how many common cards to generate
-func (e *GameEngine) GetCommonCount() int {
-	jobCount := e.jobQueue.Count()
-	if 7-jobCount < 1 {
-		return 1
-	}
-	return 7 - jobCount
-}
-
 // AddCardsFromDefs validates and inserts cards from Writer output
 func (e *GameEngine) AddCardsFromDefs(cardDefs []map[string]interface{}) int {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	defer e.recordVersion()
+
+	if e.state.StoryEditor {
+		cardDefs = e.editBatch(cardDefs)
+	}
 
 	count := 0
 	for _, cardDef := range cardDefs {
+		// Interlude cards wait for the player between lives, outside the deck
+		switch source, _ := cardDef["source"].(string); source {
+		case "interlude":
+			if e.addInterludeCard(cardDef) {
+				count++
+			}
+			continue
+		case "obituary":
+			if e.addObituary(cardDef) {
+				count++
+			}
+			continue
+		case chronicleSource:
+			if e.addChronicle(cardDef) {
+				count++
+			}
+			continue
+		case loreSource:
+			if e.addLore(cardDef) {
+				count++
+			}
+			continue
+		}
+
 		card := e.convertToCard(cardDef)
 		if card != nil {
 			e.deck.Insert(card)
 			count++
+			// Successor cards bring a new NPC into the cast
+			if npcDef, ok := cardDef["new_npc"].(map[string]interface{}); ok {
+				e.addSuccessorNPC(npcDef)
+			}
 		}
 	}
 	return count
@@ -453,36 +1084,68 @@ func (e *GameEngine) convertToCard(cardDef map[string]interface{}) cards.Card {
 
 	title, _ := cardDef["title"].(string)
 	description, _ := cardDef["description"].(string)
+	simpleDescription, _ := cardDef["simple_description"].(string)
 	character, _ := cardDef["character"].(string)
 	source, _ := cardDef["source"].(string)
 	priority := cards.PriorityCommon
 	if p, ok := cardDef["priority"].(float64); ok {
 		priority = int(p)
 	}
+	window := parseRevealWindow(cardDef["reveal_window"], e.state.calendar().DaysPerWeek)
 
 	// Check if it's a choice card or info card
 	if _, hasLeftChoice := cardDef["left_choice"]; hasLeftChoice {
-		return &cards.ChoiceCard{
-			ID:          id,
-			Title:       title,
-			Description: description,
-			Character:   character,
-			Source:      source,
-			Priority:    priority,
-			LeftChoice:  e.parseChoice(cardDef["left_choice"]),
-			RightChoice: e.parseChoice(cardDef["right_choice"]),
+		card := &cards.ChoiceCard{
+			ID:                id,
+			Title:             title,
+			Description:       description,
+			SimpleDescription: simpleDescription,
+			Character:         character,
+			Source:            source,
+			Priority:          priority,
+			LeftChoice:        e.parseChoice(cardDef["left_choice"]),
+			RightChoice:       e.parseChoice(cardDef["right_choice"]),
+			RevealWindow:      window,
+		}
+		// Drop cards whose requirements would never compile or that use
+		// tags the world does not define
+		known := e.knownTags()
+		for _, choice := range []*cards.Choice{card.LeftChoice, card.RightChoice} {
+			if !validRequirements(choice) || !e.guardTags(choice, known) {
+				return nil
+			}
 		}
+		return card
 	}
 
 	// Default to info card
 	return &cards.InfoCard{
-		ID:          id,
-		Title:       title,
-		Description: description,
-		Character:   character,
-		Source:      source,
-		Priority:    priority,
+		ID:                id,
+		Title:             title,
+		Description:       description,
+		SimpleDescription: simpleDescription,
+		Character:         character,
+		Source:            source,
+		Priority:          priority,
+		RevealWindow:      window,
+	}
+}
+
+// validRequirements reports whether a choice's requirements compile.
+// Unlocked choices must have one.
+func validRequirements(choice *cards.Choice) bool {
+	if choice == nil {
+		return true
+	}
+	if choice.Requires != "" {
+		if _, err := story.ConditionDependencies(choice.Requires); err != nil {
+			return false
+		}
+	}
+	if choice.Unlocked != nil && choice.Unlocked.Requires == "" {
+		return false
 	}
+	return validRequirements(choice.Unlocked)
 }
 
 // parseChoice converts a choice definition to a Choice object
@@ -512,9 +1175,13 @@ func (e *GameEngine) parseChoice(choiceDef interface{}) *cards.Choice {
 		}
 	}
 
+	requires, _ := choiceMap["requires"].(string)
+
 	return &cards.Choice{
-		Label: label,
-		Calls: calls,
+		Label:    label,
+		Calls:    calls,
+		Requires: requires,
+		Unlocked: e.parseChoice(choiceMap["unlocked"]),
 	}
 }
 
@@ -522,6 +1189,8 @@ func (e *GameEngine) parseChoice(choiceDef interface{}) *cards.Choice {
 func (e *GameEngine) OnWeekEnd() error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	defer e.recordVersion()
+	defer e.watchDepartures()()
 
 	// Run season's on_week_end_calls
 	if e.state.Season >= 0 && e.state.Season < len(e.state.Seasons) {
@@ -541,6 +1210,8 @@ func (e *GameEngine) OnWeekEnd() error {
 		nodeID := e.state.PendingPlotNodeID
 		node, err := e.dag.FireNode(nodeID)
 		if err == nil && node != nil {
+			e.markPlotBeat()
+			e.notePlotFact(node.ID, node.PlotDescription)
 			executor := cards.NewActionExecutor(e.state)
 			for _, call := range node.Calls {
 				callMap := map[string]interface{}{
@@ -572,9 +1243,12 @@ func (e *GameEngine) OnWeekEnd() error {
 func (e *GameEngine) OnSeasonEnd() error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	defer e.recordVersion()
+	defer e.watchDepartures()()
 
 	// Run previous season's on_season_end_calls
-	prevSeason := (e.state.Season - 1 + 4) % 4
+	seasons := e.state.calendar().SeasonsPerYear
+	prevSeason := (e.state.Season - 1 + seasons) % seasons
 	if prevSeason >= 0 && prevSeason < len(e.state.Seasons) {
 		season := e.state.Seasons[prevSeason]
 		if calls, ok := season["on_season_end_calls"].([]interface{}); ok {
@@ -594,6 +1268,7 @@ func (e *GameEngine) OnSeasonEnd() error {
 func (e *GameEngine) FirePendingPlot() error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	defer e.recordVersion()
 
 	nodeID := e.state.PendingPlotNodeID
 	if nodeID == "" {
@@ -609,8 +1284,11 @@ func (e *GameEngine) FirePendingPlot() error {
 		e.state.PendingPlotNodeID = ""
 		return nil
 	}
+	e.markPlotBeat()
+	e.notePlotFact(node.ID, node.PlotDescription)
 
 	// Execute plot node function calls
+	defer e.watchDepartures()()
 	executor := cards.NewActionExecutor(e.state)
 	for _, call := range node.Calls {
 		callMap := map[string]interface{}{
@@ -704,6 +1382,14 @@ func (e *GameEngine) HandleDeath(deathInfo *death.DeathInfo) error {
 	// Add to immediate deque
 	e.immediateDeque.PushBack(deathCard)
 	e.awaitingResurrection = true
+	e.recordDeath(deathInfo, boundary)
+	e.beginInterlude(deathInfo, boundary)
+
+	// The reborn card offers starting loadouts for the next life
+	e.state.offerLoadouts()
+	if card := e.loadoutCard(); card != nil {
+		e.immediateDeque.PushBack(card)
+	}
 
 	return nil
 }
@@ -712,17 +1398,15 @@ func (e *GameEngine) HandleDeath(deathInfo *death.DeathInfo) error {
 func (e *GameEngine) CompleteResurrection() error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	defer e.recordVersion()
 
 	e.awaitingResurrection = false
 
-	// Resurrect
+	// The world's mechanic decides what resets and where the calendar goes
 	e.deathLoop.Resurrect(make(map[string]bool))
+	e.state.applyLoadout(nil)
 
-	// Advance to next season
-	e.state.AdvanceToNextSeason()
-	e.state.IsFirstDayAfterDeath = true
-
-	return nil
+	return e.endInterlude()
 }
 
 // IsAwaitingResurrection returns true if waiting for death card flip
@@ -736,23 +1420,24 @@ func (e *GameEngine) IsAwaitingResurrection() bool {
 func (e *GameEngine) AdvanceDayWithBoundaries() map[string]bool {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	defer e.recordVersion()
 
 	oldSeason := e.state.Season
 	oldYear := e.state.Year
 
-	e.state.AdvanceDay()
+	e.advanceDay()
 
 	crossed := map[string]bool{
 		"week_end":   false,
 		"season_end": false,
 	}
 
-	// Check week boundary (every 7 days)
+	// Check week boundary (every DaysPerWeek days)
 	if e.state.Turn == 0 {
 		crossed["week_end"] = true
 	}
 
-	// Check season boundary (every 28 days)
+	// Check season boundary (every DaysPerSeason days)
 	if oldSeason != e.state.Season || oldYear != e.state.Year {
 		crossed["season_end"] = true
 	}
@@ -774,18 +1459,34 @@ func (e *GameEngine) InsertTreeCards(treeCards []cards.Card) {
 func (e *GameEngine) CheckDeath() (*death.DeathInfo, bool) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	defer e.recordVersion()
 	return e.deathLoop.CheckDeath()
 }
 
-// Resurrect resurrects the player for a new life
-func (e *GameEngine) Resurrect(tempTags map[string]bool) error {
+// Resurrect resurrects the player for a new life, starting with the
+// loadout picked by swiping the reborn card in direction (empty for none)
+func (e *GameEngine) Resurrect(tempTags map[string]bool, direction string) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
+	defer e.recordVersion()
+
+	if err := e.checkActive(); err != nil {
+		return err
+	}
+	loadout, err := e.state.pickLoadout(direction)
+	if err != nil {
+		return err
+	}
 
+	e.history.push(e.undoPoint())
 	e.deathLoop.Resurrect(tempTags)
+	e.state.applyLoadout(loadout)
 	e.dag.PartialReset()
 	e.deck.Clear()
 	e.drawnCards = make([]cards.Card, 0)
+	if err := e.endInterlude(); err != nil {
+		return err
+	}
 
 	e.state.UpdatedAt = time.Now()
 	return nil
@@ -796,12 +1497,17 @@ func (e *GameEngine) buildConditionState() map[string]interface{} {
 	return map[string]interface{}{
 		"stats":        e.state.Stats,
 		"tags":         e.state.Tags,
+		"items":        e.state.Items,
+		"has_item":     e.state.hasItem,
+		"resources":    e.state.Resources,
+		"affinity":     e.state.affinities(),
 		"day":          e.state.Day,
 		"season":       e.state.Season,
 		"year":         e.state.Year,
 		"elapsed_days": e.state.GetElapsedDays(),
 		"is_alive":     e.state.IsAlive,
 		"current_life": e.state.CurrentLife,
+		"loop":         e.state.LoopCount,
 	}
 }
 
@@ -819,6 +1525,14 @@ func (e *GameEngine) GetGameInfo() map[string]interface{} {
 		"year":          e.state.Year,
 		"is_alive":      e.state.IsAlive,
 		"current_life":  e.state.CurrentLife,
+		"in_interlude":  e.state.Interlude != nil,
+		"draw_mode":     e.state.drawMode(),
+		"status":        e.state.status(),
+		"days_played":   e.state.DaysPlayed,
+		"week_over":     e.isWeekOver(),
+		"loadouts":      e.state.Loadouts,
+		"last_death":    e.state.LastDeath,
+		"undo":          UndoStatus{Undo: len(e.history.undo), Redo: len(e.history.redo), Depth: e.history.depth},
 		"created_at":    e.state.CreatedAt,
 		"updated_at":    e.state.UpdatedAt,
 	}
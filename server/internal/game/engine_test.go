@@ -1,10 +1,17 @@
 package game
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
 	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
+	"github.com/qninhdt/world-card-ai-2/server/internal/death"
+	"github.com/qninhdt/world-card-ai-2/server/internal/story"
 )
 
 // TestNewGameEngine tests game engine creation
@@ -48,6 +55,30 @@ func TestGetState(t *testing.T) {
 	}
 }
 
+// TestSaveCopy tests that saved copies do not share state with the engine
+func TestSaveCopy(t *testing.T) {
+	schema := createTestSchema()
+	engine, _ := NewGameEngine("test-game", schema)
+	stat := schema.Stats[0].ID
+
+	state, dag, err := engine.SaveCopy()
+	if err != nil {
+		t.Fatalf("SaveCopy failed: %v", err)
+	}
+	if state == engine.GetState() || dag == engine.GetDAG() {
+		t.Fatal("Expected copies, got the engine's own state and DAG")
+	}
+	if !reflect.DeepEqual(state.Stats, engine.GetState().Stats) || len(dag.GetAllNodes()) != len(engine.GetDAG().GetAllNodes()) {
+		t.Errorf("Expected the copy to match the engine, got %+v", state.Stats)
+	}
+
+	before := state.Stats[stat]
+	engine.GetState().UpdateStat(stat, 10)
+	if state.Stats[stat] != before {
+		t.Errorf("Expected the copy to keep %s at %d, got %d", stat, before, state.Stats[stat])
+	}
+}
+
 // TestDrawCard tests card drawing
 func TestDrawCard(t *testing.T) {
 	schema := createTestSchema()
@@ -243,6 +274,45 @@ func TestGetGenerationContext(t *testing.T) {
 	}
 }
 
+// TestGenerationStats tests the stat list given to the Writer
+func TestGenerationStats(t *testing.T) {
+	engine, err := NewGameEngine("test-game", createTestSchema())
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	engine.state.Stats["mana"] = 10
+
+	stats := engine.GetGenerationContext()["stats"].([]map[string]interface{})
+	if len(stats) != 2 || stats[0]["id"] != "health" || stats[1]["id"] != "mana" {
+		t.Fatalf("Expected health and mana in schema order, got %v", stats)
+	}
+	health, mana := stats[0], stats[1]
+	if health["name"] != "Health" || health["description"] != "Health stat" || health["value"] != 100 {
+		t.Errorf("Unexpected health entry: %v", health)
+	}
+	if health["nearest_boundary"] != 100 || health["distance"] != 0 || health["in_danger"] != true {
+		t.Errorf("Expected health at its upper bound to be in danger, got %v", health)
+	}
+	if mana["nearest_boundary"] != 0 || mana["distance"] != 10 || mana["in_danger"] != true {
+		t.Errorf("Expected low mana to be in danger, got %v", mana)
+	}
+	flags := engine.GetGenerationContext()["danger_flags"].([]map[string]interface{})
+	if len(flags) != 2 || flags[0]["stat_id"] != "health" || flags[0]["relief"] != "lower" || flags[1]["relief"] != "raise" {
+		t.Errorf("Expected danger flags to lower health and raise mana, got %v", flags)
+	}
+
+	// Games saved without stat definitions fall back to the IDs
+	engine.state.StatDefs = nil
+	engine.state.Stats["mana"] = 50
+	stats = engine.GetGenerationContext()["stats"].([]map[string]interface{})
+	if len(stats) != 2 || stats[1]["name"] != "mana" || stats[1]["in_danger"] != false {
+		t.Errorf("Expected ID fallback for mana, got %v", stats)
+	}
+	if flags := engine.GetGenerationContext()["danger_flags"].([]map[string]interface{}); len(flags) != 1 {
+		t.Errorf("Expected only health to stay flagged, got %v", flags)
+	}
+}
+
 // TestGetAllEventsForDisplay tests event display formatting
 func TestGetAllEventsForDisplay(t *testing.T) {
 	schema := createTestSchema()
@@ -408,53 +478,2639 @@ func TestGetCurrentSeasonDescription(t *testing.T) {
 	}
 }
 
-// Helper function to create a test schema
-func createTestSchema() *agents.WorldGenSchema {
-	return &agents.WorldGenSchema{
-		Name:        "Test World",
-		Era:         "Test Era",
-		Description: "A test world",
-		Stats: []agents.StatDef{
-			{ID: "health", Name: "Health", Description: "Health stat"},
-			{ID: "mana", Name: "Mana", Description: "Mana stat"},
-		},
-		Tags: []agents.TagDef{
-			{ID: "tag1", Name: "Tag 1", Description: "Test tag 1", IsTemp: false},
-			{ID: "tag2", Name: "Tag 2", Description: "Test tag 2", IsTemp: true},
+// TestSoftDeadlineEscalation tests nudge and loosen deadline handling
+func TestSoftDeadlineEscalation(t *testing.T) {
+	schema := createTestSchema()
+	schema.PlotNodes = []agents.PlotNodeDef{
+		{
+			ID:              "nudged",
+			PlotDescription: "A stranger arrives",
+			Condition:       "stats.health < 0",
+			Deadline:        &agents.DeadlineDef{Week: 1},
 		},
-		Seasons: []agents.SeasonDef{
-			{ID: "spring", Name: "Spring", Description: "Spring season"},
-			{ID: "summer", Name: "Summer", Description: "Summer season"},
-			{ID: "autumn", Name: "Autumn", Description: "Autumn season"},
-			{ID: "winter", Name: "Winter", Description: "Winter season"},
+		{
+			ID:              "loosened",
+			PlotDescription: "The harvest fails",
+			Condition:       "stats.mana > 1000",
+			Deadline:        &agents.DeadlineDef{Week: 1, Action: "loosen", FallbackCondition: "stats.mana > 10"},
 		},
-		PlayerChar: agents.PlayerCharacterDef{
-			EntityDef: agents.EntityDef{ID: "player", Name: "Player"},
-			Description: "The player character",
+	}
+	engine, err := NewGameEngine("test-game", schema)
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	engine.state.SetStat("health", 50)
+
+	// After seven days the game is in week 2, past both week-1 deadlines
+	if err := engine.AdvanceWeek(); err != nil {
+		t.Fatalf("AdvanceWeek failed: %v", err)
+	}
+
+	if engine.jobQueue.Count() != 1 {
+		t.Fatalf("Expected 1 nudge job, got %d", engine.jobQueue.Count())
+	}
+	job := engine.jobQueue.Drain()[0]
+	if job.JobType != "nudge" || job.Context["node_id"] != "nudged" {
+		t.Errorf("Expected nudge for 'nudged', got %v", job.Context["node_id"])
+	}
+
+	if engine.state.PendingPlotNodeID != "loosened" {
+		t.Errorf("Expected loosened node to fire, pending is %q", engine.state.PendingPlotNodeID)
+	}
+
+	// Escalation happens once
+	engine.AdvanceWeek()
+	if engine.jobQueue.Count() != 0 {
+		t.Errorf("Expected no repeated nudge, got %d jobs", engine.jobQueue.Count())
+	}
+}
+
+// TestPlotConditionsCached tests that plot conditions are reused from week
+// to week while nothing they read changes
+func TestPlotConditionsCached(t *testing.T) {
+	schema := createTestSchema()
+	schema.PlotNodes = []agents.PlotNodeDef{{ID: "omen", PlotDescription: "A comet", Condition: "tags.comet_seen"}}
+	engine, err := NewGameEngine("test-game", schema)
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := engine.AdvanceWeek(); err != nil {
+			t.Fatalf("AdvanceWeek failed: %v", err)
+		}
+	}
+	if engine.conditions.Misses != 1 || engine.conditions.Hits != 1 {
+		t.Errorf("Expected the omen condition to run once, got %d hits and %d misses", engine.conditions.Hits, engine.conditions.Misses)
+	}
+
+	engine.state.AddTag("comet_seen")
+	if err := engine.AdvanceWeek(); err != nil {
+		t.Fatalf("AdvanceWeek failed: %v", err)
+	}
+	if !engine.dag.GetNode("omen").IsFired {
+		t.Error("Expected the omen to fire once its tag was added")
+	}
+}
+
+// TestEndingCollection tests ending tiers and secret ending hiding
+func TestEndingCollection(t *testing.T) {
+	schema := createTestSchema()
+	schema.PlotNodes = append(schema.PlotNodes,
+		agents.PlotNodeDef{ID: "end_peace", PlotDescription: "Peace", IsEnding: true, EndingTier: "good"},
+		agents.PlotNodeDef{ID: "end_void", PlotDescription: "The void", IsEnding: true, EndingTier: "secret"},
+		agents.PlotNodeDef{ID: "end_ruin", PlotDescription: "Ruin", IsEnding: true},
+	)
+	engine, _ := NewGameEngine("test-game", schema)
+
+	if engine.GetState().WorldKey != WorldKey(schema) || WorldKey(schema) == "" {
+		t.Error("Expected world key to be derived from schema")
+	}
+
+	collection := engine.GetEndingCollection(map[string]bool{"end_peace": true})
+	if len(collection) != 3 {
+		t.Fatalf("Expected 3 endings, got %d", len(collection))
+	}
+	if collection[0].ID != "end_ruin" || collection[0].Tier != "common" {
+		t.Errorf("Expected common ending first, got %+v", collection[0])
+	}
+	if !collection[1].Unlocked {
+		t.Error("Expected end_peace to be unlocked")
+	}
+	if collection[2].Description != "" {
+		t.Error("Expected locked secret ending description to be hidden")
+	}
+}
+
+// TestDeterministicWorldEngine tests that procedural worlds build valid engines
+func TestDeterministicWorldEngine(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		engine, err := NewGameEngine("test-game", agents.BuildDeterministicWorld(seed, ""))
+		if err != nil {
+			t.Fatalf("Seed %d: %v", seed, err)
+		}
+		for i := 0; i < 8; i++ {
+			if err := engine.AdvanceWeek(); err != nil {
+				t.Fatalf("Seed %d: AdvanceWeek failed: %v", seed, err)
+			}
+		}
+	}
+}
+
+// TestMacroExpansion tests that world-defined macros expand into their calls
+func TestMacroExpansion(t *testing.T) {
+	schema := createTestSchema()
+	schema.Macros = []agents.MacroDef{
+		{
+			ID:          "host_feast",
+			Description: "Throw a feast",
+			Calls: []agents.FunctionCall{
+				{Name: "update_stat", Params: map[string]interface{}{"stat_id": "health", "delta": float64(-10)}},
+				{Name: "update_stat", Params: map[string]interface{}{"stat_id": "mana", "delta": float64(15)}},
+				{Name: "add_tag", Params: map[string]interface{}{"tag_id": "tag2"}},
+			},
 		},
-		NPCs: []agents.NPCDef{
-			{
-				EntityDef: agents.EntityDef{ID: "npc1", Name: "NPC 1"},
-				Description: "Test NPC",
-				Appearance: "A test NPC",
+		{
+			ID:          "royal_feast",
+			Description: "A feast fit for a king",
+			Calls: []agents.FunctionCall{
+				{Name: "host_feast"},
+				{Name: "update_stat", Params: map[string]interface{}{"stat_id": "mana", "delta": float64(5)}},
 			},
 		},
-		Relationships: []agents.RelationshipDef{
-			{From: "player", To: "npc1", Description: "Friendly"},
+	}
+
+	engine, err := NewGameEngine("test-game", schema)
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+
+	executor := cards.NewActionExecutor(engine.state)
+	result, err := executor.Execute(map[string]interface{}{"name": "royal_feast"})
+	if err != nil {
+		t.Fatalf("Failed to execute macro: %v", err)
+	}
+
+	if engine.state.Stats["health"] != 90 || engine.state.Stats["mana"] != 70 {
+		t.Errorf("Expected health 90 and mana 70, got %v", engine.state.Stats)
+	}
+	if !engine.state.Tags["tag2"] {
+		t.Error("Expected macro to add tag2")
+	}
+	if result.StatChanges["health"] != -10 || result.StatChanges["mana"] != 20 {
+		t.Errorf("Expected merged stat changes, got %v", result.StatChanges)
+	}
+
+	macros := engine.GetGenerationContext()["macros"].([]map[string]interface{})
+	if len(macros) != 2 || macros[0]["id"] != "host_feast" {
+		t.Errorf("Expected macros in generation context sorted by ID, got %v", macros)
+	}
+}
+
+// TestInvalidMacros tests that shadowing and recursive macros are rejected
+func TestInvalidMacros(t *testing.T) {
+	cases := map[string][]agents.MacroDef{
+		"shadow": {
+			{ID: "update_stat", Calls: []agents.FunctionCall{{Name: "add_tag", Params: map[string]interface{}{"tag_id": "tag2"}}}},
 		},
-		PlotNodes: []agents.PlotNodeDef{
-			{
-				ID:              "plot1",
-				PlotDescription: "Test plot",
-				Condition:       "true",
-				IsEnding:        false,
-				SuccessorIDs:    []string{},
-			},
+		"cycle": {
+			{ID: "a", Calls: []agents.FunctionCall{{Name: "b"}}},
+			{ID: "b", Calls: []agents.FunctionCall{{Name: "a"}}},
+		},
+		"empty": {
+			{ID: "noop"},
+		},
+	}
+
+	for name, macros := range cases {
+		schema := createTestSchema()
+		schema.Macros = macros
+		if _, err := NewGameEngine("test-game", schema); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// TestPressureRules tests that linked-stat rules apply as days advance
+func TestPressureRules(t *testing.T) {
+	schema := createTestSchema()
+	schema.InitialStats["health"] = 10
+	schema.PressureRules = []agents.PressureRuleDef{
+		{
+			ID:          "starvation",
+			Description: "Low health drains mana each week",
+			Condition:   "stats['health'] < 20",
+			Calls:       []agents.FunctionCall{{Name: "update_stat", Params: map[string]interface{}{"stat_id": "mana", "delta": float64(-5)}}},
+		},
+		{
+			ID:          "blessing",
+			Description: "The blessed heal a little every day",
+			Condition:   "'tag1' in tags",
+			Calls:       []agents.FunctionCall{{Name: "update_stat", Params: map[string]interface{}{"stat_id": "health", "delta": float64(1)}}},
+			Interval:    "day",
+		},
+	}
+
+	engine, err := NewGameEngine("test-game", schema)
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+
+	if err := engine.AdvanceWeek(); err != nil {
+		t.Fatalf("AdvanceWeek failed: %v", err)
+	}
+
+	if engine.state.Stats["health"] != 17 {
+		t.Errorf("Expected daily rule to raise health to 17, got %d", engine.state.Stats["health"])
+	}
+	if engine.state.Stats["mana"] != 45 {
+		t.Errorf("Expected weekly rule to drain mana to 45, got %d", engine.state.Stats["mana"])
+	}
+
+	rules := engine.buildSnapshot()["pressure_rules"].([]map[string]interface{})
+	if len(rules) != 2 || rules[0]["active"] != true {
+		t.Errorf("Expected both rules in snapshot with starvation active, got %v", rules)
+	}
+
+	schema.PressureRules[0].Interval = "hourly"
+	if _, err := NewGameEngine("test-game", schema); err == nil {
+		t.Error("Expected an invalid interval to be rejected")
+	}
+}
+
+// TestHiddenStats tests that hidden stats stay out of player views until revealed
+func TestHiddenStats(t *testing.T) {
+	schema := createTestSchema()
+	schema.Stats = append(schema.Stats, agents.StatDef{ID: "suspicion", Name: "Suspicion", Hidden: true})
+	schema.InitialStats["suspicion"] = 30
+
+	engine, err := NewGameEngine("test-game", schema)
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+
+	if _, ok := engine.GetPlayerState().Stats["suspicion"]; ok {
+		t.Error("Expected suspicion to be hidden from the player")
+	}
+	if engine.buildConditionState()["stats"].(map[string]int)["suspicion"] != 30 {
+		t.Error("Expected conditions to see hidden stats")
+	}
+	if hidden := engine.buildSnapshot()["hidden_stats"].([]string); len(hidden) != 1 || hidden[0] != "suspicion" {
+		t.Errorf("Expected Writer snapshot to list suspicion as hidden, got %v", hidden)
+	}
+
+	version := engine.GetVersion()
+	executor := cards.NewActionExecutor(engine.state)
+	engine.mu.Lock()
+	executor.Execute(map[string]interface{}{"name": "update_stat", "params": map[string]interface{}{"stat_id": "suspicion", "delta": float64(10)}})
+	engine.mu.Unlock()
+	if ops := engine.Diff(version).Ops; len(ops) != 0 {
+		t.Errorf("Expected hidden stat change to produce no ops, got %v", ops)
+	}
+
+	version = engine.GetVersion()
+	engine.mu.Lock()
+	_, err = executor.Execute(map[string]interface{}{"name": "reveal_stat", "params": map[string]interface{}{"stat_id": "suspicion"}})
+	engine.mu.Unlock()
+	if err != nil {
+		t.Fatalf("reveal_stat failed: %v", err)
+	}
+
+	if engine.GetPlayerState().Stats["suspicion"] != 40 {
+		t.Error("Expected revealed suspicion to be visible")
+	}
+	found := false
+	for _, op := range engine.Diff(version).Ops {
+		if op.Op == PatchOpAdd && op.Path == "/stats/suspicion" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected reveal to show up as an add op")
+	}
+}
+
+// heistCard returns a card whose left choice fails 30% of the time
+func heistCard(id string) *cards.ChoiceCard {
+	return &cards.ChoiceCard{
+		ID:    id,
+		Title: "The Heist",
+		LeftChoice: &cards.Choice{
+			Label: "Rob the vault",
+			Calls: []cards.FunctionCall{{
+				Name: "random_outcome",
+				Params: map[string]interface{}{"branches": []interface{}{
+					map[string]interface{}{"label": "caught", "weight": float64(30), "calls": []interface{}{
+						map[string]interface{}{"name": "add_tag", "params": map[string]interface{}{"tag_id": "wanted"}},
+					}},
+					map[string]interface{}{"label": "escaped", "weight": float64(70), "calls": []interface{}{
+						map[string]interface{}{"name": "update_stat", "params": map[string]interface{}{"stat_id": "mana", "delta": float64(5)}},
+					}},
+				}},
+			}},
 		},
-		InitialStats: map[string]int{
-			"health": 100,
-			"mana":   50,
+		RightChoice: &cards.Choice{Label: "Walk away"},
+	}
+}
+
+// TestRandomOutcome tests weighted branches, the seeded RNG and the roll log
+func TestRandomOutcome(t *testing.T) {
+	counts := map[string]int{}
+	for i := 0; i < 200; i++ {
+		engine, err := NewGameEngine("test-game", createTestSchema())
+		if err != nil {
+			t.Fatalf("Failed to create game engine: %v", err)
+		}
+		engine.state.RNGSeed = int64(i)
+		engine.drawnCards = append(engine.drawnCards, heistCard("heist"))
+
+		result, err := engine.ResolveCard("heist", "left")
+		if err != nil {
+			t.Fatalf("ResolveCard failed: %v", err)
+		}
+		if len(result.Rolls) != 1 {
+			t.Fatalf("Expected one roll, got %v", result.Rolls)
+		}
+		outcome := result.Rolls[0].Outcome
+		counts[outcome]++
+
+		switch outcome {
+		case "caught":
+			if !engine.state.HasTag("wanted") || result.StatChanges["mana"] != 0 {
+				t.Error("Expected caught branch to only add wanted")
+			}
+		case "escaped":
+			if engine.state.HasTag("wanted") || result.StatChanges["mana"] != 5 {
+				t.Error("Expected escaped branch to only raise mana")
+			}
+		default:
+			t.Fatalf("Unexpected outcome %q", outcome)
+		}
+
+		log := engine.state.RollLog
+		if len(log) != 1 || log[0].CardID != "heist" || log[0].Outcome != outcome {
+			t.Errorf("Expected roll log to record the heist, got %v", log)
+		}
+		if engine.GetPlayerState().RNGSeed != 0 {
+			t.Error("Expected RNG seed to be hidden from the player")
+		}
+	}
+	if counts["caught"] < 30 || counts["caught"] > 90 {
+		t.Errorf("Expected roughly 30%% caught, got %v", counts)
+	}
+
+	a := &GlobalBlackboard{RNGSeed: 42}
+	b := &GlobalBlackboard{RNGSeed: 42, RNGDraws: 1}
+	a.Roll()
+	if a.Roll() != b.Roll() {
+		t.Error("Expected rolls to continue the same sequence from the draw count")
+	}
+
+	engine, _ := NewGameEngine("test-game", createTestSchema())
+	executor := cards.NewActionExecutor(engine.state)
+	if _, err := executor.Execute(map[string]interface{}{
+		"name":   "random_outcome",
+		"params": map[string]interface{}{"branches": []interface{}{map[string]interface{}{"label": "x", "weight": float64(0)}}},
+	}); err == nil {
+		t.Error("Expected zero-weight branch to be rejected")
+	}
+}
+
+// TestSkillCheck tests that stats modify the roll and pick the branch
+func TestSkillCheck(t *testing.T) {
+	engine, err := NewGameEngine("test-game", createTestSchema())
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	executor := cards.NewActionExecutor(engine.state)
+
+	check := func(statID string, target float64) cards.Roll {
+		res, err := executor.Execute(map[string]interface{}{"name": "skill_check", "params": map[string]interface{}{
+			"stat_id": statID,
+			"target":  target,
+			"success": []interface{}{map[string]interface{}{"name": "add_tag", "params": map[string]interface{}{"tag_id": "passed"}}},
+			"failure": []interface{}{map[string]interface{}{"name": "update_stat", "params": map[string]interface{}{"stat_id": "mana", "delta": float64(-1)}}},
+		}})
+		if err != nil {
+			t.Fatalf("skill_check failed: %v", err)
+		}
+		if len(res.Rolls) != 1 {
+			t.Fatalf("Expected one roll, got %v", res.Rolls)
+		}
+		return res.Rolls[0]
+	}
+
+	// health 100 gives +5, so a target of 6 always passes
+	for i := 0; i < 50; i++ {
+		if roll := check("health", 6); roll.Outcome != "success" || roll.Total < 6 {
+			t.Fatalf("Expected success against 6 with +5, got %+v", roll)
+		}
+	}
+	if !engine.state.HasTag("passed") {
+		t.Error("Expected success calls to run")
+	}
+
+	// mana 0 gives -5, so a target of 16 always fails
+	engine.state.SetStat("mana", 0)
+	for i := 0; i < 50; i++ {
+		if roll := check("mana", 16); roll.Outcome != "failure" || roll.Target != 16 {
+			t.Fatalf("Expected failure against 16 with -5, got %+v", roll)
+		}
+	}
+
+	if _, err := executor.Execute(map[string]interface{}{"name": "skill_check", "params": map[string]interface{}{
+		"stat_id": "luck", "target": float64(10),
+	}}); err == nil {
+		t.Error("Expected unknown stat to be rejected")
+	}
+}
+
+// TestItems tests item counts through the executor, conditions, lint and
+// resurrection
+func TestItems(t *testing.T) {
+	schema := createTestSchema()
+	schema.Items = []agents.ItemDef{
+		{ID: "iron_key", Name: "Iron Key", Description: "Opens the old gate"},
+		{ID: "coin", Name: "Coin", Description: "Old silver"},
+	}
+	schema.InitialItems = map[string]int{"coin": 3}
+
+	engine, err := NewGameEngine("test-game", schema)
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	if engine.state.Items["coin"] != 3 {
+		t.Fatalf("Expected 3 starting coins, got %v", engine.state.Items)
+	}
+	executor := cards.NewActionExecutor(engine.state)
+	call := func(name string, params map[string]interface{}) *cards.ExecuteResult {
+		res, err := executor.Execute(map[string]interface{}{"name": name, "params": params})
+		if err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+		return res
+	}
+
+	if res := call("give_item", map[string]interface{}{"item_id": "iron_key"}); res.ItemChanges["iron_key"] != 1 {
+		t.Errorf("Expected iron_key +1, got %v", res.ItemChanges)
+	}
+	if res := call("remove_item", map[string]interface{}{"item_id": "coin", "count": float64(5)}); res.ItemChanges["coin"] != -3 {
+		t.Errorf("Expected removing 5 of 3 coins to take 3, got %v", res.ItemChanges)
+	}
+	if !reflect.DeepEqual(engine.state.Items, map[string]int{"iron_key": 1}) {
+		t.Errorf("Expected only the key to be held, got %v", engine.state.Items)
+	}
+
+	gate := func(itemID string) {
+		call("has_item", map[string]interface{}{
+			"item_id": itemID,
+			"then":    []interface{}{map[string]interface{}{"name": "add_tag", "params": map[string]interface{}{"tag_id": "gate_open"}}},
+			"else":    []interface{}{map[string]interface{}{"name": "update_stat", "params": map[string]interface{}{"stat_id": "mana", "delta": float64(-5)}}},
+		})
+	}
+	gate("coin")
+	if engine.state.HasTag("gate_open") || engine.state.Stats["mana"] != 45 {
+		t.Errorf("Expected the else calls without coins, got tags %v and stats %v", engine.state.Tags, engine.state.Stats)
+	}
+	gate("iron_key")
+	if !engine.state.HasTag("gate_open") {
+		t.Error("Expected the then calls with the key")
+	}
+
+	for name, params := range map[string]map[string]interface{}{
+		"give_item":   {"item_id": "crown"},
+		"remove_item": {"item_id": "coin", "count": float64(0)},
+		"has_item":    {"item_id": "coin", "count": float64(1.5)},
+	} {
+		if _, err := executor.Execute(map[string]interface{}{"name": name, "params": params}); err == nil {
+			t.Errorf("Expected %s %v to be rejected", name, params)
+		}
+	}
+
+	met, err := story.EvaluateExpression(`has_item("iron_key") && items.coin == 0 && !has_item("coin")`, engine.buildConditionState())
+	if err != nil || !met {
+		t.Errorf("Expected item condition to hold, got %v, %v", met, err)
+	}
+	deps, _ := story.ConditionDependencies(`has_item("iron_key") || items["coin"] > 1`)
+	if !reflect.DeepEqual(deps, []string{"items.coin", "items.iron_key"}) {
+		t.Errorf("Expected item dependencies, got %v", deps)
+	}
+
+	death.MechanicFor(death.MechanicHeir).Reset(&deathState{GlobalBlackboard: engine.state, engine: engine}, nil)
+	if engine.state.Items["iron_key"] != 1 {
+		t.Errorf("Expected an heir to keep the key, got %v", engine.state.Items)
+	}
+	death.MechanicFor(death.MechanicGhost).Reset(&deathState{GlobalBlackboard: engine.state, engine: engine}, nil)
+	if len(engine.state.Items) != 0 {
+		t.Errorf("Expected a ghost to hold no items, got %v", engine.state.Items)
+	}
+	if met, err := story.EvaluateExpression(`items.iron_key == 0 && !has_item("iron_key")`, engine.buildConditionState()); err != nil || !met {
+		t.Errorf("Expected no items to read as 0, got %v, %v", met, err)
+	}
+
+	schema.InitialItems = map[string]int{"crown": 1}
+	if _, err := NewGameEngine("test-game", schema); err == nil {
+		t.Error("Expected unknown initial item to be rejected")
+	}
+	schema.InitialItems = nil
+	schema.PlotNodes = append(schema.PlotNodes, agents.PlotNodeDef{ID: "crowned", Condition: `has_item("crown")`, IsEnding: true})
+	found := false
+	for _, w := range LintWorld(schema) {
+		if w.Code == LintUnknownState && w.Path == "items.crown" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected unknown item to be linted, got %+v", LintWorld(schema))
+	}
+}
+
+// TestResources tests resource balances, their calls and conditions, and
+// death by bankruptcy
+func TestResources(t *testing.T) {
+	schema := createTestSchema()
+	schema.InitialStats["health"] = 60 // 100 would be a death of its own
+	schema.Resources = []agents.ResourceDef{
+		{ID: "gold", Name: "Gold", Description: "Coin in the purse", Initial: 20, Bankruptcy: true},
+		{ID: "supplies", Name: "Supplies", Description: "Food and rope"},
+	}
+
+	engine, err := NewGameEngine("test-game", schema)
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	if !reflect.DeepEqual(engine.state.Resources, map[string]int{"gold": 20, "supplies": 0}) {
+		t.Fatalf("Expected starting balances, got %v", engine.state.Resources)
+	}
+	executor := cards.NewActionExecutor(engine.state)
+	call := func(name string, params map[string]interface{}) *cards.ExecuteResult {
+		res, err := executor.Execute(map[string]interface{}{"name": name, "params": params})
+		if err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+		return res
+	}
+
+	if res := call("add_resource", map[string]interface{}{"resource_id": "gold", "amount": float64(30)}); res.ResourceChanges["gold"] != 30 {
+		t.Errorf("Expected gold +30, got %v", res.ResourceChanges)
+	}
+	if res := call("spend_resource", map[string]interface{}{"resource_id": "supplies", "amount": float64(5)}); len(res.ResourceChanges) != 0 || engine.state.Resources["supplies"] != 0 {
+		t.Errorf("Expected overspending supplies to stop at zero, got %v and %v", res.ResourceChanges, engine.state.Resources)
+	}
+	for name, params := range map[string]map[string]interface{}{
+		"add_resource":   {"resource_id": "silver", "amount": float64(1)},
+		"spend_resource": {"resource_id": "gold", "amount": float64(2.5)},
+	} {
+		if _, err := executor.Execute(map[string]interface{}{"name": name, "params": params}); err == nil {
+			t.Errorf("Expected %s %v to be rejected", name, params)
+		}
+	}
+
+	met, err := story.EvaluateExpression(`resources.gold >= 50 && resources["supplies"] == 0`, engine.buildConditionState())
+	if err != nil || !met {
+		t.Errorf("Expected resource condition to hold, got %v, %v", met, err)
+	}
+	if _, isDead := engine.CheckDeath(); isDead {
+		t.Fatal("Expected no death while solvent")
+	}
+
+	// Overspending gold is fatal, and the heir starts clear of the debt
+	call("spend_resource", map[string]interface{}{"resource_id": "gold", "amount": float64(80)})
+	deathInfo, isDead := engine.CheckDeath()
+	if !isDead || deathInfo.CauseStat != "gold" || engine.state.DeathCause != "gold" {
+		t.Fatalf("Expected death by bankruptcy in gold, got %+v", deathInfo)
+	}
+	state := &deathState{GlobalBlackboard: engine.state, engine: engine}
+	death.MechanicFor(death.MechanicHeir).Reset(state, nil)
+	engine.state.BeginLife(map[string]bool{})
+	if engine.state.Resources["gold"] != 0 {
+		t.Errorf("Expected the heir's debt written off, got %v", engine.state.Resources)
+	}
+	call("add_resource", map[string]interface{}{"resource_id": "supplies", "amount": float64(4)})
+	death.MechanicFor(death.MechanicReincarnation).Reset(state, nil)
+	if !reflect.DeepEqual(engine.state.Resources, map[string]int{"gold": 20, "supplies": 0}) {
+		t.Errorf("Expected reincarnation to restore starting balances, got %v", engine.state.Resources)
+	}
+
+	schema.Resources = append(schema.Resources, agents.ResourceDef{ID: "mana", Name: "Mana"})
+	if _, err := NewGameEngine("test-game", schema); err == nil {
+		t.Error("Expected a resource sharing a stat's ID to be rejected")
+	}
+	schema.Resources = schema.Resources[:2]
+	schema.PlotNodes = append(schema.PlotNodes, agents.PlotNodeDef{ID: "tycoon", Condition: "resources.silver > 10", IsEnding: true})
+	found := false
+	for _, w := range LintWorld(schema) {
+		if w.Code == LintUnknownState && w.Path == "resources.silver" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected unknown resource to be linted, got %+v", LintWorld(schema))
+	}
+}
+
+// TestNPCAffinity tests affinity calls and conditions, and that an NPC
+// driven to very low affinity leaves for good
+func TestNPCAffinity(t *testing.T) {
+	schema := createTestSchema()
+	schema.NPCs[0].Affinity = 20
+
+	engine, err := NewGameEngine("test-game", schema)
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	if affinity, ok := engine.state.NPCAffinity("npc1"); !ok || affinity != 20 {
+		t.Fatalf("Expected starting affinity 20, got %d, %v", affinity, ok)
+	}
+	executor := cards.NewActionExecutor(engine.state)
+
+	res, err := executor.Execute(map[string]interface{}{"name": "update_affinity", "params": map[string]interface{}{"npc_id": "npc1", "delta": float64(-50)}})
+	if err != nil || res.AffinityChanges["npc1"] != -50 {
+		t.Fatalf("Expected affinity -50, got %v, %v", res, err)
+	}
+	for _, params := range []map[string]interface{}{
+		{"npc_id": "stranger", "delta": float64(5)},
+		{"npc_id": "npc1", "delta": float64(51)},
+		{"npc_id": "npc1", "delta": float64(2.5)},
+		{"npc_id": "npc1"},
+	} {
+		if _, err := executor.Execute(map[string]interface{}{"name": "update_affinity", "params": params}); err == nil {
+			t.Errorf("Expected update_affinity %v to be rejected", params)
+		}
+	}
+
+	met, err := story.EvaluateExpression(`affinity.npc1 == -30 && affinity["npc1"] < 0`, engine.buildConditionState())
+	if err != nil || !met {
+		t.Errorf("Expected affinity condition to hold, got %v, %v", met, err)
+	}
+	if npc := engine.state.GetNPC("npc1"); !npc.Enabled || npc.Departed {
+		t.Fatalf("Expected npc1 to stay above the departure threshold, got %+v", npc)
+	}
+
+	// A choice that drives the NPC away queues a farewell
+	engine.jobQueue.Drain()
+	added := engine.AddCardsFromDefs([]map[string]interface{}{{
+		"id":    "insult",
+		"title": "The Insult",
+		"left_choice": map[string]interface{}{
+			"label": "Mock them",
+			"calls": []interface{}{map[string]interface{}{"name": "update_affinity", "params": map[string]interface{}{"npc_id": "npc1", "delta": float64(-50)}}},
 		},
-		InitialTags: []string{"tag1"},
+		"right_choice": map[string]interface{}{"label": "Apologise"},
+	}})
+	if added != 1 {
+		t.Fatalf("Expected the card to be added, got %d", added)
+	}
+	if _, err := engine.DrawCards(1); err != nil {
+		t.Fatalf("DrawCards failed: %v", err)
+	}
+	result, err := engine.ResolveCard("insult", "left")
+	if err != nil {
+		t.Fatalf("ResolveCard failed: %v", err)
+	}
+	if result.AffinityChanges["npc1"] != -50 {
+		t.Errorf("Expected the resolve result to report affinity -50, got %v", result.AffinityChanges)
+	}
+	npc := engine.state.GetNPC("npc1")
+	if npc.Affinity != -80 || !npc.Departed || npc.Enabled {
+		t.Fatalf("Expected npc1 to depart at -80, got %+v", npc)
+	}
+	departures := 0
+	for _, job := range engine.PendingJobs() {
+		if job.JobType == "departure" && job.Context["npc_id"] == "npc1" {
+			departures++
+		}
+	}
+	if departures != 1 {
+		t.Errorf("Expected one departure job, got %d", departures)
+	}
+
+	// Departed NPCs stay gone and affinity stops at -100
+	engine.state.EnableNPC("npc1")
+	engine.state.UpdateAffinity("npc1", -50)
+	if npc := engine.state.GetNPC("npc1"); npc.Enabled || npc.Affinity != -100 {
+		t.Errorf("Expected npc1 to stay gone at -100, got %+v", npc)
+	}
+
+	// Calls outside card choices, here a plot node's, queue farewells too
+	engine, err = NewGameEngine("test-game", schema)
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	snub := agents.FunctionCall{Name: "update_affinity", Params: map[string]interface{}{"npc_id": "npc1", "delta": float64(-50)}}
+	engine.dag.GetNode("plot1").Calls = []agents.FunctionCall{snub, snub}
+	engine.jobQueue.Drain()
+	engine.mu.Lock()
+	engine.state.PendingPlotNodeID = "plot1"
+	engine.mu.Unlock()
+	if err := engine.FirePendingPlot(); err != nil {
+		t.Fatalf("Failed to fire plot1: %v", err)
+	}
+	departures = 0
+	for _, job := range engine.PendingJobs() {
+		if job.JobType == "departure" && job.Context["npc_id"] == "npc1" {
+			departures++
+		}
+	}
+	if departures != 1 {
+		t.Errorf("Expected the plot node to queue one departure job, got %d", departures)
+	}
+
+	schema.NPCs[0].Affinity = 120
+	if err := agents.ValidateWorld(schema); err == nil {
+		t.Error("Expected affinity out of range to be rejected")
+	}
+	schema.NPCs[0].Affinity = 0
+	schema.PlotNodes = append(schema.PlotNodes, agents.PlotNodeDef{ID: "beloved", Condition: "affinity.ghost > 50", IsEnding: true})
+	found := false
+	for _, w := range LintWorld(schema) {
+		if w.Code == LintUnknownState && w.Path == "affinity.ghost" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected unknown NPC to be linted, got %+v", LintWorld(schema))
+	}
+}
+
+// TestChoiceRequires tests that choice requirements are enforced on resolution
+func TestChoiceRequires(t *testing.T) {
+	engine, err := NewGameEngine("test-game", createTestSchema())
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+
+	added := engine.AddCardsFromDefs([]map[string]interface{}{
+		{
+			"id":    "bribe",
+			"title": "The Guard",
+			"left_choice": map[string]interface{}{
+				"label":    "Bribe him",
+				"requires": "stats['mana'] >= 30",
+				"calls":    []interface{}{map[string]interface{}{"name": "update_stat", "params": map[string]interface{}{"stat_id": "mana", "delta": float64(-30)}}},
+			},
+			"right_choice": map[string]interface{}{"label": "Leave"},
+		},
+		{
+			"id":           "broken",
+			"title":        "Broken",
+			"left_choice":  map[string]interface{}{"label": "?", "requires": "stats['mana'] >="},
+			"right_choice": map[string]interface{}{"label": "?"},
+		},
+	})
+	if added != 1 {
+		t.Fatalf("Expected only the valid card to be added, got %d", added)
+	}
+
+	drawn, _ := engine.DrawCards(1)
+	if len(drawn) != 1 || drawn[0].GetID() != "bribe" {
+		t.Fatalf("Expected to draw the bribe card, got %v", drawn)
+	}
+	if _, err := engine.ResolveCard("bribe", "left"); err != nil {
+		t.Fatalf("Expected bribe with 50 mana to succeed: %v", err)
+	}
+
+	engine.AddCardsFromDefs([]map[string]interface{}{{
+		"id":           "bribe2",
+		"title":        "The Guard Again",
+		"left_choice":  map[string]interface{}{"label": "Bribe him", "requires": "stats['mana'] >= 30"},
+		"right_choice": map[string]interface{}{"label": "Leave"},
+	}})
+	engine.DrawCards(1)
+
+	_, err = engine.ResolveCard("bribe2", "left")
+	var reqErr *RequirementError
+	if !errors.As(err, &reqErr) || reqErr.Requires != "stats['mana'] >= 30" {
+		t.Fatalf("Expected requirement error with 20 mana, got %v", err)
+	}
+	if _, err := engine.ResolveCard("bribe2", "right"); err != nil {
+		t.Errorf("Expected the other choice to stay available: %v", err)
+	}
+}
+
+// TestSeasonMultipliers tests that seasons scale stat gains and report it
+func TestSeasonMultipliers(t *testing.T) {
+	schema := createTestSchema()
+	schema.Seasons[0].StatMultipliers = map[string]float64{"mana": 2}
+	schema.Seasons[0].Name = "Harvest"
+
+	engine, err := NewGameEngine("test-game", schema)
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	executor := cards.NewActionExecutor(engine.state)
+
+	res, err := executor.ExecuteMultiple([]map[string]interface{}{
+		{"name": "update_stat", "params": map[string]interface{}{"stat_id": "mana", "delta": float64(5)}},
+		{"name": "update_stat", "params": map[string]interface{}{"stat_id": "mana", "delta": float64(5)}},
+		{"name": "update_stat", "params": map[string]interface{}{"stat_id": "mana", "delta": float64(-4)}},
+	})
+	if err != nil {
+		t.Fatalf("ExecuteMultiple failed: %v", err)
+	}
+	if res.StatChanges["mana"] != 16 || engine.state.GetStat("mana") != 66 {
+		t.Errorf("Expected gains doubled and losses untouched, got %v", res.StatChanges)
+	}
+	if len(res.Modifiers) != 1 || res.Modifiers[0].Multiplier != 2 || res.Modifiers[0].Source != "Harvest" {
+		t.Errorf("Expected one Harvest ×2 modifier, got %+v", res.Modifiers)
+	}
+
+	// Multipliers survive a save and load
+	data, _ := json.Marshal(engine.state)
+	var loaded GlobalBlackboard
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("Failed to unmarshal state: %v", err)
+	}
+	if mult, _ := loaded.StatMultiplier("mana"); mult != 2 {
+		t.Errorf("Expected multiplier 2 after reload, got %v", mult)
+	}
+
+	engine.state.AdvanceToNextSeason()
+	res, _ = executor.Execute(map[string]interface{}{"name": "update_stat", "params": map[string]interface{}{"stat_id": "mana", "delta": float64(5)}})
+	if res.StatChanges["mana"] != 5 || len(res.Modifiers) != 0 {
+		t.Errorf("Expected no multiplier in the next season, got %v %+v", res.StatChanges, res.Modifiers)
+	}
+
+	schema.Seasons[0].StatMultipliers = map[string]float64{"gold": 2}
+	if _, err := NewGameEngine("test-game", schema); err == nil {
+		t.Error("Expected multiplier for unknown stat to be rejected")
+	}
+}
+
+// TestEraTransition tests that eras advance, swap NPCs and queue a Writer job
+func TestEraTransition(t *testing.T) {
+	schema := createTestSchema()
+	schema.NPCs = append(schema.NPCs, agents.NPCDef{EntityDef: agents.EntityDef{ID: "heir", Name: "Heir"}})
+	schema.Eras = []agents.EraDef{
+		{ID: "founding", Name: "Age of Founding", Description: "Wooden walls"},
+		{ID: "empire", Name: "Age of Empire", Description: "Marble and roads", Condition: "elapsed_days >= 3", RetireNPCs: []string{"npc1"}, IntroduceNPCs: []string{"heir"}},
+	}
+
+	engine, err := NewGameEngine("test-game", schema)
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	if engine.state.Era != "Age of Founding" || engine.state.NPCs["heir"].Enabled {
+		t.Fatalf("Expected first era with heir offstage, got %s", engine.state.Era)
+	}
+
+	engine.mu.Lock()
+	for i := 0; i < 3; i++ {
+		engine.advanceDay()
+	}
+	engine.mu.Unlock()
+
+	if engine.state.Era != "Age of Empire" || engine.state.EraIndex != 1 {
+		t.Errorf("Expected Age of Empire, got %s", engine.state.Era)
+	}
+	if engine.state.NPCs["npc1"].Enabled || !engine.state.NPCs["heir"].Enabled {
+		t.Error("Expected npc1 to retire and heir to join")
+	}
+	if info := engine.buildSnapshot()["era_info"].(map[string]interface{}); info["description"] != "Marble and roads" {
+		t.Errorf("Expected Writer to see the new era, got %v", info)
+	}
+
+	found := false
+	for _, job := range engine.jobQueue.Peek() {
+		if job.JobType == "era" && job.Context["era_id"] == "empire" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected an era job to be queued")
+	}
+
+	schema.Eras[1].Condition = ""
+	if _, err := NewGameEngine("test-game", schema); err == nil {
+		t.Error("Expected era without transition condition to be rejected")
+	}
+}
+
+// TestNPCAging tests yearly aging, natural deaths and successor NPCs
+func TestNPCAging(t *testing.T) {
+	schema := createTestSchema()
+	schema.NPCs[0].Age = 79
+	schema.NPCs = append(schema.NPCs, agents.NPCDef{EntityDef: agents.EntityDef{ID: "child", Name: "Child"}, Age: 8})
+	schema.MortalityRules = []agents.MortalityRuleDef{{MinAge: 80, Chance: 1}, {MinAge: 40, Chance: 0.01}}
+
+	engine, err := NewGameEngine("test-game", schema)
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+
+	// Step over the turn of the year
+	engine.mu.Lock()
+	engine.state.Day = 28
+	engine.state.Season = 3
+	engine.advanceDay()
+	engine.mu.Unlock()
+
+	npc := engine.state.NPCs["npc1"]
+	if npc.Age != 80 || !npc.Deceased || npc.Enabled {
+		t.Fatalf("Expected npc1 to die at 80, got %+v", npc)
+	}
+	if child := engine.state.NPCs["child"]; child.Age != 9 || child.Deceased {
+		t.Errorf("Expected child to age to 9, got %+v", child)
+	}
+
+	jobs := map[string]bool{}
+	for _, job := range engine.jobQueue.Peek() {
+		jobs[job.JobType] = true
+	}
+	if !jobs["memorial"] || !jobs["successor"] {
+		t.Errorf("Expected memorial and successor jobs, got %v", jobs)
+	}
+
+	engine.state.EnableNPC("npc1")
+	if engine.state.NPCs["npc1"].Enabled {
+		t.Error("Expected deceased NPC to stay disabled")
+	}
+
+	engine.AddCardsFromDefs([]map[string]interface{}{{
+		"id":      "successor_npc1",
+		"title":   "A New Smith",
+		"new_npc": map[string]interface{}{"id": "npc1_heir", "name": "Heir", "age": float64(20)},
+	}})
+	if heir, ok := engine.state.NPCs["npc1_heir"]; !ok || !heir.Enabled || heir.Age != 20 {
+		t.Errorf("Expected successor NPC to join the cast, got %+v", heir)
+	}
+
+	schema.MortalityRules = []agents.MortalityRuleDef{{MinAge: 60, Chance: 2}}
+	if _, err := NewGameEngine("test-game", schema); err == nil {
+		t.Error("Expected chance above 1 to be rejected")
+	}
+}
+
+// TestHeirResurrection tests that dynasty mode skips years and inherits stats
+func TestHeirResurrection(t *testing.T) {
+	schema := createTestSchema()
+	schema.ResurrectionMechanic = death.MechanicHeir
+	schema.Dynasty = &agents.DynastyDef{YearsLater: 25, Inheritance: 0.5}
+	schema.NPCs[0].Age = 60
+	schema.MortalityRules = []agents.MortalityRuleDef{{MinAge: 70, Chance: 1}}
+
+	engine, err := NewGameEngine("test-game", schema)
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	engine.state.Stats["health"] = 0
+	engine.state.Stats["mana"] = 90
+	engine.state.AddTag("wounded")
+	engine.state.IsAlive = false
+
+	if err := engine.Resurrect(map[string]bool{"wounded": true}, ""); err != nil {
+		t.Fatalf("Resurrect failed: %v", err)
+	}
+
+	if engine.state.Year != 25 || engine.state.Day != 1 {
+		t.Errorf("Expected the calendar to jump 25 years, got year %d day %d", engine.state.Year, engine.state.Day)
+	}
+	if engine.state.Stats["health"] != 25 || engine.state.Stats["mana"] != 70 {
+		t.Errorf("Expected half of each stat's distance from 50, got %v", engine.state.Stats)
+	}
+	if !engine.state.IsAlive || engine.state.LifeNumber != 2 {
+		t.Error("Expected the heir to start the second life")
+	}
+	if engine.state.HasTag("wounded") {
+		t.Error("Expected temp tags not to pass to the heir")
+	}
+	if npc := engine.state.NPCs["npc1"]; !npc.Deceased || npc.Age != 70 {
+		t.Errorf("Expected npc1 to die of old age during the gap, got %+v", npc)
+	}
+	if len(engine.state.Relationships) != 0 {
+		t.Errorf("Expected the bond with npc1 to end, got %v", engine.state.Relationships)
+	}
+
+	schema.Dynasty.Inheritance = 2
+	if _, err := NewGameEngine("test-game", schema); err == nil {
+		t.Error("Expected inheritance above 1 to be rejected")
+	}
+}
+
+// TestResurrectionMechanics tests each mechanic's state-reset rules
+func TestResurrectionMechanics(t *testing.T) {
+	tests := []struct {
+		mechanic   string
+		wantSeason int
+		wantDay    int
+		wantNPC    bool
+		wantHealth int
+	}{
+		{death.MechanicReincarnation, 2, 1, false, 50},
+		{death.MechanicTimeLoop, 1, 1, true, 100}, // back to the loop's start
+		{death.MechanicGhost, 1, 10, true, 50},
+		{"The river returns you", 2, 1, false, 50}, // free text falls back to reincarnation
+	}
+
+	for _, tt := range tests {
+		schema := createTestSchema()
+		schema.ResurrectionMechanic = tt.mechanic
+		engine, err := NewGameEngine("test-game", schema)
+		if err != nil {
+			t.Fatalf("Failed to create game engine: %v", err)
+		}
+		engine.state.Season = 1
+		engine.state.Day = 10
+		engine.state.Stats["health"] = 0
+		engine.state.IsAlive = false
+
+		if err := engine.CompleteResurrection(); err != nil {
+			t.Fatalf("%s: CompleteResurrection failed: %v", tt.mechanic, err)
+		}
+
+		s := engine.state
+		if s.Season != tt.wantSeason || s.Day != tt.wantDay {
+			t.Errorf("%s: expected season %d day %d, got season %d day %d", tt.mechanic, tt.wantSeason, tt.wantDay, s.Season, s.Day)
+		}
+		if s.NPCs["npc1"].Enabled != tt.wantNPC {
+			t.Errorf("%s: expected npc1 enabled=%v", tt.mechanic, tt.wantNPC)
+		}
+		if s.Stats["health"] != tt.wantHealth || !s.IsAlive || s.LifeNumber != 2 || !s.IsFirstDayAfterDeath {
+			t.Errorf("%s: expected a fresh second life, got health %d life %d", tt.mechanic, s.Stats["health"], s.LifeNumber)
+		}
+		if prompt := engine.buildSnapshot()["resurrection"].(map[string]interface{})["reborn_prompt"]; prompt == "" {
+			t.Errorf("%s: expected a reborn prompt for the Writer", tt.mechanic)
+		}
+	}
+}
+
+// TestTimeLoopKnowledge tests that loops rewind the world but keep knowledge
+// tags, which unlock new choices on repeated cards
+func TestTimeLoopKnowledge(t *testing.T) {
+	schema := createTestSchema()
+	schema.ResurrectionMechanic = death.MechanicTimeLoop
+	schema.Tags = append(schema.Tags,
+		agents.TagDef{ID: "knows_password", Name: "Knows the Password", Knowledge: true},
+		agents.TagDef{ID: "drunk", Name: "Drunk"},
+	)
+
+	engine, err := NewGameEngine("test-game", schema)
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+
+	gateCard := map[string]interface{}{
+		"id":    "gate",
+		"title": "The Gate",
+		"left_choice": map[string]interface{}{
+			"label": "Knock",
+			"unlocked": map[string]interface{}{
+				"label":    "Whisper the password",
+				"requires": "'knows_password' in tags",
+			},
+		},
+		"right_choice": map[string]interface{}{"label": "Leave"},
+	}
+
+	engine.AddCardsFromDefs([]map[string]interface{}{gateCard})
+	drawn, _ := engine.DrawCards(1)
+	if label := drawn[0].(*cards.ChoiceCard).LeftChoice.Label; label != "Knock" {
+		t.Fatalf("Expected the locked choice in the first loop, got %q", label)
+	}
+
+	engine.state.AddTag("knows_password")
+	engine.state.AddTag("drunk")
+	engine.state.Stats["mana"] = 0
+	engine.state.Day = 20
+	if err := engine.Resurrect(map[string]bool{}, ""); err != nil {
+		t.Fatalf("Resurrect failed: %v", err)
+	}
+
+	if engine.state.LoopCount != 1 || engine.state.Day != 1 || engine.state.Season != 0 {
+		t.Errorf("Expected loop 1 on day 1 of the same season, got loop %d day %d", engine.state.LoopCount, engine.state.Day)
+	}
+	if engine.state.Stats["mana"] != 50 {
+		t.Errorf("Expected mana back at its loop-start value, got %d", engine.state.Stats["mana"])
+	}
+	if !engine.state.HasTag("knows_password") || engine.state.HasTag("drunk") {
+		t.Errorf("Expected only knowledge to survive the loop, got %v", engine.state.Tags)
+	}
+
+	engine.AddCardsFromDefs([]map[string]interface{}{gateCard})
+	drawn, _ = engine.DrawCards(1)
+	if label := drawn[0].(*cards.ChoiceCard).LeftChoice.Label; label != "Whisper the password" {
+		t.Errorf("Expected the password to unlock a new choice, got %q", label)
+	}
+	if loop := engine.buildConditionState()["loop"]; loop != 1 {
+		t.Errorf("Expected conditions to see loop 1, got %v", loop)
+	}
+}
+
+// TestBetweenLivesInterlude tests that the interlude's meta-choice waits for
+// the next life and shapes how it starts
+func TestBetweenLivesInterlude(t *testing.T) {
+	engine, err := NewGameEngine("test-game", createTestSchema())
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	engine.state.Stats["health"] = 0
+
+	info, isDead := engine.CheckDeath()
+	if !isDead {
+		t.Fatal("Expected the player to be dead")
+	}
+	if err := engine.HandleDeath(info); err != nil {
+		t.Fatalf("HandleDeath failed: %v", err)
+	}
+	if !engine.InInterlude() {
+		t.Fatal("Expected an interlude after death")
+	}
+	var interludeJob *CardGenJob
+	for _, job := range engine.PendingJobs() {
+		if job.JobType == "interlude" {
+			interludeJob = job
+		}
+	}
+	if interludeJob == nil || interludeJob.Context["cause_stat"] != "health" {
+		t.Fatalf("Expected an interlude job for health, got %+v", interludeJob)
+	}
+
+	added := engine.AddCardsFromDefs([]map[string]interface{}{
+		{"id": "interlude_river", "title": "The River", "source": "interlude"},
+		{
+			"id":     "interlude_ferryman",
+			"title":  "The Ferryman",
+			"source": "interlude",
+			"left_choice": map[string]interface{}{
+				"label": "Drink from the river",
+				"calls": []interface{}{map[string]interface{}{"name": "update_stat", "params": map[string]interface{}{"stat_id": "health", "delta": float64(20)}}},
+			},
+			"right_choice": map[string]interface{}{
+				"label": "Keep your memories",
+				"calls": []interface{}{map[string]interface{}{"name": "add_tag", "params": map[string]interface{}{"tag_id": "tag2"}}},
+			},
+		},
+		{
+			"id":     "interlude_bad",
+			"title":  "Bad",
+			"source": "interlude",
+			"left_choice": map[string]interface{}{
+				"label": "Summon",
+				"calls": []interface{}{map[string]interface{}{"name": "enable_npc", "params": map[string]interface{}{"npc_id": "npc1"}}},
+			},
+		},
+	})
+	if added != 2 || engine.deck.Size() != 0 {
+		t.Fatalf("Expected 2 interlude cards outside the deck, got %d (deck %d)", added, engine.deck.Size())
+	}
+
+	drawn, err := engine.DrawInterlude()
+	if err != nil || len(drawn) != 2 {
+		t.Fatalf("Expected 2 interlude cards, got %d (%v)", len(drawn), err)
+	}
+	if _, err := engine.ResolveCard("interlude_river", "left"); err != nil {
+		t.Fatalf("Failed to resolve info card: %v", err)
+	}
+	if _, err := engine.ResolveCard("interlude_ferryman", "left"); err != nil {
+		t.Fatalf("Failed to resolve meta-choice: %v", err)
+	}
+	if engine.state.Stats["health"] != 0 {
+		t.Errorf("Expected the meta-choice to wait for the next life, health is %d", engine.state.Stats["health"])
+	}
+
+	if err := engine.CompleteResurrection(); err != nil {
+		t.Fatalf("CompleteResurrection failed: %v", err)
+	}
+	if engine.InInterlude() {
+		t.Error("Expected the interlude to end with resurrection")
+	}
+	if engine.state.Stats["health"] != 70 {
+		t.Errorf("Expected the next life to start with health 70, got %d", engine.state.Stats["health"])
+	}
+	if _, err := engine.DrawInterlude(); err == nil {
+		t.Error("Expected no interlude to draw after resurrection")
+	}
+}
+
+// TestStartingLoadouts tests that the reborn card offers two archetypes from
+// the schema and the resurrect swipe starts the next life with one
+func TestStartingLoadouts(t *testing.T) {
+	engine, err := NewGameEngine("test-game", createTestSchema())
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	// tag2 is temporary, so it never becomes an archetype
+	if got := len(engine.state.LoadoutPool); got != 3 {
+		t.Fatalf("Expected 3 loadouts (health, mana, tag1), got %d", got)
+	}
+	if err := engine.Resurrect(map[string]bool{}, "left"); err == nil {
+		t.Error("Expected an error picking a loadout that was never offered")
+	}
+
+	engine.state.Stats["mana"] = 0
+	info, _ := engine.CheckDeath()
+	if err := engine.HandleDeath(info); err != nil {
+		t.Fatalf("HandleDeath failed: %v", err)
+	}
+	offered := engine.state.Loadouts
+	if len(offered) != 2 || offered[0].ID == offered[1].ID {
+		t.Fatalf("Expected 2 distinct loadouts, got %+v", offered)
+	}
+
+	engine.DrawCard() // death card
+	reborn, ok := engine.DrawCard().(*cards.ChoiceCard)
+	if !ok || reborn.LeftChoice.Label != offered[0].Name || reborn.RightChoice.Label != offered[1].Name {
+		t.Fatalf("Expected a reborn card offering the loadouts, got %+v", reborn)
+	}
+
+	picked := offered[1]
+	if err := engine.Resurrect(map[string]bool{}, "right"); err != nil {
+		t.Fatalf("Resurrect failed: %v", err)
+	}
+	for id, delta := range picked.Stats {
+		if got := engine.state.Stats[id]; got != 50+delta {
+			t.Errorf("Expected %s to start at %d, got %d", id, 50+delta, got)
+		}
+	}
+	for _, id := range picked.Tags {
+		if !engine.state.Tags[id] {
+			t.Errorf("Expected the new life to start with tag %s", id)
+		}
+	}
+	if len(engine.state.Loadouts) != 0 {
+		t.Error("Expected the offer to close once a life begins")
+	}
+}
+
+// TestObituary tests that a death queues an obituary from the life's choices
+// and the Writer's obituary follows the death card
+func TestObituary(t *testing.T) {
+	engine, err := NewGameEngine("test-game", createTestSchema())
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	engine.AddCardsFromDefs([]map[string]interface{}{{
+		"id":    "duel",
+		"title": "The Duel",
+		"left_choice": map[string]interface{}{
+			"label": "Fight",
+			"calls": []interface{}{map[string]interface{}{"name": "update_stat", "params": map[string]interface{}{"stat_id": "health", "delta": float64(-50)}}},
+		},
+		"right_choice": map[string]interface{}{"label": "Flee"},
+	}})
+	engine.state.Stats["health"] = 40
+	if _, err := engine.DrawCards(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.ResolveCard("duel", "left"); err != nil {
+		t.Fatalf("Failed to resolve card: %v", err)
+	}
+
+	info, isDead := engine.CheckDeath()
+	if !isDead {
+		t.Fatal("Expected the player to be dead")
+	}
+	if err := engine.HandleDeath(info); err != nil {
+		t.Fatalf("HandleDeath failed: %v", err)
+	}
+
+	var job *CardGenJob
+	for _, j := range engine.PendingJobs() {
+		if j.JobType == "obituary" {
+			job = j
+		}
+	}
+	if job == nil {
+		t.Fatal("Expected an obituary job")
+	}
+	choices := job.Context["choices"].([]map[string]interface{})
+	if len(choices) != 1 || choices[0]["card"] != "The Duel" || choices[0]["choice"] != "Fight" {
+		t.Errorf("Expected the duel in the life's choices, got %v", choices)
+	}
+
+	added := engine.AddCardsFromDefs([]map[string]interface{}{
+		{"id": "obituary_1", "title": "Here Lies Player", "description": "They fought when they should have fled.", "source": "obituary"},
+	})
+	if added != 1 || engine.state.LastDeath.Obituary != "They fought when they should have fled." {
+		t.Fatalf("Expected the obituary stored with the death, got %+v", engine.state.LastDeath)
+	}
+	deathCard, ok := engine.DrawCard().(*cards.InfoCard)
+	if !ok || len(deathCard.NextCards) != 1 || deathCard.NextCards[0].GetID() != "obituary_1" {
+		t.Errorf("Expected the obituary to follow the death card, got %+v", deathCard)
+	}
+
+	if err := engine.Resurrect(map[string]bool{}, ""); err != nil {
+		t.Fatalf("Resurrect failed: %v", err)
+	}
+	if len(engine.state.LifeLog) != 0 {
+		t.Error("Expected a new life to start with an empty life log")
+	}
+}
+
+// TestResolveBatch tests that queued resolutions apply in order, all or nothing
+func TestResolveBatch(t *testing.T) {
+	engine, err := NewGameEngine("test-game", createTestSchema())
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	defs := make([]map[string]interface{}, 0, 3)
+	for _, id := range []string{"first", "second", "third"} {
+		defs = append(defs, map[string]interface{}{
+			"id":    id,
+			"title": id,
+			"left_choice": map[string]interface{}{
+				"label": "Rest",
+				"calls": []interface{}{map[string]interface{}{"name": "update_stat", "params": map[string]interface{}{"stat_id": "health", "delta": float64(-5)}}},
+			},
+			"right_choice": map[string]interface{}{"label": "Wait"},
+		})
+	}
+	engine.AddCardsFromDefs(defs)
+	if _, err := engine.DrawCards(3); err != nil {
+		t.Fatal(err)
+	}
+	base := engine.GetVersion()
+
+	// A bad last step rolls back the steps before it
+	result, err := engine.ResolveBatch([]BatchStep{
+		{CardID: "first", Direction: "left", ExpectedVersion: base},
+		{CardID: "second", Direction: "left"},
+		{CardID: "missing", Direction: "left"},
+	})
+	if err != nil {
+		t.Fatalf("ResolveBatch failed: %v", err)
+	}
+	if result.Applied || result.FailedStep != 2 || len(result.Steps) != 3 || result.Steps[2].Error == "" {
+		t.Fatalf("Expected the batch to fail at step 2, got %+v", result)
+	}
+	if engine.state.Stats["health"] != 100 || len(engine.drawnCards) != 3 || engine.GetVersion() != base {
+		t.Errorf("Expected a failed batch to leave the game untouched, got health %d, %d drawn, version %d",
+			engine.state.Stats["health"], len(engine.drawnCards), engine.GetVersion())
+	}
+
+	// A stale version is a conflict
+	result, err = engine.ResolveBatch([]BatchStep{{CardID: "first", Direction: "left", ExpectedVersion: base + 5}})
+	if err != nil {
+		t.Fatalf("ResolveBatch failed: %v", err)
+	}
+	if result.Applied || !result.Steps[0].Conflict {
+		t.Errorf("Expected a version conflict, got %+v", result)
+	}
+
+	result, err = engine.ResolveBatch([]BatchStep{
+		{CardID: "first", Direction: "left", ExpectedVersion: base},
+		{CardID: "second", Direction: "left", ExpectedVersion: base + 1},
+		{CardID: "third", Direction: "right"},
+	})
+	if err != nil {
+		t.Fatalf("ResolveBatch failed: %v", err)
+	}
+	if !result.Applied || result.FailedStep != -1 {
+		t.Fatalf("Expected the batch to apply, got %+v", result)
+	}
+	if engine.state.Stats["health"] != 90 || len(engine.drawnCards) != 0 {
+		t.Errorf("Expected both choices applied, got health %d, %d drawn", engine.state.Stats["health"], len(engine.drawnCards))
+	}
+	if result.Steps[1].Version != base+2 || result.Version != engine.GetVersion() {
+		t.Errorf("Expected a version per step, got %+v", result.Steps)
+	}
+
+	if _, err := engine.ResolveBatch(nil); err == nil {
+		t.Error("Expected an empty batch to be rejected")
+	}
+}
+
+// TestVerifyOfflineSession tests that an offline decision log is replayed
+// and accepted only when it reaches the claimed state
+func TestVerifyOfflineSession(t *testing.T) {
+	engine, err := NewGameEngine("test-game", createTestSchema())
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	engine.AddCardsFromDefs([]map[string]interface{}{{
+		"id":    "storm",
+		"title": "Storm",
+		"left_choice": map[string]interface{}{
+			"label": "Shelter",
+			"calls": []interface{}{map[string]interface{}{"name": "update_stat", "params": map[string]interface{}{"stat_id": "health", "delta": float64(-5)}}},
+		},
+		"right_choice": map[string]interface{}{"label": "Endure"},
+	}})
+	if _, err := engine.DrawCards(1); err != nil {
+		t.Fatal(err)
+	}
+
+	// The embedded engine starts from the same state and seed
+	cp, err := engine.checkpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := &GlobalBlackboard{}
+	if err := json.Unmarshal(cp.state, state); err != nil {
+		t.Fatal(err)
+	}
+	dag := story.NewMacroDAG()
+	if err := json.Unmarshal(cp.dag, dag); err != nil {
+		t.Fatal(err)
+	}
+	offline := LoadGameEngine("test-game", state, dag)
+	offline.drawnCards = append(offline.drawnCards, cp.drawnCards...)
+	if _, err := offline.ResolveCard("storm", "left"); err != nil {
+		t.Fatalf("Offline resolve failed: %v", err)
+	}
+	if err := offline.AdvanceWeek(); err != nil {
+		t.Fatalf("Offline advance failed: %v", err)
+	}
+	claimed := &ClaimedState{
+		Stats:      offline.state.VisibleStats(),
+		Tags:       offline.state.GetTags(),
+		Day:        offline.state.Day,
+		Season:     offline.state.Season,
+		Year:       offline.state.Year,
+		IsAlive:    offline.state.IsAlive,
+		LifeNumber: offline.state.LifeNumber,
+	}
+	actions := []OfflineAction{
+		{Action: OfflineResolve, CardID: "storm", Direction: "left"},
+		{Action: OfflineAdvance},
+	}
+
+	// A cheated claim is rejected and changes nothing
+	base := engine.GetVersion()
+	cheated := *claimed
+	cheated.Stats = map[string]int{"health": 100, "mana": claimed.Stats["mana"]}
+	result, err := engine.VerifyOfflineSession(actions, &cheated)
+	if err != nil {
+		t.Fatalf("VerifyOfflineSession failed: %v", err)
+	}
+	if result.Accepted || len(result.Mismatches) != 1 || result.Mismatches[0] != "stats.health" {
+		t.Fatalf("Expected the cheated health to be rejected, got %+v", result)
+	}
+	if engine.state.Stats["health"] != 100 || len(engine.drawnCards) != 1 || engine.GetVersion() != base {
+		t.Error("Expected a rejected session to leave the game untouched")
+	}
+
+	result, err = engine.VerifyOfflineSession(actions, claimed)
+	if err != nil {
+		t.Fatalf("VerifyOfflineSession failed: %v", err)
+	}
+	if !result.Accepted {
+		t.Fatalf("Expected the honest session to be accepted, got %+v", result)
+	}
+	if engine.state.Stats["health"] != 95 || engine.state.Day != claimed.Day || len(engine.drawnCards) != 0 {
+		t.Errorf("Expected the replay to be kept, got health %d on day %d", engine.state.Stats["health"], engine.state.Day)
+	}
+
+	// Replaying a card that was already resolved fails
+	result, err = engine.VerifyOfflineSession(actions[:1], claimed)
+	if err != nil {
+		t.Fatalf("VerifyOfflineSession failed: %v", err)
+	}
+	if result.Accepted || result.FailedStep != 0 || result.Error == "" {
+		t.Errorf("Expected the replay to fail at step 0, got %+v", result)
+	}
+}
+
+// TestDump tests that a debug dump includes hidden state and queued cards
+func TestDump(t *testing.T) {
+	engine, err := NewGameEngine("test-game", createTestSchema())
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	engine.state.HiddenStats = map[string]bool{"mana": true}
+	engine.AddCardsFromDefs([]map[string]interface{}{{"id": "omen", "title": "Omen"}})
+
+	data, err := engine.Dump()
+	if err != nil {
+		t.Fatalf("Dump failed: %v", err)
+	}
+	var dump struct {
+		State struct {
+			Stats map[string]int `json:"stats"`
+		} `json:"state"`
+		Deck []map[string]interface{} `json:"deck"`
+	}
+	if err := json.Unmarshal(data, &dump); err != nil {
+		t.Fatalf("Failed to decode dump: %v", err)
+	}
+	if _, ok := dump.State.Stats["mana"]; !ok {
+		t.Error("Expected hidden stats in the dump")
+	}
+	if len(dump.Deck) != 1 || dump.Deck[0]["id"] != "omen" {
+		t.Errorf("Expected the deck in the dump, got %v", dump.Deck)
+	}
+}
+
+// TestPatchState tests admin merge patches and their validation
+func TestPatchState(t *testing.T) {
+	engine, err := NewGameEngine("test-game", createTestSchema())
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	engine.state.Tags["tag2"] = true
+
+	fields, err := engine.PatchState([]byte(`{
+		"stats": {"health": 40},
+		"tags": {"tag1": true, "tag2": null},
+		"npcs": {"npc1": {"enabled": false}},
+		"day": 9,
+		"pending_plot_node_id": "plot1"
+	}`))
+	if err != nil {
+		t.Fatalf("PatchState failed: %v", err)
+	}
+	if len(fields) != 5 || fields[0] != "day" {
+		t.Errorf("Expected the five patched fields sorted, got %v", fields)
+	}
+
+	state := engine.GetState()
+	if state.Stats["health"] != 40 || state.Stats["mana"] != 50 {
+		t.Errorf("Expected health 40 and mana untouched, got %v", state.Stats)
+	}
+	if !state.Tags["tag1"] || state.Tags["tag2"] {
+		t.Errorf("Expected tag1 added and tag2 removed, got %v", state.Tags)
+	}
+	if npc := state.NPCs["npc1"]; npc.Enabled || npc.Name != "NPC 1" {
+		t.Errorf("Expected npc1 disabled with its other fields kept, got %+v", npc)
+	}
+	if state.Day != 9 || state.Turn != 1 || state.PendingPlotNodeID != "plot1" {
+		t.Errorf("Expected day 9, turn 1 and plot1 pending, got %d, %d, %q", state.Day, state.Turn, state.PendingPlotNodeID)
+	}
+	if engine.GetVersion() != 1 {
+		t.Errorf("Expected the patch to record version 1, got %d", engine.GetVersion())
+	}
+
+	rejected := []struct {
+		patch string
+		field string
+	}{
+		{`[]`, ""},
+		{`{"rng_seed": 1}`, "rng_seed"},
+		{`{"stats": {"health": 101}}`, "stats.health"},
+		{`{"stats": {"gold": 10}}`, "stats.gold"},
+		{`{"stats": {"mana": null}}`, "stats.mana"},
+		{`{"tags": {"unknown": true}}`, "tags.unknown"},
+		{`{"npcs": {"npc1": {"name": "Bob"}}}`, "npcs.npc1.name"},
+		{`{"npcs": {"npc1": null}}`, "npcs.npc1"},
+		{`{"npcs": {"npc2": {"enabled": true}}}`, "npcs.npc2"},
+		{`{"day": 0}`, "day"},
+		{`{"season": 4}`, "season"},
+		{`{"pending_plot_node_id": "missing"}`, "pending_plot_node_id"},
+		{`{"stats": {"health": 10}, "day": 30}`, "day"},
+	}
+	for _, tt := range rejected {
+		_, err := engine.PatchState([]byte(tt.patch))
+		var patchErr *PatchError
+		if !errors.As(err, &patchErr) || patchErr.Field != tt.field {
+			t.Errorf("%s: expected a PatchError on %q, got %v", tt.patch, tt.field, err)
+		}
+	}
+	if state := engine.GetState(); state.Stats["health"] != 40 || state.Day != 9 {
+		t.Errorf("Expected rejected patches to change nothing, got health %d day %d", state.Stats["health"], state.Day)
+	}
+	if engine.GetVersion() != 1 {
+		t.Errorf("Expected rejected patches to keep version 1, got %d", engine.GetVersion())
+	}
+}
+
+// recordedFailures is a validation store that keeps failures in memory
+type recordedFailures []agents.ValidationFailure
+
+func (r *recordedFailures) RecordValidationFailure(f agents.ValidationFailure) error {
+	*r = append(*r, f)
+	return nil
+}
+
+// TestUnknownTags tests that Writer cards using undefined tags are mapped to
+// a close known tag or dropped, and that both are recorded
+func TestUnknownTags(t *testing.T) {
+	engine, err := NewGameEngine("test-game", createTestSchema())
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	var failures recordedFailures
+	agents.SetValidationStore(&failures)
+	defer agents.SetValidationStore(nil)
+
+	tagCall := func(name, tagID string) []interface{} {
+		return []interface{}{map[string]interface{}{"name": name, "params": map[string]interface{}{"tag_id": tagID}}}
+	}
+	added := engine.AddCardsFromDefs([]map[string]interface{}{
+		{
+			"id":           "mapped",
+			"title":        "Mapped",
+			"left_choice":  map[string]interface{}{"label": "Take it", "calls": tagCall("add_tag", "Tag-2")},
+			"right_choice": map[string]interface{}{"label": "Leave", "calls": tagCall("remove_tag", "tag1")},
+		},
+		{
+			"id":          "rejected",
+			"title":       "Rejected",
+			"left_choice": map[string]interface{}{"label": "Slay", "calls": tagCall("add_tag", "dragon_slayer")},
+		},
+		{
+			"id":           "rejected_unlocked",
+			"title":        "Rejected Unlocked",
+			"left_choice":  map[string]interface{}{"label": "Wait", "unlocked": map[string]interface{}{"label": "Slay", "requires": "'tag1' in tags", "calls": tagCall("add_tag", "dragon_slayer")}},
+			"right_choice": map[string]interface{}{"label": "Leave"},
+		},
+	})
+	if added != 1 {
+		t.Fatalf("Expected only the mappable card to be added, got %d", added)
+	}
+
+	engine.DrawCards(1)
+	if _, err := engine.ResolveCard("mapped", "left"); err != nil {
+		t.Fatalf("Failed to resolve mapped card: %v", err)
+	}
+	if !engine.state.Tags["tag2"] || engine.state.Tags["Tag-2"] {
+		t.Errorf("Expected Tag-2 to be applied as tag2, got %v", engine.state.Tags)
+	}
+
+	classes := map[string]int{}
+	for _, f := range failures {
+		if f.Agent != agents.AgentWriter {
+			t.Errorf("Expected writer failures, got %s", f.Agent)
+		}
+		classes[f.Class]++
+	}
+	if classes[agents.FailureTagMapped] != 1 || classes[agents.FailureTagRejected] != 2 {
+		t.Errorf("Expected 1 mapped and 2 rejected tags, got %v", classes)
+	}
+}
+
+// TestStoryEditor tests that the Editor repairs or rejects Writer cards that
+// contradict the story, and only in games that enabled it
+func TestStoryEditor(t *testing.T) {
+	engine, err := NewGameEngine("test-game", createTestSchema())
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	var failures recordedFailures
+	agents.SetValidationStore(&failures)
+	defer agents.SetValidationStore(nil)
+
+	npc := engine.state.NPCs["npc1"]
+	npc.Enabled, npc.Deceased = false, true
+	engine.state.NPCs["npc1"] = npc
+
+	call := func(name, key, value string) map[string]interface{} {
+		return map[string]interface{}{"name": name, "params": map[string]interface{}{key: value}}
+	}
+	batch := func() []map[string]interface{} {
+		return []map[string]interface{}{
+			{
+				"id":           "ghost",
+				"title":        "Ghost",
+				"character":    "npc1",
+				"left_choice":  map[string]interface{}{"label": "Revive", "calls": []interface{}{call("enable_npc", "npc_id", "npc1")}},
+				"right_choice": map[string]interface{}{"label": "Mourn", "calls": []interface{}{call("add_tag", "tag_id", "tag2")}},
+			},
+			{
+				"id":           "cure",
+				"title":        "Cure",
+				"left_choice":  map[string]interface{}{"label": "Heal", "calls": []interface{}{call("remove_tag", "tag_id", "tag2")}},
+				"right_choice": map[string]interface{}{"label": "Wait"},
+			},
+			{"id": "lifted", "title": "Lifted", "left_choice": map[string]interface{}{"label": "Lift", "calls": []interface{}{call("remove_tag", "tag_id", "tag1")}}, "right_choice": map[string]interface{}{"label": "Wait"}},
+			{"id": "premature", "title": "Premature", "plot_node": "plot1"},
+		}
+	}
+
+	// Without the Editor, every card is added as written
+	plain, err := NewGameEngine("plain-game", createTestSchema())
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	if added := plain.AddCardsFromDefs(batch()); added != 4 {
+		t.Fatalf("Expected 4 cards without the Editor, got %d", added)
+	}
+	if len(failures) != 0 {
+		t.Fatalf("Expected no Editor failures without the Editor, got %v", failures)
+	}
+
+	engine.EnableEditor()
+	defs := batch()
+	if added := engine.AddCardsFromDefs(defs); added != 3 {
+		t.Fatalf("Expected all but the premature plot card to be added, got %d", added)
+	}
+	if defs[0]["character"] != "narrator" {
+		t.Errorf("Expected the dead NPC's card to go to the narrator, got %v", defs[0]["character"])
+	}
+	if calls := defs[0]["left_choice"].(map[string]interface{})["calls"].([]interface{}); len(calls) != 0 {
+		t.Errorf("Expected enable_npc of the dead NPC to be dropped, got %v", calls)
+	}
+
+	// Without the ghost card adding tag2, the cure removes a tag the
+	// player does not have
+	if added := engine.AddCardsFromDefs(batch()[1:2]); added != 0 {
+		t.Errorf("Expected the cure card to be rejected on its own, got %d added", added)
+	}
+
+	classes := map[string]int{}
+	for _, f := range failures {
+		if f.Agent != agents.AgentEditor {
+			t.Errorf("Expected editor failures, got %s", f.Agent)
+		}
+		classes[f.Class]++
+	}
+	if classes[agents.FailureContradictionRepaired] != 2 || classes[agents.FailureContradictionRejected] != 2 {
+		t.Errorf("Expected 2 repaired and 2 rejected cards, got %v", classes)
+	}
+
+	// Once its plot node fires, a plot card is accepted
+	if _, err := engine.dag.FireNode("plot1"); err != nil {
+		t.Fatalf("Failed to fire plot1: %v", err)
+	}
+	if added := engine.AddCardsFromDefs(batch()[3:]); added != 1 {
+		t.Errorf("Expected the plot card to be added after its node fired, got %d", added)
+	}
+}
+
+// TestFacts tests that facts are established by calls, plot firings and
+// deaths, kept apart from tags, given to the Writer and checked by the Editor
+func TestFacts(t *testing.T) {
+	engine, err := NewGameEngine("test-game", createTestSchema())
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+
+	factCall := func(name, factID string) []interface{} {
+		return []interface{}{map[string]interface{}{"name": name, "params": map[string]interface{}{"fact_id": factID, "text": "The bridge fell"}}}
+	}
+	engine.AddCardsFromDefs([]map[string]interface{}{{
+		"id":           "storm",
+		"title":        "Storm",
+		"left_choice":  map[string]interface{}{"label": "Cut the ropes", "calls": factCall("add_fact", "bridge_destroyed")},
+		"right_choice": map[string]interface{}{"label": "Hold on"},
+	}})
+	engine.DrawCards(1)
+	if _, err := engine.ResolveCard("storm", "left"); err != nil {
+		t.Fatalf("Failed to resolve storm card: %v", err)
+	}
+	if fact := engine.state.Facts["bridge_destroyed"]; fact.Text != "The bridge fell" || fact.Source != FactSourceCall {
+		t.Fatalf("Expected add_fact to establish bridge_destroyed, got %+v", engine.state.Facts)
+	}
+	if engine.state.Tags["bridge_destroyed"] {
+		t.Error("Expected facts to stay out of tags")
+	}
+
+	engine.mu.Lock()
+	engine.state.PendingPlotNodeID = "plot1"
+	engine.mu.Unlock()
+	if err := engine.FirePendingPlot(); err != nil {
+		t.Fatalf("Failed to fire plot1: %v", err)
+	}
+	if !engine.state.HasFact(plotFactID("plot1")) {
+		t.Errorf("Expected firing plot1 to establish a fact, got %v", engine.state.Facts)
+	}
+	facts, _ := engine.buildSnapshot()["facts"].([]Fact)
+	if len(facts) != 2 {
+		t.Errorf("Expected the Writer to see 2 facts, got %v", facts)
+	}
+
+	if _, err := cards.NewActionExecutor(engine.state).Execute(map[string]interface{}{
+		"name": "add_fact", "params": map[string]interface{}{"fact_id": "Bridge Destroyed"},
+	}); err == nil {
+		t.Error("Expected add_fact to reject a fact ID that is not snake_case")
+	}
+
+	// A time loop rewinds facts but not deaths
+	engine.state.MarkLoopStart()
+	engine.state.EstablishFact("gate_open", "")
+	engine.state.establishFact(deathFactID("npc1"), "", FactSourceDeath)
+	engine.state.RestoreLoop()
+	if engine.state.HasFact("gate_open") || !engine.state.HasFact(deathFactID("npc1")) || !engine.state.HasFact("bridge_destroyed") {
+		t.Errorf("Expected the loop to rewind only gate_open, got %v", engine.state.Facts)
+	}
+
+	engine.EnableEditor()
+	added := engine.AddCardsFromDefs([]map[string]interface{}{
+		{"id": "ruins", "title": "Ruins", "assumes": []interface{}{"bridge_destroyed"}},
+		{"id": "crossing", "title": "Crossing", "assumes": []interface{}{"!bridge_destroyed"}},
+		{"id": "gate", "title": "Gate", "assumes": []interface{}{"gate_open"}},
+	})
+	if added != 1 {
+		t.Errorf("Expected only the card consistent with the facts to be added, got %d", added)
+	}
+
+	if _, err := cards.NewActionExecutor(engine.state).Execute(map[string]interface{}{
+		"name": "retract_fact", "params": map[string]interface{}{"fact_id": "bridge_destroyed"},
+	}); err != nil || engine.state.HasFact("bridge_destroyed") {
+		t.Errorf("Expected retract_fact to forget bridge_destroyed, got %v (%v)", engine.state.Facts, err)
+	}
+}
+
+// TestDirector tests the pacing directives chosen from recent play
+func TestDirector(t *testing.T) {
+	engine, err := NewGameEngine("test-game", createTestSchema())
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	if d := engine.direct(); d.Directive != PacingSteady {
+		t.Fatalf("Expected a new game to be steady, got %+v", d)
+	}
+
+	beats := func(direction string, swing, n int) {
+		engine.state.Pacing = nil
+		for i := 0; i < n; i++ {
+			engine.state.LogBeat(direction, map[string]int{"health": -swing})
+		}
+	}
+
+	beats("left", 20, 3)
+	if d := engine.direct(); d.Directive != PacingCalmWeek || d.Signals.Volatility != 20 {
+		t.Errorf("Expected wild swings to call for a calm week, got %+v", d)
+	}
+
+	beats("left", 1, 6)
+	if d := engine.direct(); d.Directive != PacingEscalate {
+		t.Errorf("Expected an uneventful run to escalate, got %+v", d)
+	}
+
+	beats("right", 8, 6)
+	if d := engine.direct(); d.Directive != PacingComicRelief || d.Signals.SideStreak != 6 {
+		t.Errorf("Expected autopilot swiping to call for comic relief, got %+v", d)
+	}
+
+	engine.state.Pacing = nil
+	engine.state.Season = 1
+	if d := engine.direct(); d.Directive != PacingEscalate || d.Signals.DaysSincePlot != 28 {
+		t.Errorf("Expected a season without plot to escalate, got %+v", d)
+	}
+	engine.markPlotBeat()
+	engine.state.IsFirstDayAfterDeath = true
+	if d := engine.direct(); d.Directive != PacingComicRelief {
+		t.Errorf("Expected comic relief after a death, got %+v", d)
+	}
+
+	pacing := engine.GetGenerationContext()["pacing"].(map[string]interface{})
+	if pacing["directive"] != PacingComicRelief {
+		t.Errorf("Expected the directive in the Writer context, got %v", pacing)
+	}
+}
+
+// TestDifficultyBalancer tests that difficulty eases after repeated deaths,
+// rises after a quiet stretch and drives weekly stat drift
+func TestDifficultyBalancer(t *testing.T) {
+	schema := createTestSchema()
+	schema.Difficulty = &agents.DifficultyDef{Min: 3}
+	if _, err := NewGameEngine("test-game", schema); err == nil {
+		t.Error("Expected a minimum above 1 to be rejected")
+	}
+	schema.Difficulty = &agents.DifficultyDef{Max: 1.2}
+	engine, err := NewGameEngine("test-game", schema)
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	d := engine.state.Difficulty
+	if d.Level != 1 || d.Min != defaultMinDifficulty || d.Max != 1.2 {
+		t.Fatalf("Unexpected difficulty: %+v", d)
+	}
+
+	// Two deaths in the window ease the game
+	engine.state.logDeath()
+	engine.state.logDeath()
+	engine.balanceDifficulty()
+	if d.Level != 0.9 {
+		t.Errorf("Expected level 0.9 after two deaths, got %v", d.Level)
+	}
+
+	// Without deaths for a whole window the game gets harder, up to Max
+	engine.state.Year = 1
+	for i := 0; i < 5; i++ {
+		engine.balanceDifficulty()
+	}
+	if d.Level != 1.2 || len(d.DeathDays) != 0 {
+		t.Errorf("Expected level capped at 1.2 with old deaths forgotten, got %+v", d)
+	}
+
+	// Pressure pushes stats away from 50 but stops at the danger margin
+	engine.state.Stats["health"] = 84
+	engine.state.Stats["mana"] = 40
+	engine.applyDrift(2)
+	if engine.state.Stats["health"] != 85 || engine.state.Stats["mana"] != 38 {
+		t.Errorf("Expected pressure drift to 85 and 38, got %v", engine.state.Stats)
+	}
+	engine.applyDrift(-5)
+	if engine.state.Stats["health"] != 80 || engine.state.Stats["mana"] != 43 {
+		t.Errorf("Expected relief drift to 80 and 43, got %v", engine.state.Stats)
+	}
+
+	guidance := engine.GetGenerationContext()["difficulty"].(map[string]interface{})
+	if guidance["typical_delta"] != 12 || guidance["max_delta"] != 30 || guidance["weekly_drift"] != 1 {
+		t.Errorf("Unexpected Writer guidance at level 1.2: %v", guidance)
+	}
+}
+
+// TestTutorial tests that the onboarding cards come first, name the world's
+// stats and tags, and leave the game untouched
+func TestTutorial(t *testing.T) {
+	engine, err := NewGameEngine("test-game", createTestSchema())
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	engine.deck.Insert(&cards.InfoCard{ID: "deck_card", Title: "Deck", Source: "info"})
+	engine.StartTutorial()
+
+	drawn, _ := engine.DrawCards(3)
+	ids := make([]string, 0, len(drawn))
+	for _, card := range drawn {
+		ids = append(ids, card.GetID())
+	}
+	if strings.Join(ids, ",") != "tutorial_swipe,tutorial_stats,tutorial_tags" {
+		t.Fatalf("Expected the tutorial to open the game in order, got %v", ids)
+	}
+	if desc := drawn[1].GetDescription(); !strings.Contains(desc, "Health, Mana") {
+		t.Errorf("Expected the stats card to name the world's stats, got %q", desc)
+	}
+	if desc := drawn[2].GetDescription(); !strings.Contains(desc, `"Tag 1"`) {
+		t.Errorf("Expected the tags card to name a world tag, got %q", desc)
+	}
+
+	stats := map[string]int{"health": engine.state.Stats["health"], "mana": engine.state.Stats["mana"]}
+	if _, err := engine.ResolveCard("tutorial_swipe", "left"); err != nil {
+		t.Fatalf("Failed to swipe the tutorial card: %v", err)
+	}
+	if _, err := engine.ResolveCard("tutorial_swipe", "right"); err == nil {
+		t.Error("Expected a swiped tutorial card to be gone")
+	}
+	if _, err := engine.ResolveCard("tutorial_stats", ""); err != nil {
+		t.Fatalf("Failed to resolve a tutorial info card: %v", err)
+	}
+	if len(engine.state.Pacing) != 0 || len(engine.state.LifeLog) != 0 {
+		t.Errorf("Expected tutorial swipes to stay out of the logs, got %v and %v", engine.state.Pacing, engine.state.LifeLog)
+	}
+	for id, value := range stats {
+		if engine.state.Stats[id] != value {
+			t.Errorf("Expected %s to stay %d, got %d", id, value, engine.state.Stats[id])
+		}
+	}
+
+	// The rest of the tutorial, then the deck
+	drawn, _ = engine.DrawCards(7)
+	if len(drawn) != 3 || drawn[1].GetID() != "tutorial_death" || drawn[2].GetID() != "deck_card" {
+		t.Errorf("Expected the last tutorial cards before the deck, got %v", drawn)
+	}
+}
+
+// TestGlossary tests that the glossary follows the world as NPCs and tags
+// join, and keeps hidden stats and unmet NPCs out
+func TestGlossary(t *testing.T) {
+	schema := createTestSchema()
+	schema.Stats = append(schema.Stats, agents.StatDef{ID: "suspicion", Name: "Suspicion", Hidden: true})
+	schema.NPCs = append(schema.NPCs, agents.NPCDef{EntityDef: agents.EntityDef{ID: "stranger", Name: "Stranger"}})
+
+	engine, err := NewGameEngine("test-game", schema)
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	engine.state.DisableNPC("stranger")
+
+	glossary := engine.GetGlossary()
+	if len(glossary.Stats) != 2 || glossary.Stats[0] != (GlossaryEntry{ID: "health", Name: "Health", Description: "Health stat"}) {
+		t.Errorf("Expected the two visible stats, got %+v", glossary.Stats)
+	}
+	if len(glossary.Tags) != 2 || len(glossary.Seasons) != 4 || glossary.Seasons[0].Name != "Spring" {
+		t.Errorf("Expected the world's tags and seasons, got %+v and %+v", glossary.Tags, glossary.Seasons)
+	}
+	if len(glossary.NPCs) != 1 || glossary.NPCs[0] != (GlossaryEntry{ID: "npc1", Name: "NPC 1", Description: "Test NPC"}) {
+		t.Errorf("Expected only the NPC the player can meet, got %+v", glossary.NPCs)
+	}
+
+	engine.AddCardsFromDefs([]map[string]interface{}{{
+		"id":      "successor_npc1",
+		"title":   "A New Smith",
+		"new_npc": map[string]interface{}{"id": "npc1_heir", "name": "Heir", "description": "The smith's apprentice"},
+	}})
+	engine.state.Tags["outlaw"] = true
+
+	glossary = engine.GetGlossary()
+	if len(glossary.NPCs) != 2 || glossary.NPCs[1] != (GlossaryEntry{ID: "npc1_heir", Name: "Heir", Description: "The smith's apprentice"}) {
+		t.Errorf("Expected the successor in the glossary, got %+v", glossary.NPCs)
+	}
+	if last := glossary.Tags[len(glossary.Tags)-1]; last != (GlossaryEntry{ID: "outlaw", Name: "outlaw"}) {
+		t.Errorf("Expected the undefined active tag last, got %+v", glossary.Tags)
+	}
+}
+
+// TestCodex tests that NPCs, tags and arcs unlock as the player meets them,
+// each asking the Writer once for lore
+func TestCodex(t *testing.T) {
+	schema := createTestSchema()
+	schema.Arcs = []agents.ArcDef{{ID: "uprising", Title: "The Uprising", Theme: "Rebellion"}}
+	schema.PlotNodes[0].ArcID = "uprising"
+
+	engine, err := NewGameEngine("test-game", schema)
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	if len(engine.GetCodex()) != 0 {
+		t.Fatalf("Expected an empty codex before play, got %+v", engine.GetCodex())
+	}
+
+	engine.AddCardsFromDefs([]map[string]interface{}{{
+		"id":        "smithy",
+		"title":     "At the Smithy",
+		"character": "npc1",
+		"left_choice": map[string]interface{}{
+			"label": "Help",
+			"calls": []interface{}{map[string]interface{}{"name": "add_tag", "params": map[string]interface{}{"tag_id": "tag2"}}},
+		},
+		"right_choice": map[string]interface{}{"label": "Leave"},
+	}})
+	if _, err := engine.DrawCards(1); err != nil {
+		t.Fatal(err)
+	}
+	codex := engine.GetCodex()
+	if len(codex) != 2 || codex[0].Kind != CodexNPC || codex[0].Name != "NPC 1" || codex[1] != (CodexEntry{Kind: CodexTag, ID: "tag1", Name: "Tag 1", Description: "Test tag 1", Day: 1, Season: engine.state.Season, Year: engine.state.Year}) {
+		t.Fatalf("Expected the NPC and the starting tag, got %+v", codex)
+	}
+	if engine.state.NPCs["npc1"].AppearanceCount != 1 {
+		t.Errorf("Expected the appearance counted, got %d", engine.state.NPCs["npc1"].AppearanceCount)
+	}
+
+	if _, err := engine.ResolveCard("smithy", "left"); err != nil {
+		t.Fatalf("Failed to resolve card: %v", err)
+	}
+	if _, err := engine.dag.FireNode("plot1"); err != nil {
+		t.Fatal(err)
+	}
+	engine.AddCardsFromDefs([]map[string]interface{}{{"id": "again", "title": "Back Again", "character": "npc1"}})
+	if _, err := engine.DrawCards(1); err != nil {
+		t.Fatal(err)
+	}
+	codex = engine.GetCodex()
+	if len(codex) != 4 || codex[2].ID != "tag2" || codex[3] != (CodexEntry{Kind: CodexArc, ID: "uprising", Name: "The Uprising", Description: "Rebellion", Day: codex[3].Day, Season: codex[3].Season, Year: codex[3].Year}) {
+		t.Fatalf("Expected the gained tag and the started arc once each, got %+v", codex)
+	}
+
+	lore := 0
+	for _, job := range engine.PendingJobs() {
+		if job.JobType == "lore" {
+			lore++
+		}
+	}
+	if lore != 4 {
+		t.Errorf("Expected a lore job per entry, got %d", lore)
+	}
+
+	added := engine.AddCardsFromDefs([]map[string]interface{}{
+		{"id": "lore_npc_npc1", "title": "The Smith", "description": "Forged the city's first bell.", "source": "lore"},
+		{"id": "lore_npc_npc1", "title": "The Smith", "description": "Rewritten.", "source": "lore"},
+		{"id": "lore_npc_nobody", "title": "Nobody", "description": "Unknown.", "source": "lore"},
+	})
+	if added != 1 || engine.GetCodex()[0].Lore != "Forged the city's first bell." {
+		t.Errorf("Expected the lore stored once on its entry, got %d and %+v", added, engine.GetCodex()[0])
+	}
+}
+
+// TestChronicle tests that a week's choices and stat changes are summed up
+// on top of the next week's deck, and optionally polished by the Writer
+func TestChronicle(t *testing.T) {
+	engine, err := NewGameEngine("test-game", createTestSchema())
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	engine.AddCardsFromDefs([]map[string]interface{}{{
+		"id":    "toll",
+		"title": "The Toll",
+		"left_choice": map[string]interface{}{
+			"label": "Pay",
+			"calls": []interface{}{map[string]interface{}{"name": "update_stat", "params": map[string]interface{}{"stat_id": "mana", "delta": float64(-10)}}},
+		},
+		"right_choice": map[string]interface{}{"label": "Refuse"},
+	}})
+	if _, err := engine.DrawCards(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.ResolveCard("toll", "left"); err != nil {
+		t.Fatalf("Failed to resolve card: %v", err)
+	}
+	if err := engine.AdvanceWeek(); err != nil {
+		t.Fatal(err)
+	}
+
+	chronicle, ok := engine.DrawCard().(*cards.InfoCard)
+	if !ok || chronicle.ID != "chronicle_week_1" || chronicle.Description != `This week you chose "Pay" at The Toll. Mana fell by 10.` {
+		t.Fatalf("Expected the week's chronicle first, got %+v", chronicle)
+	}
+	if len(engine.state.WeekLog) != 0 || engine.state.WeekStartStats["mana"] != engine.state.Stats["mana"] {
+		t.Errorf("Expected the next week to start afresh, got %+v and %+v", engine.state.WeekLog, engine.state.WeekStartStats)
+	}
+	chronicleJobs := func() []*CardGenJob {
+		var jobs []*CardGenJob
+		for _, job := range engine.PendingJobs() {
+			if job.JobType == "chronicle" {
+				jobs = append(jobs, job)
+			}
+		}
+		return jobs
+	}
+	if jobs := chronicleJobs(); len(jobs) != 0 {
+		t.Errorf("Expected no chronicle job without polishing, got %+v", jobs)
+	}
+
+	engine.PolishChronicles()
+	if err := engine.AdvanceWeek(); err != nil {
+		t.Fatal(err)
+	}
+	jobs := chronicleJobs()
+	if len(jobs) != 1 || jobs[0].Context["draft"] != "A quiet week passed without a decision. Nothing changed for better or worse." {
+		t.Fatalf("Expected a chronicle job with the template draft, got %+v", jobs)
+	}
+	added := engine.AddCardsFromDefs([]map[string]interface{}{
+		{"id": jobs[0].Context["card_id"], "title": "A Still Week", "description": "The roads were empty and the granaries full.", "source": "chronicle"},
+	})
+	chronicle, _ = engine.DrawCard().(*cards.InfoCard)
+	if added != 1 || chronicle == nil || chronicle.Title != "A Still Week" || chronicle.Description != "The roads were empty and the granaries full." {
+		t.Errorf("Expected the polished chronicle, got %d and %+v", added, chronicle)
+	}
+}
+
+// TestDeckConfig tests that a world shapes its week decks and that harder
+// games space out info cards
+func TestDeckConfig(t *testing.T) {
+	for _, def := range []agents.DeckDef{{Size: -1}, {Size: DaysPerSeason + 1}, {CommonShare: 1.5}, {InfoEvery: -2}} {
+		schema := createTestSchema()
+		schema.Deck = &def
+		if _, err := NewGameEngine("test-game", schema); err == nil {
+			t.Errorf("Expected %+v to be rejected", def)
+		}
+	}
+
+	schema := createTestSchema()
+	schema.Deck = &agents.DeckDef{Size: 10, CommonShare: 0.5, InfoEvery: 2}
+	engine, err := NewGameEngine("test-game", schema)
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	if engine.GetWeekDeckSize() != 10 || engine.GetCommonCount() != 10 || engine.GetInfoCount() != 5 {
+		t.Errorf("Expected 10 common cards with 5 info cards, got %d, %d and %d", engine.GetWeekDeckSize(), engine.GetCommonCount(), engine.GetInfoCount())
+	}
+
+	for i := 0; i < 8; i++ {
+		engine.jobQueue.Enqueue(&CardGenJob{JobType: "plot"})
+	}
+	if engine.GetCommonCount() != 5 {
+		t.Errorf("Expected half the deck kept for common cards, got %d", engine.GetCommonCount())
+	}
+
+	engine.state.Difficulty.Level = 1.5
+	deck := engine.GetGenerationContext()["deck"].(map[string]interface{})
+	if deck["common_count"] != 5 || deck["info_count"] != 1 {
+		t.Errorf("Expected one info card in five at level 1.5, got %+v", deck)
+	}
+}
+
+// TestCustomCalendar tests worlds with their own week, season and year
+// lengths
+func TestCustomCalendar(t *testing.T) {
+	for _, def := range []agents.CalendarDef{{DaysPerWeek: -1}, {DaysPerWeek: 5, DaysPerSeason: 12}, {SeasonsPerYear: 3}, {DaysPerSeason: 500}} {
+		schema := createTestSchema()
+		schema.Calendar = &def
+		if _, err := NewGameEngine("test-game", schema); err == nil {
+			t.Errorf("Expected %+v to be rejected", def)
+		}
+	}
+
+	schema := createTestSchema()
+	schema.Seasons = []agents.SeasonDef{
+		{ID: "thaw", Name: "Thaw"},
+		{ID: "bloom", Name: "Bloom"},
+		{ID: "frost", Name: "Frost"},
+	}
+	schema.Calendar = &agents.CalendarDef{DaysPerWeek: 5}
+	engine, err := NewGameEngine("test-game", schema)
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	if engine.GetWeekDeckSize() != 5 {
+		t.Errorf("Expected a card per day of a 5-day week, got %d", engine.GetWeekDeckSize())
+	}
+
+	weeks, seasons := 0, 0
+	for day := 1; day <= 60; day++ {
+		crossed := engine.AdvanceDayWithBoundaries()
+		if crossed["week_end"] {
+			weeks++
+		}
+		if crossed["season_end"] {
+			seasons++
+		}
+	}
+	if weeks != 12 || seasons != 3 {
+		t.Errorf("Expected 12 weeks and 3 seasons in 60 days, got %d and %d", weeks, seasons)
+	}
+
+	state := engine.GetState()
+	if state.Year != 1 || state.Season != 0 || state.Day != 1 {
+		t.Errorf("Expected a 60-day year, got day %d of season %d of year %d", state.Day, state.Season, state.Year)
+	}
+	if state.GetElapsedDays() != 60 || state.ElapsedDisplay() != "1y 0d" {
+		t.Errorf("Expected a year elapsed, got %d days (%s)", state.GetElapsedDays(), state.ElapsedDisplay())
+	}
+	state.AdvanceToNextSeason()
+	state.AdvanceToNextSeason()
+	if state.WeekInSeason() != 1 || state.DateDisplay() != "Day 1, Frost, Year 1" {
+		t.Errorf("Expected the first week of Frost, got week %d of %s", state.WeekInSeason(), state.DateDisplay())
+	}
+}
+
+// TestReviewWorld tests validating and linting a world before play
+func TestReviewWorld(t *testing.T) {
+	review := ReviewWorld(createTestSchema())
+	if !review.Valid || len(review.Errors) != 0 || review.Graph == nil {
+		t.Fatalf("Expected the test world to pass with a DAG preview, got %+v", review)
+	}
+
+	schema := createTestSchema()
+	schema.Calendar = &agents.CalendarDef{DaysPerWeek: 5, DaysPerSeason: 12}
+	review = ReviewWorld(schema)
+	if review.Valid || len(review.Errors) != 1 || review.Graph != nil {
+		t.Errorf("Expected the engine to reject the calendar, got %+v", review)
+	}
+
+	schema = createTestSchema()
+	schema.NPCs = nil
+	schema.Arcs = []agents.ArcDef{{ID: "arc_empty", Title: "Empty"}}
+	schema.PlotNodes = []agents.PlotNodeDef{
+		{ID: "start", Condition: "stats.health > 10 && tags.missing", SuccessorIDs: []string{"middle"}},
+		{ID: "middle", Condition: "stats.luck < 5", PredecessorIDs: []string{"start"}},
+	}
+	codes := make(map[string]string)
+	for _, warning := range ReviewWorld(schema).Warnings {
+		codes[warning.Code+" "+warning.Subject] = warning.Message
+	}
+	for _, want := range []string{LintNoNPCs + " ", LintDeadEnd + " middle", LintNoEnding + " ", LintEmptyArc + " arc_empty", LintUnknownState + " start", LintUnknownState + " middle"} {
+		if _, ok := codes[want]; !ok {
+			t.Errorf("Expected a %q warning, got %v", want, codes)
+		}
+	}
+	if len(codes) != 6 {
+		t.Errorf("Expected 6 warnings, got %v", codes)
+	}
+}
+
+// TestStoryReachability tests finding plot nodes and endings that can never
+// fire, and how soon the others can
+func TestStoryReachability(t *testing.T) {
+	schema := createTestSchema()
+	schema.PlotNodes = []agents.PlotNodeDef{
+		{ID: "start", Condition: "elapsed_days >= 14", SuccessorIDs: []string{"war", "peace"}},
+		{ID: "war", ExclusiveGroup: "path", SuccessorIDs: []string{"victory"}},
+		{ID: "peace", ExclusiveGroup: "path", Condition: "year >= 1", SuccessorIDs: []string{"harmony"}},
+		{ID: "victory", IsEnding: true, EndingTier: "good"},
+		{ID: "harmony", IsEnding: true, Condition: "stats.health > 100"},
+		{ID: "lost", SuccessorIDs: []string{"secret"}, Condition: "stats.mana < 0"},
+		{ID: "secret", IsEnding: true, EndingTier: "secret"},
+		{ID: "late", Condition: "day < 1", SuccessorIDs: []string{"secret"}},
+	}
+
+	review := ReviewWorld(schema)
+	want := []EndingReach{
+		{ID: "harmony", Tier: "common"},
+		{ID: "secret", Tier: "secret"},
+		{ID: "victory", Tier: "good", Reachable: true, MinWeeks: 4},
+	}
+	if !reflect.DeepEqual(review.Endings, want) {
+		t.Errorf("Expected endings %+v, got %+v", want, review.Endings)
+	}
+
+	codes := make(map[string]bool)
+	for _, warning := range review.Warnings {
+		codes[warning.Code+" "+warning.Subject] = true
+	}
+	for _, code := range []string{LintUnreachableEnding + " harmony", LintUnreachableEnding + " secret", LintUnsatisfiable + " lost", LintUnsatisfiable + " late"} {
+		if !codes[code] {
+			t.Errorf("Expected a %q warning, got %v", code, codes)
+		}
+	}
+	if codes[LintNoReachableEnding+" "] || codes[LintUnreachable+" victory"] {
+		t.Errorf("Expected victory to be reachable, got %v", codes)
+	}
+
+	// A 5-day week needs an extra week for the first beat, and a year of
+	// 60 days brings peace in week 12
+	schema.Calendar = &agents.CalendarDef{DaysPerWeek: 5, DaysPerSeason: 15}
+	reach := analyzeStory(schema, reviewCalendar(schema))
+	if reach.minWeeks["start"] != 3 || reach.minWeeks["peace"] != 12 || reach.minWeeks["victory"] != 5 {
+		t.Errorf("Expected start, peace and victory in weeks 3, 12 and 5, got %v", reach.minWeeks)
+	}
+
+	// Without the war, no ending is left
+	schema.PlotNodes[1].Condition = "stats.health < 0"
+	found := false
+	for _, warning := range LintWorld(schema) {
+		found = found || warning.Code == LintNoReachableEnding
+	}
+	if !found {
+		t.Errorf("Expected no reachable ending, got %+v", LintWorld(schema))
+	}
+}
+
+// TestConditionDiagnostics tests flagging conditions that can never hold or
+// name what the world lacks
+func TestConditionDiagnostics(t *testing.T) {
+	schema := createTestSchema()
+	schema.PlotNodes = []agents.PlotNodeDef{
+		{ID: "start", Condition: `stats.health > 150 || "ghost" in tags`, SuccessorIDs: []string{"end"}},
+		{ID: "end", IsEnding: true, Condition: "stats.mana > 60 && stats.mana < 40 && season < 4",
+			Deadline: &agents.DeadlineDef{Week: 3, Action: "loosen", FallbackCondition: "day > 28"}},
+	}
+	schema.PressureRules = []agents.PressureRuleDef{{ID: "famine", Condition: "stats.food < 10", Calls: []agents.FunctionCall{{Name: "update_stat", Params: map[string]interface{}{"stat_id": "health", "delta": -1}}}}}
+
+	got := make([]LintWarning, 0)
+	for _, warning := range LintWorld(schema) {
+		if warning.Field != "" {
+			warning.Message = ""
+			got = append(got, warning)
+		}
+	}
+	want := []LintWarning{
+		{Code: LintUnknownState, Subject: "start", Field: FieldPlotCondition, Path: "tags.ghost"},
+		{Code: LintOutOfRange, Subject: "start", Field: FieldPlotCondition, Path: "stats.health", Expr: "stats.health > 150"},
+		{Code: LintContradiction, Subject: "end", Field: FieldPlotCondition, Path: "stats.mana"},
+		{Code: LintOutOfRange, Subject: "end", Field: FieldPlotFallback, Path: "day", Expr: "day > 28"},
+		{Code: LintUnknownState, Subject: "famine", Field: FieldPressureCondition, Path: "stats.food"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected diagnostics %+v, got %+v", want, got)
+	}
+}
+
+// TestDrawModes tests dealing the week at once and a card a day
+func TestDrawModes(t *testing.T) {
+	fill := func(engine *GameEngine, n int) {
+		for i := 0; i < n; i++ {
+			engine.deck.Insert(&cards.InfoCard{ID: fmt.Sprintf("card_%d", i), Title: "Test", Source: "test", Priority: cards.PriorityCommon})
+		}
+	}
+
+	week, _ := NewGameEngine("test-game", createTestSchema())
+	if week.GetDrawMode() != DrawModeWeek {
+		t.Fatalf("Expected the week mode by default, got %q", week.GetDrawMode())
+	}
+	fill(week, 3)
+	hand, _ := week.DrawCards(7)
+	if len(hand) != 3 {
+		t.Fatalf("Expected the whole hand, got %d cards", len(hand))
+	}
+	week.ResolveCard(hand[0].GetID(), "left")
+	if days := week.GetState().GetElapsedDays(); days != 0 {
+		t.Errorf("Expected days to pass only with the week, got %d", days)
+	}
+
+	daily, _ := NewGameEngine("test-game", createTestSchema())
+	daily.SetDrawMode(DrawModeDaily)
+	fill(daily, DaysPerWeek)
+	for day := 1; day <= DaysPerWeek; day++ {
+		drawn, err := daily.DrawCards(7)
+		if err != nil || len(drawn) != 1 {
+			t.Fatalf("Day %d: expected one card, got %d (%v)", day, len(drawn), err)
+		}
+		again, _ := daily.DrawCards(7)
+		if len(again) != 1 || again[0].GetID() != drawn[0].GetID() {
+			t.Fatalf("Day %d: expected the unresolved card again", day)
+		}
+		if _, err := daily.ResolveCard(drawn[0].GetID(), "left"); err != nil {
+			t.Fatalf("Day %d: failed to resolve: %v", day, err)
+		}
+		if days := daily.GetState().GetElapsedDays(); days != day {
+			t.Fatalf("Expected %d days to have passed, got %d", day, days)
+		}
+	}
+	if _, err := daily.DrawCards(7); !errors.Is(err, ErrWeekOver) {
+		t.Errorf("Expected ErrWeekOver after the last day, got %v", err)
+	}
+	if !daily.IsWeekOver() {
+		t.Error("Expected the week to be over")
+	}
+
+	if err := daily.AdvanceWeek(); err != nil {
+		t.Fatalf("Failed to advance week: %v", err)
+	}
+	state := daily.GetState()
+	if state.GetElapsedDays() != DaysPerWeek || state.DaysPlayed != 0 {
+		t.Errorf("Expected advancing to pass no played days again, got %d days and %d played", state.GetElapsedDays(), state.DaysPlayed)
+	}
+	if chronicle := daily.DrawCard(); chronicle == nil || chronicle.GetID() != "chronicle_week_1" {
+		t.Errorf("Expected the chronicle of week 1, got %v", chronicle)
+	}
+
+	// Days left unplayed pass with the week
+	fill(daily, 2)
+	drawn, _ := daily.DrawCards(7)
+	daily.ResolveCard(drawn[0].GetID(), "left")
+	daily.AdvanceWeek()
+	if days := daily.GetState().GetElapsedDays(); days != 2*DaysPerWeek {
+		t.Errorf("Expected two weeks to have passed, got %d days", days)
+	}
+
+	if _, err := ParseDrawMode("hourly"); err == nil {
+		t.Error("Expected an unknown draw mode to be rejected")
+	}
+}
+
+// TestRevealWindows tests that plot cards are dealt within their days of
+// the week, early only when nothing else is left
+func TestRevealWindows(t *testing.T) {
+	engine, _ := NewGameEngine("test-game", createTestSchema())
+	defs := []map[string]interface{}{
+		{"id": "coup", "title": "Coup", "source": "plot", "priority": float64(cards.PriorityPlot), "reveal_window": map[string]interface{}{"latest": float64(2)}},
+		{"id": "wedding", "title": "Wedding", "source": "plot", "priority": float64(cards.PriorityPlot), "reveal_window": map[string]interface{}{"earliest": float64(6)}},
+		{"id": "omen", "title": "Omen", "source": "plot", "priority": float64(cards.PriorityPlot), "reveal_window": map[string]interface{}{"earliest": float64(5), "latest": float64(3)}},
+	}
+	for i := 0; i < 4; i++ {
+		defs = append(defs, map[string]interface{}{"id": fmt.Sprintf("common_%d", i), "title": "Test", "source": "common"})
+	}
+	if n := engine.AddCardsFromDefs(defs); n != len(defs) {
+		t.Fatalf("Expected %d cards, got %d", len(defs), n)
+	}
+	if omen := engine.FindCard("omen").(*cards.InfoCard); omen.RevealWindow != nil {
+		t.Errorf("Expected a window closing before it opens to be dropped, got %+v", omen.RevealWindow)
+	}
+
+	hand, _ := engine.DrawCards(7)
+	days := make(map[string]int)
+	for i, card := range hand {
+		days[card.GetID()] = i + 1
+	}
+	if days["coup"] == 0 || days["coup"] > 2 {
+		t.Errorf("Expected the coup by day 2, got day %d", days["coup"])
+	}
+	if days["wedding"] < 6 {
+		t.Errorf("Expected the wedding from day 6, got day %d", days["wedding"])
+	}
+
+	// A held-back card is dealt early rather than leaving a day empty
+	daily, _ := NewGameEngine("test-game", createTestSchema())
+	daily.SetDrawMode(DrawModeDaily)
+	daily.AddCardsFromDefs(defs[1:2])
+	drawn, err := daily.DrawCards(1)
+	if err != nil || len(drawn) != 1 || drawn[0].GetID() != "wedding" {
+		t.Errorf("Expected the wedding on day 1 with nothing else to deal, got %v (%v)", drawn, err)
+	}
+}
+
+// TestPhaseInterruptions tests that a phase begun by a choice deals its
+// card at once, ahead of the rest of the hand
+func TestPhaseInterruptions(t *testing.T) {
+	engine, _ := NewGameEngine("test-game", createTestSchema())
+	engine.SetDrawMode(DrawModeDaily)
+	advance := []interface{}{map[string]interface{}{"name": "advance_phase", "params": map[string]interface{}{"event_id": "siege"}}}
+	engine.state.AddEvent(&PhaseEvent{
+		BaseEvent: BaseEvent{ID: "siege", Name: "Siege"},
+		Phases: []EventPhase{
+			{Name: "Rumours"},
+			{Name: "Assault", Interrupt: &InterruptDef{
+				Title:       "{event}: {phase}!",
+				Description: "After the {previous_phase}, the walls are breached",
+				LeftChoice:  map[string]interface{}{"label": "Hold the gate", "calls": advance},
+				RightChoice: map[string]interface{}{"label": "Flee the {event}"},
+			}},
+			{Name: "Aftermath", Description: "Smoke hangs over the city", Interrupt: &InterruptDef{}},
+		},
+	})
+	engine.AddCardsFromDefs([]map[string]interface{}{{
+		"id": "scout", "title": "Scout",
+		"left_choice":  map[string]interface{}{"label": "Sound the alarm", "calls": advance},
+		"right_choice": map[string]interface{}{"label": "Wait"},
+	}})
+
+	drawn, _ := engine.DrawCards(7)
+	result, err := engine.ResolveCard(drawn[0].GetID(), "left")
+	if err != nil {
+		t.Fatalf("Failed to resolve: %v", err)
+	}
+	if len(result.Interrupts) != 1 {
+		t.Fatalf("Expected the assault to interrupt, got %v", result.Interrupts)
+	}
+	assault, ok := result.Interrupts[0].(*cards.ChoiceCard)
+	if !ok || assault.ID != "interrupt_siege_1" || assault.Title != "Siege: Assault!" ||
+		assault.Description != "After the Rumours, the walls are breached" || assault.RightChoice.Label != "Flee the Siege" {
+		t.Fatalf("Expected the filled-in assault card, got %+v", result.Interrupts[0])
+	}
+	if hand, _ := engine.DrawCards(7); len(hand) != 1 || hand[0].GetID() != assault.ID {
+		t.Fatalf("Expected the assault to be dealt at once, got %v", hand)
+	}
+
+	days := engine.GetState().GetElapsedDays()
+	result, err = engine.ResolveCard(assault.ID, "left")
+	if err != nil {
+		t.Fatalf("Failed to resolve the assault: %v", err)
+	}
+	if engine.GetState().GetElapsedDays() != days {
+		t.Error("Expected an interruption not to take a day")
+	}
+	if len(result.Interrupts) != 1 || result.Interrupts[0].GetTitle() != "Siege: Aftermath" ||
+		result.Interrupts[0].GetDescription() != "Smoke hangs over the city" || result.Interrupts[0].IsChoiceCard() {
+		t.Errorf("Expected the default aftermath card, got %+v", result.Interrupts)
+	}
+
+	executor := cards.NewActionExecutor(engine.state)
+	if _, err := executor.Execute(map[string]interface{}{"name": "advance_phase", "params": map[string]interface{}{"event_id": "famine"}}); err == nil {
+		t.Error("Expected advancing an unknown event to fail")
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	engine, err := NewGameEngine("test-game", createTestSchema())
+	if err != nil {
+		t.Fatalf("Failed to create game engine: %v", err)
+	}
+	engine.AddCardsFromDefs([]map[string]interface{}{
+		{
+			"id":    "hurt",
+			"title": "Hurt",
+			"left_choice": map[string]interface{}{
+				"label": "Fall",
+				"calls": []interface{}{map[string]interface{}{"name": "update_stat", "params": map[string]interface{}{"stat_id": "health", "delta": float64(-5)}}},
+			},
+			"right_choice": map[string]interface{}{"label": "Wait"},
+		},
+	})
+	if err := engine.Undo(); err != ErrNothingToUndo {
+		t.Fatalf("Expected nothing to undo before any action, got %v", err)
+	}
+
+	if _, err := engine.DrawCards(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.ResolveCard("hurt", "left"); err != nil {
+		t.Fatal(err)
+	}
+	if status := engine.UndoStatus(); status.Undo != 2 || status.Redo != 0 || status.Depth != DefaultUndoDepth {
+		t.Fatalf("Expected 2 actions to undo, got %+v", status)
+	}
+
+	// Undoing the resolution gives the card back
+	version := engine.GetVersion()
+	if err := engine.Undo(); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if engine.state.Stats["health"] != 100 || len(engine.drawnCards) != 1 {
+		t.Errorf("Expected health 100 with the card in hand, got %d and %d drawn", engine.state.Stats["health"], len(engine.drawnCards))
+	}
+	if engine.GetVersion() <= version {
+		t.Errorf("Expected undo to move the version forward from %d, got %d", version, engine.GetVersion())
+	}
+
+	// and undoing the draw puts it back in the deck
+	if err := engine.Undo(); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if len(engine.drawnCards) != 0 || engine.deck.Size() != 1 {
+		t.Errorf("Expected the card back in the deck, got %d drawn and %d in the deck", len(engine.drawnCards), engine.deck.Size())
+	}
+
+	if err := engine.Redo(); err != nil {
+		t.Fatalf("Redo failed: %v", err)
+	}
+	if err := engine.Redo(); err != nil {
+		t.Fatalf("Redo failed: %v", err)
+	}
+	if engine.state.Stats["health"] != 95 || len(engine.drawnCards) != 0 {
+		t.Errorf("Expected redo to resolve the card again, got health %d and %d drawn", engine.state.Stats["health"], len(engine.drawnCards))
+	}
+	if err := engine.Redo(); err != ErrNothingToRedo {
+		t.Errorf("Expected nothing left to redo, got %v", err)
+	}
+
+	// A new action forgets what was undone
+	if err := engine.Undo(); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if _, err := engine.ResolveCard("hurt", "right"); err != nil {
+		t.Fatal(err)
+	}
+	if status := engine.UndoStatus(); status.Redo != 0 {
+		t.Errorf("Expected a new action to clear redo, got %+v", status)
+	}
+
+	// Lowering the depth drops the oldest actions, and 0 turns undo off
+	engine.SetUndoDepth(1)
+	if status := engine.UndoStatus(); status.Undo != 1 || status.Depth != 1 {
+		t.Errorf("Expected 1 action to undo at depth 1, got %+v", status)
+	}
+	engine.SetUndoDepth(0)
+	if err := engine.Undo(); err != ErrNothingToUndo {
+		t.Errorf("Expected undo off at depth 0, got %v", err)
+	}
+}
+
+// TestSeededGames tests that games with the same seed deal the same deck
+// and fire the same plot node, and that the seed changes the deal
+func TestSeededGames(t *testing.T) {
+	play := func(seed int64) (string, string) {
+		schema := createTestSchema()
+		schema.PlotNodes = append(schema.PlotNodes,
+			agents.PlotNodeDef{ID: "plot2", PlotDescription: "Another plot", Condition: "true"},
+			agents.PlotNodeDef{ID: "plot3", PlotDescription: "A third plot", Condition: "true"},
+		)
+		engine, err := NewSeededGameEngine("test-game", schema, seed)
+		if err != nil {
+			t.Fatalf("Failed to create game engine: %v", err)
+		}
+		var defs []map[string]interface{}
+		for i := 0; i < 7; i++ {
+			defs = append(defs, map[string]interface{}{"id": fmt.Sprintf("card%d", i), "title": "Card"})
+		}
+		engine.AddCardsFromDefs(defs)
+		if err := engine.checkPlotConditions(); err != nil {
+			t.Fatal(err)
+		}
+
+		drawn, err := engine.DrawCards(7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var order []string
+		for _, card := range drawn {
+			order = append(order, card.GetID())
+		}
+		return strings.Join(order, ","), engine.state.PendingPlotNodeID
+	}
+
+	deal, plot := play(42)
+	if again, againPlot := play(42); again != deal || againPlot != plot {
+		t.Errorf("Expected seed 42 to replay %s and %s, got %s and %s", deal, plot, again, againPlot)
+	}
+	deals := map[string]bool{deal: true}
+	for seed := int64(1); seed <= 5; seed++ {
+		other, _ := play(seed)
+		deals[other] = true
+	}
+	if len(deals) < 2 {
+		t.Errorf("Expected different seeds to deal differently, got only %s", deal)
 	}
 }
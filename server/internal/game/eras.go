@@ -0,0 +1,103 @@
+package game
+
+import (
+	"fmt"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/story"
+)
+
+// Era is one age of a multi-era world. When Condition holds in the previous
+// era, the world moves on: RetireNPCs leave the cast and IntroduceNPCs join.
+type Era struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Description   string   `json:"description"`
+	Condition     string   `json:"condition,omitempty"`
+	RetireNPCs    []string `json:"retire_npcs,omitempty"`
+	IntroduceNPCs []string `json:"introduce_npcs,omitempty"`
+}
+
+// newEras converts and validates schema eras
+func newEras(defs []agents.EraDef, npcs map[string]NPC) ([]Era, error) {
+	eras := make([]Era, 0, len(defs))
+	for i, def := range defs {
+		if def.ID == "" || def.Name == "" {
+			return nil, fmt.Errorf("era %d: missing id or name", i)
+		}
+		if i > 0 {
+			if def.Condition == "" {
+				return nil, fmt.Errorf("era %s: missing transition condition", def.ID)
+			}
+			if _, err := story.ConditionDependencies(def.Condition); err != nil {
+				return nil, fmt.Errorf("era %s: %w", def.ID, err)
+			}
+		}
+		for _, npcID := range append(append([]string{}, def.RetireNPCs...), def.IntroduceNPCs...) {
+			if _, ok := npcs[npcID]; !ok {
+				return nil, fmt.Errorf("era %s: unknown npc %s", def.ID, npcID)
+			}
+		}
+
+		eras = append(eras, Era{
+			ID:            def.ID,
+			Name:          def.Name,
+			Description:   def.Description,
+			Condition:     def.Condition,
+			RetireNPCs:    def.RetireNPCs,
+			IntroduceNPCs: def.IntroduceNPCs,
+		})
+	}
+	return eras, nil
+}
+
+// checkEraTransition moves the world into the next era when its condition
+// holds and queues a Writer job to narrate the change. Caller must hold e.mu.
+func (e *GameEngine) checkEraTransition() bool {
+	next := e.state.EraIndex + 1
+	if next >= len(e.state.Eras) {
+		return false
+	}
+	era := e.state.Eras[next]
+	ok, err := story.EvaluateExpression(era.Condition, e.buildConditionState())
+	if err != nil || !ok {
+		return false
+	}
+
+	e.state.EraIndex = next
+	e.state.Era = era.Name
+	for _, npcID := range era.RetireNPCs {
+		e.state.DisableNPC(npcID)
+	}
+	for _, npcID := range era.IntroduceNPCs {
+		e.state.EnableNPC(npcID)
+	}
+
+	e.jobQueue.Enqueue(&CardGenJob{
+		JobType: "era",
+		Context: map[string]interface{}{
+			"era_id":          era.ID,
+			"name":            era.Name,
+			"description":     era.Description,
+			"retired_npcs":    era.RetireNPCs,
+			"introduced_npcs": era.IntroduceNPCs,
+		},
+	})
+	return true
+}
+
+// buildEraContext describes the current era for the Writer, or nil for
+// single-era worlds
+func (e *GameEngine) buildEraContext() map[string]interface{} {
+	if len(e.state.Eras) == 0 {
+		return nil
+	}
+	era := e.state.Eras[e.state.EraIndex]
+	return map[string]interface{}{
+		"id":          era.ID,
+		"name":        era.Name,
+		"description": era.Description,
+		"number":      e.state.EraIndex + 1,
+		"total":       len(e.state.Eras),
+	}
+}
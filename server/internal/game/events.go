@@ -26,6 +26,7 @@ type Event interface {
 	GetIcon() string
 	IsFinished() bool
 	ProgressDisplay() string
+	base() *BaseEvent
 }
 
 // BaseEvent contains common event fields
@@ -40,8 +41,9 @@ type BaseEvent struct {
 
 // EventPhase represents a phase in a PhaseEvent
 type EventPhase struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Interrupt   *InterruptDef `json:"interrupt,omitempty"` // card dealt the moment the phase begins
 }
 
 // PhaseEvent progresses through named phases
@@ -80,6 +82,7 @@ func (e *BaseEvent) GetDescription() string                { return e.Descriptio
 func (e *BaseEvent) GetIcon() string                        { return e.Icon }
 func (e *BaseEvent) GetOnActionEndCalls() []map[string]interface{} { return e.OnActionEndCalls }
 func (e *BaseEvent) GetOnPhaseEndCalls() []map[string]interface{}  { return e.OnPhaseEndCalls }
+func (e *BaseEvent) base() *BaseEvent                        { return e }
 
 // Implement Event interface for PhaseEvent
 func (e *PhaseEvent) GetType() EventType { return EventTypePhase }
@@ -160,7 +163,44 @@ func (e *ConditionEvent) ProgressDisplay() string {
 	return "Active"
 }
 
-// UnmarshalEvent unmarshals JSON into the correct event type
+// MarshalJSON adds the event type so UnmarshalEvent can restore it
+func (e *PhaseEvent) MarshalJSON() ([]byte, error) {
+	type Alias PhaseEvent
+	return json.Marshal(&struct {
+		Type EventType `json:"type"`
+		*Alias
+	}{EventTypePhase, (*Alias)(e)})
+}
+
+// MarshalJSON implements json.Marshaler
+func (e *ProgressEvent) MarshalJSON() ([]byte, error) {
+	type Alias ProgressEvent
+	return json.Marshal(&struct {
+		Type EventType `json:"type"`
+		*Alias
+	}{EventTypeProgress, (*Alias)(e)})
+}
+
+// MarshalJSON implements json.Marshaler
+func (e *TimedEvent) MarshalJSON() ([]byte, error) {
+	type Alias TimedEvent
+	return json.Marshal(&struct {
+		Type EventType `json:"type"`
+		*Alias
+	}{EventTypeTimed, (*Alias)(e)})
+}
+
+// MarshalJSON implements json.Marshaler
+func (e *ConditionEvent) MarshalJSON() ([]byte, error) {
+	type Alias ConditionEvent
+	return json.Marshal(&struct {
+		Type EventType `json:"type"`
+		*Alias
+	}{EventTypeCondition, (*Alias)(e)})
+}
+
+// UnmarshalEvent unmarshals JSON into the correct event type, normalizing
+// its display fields
 func UnmarshalEvent(data []byte) (Event, error) {
 	var raw map[string]interface{}
 	if err := json.Unmarshal(data, &raw); err != nil {
@@ -172,36 +212,39 @@ func UnmarshalEvent(data []byte) (Event, error) {
 		eventType = EventType(typeStr)
 	}
 
+	var event Event
 	switch eventType {
 	case EventTypePhase:
 		var e PhaseEvent
 		if err := json.Unmarshal(data, &e); err != nil {
 			return nil, err
 		}
-		return &e, nil
+		event = &e
 	case EventTypeProgress:
 		var e ProgressEvent
 		if err := json.Unmarshal(data, &e); err != nil {
 			return nil, err
 		}
-		return &e, nil
+		event = &e
 	case EventTypeTimed:
 		var e TimedEvent
 		if err := json.Unmarshal(data, &e); err != nil {
 			return nil, err
 		}
-		return &e, nil
+		event = &e
 	case EventTypeCondition:
 		var e ConditionEvent
 		if err := json.Unmarshal(data, &e); err != nil {
 			return nil, err
 		}
-		return &e, nil
+		event = &e
 	default:
 		var e PhaseEvent
 		if err := json.Unmarshal(data, &e); err != nil {
 			return nil, err
 		}
-		return &e, nil
+		event = &e
 	}
+	normalizeEventDisplay(event)
+	return event, nil
 }
@@ -1,7 +1,9 @@
 package game
 
 import (
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 // TestPhaseEventIsFinished tests phase event completion
@@ -286,3 +288,42 @@ func TestEventInterface(t *testing.T) {
 		}
 	}
 }
+
+// TestNormalizeIcon tests that icons are reduced to the allowed set
+func TestNormalizeIcon(t *testing.T) {
+	cases := []struct {
+		icon, want string
+	}{
+		{"castle", "castle"},
+		{" :Castle: ", "castle"},
+		{"🏰", "🏰"},
+		{"❤️", "❤"},
+		{"🦄", "📜"},
+		{"<img src=x>", "📜"},
+		{"", "📜"},
+	}
+	for _, c := range cases {
+		if got := NormalizeIcon(c.icon, "📜"); got != c.want {
+			t.Errorf("NormalizeIcon(%q) = %q, want %q", c.icon, got, c.want)
+		}
+	}
+}
+
+// TestEventDisplayNormalization tests that events get a fallback icon for
+// their type and a usable name when added or loaded
+func TestEventDisplayNormalization(t *testing.T) {
+	state := &GlobalBlackboard{Events: make(map[string]Event)}
+	state.AddEvent(&TimedEvent{BaseEvent: BaseEvent{ID: "siege", Name: "   ", Icon: "a very long icon"}})
+	siege := state.GetEvent("siege")
+	if siege.GetIcon() != DefaultEventIcons[EventTypeTimed] || siege.GetName() != "siege" {
+		t.Errorf("Expected the timed fallback icon and the ID as name, got %q and %q", siege.GetIcon(), siege.GetName())
+	}
+
+	event, err := UnmarshalEvent([]byte(`{"type": "progress", "id": "harvest", "name": "` + strings.Repeat("Long ", 20) + `", "icon": "WHEAT"}`))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal event: %v", err)
+	}
+	if event.GetIcon() != "wheat" || utf8.RuneCountInString(event.GetName()) != maxEventNameLength {
+		t.Errorf("Expected a normalized icon and a shortened name, got %q and %q", event.GetIcon(), event.GetName())
+	}
+}
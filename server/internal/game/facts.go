@@ -0,0 +1,108 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Where facts come from
+const (
+	FactSourceCall  = "call"  // an add_fact call on a card or plot node
+	FactSourcePlot  = "plot"  // a plot node fired
+	FactSourceDeath = "death" // an NPC died
+)
+
+// Fact is a piece of story continuity, such as the mayor being dead or the
+// bridge destroyed. Facts are kept apart from tags: they never count as
+// tags in conditions, cards or karma.
+type Fact struct {
+	ID     string `json:"id"`
+	Text   string `json:"text,omitempty"`
+	Source string `json:"source"`
+	Day    int    `json:"day"` // elapsed days when it was established
+}
+
+// plotFactID is the fact a fired plot node establishes
+func plotFactID(nodeID string) string {
+	return "plot_" + nodeID
+}
+
+// deathFactID is the fact an NPC's death establishes
+func deathFactID(npcID string) string {
+	return npcID + "_dead"
+}
+
+// EstablishFact records a fact from an add_fact call
+func (s *GlobalBlackboard) EstablishFact(id, text string) {
+	s.establishFact(id, text, FactSourceCall)
+}
+
+// establishFact records a fact, keeping its first text when it was already
+// established
+func (s *GlobalBlackboard) establishFact(id, text, source string) {
+	if s.Facts == nil {
+		s.Facts = make(map[string]Fact)
+	}
+	if fact, ok := s.Facts[id]; ok {
+		if fact.Text == "" {
+			fact.Text = text
+			s.Facts[id] = fact
+		}
+		return
+	}
+	s.Facts[id] = Fact{ID: id, Text: text, Source: source, Day: s.GetElapsedDays()}
+	s.UpdatedAt = time.Now()
+}
+
+// RetractFact forgets a fact that no longer holds, e.g. a rebuilt bridge
+func (s *GlobalBlackboard) RetractFact(id string) {
+	delete(s.Facts, id)
+	s.UpdatedAt = time.Now()
+}
+
+// HasFact reports whether a fact is established
+func (s *GlobalBlackboard) HasFact(id string) bool {
+	_, ok := s.Facts[id]
+	return ok
+}
+
+// restoreFacts rewinds facts to those established when the loop began.
+// Deaths are not rewound, so their facts stay.
+func (s *GlobalBlackboard) restoreFacts(anchor map[string]Fact) {
+	facts := make(map[string]Fact, len(anchor))
+	for id, fact := range anchor {
+		facts[id] = fact
+	}
+	for id, fact := range s.Facts {
+		if fact.Source == FactSourceDeath {
+			facts[id] = fact
+		}
+	}
+	s.Facts = facts
+}
+
+// buildFactList returns the established facts for the Writer, oldest first
+func (e *GameEngine) buildFactList() []Fact {
+	facts := make([]Fact, 0, len(e.state.Facts))
+	for _, fact := range e.state.Facts {
+		facts = append(facts, fact)
+	}
+	sort.Slice(facts, func(i, j int) bool {
+		if facts[i].Day != facts[j].Day {
+			return facts[i].Day < facts[j].Day
+		}
+		return facts[i].ID < facts[j].ID
+	})
+	return facts
+}
+
+// notePlotFact records that a plot node fired. Caller must hold e.mu.
+func (e *GameEngine) notePlotFact(nodeID, description string) {
+	e.state.establishFact(plotFactID(nodeID), description, FactSourcePlot)
+}
+
+// noteDeathFact records that an NPC died. Caller must hold e.mu.
+func (e *GameEngine) noteDeathFact(npc NPC) {
+	e.state.establishFact(deathFactID(npc.ID), fmt.Sprintf("%s died at the age of %d", npc.Name, npc.Age), FactSourceDeath)
+}
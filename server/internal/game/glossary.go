@@ -0,0 +1,102 @@
+package game
+
+import (
+	"sort"
+)
+
+// GlossaryEntry explains one term of the world
+type GlossaryEntry struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Glossary lists the world's terms for tooltips
+type Glossary struct {
+	Stats   []GlossaryEntry `json:"stats"`
+	Tags    []GlossaryEntry `json:"tags"`
+	NPCs    []GlossaryEntry `json:"npcs"`
+	Seasons []GlossaryEntry `json:"seasons"`
+}
+
+// GetGlossary builds the glossary from the current state, so NPCs and tags
+// that join mid-game are included. Hidden stats and NPCs the player has
+// not met yet are left out.
+func (e *GameEngine) GetGlossary() *Glossary {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	glossary := &Glossary{
+		Stats:   make([]GlossaryEntry, 0, len(e.state.Stats)),
+		Tags:    make([]GlossaryEntry, 0, len(e.state.TagDefs)),
+		NPCs:    make([]GlossaryEntry, 0, len(e.state.NPCs)),
+		Seasons: make([]GlossaryEntry, 0, len(e.state.Seasons)),
+	}
+
+	for _, stat := range e.buildStatList() {
+		if hidden, _ := stat["hidden"].(bool); hidden {
+			continue
+		}
+		glossary.Stats = append(glossary.Stats, GlossaryEntry{
+			ID:          stat["id"].(string),
+			Name:        stat["name"].(string),
+			Description: stat["description"].(string),
+		})
+	}
+
+	// Defined tags first, then active tags the world never defined
+	defined := make(map[string]bool, len(e.state.TagDefs))
+	for _, def := range e.state.TagDefs {
+		entry := definitionEntry(def)
+		if entry.ID == "" {
+			continue
+		}
+		defined[entry.ID] = true
+		glossary.Tags = append(glossary.Tags, entry)
+	}
+	extra := make([]string, 0)
+	for id, active := range e.state.Tags {
+		if active && !defined[id] {
+			extra = append(extra, id)
+		}
+	}
+	sort.Strings(extra)
+	for _, id := range extra {
+		glossary.Tags = append(glossary.Tags, GlossaryEntry{ID: id, Name: id})
+	}
+
+	for _, npc := range e.state.NPCs {
+		if !npc.Enabled && !npc.Deceased && !npc.Departed && npc.AppearanceCount == 0 {
+			continue
+		}
+		glossary.NPCs = append(glossary.NPCs, GlossaryEntry{
+			ID:          npc.ID,
+			Name:        npc.Name,
+			Description: npc.Description,
+		})
+	}
+	sort.Slice(glossary.NPCs, func(i, j int) bool {
+		return glossary.NPCs[i].ID < glossary.NPCs[j].ID
+	})
+
+	for _, def := range e.state.Seasons {
+		if entry := definitionEntry(def); entry.ID != "" {
+			glossary.Seasons = append(glossary.Seasons, entry)
+		}
+	}
+
+	return glossary
+}
+
+// definitionEntry reads the id, name and description of a definition map,
+// naming it by its ID when it has no name
+func definitionEntry(def map[string]interface{}) GlossaryEntry {
+	entry := GlossaryEntry{}
+	entry.ID, _ = def["id"].(string)
+	entry.Name, _ = def["name"].(string)
+	entry.Description, _ = def["description"].(string)
+	if entry.Name == "" {
+		entry.Name = entry.ID
+	}
+	return entry
+}
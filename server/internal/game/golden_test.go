@@ -0,0 +1,209 @@
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata/golden")
+
+// checkGolden compares the indented JSON of v with testdata/golden/<name>.json
+// and returns the golden bytes
+func checkGolden(t *testing.T, name string, v interface{}) []byte {
+	t.Helper()
+
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatalf("Failed to marshal %s: %v", name, err)
+	}
+
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, append(got, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+	want = bytes.TrimSpace(want)
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s does not match %s (run go test -update if the change is intended)\ngot:\n%s", name, path, got)
+	}
+	return want
+}
+
+// checkRoundTrip re-marshals decoded golden data and compares it with the original
+func checkRoundTrip(t *testing.T, name string, want []byte, decoded interface{}) {
+	t.Helper()
+
+	got, err := json.MarshalIndent(decoded, "", "  ")
+	if err != nil {
+		t.Fatalf("Failed to re-marshal %s: %v", name, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s changed after a round trip\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+// goldenEvents returns one event of every type
+func goldenEvents() map[string]Event {
+	return map[string]Event{
+		"siege": &PhaseEvent{
+			BaseEvent: BaseEvent{
+				ID:               "siege",
+				Name:             "The Siege",
+				Description:      "Enemies surround the city",
+				Icon:             "castle",
+				OnActionEndCalls: []map[string]interface{}{{"name": "update_stat", "params": map[string]interface{}{"stat_id": "food", "delta": -2}}},
+				OnPhaseEndCalls:  []map[string]interface{}{},
+			},
+			Phases: []EventPhase{
+				{Name: "Encirclement", Description: "The walls are watched"},
+				{Name: "Assault", Description: "The walls are attacked"},
+			},
+			CurrentPhase: 1,
+		},
+		"harvest": &ProgressEvent{
+			BaseEvent: BaseEvent{
+				ID:               "harvest",
+				Name:             "Harvest",
+				Description:      "Bring in the crops",
+				Icon:             "wheat",
+				OnActionEndCalls: []map[string]interface{}{},
+				OnPhaseEndCalls:  []map[string]interface{}{},
+			},
+			Target:        10,
+			Current:       4,
+			ProgressLabel: "Sheaves",
+		},
+		"tribute": &TimedEvent{
+			BaseEvent: BaseEvent{
+				ID:               "tribute",
+				Name:             "Tribute",
+				Description:      "Pay the king before winter",
+				Icon:             "coin",
+				OnActionEndCalls: []map[string]interface{}{},
+				OnPhaseEndCalls:  []map[string]interface{}{},
+			},
+			DeadlineDay:    14,
+			DeadlineSeason: 3,
+			DeadlineYear:   1,
+		},
+		"plague": &ConditionEvent{
+			BaseEvent: BaseEvent{
+				ID:               "plague",
+				Name:             "Plague",
+				Description:      "Sickness spreads",
+				Icon:             "skull",
+				OnActionEndCalls: []map[string]interface{}{},
+				OnPhaseEndCalls:  []map[string]interface{}{},
+			},
+			EndCondition: "stats.health > 80",
+		},
+	}
+}
+
+// goldenBlackboard returns a blackboard with every field populated
+func goldenBlackboard() *GlobalBlackboard {
+	stamp := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	return &GlobalBlackboard{
+		WorldName: "Golden Realm",
+		WorldKey:  "3f2a9c",
+		Era:       "Iron Age",
+		YearStart: 812,
+		PlayerChar: PlayerCharacter{
+			ID:          "player",
+			Name:        "Ada",
+			Description: "A wandering scribe",
+		},
+		NPCs: map[string]NPC{
+			"smith": {ID: "smith", Name: "Bram", Appearance: "Soot-stained", Enabled: true, AppearanceCount: 3},
+		},
+		Stats:                map[string]int{"health": 70, "food": 35},
+		Tags:                 map[string]bool{"wounded": true},
+		Events:               goldenEvents(),
+		Day:                  9,
+		Season:               2,
+		Year:                 1,
+		StartDay:             1,
+		StartSeason:          0,
+		StartYear:            0,
+		Turn:                 3,
+		PendingPlotNodeID:    "betrayal",
+		IsAlive:              true,
+		CurrentLife:          2,
+		DeathCause:           "",
+		DeathTurn:            0,
+		Karma:                []string{"merciful"},
+		LifeNumber:           2,
+		ResurrectionMechanic: "The river returns you",
+		ResurrectionFlavor:   "Cold water fills your lungs",
+		PreviousLifeTags:     []string{"merciful", "wounded"},
+		IsFirstDayAfterDeath: false,
+		WelcomeCard:          map[string]interface{}{"id": "welcome", "title": "Welcome"},
+		RebornCard:           nil,
+		SeasonCard:           nil,
+		DeathCard:            nil,
+		PendingDeathCards:    map[string]interface{}{"health": map[string]interface{}{"id": "death_health"}},
+		Seasons: []map[string]interface{}{
+			{"id": "spring", "name": "Spring", "description": "Thaw"},
+		},
+		StatDefs: []map[string]interface{}{
+			{"id": "health", "name": "Health", "description": "How hale Ada is"},
+		},
+		TagDefs: []map[string]interface{}{
+			{"id": "wounded", "name": "Wounded", "description": "Hurt", "is_temp": true},
+		},
+		Relationships: []map[string]interface{}{
+			{"from": "player", "to": "smith", "description": "Owes a debt"},
+		},
+		Version:   17,
+		CreatedAt: stamp,
+		UpdatedAt: stamp.Add(time.Hour),
+	}
+}
+
+// TestGoldenEvents tests that every event type keeps its saved form
+func TestGoldenEvents(t *testing.T) {
+	for id, event := range goldenEvents() {
+		name := string(event.GetType()) + "_event"
+		want := checkGolden(t, name, event)
+
+		decoded, err := UnmarshalEvent(want)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal %s: %v", name, err)
+		}
+		if decoded.GetType() != event.GetType() {
+			t.Errorf("Expected %s to decode as %s, got %s", id, event.GetType(), decoded.GetType())
+		}
+		checkRoundTrip(t, name, want, decoded)
+	}
+}
+
+// TestGoldenBlackboard tests that the blackboard keeps its saved form
+func TestGoldenBlackboard(t *testing.T) {
+	want := checkGolden(t, "blackboard", goldenBlackboard())
+
+	var decoded GlobalBlackboard
+	if err := json.Unmarshal(want, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal blackboard: %v", err)
+	}
+	if len(decoded.Events) != 4 {
+		t.Errorf("Expected 4 events, got %d", len(decoded.Events))
+	}
+	if _, ok := decoded.Events["tribute"].(*TimedEvent); !ok {
+		t.Errorf("Expected tribute to decode as *TimedEvent, got %T", decoded.Events["tribute"])
+	}
+	checkRoundTrip(t, "blackboard", want, &decoded)
+}
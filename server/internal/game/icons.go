@@ -0,0 +1,98 @@
+package game
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maxEventNameLength caps event names shown in the UI, in runes
+const maxEventNameLength = 60
+
+// EventIcons maps the icon names events may use to their emoji. An event
+// icon is either a name or one of these emoji.
+var EventIcons = map[string]string{
+	"alert":     "🔔",
+	"anchor":    "⚓",
+	"book":      "📖",
+	"castle":    "🏰",
+	"chart":     "📊",
+	"clock":     "⏰",
+	"coin":      "🪙",
+	"crown":     "👑",
+	"dagger":    "🗡",
+	"dove":      "🕊",
+	"fire":      "🔥",
+	"flag":      "🚩",
+	"gear":      "⚙",
+	"gem":       "💎",
+	"heart":     "❤",
+	"hourglass": "⏳",
+	"key":       "🔑",
+	"leaf":      "🍃",
+	"lightning": "⚡",
+	"lock":      "🔒",
+	"map":       "🗺",
+	"moon":      "🌙",
+	"mountain":  "⛰",
+	"potion":    "🧪",
+	"scroll":    "📜",
+	"shield":    "🛡",
+	"ship":      "⛵",
+	"skull":     "💀",
+	"snow":      "❄",
+	"star":      "⭐",
+	"storm":     "⛈",
+	"sun":       "☀",
+	"sword":     "⚔",
+	"tent":      "⛺",
+	"wave":      "🌊",
+	"wheat":     "🌾",
+}
+
+// DefaultEventIcons are used for events without a usable icon
+var DefaultEventIcons = map[EventType]string{
+	EventTypePhase:     "📜",
+	EventTypeProgress:  "📊",
+	EventTypeTimed:     "⏰",
+	EventTypeCondition: "🔔",
+}
+
+// allowedEmoji holds the emoji of EventIcons
+var allowedEmoji = func() map[string]bool {
+	emoji := make(map[string]bool, len(EventIcons))
+	for _, e := range EventIcons {
+		emoji[e] = true
+	}
+	return emoji
+}()
+
+// NormalizeIcon returns icon as an allowed icon name or emoji, or fallback
+// when it is neither. Names are matched case-insensitively and may be
+// wrapped in colons; emoji lose their variation selectors.
+func NormalizeIcon(icon, fallback string) string {
+	icon = strings.TrimSpace(icon)
+	name := strings.ToLower(strings.Trim(icon, ":"))
+	if _, ok := EventIcons[name]; ok {
+		return name
+	}
+	emoji := strings.NewReplacer("\uFE0F", "", "\uFE0E", "").Replace(icon)
+	if allowedEmoji[emoji] {
+		return emoji
+	}
+	return fallback
+}
+
+// normalizeEventDisplay makes an event safe to display: an allowed icon or
+// its type's default, and a trimmed name that falls back to its ID
+func normalizeEventDisplay(event Event) {
+	base := event.base()
+	base.Icon = NormalizeIcon(base.Icon, DefaultEventIcons[event.GetType()])
+	base.Name = strings.TrimSpace(base.Name)
+	if base.Name == "" {
+		base.Name = base.ID
+	}
+	if utf8.RuneCountInString(base.Name) > maxEventNameLength {
+		base.Name = string([]rune(base.Name)[:maxEventNameLength-1]) + "…"
+	}
+	base.Description = strings.TrimSpace(base.Description)
+}
@@ -0,0 +1,151 @@
+package game
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
+	"github.com/qninhdt/world-card-ai-2/server/internal/death"
+)
+
+// maxInterludeCards caps the liminal cards shown between two lives
+const maxInterludeCards = 3
+
+// interludeFunctions are the calls a meta-choice may carry into the next
+// life; they shape where it starts, not the life that just ended
+var interludeFunctions = map[string]bool{
+	"update_stat": true,
+	"add_tag":     true,
+	"remove_tag":  true,
+	"reveal_stat": true,
+}
+
+// Interlude is the liminal scene between a death and the next life
+type Interlude struct {
+	Cause  string                   `json:"cause"`            // stat that ended the life
+	Cards  []map[string]interface{} `json:"cards,omitempty"`  // Writer card definitions, in order
+	Choice string                   `json:"choice,omitempty"` // label of the meta-choice taken
+	Boon   []cards.FunctionCall     `json:"boon,omitempty"`   // calls applied once the next life begins
+}
+
+// beginInterlude opens the interlude phase and asks the Writer for its cards
+func (e *GameEngine) beginInterlude(deathInfo *death.DeathInfo, boundary string) {
+	e.state.Interlude = &Interlude{Cause: deathInfo.CauseStat}
+	e.jobQueue.Enqueue(&CardGenJob{
+		JobType: "interlude",
+		Context: map[string]interface{}{
+			"cause_stat": deathInfo.CauseStat,
+			"boundary":   boundary,
+			"life":       e.state.LifeNumber,
+			"karma":      e.state.Karma,
+			"mechanic":   e.deathLoop.Mechanic().Name(),
+			"card_count": maxInterludeCards,
+		},
+	})
+}
+
+// addInterludeCard keeps a Writer interlude card for the open interlude and
+// reports whether it was accepted
+func (e *GameEngine) addInterludeCard(cardDef map[string]interface{}) bool {
+	interlude := e.state.Interlude
+	if interlude == nil || len(interlude.Cards) >= maxInterludeCards {
+		return false
+	}
+
+	card := e.convertToCard(cardDef)
+	if card == nil {
+		return false
+	}
+	if choiceCard, ok := card.(*cards.ChoiceCard); ok {
+		for _, choice := range []*cards.Choice{choiceCard.LeftChoice, choiceCard.RightChoice} {
+			if !validBoon(choice) {
+				return false
+			}
+		}
+	}
+
+	interlude.Cards = append(interlude.Cards, cardDef)
+	return true
+}
+
+// validBoon reports whether a meta-choice only uses interlude functions
+func validBoon(choice *cards.Choice) bool {
+	if choice == nil {
+		return true
+	}
+	for _, call := range choice.Calls {
+		if !interludeFunctions[call.Name] {
+			return false
+		}
+	}
+	return validBoon(choice.Unlocked)
+}
+
+// isInterludeCard reports whether a card belongs to the open interlude
+func (e *GameEngine) isInterludeCard(card *cards.ChoiceCard) bool {
+	return e.state.Interlude != nil && card.Source == "interlude"
+}
+
+// chooseBoon records the interlude's single meta-choice
+func (e *GameEngine) chooseBoon(choice *cards.Choice) error {
+	interlude := e.state.Interlude
+	if interlude.Choice != "" {
+		return fmt.Errorf("interlude choice already made: %s", interlude.Choice)
+	}
+	interlude.Choice = choice.Label
+	interlude.Boon = choice.Calls
+	return nil
+}
+
+// endInterlude closes the interlude, applying its meta-choice to the life
+// that just began
+func (e *GameEngine) endInterlude() error {
+	interlude := e.state.Interlude
+	if interlude == nil {
+		return nil
+	}
+	e.state.Interlude = nil
+
+	defer e.watchDepartures()()
+	executor := cards.NewActionExecutor(e.state)
+	for _, call := range interlude.Boon {
+		callMap := map[string]interface{}{
+			"name":   call.Name,
+			"params": call.Params,
+		}
+		if _, err := executor.Execute(callMap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DrawInterlude deals the interlude's cards, ready to resolve
+func (e *GameEngine) DrawInterlude() ([]cards.Card, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.checkActive(); err != nil {
+		return nil, err
+	}
+	if e.state.Interlude == nil {
+		return nil, fmt.Errorf("no interlude in progress")
+	}
+
+	e.drawnCards = make([]cards.Card, 0, len(e.state.Interlude.Cards))
+	for _, cardDef := range e.state.Interlude.Cards {
+		if card := e.convertToCard(cardDef); card != nil {
+			e.unlockChoices(card)
+			e.drawnCards = append(e.drawnCards, card)
+		}
+	}
+	e.state.UpdatedAt = time.Now()
+	return e.drawnCards, nil
+}
+
+// InInterlude reports whether the player is between lives
+func (e *GameEngine) InInterlude() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.state.Interlude != nil
+}
@@ -0,0 +1,138 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
+)
+
+// interruptionSource marks the cards event phases deal the moment they begin
+const interruptionSource = "interruption"
+
+// Default interruption text, for phases that only ask to interrupt
+const (
+	defaultInterruptTitle       = "{event}: {phase}"
+	defaultInterruptDescription = "{phase_description}"
+)
+
+// InterruptDef is the template of the card a phase deals when it begins,
+// ahead of the rest of the week instead of in the next Writer batch. Text,
+// character and choice labels may use {event}, {phase},
+// {phase_description} and {previous_phase}; empty text uses the defaults.
+type InterruptDef struct {
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Character   string                 `json:"character,omitempty"`
+	LeftChoice  map[string]interface{} `json:"left_choice,omitempty"` // as in Writer cards; with both, the card is a choice
+	RightChoice map[string]interface{} `json:"right_choice,omitempty"`
+}
+
+// isInterruption reports whether a card was dealt by an event phase
+func isInterruption(card cards.Card) bool {
+	return card.GetSource() == interruptionSource
+}
+
+// eventPhases records the phase of every phase event. Caller must hold
+// e.mu.
+func (e *GameEngine) eventPhases() map[string]int {
+	phases := make(map[string]int)
+	for id, event := range e.state.Events {
+		if ev, ok := event.(*PhaseEvent); ok {
+			phases[id] = ev.CurrentPhase
+		}
+	}
+	return phases
+}
+
+// queueInterruptions puts the cards of phases begun since before at the
+// front of the immediate deque. Caller must hold e.mu.
+func (e *GameEngine) queueInterruptions(before map[string]int) {
+	ids := make([]string, 0, len(before))
+	for id := range before {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	queued := make([]cards.Card, 0)
+	for _, id := range ids {
+		ev, ok := e.state.Events[id].(*PhaseEvent)
+		if !ok || ev.CurrentPhase <= before[id] || ev.IsFinished() {
+			continue
+		}
+		if card := e.interruptionCard(ev, before[id]); card != nil {
+			queued = append(queued, card)
+		}
+	}
+	for i := len(queued) - 1; i >= 0; i-- {
+		e.immediateDeque.PushFront(queued[i])
+	}
+}
+
+// drawInterruptions takes the interruption cards from the front of the
+// immediate deque. Caller must hold e.mu.
+func (e *GameEngine) drawInterruptions() []cards.Card {
+	drawn := make([]cards.Card, 0)
+	for elem := e.immediateDeque.Front(); elem != nil; elem = e.immediateDeque.Front() {
+		card := elem.Value.(cards.Card)
+		if !isInterruption(card) {
+			break
+		}
+		e.immediateDeque.Remove(elem)
+		drawn = append(drawn, card)
+	}
+	return drawn
+}
+
+// interruptionCard fills in the template of the phase ev is now in, or
+// returns nil if the phase does not interrupt. A card whose choices do not
+// validate is dealt as an info card. Caller must hold e.mu.
+func (e *GameEngine) interruptionCard(ev *PhaseEvent, previous int) cards.Card {
+	phase := ev.Phases[ev.CurrentPhase]
+	if phase.Interrupt == nil {
+		return nil
+	}
+	def := phase.Interrupt
+
+	fill := strings.NewReplacer(
+		"{event}", ev.Name,
+		"{phase}", phase.Name,
+		"{phase_description}", phase.Description,
+		"{previous_phase}", ev.Phases[previous].Name,
+	).Replace
+	text := func(template, fallback string) string {
+		if template == "" {
+			template = fallback
+		}
+		return fill(template)
+	}
+
+	cardDef := map[string]interface{}{
+		"id":          fmt.Sprintf("interrupt_%s_%d", ev.ID, ev.CurrentPhase),
+		"title":       text(def.Title, defaultInterruptTitle),
+		"description": text(def.Description, defaultInterruptDescription),
+		"character":   text(def.Character, "narrator"),
+		"source":      interruptionSource,
+		"priority":    float64(cards.PriorityEvent),
+	}
+	if def.LeftChoice != nil && def.RightChoice != nil {
+		for key, choice := range map[string]map[string]interface{}{"left_choice": def.LeftChoice, "right_choice": def.RightChoice} {
+			filled := make(map[string]interface{}, len(choice))
+			for k, v := range choice {
+				filled[k] = v
+			}
+			if label, ok := choice["label"].(string); ok {
+				filled["label"] = fill(label)
+			}
+			cardDef[key] = filled
+		}
+	}
+
+	if card := e.convertToCard(cardDef); card != nil {
+		return card
+	}
+	delete(cardDef, "left_choice")
+	delete(cardDef, "right_choice")
+	return e.convertToCard(cardDef)
+}
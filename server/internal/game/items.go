@@ -0,0 +1,113 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+)
+
+// maxItemCount is the most of one item the player can hold
+const maxItemCount = 99
+
+// newItemDefs converts and validates schema items and the counts the
+// player starts with, keyed by item ID
+func newItemDefs(defs []agents.ItemDef, initial map[string]int) (map[string]agents.ItemDef, map[string]int, error) {
+	items := make(map[string]agents.ItemDef, len(defs))
+	for _, def := range defs {
+		if def.ID == "" {
+			return nil, nil, fmt.Errorf("item with empty id")
+		}
+		if _, ok := items[def.ID]; ok {
+			return nil, nil, fmt.Errorf("item %s defined twice", def.ID)
+		}
+		items[def.ID] = def
+	}
+
+	counts := make(map[string]int, len(initial))
+	for id, count := range initial {
+		if _, ok := items[id]; !ok {
+			return nil, nil, fmt.Errorf("initial_items: unknown item %s", id)
+		}
+		if count < 0 || count > maxItemCount {
+			return nil, nil, fmt.Errorf("initial_items: %s count out of range: %d", id, count)
+		}
+		if count > 0 {
+			counts[id] = count
+		}
+	}
+	return items, counts, nil
+}
+
+// ItemCount returns how many of an item the player holds, and whether the
+// world defines the item
+func (s *GlobalBlackboard) ItemCount(id string) (int, bool) {
+	if _, ok := s.ItemDefs[id]; !ok {
+		return 0, false
+	}
+	return s.Items[id], true
+}
+
+// GiveItem adds to the player's count of an item, up to maxItemCount
+func (s *GlobalBlackboard) GiveItem(id string, count int) {
+	if s.Items == nil {
+		s.Items = make(map[string]int)
+	}
+	s.Items[id] = min(s.Items[id]+count, maxItemCount)
+	s.UpdatedAt = time.Now()
+}
+
+// RemoveItem takes from the player's count of an item; the count does not
+// go below zero and an item the player no longer holds is dropped
+func (s *GlobalBlackboard) RemoveItem(id string, count int) {
+	if s.Items[id] <= count {
+		delete(s.Items, id)
+	} else {
+		s.Items[id] -= count
+	}
+	s.UpdatedAt = time.Now()
+}
+
+// ClearItems drops every item the player holds
+func (s *GlobalBlackboard) ClearItems() {
+	s.Items = nil
+	s.UpdatedAt = time.Now()
+}
+
+// GetItems returns a copy of the item counts
+func (s *GlobalBlackboard) GetItems() map[string]int {
+	items := make(map[string]int, len(s.Items))
+	for id, count := range s.Items {
+		items[id] = count
+	}
+	return items
+}
+
+// hasItem backs has_item in conditions: whether the player holds at least
+// one of an item
+func (s *GlobalBlackboard) hasItem(id string) bool {
+	return s.Items[id] > 0
+}
+
+// buildItemList returns the world's items with the counts held, sorted by
+// ID, for the Writer
+func (e *GameEngine) buildItemList() []map[string]interface{} {
+	ids := make([]string, 0, len(e.state.ItemDefs))
+	for id := range e.state.ItemDefs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	items := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		def := e.state.ItemDefs[id]
+		items = append(items, map[string]interface{}{
+			"id":          def.ID,
+			"name":        def.Name,
+			"description": def.Description,
+			"held":        e.state.Items[id],
+		})
+	}
+	return items
+}
@@ -4,7 +4,7 @@ import "container/list"
 
 // CardGenJob represents a single card generation job for the Writer
 type CardGenJob struct {
-	JobType string                 `json:"job_type"` // "plot" | "event_start" | "event_phase" | "chain" | "info"
+	JobType string                 `json:"job_type"` // "plot" | "event_start" | "event_phase" | "chain" | "info" | "interlude"
 	Context map[string]interface{} `json:"context"`  // Extra context: plot description, event def, chain tag, etc.
 }
 
@@ -35,6 +35,15 @@ func (jq *JobQueue) Drain() []*CardGenJob {
 	return jobs
 }
 
+// Peek returns all pending jobs without removing them
+func (jq *JobQueue) Peek() []*CardGenJob {
+	jobs := make([]*CardGenJob, 0, jq.pending.Len())
+	for elem := jq.pending.Front(); elem != nil; elem = elem.Next() {
+		jobs = append(jobs, elem.Value.(*CardGenJob))
+	}
+	return jobs
+}
+
 // HasJobs returns true if there are pending jobs
 func (jq *JobQueue) HasJobs() bool {
 	return jq.pending.Len() > 0
@@ -55,3 +64,27 @@ func (jq *JobQueue) HasHighPriority() bool {
 	}
 	return false
 }
+
+// RequeueJobs puts previously failed jobs back into the queue
+func (e *GameEngine) RequeueJobs(jobs []*CardGenJob) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, job := range jobs {
+		e.jobQueue.Enqueue(job)
+	}
+}
+
+// PendingJobCount returns the number of queued generation jobs
+func (e *GameEngine) PendingJobCount() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.jobQueue.Count()
+}
+
+// PendingJobs returns the queued generation jobs without draining them
+func (e *GameEngine) PendingJobs() []*CardGenJob {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.jobQueue.Peek()
+}
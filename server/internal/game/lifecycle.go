@@ -0,0 +1,95 @@
+package game
+
+import (
+	"fmt"
+	"time"
+)
+
+// GameStatus is where a game is in its lifecycle
+type GameStatus string
+
+// Game statuses. Only active games can be played.
+const (
+	StatusActive   GameStatus = "active"
+	StatusPaused   GameStatus = "paused"   // set aside; resuming makes it active again
+	StatusEnded    GameStatus = "ended"    // over for good
+	StatusArchived GameStatus = "archived" // an ended game put away
+)
+
+// statusTransitions lists the statuses each status may move to
+var statusTransitions = map[GameStatus][]GameStatus{
+	StatusActive:   {StatusPaused, StatusEnded},
+	StatusPaused:   {StatusActive, StatusEnded},
+	StatusEnded:    {StatusArchived},
+	StatusArchived: {StatusEnded},
+}
+
+// StatusError is returned for actions a game's status does not allow
+type StatusError struct {
+	Status GameStatus
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("game is %s", e.Status)
+}
+
+// TransitionError is returned for a status change the lifecycle does not
+// allow
+type TransitionError struct {
+	From GameStatus
+	To   GameStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("game cannot go from %s to %s", e.From, e.To)
+}
+
+// ParseGameStatus validates a requested status
+func ParseGameStatus(status string) (GameStatus, error) {
+	if _, ok := statusTransitions[GameStatus(status)]; !ok {
+		return "", fmt.Errorf("unknown status %q", status)
+	}
+	return GameStatus(status), nil
+}
+
+// status returns the game's status, active for games saved before it
+// existed
+func (s *GlobalBlackboard) status() GameStatus {
+	if s.Status == "" {
+		return StatusActive
+	}
+	return s.Status
+}
+
+// GetStatus returns the game's status
+func (e *GameEngine) GetStatus() GameStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.state.status()
+}
+
+// SetStatus moves the game to another status, if the lifecycle allows it
+func (e *GameEngine) SetStatus(status GameStatus) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	from := e.state.status()
+	for _, to := range statusTransitions[from] {
+		if to == status {
+			e.state.Status = status
+			e.state.UpdatedAt = time.Now()
+			e.recordVersion()
+			return nil
+		}
+	}
+	return &TransitionError{From: from, To: status}
+}
+
+// checkActive rejects actions on games that are not active. Caller must
+// hold e.mu.
+func (e *GameEngine) checkActive() error {
+	if status := e.state.status(); status != StatusActive {
+		return &StatusError{Status: status}
+	}
+	return nil
+}
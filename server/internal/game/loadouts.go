@@ -0,0 +1,126 @@
+package game
+
+import (
+	"fmt"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
+)
+
+// loadoutBonus is the head start a stat archetype gives its stat
+const loadoutBonus = 15
+
+// Loadout is a starting archetype the player may pick for a new life
+type Loadout struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Stats       map[string]int `json:"stats,omitempty"` // deltas applied as the life begins
+	Tags        []string       `json:"tags,omitempty"`  // tags the life begins with
+}
+
+// newLoadouts derives starting archetypes from the schema: one favoring each
+// visible stat and one for each lasting tag
+func newLoadouts(schema *agents.WorldGenSchema) []Loadout {
+	var loadouts []Loadout
+	for _, stat := range schema.Stats {
+		if stat.Hidden {
+			continue
+		}
+		loadouts = append(loadouts, Loadout{
+			ID:          "stat_" + stat.ID,
+			Name:        stat.Name,
+			Description: fmt.Sprintf("Begin with %s +%d", stat.Name, loadoutBonus),
+			Stats:       map[string]int{stat.ID: loadoutBonus},
+		})
+	}
+	for _, tag := range schema.Tags {
+		if tag.IsTemp || tag.Knowledge {
+			continue
+		}
+		loadouts = append(loadouts, Loadout{
+			ID:          "tag_" + tag.ID,
+			Name:        tag.Name,
+			Description: tag.Description,
+			Tags:        []string{tag.ID},
+		})
+	}
+	return loadouts
+}
+
+// offerLoadouts picks two archetypes from the pool for the reborn card, the
+// first for a left swipe and the second for a right one
+func (s *GlobalBlackboard) offerLoadouts() {
+	s.Loadouts = nil
+	if len(s.LoadoutPool) < 2 {
+		return
+	}
+
+	first := int(s.Roll() * float64(len(s.LoadoutPool)))
+	second := int(s.Roll() * float64(len(s.LoadoutPool)-1))
+	if second >= first {
+		second++
+	}
+	s.Loadouts = []Loadout{s.LoadoutPool[first], s.LoadoutPool[second]}
+}
+
+// loadoutCard builds the reborn card that exposes the offered loadouts as
+// its two choices; the resurrect call carries the swipe
+func (e *GameEngine) loadoutCard() cards.Card {
+	if len(e.state.Loadouts) != 2 {
+		return nil
+	}
+	return &cards.ChoiceCard{
+		ID:          fmt.Sprintf("reborn_loadout_%d", e.state.LifeNumber+1),
+		Title:       "Who Will You Be?",
+		Description: "Another life waits. Choose how it begins.",
+		Character:   "narrator",
+		Source:      "reborn",
+		Priority:    5,
+		LeftChoice:  &cards.Choice{Label: e.state.Loadouts[0].Name},
+		RightChoice: &cards.Choice{Label: e.state.Loadouts[1].Name},
+	}
+}
+
+// pickLoadout returns the offered loadout for a reborn card swipe; an empty
+// direction picks none
+func (s *GlobalBlackboard) pickLoadout(direction string) (*Loadout, error) {
+	if direction == "" {
+		return nil, nil
+	}
+	if len(s.Loadouts) != 2 {
+		return nil, fmt.Errorf("no loadouts offered")
+	}
+	switch direction {
+	case "left":
+		return &s.Loadouts[0], nil
+	case "right":
+		return &s.Loadouts[1], nil
+	}
+	return nil, fmt.Errorf("invalid direction: %s", direction)
+}
+
+// applyLoadout starts the new life with a loadout's modifiers. Stats stay
+// off the deadly bounds so no life begins dead.
+func (s *GlobalBlackboard) applyLoadout(loadout *Loadout) {
+	s.Loadouts = nil
+	if loadout == nil {
+		return
+	}
+	for id, delta := range loadout.Stats {
+		if _, ok := s.Stats[id]; !ok {
+			continue
+		}
+		value := s.Stats[id] + delta
+		if value < 1 {
+			value = 1
+		}
+		if value > 99 {
+			value = 99
+		}
+		s.SetStat(id, value)
+	}
+	for _, id := range loadout.Tags {
+		s.AddTag(id)
+	}
+}
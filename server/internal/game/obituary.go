@@ -0,0 +1,133 @@
+package game
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
+	"github.com/qninhdt/world-card-ai-2/server/internal/death"
+)
+
+// maxLifeLog caps the choices remembered for the current life's obituary
+const maxLifeLog = 30
+
+// LifeEntry is one choice made during the current life
+type LifeEntry struct {
+	CardID      string         `json:"card_id"`
+	Title       string         `json:"title"`
+	Choice      string         `json:"choice"`
+	Direction   string         `json:"direction,omitempty"`    // "left" or "right"
+	StatChanges map[string]int `json:"stat_changes,omitempty"` // visible stats the choice moved
+	Day         int            `json:"day"`
+	Season      int            `json:"season"`
+	Year        int            `json:"year_in_game"`
+}
+
+// LogChoice appends a resolved choice to the life and week logs
+func (s *GlobalBlackboard) LogChoice(card cards.Card, choice *cards.Choice, direction string) {
+	s.LifeLog = append(s.LifeLog, LifeEntry{
+		CardID:    card.GetID(),
+		Title:     card.GetTitle(),
+		Choice:    choice.Label,
+		Direction: direction,
+		Day:       s.Day,
+		Season:    s.Season,
+		Year:      s.Year,
+	})
+	s.WeekLog = append(s.WeekLog, s.LifeLog[len(s.LifeLog)-1])
+	if len(s.LifeLog) > maxLifeLog {
+		s.LifeLog = append([]LifeEntry(nil), s.LifeLog[len(s.LifeLog)-maxLifeLog:]...)
+	}
+	if len(s.WeekLog) > maxLifeLog {
+		s.WeekLog = append([]LifeEntry(nil), s.WeekLog[len(s.WeekLog)-maxLifeLog:]...)
+	}
+}
+
+// logChoiceEffects records the visible stat changes of the choice last
+// logged
+func (s *GlobalBlackboard) logChoiceEffects(statChanges map[string]int) {
+	changes := make(map[string]int, len(statChanges))
+	for id, delta := range statChanges {
+		if delta != 0 && !s.HiddenStats[id] {
+			changes[id] = delta
+		}
+	}
+	if len(changes) == 0 {
+		return
+	}
+	if n := len(s.LifeLog); n > 0 {
+		s.LifeLog[n-1].StatChanges = changes
+	}
+	if n := len(s.WeekLog); n > 0 {
+		s.WeekLog[n-1].StatChanges = changes
+	}
+}
+
+// recordDeath keeps the death for the obituary, without hidden stats, and
+// asks the Writer to sum up the life that ended
+func (e *GameEngine) recordDeath(deathInfo *death.DeathInfo, boundary string) {
+	record := *deathInfo
+	record.LifeNumber = e.state.LifeNumber
+	record.Stats = make(map[string]int, len(deathInfo.Stats))
+	for id, value := range deathInfo.Stats {
+		if !e.state.HiddenStats[id] {
+			record.Stats[id] = value
+		}
+	}
+	e.state.LastDeath = &record
+	e.state.logDeath()
+
+	tags := make([]string, 0, len(e.state.Tags))
+	for id, active := range e.state.Tags {
+		if active {
+			tags = append(tags, id)
+		}
+	}
+	sort.Strings(tags)
+
+	choices := make([]map[string]interface{}, 0, len(e.state.LifeLog))
+	for _, entry := range e.state.LifeLog {
+		choices = append(choices, map[string]interface{}{
+			"card":   entry.Title,
+			"choice": entry.Choice,
+			"year":   entry.Year,
+			"season": entry.Season,
+		})
+	}
+	e.jobQueue.Enqueue(&CardGenJob{
+		JobType: "obituary",
+		Context: map[string]interface{}{
+			"life":       record.LifeNumber,
+			"cause_stat": record.CauseStat,
+			"boundary":   boundary,
+			"stats":      record.Stats,
+			"tags":       tags,
+			"choices":    choices,
+		},
+	})
+}
+
+// addObituary stores the Writer's obituary with the last death and shows it
+// after the death card, reporting whether it was accepted
+func (e *GameEngine) addObituary(cardDef map[string]interface{}) bool {
+	if e.state.LastDeath == nil || e.state.LastDeath.Obituary != "" {
+		return false
+	}
+	card, ok := e.convertToCard(cardDef).(*cards.InfoCard)
+	if !ok || card.Description == "" {
+		return false
+	}
+	e.state.LastDeath.Obituary = card.Description
+
+	// Attach to the death card while it waits to be drawn
+	for elem := e.immediateDeque.Front(); elem != nil; elem = elem.Next() {
+		if deathCard, ok := elem.Value.(*cards.InfoCard); ok && strings.HasPrefix(deathCard.ID, "death_") {
+			deathCard.NextCards = append(deathCard.NextCards, card)
+			return true
+		}
+	}
+	if e.awaitingResurrection {
+		e.immediateDeque.PushFront(card)
+	}
+	return true
+}
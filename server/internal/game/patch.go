@@ -0,0 +1,260 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// patchableFields are the blackboard fields a state patch may touch. The
+// rest (definitions, RNG, death bookkeeping) only change through play.
+var patchableFields = map[string]bool{
+	"stats":                true,
+	"hidden_stats":         true,
+	"tags":                 true,
+	"items":                true,
+	"resources":            true,
+	"npcs":                 true,
+	"day":                  true,
+	"season":               true,
+	"year_in_game":         true,
+	"pending_plot_node_id": true,
+}
+
+// patchableNPCFields are the NPC fields a state patch may touch
+var patchableNPCFields = map[string]bool{
+	"enabled":          true,
+	"age":              true,
+	"deceased":         true,
+	"appearance_count": true,
+	"affinity":         true,
+	"departed":         true,
+}
+
+// PatchError reports a state patch that was rejected; nothing was applied
+type PatchError struct {
+	Field  string
+	Reason string
+}
+
+func (e *PatchError) Error() string {
+	if e.Field == "" {
+		return e.Reason
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+// PatchState applies a JSON merge patch (RFC 7396) to the whitelisted
+// blackboard fields, validating the result. It returns the top-level fields
+// the patch touched. On error the state is unchanged.
+func (e *GameEngine) PatchState(patch []byte) ([]string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil || fields == nil {
+		return nil, &PatchError{Field: "", Reason: "patch must be a JSON object"}
+	}
+
+	changed := make([]string, 0, len(fields))
+	for field, raw := range fields {
+		if !patchableFields[field] {
+			return nil, &PatchError{Field: field, Reason: "field is not patchable"}
+		}
+		if field == "npcs" {
+			if err := checkNPCPatch(raw); err != nil {
+				return nil, err
+			}
+		}
+		changed = append(changed, field)
+	}
+	sort.Strings(changed)
+
+	var changes map[string]interface{}
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return nil, &PatchError{Field: "", Reason: err.Error()}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// Patch a JSON copy so a rejected patch leaves the state alone
+	data, err := json.Marshal(e.state)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	for field, value := range changes {
+		doc[field] = mergePatch(doc[field], value)
+	}
+
+	data, err = json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	patched := &GlobalBlackboard{}
+	if err := json.Unmarshal(data, patched); err != nil {
+		return nil, &PatchError{Field: "", Reason: err.Error()}
+	}
+	if err := e.validatePatched(patched); err != nil {
+		return nil, err
+	}
+
+	// Copy back only the patchable fields; the rest of the copy went
+	// through JSON and is not trusted to round-trip
+	defer e.recordVersion()
+	e.state.Stats = patched.Stats
+	e.state.HiddenStats = patched.HiddenStats
+	e.state.Tags = patched.Tags
+	if e.state.Tags == nil {
+		e.state.Tags = make(map[string]bool)
+	}
+	e.state.Items = patched.Items
+	e.state.Resources = patched.Resources
+	e.state.NPCs = patched.NPCs
+	e.state.Day = patched.Day
+	e.state.Season = patched.Season
+	e.state.Year = patched.Year
+	e.state.PendingPlotNodeID = patched.PendingPlotNodeID
+	e.state.syncTurn()
+	e.state.UpdatedAt = time.Now()
+	return changed, nil
+}
+
+// mergePatch applies an RFC 7396 merge patch to a decoded JSON value
+func mergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = make(map[string]interface{})
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+	return targetObj
+}
+
+// checkNPCPatch rejects NPC patches that add, remove or rename NPCs or touch
+// fields other than patchableNPCFields
+func checkNPCPatch(raw json.RawMessage) error {
+	var npcs map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &npcs); err != nil || npcs == nil {
+		return &PatchError{Field: "npcs", Reason: "must map NPC IDs to objects"}
+	}
+	for id, fields := range npcs {
+		if fields == nil {
+			return &PatchError{Field: "npcs." + id, Reason: "NPCs cannot be removed"}
+		}
+		for field := range fields {
+			if !patchableNPCFields[field] {
+				return &PatchError{Field: "npcs." + id + "." + field, Reason: "field is not patchable"}
+			}
+		}
+	}
+	return nil
+}
+
+// validatePatched checks a patched copy of the blackboard against the live
+// one. Caller must hold e.mu.
+func (e *GameEngine) validatePatched(patched *GlobalBlackboard) error {
+	for id, value := range patched.Stats {
+		if _, ok := e.state.Stats[id]; !ok {
+			return &PatchError{Field: "stats." + id, Reason: "unknown stat"}
+		}
+		if value < 0 || value > 100 {
+			return &PatchError{Field: "stats." + id, Reason: "must be between 0 and 100"}
+		}
+	}
+	for id := range e.state.Stats {
+		if _, ok := patched.Stats[id]; !ok {
+			return &PatchError{Field: "stats." + id, Reason: "stats cannot be removed"}
+		}
+	}
+
+	for id, hidden := range patched.HiddenStats {
+		if _, ok := e.state.Stats[id]; !ok {
+			return &PatchError{Field: "hidden_stats." + id, Reason: "unknown stat"}
+		}
+		if !hidden {
+			return &PatchError{Field: "hidden_stats." + id, Reason: "use null to reveal a stat"}
+		}
+	}
+	if len(patched.HiddenStats) == 0 {
+		patched.HiddenStats = nil
+	}
+
+	known := e.knownTags()
+	for id, active := range patched.Tags {
+		if !known[id] {
+			return &PatchError{Field: "tags." + id, Reason: "unknown tag"}
+		}
+		if !active {
+			return &PatchError{Field: "tags." + id, Reason: "use null to remove a tag"}
+		}
+	}
+
+	for id, count := range patched.Items {
+		if _, ok := e.state.ItemDefs[id]; !ok {
+			return &PatchError{Field: "items." + id, Reason: "unknown item"}
+		}
+		if count < 1 || count > maxItemCount {
+			return &PatchError{Field: "items." + id, Reason: fmt.Sprintf("must be between 1 and %d; use null to remove an item", maxItemCount)}
+		}
+	}
+	if len(patched.Items) == 0 {
+		patched.Items = nil
+	}
+
+	for id, balance := range patched.Resources {
+		if _, ok := e.state.ResourceDefs[id]; !ok {
+			return &PatchError{Field: "resources." + id, Reason: "unknown resource"}
+		}
+		if balance < 0 || balance > maxResourceBalance {
+			return &PatchError{Field: "resources." + id, Reason: fmt.Sprintf("must be between 0 and %d", maxResourceBalance)}
+		}
+	}
+	if len(patched.Resources) == 0 {
+		patched.Resources = nil
+	}
+
+	for id, npc := range patched.NPCs {
+		if _, ok := e.state.NPCs[id]; !ok {
+			return &PatchError{Field: "npcs." + id, Reason: "unknown NPC"}
+		}
+		if npc.Age < 0 || npc.AppearanceCount < 0 {
+			return &PatchError{Field: "npcs." + id, Reason: "age and appearance_count cannot be negative"}
+		}
+		if npc.Deceased && npc.Enabled {
+			return &PatchError{Field: "npcs." + id, Reason: "a deceased NPC cannot be enabled"}
+		}
+		if npc.Departed && npc.Enabled {
+			return &PatchError{Field: "npcs." + id, Reason: "a departed NPC cannot be enabled"}
+		}
+		if npc.Affinity < minAffinity || npc.Affinity > maxAffinity {
+			return &PatchError{Field: "npcs." + id, Reason: fmt.Sprintf("affinity must be between %d and %d", minAffinity, maxAffinity)}
+		}
+	}
+
+	calendar := patched.calendar()
+	if patched.Day < 1 || patched.Day > calendar.DaysPerSeason {
+		return &PatchError{Field: "day", Reason: fmt.Sprintf("must be between 1 and %d", calendar.DaysPerSeason)}
+	}
+	if patched.Season < 0 || patched.Season >= calendar.SeasonsPerYear {
+		return &PatchError{Field: "season", Reason: fmt.Sprintf("must be between 0 and %d", calendar.SeasonsPerYear-1)}
+	}
+	if patched.Year < 0 {
+		return &PatchError{Field: "year_in_game", Reason: "cannot be negative"}
+	}
+	if patched.PendingPlotNodeID != "" && e.dag.GetNode(patched.PendingPlotNodeID) == nil {
+		return &PatchError{Field: "pending_plot_node_id", Reason: "unknown plot node"}
+	}
+	return nil
+}
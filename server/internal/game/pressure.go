@@ -0,0 +1,117 @@
+package game
+
+import (
+	"fmt"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
+	"github.com/qninhdt/world-card-ai-2/server/internal/death"
+	"github.com/qninhdt/world-card-ai-2/server/internal/story"
+)
+
+// Pressure rule intervals
+const (
+	PressureEveryDay  = "day"
+	PressureEveryWeek = "week"
+)
+
+// PressureRule links stats: while Condition holds, Calls run once per
+// Interval as days advance (e.g. health below 20 drains morale each week)
+type PressureRule struct {
+	ID          string               `json:"id"`
+	Description string               `json:"description"`
+	Condition   string               `json:"condition"`
+	Calls       []cards.FunctionCall `json:"calls"`
+	Interval    string               `json:"interval"`
+}
+
+// newPressureRules converts and validates schema pressure rules
+func newPressureRules(defs []agents.PressureRuleDef) ([]PressureRule, error) {
+	rules := make([]PressureRule, 0, len(defs))
+	for _, def := range defs {
+		interval := def.Interval
+		if interval == "" {
+			interval = PressureEveryWeek
+		}
+		if interval != PressureEveryDay && interval != PressureEveryWeek {
+			return nil, fmt.Errorf("pressure rule %s: invalid interval %q", def.ID, def.Interval)
+		}
+		if def.Condition == "" {
+			return nil, fmt.Errorf("pressure rule %s: missing condition", def.ID)
+		}
+		if _, err := story.ConditionDependencies(def.Condition); err != nil {
+			return nil, fmt.Errorf("pressure rule %s: %w", def.ID, err)
+		}
+
+		calls := make([]cards.FunctionCall, 0, len(def.Calls))
+		for _, call := range def.Calls {
+			calls = append(calls, cards.FunctionCall{Name: call.Name, Params: call.Params})
+		}
+		rules = append(rules, PressureRule{
+			ID:          def.ID,
+			Description: def.Description,
+			Condition:   def.Condition,
+			Calls:       calls,
+			Interval:    interval,
+		})
+	}
+	return rules, nil
+}
+
+// advanceDay advances one day, ages NPCs at the turn of the year, moves the
+// time-loop anchor when a season is survived, applies pressure rules that
+// are due and checks for an era transition. Caller must hold e.mu.
+func (e *GameEngine) advanceDay() {
+	oldYear, oldSeason := e.state.Year, e.state.Season
+	e.state.AdvanceDay()
+	if e.state.Year != oldYear {
+		e.ageNPCs()
+	}
+	// A survived season becomes the new point time loops return to
+	if e.state.Season != oldSeason && e.state.ResurrectionMechanic == death.MechanicTimeLoop {
+		e.state.MarkLoopStart()
+	}
+
+	weekStarted := (e.state.Day-1)%e.state.calendar().DaysPerWeek == 0
+	defer e.watchDepartures()()
+	for _, rule := range e.state.PressureRules {
+		if rule.Interval == PressureEveryWeek && !weekStarted {
+			continue
+		}
+		active, err := story.EvaluateExpression(rule.Condition, e.buildConditionState())
+		if err != nil || !active {
+			continue
+		}
+
+		executor := cards.NewActionExecutor(e.state)
+		for _, call := range rule.Calls {
+			callMap := map[string]interface{}{
+				"name":   call.Name,
+				"params": call.Params,
+			}
+			if _, err := executor.Execute(callMap); err != nil {
+				break
+			}
+		}
+	}
+
+	e.checkEraTransition()
+}
+
+// buildPressureRules describes the world's stat linkages for the Writer,
+// marking the rules whose condition currently holds
+func (e *GameEngine) buildPressureRules() []map[string]interface{} {
+	conditionState := e.buildConditionState()
+	rules := make([]map[string]interface{}, 0, len(e.state.PressureRules))
+	for _, rule := range e.state.PressureRules {
+		active, _ := story.EvaluateExpression(rule.Condition, conditionState)
+		rules = append(rules, map[string]interface{}{
+			"id":          rule.ID,
+			"description": rule.Description,
+			"condition":   rule.Condition,
+			"interval":    rule.Interval,
+			"active":      active,
+		})
+	}
+	return rules
+}
@@ -0,0 +1,258 @@
+package game
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/story"
+)
+
+// EndingReach is how an ending of a world can be reached
+type EndingReach struct {
+	ID        string `json:"id"`
+	Tier      string `json:"tier"`
+	Reachable bool   `json:"reachable"`
+	MinWeeks  int    `json:"min_weeks,omitempty"` // earliest week the ending can fire; 0 when unreachable
+}
+
+// storyReach is what static analysis can tell of a world's story DAG. A
+// node is fireable when some play could fire it: its condition can hold
+// and its predecessors can let it. The analysis is optimistic, so a
+// fireable node may still be hard to reach, but an unfireable one never
+// fires.
+type storyReach struct {
+	satisfiable map[string]bool // the node's condition, or its fallback, can hold
+	fireable    map[string]bool
+	minWeeks    map[string]int // earliest week a fireable node can fire
+}
+
+// nodeRoute is a condition that may fire a node from a week on
+type nodeRoute struct {
+	condition string
+	fromWeek  int
+}
+
+// analyzeStory works out which plot nodes can fire and how soon. One node
+// fires per week, after the week's days have passed, so a node comes at
+// least a week after a predecessor, and no sooner than the time its
+// condition asks for.
+func analyzeStory(schema *agents.WorldGenSchema, calendar *Calendar) *storyReach {
+	nodes := make(map[string]*agents.PlotNodeDef, len(schema.PlotNodes))
+	for i := range schema.PlotNodes {
+		nodes[schema.PlotNodes[i].ID] = &schema.PlotNodes[i]
+	}
+	preds := make(map[string][]string, len(nodes))
+	for _, node := range schema.PlotNodes {
+		for _, succID := range node.SuccessorIDs {
+			if nodes[succID] != nil {
+				preds[succID] = append(preds[succID], node.ID)
+			}
+		}
+	}
+
+	reach := &storyReach{
+		satisfiable: make(map[string]bool, len(nodes)),
+		fireable:    make(map[string]bool, len(nodes)),
+		minWeeks:    make(map[string]int, len(nodes)),
+	}
+	timeWeeks := make(map[string]int, len(nodes))
+	for id, node := range nodes {
+		timeWeeks[id] = -1
+		for _, route := range nodeRoutes(node) {
+			weeks, ok := conditionWeeks(route.condition, calendar)
+			if !ok {
+				continue
+			}
+			weeks = max(weeks, route.fromWeek)
+			if timeWeeks[id] < 0 || weeks < timeWeeks[id] {
+				timeWeeks[id] = weeks
+			}
+		}
+		reach.satisfiable[id] = timeWeeks[id] >= 0
+	}
+
+	// Firing and pruning only ever enable more nodes, so iterate to a
+	// fixpoint. A node is pruned when a node of its exclusive group fires,
+	// or when all of its predecessors are pruned.
+	prunable := make(map[string]bool, len(nodes))
+	for changed := true; changed; {
+		changed = false
+		for id, node := range nodes {
+			if !reach.fireable[id] && reach.satisfiable[id] && predecessorsCanAllow(preds[id], reach.fireable, prunable) {
+				reach.fireable[id] = true
+				changed = true
+			}
+			if !prunable[id] && canBePruned(node, preds[id], nodes, reach.fireable, prunable) {
+				prunable[id] = true
+				changed = true
+			}
+		}
+	}
+
+	// Relax the earliest weeks until they settle; each pass can only
+	// bring a week forward
+	for round := 0; round <= len(nodes); round++ {
+		changed := false
+		for id := range nodes {
+			if !reach.fireable[id] {
+				continue
+			}
+			weeks := 0
+			if len(preds[id]) == 0 {
+				weeks = timeWeeks[id]
+			} else {
+				for _, predID := range preds[id] {
+					if w, ok := reach.minWeeks[predID]; ok && (weeks == 0 || w+1 < weeks) {
+						weeks = w + 1
+					}
+				}
+				if weeks == 0 {
+					continue
+				}
+				weeks = max(weeks, timeWeeks[id])
+			}
+			if w, ok := reach.minWeeks[id]; !ok || weeks < w {
+				reach.minWeeks[id] = weeks
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	return reach
+}
+
+// endings reports every ending of the world, in ID order
+func (r *storyReach) endings(schema *agents.WorldGenSchema) []EndingReach {
+	endings := make([]EndingReach, 0)
+	for _, node := range schema.PlotNodes {
+		if !node.IsEnding {
+			continue
+		}
+		endings = append(endings, EndingReach{
+			ID:        node.ID,
+			Tier:      story.NormalizeEndingTier(node.EndingTier),
+			Reachable: r.fireable[node.ID],
+			MinWeeks:  r.minWeeks[node.ID],
+		})
+	}
+	sort.Slice(endings, func(i, j int) bool { return endings[i].ID < endings[j].ID })
+	return endings
+}
+
+// nodeRoutes returns the conditions that may fire a node: its own, and the
+// fallback a "loosen" deadline switches to once its week has passed
+func nodeRoutes(node *agents.PlotNodeDef) []nodeRoute {
+	routes := []nodeRoute{{condition: node.Condition, fromWeek: 1}}
+	if node.Deadline != nil && node.Deadline.Action == story.DeadlineActionLoosen && node.Deadline.Week > 0 {
+		routes = append(routes, nodeRoute{condition: node.Deadline.FallbackCondition, fromWeek: node.Deadline.Week + 1})
+	}
+	return routes
+}
+
+// conditionWeeks returns the earliest week a condition's time bounds allow,
+// or false when its bounds can never all hold. Conditions that do not
+// parse are left to the engine's checks.
+func conditionWeeks(condition string, calendar *Calendar) (int, bool) {
+	if condition == "" {
+		return 1, true
+	}
+	bounds, err := story.ConditionBounds(condition)
+	if err != nil {
+		return 1, true
+	}
+
+	weeks := 1
+	for path, b := range bounds {
+		domain, ok := stateDomain(path, calendar)
+		if !ok {
+			continue
+		}
+		b.Min = math.Max(b.Min, domain.Min)
+		b.Max = math.Min(b.Max, domain.Max)
+		if b.Empty() {
+			return 0, false
+		}
+
+		// Days that must pass for the path to reach its lower bound
+		days := 0.0
+		switch path {
+		case "elapsed_days":
+			days = b.Min
+		case "day":
+			days = b.Min - 1
+		case "season":
+			days = b.Min * float64(calendar.DaysPerSeason)
+		case "year":
+			days = b.Min * float64(calendar.DaysPerYear())
+		}
+		weeks = max(weeks, int(math.Ceil(days/float64(calendar.DaysPerWeek))))
+	}
+	return weeks, true
+}
+
+// stateDomain returns the values a condition state path can hold
+func stateDomain(path string, calendar *Calendar) (story.Bound, bool) {
+	switch path {
+	case "day":
+		return story.Bound{Min: 1, Max: float64(calendar.DaysPerSeason)}, true
+	case "season":
+		return story.Bound{Min: 0, Max: float64(calendar.SeasonsPerYear - 1)}, true
+	case "year", "elapsed_days", "loop":
+		return story.Bound{Min: 0, Max: math.Inf(1)}, true
+	}
+	if strings.HasPrefix(path, "stats.") {
+		return story.Bound{Min: 0, Max: 100}, true
+	}
+	if strings.HasPrefix(path, "items.") {
+		return story.Bound{Min: 0, Max: maxItemCount}, true
+	}
+	if strings.HasPrefix(path, "resources.") {
+		return story.Bound{Min: 0, Max: maxResourceBalance}, true
+	}
+	if strings.HasPrefix(path, "affinity.") {
+		return story.Bound{Min: minAffinity, Max: maxAffinity}, true
+	}
+	return story.Bound{}, false
+}
+
+// predecessorsCanAllow reports whether a node's predecessors can let it
+// fire: each can fire or be pruned, and at least one can fire
+func predecessorsCanAllow(preds []string, fireable, prunable map[string]bool) bool {
+	if len(preds) == 0 {
+		return true
+	}
+	anyFireable := false
+	for _, predID := range preds {
+		if fireable[predID] {
+			anyFireable = true
+		} else if !prunable[predID] {
+			return false
+		}
+	}
+	return anyFireable
+}
+
+// canBePruned reports whether a node can be pruned: another node of its
+// exclusive group can fire, or all of its predecessors can be pruned
+func canBePruned(node *agents.PlotNodeDef, preds []string, nodes map[string]*agents.PlotNodeDef, fireable, prunable map[string]bool) bool {
+	if node.ExclusiveGroup != "" {
+		for id, other := range nodes {
+			if id != node.ID && other.ExclusiveGroup == node.ExclusiveGroup && fireable[id] {
+				return true
+			}
+		}
+	}
+	if len(preds) == 0 {
+		return false
+	}
+	for _, predID := range preds {
+		if !prunable[predID] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,144 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+)
+
+// maxResourceBalance caps a resource so balances never overflow
+const maxResourceBalance = 1_000_000_000
+
+// newResourceDefs converts and validates schema resources, keyed by ID,
+// with the balances the player starts with. A resource may not share its ID
+// with a stat, since a bankruptcy is reported as the death's cause.
+func newResourceDefs(defs []agents.ResourceDef, stats []agents.StatDef) (map[string]agents.ResourceDef, map[string]int, error) {
+	statIDs := make(map[string]bool, len(stats))
+	for _, stat := range stats {
+		statIDs[stat.ID] = true
+	}
+	resources := make(map[string]agents.ResourceDef, len(defs))
+	balances := make(map[string]int, len(defs))
+	for _, def := range defs {
+		if def.ID == "" {
+			return nil, nil, fmt.Errorf("resource with empty id")
+		}
+		if _, ok := resources[def.ID]; ok {
+			return nil, nil, fmt.Errorf("resource %s defined twice", def.ID)
+		}
+		if statIDs[def.ID] {
+			return nil, nil, fmt.Errorf("resource %s shares its id with a stat", def.ID)
+		}
+		if def.Initial < 0 || def.Initial > maxResourceBalance {
+			return nil, nil, fmt.Errorf("resource %s initial balance out of range: %d", def.ID, def.Initial)
+		}
+		resources[def.ID] = def
+		balances[def.ID] = def.Initial
+	}
+	return resources, balances, nil
+}
+
+// ResourceBalance returns the player's balance of a resource, and whether
+// the world defines the resource
+func (s *GlobalBlackboard) ResourceBalance(id string) (int, bool) {
+	if _, ok := s.ResourceDefs[id]; !ok {
+		return 0, false
+	}
+	return s.Resources[id], true
+}
+
+// AddResource adds to a resource's balance, up to maxResourceBalance
+func (s *GlobalBlackboard) AddResource(id string, amount int) {
+	if s.Resources == nil {
+		s.Resources = make(map[string]int)
+	}
+	s.Resources[id] = min(s.Resources[id]+amount, maxResourceBalance)
+	s.UpdatedAt = time.Now()
+}
+
+// SpendResource takes from a resource's balance. Overspending a resource
+// with the bankruptcy rule leaves it below zero, which kills the player at
+// the next death check; any other resource stops at zero.
+func (s *GlobalBlackboard) SpendResource(id string, amount int) {
+	if s.Resources == nil {
+		s.Resources = make(map[string]int)
+	}
+	balance := s.Resources[id] - amount
+	if balance < 0 && !s.ResourceDefs[id].Bankruptcy {
+		balance = 0
+	}
+	s.Resources[id] = balance
+	s.UpdatedAt = time.Now()
+}
+
+// ResetResources puts every resource back at its initial balance
+func (s *GlobalBlackboard) ResetResources() {
+	if len(s.ResourceDefs) == 0 {
+		return
+	}
+	s.Resources = make(map[string]int, len(s.ResourceDefs))
+	for id, def := range s.ResourceDefs {
+		s.Resources[id] = def.Initial
+	}
+	s.UpdatedAt = time.Now()
+}
+
+// clearDebts brings resources left below zero by a bankruptcy back to
+// zero, so a new life does not start bankrupt
+func (s *GlobalBlackboard) clearDebts() {
+	for id, balance := range s.Resources {
+		if balance < 0 {
+			s.Resources[id] = 0
+		}
+	}
+}
+
+// BankruptResource returns the resource, by ID order, whose bankruptcy
+// rule the player tripped, or "" if none
+func (s *GlobalBlackboard) BankruptResource() string {
+	ids := make([]string, 0, len(s.Resources))
+	for id, balance := range s.Resources {
+		if balance < 0 {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return ""
+	}
+	sort.Strings(ids)
+	return ids[0]
+}
+
+// GetResources returns a copy of the resource balances
+func (s *GlobalBlackboard) GetResources() map[string]int {
+	resources := make(map[string]int, len(s.Resources))
+	for id, balance := range s.Resources {
+		resources[id] = balance
+	}
+	return resources
+}
+
+// buildResourceList returns the world's resources with their balances,
+// sorted by ID, for the Writer
+func (e *GameEngine) buildResourceList() []map[string]interface{} {
+	ids := make([]string, 0, len(e.state.ResourceDefs))
+	for id := range e.state.ResourceDefs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	resources := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		def := e.state.ResourceDefs[id]
+		resources = append(resources, map[string]interface{}{
+			"id":          def.ID,
+			"name":        def.Name,
+			"description": def.Description,
+			"balance":     e.state.Resources[id],
+			"bankruptcy":  def.Bankruptcy,
+		})
+	}
+	return resources
+}
@@ -0,0 +1,25 @@
+package game
+
+import (
+	"github.com/qninhdt/world-card-ai-2/server/internal/death"
+)
+
+// deathState lets resurrection mechanics reach engine-level effects, such
+// as NPC aging that queues Writer jobs
+type deathState struct {
+	*GlobalBlackboard
+	engine *GameEngine
+}
+
+// AgeYears moves the calendar forward, aging NPCs a year at a time
+func (d *deathState) AgeYears(years int) {
+	for i := 0; i < years; i++ {
+		d.engine.ageNPCs()
+	}
+	d.SetYear(d.Year + years)
+}
+
+// newDeathLoop creates the engine's death loop
+func (e *GameEngine) newDeathLoop() *death.DeathLoop {
+	return death.NewDeathLoop(&deathState{GlobalBlackboard: e.state, engine: e})
+}
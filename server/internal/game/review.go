@@ -0,0 +1,264 @@
+package game
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/story"
+)
+
+// Lint warning codes
+const (
+	LintNoEnding     = "no_ending"     // no plot node ends the story
+	LintDeadEnd      = "dead_end"      // a plot node leads nowhere and ends nothing
+	LintEmptyArc     = "empty_arc"     // an arc holds no plot nodes
+	LintUnknownState = "unknown_state" // a condition reads a stat, tag, item or resource the world lacks
+	LintNoNPCs       = "no_npcs"       // the world has no one to meet
+
+	LintOutOfRange    = "out_of_range"  // a comparison no value of its path can meet
+	LintContradiction = "contradiction" // comparisons joined by && exclude each other
+
+	LintUnsatisfiable     = "unsatisfiable"       // a plot node's condition can never hold
+	LintUnreachable       = "unreachable"         // a plot node's predecessors never let it fire
+	LintUnreachableEnding = "unreachable_ending"  // an ending can never fire
+	LintNoReachableEnding = "no_reachable_ending" // the story has endings but none can fire
+)
+
+// Fields of a world whose conditions are linted
+const (
+	FieldPlotCondition     = "plot_node.condition"
+	FieldPlotFallback      = "plot_node.deadline.fallback_condition"
+	FieldPressureCondition = "pressure_rule.condition"
+	FieldEraCondition      = "era.condition"
+)
+
+// LintWarning is a doubtful part of a world that still plays. Warnings
+// about a condition say which field of the subject holds it and which
+// state path is at fault.
+type LintWarning struct {
+	Code    string `json:"code"`
+	Subject string `json:"subject,omitempty"` // the ID it concerns
+	Field   string `json:"field,omitempty"`   // e.g. "plot_node.condition"
+	Path    string `json:"path,omitempty"`    // e.g. "stats.health"
+	Expr    string `json:"expr,omitempty"`    // the comparison at fault, as written
+	Message string `json:"message"`
+}
+
+// WorldReview is a world checked before play: the errors that keep a game
+// from starting, the warnings that do not, how its endings can be reached
+// and a preview of its story DAG
+type WorldReview struct {
+	Valid    bool                   `json:"valid"`
+	Errors   []string               `json:"errors"`
+	Warnings []LintWarning          `json:"warnings"`
+	Endings  []EndingReach          `json:"endings"`
+	Graph    map[string]interface{} `json:"graph,omitempty"` // as GET /dag shows it; only for valid worlds
+}
+
+// ReviewWorld runs every check a world must pass to be played, the
+// Architect's and the engine's, then lints it
+func ReviewWorld(schema *agents.WorldGenSchema) *WorldReview {
+	calendar := reviewCalendar(schema)
+	reach := analyzeStory(schema, calendar)
+	review := &WorldReview{Errors: make([]string, 0), Warnings: lintWorld(schema, calendar, reach), Endings: reach.endings(schema)}
+	if err := agents.ValidateWorld(schema); err != nil {
+		review.Errors = append(review.Errors, err.Error())
+	}
+	engine, err := NewSeededGameEngine("review", schema, 0)
+	if err != nil {
+		review.Errors = append(review.Errors, err.Error())
+	} else {
+		review.Graph = engine.dag.GetVisualGraph()
+	}
+	review.Valid = len(review.Errors) == 0
+	return review
+}
+
+// LintWorld returns the doubtful parts of a world, by plot node and arc in
+// schema order
+func LintWorld(schema *agents.WorldGenSchema) []LintWarning {
+	calendar := reviewCalendar(schema)
+	return lintWorld(schema, calendar, analyzeStory(schema, calendar))
+}
+
+// reviewCalendar returns the calendar a world's story is analyzed with; an
+// invalid calendar is reported by the engine's checks and the default
+// stands in
+func reviewCalendar(schema *agents.WorldGenSchema) *Calendar {
+	calendar, err := newCalendar(schema.Calendar, len(schema.Seasons))
+	if err != nil {
+		return &defaultCalendar
+	}
+	return calendar
+}
+
+// lintWorld is LintWorld with the story already analyzed
+func lintWorld(schema *agents.WorldGenSchema, calendar *Calendar, reach *storyReach) []LintWarning {
+	warnings := make([]LintWarning, 0)
+	warn := func(code, subject, format string, args ...interface{}) {
+		warnings = append(warnings, LintWarning{Code: code, Subject: subject, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(schema.NPCs) == 0 {
+		warn(LintNoNPCs, "", "the world has no NPCs, so every card is narrated")
+	}
+
+	stats := make(map[string]bool, len(schema.Stats))
+	for _, stat := range schema.Stats {
+		stats[stat.ID] = true
+	}
+	tags := make(map[string]bool, len(schema.Tags))
+	for _, tag := range schema.Tags {
+		tags[tag.ID] = true
+	}
+	items := make(map[string]bool, len(schema.Items))
+	for _, item := range schema.Items {
+		items[item.ID] = true
+	}
+	resources := make(map[string]bool, len(schema.Resources))
+	for _, resource := range schema.Resources {
+		resources[resource.ID] = true
+	}
+	npcs := make(map[string]bool, len(schema.NPCs))
+	for _, npc := range schema.NPCs {
+		npcs[npc.ID] = true
+	}
+	lintCondition := func(subject, field, condition string) {
+		warnings = append(warnings, conditionDiagnostics(subject, field, condition, stats, tags, items, resources, npcs, calendar)...)
+	}
+	arcNodes := make(map[string]int, len(schema.Arcs))
+	ending, reachableEnding := false, false
+	for _, node := range schema.PlotNodes {
+		arcNodes[node.ArcID]++
+		if node.IsEnding {
+			ending = true
+			reachableEnding = reachableEnding || reach.fireable[node.ID]
+		} else if len(node.SuccessorIDs) == 0 {
+			warn(LintDeadEnd, node.ID, "plot node %s has no successors and is not an ending", node.ID)
+		}
+		lintCondition(node.ID, FieldPlotCondition, node.Condition)
+		if node.Deadline != nil && node.Deadline.Action == story.DeadlineActionLoosen {
+			lintCondition(node.ID, FieldPlotFallback, node.Deadline.FallbackCondition)
+		}
+
+		switch {
+		case reach.fireable[node.ID]:
+		case node.IsEnding && !reach.satisfiable[node.ID]:
+			warn(LintUnreachableEnding, node.ID, "ending %s can never fire: its condition can never hold", node.ID)
+		case node.IsEnding:
+			warn(LintUnreachableEnding, node.ID, "ending %s can never fire: no path of plot nodes leads to it", node.ID)
+		case !reach.satisfiable[node.ID]:
+			warn(LintUnsatisfiable, node.ID, "plot node %s can never fire: its condition can never hold", node.ID)
+		default:
+			warn(LintUnreachable, node.ID, "plot node %s can never fire: no path of plot nodes leads to it", node.ID)
+		}
+	}
+	if len(schema.PlotNodes) > 0 && !ending {
+		warn(LintNoEnding, "", "no plot node is an ending, so the story cannot finish")
+	} else if ending && !reachableEnding {
+		warn(LintNoReachableEnding, "", "no ending can fire, so the story cannot finish")
+	}
+	for _, arc := range schema.Arcs {
+		if arcNodes[arc.ID] == 0 {
+			warn(LintEmptyArc, arc.ID, "arc %s has no plot nodes", arc.ID)
+		}
+	}
+	for _, rule := range schema.PressureRules {
+		lintCondition(rule.ID, FieldPressureCondition, rule.Condition)
+	}
+	for _, era := range schema.Eras {
+		lintCondition(era.ID, FieldEraCondition, era.Condition)
+	}
+	return warnings
+}
+
+// conditionDiagnostics checks a condition against the world: stats, tags,
+// items, resources and NPCs it names must exist, each comparison must be one some
+// value of its path can meet (stats run 0-100, dates follow the calendar), and
+// comparisons joined by && must leave some value. Conditions that do not
+// parse are left to the engine's checks.
+func conditionDiagnostics(subject, field, condition string, stats, tags, items, resources, npcs map[string]bool, calendar *Calendar) []LintWarning {
+	diagnostics := make([]LintWarning, 0)
+	if condition == "" {
+		return diagnostics
+	}
+	deps, err := story.ConditionDependencies(condition)
+	if err != nil {
+		return diagnostics
+	}
+	tagIDs, _ := story.ConditionTags(condition)
+	comparisons, _ := story.ConditionComparisons(condition)
+	bounds, _ := story.ConditionBounds(condition)
+
+	unknown := make(map[string]bool)
+	for _, path := range deps {
+		if id, ok := strings.CutPrefix(path, "stats."); ok && !stats[id] {
+			unknown[path] = true
+		}
+		if id, ok := strings.CutPrefix(path, "items."); ok && !items[id] {
+			unknown[path] = true
+		}
+		if id, ok := strings.CutPrefix(path, "resources."); ok && !resources[id] {
+			unknown[path] = true
+		}
+		if id, ok := strings.CutPrefix(path, "affinity."); ok && !npcs[id] {
+			unknown[path] = true
+		}
+	}
+	for _, id := range tagIDs {
+		if !tags[id] {
+			unknown["tags."+id] = true
+		}
+	}
+	paths := make([]string, 0, len(unknown))
+	for path := range unknown {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		diagnostics = append(diagnostics, LintWarning{
+			Code: LintUnknownState, Subject: subject, Field: field, Path: path,
+			Message: fmt.Sprintf("%s reads %s, which the world does not define", subject, path),
+		})
+	}
+
+	outOfRange := make(map[string]bool)
+	for _, c := range comparisons {
+		domain, ok := stateDomain(c.Path, calendar)
+		if !ok || !(story.Bound{Min: max(c.Bound.Min, domain.Min), Max: min(c.Bound.Max, domain.Max)}).Empty() {
+			continue
+		}
+		outOfRange[c.Path] = true
+		diagnostics = append(diagnostics, LintWarning{
+			Code: LintOutOfRange, Subject: subject, Field: field, Path: c.Path, Expr: c.Expr,
+			Message: fmt.Sprintf("%s: %s can never hold, %s runs %s", subject, c.Expr, c.Path, formatDomain(domain)),
+		})
+	}
+
+	paths = paths[:0]
+	for path, b := range bounds {
+		if b.Empty() && !outOfRange[path] {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		diagnostics = append(diagnostics, LintWarning{
+			Code: LintContradiction, Subject: subject, Field: field, Path: path,
+			Message: fmt.Sprintf("%s: the comparisons of %s exclude each other", subject, path),
+		})
+	}
+	return diagnostics
+}
+
+// formatDomain describes the values a state path can hold, e.g. "0-100"
+// or "from 0"
+func formatDomain(domain story.Bound) string {
+	if math.IsInf(domain.Max, 1) {
+		return fmt.Sprintf("from %g", domain.Min)
+	}
+	return fmt.Sprintf("%g-%g", domain.Min, domain.Max)
+}
@@ -0,0 +1,54 @@
+package game
+
+import (
+	"fmt"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+)
+
+// maxStatMultiplier bounds how strongly a season may scale stat gains
+const maxStatMultiplier = 3.0
+
+// validateStatMultipliers checks that season multipliers name known stats
+// and stay within range
+func validateStatMultipliers(schema *agents.WorldGenSchema) error {
+	stats := make(map[string]bool, len(schema.Stats))
+	for _, stat := range schema.Stats {
+		stats[stat.ID] = true
+	}
+
+	for _, season := range schema.Seasons {
+		for statID, mult := range season.StatMultipliers {
+			if !stats[statID] {
+				return fmt.Errorf("season %s: unknown stat %s", season.ID, statID)
+			}
+			if mult < 0 || mult > maxStatMultiplier {
+				return fmt.Errorf("season %s: multiplier for %s out of range: %v", season.ID, statID, mult)
+			}
+		}
+	}
+	return nil
+}
+
+// StatMultiplier returns the current season's multiplier for gains of a
+// stat and the season name, or 1 when the season does not scale it
+func (s *GlobalBlackboard) StatMultiplier(id string) (float64, string) {
+	if s.Season < 0 || s.Season >= len(s.Seasons) {
+		return 1, ""
+	}
+	season := s.Seasons[s.Season]
+	name, _ := season["name"].(string)
+
+	// Saved games decode the multipliers as a generic map
+	switch mults := season["stat_multipliers"].(type) {
+	case map[string]float64:
+		if mult, ok := mults[id]; ok {
+			return mult, name
+		}
+	case map[string]interface{}:
+		if mult, ok := mults[id].(float64); ok {
+			return mult, name
+		}
+	}
+	return 1, ""
+}
@@ -3,19 +3,28 @@ package game
 import (
 	"encoding/json"
 	"fmt"
+	"math/rand"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
+	"github.com/qninhdt/world-card-ai-2/server/internal/death"
 )
 
 // NPC represents a non-player character
 type NPC struct {
 	ID              string `json:"id"`
 	Name            string `json:"name"`
+	Description     string `json:"description,omitempty"`
 	Appearance      string `json:"appearance"`
 	Enabled         bool   `json:"enabled"`
 	AppearanceCount int    `json:"appearance_count"`
+	Age             int    `json:"age,omitempty"`      // in-game years; 0 means ageless
+	Deceased        bool   `json:"deceased,omitempty"` // died of old age, cannot be enabled again
+	Affinity        int    `json:"affinity,omitempty"` // how the NPC feels about the player, -100 to 100
+	Departed        bool   `json:"departed,omitempty"` // left over low affinity, cannot be enabled again
 }
 
 // PlayerCharacter represents the player character
@@ -29,6 +38,7 @@ type PlayerCharacter struct {
 type GlobalBlackboard struct {
 	// World metadata
 	WorldName string `json:"world_name"`
+	WorldKey  string `json:"world_key"` // identifies games created from the same schema
 	Era       string `json:"era"`
 	YearStart int    `json:"year_start"`
 
@@ -40,30 +50,76 @@ type GlobalBlackboard struct {
 	Stats  map[string]int `json:"stats"`  // keyed by stat ID, values 0-100
 	Tags   map[string]bool `json:"tags"`  // keyed by tag ID
 	Events map[string]Event `json:"events"` // keyed by event ID
+	Items  map[string]int   `json:"items,omitempty"` // counts held, keyed by item ID
+	Resources map[string]int `json:"resources,omitempty"` // balances, keyed by resource ID
+
+	// Multi-era worlds; Era above holds the current era's name
+	Eras     []Era `json:"eras,omitempty"`
+	EraIndex int   `json:"era_index,omitempty"`
+
+	// Time loops: how many resets so far and the world at the loop's start
+	LoopCount  int         `json:"loop_count,omitempty"`
+	LoopAnchor *LoopAnchor `json:"loop_anchor,omitempty"`
+
+	// Natural deaths of aging NPCs
+	MortalityRules []MortalityRule `json:"mortality_rules,omitempty"`
+
+	// NPCs, tags and arcs the player has come across, in unlock order
+	Codex []CodexEntry `json:"codex,omitempty"`
+
+	// Story continuity, kept apart from gameplay tags
+	Facts map[string]Fact `json:"facts,omitempty"` // keyed by fact ID
+
+	// Stats not yet shown to the player (conditions and the Writer see them)
+	HiddenStats map[string]bool `json:"hidden_stats,omitempty"`
+
+	// Chance rolls; the seed never leaves the server
+	RNGSeed  int64        `json:"rng_seed,omitempty"`
+	RNGDraws int64        `json:"rng_draws,omitempty"`
+	RollLog  []RollRecord `json:"roll_log,omitempty"` // most recent last
 
 	// Time tracking
-	Day              int `json:"day"`               // 1-28
-	Season           int `json:"season"`            // 0-3
+	Day              int `json:"day"`               // 1-28 by default
+	Season           int `json:"season"`            // 0-3 by default
 	Year             int `json:"year_in_game"`
 	StartDay         int `json:"start_day"`         // for elapsed time calculation
 	StartSeason      int `json:"start_season"`      // for elapsed time calculation
 	StartYear        int `json:"start_year"`        // for elapsed time calculation
-	Turn             int `json:"turn"`              // actions this week (0-6)
+	Turn             int `json:"turn"`              // actions this week (0-6 by default)
+	DaysPlayed       int `json:"days_played,omitempty"` // days of this week a daily game has drawn and resolved
 
 	// Plot state
 	PendingPlotNodeID string `json:"pending_plot_node_id"`
 
 	// Death/resurrection state
-	IsAlive              bool     `json:"is_alive"`
-	CurrentLife          int      `json:"current_life"`
-	DeathCause           string   `json:"death_cause"`
-	DeathTurn            int      `json:"death_turn"`
-	Karma                []string `json:"karma"`                    // tags from previous lives
-	LifeNumber           int      `json:"life_number"`              // current life count
-	ResurrectionMechanic string   `json:"resurrection_mechanic"`
-	ResurrectionFlavor   string   `json:"resurrection_flavor"`
-	PreviousLifeTags     []string `json:"previous_life_tags"`       // tags from last life
-	IsFirstDayAfterDeath bool     `json:"is_first_day_after_death"` // flag for first day after resurrection
+	IsAlive              bool             `json:"is_alive"`
+	CurrentLife          int              `json:"current_life"`
+	DeathCause           string           `json:"death_cause"`
+	DeathTurn            int              `json:"death_turn"`
+	Karma                []string         `json:"karma"`                    // tags from previous lives
+	LifeNumber           int              `json:"life_number"`              // current life count
+	ResurrectionMechanic string           `json:"resurrection_mechanic"`
+	ResurrectionFlavor   string           `json:"resurrection_flavor"`
+	Dynasty              *Dynasty         `json:"dynasty,omitempty"`        // heir resurrection settings
+	Difficulty           *Difficulty      `json:"difficulty,omitempty"`     // self-adjusting challenge level
+	Deck                 *DeckConfig      `json:"deck,omitempty"`           // how week decks are made up
+	Calendar             *Calendar        `json:"calendar,omitempty"`       // days per week and season, seasons per year
+	Sampling             agents.SamplingSchedule `json:"sampling,omitempty"` // the world's Writer sampling overrides by job type
+	DrawMode             DrawMode         `json:"draw_mode,omitempty"`      // how the week deck is dealt
+	Status               GameStatus       `json:"status,omitempty"`         // lifecycle; empty means active
+	Interlude            *Interlude       `json:"interlude,omitempty"`      // scene between death and the next life
+	LoadoutPool          []Loadout        `json:"loadout_pool,omitempty"`   // starting archetypes derived from the schema
+	Loadouts             []Loadout        `json:"loadouts,omitempty"`       // archetypes the reborn card offers
+	LifeLog              []LifeEntry      `json:"life_log,omitempty"`       // choices of the current life, most recent last
+	WeekLog              []LifeEntry      `json:"week_log,omitempty"`       // choices of the current week, for its chronicle
+	WeekStartStats       map[string]int   `json:"week_start_stats,omitempty"` // stats when the week or life began
+	PolishChronicle      bool             `json:"polish_chronicle,omitempty"` // have the Writer rewrite week chronicles
+	StoryEditor          bool             `json:"story_editor,omitempty"`     // check Writer batches against canonical facts
+	Pacing               []PacingBeat     `json:"pacing,omitempty"`         // recent choices for the Director, most recent last
+	LastPlotBeat         int              `json:"last_plot_beat,omitempty"` // elapsed days when a plot node last fired
+	LastDeath            *death.DeathInfo `json:"last_death,omitempty"`     // most recent death and its obituary
+	PreviousLifeTags     []string         `json:"previous_life_tags"`       // tags from last life
+	IsFirstDayAfterDeath bool             `json:"is_first_day_after_death"` // flag for first day after resurrection
 
 	// Structural cards
 	WelcomeCard      interface{}            `json:"welcome_card"`
@@ -74,8 +130,16 @@ type GlobalBlackboard struct {
 
 	// Definitions
 	Seasons       []map[string]interface{} `json:"seasons"`       // season definitions
+	StatDefs      []map[string]interface{} `json:"stat_defs"`     // stat display names and descriptions
 	TagDefs       []map[string]interface{} `json:"tag_defs"`      // tag definitions
 	Relationships []map[string]interface{} `json:"relationships"` // relationship definitions
+	Macros        map[string]cards.Macro   `json:"macros,omitempty"` // world-defined compound functions
+	ItemDefs      map[string]agents.ItemDef `json:"item_defs,omitempty"` // collectible items, keyed by ID
+	ResourceDefs  map[string]agents.ResourceDef `json:"resource_defs,omitempty"` // unbounded resources, keyed by ID
+	PressureRules []PressureRule           `json:"pressure_rules,omitempty"` // linked-stat consequences
+
+	// Version is bumped whenever the engine records a state change
+	Version int64 `json:"version"`
 
 	// Timestamps
 	CreatedAt time.Time `json:"created_at"`
@@ -110,31 +174,43 @@ func NewGlobalBlackboard(schema *agents.WorldGenSchema) *GlobalBlackboard {
 		Karma:                make([]string, 0),
 		PreviousLifeTags:     make([]string, 0),
 		IsFirstDayAfterDeath: false,
+		ResurrectionMechanic: schema.ResurrectionMechanic,
+		ResurrectionFlavor:   schema.ResurrectionFlavor,
 		PendingDeathCards:    make(map[string]interface{}),
 		Seasons:              make([]map[string]interface{}, 0),
+		StatDefs:             make([]map[string]interface{}, 0),
 		TagDefs:              make([]map[string]interface{}, 0),
 		Relationships:        make([]map[string]interface{}, 0),
+		RNGSeed:              time.Now().UnixNano(),
 		CreatedAt:            time.Now(),
 		UpdatedAt:            time.Now(),
 	}
 
 	// Initialize seasons
 	for _, season := range schema.Seasons {
-		state.Seasons = append(state.Seasons, map[string]interface{}{
+		def := map[string]interface{}{
 			"id":          season.ID,
 			"name":        season.Name,
 			"description": season.Description,
-		})
+		}
+		if len(season.StatMultipliers) > 0 {
+			def["stat_multipliers"] = season.StatMultipliers
+		}
+		state.Seasons = append(state.Seasons, def)
 	}
 
 	// Initialize tag definitions
 	for _, tag := range schema.Tags {
-		state.TagDefs = append(state.TagDefs, map[string]interface{}{
+		def := map[string]interface{}{
 			"id":          tag.ID,
 			"name":        tag.Name,
 			"description": tag.Description,
 			"is_temp":     tag.IsTemp,
-		})
+		}
+		if tag.Knowledge {
+			def["knowledge"] = true
+		}
+		state.TagDefs = append(state.TagDefs, def)
 	}
 
 	// Initialize relationships
@@ -146,23 +222,53 @@ func NewGlobalBlackboard(schema *agents.WorldGenSchema) *GlobalBlackboard {
 		})
 	}
 
+	// Initialize macros
+	if len(schema.Macros) > 0 {
+		state.Macros = make(map[string]cards.Macro, len(schema.Macros))
+		for _, def := range schema.Macros {
+			calls := make([]cards.FunctionCall, 0, len(def.Calls))
+			for _, call := range def.Calls {
+				calls = append(calls, cards.FunctionCall{Name: call.Name, Params: call.Params})
+			}
+			state.Macros[def.ID] = cards.Macro{
+				ID:          def.ID,
+				Description: def.Description,
+				Calls:       calls,
+			}
+		}
+	}
+
 	// Initialize NPCs
 	for _, npc := range schema.NPCs {
 		state.NPCs[npc.ID] = NPC{
-			ID:         npc.ID,
-			Name:       npc.Name,
-			Appearance: npc.Appearance,
-			Enabled:    true,
+			ID:          npc.ID,
+			Name:        npc.Name,
+			Description: npc.Description,
+			Appearance:  npc.Appearance,
+			Enabled:     true,
+			Age:         npc.Age,
+			Affinity:    npc.Affinity,
 		}
 	}
 
 	// Initialize stats
 	for _, stat := range schema.Stats {
+		state.StatDefs = append(state.StatDefs, map[string]interface{}{
+			"id":          stat.ID,
+			"name":        stat.Name,
+			"description": stat.Description,
+		})
 		if val, ok := schema.InitialStats[stat.ID]; ok {
 			state.Stats[stat.ID] = val
 		} else {
 			state.Stats[stat.ID] = 50 // default
 		}
+		if stat.Hidden {
+			if state.HiddenStats == nil {
+				state.HiddenStats = make(map[string]bool)
+			}
+			state.HiddenStats[stat.ID] = true
+		}
 	}
 
 	// Initialize tags
@@ -188,6 +294,107 @@ func (s *GlobalBlackboard) GetStat(id string) int {
 	return val
 }
 
+// RevealStat makes a hidden stat visible to the player
+func (s *GlobalBlackboard) RevealStat(id string) {
+	delete(s.HiddenStats, id)
+	if len(s.HiddenStats) == 0 {
+		s.HiddenStats = nil
+	}
+	s.UpdatedAt = time.Now()
+}
+
+// AdvanceEventPhase moves a phase event on to its next phase
+func (s *GlobalBlackboard) AdvanceEventPhase(id string) bool {
+	event, ok := s.Events[id].(*PhaseEvent)
+	if !ok || event.AdvancePhase() == nil {
+		return false
+	}
+	s.UpdatedAt = time.Now()
+	return true
+}
+
+// Roll returns the next value in [0, 1) of the game's seeded sequence. Each
+// value is derived from the seed and draw count (splitmix64), so a saved game
+// continues the same sequence after it is loaded.
+func (s *GlobalBlackboard) Roll() float64 {
+	return float64(s.nextDraw()>>11) / (1 << 53)
+}
+
+// Rand returns a *rand.Rand drawing from the same seeded sequence as Roll,
+// for randomness that needs more than a roll
+func (s *GlobalBlackboard) Rand() *rand.Rand {
+	return rand.New(drawSource{s})
+}
+
+// nextDraw advances the seeded sequence
+func (s *GlobalBlackboard) nextDraw() uint64 {
+	s.RNGDraws++
+	x := uint64(s.RNGSeed) + uint64(s.RNGDraws)*0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// drawSource is a rand.Source over a blackboard's seeded sequence. It keeps
+// no state of its own, so the blackboard's seed and draw count are all a
+// saved game needs to continue it.
+type drawSource struct {
+	state *GlobalBlackboard
+}
+
+func (d drawSource) Int63() int64 { return int64(d.state.nextDraw() >> 1) }
+
+// Seed is a no-op; the blackboard's RNGSeed seeds the sequence
+func (d drawSource) Seed(int64) {}
+
+// maxRollLog bounds how many rolls the blackboard remembers
+const maxRollLog = 50
+
+// RollRecord is a chance roll kept for the game history
+type RollRecord struct {
+	cards.Roll
+	CardID string `json:"card_id"`
+	Day    int    `json:"day"`
+	Season int    `json:"season"`
+	Year   int    `json:"year_in_game"`
+}
+
+// LogRolls appends the rolls of a card resolution to the roll log
+func (s *GlobalBlackboard) LogRolls(cardID string, rolls []cards.Roll) {
+	for _, roll := range rolls {
+		s.RollLog = append(s.RollLog, RollRecord{
+			Roll:   roll,
+			CardID: cardID,
+			Day:    s.Day,
+			Season: s.Season,
+			Year:   s.Year,
+		})
+	}
+	if len(s.RollLog) > maxRollLog {
+		s.RollLog = append([]RollRecord(nil), s.RollLog[len(s.RollLog)-maxRollLog:]...)
+	}
+}
+
+// VisibleStats returns a copy of the stats the player may see
+func (s *GlobalBlackboard) VisibleStats() map[string]int {
+	result := make(map[string]int, len(s.Stats))
+	for k, v := range s.Stats {
+		if !s.HiddenStats[k] {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// GetMacro returns a world-defined macro by ID
+func (s *GlobalBlackboard) GetMacro(id string) (*cards.Macro, bool) {
+	macro, ok := s.Macros[id]
+	if !ok {
+		return nil, false
+	}
+	return &macro, true
+}
+
 // SetStat sets a stat value, clamped to 0-100
 func (s *GlobalBlackboard) SetStat(id string, value int) {
 	if value < 0 {
@@ -234,7 +441,7 @@ func (s *GlobalBlackboard) GetNPC(id string) *NPC {
 
 // EnableNPC enables an NPC
 func (s *GlobalBlackboard) EnableNPC(id string) {
-	if npc, ok := s.NPCs[id]; ok {
+	if npc, ok := s.NPCs[id]; ok && !npc.Deceased && !npc.Departed {
 		npc.Enabled = true
 		s.NPCs[id] = npc
 		s.UpdatedAt = time.Now()
@@ -250,8 +457,9 @@ func (s *GlobalBlackboard) DisableNPC(id string) {
 	}
 }
 
-// AddEvent adds an event
+// AddEvent adds an event, normalizing its icon and name for display
 func (s *GlobalBlackboard) AddEvent(event Event) {
+	normalizeEventDisplay(event)
 	s.Events[event.GetID()] = event
 	s.UpdatedAt = time.Now()
 }
@@ -269,24 +477,32 @@ func (s *GlobalBlackboard) GetEvent(id string) Event {
 
 // AdvanceDay advances the calendar by one day
 func (s *GlobalBlackboard) AdvanceDay() {
+	calendar := s.calendar()
 	s.Day++
-	s.Turn++
-	if s.Day > 28 {
+	if s.Day > calendar.DaysPerSeason {
 		s.Day = 1
-		s.Turn = 0
 		s.Season++
-		if s.Season > 3 {
+		if s.Season >= calendar.SeasonsPerYear {
 			s.Season = 0
 			s.Year++
 		}
 	}
+	s.syncTurn()
 	s.UpdatedAt = time.Now()
 }
 
+// syncTurn derives the day of the week from the day of the season, so the
+// week restarts on days 1, 8, 15 and 22 of the default calendar however the
+// calendar moved
+func (s *GlobalBlackboard) syncTurn() {
+	s.Turn = (s.Day - 1) % s.calendar().DaysPerWeek
+}
+
 // GetElapsedDays returns total days elapsed since start
 func (s *GlobalBlackboard) GetElapsedDays() int {
-	currentAbs := (s.Year * 112) + (s.Season * 28) + s.Day
-	startAbs := (s.StartYear * 112) + (s.StartSeason * 28) + s.StartDay
+	calendar := s.calendar()
+	currentAbs := (s.Year * calendar.DaysPerYear()) + (s.Season * calendar.DaysPerSeason) + s.Day
+	startAbs := (s.StartYear * calendar.DaysPerYear()) + (s.StartSeason * calendar.DaysPerSeason) + s.StartDay
 	return currentAbs - startAbs
 }
 
@@ -356,6 +572,7 @@ func (s *GlobalBlackboard) SetYear(year int) {
 // SetDay sets the day
 func (s *GlobalBlackboard) SetDay(day int) {
 	s.Day = day
+	s.syncTurn()
 	s.UpdatedAt = time.Now()
 }
 
@@ -365,34 +582,80 @@ func (s *GlobalBlackboard) SetTags(tags map[string]bool) {
 	s.UpdatedAt = time.Now()
 }
 
+// GetResurrectionMechanic returns the world's resurrection mechanic
+func (s *GlobalBlackboard) GetResurrectionMechanic() string {
+	return s.ResurrectionMechanic
+}
+
+// BeginLife starts the next life with the given tags, recording them as
+// karma
+func (s *GlobalBlackboard) BeginLife(karma map[string]bool) {
+	s.PreviousLifeTags = make([]string, 0, len(s.Tags))
+	for tagID, active := range s.Tags {
+		if active {
+			s.PreviousLifeTags = append(s.PreviousLifeTags, tagID)
+		}
+	}
+	s.Karma = make([]string, 0, len(karma))
+	for tagID := range karma {
+		s.Karma = append(s.Karma, tagID)
+	}
+	sort.Strings(s.PreviousLifeTags)
+	sort.Strings(s.Karma)
+
+	s.Tags = karma
+	s.clearDebts()
+	s.LifeLog = nil
+	s.WeekLog = nil
+	s.WeekStartStats = nil
+	s.IsAlive = true
+	s.DeathCause = ""
+	s.DeathTurn = 0
+	s.LifeNumber++
+	s.CurrentLife++
+	s.IsFirstDayAfterDeath = true
+	s.UpdatedAt = time.Now()
+}
+
 // SetCurrentLife sets the current life
 func (s *GlobalBlackboard) SetCurrentLife(life int) {
 	s.CurrentLife = life
 	s.UpdatedAt = time.Now()
 }
 
-// WeekInSeason returns current week within the season (1-4)
+// WeekInSeason returns current week within the season (1-4 by default)
 func (s *GlobalBlackboard) WeekInSeason() int {
-	return ((s.Day - 1) / 7) + 1
+	return ((s.Day - 1) / s.calendar().DaysPerWeek) + 1
 }
 
 // DateDisplay returns formatted date string (e.g. "Day 5, Spring, Year 1")
 func (s *GlobalBlackboard) DateDisplay() string {
+	return fmt.Sprintf("Day %d, %s, Year %d", s.Day, s.SeasonName(), s.Year)
+}
+
+// SeasonName returns the current season's name: the world's own, else
+// Spring to Winter, else "Season N" past the fourth
+func (s *GlobalBlackboard) SeasonName() string {
+	if s.Season >= 0 && s.Season < len(s.Seasons) {
+		if name, ok := s.Seasons[s.Season]["name"].(string); ok && name != "" {
+			return name
+		}
+	}
 	seasonNames := []string{"Spring", "Summer", "Autumn", "Winter"}
-	seasonName := "Unknown"
 	if s.Season >= 0 && s.Season < len(seasonNames) {
-		seasonName = seasonNames[s.Season]
+		return seasonNames[s.Season]
 	}
-	return fmt.Sprintf("Day %d, %s, Year %d", s.Day, seasonName, s.Year)
+	return fmt.Sprintf("Season %d", s.Season+1)
 }
 
 // ElapsedDisplay returns formatted elapsed time (e.g. "1y 2s 5d")
 func (s *GlobalBlackboard) ElapsedDisplay() string {
+	calendar := s.calendar()
 	elapsed := s.GetElapsedDays()
-	years := elapsed / 112
-	rem := elapsed % 112
-	seasons := rem / 28
-	days := rem % 28
+	years := elapsed / calendar.DaysPerYear()
+	rem := elapsed % calendar.DaysPerYear()
+	seasons := rem / calendar.DaysPerSeason
+	days := rem % calendar.DaysPerSeason
 
 	var parts []string
 	if years > 0 {
@@ -429,10 +692,11 @@ func (s *GlobalBlackboard) GetEnabledNPCNames() []string {
 // AdvanceToNextSeason skips remaining days and starts Day 1 of next season
 func (s *GlobalBlackboard) AdvanceToNextSeason() {
 	s.Day = 1
-	s.Season = (s.Season + 1) % 4
+	s.Season = (s.Season + 1) % s.calendar().SeasonsPerYear
 	if s.Season == 0 {
 		s.Year++
 	}
+	s.syncTurn()
 	s.UpdatedAt = time.Now()
 }
 func (s *GlobalBlackboard) MarshalJSON() ([]byte, error) {
@@ -1,6 +1,7 @@
 package game
 
 import (
+	"math/rand"
 	"testing"
 	"time"
 )
@@ -220,11 +221,10 @@ func TestAdvanceDayWeekBoundary(t *testing.T) {
 
 	state.AdvanceDay()
 
-	// After advancing: day becomes 8, turn becomes 7
-	// Turn is reset to 0 when turn >= 7 (which is 7 days per week)
-	// But the reset happens in the next iteration, so turn will be 7 after first advance
-	if state.Turn != 7 {
-		t.Errorf("Expected turn 7, got %d", state.Turn)
+	// After advancing: day becomes 8, the first day of week 2, so turn
+	// starts over at 0
+	if state.Turn != 0 {
+		t.Errorf("Expected turn 0, got %d", state.Turn)
 	}
 
 	if state.Day != 8 {
@@ -542,3 +542,99 @@ func TestTimestamps(t *testing.T) {
 		t.Error("UpdatedAt is in the future")
 	}
 }
+
+// calendarRuns and calendarSteps size the calendar property tests
+const (
+	calendarRuns  = 2000
+	calendarSteps = 200
+)
+
+// randomCalendar returns a blackboard on a random valid date, with the
+// start of the run on that date
+func randomCalendar(rng *rand.Rand) *GlobalBlackboard {
+	state := NewGlobalBlackboard(createTestSchema())
+	state.SetDay(rng.Intn(28) + 1)
+	state.Season = rng.Intn(4)
+	state.Year = rng.Intn(50)
+	state.StartDay, state.StartSeason, state.StartYear = state.Day, state.Season, state.Year
+	return state
+}
+
+// checkCalendar fails if the date is out of range or the derived week
+// fields disagree with it
+func checkCalendar(t *testing.T, state *GlobalBlackboard, trace []string) {
+	t.Helper()
+	if state.Day < 1 || state.Day > 28 || state.Season < 0 || state.Season > 3 || state.Year < 0 {
+		t.Fatalf("Date out of range: day %d season %d year %d after %v", state.Day, state.Season, state.Year, trace)
+	}
+	if state.Turn != (state.Day-1)%7 {
+		t.Fatalf("Expected turn %d on day %d, got %d after %v", (state.Day-1)%7, state.Day, state.Turn, trace)
+	}
+	if week := state.WeekInSeason(); week < 1 || week > 4 || week != (state.Day-1)/7+1 {
+		t.Fatalf("Expected week %d on day %d, got %d after %v", (state.Day-1)/7+1, state.Day, week, trace)
+	}
+}
+
+// TestCalendarProperties tests calendar invariants over random sequences of
+// day and season advances
+func TestCalendarProperties(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for run := 0; run < calendarRuns; run++ {
+		state := randomCalendar(rng)
+		checkCalendar(t, state, nil)
+
+		trace := make([]string, 0, calendarSteps)
+		elapsed := 0
+		for step := 0; step < calendarSteps; step++ {
+			if rng.Intn(10) == 0 {
+				// Skipping to the next season lands on its first day
+				oldSeason, oldYear, oldDay := state.Season, state.Year, state.Day
+				trace = append(trace, "season")
+				state.AdvanceToNextSeason()
+				elapsed += 29 - oldDay
+
+				if state.Day != 1 || state.Season != (oldSeason+1)%4 {
+					t.Fatalf("Expected day 1 of season %d, got day %d of season %d after %v", (oldSeason+1)%4, state.Day, state.Season, trace)
+				}
+				if wantYear := oldYear + (oldSeason+1)/4; state.Year != wantYear {
+					t.Fatalf("Expected year %d, got %d after %v", wantYear, state.Year, trace)
+				}
+			} else {
+				trace = append(trace, "day")
+				state.AdvanceDay()
+				elapsed++
+			}
+
+			checkCalendar(t, state, trace)
+			if got := state.GetElapsedDays(); got != elapsed {
+				t.Fatalf("Expected %d elapsed days, got %d after %v", elapsed, got, trace)
+			}
+		}
+	}
+}
+
+// TestCalendarElapsedRoundTrip tests that elapsed days and the date agree:
+// advancing n days from any date lands n days later
+func TestCalendarElapsedRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+
+	for run := 0; run < calendarRuns; run++ {
+		state := randomCalendar(rng)
+		n := rng.Intn(1000)
+		startAbs := state.Year*112 + state.Season*28 + state.Day - 1
+
+		for i := 0; i < n; i++ {
+			state.AdvanceDay()
+		}
+
+		abs := startAbs + n
+		if state.Year != abs/112 || state.Season != abs%112/28 || state.Day != abs%28+1 {
+			t.Fatalf("Expected %d days after day %d to be day %d season %d year %d, got day %d season %d year %d",
+				n, startAbs, abs%28+1, abs%112/28, abs/112, state.Day, state.Season, state.Year)
+		}
+		if state.GetElapsedDays() != n {
+			t.Fatalf("Expected %d elapsed days, got %d", n, state.GetElapsedDays())
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
+)
+
+// tagMatchThreshold is the similarity an unknown tag needs to a known tag
+// to be mapped onto it rather than rejected
+const tagMatchThreshold = 0.8
+
+// knownTags returns the tags cards may use: the world's tag definitions and
+// any tag already active. Caller must hold e.mu.
+func (e *GameEngine) knownTags() map[string]bool {
+	known := make(map[string]bool, len(e.state.TagDefs)+len(e.state.Tags))
+	for _, def := range e.state.TagDefs {
+		if id, ok := def["id"].(string); ok {
+			known[id] = true
+		}
+	}
+	for id, active := range e.state.Tags {
+		if active {
+			known[id] = true
+		}
+	}
+	return known
+}
+
+// guardTags checks the tags a choice adds or removes against the known
+// tags. An unknown tag close to a known one is rewritten to it; otherwise
+// the choice is rejected so the card can be regenerated. Caller must hold
+// e.mu.
+func (e *GameEngine) guardTags(choice *cards.Choice, known map[string]bool) bool {
+	if choice == nil {
+		return true
+	}
+	for _, call := range choice.Calls {
+		if call.Name != "add_tag" && call.Name != "remove_tag" {
+			continue
+		}
+		tagID, _ := call.Params["tag_id"].(string)
+		if tagID == "" || known[tagID] {
+			continue
+		}
+		match, score := nearestTag(tagID, known)
+		if score < tagMatchThreshold {
+			agents.RecordValidationFailure(agents.ValidationFailure{
+				Agent:  agents.AgentWriter,
+				Class:  agents.FailureTagRejected,
+				Detail: fmt.Sprintf("%s: unknown tag %q", call.Name, tagID),
+			})
+			return false
+		}
+		// Params is shared with the card definition, so saved games and
+		// interlude cards see the mapped tag too
+		call.Params["tag_id"] = match
+		agents.RecordValidationFailure(agents.ValidationFailure{
+			Agent:  agents.AgentWriter,
+			Class:  agents.FailureTagMapped,
+			Detail: fmt.Sprintf("%s: unknown tag %q mapped to %q", call.Name, tagID, match),
+		})
+	}
+	return e.guardTags(choice.Unlocked, known)
+}
+
+// nearestTag returns the known tag most similar to tagID and its
+// similarity, from 0 to 1. Ties go to the alphabetically first tag.
+func nearestTag(tagID string, known map[string]bool) (string, float64) {
+	ids := make([]string, 0, len(known))
+	for id := range known {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	best, bestScore := "", 0.0
+	for _, id := range ids {
+		if score := tagSimilarity(tagID, id); score > bestScore {
+			best, bestScore = id, score
+		}
+	}
+	return best, bestScore
+}
+
+// tagSimilarity compares two tag IDs ignoring case and separators, as one
+// minus their edit distance over the longer length
+func tagSimilarity(a, b string) float64 {
+	ra, rb := []rune(normalizeTag(a)), []rune(normalizeTag(b))
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(editDistance(ra, rb))/float64(longest)
+}
+
+// normalizeTag lowercases a tag ID and drops the separators the Writer
+// tends to vary
+func normalizeTag(id string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '_', '-', ' ', '.':
+			return -1
+		}
+		return r
+	}, strings.ToLower(id))
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,130 @@
+package game
+
+import (
+	"sort"
+	"time"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
+	"github.com/qninhdt/world-card-ai-2/server/internal/story"
+)
+
+// LoopAnchor is the world as it was on day 1 of the looping season
+type LoopAnchor struct {
+	Stats     map[string]int  `json:"stats"`
+	Tags      map[string]bool `json:"tags"`
+	Items     map[string]int  `json:"items,omitempty"`
+	Resources map[string]int  `json:"resources,omitempty"`
+	NPCs      map[string]bool `json:"npcs"` // enabled flags
+	Facts     map[string]Fact `json:"facts,omitempty"`
+}
+
+// MarkLoopStart records the current world as the point time loops return to
+func (s *GlobalBlackboard) MarkLoopStart() {
+	anchor := &LoopAnchor{
+		Stats:     s.GetStats(),
+		Tags:      s.GetTags(),
+		Items:     s.GetItems(),
+		Resources: s.GetResources(),
+		NPCs:      make(map[string]bool, len(s.NPCs)),
+		Facts:     make(map[string]Fact, len(s.Facts)),
+	}
+	for id, npc := range s.NPCs {
+		anchor.NPCs[id] = npc.Enabled
+	}
+	for id, fact := range s.Facts {
+		anchor.Facts[id] = fact
+	}
+	s.LoopAnchor = anchor
+}
+
+// RestoreLoop rewinds stats, items, resources, NPCs and facts to the loop's
+// start, counts the loop and returns the tags the loop started with
+func (s *GlobalBlackboard) RestoreLoop() map[string]bool {
+	s.LoopCount++
+	s.UpdatedAt = time.Now()
+
+	tags := make(map[string]bool)
+	if s.LoopAnchor == nil {
+		for id := range s.Stats {
+			s.Stats[id] = 50
+		}
+		s.Items = nil
+		s.ResetResources()
+		s.restoreFacts(nil)
+		return tags
+	}
+	s.restoreFacts(s.LoopAnchor.Facts)
+
+	s.Items = nil
+	for id, count := range s.LoopAnchor.Items {
+		s.GiveItem(id, count)
+	}
+	// Anchors from before resources existed start them over
+	if s.LoopAnchor.Resources == nil {
+		s.ResetResources()
+	} else {
+		s.Resources = make(map[string]int, len(s.LoopAnchor.Resources))
+		for id, balance := range s.LoopAnchor.Resources {
+			s.Resources[id] = balance
+		}
+	}
+
+	for id, value := range s.LoopAnchor.Stats {
+		s.Stats[id] = value
+	}
+	for id, enabled := range s.LoopAnchor.NPCs {
+		if npc, ok := s.NPCs[id]; ok && !npc.Deceased && !npc.Departed {
+			npc.Enabled = enabled
+			s.NPCs[id] = npc
+		}
+	}
+	for id, active := range s.LoopAnchor.Tags {
+		tags[id] = active
+	}
+	return tags
+}
+
+// KnowledgeTags returns the active tags defined as knowledge, which the
+// player carries from one loop into the next
+func (s *GlobalBlackboard) KnowledgeTags() map[string]bool {
+	result := make(map[string]bool)
+	for _, def := range s.TagDefs {
+		id, _ := def["id"].(string)
+		if knowledge, _ := def["knowledge"].(bool); knowledge && s.Tags[id] {
+			result[id] = true
+		}
+	}
+	return result
+}
+
+// buildKnowledgeList returns the knowledge tags the player has gathered
+// across loops, for the Writer
+func (e *GameEngine) buildKnowledgeList() []string {
+	known := make([]string, 0)
+	for id := range e.state.KnowledgeTags() {
+		known = append(known, id)
+	}
+	sort.Strings(known)
+	return known
+}
+
+// unlockChoices swaps in a card's unlocked choices whose requirement holds,
+// e.g. an option that only a player who remembers the last loop can take.
+// Caller must hold e.mu.
+func (e *GameEngine) unlockChoices(card cards.Card) {
+	choiceCard, ok := card.(*cards.ChoiceCard)
+	if !ok {
+		return
+	}
+	conditionState := e.buildConditionState()
+	for _, slot := range []**cards.Choice{&choiceCard.LeftChoice, &choiceCard.RightChoice} {
+		choice := *slot
+		if choice == nil || choice.Unlocked == nil {
+			continue
+		}
+		met, err := story.EvaluateExpression(choice.Unlocked.Requires, conditionState)
+		if err == nil && met {
+			*slot = choice.Unlocked
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/cards"
+	"github.com/qninhdt/world-card-ai-2/server/internal/death"
+)
+
+// tutorialSource marks the cards of the onboarding sequence
+const tutorialSource = "tutorial"
+
+// rebirthLessons tells a new player what each resurrection mechanic does
+var rebirthLessons = map[string]string{
+	death.MechanicReincarnation: "You are reborn next season with every stat back at 50. The people you knew forget you.",
+	death.MechanicTimeLoop:      "The season starts over from day 1. You remember what you learned; nobody else does.",
+	death.MechanicHeir:          "Your heir carries on years later and inherits part of what you built.",
+	death.MechanicGhost:         "You rise as a ghost with every stat back at 50, and the world carries on around you.",
+}
+
+// StartTutorial queues the onboarding cards ahead of everything else. They
+// explain swiping, stats, tags, seasons and death using this world's own
+// names, and change nothing when resolved.
+func (e *GameEngine) StartTutorial() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	defer e.recordVersion()
+
+	tutorial := e.tutorialCards()
+	for i := len(tutorial) - 1; i >= 0; i-- {
+		e.immediateDeque.PushFront(tutorial[i])
+	}
+}
+
+// tutorialCards builds the onboarding sequence. Caller must hold e.mu.
+func (e *GameEngine) tutorialCards() []cards.Card {
+	world := e.state.WorldName
+	if world == "" {
+		world = "this world"
+	}
+	noop := func(label string) *cards.Choice {
+		return &cards.Choice{Label: label, Calls: []cards.FunctionCall{}}
+	}
+
+	tagLesson := "Tags mark what has happened to you. Cards, events and the story check for them."
+	for _, def := range e.state.TagDefs {
+		name, _ := def["name"].(string)
+		if name == "" {
+			continue
+		}
+		tagLesson = fmt.Sprintf("Tags such as %q mark what has happened to you. Cards, events and the story check for them.", name)
+		if temp, _ := def["is_temp"].(bool); temp {
+			tagLesson += " Some fade when the season ends."
+		}
+		break
+	}
+
+	rebirth, ok := rebirthLessons[e.deathLoop.Mechanic().Name()]
+	if !ok {
+		rebirth = rebirthLessons[death.MechanicReincarnation]
+	}
+
+	return []cards.Card{
+		&cards.ChoiceCard{
+			ID:          "tutorial_swipe",
+			Title:       "Welcome to " + world,
+			Description: "Every card is a moment of your life. Swipe left or right to choose; try it now.",
+			Character:   "narrator",
+			Source:      tutorialSource,
+			Priority:    10,
+			LeftChoice:  noop("Swipe left"),
+			RightChoice: noop("Swipe right"),
+		},
+		tutorialInfo("tutorial_stats", "Your Stats",
+			fmt.Sprintf("You are measured by %s. Each choice raises or lowers them.", strings.Join(e.visibleStatNames(), ", "))),
+		tutorialInfo("tutorial_tags", "Tags", tagLesson),
+		tutorialInfo("tutorial_seasons", "Seasons",
+			"Each hand of cards is one week. Four weeks make a season and four seasons a year. Seasons change the odds and bring their own events."),
+		tutorialInfo("tutorial_death", "Death",
+			"A stat that reaches 0 or 100 ends this life. "+rebirth),
+	}
+}
+
+// tutorialInfo builds one onboarding info card
+func tutorialInfo(id, title, description string) *cards.InfoCard {
+	return &cards.InfoCard{
+		ID:          id,
+		Title:       title,
+		Description: description,
+		Character:   "narrator",
+		Source:      tutorialSource,
+		Priority:    10,
+	}
+}
+
+// visibleStatNames lists the display names of the stats the player can
+// see. Caller must hold e.mu.
+func (e *GameEngine) visibleStatNames() []string {
+	names := make([]string, 0, len(e.state.Stats))
+	for _, stat := range e.buildStatList() {
+		if hidden, _ := stat["hidden"].(bool); hidden {
+			continue
+		}
+		names = append(names, fmt.Sprint(stat["name"]))
+	}
+	return names
+}
+
+// drawTutorial takes up to count onboarding cards from the front of the
+// immediate deque. Caller must hold e.mu.
+func (e *GameEngine) drawTutorial(count int) []cards.Card {
+	drawn := make([]cards.Card, 0, count)
+	for len(drawn) < count {
+		elem := e.immediateDeque.Front()
+		if elem == nil {
+			break
+		}
+		card := elem.Value.(cards.Card)
+		if card.GetSource() != tutorialSource {
+			break
+		}
+		e.immediateDeque.Remove(elem)
+		drawn = append(drawn, card)
+	}
+	return drawn
+}
+
+// isTutorialCard reports whether a card belongs to the onboarding sequence
+func isTutorialCard(card cards.Card) bool {
+	return card.GetSource() == tutorialSource
+}
@@ -0,0 +1,146 @@
+package game
+
+import "errors"
+
+// DefaultUndoDepth is how many actions a game can take back unless
+// SetUndoDepth changes it
+const DefaultUndoDepth = 20
+
+// Undo errors
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+// undoHistory holds a checkpoint from before each recent action, most
+// recent last, and the checkpoints undone since the last action
+type undoHistory struct {
+	depth int
+	undo  []*checkpoint
+	redo  []*checkpoint
+}
+
+// newUndoHistory creates a history keeping up to depth actions
+func newUndoHistory(depth int) *undoHistory {
+	return &undoHistory{depth: depth}
+}
+
+// push records the checkpoint taken before an action. A new action
+// forgets what was undone.
+func (h *undoHistory) push(cp *checkpoint) {
+	if cp == nil || h.depth <= 0 {
+		return
+	}
+	h.undo = append(h.undo, cp)
+	if len(h.undo) > h.depth {
+		h.undo = append([]*checkpoint(nil), h.undo[len(h.undo)-h.depth:]...)
+	}
+	h.redo = nil
+}
+
+// UndoStatus says how many actions can be undone and redone
+type UndoStatus struct {
+	Undo  int `json:"undo"`
+	Redo  int `json:"redo"`
+	Depth int `json:"depth"`
+}
+
+// SetUndoDepth changes how many actions can be taken back, dropping the
+// oldest past the new depth; 0 turns undo off
+func (e *GameEngine) SetUndoDepth(depth int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.history.depth = max(depth, 0)
+	if len(e.history.undo) > e.history.depth {
+		e.history.undo = e.history.undo[len(e.history.undo)-e.history.depth:]
+	}
+	if e.history.depth == 0 {
+		e.history.redo = nil
+	}
+}
+
+// UndoStatus returns how many actions can be undone and redone
+func (e *GameEngine) UndoStatus() UndoStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return UndoStatus{Undo: len(e.history.undo), Redo: len(e.history.redo), Depth: e.history.depth}
+}
+
+// undoPoint takes the checkpoint an action can be undone to, or nil when
+// undo is off or the checkpoint fails. Caller must hold e.mu.
+func (e *GameEngine) undoPoint() *checkpoint {
+	if e.history.depth <= 0 {
+		return nil
+	}
+	cp, err := e.checkpoint()
+	if err != nil {
+		return nil
+	}
+	return cp
+}
+
+// Undo takes back the last draw, resolution, batch, synced offline session,
+// week advance or resurrection. The blackboard, plot graph, deck, hand and
+// job queue go back to how they were, and the change is recorded as a new
+// version so diffs keep moving forward.
+func (e *GameEngine) Undo() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	defer e.recordVersion()
+
+	if err := e.checkActive(); err != nil {
+		return err
+	}
+	if len(e.history.undo) == 0 {
+		return ErrNothingToUndo
+	}
+	current, err := e.checkpoint()
+	if err != nil {
+		return err
+	}
+	last := len(e.history.undo) - 1
+	if err := e.travel(e.history.undo[last]); err != nil {
+		return err
+	}
+	e.history.undo = e.history.undo[:last]
+	e.history.redo = append(e.history.redo, current)
+	return nil
+}
+
+// Redo applies the last undone action again
+func (e *GameEngine) Redo() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	defer e.recordVersion()
+
+	if err := e.checkActive(); err != nil {
+		return err
+	}
+	if len(e.history.redo) == 0 {
+		return ErrNothingToRedo
+	}
+	current, err := e.checkpoint()
+	if err != nil {
+		return err
+	}
+	last := len(e.history.redo) - 1
+	if err := e.travel(e.history.redo[last]); err != nil {
+		return err
+	}
+	e.history.redo = e.history.redo[:last]
+	e.history.undo = append(e.history.undo, current)
+	return nil
+}
+
+// travel applies a checkpoint but keeps the current version, so the
+// deferred recordVersion numbers the restored state after it. Caller must
+// hold e.mu.
+func (e *GameEngine) travel(cp *checkpoint) error {
+	version := e.state.Version
+	if err := e.apply(cp); err != nil {
+		return err
+	}
+	e.state.Version = version
+	return nil
+}
@@ -0,0 +1,147 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MaxOfflineActions bounds the decision log of one offline session
+const MaxOfflineActions = 500
+
+// Offline decision log actions
+const (
+	OfflineResolve = "resolve"
+	OfflineAdvance = "advance"
+)
+
+// OfflineAction is one decision an embedded engine made while offline
+type OfflineAction struct {
+	Action    string `json:"action"` // "resolve" | "advance"
+	CardID    string `json:"card_id,omitempty"`
+	Direction string `json:"direction,omitempty"`
+}
+
+// ClaimedState is the final state an embedded engine reached. Only what the
+// player can see is compared; hidden stats never leave the server.
+type ClaimedState struct {
+	Stats      map[string]int  `json:"stats"`
+	Tags       map[string]bool `json:"tags"`
+	Day        int             `json:"day"`
+	Season     int             `json:"season"`
+	Year       int             `json:"year_in_game"`
+	IsAlive    bool            `json:"is_alive"`
+	LifeNumber int             `json:"life_number"`
+}
+
+// Verification is the outcome of replaying an offline session
+type Verification struct {
+	Accepted   bool     `json:"accepted"`
+	FailedStep int      `json:"failed_step"`          // -1 unless an action could not be replayed
+	Error      string   `json:"error,omitempty"`      // why FailedStep could not be replayed
+	Mismatches []string `json:"mismatches,omitempty"` // claimed fields that differ from the replay
+	Version    int64    `json:"version"`
+}
+
+// VerifyOfflineSession replays an offline decision log with the game's own
+// seed and accepts it only if it reaches the claimed state. A rejected
+// session leaves the game as it was.
+func (e *GameEngine) VerifyOfflineSession(actions []OfflineAction, claimed *ClaimedState) (*Verification, error) {
+	if len(actions) == 0 {
+		return nil, fmt.Errorf("decision log is empty")
+	}
+	if len(actions) > MaxOfflineActions {
+		return nil, fmt.Errorf("decision log has %d actions, at most %d allowed", len(actions), MaxOfflineActions)
+	}
+	if claimed == nil {
+		return nil, fmt.Errorf("claimed state is required")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.checkActive(); err != nil {
+		return nil, err
+	}
+	saved, err := e.checkpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Verification{FailedStep: -1}
+	for i, action := range actions {
+		switch action.Action {
+		case OfflineResolve:
+			_, err = e.resolveCard(action.CardID, action.Direction)
+		case OfflineAdvance:
+			err = e.advanceWeek()
+		default:
+			err = fmt.Errorf("unknown action: %s", action.Action)
+		}
+		if err != nil {
+			result.FailedStep = i
+			result.Error = err.Error()
+			break
+		}
+		e.recordVersion()
+	}
+
+	if result.FailedStep < 0 {
+		result.Mismatches = e.compareClaim(claimed)
+	}
+	if result.FailedStep >= 0 || len(result.Mismatches) > 0 {
+		if err := e.restore(saved); err != nil {
+			return nil, err
+		}
+		result.Version = e.state.Version
+		return result, nil
+	}
+
+	e.history.push(saved)
+	result.Accepted = true
+	result.Version = e.state.Version
+	return result, nil
+}
+
+// compareClaim lists where a claimed state differs from the blackboard.
+// Caller must hold e.mu.
+func (e *GameEngine) compareClaim(claimed *ClaimedState) []string {
+	var mismatches []string
+	visible := e.state.VisibleStats()
+	for id, value := range visible {
+		if claimed.Stats[id] != value {
+			mismatches = append(mismatches, "stats."+id)
+		}
+	}
+	for id := range claimed.Stats {
+		if _, ok := visible[id]; !ok {
+			mismatches = append(mismatches, "stats."+id)
+		}
+	}
+	for id, active := range e.state.Tags {
+		if claimed.Tags[id] != active {
+			mismatches = append(mismatches, "tags."+id)
+		}
+	}
+	for id, active := range claimed.Tags {
+		if _, ok := e.state.Tags[id]; !ok && active {
+			mismatches = append(mismatches, "tags."+id)
+		}
+	}
+	if claimed.Day != e.state.Day {
+		mismatches = append(mismatches, "day")
+	}
+	if claimed.Season != e.state.Season {
+		mismatches = append(mismatches, "season")
+	}
+	if claimed.Year != e.state.Year {
+		mismatches = append(mismatches, "year_in_game")
+	}
+	if claimed.IsAlive != e.state.IsAlive {
+		mismatches = append(mismatches, "is_alive")
+	}
+	if claimed.LifeNumber != e.state.LifeNumber {
+		mismatches = append(mismatches, "life_number")
+	}
+	sort.Strings(mismatches)
+	return mismatches
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// adminUsers returns the user IDs allowed to call admin endpoints,
+// read from the comma-separated ADMIN_USERS env var
+func adminUsers() map[string]bool {
+	users := make(map[string]bool)
+	for _, id := range strings.Split(os.Getenv("ADMIN_USERS"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			users[id] = true
+		}
+	}
+	return users
+}
+
+// AdminMiddleware validates the JWT like AuthMiddleware, also accepting
+// the session-less tokens the admin CLI signs, and additionally requires
+// the user to be listed in ADMIN_USERS
+func AdminMiddleware(sessions SessionResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return authMiddleware(sessions, true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !adminUsers()[CallerFrom(r.Context()).UserID] {
+				http.Error(w, "Admin access required", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// OrgKeyUserPrefix marks the user ID of requests made with an organization
+// API key, so they never collide with real users
+const OrgKeyUserPrefix = "org:"
+
+// APIKeyResolver returns who an API key belongs to: an organization for
+// organization keys, a user for personal keys
+type APIKeyResolver func(key string) (string, error)
+
+// APIKeyAuthMiddleware accepts an API key in the X-API-Key header and
+// otherwise falls back to AuthMiddleware. Organization key requests act as
+// "org:<id>" with the organization as the Caller's OrgID. Personal key
+// requests act as their user, with the Caller's APIKey set.
+func APIKeyAuthMiddleware(orgKeys, userKeys APIKeyResolver, sessions SessionResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		jwtAuth := AuthMiddleware(sessions)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("X-API-Key")
+			if key == "" {
+				jwtAuth.ServeHTTP(w, r)
+				return
+			}
+
+			if orgID, err := orgKeys(key); err == nil {
+				ctx := WithCaller(r.Context(), &Caller{UserID: OrgKeyUserPrefix + orgID, OrgID: orgID})
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			userID, err := userKeys(key)
+			if err != nil {
+				http.Error(w, "Invalid API key", http.StatusUnauthorized)
+				return
+			}
+			ctx := WithCaller(r.Context(), &Caller{UserID: userID, APIKey: true})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
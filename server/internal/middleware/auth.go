@@ -1,71 +1,164 @@
 package middleware
 
 import (
-	"context"
 	"fmt"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("your-secret-key-change-in-production")
+// defaultJWTSecret signs tokens when JWT_SECRET is unset. It is public, so
+// it is only fit for development.
+const defaultJWTSecret = "your-secret-key-change-in-production"
+
+// TokenTTL is how long a token without a session stays valid, as signed by
+// the admin CLI. Such tokens cannot be revoked, so only admin routes take
+// them.
+const TokenTTL = 24 * time.Hour
+
+// AccessTokenTTL is how long a session's access token stays valid before it
+// must be refreshed
+const AccessTokenTTL = 15 * time.Minute
+
+// RefreshTokenTTL is how long a session lasts without being refreshed
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+// jwtSecret returns the key tokens are signed with, read from JWT_SECRET
+func jwtSecret() []byte {
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	return []byte(defaultJWTSecret)
+}
+
+// UsingDefaultSecret reports whether tokens are signed with the development
+// secret
+func UsingDefaultSecret() bool {
+	return os.Getenv("JWT_SECRET") == ""
+}
 
 type Claims struct {
-	UserID string `json:"user_id"`
+	UserID    string `json:"user_id"`
+	SessionID string `json:"sid,omitempty"` // the login session; empty for tokens without one
 	jwt.RegisteredClaims
 }
 
-// AuthMiddleware validates JWT tokens
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Missing authorization header", http.StatusUnauthorized)
-			return
-		}
+// SessionResolver returns the user of a session that is still active
+type SessionResolver func(sessionID string) (string, error)
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
-			return
-		}
+// AuthMiddleware validates JWT tokens. Tokens issued to a session stop
+// working as soon as it is revoked; tokens without one are refused.
+func AuthMiddleware(sessions SessionResolver) func(http.Handler) http.Handler {
+	return authMiddleware(sessions, false)
+}
+
+// authMiddleware validates JWT tokens, also accepting tokens without a
+// session when sessionless is set
+func authMiddleware(sessions SessionResolver, sessionless bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				http.Error(w, "Missing authorization header", http.StatusUnauthorized)
+				return
+			}
 
-		tokenString := parts[1]
-		claims := &Claims{}
+			parts := strings.Split(authHeader, " ")
+			if len(parts) != 2 || parts[0] != "Bearer" {
+				http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
+				return
+			}
 
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			claims, err := verifyToken(parts[1], sessions, sessionless)
+			if err != nil {
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
 			}
-			return jwtSecret, nil
+
+			ctx := WithCaller(r.Context(), &Caller{UserID: claims.UserID, SessionID: claims.SessionID})
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
+	}
+}
 
-		if err != nil || !token.Valid {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
+// VerifyToken parses a token and checks that its session is still active
+// and belongs to its user
+func VerifyToken(tokenString string, sessions SessionResolver) (*Claims, error) {
+	return verifyToken(tokenString, sessions, false)
+}
 
-		if claims.UserID == "" {
-			http.Error(w, "Invalid token claims", http.StatusUnauthorized)
-			return
+// verifyToken is VerifyToken, letting tokens without a session through
+// when sessionless is set
+func verifyToken(tokenString string, sessions SessionResolver, sessionless bool) (*Claims, error) {
+	claims, err := ParseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.SessionID == "" {
+		if !sessionless {
+			return nil, fmt.Errorf("token has no session")
 		}
+		return claims, nil
+	}
+	userID, err := sessions(claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if userID != claims.UserID {
+		return nil, fmt.Errorf("session belongs to another user")
+	}
+	return claims, nil
+}
 
-		ctx := context.WithValue(r.Context(), "user_id", claims.UserID)
-		next.ServeHTTP(w, r.WithContext(ctx))
+// ParseToken verifies a token's signature and expiry and returns its claims
+func ParseToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return jwtSecret(), nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+	if claims.UserID == "" {
+		return nil, fmt.Errorf("token has no user ID")
+	}
+	return claims, nil
 }
 
-// GenerateToken creates a JWT token for a user
+// GenerateToken creates a JWT token for a user without a session, valid
+// for TokenTTL. Only admin routes accept it.
 func GenerateToken(userID string) (string, error) {
+	return signToken(userID, "", TokenTTL)
+}
+
+// GenerateSessionToken creates an access token for a login session, valid
+// for AccessTokenTTL
+func GenerateSessionToken(userID, sessionID string) (string, error) {
+	return signToken(userID, sessionID, AccessTokenTTL)
+}
+
+// signToken signs a token for a user and session lasting ttl
+func signToken(userID, sessionID string, ttl time.Duration) (string, error) {
+	now := time.Now()
 	claims := &Claims{
-		UserID: userID,
+		UserID:    userID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return token.SignedString(jwtSecret())
 }
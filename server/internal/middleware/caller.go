@@ -0,0 +1,26 @@
+package middleware
+
+import "context"
+
+// Caller is who an authenticated request acts as
+type Caller struct {
+	UserID    string // "org:<id>" for organization API keys
+	OrgID     string // set for organization API keys
+	SessionID string // set for tokens issued to a login session
+	APIKey    bool   // a personal API key
+}
+
+// callerKey is the context key of the Caller
+type callerKey struct{}
+
+// WithCaller returns ctx carrying caller
+func WithCaller(ctx context.Context, caller *Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFrom returns the caller set by the auth middleware, or nil on
+// public routes
+func CallerFrom(ctx context.Context) *Caller {
+	caller, _ := ctx.Value(callerKey{}).(*Caller)
+	return caller
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OAuthStateTTL is how long a player has to finish signing in at a provider
+const OAuthStateTTL = 10 * time.Minute
+
+// OAuthState is carried through a provider's sign-in page and back. It has
+// no user_id, so it is never accepted as a session token.
+type OAuthState struct {
+	Provider string `json:"provider"`
+	Nonce    string `json:"nonce"`               // also set as a cookie, so only the browser that started can finish
+	LinkUser string `json:"link_user,omitempty"` // link the identity to this account instead of signing in
+	jwt.RegisteredClaims
+}
+
+// GenerateOAuthState signs the state of a sign-in started at provider
+func GenerateOAuthState(provider, nonce, linkUser string) (string, error) {
+	now := time.Now()
+	state := &OAuthState{
+		Provider: provider,
+		Nonce:    nonce,
+		LinkUser: linkUser,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(OAuthStateTTL)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, state).SignedString(jwtSecret())
+}
+
+// ParseOAuthState verifies a state's signature and expiry
+func ParseOAuthState(stateString string) (*OAuthState, error) {
+	state := &OAuthState{}
+	token, err := jwt.ParseWithClaims(stateString, state, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return jwtSecret(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || state.Provider == "" || state.Nonce == "" {
+		return nil, fmt.Errorf("invalid state")
+	}
+	return state, nil
+}
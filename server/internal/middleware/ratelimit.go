@@ -1,59 +1,330 @@
 package middleware
 
 import (
+	"container/list"
+	"fmt"
 	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/time/rate"
 )
 
-// RateLimiter tracks rate limits per IP
+// Clients idle for RateLimitIdleTTL are forgotten once their burst has
+// refilled, since a new limiter would treat them the same. A group keeps at
+// most MaxRateLimitClients; beyond that the least recently seen client is
+// forgotten early and gets a fresh burst.
+const (
+	RateLimitIdleTTL    = 10 * time.Minute
+	MaxRateLimitClients = 100000
+)
+
+// Route groups, each limited on its own
+const (
+	RouteGroupIP       = "ip"       // every request by address, before authentication
+	RouteGroupDefault  = "default"  // reads, accounts and everything not listed below
+	RouteGroupPlay     = "play"     // drawing and resolving cards
+	RouteGroupGenerate = "generate" // advancing weeks, building worlds and rendering images
+)
+
+// RouteGroups lists the route groups in the order they are documented
+var RouteGroups = []string{RouteGroupIP, RouteGroupDefault, RouteGroupPlay, RouteGroupGenerate}
+
+// RateLimit is the sustained rate and burst allowed to one client in a
+// route group. A zero PerSecond means no limit.
+type RateLimit struct {
+	PerSecond float64
+	Burst     int
+}
+
+// DefaultRateLimits returns the limits used until configured otherwise
+func DefaultRateLimits() map[string]RateLimit {
+	return map[string]RateLimit{
+		RouteGroupIP:       {PerSecond: 200, Burst: 400},
+		RouteGroupDefault:  {PerSecond: 100, Burst: 1},
+		RouteGroupPlay:     {PerSecond: 20, Burst: 5},
+		RouteGroupGenerate: {PerSecond: 1, Burst: 3},
+	}
+}
+
+// ParseRateLimits reads limits written as "group=rate:burst" pairs
+// separated by commas, e.g. "play=10:5,generate=0.5:2"
+func ParseRateLimits(spec string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	for _, field := range strings.Split(spec, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		group, value, ok := strings.Cut(field, "=")
+		if !ok || !isRouteGroup(group) {
+			return nil, fmt.Errorf("unknown route group in %q", field)
+		}
+		perSecond, burst, ok := strings.Cut(value, ":")
+		if !ok {
+			return nil, fmt.Errorf("limit %q must be rate:burst", field)
+		}
+		var limit RateLimit
+		var err error
+		if limit.PerSecond, err = strconv.ParseFloat(perSecond, 64); err != nil || limit.PerSecond < 0 {
+			return nil, fmt.Errorf("invalid rate in %q", field)
+		}
+		if limit.Burst, err = strconv.Atoi(burst); err != nil || limit.Burst < 1 {
+			return nil, fmt.Errorf("invalid burst in %q", field)
+		}
+		limits[group] = limit
+	}
+	return limits, nil
+}
+
+// isRouteGroup reports whether group is one of RouteGroups
+func isRouteGroup(group string) bool {
+	for _, known := range RouteGroups {
+		if group == known {
+			return true
+		}
+	}
+	return false
+}
+
+// clientLimiter is one client's limiter in a route group
+type clientLimiter struct {
+	key      string
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// limiterGroup holds one route group's limit and its per-client limiters
+type limiterGroup struct {
+	limit   RateLimit
+	clients map[string]*list.Element // of *clientLimiter
+	recent  *list.List               // most recently seen first
+}
+
+// RateLimiter tracks rate limits per route group and client. Clients are
+// authenticated users where known and IP addresses otherwise.
 type RateLimiter struct {
-	limiters map[string]*rate.Limiter
-	mu       sync.RWMutex
+	groups  map[string]*limiterGroup
+	proxies []*net.IPNet // trusted to name the client in X-Forwarded-For
+	mu      sync.Mutex
 }
 
-// NewRateLimiter creates a new rate limiter
+// NewRateLimiter creates a rate limiter with DefaultRateLimits
 func NewRateLimiter() *RateLimiter {
-	return &RateLimiter{
-		limiters: make(map[string]*rate.Limiter),
+	rl := &RateLimiter{groups: make(map[string]*limiterGroup)}
+	for group, limit := range DefaultRateLimits() {
+		rl.SetLimit(group, limit)
+	}
+	return rl
+}
+
+// SetLimit changes a route group's limit. Clients start over with a full
+// burst.
+func (rl *RateLimiter) SetLimit(group string, limit RateLimit) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.groups[group] = &limiterGroup{
+		limit:   limit,
+		clients: make(map[string]*list.Element),
+		recent:  list.New(),
+	}
+}
+
+// Clients returns how many clients a route group is tracking
+func (rl *RateLimiter) Clients(group string) int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if g, ok := rl.groups[group]; ok {
+		return g.recent.Len()
 	}
+	return 0
 }
 
-// getIP extracts client IP from request
-func getIP(r *http.Request) string {
-	ip := r.Header.Get("X-Forwarded-For")
-	if ip != "" {
+// Sweep forgets clients idle for RateLimitIdleTTL at now whose burst has
+// refilled, and returns how many were removed
+func (rl *RateLimiter) Sweep(now time.Time) int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	removed := 0
+	for _, g := range rl.groups {
+		// Oldest first; everything after the first recent client is newer
+		for elem := g.recent.Back(); elem != nil; {
+			c := elem.Value.(*clientLimiter)
+			if now.Sub(c.lastSeen) < RateLimitIdleTTL {
+				break
+			}
+			prev := elem.Prev()
+			if c.limiter.TokensAt(now) >= float64(c.limiter.Burst()) {
+				g.recent.Remove(elem)
+				delete(g.clients, c.key)
+				removed++
+			}
+			elem = prev
+		}
+	}
+	return removed
+}
+
+// StartSweeper sweeps every interval in the background until the returned
+// function is called
+func (rl *RateLimiter) StartSweeper(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				rl.Sweep(now)
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// ParseTrustedProxies reads proxy addresses and CIDR ranges separated by
+// commas, e.g. "10.0.0.0/8,192.0.2.7"
+func ParseTrustedProxies(spec string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, field := range strings.Split(spec, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		if !strings.Contains(field, "/") {
+			ip := net.ParseIP(field)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid proxy address %q", field)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy range %q", field)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// SetTrustedProxies sets the proxies whose X-Forwarded-For is believed.
+// Requests from anywhere else are counted by their own address.
+func (rl *RateLimiter) SetTrustedProxies(proxies []*net.IPNet) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.proxies = proxies
+}
+
+// trusted reports whether an address is a trusted proxy. Caller must hold
+// rl.mu.
+func (rl *RateLimiter) trusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range rl.proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address a request came from. X-Forwarded-For is
+// only read when the peer is a trusted proxy, and then from the right, so
+// addresses a client prepends itself are skipped.
+func (rl *RateLimiter) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if !rl.trusted(ip) {
 		return ip
 	}
-	ip, _, _ = net.SplitHostPort(r.RemoteAddr)
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !rl.trusted(hop) {
+			return hop
+		}
+		ip = hop
+	}
 	return ip
 }
 
-// Allow checks if request is allowed
-func (rl *RateLimiter) Allow(ip string) bool {
+// clientKey identifies who a request counts against: its user once
+// authenticated, otherwise its IP
+func (rl *RateLimiter) clientKey(r *http.Request) string {
+	if caller := CallerFrom(r.Context()); caller != nil && caller.UserID != "" {
+		return "user:" + caller.UserID
+	}
+	return "ip:" + rl.clientIP(r)
+}
+
+// Allow checks if a client may make another request in a route group
+func (rl *RateLimiter) Allow(group, client string) bool {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	limiter, exists := rl.limiters[ip]
-	if !exists {
-		// 100 requests per second per IP
-		limiter = rate.NewLimiter(100, 1)
-		rl.limiters[ip] = limiter
+	g, ok := rl.groups[group]
+	if !ok {
+		g = rl.groups[RouteGroupDefault]
+	}
+	if g == nil || g.limit.PerSecond == 0 {
+		return true
 	}
 
-	return limiter.Allow()
+	now := time.Now()
+	elem, exists := g.clients[client]
+	if exists {
+		g.recent.MoveToFront(elem)
+	} else {
+		if g.recent.Len() >= MaxRateLimitClients {
+			oldest := g.recent.Back()
+			g.recent.Remove(oldest)
+			delete(g.clients, oldest.Value.(*clientLimiter).key)
+		}
+		elem = g.recent.PushFront(&clientLimiter{
+			key:     client,
+			limiter: rate.NewLimiter(rate.Limit(g.limit.PerSecond), g.limit.Burst),
+		})
+		g.clients[client] = elem
+	}
+
+	c := elem.Value.(*clientLimiter)
+	c.lastSeen = now
+	return c.limiter.AllowN(now, 1)
 }
 
-// Middleware returns rate limiting middleware
-func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := getIP(r)
-		if !rl.Allow(ip) {
-			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
+// Limit returns middleware applying a route group's limit. Put it after
+// authentication so users are limited rather than their addresses, except
+// for RouteGroupIP, which goes in front of everything else.
+func (rl *RateLimiter) Limit(group string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !rl.Allow(group, rl.clientKey(r)) {
+				http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
@@ -1,6 +1,36 @@
 package middleware
 
-import "net/http"
+import (
+	"net/http"
+	"net/url"
+)
+
+// SecretQueryParams are query parameters that carry credentials: session
+// tokens for OAuth account linking and provider authorization codes
+var SecretQueryParams = []string{"token", "refresh_token", "code"}
+
+// RedactQueryMiddleware blanks secret query parameters in r.RequestURI,
+// which access logs print. Place it before the logger; handlers still read
+// the real values from r.URL.
+func RedactQueryMiddleware(params ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			redacted := false
+			for _, param := range params {
+				if query.Has(param) {
+					query.Set(param, "REDACTED")
+					redacted = true
+				}
+			}
+			if redacted {
+				uri := url.URL{Path: r.URL.Path, RawPath: r.URL.RawPath, RawQuery: query.Encode()}
+				r.RequestURI = uri.RequestURI()
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
 
 // SecurityHeadersMiddleware adds security headers to responses
 func SecurityHeadersMiddleware(next http.Handler) http.Handler {
@@ -0,0 +1,197 @@
+// Package oauth signs players in with third-party identity providers. A
+// Provider turns the code a provider redirects back with into the identity
+// it vouches for; accounts are linked to identities by the caller.
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
+
+// Provider names
+const (
+	Google = "google"
+	GitHub = "github"
+)
+
+// CallbackPath is where providers redirect back to, under the server's
+// public URL; %s is the provider name
+const CallbackPath = "/api/v1/auth/oauth/%s/callback"
+
+// maxUserInfoBytes caps a provider's user info response
+const maxUserInfoBytes = 1 << 20
+
+// Identity is an account at a provider
+type Identity struct {
+	Provider string
+	Subject  string // the provider's stable account ID
+	Login    string // the account's handle or name, to suggest a username
+	Email    string
+}
+
+// Provider runs one identity provider's authorization code flow
+type Provider interface {
+	Name() string
+	// AuthCodeURL is where to send the browser, carrying state back
+	AuthCodeURL(state string) string
+	// Identify exchanges an authorization code for the account it belongs to
+	Identify(ctx context.Context, code string) (*Identity, error)
+}
+
+// provider is a Provider reading identities from a user info endpoint
+type provider struct {
+	name        string
+	config      *oauth2.Config
+	userInfoURL string
+	parse       func(body []byte) (*Identity, error)
+}
+
+// NewGoogle returns the Google provider
+func NewGoogle(clientID, clientSecret, redirectURL string) Provider {
+	return &provider{
+		name: Google,
+		config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Endpoint:     endpoints.Google,
+			Scopes:       []string{"openid", "email", "profile"},
+		},
+		userInfoURL: "https://openidconnect.googleapis.com/v1/userinfo",
+		parse:       parseGoogleUser,
+	}
+}
+
+// NewGitHub returns the GitHub provider
+func NewGitHub(clientID, clientSecret, redirectURL string) Provider {
+	return &provider{
+		name: GitHub,
+		config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Endpoint:     endpoints.GitHub,
+			Scopes:       []string{"read:user", "user:email"},
+		},
+		userInfoURL: "https://api.github.com/user",
+		parse:       parseGitHubUser,
+	}
+}
+
+// FromEnv returns the providers with credentials in the environment, by
+// name: GOOGLE_CLIENT_ID/GOOGLE_CLIENT_SECRET and
+// GITHUB_CLIENT_ID/GITHUB_CLIENT_SECRET. Callbacks go to OAUTH_REDIRECT_BASE,
+// the server's public URL.
+func FromEnv() map[string]Provider {
+	base := strings.TrimSuffix(os.Getenv("OAUTH_REDIRECT_BASE"), "/")
+	if base == "" {
+		base = "http://localhost:8080"
+	}
+	constructors := map[string]func(clientID, clientSecret, redirectURL string) Provider{
+		Google: NewGoogle,
+		GitHub: NewGitHub,
+	}
+
+	providers := make(map[string]Provider)
+	for name, newProvider := range constructors {
+		prefix := strings.ToUpper(name)
+		id, secret := os.Getenv(prefix+"_CLIENT_ID"), os.Getenv(prefix+"_CLIENT_SECRET")
+		if id == "" || secret == "" {
+			continue
+		}
+		providers[name] = newProvider(id, secret, base+fmt.Sprintf(CallbackPath, name))
+	}
+	return providers
+}
+
+func (p *provider) Name() string {
+	return p.name
+}
+
+func (p *provider) AuthCodeURL(state string) string {
+	return p.config.AuthCodeURL(state)
+}
+
+func (p *provider) Identify(ctx context.Context, code string) (*Identity, error) {
+	token, err := p.config.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("%s: exchange code: %w", p.name, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.userInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.config.Client(ctx, token).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: user info: %w", p.name, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxUserInfoBytes))
+	if err != nil {
+		return nil, fmt.Errorf("%s: user info: %w", p.name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: user info: status %d", p.name, resp.StatusCode)
+	}
+
+	identity, err := p.parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: user info: %w", p.name, err)
+	}
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%s: user info has no account ID", p.name)
+	}
+	identity.Provider = p.name
+	return identity, nil
+}
+
+// parseGoogleUser reads an OpenID Connect user info response. Unverified
+// emails are left out.
+func parseGoogleUser(body []byte) (*Identity, error) {
+	var user struct {
+		Sub           string `json:"sub"`
+		Name          string `json:"name"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err := json.Unmarshal(body, &user); err != nil {
+		return nil, err
+	}
+	identity := &Identity{Subject: user.Sub, Login: user.Name}
+	if user.EmailVerified {
+		identity.Email = user.Email
+		if local, _, ok := strings.Cut(user.Email, "@"); ok {
+			identity.Login = local
+		}
+	}
+	return identity, nil
+}
+
+// parseGitHubUser reads a GitHub user, keyed by its numeric ID since logins
+// can be renamed
+func parseGitHubUser(body []byte) (*Identity, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(body, &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return &Identity{}, nil
+	}
+	return &Identity{Subject: strconv.FormatInt(user.ID, 10), Login: user.Login, Email: user.Email}, nil
+}
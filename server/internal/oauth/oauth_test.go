@@ -0,0 +1,84 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+// TestIdentify exchanges a code and reads the account from a fake GitHub
+func TestIdentify(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "good" {
+			http.Error(w, `{"error":"bad_verification_code"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"secret","token_type":"bearer"}`))
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"id": 42, "login": "octocat", "email": "octo@example.com"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	p := NewGitHub("id", "secret", "http://localhost/callback").(*provider)
+	p.config.Endpoint = oauth2.Endpoint{AuthURL: server.URL + "/authorize", TokenURL: server.URL + "/token"}
+	p.userInfoURL = server.URL + "/user"
+
+	identity, err := p.Identify(context.Background(), "good")
+	if err != nil {
+		t.Fatalf("Failed to identify: %v", err)
+	}
+	if identity.Provider != GitHub || identity.Subject != "42" || identity.Login != "octocat" {
+		t.Errorf("Expected octocat's GitHub account, got %+v", identity)
+	}
+	if _, err := p.Identify(context.Background(), "bad"); err == nil {
+		t.Error("Expected a rejected code to fail")
+	}
+
+	authURL, _ := url.Parse(p.AuthCodeURL("xyz"))
+	if authURL.Query().Get("state") != "xyz" || authURL.Query().Get("redirect_uri") != "http://localhost/callback" {
+		t.Errorf("Expected the state and callback in the sign-in URL, got %s", authURL)
+	}
+}
+
+// TestParseGoogleUser tests that unverified emails are not trusted
+func TestParseGoogleUser(t *testing.T) {
+	identity, _ := parseGoogleUser([]byte(`{"sub": "7", "name": "Ada L", "email": "ada@example.com", "email_verified": true}`))
+	if identity.Subject != "7" || identity.Email != "ada@example.com" || identity.Login != "ada" {
+		t.Errorf("Expected a verified account named after its email, got %+v", identity)
+	}
+	identity, _ = parseGoogleUser([]byte(`{"sub": "8", "name": "Eve", "email": "ada@example.com"}`))
+	if identity.Email != "" || identity.Login != "Eve" {
+		t.Errorf("Expected an unverified email to be dropped, got %+v", identity)
+	}
+}
+
+// TestFromEnv tests that only providers with credentials are enabled
+func TestFromEnv(t *testing.T) {
+	t.Setenv("GITHUB_CLIENT_ID", "id")
+	t.Setenv("GITHUB_CLIENT_SECRET", "secret")
+	t.Setenv("GOOGLE_CLIENT_ID", "id")
+	t.Setenv("GOOGLE_CLIENT_SECRET", "")
+	t.Setenv("OAUTH_REDIRECT_BASE", "https://cards.example.com/")
+
+	providers := FromEnv()
+	if len(providers) != 1 || providers[GitHub] == nil {
+		t.Fatalf("Expected only GitHub, got %v", providers)
+	}
+	authURL, _ := url.Parse(providers[GitHub].AuthCodeURL("s"))
+	if got := authURL.Query().Get("redirect_uri"); got != "https://cards.example.com/api/v1/auth/oauth/github/callback" {
+		t.Errorf("Expected the callback under OAUTH_REDIRECT_BASE, got %s", got)
+	}
+}
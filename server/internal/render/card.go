@@ -0,0 +1,176 @@
+// Package render draws shareable images of game cards
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"sort"
+	"strings"
+)
+
+// Card image template, in pixels
+const (
+	cardWidth  = 360
+	cardHeight = 540
+	margin     = 24
+	border     = 6
+	scale      = 2 // font scale
+	lineGap    = 6
+)
+
+// Template colors
+var (
+	backgroundColor = color.RGBA{0x1d, 0x1b, 0x26, 0xff}
+	borderColor     = color.RGBA{0xc9, 0xa2, 0x4d, 0xff}
+	titleColor      = color.RGBA{0xf4, 0xe4, 0xbc, 0xff}
+	textColor       = color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+	mutedColor      = color.RGBA{0x99, 0x94, 0xa8, 0xff}
+	gainColor       = color.RGBA{0x6c, 0xc0, 0x6a, 0xff}
+	lossColor       = color.RGBA{0xd9, 0x5b, 0x5b, 0xff}
+)
+
+// Choice is one side of a choice card as drawn
+type Choice struct {
+	Label  string         `json:"label"`
+	Deltas map[string]int `json:"deltas,omitempty"` // stat changes the choice makes
+}
+
+// Card is everything drawn on a card image
+type Card struct {
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	Character   string  `json:"character,omitempty"`
+	Left        *Choice `json:"left,omitempty"`
+	Right       *Choice `json:"right,omitempty"`
+}
+
+// PNG draws the card on the shareable template and encodes it as PNG
+func PNG(card Card) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, cardWidth, cardHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{borderColor}, image.Point{}, draw.Src)
+	inner := image.Rect(border, border, cardWidth-border, cardHeight-border)
+	draw.Draw(img, inner, &image.Uniform{backgroundColor}, image.Point{}, draw.Src)
+
+	c := &canvas{img: img, y: margin}
+	c.paragraph(card.Title, titleColor)
+	if card.Character != "" {
+		c.paragraph("- "+card.Character, mutedColor)
+	}
+	c.y += lineHeight
+	c.paragraph(card.Description, textColor)
+
+	for _, side := range []struct {
+		name   string
+		choice *Choice
+	}{{"LEFT:", card.Left}, {"RIGHT:", card.Right}} {
+		if side.choice == nil {
+			continue
+		}
+		c.y += lineHeight
+		c.paragraph(side.name+" "+side.choice.Label, titleColor)
+		c.deltas(side.choice.Deltas)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode card image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Text layout derived from the template
+const (
+	charWidth    = (glyphWidth + 1) * scale
+	lineHeight   = glyphHeight*scale + lineGap
+	charsPerLine = (cardWidth - 2*margin) / charWidth
+)
+
+// canvas draws lines of text down the card, dropping what does not fit
+type canvas struct {
+	img *image.RGBA
+	y   int
+}
+
+// paragraph draws word-wrapped text
+func (c *canvas) paragraph(text string, col color.Color) {
+	for _, line := range wrap(text, charsPerLine) {
+		c.line(margin, line, col)
+	}
+}
+
+// deltas draws stat changes in ID order, gains and losses in their colors
+func (c *canvas) deltas(deltas map[string]int) {
+	ids := make([]string, 0, len(deltas))
+	for id := range deltas {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		col := gainColor
+		if deltas[id] < 0 {
+			col = lossColor
+		}
+		c.line(margin+2*charWidth, fmt.Sprintf("%s %+d", id, deltas[id]), col)
+	}
+}
+
+// line draws one line of text at x and moves down
+func (c *canvas) line(x int, text string, col color.Color) {
+	if c.y+lineHeight > cardHeight-margin {
+		return
+	}
+	for _, r := range text {
+		c.glyph(x, c.y, r, col)
+		x += charWidth
+	}
+	c.y += lineHeight
+}
+
+// glyph draws one scaled character with its top-left corner at x, y
+func (c *canvas) glyph(x, y int, r rune, col color.Color) {
+	src := &image.Uniform{col}
+	for row, bits := range glyph(r) {
+		for column, bit := range bits {
+			if bit != '#' {
+				continue
+			}
+			px := image.Rect(x+column*scale, y+row*scale, x+(column+1)*scale, y+(row+1)*scale)
+			draw.Draw(c.img, px, src, image.Point{}, draw.Src)
+		}
+	}
+}
+
+// wrap splits text into lines of at most width characters, breaking long
+// words
+func wrap(text string, width int) []string {
+	var lines []string
+	var line []rune
+	for _, word := range strings.Fields(text) {
+		w := []rune(word)
+		for len(w) > width {
+			if len(line) > 0 {
+				lines = append(lines, string(line))
+				line = nil
+			}
+			lines = append(lines, string(w[:width]))
+			w = w[width:]
+		}
+		if len(line) > 0 && len(line)+1+len(w) > width {
+			lines = append(lines, string(line))
+			line = nil
+		}
+		if len(line) > 0 {
+			line = append(line, ' ')
+		}
+		line = append(line, w...)
+	}
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+	return lines
+}
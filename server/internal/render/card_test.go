@@ -0,0 +1,56 @@
+package render
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// TestPNG tests that a card renders on the template and stays deterministic
+func TestPNG(t *testing.T) {
+	card := Card{
+		Title:       "The Duel",
+		Description: strings.Repeat("A stranger throws down a gauntlet at your feet. ", 20),
+		Character:   "stranger",
+		Left:        &Choice{Label: "Fight", Deltas: map[string]int{"health": -10, "honor": 15}},
+		Right:       &Choice{Label: "Walk away"},
+	}
+
+	data, err := PNG(card)
+	if err != nil {
+		t.Fatalf("Failed to render card: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to decode card image: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != cardWidth || b.Dy() != cardHeight {
+		t.Errorf("Expected a %dx%d image, got %dx%d", cardWidth, cardHeight, b.Dx(), b.Dy())
+	}
+
+	again, err := PNG(card)
+	if err != nil || !bytes.Equal(data, again) {
+		t.Error("Expected the same card to render the same image")
+	}
+}
+
+// TestWrap tests word wrapping
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"one two three", []string{"one two", "three"}},
+		{"abcdefghij", []string{"abcdefg", "hij"}},
+		{"  spaced   out  ", []string{"spaced", "out"}},
+	}
+
+	for _, tt := range tests {
+		got := wrap(tt.text, 7)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("wrap(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
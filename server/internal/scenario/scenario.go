@@ -0,0 +1,352 @@
+// Package scenario runs scripted playthroughs written as JSON, so designers
+// can pin down how the engine should play out without writing Go. A scenario
+// names a world, feeds the engine the cards the Writer would have written,
+// and lists the choices to make with what the game must look like after
+// each step. Worlds come from the deterministic Architect, so no scenario
+// calls an LLM.
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+	"github.com/qninhdt/world-card-ai-2/server/internal/game"
+)
+
+// Scenario is one scripted playthrough
+type Scenario struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	World       World  `json:"world"`
+	RNGSeed     int64  `json:"rng_seed,omitempty"` // seeds chance rolls and tie-breaks
+	Steps       []Step `json:"steps"`
+}
+
+// World picks the world a scenario plays: the deterministic Architect's
+// world for a seed, or a full schema, with a few parts replaced
+type World struct {
+	Seed         int64                  `json:"seed,omitempty"`
+	Theme        string                 `json:"theme,omitempty"`
+	Schema       *agents.WorldGenSchema `json:"schema,omitempty"`        // used instead of the seed when set
+	InitialStats map[string]int         `json:"initial_stats,omitempty"` // merged over the world's
+	InitialTags  []string               `json:"initial_tags,omitempty"`  // added to the world's
+	PlotNodes    []agents.PlotNodeDef   `json:"plot_nodes,omitempty"`    // replace the world's plot
+}
+
+// Step is one action and what must hold after it. Exactly one of Cards,
+// Draw, Resolve and Advance is set.
+type Step struct {
+	Cards     []map[string]interface{} `json:"cards,omitempty"`     // Writer output added to the deck
+	Draw      int                      `json:"draw,omitempty"`      // cards to draw
+	Resolve   string                   `json:"resolve,omitempty"`   // card ID to resolve
+	Direction string                   `json:"direction,omitempty"` // "left" or "right", with Resolve
+	Advance   bool                     `json:"advance,omitempty"`   // end the week
+	Expect    *Expect                  `json:"expect,omitempty"`
+}
+
+// Expect lists what must hold after a step. Unset fields are not checked;
+// maps only check the keys they list.
+type Expect struct {
+	Error        string          `json:"error,omitempty"` // the step fails with this text
+	Added        *int            `json:"added,omitempty"` // cards the engine accepted
+	Drawn        []string        `json:"drawn,omitempty"` // card IDs drawn, in order
+	Stats        map[string]int  `json:"stats,omitempty"`
+	Tags         map[string]bool `json:"tags,omitempty"` // true for held, false for absent
+	Items        map[string]int  `json:"items,omitempty"`
+	Resources    map[string]int  `json:"resources,omitempty"`
+	PendingPlots []string        `json:"pending_plots,omitempty"` // plot nodes a choice made ready
+	Fired        []string        `json:"fired,omitempty"`         // plot nodes fired by now
+	NotFired     []string        `json:"not_fired,omitempty"`     // plot nodes still waiting
+	Jobs         []string        `json:"jobs,omitempty"`          // queued Writer job types, in order
+	Alive        *bool           `json:"alive,omitempty"`
+	DeathCause   string          `json:"death_cause,omitempty"`
+}
+
+// Load reads a scenario file and checks its steps
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sc Scenario
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if sc.Name == "" {
+		sc.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := sc.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &sc, nil
+}
+
+// LoadDir reads every .json scenario in a directory, in name order
+func LoadDir(dir string) ([]*Scenario, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	scenarios := make([]*Scenario, 0, len(paths))
+	for _, path := range paths {
+		sc, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		scenarios = append(scenarios, sc)
+	}
+	return scenarios, nil
+}
+
+// Validate checks that each step names exactly one action
+func (sc *Scenario) Validate() error {
+	if len(sc.Steps) == 0 {
+		return fmt.Errorf("no steps")
+	}
+	for i, step := range sc.Steps {
+		actions := 0
+		for _, set := range []bool{step.Cards != nil, step.Draw != 0, step.Resolve != "", step.Advance} {
+			if set {
+				actions++
+			}
+		}
+		if actions != 1 {
+			return fmt.Errorf("step %d: expected one of cards, draw, resolve or advance, got %d", i+1, actions)
+		}
+		if step.Draw < 0 {
+			return fmt.Errorf("step %d: draw must be positive", i+1)
+		}
+		if step.Resolve != "" && step.Direction != "left" && step.Direction != "right" {
+			return fmt.Errorf("step %d: direction must be left or right, got %q", i+1, step.Direction)
+		}
+	}
+	return nil
+}
+
+// schema builds the scenario's world
+func (w *World) schema() (*agents.WorldGenSchema, error) {
+	var schema *agents.WorldGenSchema
+	if w.Schema != nil {
+		// Copy so a run never changes the scenario
+		data, err := json.Marshal(w.Schema)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &schema); err != nil {
+			return nil, err
+		}
+	} else {
+		schema = agents.BuildDeterministicWorld(w.Seed, w.Theme)
+	}
+
+	if schema.InitialStats == nil {
+		schema.InitialStats = make(map[string]int)
+	}
+	for id, value := range w.InitialStats {
+		schema.InitialStats[id] = value
+	}
+	schema.InitialTags = append(schema.InitialTags, w.InitialTags...)
+	if w.PlotNodes != nil {
+		schema.PlotNodes = w.PlotNodes
+	}
+	return schema, nil
+}
+
+// Run plays the scenario on a fresh engine and returns the first step whose
+// expectations fail, listing every mismatch in it
+func (sc *Scenario) Run() error {
+	schema, err := sc.World.schema()
+	if err != nil {
+		return err
+	}
+	engine, err := game.NewSeededGameEngine("scenario-"+sc.Name, schema, sc.RNGSeed)
+	if err != nil {
+		return fmt.Errorf("world: %w", err)
+	}
+
+	for i, step := range sc.Steps {
+		if err := runStep(engine, step); err != nil {
+			return fmt.Errorf("step %d (%s): %w", i+1, step.describe(), err)
+		}
+	}
+	return nil
+}
+
+// describe names a step's action for failure messages
+func (step Step) describe() string {
+	switch {
+	case step.Cards != nil:
+		return fmt.Sprintf("add %d cards", len(step.Cards))
+	case step.Draw != 0:
+		return fmt.Sprintf("draw %d", step.Draw)
+	case step.Resolve != "":
+		return fmt.Sprintf("resolve %s %s", step.Resolve, step.Direction)
+	default:
+		return "advance"
+	}
+}
+
+// outcome is what a step's action returned
+type outcome struct {
+	added        int
+	drawn        []string
+	pendingPlots []string
+}
+
+// runStep performs a step's action and checks its expectations
+func runStep(engine *game.GameEngine, step Step) error {
+	var out outcome
+	var err error
+	switch {
+	case step.Cards != nil:
+		// Round-trip so numbers arrive as the Writer's JSON would have them
+		var defs []map[string]interface{}
+		if err := remarshal(step.Cards, &defs); err != nil {
+			return err
+		}
+		out.added = engine.AddCardsFromDefs(defs)
+	case step.Draw != 0:
+		drawn, drawErr := engine.DrawCards(step.Draw)
+		for _, card := range drawn {
+			out.drawn = append(out.drawn, card.GetID())
+		}
+		err = drawErr
+	case step.Resolve != "":
+		result, resolveErr := engine.ResolveCard(step.Resolve, step.Direction)
+		if result != nil {
+			out.pendingPlots = result.PendingPlots
+		}
+		err = resolveErr
+	default:
+		err = engine.AdvanceWeek()
+	}
+
+	expect := step.Expect
+	if expect == nil {
+		expect = &Expect{}
+	}
+	switch {
+	case err != nil && expect.Error == "":
+		return err
+	case err != nil && !strings.Contains(err.Error(), expect.Error):
+		return fmt.Errorf("expected an error containing %q, got %v", expect.Error, err)
+	case err == nil && expect.Error != "":
+		return fmt.Errorf("expected an error containing %q, got none", expect.Error)
+	}
+
+	if mismatches := expect.check(engine, out); len(mismatches) > 0 {
+		return fmt.Errorf("%s", strings.Join(mismatches, "; "))
+	}
+	return nil
+}
+
+// check lists how the game differs from the expectations
+func (expect *Expect) check(engine *game.GameEngine, out outcome) []string {
+	var mismatches []string
+	mismatch := func(format string, args ...interface{}) {
+		mismatches = append(mismatches, fmt.Sprintf(format, args...))
+	}
+	state := engine.GetState()
+
+	if expect.Added != nil && out.added != *expect.Added {
+		mismatch("expected %d cards added, got %d", *expect.Added, out.added)
+	}
+	if expect.Drawn != nil && !reflect.DeepEqual(expect.Drawn, nonNil(out.drawn)) {
+		mismatch("expected drawn %v, got %v", expect.Drawn, out.drawn)
+	}
+	for _, id := range sortedKeys(expect.Stats) {
+		if got, ok := state.Stats[id]; !ok || got != expect.Stats[id] {
+			mismatch("expected stats.%s = %d, got %s", id, expect.Stats[id], valueOf(got, ok))
+		}
+	}
+	for _, id := range sortedKeys(expect.Tags) {
+		if state.Tags[id] != expect.Tags[id] {
+			mismatch("expected tags.%s to be %v", id, expect.Tags[id])
+		}
+	}
+	for _, id := range sortedKeys(expect.Items) {
+		if got := state.Items[id]; got != expect.Items[id] {
+			mismatch("expected items.%s = %d, got %d", id, expect.Items[id], got)
+		}
+	}
+	for _, id := range sortedKeys(expect.Resources) {
+		if got, ok := state.Resources[id]; !ok || got != expect.Resources[id] {
+			mismatch("expected resources.%s = %d, got %s", id, expect.Resources[id], valueOf(got, ok))
+		}
+	}
+	if expect.PendingPlots != nil && !reflect.DeepEqual(expect.PendingPlots, nonNil(out.pendingPlots)) {
+		mismatch("expected pending plots %v, got %v", expect.PendingPlots, out.pendingPlots)
+	}
+
+	dag := engine.GetDAG()
+	for _, id := range expect.Fired {
+		if node := dag.GetNode(id); node == nil || !node.IsFired {
+			mismatch("expected plot node %s to have fired", id)
+		}
+	}
+	for _, id := range expect.NotFired {
+		if node := dag.GetNode(id); node == nil || node.IsFired {
+			mismatch("expected plot node %s not to have fired", id)
+		}
+	}
+
+	if expect.Jobs != nil {
+		jobs := []string{}
+		for _, job := range engine.PendingJobs() {
+			jobs = append(jobs, job.JobType)
+		}
+		if !reflect.DeepEqual(expect.Jobs, jobs) {
+			mismatch("expected jobs %v, got %v", expect.Jobs, jobs)
+		}
+	}
+	if expect.Alive != nil && state.IsAlive != *expect.Alive {
+		mismatch("expected alive to be %v", *expect.Alive)
+	}
+	if expect.DeathCause != "" && state.DeathCause != expect.DeathCause {
+		mismatch("expected death cause %q, got %q", expect.DeathCause, state.DeathCause)
+	}
+	return mismatches
+}
+
+// remarshal copies v into out through JSON
+func remarshal(v, out interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// nonNil turns a nil list into an empty one, so "[]" expects nothing
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+// sortedKeys returns a map's keys in order, so mismatches read the same
+// every run
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// valueOf prints a looked-up value, or "nothing" when it is missing
+func valueOf(value int, ok bool) string {
+	if !ok {
+		return "nothing"
+	}
+	return fmt.Sprint(value)
+}
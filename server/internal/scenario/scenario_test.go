@@ -0,0 +1,59 @@
+package scenario
+
+import (
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+)
+
+var scenarioDir = flag.String("scenarios", "testdata", "directory of scenario files to run")
+
+// TestScenarios plays every scenario file
+func TestScenarios(t *testing.T) {
+	scenarios, err := LoadDir(*scenarioDir)
+	if err != nil {
+		t.Fatalf("Failed to load scenarios: %v", err)
+	}
+	if len(scenarios) == 0 {
+		t.Fatalf("No scenarios in %s", *scenarioDir)
+	}
+	for _, sc := range scenarios {
+		t.Run(sc.Name, func(t *testing.T) {
+			if err := sc.Run(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// TestRunReportsMismatches tests that a failed run names the step and every
+// expectation it missed
+func TestRunReportsMismatches(t *testing.T) {
+	alive := false
+	sc := &Scenario{
+		Name:  "wrong",
+		World: World{Seed: 1, InitialStats: map[string]int{"crew": 50}, PlotNodes: []agents.PlotNodeDef{}},
+		Steps: []Step{
+			{Cards: []map[string]interface{}{{"id": "calm", "title": "Calm Seas", "description": "Nothing stirs."}}},
+			{Draw: 7, Expect: &Expect{Stats: map[string]int{"crew": 40, "gold": 1}, Alive: &alive}},
+		},
+	}
+	err := sc.Run()
+	if err == nil {
+		t.Fatal("Expected the run to fail")
+	}
+	for _, want := range []string{"step 2 (draw 7)", "stats.crew = 40, got 50", "stats.gold = 1, got nothing", "alive to be false"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %q", want, err)
+		}
+	}
+
+	for _, bad := range []Step{{}, {Draw: 1, Advance: true}, {Resolve: "calm", Direction: "up"}} {
+		sc.Steps = []Step{bad}
+		if err := sc.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", bad)
+		}
+	}
+}
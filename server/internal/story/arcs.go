@@ -0,0 +1,133 @@
+package story
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Arc groups plot nodes into a chapter of the story
+type Arc struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Theme string `json:"theme"`
+}
+
+// AddArc registers arc metadata
+func (dag *MacroDAG) AddArc(arc *Arc) error {
+	dag.mu.Lock()
+	defer dag.mu.Unlock()
+
+	if arc.ID == "" {
+		return fmt.Errorf("arc ID is required")
+	}
+	if _, exists := dag.arcs[arc.ID]; exists {
+		return fmt.Errorf("arc %s already exists", arc.ID)
+	}
+	dag.arcs[arc.ID] = arc
+	return nil
+}
+
+// GetArc returns an arc by ID
+func (dag *MacroDAG) GetArc(id string) *Arc {
+	dag.mu.RLock()
+	defer dag.mu.RUnlock()
+	return dag.arcs[id]
+}
+
+// GetArcs returns all arcs sorted by ID
+func (dag *MacroDAG) GetArcs() []*Arc {
+	dag.mu.RLock()
+	defer dag.mu.RUnlock()
+	return dag.sortedArcs()
+}
+
+// sortedArcs returns arcs sorted by ID. Caller must hold dag.mu.
+func (dag *MacroDAG) sortedArcs() []*Arc {
+	arcs := make([]*Arc, 0, len(dag.arcs))
+	for _, arc := range dag.arcs {
+		arcs = append(arcs, arc)
+	}
+	sort.Slice(arcs, func(i, j int) bool { return arcs[i].ID < arcs[j].ID })
+	return arcs
+}
+
+// groupByArc buckets node summaries by the arc of the node they describe.
+// Nodes without an arc are collected under an entry with an empty ID.
+// Caller must hold dag.mu.
+func (dag *MacroDAG) groupByArc(fired, next []map[string]interface{}) []map[string]interface{} {
+	type bucket struct {
+		fired []map[string]interface{}
+		next  []map[string]interface{}
+	}
+	buckets := make(map[string]*bucket)
+	get := func(summary map[string]interface{}) *bucket {
+		arcID := ""
+		if node := dag.nodes[summary["id"].(string)]; node != nil {
+			arcID = node.ArcID
+		}
+		b, ok := buckets[arcID]
+		if !ok {
+			b = &bucket{fired: []map[string]interface{}{}, next: []map[string]interface{}{}}
+			buckets[arcID] = b
+		}
+		return b
+	}
+	for _, summary := range fired {
+		b := get(summary)
+		b.fired = append(b.fired, summary)
+	}
+	for _, summary := range next {
+		b := get(summary)
+		b.next = append(b.next, summary)
+	}
+
+	groups := make([]map[string]interface{}, 0, len(buckets))
+	for _, arc := range dag.sortedArcs() {
+		if b, ok := buckets[arc.ID]; ok {
+			groups = append(groups, map[string]interface{}{
+				"id":          arc.ID,
+				"title":       arc.Title,
+				"theme":       arc.Theme,
+				"fired_nodes": b.fired,
+				"next_nodes":  b.next,
+			})
+		}
+	}
+	if b, ok := buckets[""]; ok {
+		groups = append(groups, map[string]interface{}{
+			"id":          "",
+			"title":       "",
+			"theme":       "",
+			"fired_nodes": b.fired,
+			"next_nodes":  b.next,
+		})
+	}
+	return groups
+}
+
+// arcProgress summarizes fired/total nodes per arc for chaptered progress UIs.
+// Caller must hold dag.mu.
+func (dag *MacroDAG) arcProgress() []map[string]interface{} {
+	progress := make([]map[string]interface{}, 0, len(dag.arcs))
+	for _, arc := range dag.sortedArcs() {
+		total, fired := 0, 0
+		for _, node := range dag.nodes {
+			if node.ArcID != arc.ID {
+				continue
+			}
+			total++
+			if node.IsFired {
+				fired++
+			}
+		}
+		progress = append(progress, map[string]interface{}{
+			"id":       arc.ID,
+			"title":    arc.Title,
+			"theme":    arc.Theme,
+			"total":    total,
+			"fired":    fired,
+			"complete": total > 0 && fired == total,
+		})
+	}
+	return progress
+}
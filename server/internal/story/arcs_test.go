@@ -0,0 +1,61 @@
+package story
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestWriterContextGroupsByArc tests arc grouping in the Writer context
+func TestWriterContextGroupsByArc(t *testing.T) {
+	dag := NewMacroDAG()
+	dag.AddArc(&Arc{ID: "rebellion", Title: "The Rebellion", Theme: "uprising"})
+	dag.AddNode(&PlotNode{ID: "spark", ArcID: "rebellion"})
+	dag.AddNode(&PlotNode{ID: "riot", ArcID: "rebellion", Condition: "true"})
+	dag.AddNode(&PlotNode{ID: "loose"})
+	dag.AddEdge("spark", "riot")
+	dag.FireNode("spark")
+	dag.FireNode("loose")
+
+	ctx := dag.GetWriterContext()
+	groups := ctx["arcs"].([]map[string]interface{})
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 arc groups, got %d", len(groups))
+	}
+	if groups[0]["id"] != "rebellion" {
+		t.Errorf("Expected first group rebellion, got %v", groups[0]["id"])
+	}
+	if n := len(groups[0]["next_nodes"].([]map[string]interface{})); n != 1 {
+		t.Errorf("Expected 1 next node in rebellion, got %d", n)
+	}
+	if groups[1]["id"] != "" {
+		t.Errorf("Expected unassigned group last, got %v", groups[1]["id"])
+	}
+}
+
+// TestDAGJSONRoundTripWithArcs tests arc persistence and legacy loading
+func TestDAGJSONRoundTripWithArcs(t *testing.T) {
+	dag := NewMacroDAG()
+	dag.AddArc(&Arc{ID: "a1", Title: "Act One"})
+	dag.AddNode(&PlotNode{ID: "n1", ArcID: "a1", Condition: "day > 3"})
+
+	data, err := json.Marshal(dag)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	loaded := NewMacroDAG()
+	if err := json.Unmarshal(data, loaded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if loaded.GetArc("a1") == nil || loaded.GetNode("n1").ArcID != "a1" {
+		t.Error("Arc data lost in round trip")
+	}
+
+	legacy := NewMacroDAG()
+	if err := json.Unmarshal([]byte(`[{"id":"old","condition":"day > 1"}]`), legacy); err != nil {
+		t.Fatalf("Legacy unmarshal failed: %v", err)
+	}
+	if legacy.GetNode("old") == nil {
+		t.Error("Legacy node not loaded")
+	}
+}
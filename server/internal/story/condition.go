@@ -0,0 +1,464 @@
+package story
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+	"github.com/expr-lang/expr/vm"
+)
+
+// compiledCondition is a compiled expression plus the state paths it reads
+type compiledCondition struct {
+	program      *vm.Program
+	dependencies []string // e.g. "stats.health", "tags", "day"
+}
+
+// errNonBoolean is returned when a condition yields a non-boolean value
+var errNonBoolean = errors.New("condition did not evaluate to boolean")
+
+// programCacheSize bounds how many compiled conditions are kept
+const programCacheSize = 1024
+
+// programEntry is a compiled condition in the program cache
+type programEntry struct {
+	source string
+	cond   *compiledCondition
+}
+
+// programCache shares compiled conditions across nodes and events by
+// source, evicting the least recently used beyond programCacheSize
+var programCache = struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element // of *programEntry
+	recent  *list.List               // most recently used first
+}{
+	entries: make(map[string]*list.Element),
+	recent:  list.New(),
+}
+
+// compileCondition compiles an expression once and records its dependencies
+func compileCondition(source string) (*compiledCondition, error) {
+	programCache.mu.Lock()
+	if elem, ok := programCache.entries[source]; ok {
+		programCache.recent.MoveToFront(elem)
+		programCache.mu.Unlock()
+		return elem.Value.(*programEntry).cond, nil
+	}
+	programCache.mu.Unlock()
+
+	program, err := expr.Compile(source)
+	if err != nil {
+		return nil, err
+	}
+
+	deps, err := ConditionDependencies(source)
+	if err != nil {
+		return nil, err
+	}
+
+	cond := &compiledCondition{program: program, dependencies: deps}
+
+	programCache.mu.Lock()
+	defer programCache.mu.Unlock()
+	// Another caller may have compiled the same source meanwhile
+	if elem, ok := programCache.entries[source]; ok {
+		programCache.recent.MoveToFront(elem)
+		return elem.Value.(*programEntry).cond, nil
+	}
+	programCache.entries[source] = programCache.recent.PushFront(&programEntry{source: source, cond: cond})
+	if programCache.recent.Len() > programCacheSize {
+		oldest := programCache.recent.Back()
+		programCache.recent.Remove(oldest)
+		delete(programCache.entries, oldest.Value.(*programEntry).source)
+	}
+	return cond, nil
+}
+
+// dependencyVisitor collects identifier and member accesses from an AST
+type dependencyVisitor struct {
+	members     map[string]bool
+	identifiers []*ast.IdentifierNode
+	consumed    map[*ast.IdentifierNode]bool
+}
+
+// Visit implements ast.Visitor
+func (v *dependencyVisitor) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.IdentifierNode:
+		v.identifiers = append(v.identifiers, n)
+	case *ast.MemberNode:
+		base, ok := n.Node.(*ast.IdentifierNode)
+		if !ok {
+			return
+		}
+		if prop, ok := n.Property.(*ast.StringNode); ok {
+			v.members[base.Value+"."+prop.Value] = true
+			v.consumed[base] = true
+		}
+	case *ast.CallNode:
+		if callee, ok := n.Callee.(*ast.IdentifierNode); ok {
+			v.consumed[callee] = true
+			// has_item("x") reads items.x
+			if callee.Value == HasItemFunc && len(n.Arguments) > 0 {
+				if id, ok := n.Arguments[0].(*ast.StringNode); ok {
+					v.members["items."+id.Value] = true
+				}
+			}
+		}
+	}
+}
+
+// HasItemFunc is the condition function that tests whether the player
+// holds an item, as has_item("iron_key")
+const HasItemFunc = "has_item"
+
+// ConditionDependencies returns the sorted state paths an expression reads.
+// Member access like stats.health or tags["x"] yields "stats.health" and
+// "tags.x", and has_item("x") yields "items.x"; any other use of a variable
+// yields the bare name (e.g. "tags").
+func ConditionDependencies(source string) ([]string, error) {
+	tree, err := parser.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &dependencyVisitor{
+		members:  make(map[string]bool),
+		consumed: make(map[*ast.IdentifierNode]bool),
+	}
+	ast.Walk(&tree.Node, v)
+
+	seen := make(map[string]bool)
+	deps := make([]string, 0, len(v.members)+len(v.identifiers))
+	for path := range v.members {
+		seen[path] = true
+		deps = append(deps, path)
+	}
+	for _, ident := range v.identifiers {
+		if v.consumed[ident] || seen[ident.Value] {
+			continue
+		}
+		seen[ident.Value] = true
+		deps = append(deps, ident.Value)
+	}
+	sort.Strings(deps)
+	return deps, nil
+}
+
+// Bound is the inclusive range a condition allows a state path
+type Bound struct {
+	Min float64 // -Inf when unbounded
+	Max float64 // +Inf when unbounded
+}
+
+// Empty reports whether no value fits the bound
+func (b Bound) Empty() bool {
+	return b.Min > b.Max
+}
+
+// ConditionBounds returns the ranges an expression requires of state paths
+// it compares with numbers, e.g. "elapsed_days >= 28 && stats.gold < 10"
+// yields elapsed_days in [28, +Inf] and stats.gold in [-Inf, 9]. Only
+// comparisons joined by && count, so the bounds are necessary but not
+// sufficient; paths are taken to hold whole numbers.
+func ConditionBounds(source string) (map[string]Bound, error) {
+	tree, err := parser.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := make(map[string]Bound)
+	var walk func(node ast.Node)
+	walk = func(node ast.Node) {
+		n, ok := node.(*ast.BinaryNode)
+		if !ok {
+			return
+		}
+		if n.Operator == "&&" || n.Operator == "and" {
+			walk(n.Left)
+			walk(n.Right)
+			return
+		}
+		c, ok := comparison(n)
+		if !ok {
+			return
+		}
+		b, ok := bounds[c.Path]
+		if !ok {
+			b = c.Bound
+		} else {
+			b.Min = math.Max(b.Min, c.Bound.Min)
+			b.Max = math.Min(b.Max, c.Bound.Max)
+		}
+		bounds[c.Path] = b
+	}
+	walk(tree.Node)
+	return bounds, nil
+}
+
+// Comparison is a comparison of a state path with a number, as written in
+// a condition, and the range it allows the path
+type Comparison struct {
+	Expr  string
+	Path  string
+	Bound Bound
+}
+
+// ConditionComparisons returns every comparison of a state path with a
+// number in an expression, wherever it appears
+func ConditionComparisons(source string) ([]Comparison, error) {
+	tree, err := parser.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	comparisons := make([]Comparison, 0)
+	ast.Find(tree.Node, func(node ast.Node) bool {
+		if n, ok := node.(*ast.BinaryNode); ok {
+			if c, ok := comparison(n); ok {
+				comparisons = append(comparisons, c)
+			}
+		}
+		return false
+	})
+	return comparisons, nil
+}
+
+// ConditionTags returns the sorted tag IDs an expression tests, as
+// tags.x, tags["x"] or "x" in tags
+func ConditionTags(source string) ([]string, error) {
+	tree, err := parser.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	ast.Find(tree.Node, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.MemberNode:
+			if path, ok := conditionPath(n); ok && strings.HasPrefix(path, "tags.") {
+				seen[strings.TrimPrefix(path, "tags.")] = true
+			}
+		case *ast.BinaryNode:
+			name, isString := n.Left.(*ast.StringNode)
+			base, isIdent := n.Right.(*ast.IdentifierNode)
+			if n.Operator == "in" && isString && isIdent && base.Value == "tags" {
+				seen[name.Value] = true
+			}
+		}
+		return false
+	})
+	tags := make([]string, 0, len(seen))
+	for tag := range seen {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// comparison reads a comparison of a state path with a number, the number
+// on either side
+func comparison(n *ast.BinaryNode) (Comparison, bool) {
+	op := n.Operator
+	path, isPath := conditionPath(n.Left)
+	value, isNumber := conditionNumber(n.Right)
+	if !isPath || !isNumber {
+		// The number may come first, as in 10 < stats.gold
+		path, isPath = conditionPath(n.Right)
+		value, isNumber = conditionNumber(n.Left)
+		if !isPath || !isNumber {
+			return Comparison{}, false
+		}
+		op = map[string]string{"<": ">", "<=": ">=", ">": "<", ">=": "<=", "==": "=="}[op]
+	}
+
+	b := Bound{Min: math.Inf(-1), Max: math.Inf(1)}
+	switch op {
+	case ">=":
+		b.Min = math.Ceil(value)
+	case ">":
+		b.Min = math.Floor(value) + 1
+	case "<=":
+		b.Max = math.Floor(value)
+	case "<":
+		b.Max = math.Ceil(value) - 1
+	case "==":
+		b.Min, b.Max = value, value
+	default:
+		return Comparison{}, false
+	}
+	return Comparison{Expr: n.String(), Path: path, Bound: b}, true
+}
+
+// conditionPath returns the state path a node reads, e.g. "stats.gold" or
+// "elapsed_days"
+func conditionPath(node ast.Node) (string, bool) {
+	switch n := node.(type) {
+	case *ast.IdentifierNode:
+		return n.Value, true
+	case *ast.MemberNode:
+		base, ok := n.Node.(*ast.IdentifierNode)
+		prop, isString := n.Property.(*ast.StringNode)
+		if ok && isString {
+			return base.Value + "." + prop.Value, true
+		}
+	}
+	return "", false
+}
+
+// conditionNumber returns the value of a numeric literal
+func conditionNumber(node ast.Node) (float64, bool) {
+	switch n := node.(type) {
+	case *ast.IntegerNode:
+		return float64(n.Value), true
+	case *ast.FloatNode:
+		return n.Value, true
+	case *ast.UnaryNode:
+		if value, ok := conditionNumber(n.Node); ok && n.Operator == "-" {
+			return -value, true
+		}
+	}
+	return 0, false
+}
+
+// run evaluates the condition against state
+func (cond *compiledCondition) run(state map[string]interface{}) (bool, error) {
+	result, err := vm.Run(cond.program, state)
+	if err != nil {
+		return false, err
+	}
+	boolResult, ok := result.(bool)
+	if !ok {
+		return false, errNonBoolean
+	}
+	return boolResult, nil
+}
+
+// EvaluateExpression evaluates a standalone condition, e.g. an event's end
+// condition
+func EvaluateExpression(source string, state map[string]interface{}) (bool, error) {
+	if source == "" {
+		return true, nil
+	}
+	cond, err := compileCondition(source)
+	if err != nil {
+		return false, fmt.Errorf("invalid condition: %w", err)
+	}
+	return cond.run(state)
+}
+
+// resolvePath looks up a dependency path in a condition state map
+func resolvePath(state map[string]interface{}, path string) interface{} {
+	base, key, ok := strings.Cut(path, ".")
+	if !ok {
+		return state[path]
+	}
+	switch m := state[base].(type) {
+	case map[string]int:
+		if v, ok := m[key]; ok {
+			return v
+		}
+	case map[string]bool:
+		if v, ok := m[key]; ok {
+			return v
+		}
+	case map[string]interface{}:
+		return m[key]
+	}
+	return nil
+}
+
+// fingerprint captures the current values of state paths. The state shares
+// the game's maps, so values are printed rather than kept; fmt prints maps
+// with sorted keys, so whole-map dependencies compare stably.
+func fingerprint(paths []string, state map[string]interface{}) string {
+	values := make([]interface{}, len(paths))
+	for i, path := range paths {
+		values[i] = resolvePath(state, path)
+	}
+	return fmt.Sprint(values)
+}
+
+// cachedResult is a remembered condition result
+type cachedResult struct {
+	program     *vm.Program // the program that produced it
+	fingerprint string      // of the condition's dependencies; empty where the DAG's index tracks them
+	result      bool
+}
+
+// ConditionCache remembers condition results between checks of one game,
+// so a condition only runs again once a state path it reads changes value
+// or the condition itself changes, e.g. when a deadline loosens it
+type ConditionCache struct {
+	values  map[string]string // state path -> fingerprint at the last DAG check
+	results map[string]cachedResult
+	Hits    int
+	Misses  int
+}
+
+// NewConditionCache creates an empty cache
+func NewConditionCache() *ConditionCache {
+	return &ConditionCache{
+		values:  make(map[string]string),
+		results: make(map[string]cachedResult),
+	}
+}
+
+// changed records the current value of each path and returns those whose
+// value differs from the last check
+func (c *ConditionCache) changed(paths []string, state map[string]interface{}) []string {
+	changed := make([]string, 0)
+	for _, path := range paths {
+		fp := fingerprint([]string{path}, state)
+		if previous, ok := c.values[path]; !ok || previous != fp {
+			c.values[path] = fp
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+// evaluate returns the condition's result under key, running it only when
+// no result is cached for its program and fingerprint. A nil cache always
+// runs it.
+func (c *ConditionCache) evaluate(key, fp string, cond *compiledCondition, state map[string]interface{}) (bool, error) {
+	if c != nil {
+		if cached, ok := c.results[key]; ok && cached.program == cond.program && cached.fingerprint == fp {
+			c.Hits++
+			return cached.result, nil
+		}
+		c.Misses++
+	}
+
+	result, err := cond.run(state)
+	if err != nil {
+		return false, err
+	}
+	if c != nil {
+		c.results[key] = cachedResult{program: cond.program, fingerprint: fp, result: result}
+	}
+	return result, nil
+}
+
+// EvaluateCached evaluates a standalone condition under key, reusing its
+// last result while the values it reads are unchanged. cache may be nil.
+func EvaluateCached(cache *ConditionCache, key, source string, state map[string]interface{}) (bool, error) {
+	if source == "" {
+		return true, nil
+	}
+	cond, err := compileCondition(source)
+	if err != nil {
+		return false, fmt.Errorf("invalid condition: %w", err)
+	}
+	var fp string
+	if cache != nil {
+		fp = fingerprint(cond.dependencies, state)
+	}
+	return cache.evaluate(key, fp, cond, state)
+}
@@ -0,0 +1,249 @@
+package story
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// TestConditionDependencies tests dependency extraction from expressions
+func TestConditionDependencies(t *testing.T) {
+	cases := map[string][]string{
+		`stats.health > 50`:                              {"stats.health"},
+		`stats["gold"] < 10 && tags.cursed`:              {"stats.gold", "tags.cursed"},
+		`"blessed" in tags || day > 7`:                   {"day", "tags"},
+		`len(tags) > 2 and stats.health == stats.health`: {"stats.health", "tags"},
+	}
+
+	for source, want := range cases {
+		got, err := ConditionDependencies(source)
+		if err != nil {
+			t.Fatalf("ConditionDependencies(%q) failed: %v", source, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ConditionDependencies(%q) = %v, want %v", source, got, want)
+		}
+	}
+}
+
+// TestConditionBounds tests reading the ranges a condition requires
+func TestConditionBounds(t *testing.T) {
+	inf := math.Inf(1)
+	cases := map[string]map[string]Bound{
+		`elapsed_days >= 28 && stats.gold < 10`: {"elapsed_days": {28, inf}, "stats.gold": {-inf, 9}},
+		`10 < stats.gold and stats.gold <= 20`:  {"stats.gold": {11, 20}},
+		`year == 2 && tags.crowned`:             {"year": {2, 2}},
+		`stats.gold > 90 || stats.gold < 10`:    {},
+		`season > -1`:                           {"season": {0, inf}},
+	}
+
+	for source, want := range cases {
+		got, err := ConditionBounds(source)
+		if err != nil {
+			t.Fatalf("ConditionBounds(%q) failed: %v", source, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ConditionBounds(%q) = %v, want %v", source, got, want)
+		}
+	}
+	if b, _ := ConditionBounds(`stats.gold > 50 && stats.gold < 20`); !b["stats.gold"].Empty() {
+		t.Errorf("Expected contradictory bounds to be empty, got %v", b["stats.gold"])
+	}
+}
+
+// TestConditionComparisonsAndTags tests listing every numeric comparison
+// and tag test in a condition
+func TestConditionComparisonsAndTags(t *testing.T) {
+	source := `stats.health > 150 || (tags.cursed && "blessed" in tags && tags["marked"] && 3 >= day)`
+	comparisons, err := ConditionComparisons(source)
+	if err != nil {
+		t.Fatalf("ConditionComparisons failed: %v", err)
+	}
+	want := []Comparison{
+		{Expr: "stats.health > 150", Path: "stats.health", Bound: Bound{151, math.Inf(1)}},
+		{Expr: "3 >= day", Path: "day", Bound: Bound{math.Inf(-1), 3}},
+	}
+	if !reflect.DeepEqual(comparisons, want) {
+		t.Errorf("ConditionComparisons = %+v, want %+v", comparisons, want)
+	}
+
+	tags, err := ConditionTags(source)
+	if err != nil {
+		t.Fatalf("ConditionTags failed: %v", err)
+	}
+	if !reflect.DeepEqual(tags, []string{"blessed", "cursed", "marked"}) {
+		t.Errorf("ConditionTags = %v", tags)
+	}
+}
+
+// TestProgramCacheBounded tests that compiled conditions are shared by
+// source and the least recently used are evicted past the bound
+func TestProgramCacheBounded(t *testing.T) {
+	first, err := compileCondition("stats.health > 50")
+	if err != nil {
+		t.Fatalf("compileCondition failed: %v", err)
+	}
+	again, _ := compileCondition("stats.health > 50")
+	if again != first {
+		t.Error("Expected the same source to share its compiled condition")
+	}
+
+	for i := 0; i < programCacheSize; i++ {
+		if _, err := compileCondition(fmt.Sprintf("day > %d", i)); err != nil {
+			t.Fatalf("compileCondition failed: %v", err)
+		}
+	}
+
+	programCache.mu.Lock()
+	size := programCache.recent.Len()
+	_, kept := programCache.entries["stats.health > 50"]
+	programCache.mu.Unlock()
+	if size != programCacheSize {
+		t.Errorf("Expected %d cached conditions, got %d", programCacheSize, size)
+	}
+	if kept {
+		t.Error("Expected the least recently used condition to be evicted")
+	}
+
+	state := map[string]interface{}{"stats": map[string]int{"health": 70}}
+	if ok, err := EvaluateExpression("stats.health > 50", state); err != nil || !ok {
+		t.Errorf("Expected an evicted condition to compile again, got %v (%v)", ok, err)
+	}
+}
+
+// TestConditionCache tests that plot conditions run again only when a
+// path they read changed or their condition did, and that standalone
+// conditions are reused while their values hold
+func TestConditionCache(t *testing.T) {
+	dag := NewMacroDAG()
+	dag.AddNode(&PlotNode{ID: "rich", Condition: "stats.gold > 80"})
+	dag.AddNode(&PlotNode{ID: "cursed", Condition: "tags.cursed"})
+	dag.AddNode(&PlotNode{ID: "harvest", Condition: "stats.food > 80", Deadline: &SoftDeadline{Week: 1, Action: DeadlineActionLoosen, FallbackCondition: "stats.food > 10"}})
+
+	cache := NewConditionCache()
+	stats := map[string]int{"gold": 10, "food": 20}
+	state := map[string]interface{}{"stats": stats, "tags": map[string]bool{}}
+	check := func(hits, misses int, want ...string) {
+		t.Helper()
+		nodes, err := dag.GetActivatableNodesCached(state, cache)
+		if err != nil {
+			t.Fatalf("GetActivatableNodesCached failed: %v", err)
+		}
+		ids := make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			ids = append(ids, node.ID)
+		}
+		if len(want) == 0 {
+			want = []string{}
+		}
+		if !reflect.DeepEqual(ids, want) {
+			t.Errorf("Expected %v activatable, got %v", want, ids)
+		}
+		if cache.Hits != hits || cache.Misses != misses {
+			t.Errorf("Expected %d hits and %d misses, got %d and %d", hits, misses, cache.Hits, cache.Misses)
+		}
+	}
+
+	check(0, 3)
+	check(3, 3)
+
+	// The state shares the game's maps, so changes in place are caught
+	stats["gold"] = 90
+	check(5, 4, "rich")
+
+	// A loosened deadline swaps the condition without any value changing
+	if _, err := dag.EscalateDeadline("harvest"); err != nil {
+		t.Fatalf("EscalateDeadline failed: %v", err)
+	}
+	check(7, 5, "harvest", "rich")
+
+	cache = NewConditionCache()
+	for i, want := range []bool{false, false, true} {
+		if i == 2 {
+			stats["food"] = 100
+		}
+		met, err := EvaluateCached(cache, "event:feast", "stats.food > 50", state)
+		if err != nil || met != want {
+			t.Errorf("Evaluation %d: expected %v, got %v (%v)", i, want, met, err)
+		}
+	}
+	if cache.Hits != 1 || cache.Misses != 2 {
+		t.Errorf("Expected 1 hit and 2 misses, got %d and %d", cache.Hits, cache.Misses)
+	}
+}
+
+// TestPendingHints tests that only dependent nodes are re-checked
+func TestPendingHints(t *testing.T) {
+	dag := NewMacroDAG()
+	dag.AddNode(&PlotNode{ID: "rich", Condition: "stats.gold > 80"})
+	dag.AddNode(&PlotNode{ID: "cursed", Condition: "tags.cursed"})
+	dag.AddNode(&PlotNode{ID: "after", Condition: "stats.gold > 80"})
+	dag.AddEdge("cursed", "after")
+
+	if got := dag.NodesDependingOn([]string{"stats.gold"}); !reflect.DeepEqual(got, []string{"after", "rich"}) {
+		t.Errorf("Expected [after rich], got %v", got)
+	}
+
+	state := map[string]interface{}{
+		"stats": map[string]int{"gold": 90},
+		"tags":  map[string]bool{"cursed": true},
+	}
+	hints := dag.PendingHints([]string{"stats.gold"}, state)
+	if len(hints) != 1 || hints[0].ID != "rich" {
+		t.Errorf("Expected only rich (after has unfired predecessor), got %v", hints)
+	}
+}
+
+// TestRecompileReportsFailures tests recompiling conditions and rebuilding the index
+func TestRecompileReportsFailures(t *testing.T) {
+	dag := NewMacroDAG()
+	dag.AddNode(&PlotNode{ID: "rich", Condition: "stats.gold > 80"})
+	dag.AddNode(&PlotNode{ID: "broken", Condition: "tags.cursed"})
+	dag.GetNode("broken").Condition = "tags.cursed &&"
+
+	failures := dag.Recompile()
+	if len(failures) != 1 || failures["broken"] == nil {
+		t.Fatalf("Expected only broken to fail, got %v", failures)
+	}
+	if got := dag.NodesDependingOn([]string{"tags.cursed"}); len(got) != 0 {
+		t.Errorf("Expected broken node dropped from index, got %v", got)
+	}
+	if got := dag.NodesDependingOn([]string{"stats.gold"}); !reflect.DeepEqual(got, []string{"rich"}) {
+		t.Errorf("Expected [rich], got %v", got)
+	}
+}
+
+// TestEscalateDeadline tests that a loosened node switches its condition and
+// index once, that a broken fallback is caught when the node is added, and
+// that resurrection restores the original condition
+func TestEscalateDeadline(t *testing.T) {
+	dag := NewMacroDAG()
+	if err := dag.AddNode(&PlotNode{ID: "broken", Condition: "stats.gold > 80", Deadline: &SoftDeadline{Week: 1, Action: DeadlineActionLoosen, FallbackCondition: "stats.gold >"}}); err == nil {
+		t.Error("Expected a broken fallback condition to be rejected")
+	}
+
+	dag.AddNode(&PlotNode{ID: "harvest", Condition: "stats.food > 80", Deadline: &SoftDeadline{Week: 1, Action: DeadlineActionLoosen, FallbackCondition: "stats.food > 10"}})
+	for i := 0; i < 2; i++ {
+		if _, err := dag.EscalateDeadline("harvest"); err != nil {
+			t.Fatalf("EscalateDeadline failed: %v", err)
+		}
+	}
+	if got := dag.NodesDependingOn([]string{"stats.food"}); !reflect.DeepEqual(got, []string{"harvest"}) {
+		t.Errorf("Expected harvest indexed once, got %v", got)
+	}
+	if met, _ := dag.CheckCondition("harvest", map[string]interface{}{"stats": map[string]int{"food": 20}}); !met {
+		t.Error("Expected the fallback condition to hold")
+	}
+
+	dag.PartialReset()
+	if dag.GetNode("harvest").Deadline.Escalated {
+		t.Error("Expected PartialReset to clear the escalation")
+	}
+	if met, _ := dag.CheckCondition("harvest", map[string]interface{}{"stats": map[string]int{"food": 20}}); met {
+		t.Error("Expected the original condition after PartialReset")
+	}
+	if got := dag.NodesDependingOn([]string{"stats.food"}); !reflect.DeepEqual(got, []string{"harvest"}) {
+		t.Errorf("Expected harvest indexed once after PartialReset, got %v", got)
+	}
+}
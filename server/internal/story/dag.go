@@ -1,13 +1,15 @@
 package story
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
-	"github.com/expr-lang/expr"
 	"github.com/expr-lang/expr/vm"
 	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
 )
@@ -19,22 +21,34 @@ type PlotNode struct {
 	Condition        string                   `json:"condition"`
 	Calls            []agents.FunctionCall    `json:"calls"`
 	IsEnding         bool                     `json:"is_ending"`
+	EndingTier       string                   `json:"ending_tier,omitempty"`
 	IsFired          bool                     `json:"is_fired"`
+	ArcID            string                   `json:"arc_id,omitempty"`
+	Deadline         *SoftDeadline            `json:"deadline,omitempty"`
+	ExclusiveGroup   string                   `json:"exclusive_group,omitempty"` // firing one member prunes the rest
+	IsPruned         bool                     `json:"is_pruned"`
 	PredecessorIDs   []string                 `json:"predecessor_ids"`
 	SuccessorIDs     []string                 `json:"successor_ids"`
 	compiledProgram  *vm.Program              `json:"-"`
+	dependencies     []string
+	condition        *compiledCondition // nil for an empty condition
+	fallback         *compiledCondition // a "loosen" deadline's fallback; nil when always true
 }
 
 // MacroDAG wraps a directed acyclic graph for story progression
 type MacroDAG struct {
-	nodes map[string]*PlotNode
-	mu    sync.RWMutex
+	nodes    map[string]*PlotNode
+	depIndex map[string][]string // dependency path -> node IDs
+	arcs     map[string]*Arc
+	mu       sync.RWMutex
 }
 
 // NewMacroDAG creates a new empty DAG
 func NewMacroDAG() *MacroDAG {
 	return &MacroDAG{
-		nodes: make(map[string]*PlotNode),
+		nodes:    make(map[string]*PlotNode),
+		depIndex: make(map[string][]string),
+		arcs:     make(map[string]*Arc),
 	}
 }
 
@@ -48,18 +62,54 @@ func (dag *MacroDAG) AddNode(node *PlotNode) error {
 	}
 
 	// Pre-compile condition expression
+	if err := node.compile(); err != nil {
+		return err
+	}
+
+	dag.nodes[node.ID] = node
+	dag.indexNode(node)
+	return nil
+}
+
+// compile compiles the node's condition, and the fallback of a "loosen"
+// deadline so a broken one is caught before it is needed, then records the
+// active condition's dependencies
+func (node *PlotNode) compile() error {
+	var condition, fallback *compiledCondition
+	var err error
 	if node.Condition != "" {
-		program, err := expr.Compile(node.Condition)
-		if err != nil {
+		if condition, err = compileCondition(node.Condition); err != nil {
 			return fmt.Errorf("invalid condition for node %s: %w", node.ID, err)
 		}
-		node.compiledProgram = program
 	}
-
-	dag.nodes[node.ID] = node
+	if node.Deadline != nil && node.Deadline.Action == DeadlineActionLoosen && node.Deadline.FallbackCondition != "" {
+		if fallback, err = compileCondition(node.Deadline.FallbackCondition); err != nil {
+			return fmt.Errorf("invalid fallback condition for node %s: %w", node.ID, err)
+		}
+	}
+	node.condition, node.fallback = condition, fallback
+	node.activate()
 	return nil
 }
 
+// activate points the node at its compiled condition, or at the fallback
+// once a "loosen" deadline has escalated
+func (node *PlotNode) activate() {
+	active := node.condition
+	if node.loosened() {
+		active = node.fallback
+	}
+	node.compiledProgram, node.dependencies = nil, nil
+	if active != nil {
+		node.compiledProgram, node.dependencies = active.program, active.dependencies
+	}
+}
+
+// Dependencies returns the state paths the node's condition reads
+func (node *PlotNode) Dependencies() []string {
+	return node.dependencies
+}
+
 // AddEdge adds a directed edge from one node to another
 func (dag *MacroDAG) AddEdge(fromID, toID string) error {
 	dag.mu.Lock()
@@ -111,16 +161,14 @@ func (dag *MacroDAG) CheckCondition(nodeID string, state map[string]interface{})
 		return false, fmt.Errorf("node %s not found", nodeID)
 	}
 
-	if node.Condition == "" {
+	if node.ActiveCondition() == "" {
 		return true, nil // no condition = always true
 	}
 
 	if node.compiledProgram == nil {
-		program, err := expr.Compile(node.Condition)
-		if err != nil {
-			return false, fmt.Errorf("invalid condition: %w", err)
+		if err := node.compile(); err != nil {
+			return false, err
 		}
-		node.compiledProgram = program
 	}
 
 	// SECURITY FIX: Add timeout to prevent DoS
@@ -155,53 +203,61 @@ func (dag *MacroDAG) CheckCondition(nodeID string, state map[string]interface{})
 }
 
 // GetActivatableNodes returns nodes that are ready to fire
-// (all predecessors fired AND condition met)
+// (all predecessors fired AND condition met), ordered by ID
 func (dag *MacroDAG) GetActivatableNodes(state map[string]interface{}) ([]*PlotNode, error) {
+	return dag.GetActivatableNodesCached(state, nil)
+}
+
+// GetActivatableNodesCached is GetActivatableNodes reusing the results in
+// cache. The dependency index names the nodes reading a state path that
+// changed since the cache's last check; only they, and nodes whose
+// condition changed, run again. cache may be nil.
+func (dag *MacroDAG) GetActivatableNodesCached(state map[string]interface{}, cache *ConditionCache) ([]*PlotNode, error) {
 	dag.mu.RLock()
 	defer dag.mu.RUnlock()
 
+	if cache != nil {
+		paths := make([]string, 0, len(dag.depIndex))
+		for path := range dag.depIndex {
+			paths = append(paths, path)
+		}
+		for _, id := range dag.nodesDependingOn(cache.changed(paths, state)) {
+			delete(cache.results, nodeCacheKey(id))
+		}
+	}
+
 	var activatable []*PlotNode
 
 	for _, node := range dag.nodes {
-		if node.IsFired {
-			continue // already fired
+		if node.IsFired || node.IsPruned {
+			continue // already fired or abandoned branch
 		}
 
-		// Check if all predecessors are fired
-		allPredecessorsFired := true
-		if len(node.PredecessorIDs) == 0 {
-			allPredecessorsFired = true // no predecessors = can fire
-		} else {
-			for _, predID := range node.PredecessorIDs {
-				pred := dag.nodes[predID]
-				if !pred.IsFired {
-					allPredecessorsFired = false
-					break
-				}
-			}
-		}
-
-		if !allPredecessorsFired {
+		// Check if all predecessors are fired (pruned ones are skipped)
+		if !dag.predecessorsSatisfied(node) {
 			continue
 		}
 
 		// Check condition
-		if node.Condition != "" {
+		if node.ActiveCondition() != "" {
+			nodeCache := cache
 			if node.compiledProgram == nil {
-				program, err := expr.Compile(node.Condition)
-				if err != nil {
-					return nil, fmt.Errorf("invalid condition for node %s: %w", node.ID, err)
+				if err := node.compile(); err != nil {
+					return nil, err
 				}
-				node.compiledProgram = program
+				// Not in the dependency index, so its result cannot be kept
+				nodeCache = nil
 			}
 
-			result, err := vm.Run(node.compiledProgram, state)
+			cond := &compiledCondition{program: node.compiledProgram, dependencies: node.dependencies}
+			result, err := nodeCache.evaluate(nodeCacheKey(node.ID), "", cond, state)
+			if errors.Is(err, errNonBoolean) {
+				continue
+			}
 			if err != nil {
 				return nil, fmt.Errorf("condition evaluation error for node %s: %w", node.ID, err)
 			}
-
-			boolResult, ok := result.(bool)
-			if !ok || !boolResult {
+			if !result {
 				continue
 			}
 		}
@@ -209,9 +265,16 @@ func (dag *MacroDAG) GetActivatableNodes(state map[string]interface{}) ([]*PlotN
 		activatable = append(activatable, node)
 	}
 
+	// Map order varies run to run; callers breaking ties need a stable one
+	sort.Slice(activatable, func(i, j int) bool { return activatable[i].ID < activatable[j].ID })
 	return activatable, nil
 }
 
+// nodeCacheKey is the key a node's condition result is cached under
+func nodeCacheKey(id string) string {
+	return "node:" + id
+}
+
 // FireNode marks a node as fired and returns it
 func (dag *MacroDAG) FireNode(id string) (*PlotNode, error) {
 	dag.mu.Lock()
@@ -222,7 +285,12 @@ func (dag *MacroDAG) FireNode(id string) (*PlotNode, error) {
 		return nil, fmt.Errorf("node %s not found", id)
 	}
 
+	if node.IsPruned {
+		return nil, fmt.Errorf("node %s is pruned", id)
+	}
+
 	node.IsFired = true
+	dag.pruneExclusiveSiblings(node)
 	return node, nil
 }
 
@@ -239,7 +307,9 @@ func (dag *MacroDAG) CheckEnding() bool {
 	return false
 }
 
-// PartialReset resets non-ending nodes (for resurrection)
+// PartialReset resets non-ending nodes and every pruned branch (for
+// resurrection). Fired endings stay fired; unlocked endings are recorded
+// per player, so an ending pruned in one life can be reached in the next.
 func (dag *MacroDAG) PartialReset() {
 	dag.mu.Lock()
 	defer dag.mu.Unlock()
@@ -248,6 +318,14 @@ func (dag *MacroDAG) PartialReset() {
 		if !node.IsEnding {
 			node.IsFired = false
 		}
+		node.IsPruned = false
+		// Deadlines escalate afresh in the new life
+		if node.Deadline != nil && node.Deadline.Escalated && !node.IsFired {
+			dag.unindexNode(node)
+			node.Deadline.Escalated = false
+			node.activate()
+			dag.indexNode(node)
+		}
 	}
 }
 
@@ -271,7 +349,7 @@ func (dag *MacroDAG) GetWriterContext() map[string]interface{} {
 			// Add successors
 			for _, succID := range node.SuccessorIDs {
 				succ := dag.nodes[succID]
-				if !succ.IsFired {
+				if !succ.IsFired && !succ.IsPruned {
 					nextNodes = append(nextNodes, map[string]interface{}{
 						"id":                 succ.ID,
 						"plot_description":   succ.PlotDescription,
@@ -285,6 +363,7 @@ func (dag *MacroDAG) GetWriterContext() map[string]interface{} {
 	return map[string]interface{}{
 		"fired_nodes": firedNodes,
 		"next_nodes":  nextNodes,
+		"arcs":        dag.groupByArc(firedNodes, nextNodes),
 	}
 }
 
@@ -303,6 +382,10 @@ func (dag *MacroDAG) GetVisualGraph() map[string]interface{} {
 			"condition":          node.Condition,
 			"is_ending":          node.IsEnding,
 			"is_fired":           node.IsFired,
+			"arc_id":             node.ArcID,
+			"deadline":           node.Deadline,
+			"is_pruned":          node.IsPruned,
+			"ending_tier":        node.EndingTier,
 		})
 
 		for _, succID := range node.SuccessorIDs {
@@ -316,9 +399,16 @@ func (dag *MacroDAG) GetVisualGraph() map[string]interface{} {
 	return map[string]interface{}{
 		"nodes": nodes,
 		"edges": edges,
+		"arcs":  dag.arcProgress(),
 	}
 }
 
+// dagJSON is the persisted form of a MacroDAG
+type dagJSON struct {
+	Nodes []*PlotNode `json:"nodes"`
+	Arcs  []*Arc      `json:"arcs"`
+}
+
 // MarshalJSON implements json.Marshaler
 func (dag *MacroDAG) MarshalJSON() ([]byte, error) {
 	dag.mu.RLock()
@@ -328,14 +418,21 @@ func (dag *MacroDAG) MarshalJSON() ([]byte, error) {
 	for _, node := range dag.nodes {
 		nodes = append(nodes, node)
 	}
+	// Stable order keeps saves byte-identical across runs
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
 
-	return json.Marshal(nodes)
+	return json.Marshal(dagJSON{Nodes: nodes, Arcs: dag.sortedArcs()})
 }
 
-// UnmarshalJSON implements json.Unmarshaler
+// UnmarshalJSON implements json.Unmarshaler. It also accepts the legacy
+// format, a bare array of nodes.
 func (dag *MacroDAG) UnmarshalJSON(data []byte) error {
-	var nodes []*PlotNode
-	if err := json.Unmarshal(data, &nodes); err != nil {
+	var doc dagJSON
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(data, &doc.Nodes); err != nil {
+			return err
+		}
+	} else if err := json.Unmarshal(data, &doc); err != nil {
 		return err
 	}
 
@@ -343,16 +440,18 @@ func (dag *MacroDAG) UnmarshalJSON(data []byte) error {
 	defer dag.mu.Unlock()
 
 	dag.nodes = make(map[string]*PlotNode)
-	for _, node := range nodes {
+	dag.depIndex = make(map[string][]string)
+	dag.arcs = make(map[string]*Arc)
+	for _, arc := range doc.Arcs {
+		dag.arcs[arc.ID] = arc
+	}
+	for _, node := range doc.Nodes {
 		// Pre-compile condition
-		if node.Condition != "" {
-			program, err := expr.Compile(node.Condition)
-			if err != nil {
-				return fmt.Errorf("invalid condition for node %s: %w", node.ID, err)
-			}
-			node.compiledProgram = program
+		if err := node.compile(); err != nil {
+			return err
 		}
 		dag.nodes[node.ID] = node
+		dag.indexNode(node)
 	}
 
 	return nil
@@ -0,0 +1,82 @@
+package story
+
+import "fmt"
+
+// Deadline escalation actions
+const (
+	DeadlineActionNudge  = "nudge"  // queue an info card hinting at the beat
+	DeadlineActionLoosen = "loosen" // switch to the fallback condition
+)
+
+// SoftDeadline is a hint that a node should fire by a given game week.
+// Once the week has passed the engine escalates it exactly once.
+type SoftDeadline struct {
+	Week              int    `json:"week"`                         // 1-based game week (elapsed_days/7 + 1)
+	Action            string `json:"action"`                       // "nudge" (default) or "loosen"
+	FallbackCondition string `json:"fallback_condition,omitempty"` // used when loosened; empty means always true
+	Escalated         bool   `json:"escalated"`
+}
+
+// ActiveCondition returns the condition currently governing the node,
+// which is the fallback once a "loosen" deadline has escalated
+func (node *PlotNode) ActiveCondition() string {
+	if node.loosened() {
+		return node.Deadline.FallbackCondition
+	}
+	return node.Condition
+}
+
+// loosened reports whether a "loosen" deadline has escalated
+func (node *PlotNode) loosened() bool {
+	return node.Deadline != nil && node.Deadline.Escalated && node.Deadline.Action == DeadlineActionLoosen
+}
+
+// GetOverdueNodes returns unfired, unescalated nodes whose predecessors have
+// all fired and whose soft deadline week is before the current week
+func (dag *MacroDAG) GetOverdueNodes(currentWeek int) []*PlotNode {
+	dag.mu.RLock()
+	defer dag.mu.RUnlock()
+
+	overdue := make([]*PlotNode, 0)
+	for _, node := range dag.nodes {
+		if node.IsFired || node.Deadline == nil || node.Deadline.Escalated || node.Deadline.Week <= 0 {
+			continue
+		}
+		if currentWeek <= node.Deadline.Week || node.IsPruned {
+			continue
+		}
+		if dag.predecessorsSatisfied(node) {
+			overdue = append(overdue, node)
+		}
+	}
+	return overdue
+}
+
+// EscalateDeadline marks a node's deadline as escalated. For "loosen"
+// deadlines the fallback condition takes effect immediately.
+func (dag *MacroDAG) EscalateDeadline(id string) (*PlotNode, error) {
+	dag.mu.Lock()
+	defer dag.mu.Unlock()
+
+	node, ok := dag.nodes[id]
+	if !ok {
+		return nil, fmt.Errorf("node %s not found", id)
+	}
+	if node.Deadline == nil {
+		return nil, fmt.Errorf("node %s has no deadline", id)
+	}
+	if node.Deadline.Escalated {
+		return node, nil
+	}
+
+	// A deadline changed since the node was added compiles here, before
+	// anything is marked
+	if err := node.compile(); err != nil {
+		return nil, err
+	}
+	dag.unindexNode(node)
+	node.Deadline.Escalated = true
+	node.activate()
+	dag.indexNode(node)
+	return node, nil
+}
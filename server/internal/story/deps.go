@@ -0,0 +1,110 @@
+package story
+
+import (
+	"sort"
+	"strings"
+)
+
+// indexNode records a node under each of its condition dependencies.
+// Caller must hold dag.mu.
+func (dag *MacroDAG) indexNode(node *PlotNode) {
+	for _, dep := range node.dependencies {
+		dag.depIndex[dep] = append(dag.depIndex[dep], node.ID)
+	}
+}
+
+// unindexNode removes a node from the dependency index, before its
+// dependencies change. Caller must hold dag.mu.
+func (dag *MacroDAG) unindexNode(node *PlotNode) {
+	for _, dep := range node.dependencies {
+		kept := make([]string, 0, len(dag.depIndex[dep]))
+		for _, id := range dag.depIndex[dep] {
+			if id != node.ID {
+				kept = append(kept, id)
+			}
+		}
+		if len(kept) == 0 {
+			delete(dag.depIndex, dep)
+		} else {
+			dag.depIndex[dep] = kept
+		}
+	}
+}
+
+// NodesDependingOn returns the IDs of nodes whose conditions read any of the
+// given state paths. A change to "stats.health" also matches nodes that read
+// the whole "stats" map.
+func (dag *MacroDAG) NodesDependingOn(changed []string) []string {
+	dag.mu.RLock()
+	defer dag.mu.RUnlock()
+	return dag.nodesDependingOn(changed)
+}
+
+// nodesDependingOn is NodesDependingOn without locking
+func (dag *MacroDAG) nodesDependingOn(changed []string) []string {
+	seen := make(map[string]bool)
+	result := make([]string, 0)
+	add := func(ids []string) {
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				result = append(result, id)
+			}
+		}
+	}
+
+	for _, path := range changed {
+		add(dag.depIndex[path])
+		if root, _, ok := strings.Cut(path, "."); ok {
+			add(dag.depIndex[root])
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// PendingHints re-checks only the nodes affected by the changed paths and
+// returns those that are now ready to fire (unfired, predecessors fired,
+// condition true). Used to surface "plot pending" hints between weeks.
+func (dag *MacroDAG) PendingHints(changed []string, state map[string]interface{}) []*PlotNode {
+	dag.mu.RLock()
+	defer dag.mu.RUnlock()
+
+	hints := make([]*PlotNode, 0)
+	for _, id := range dag.nodesDependingOn(changed) {
+		node := dag.nodes[id]
+		if node == nil || node.IsFired || node.IsPruned || node.compiledProgram == nil {
+			continue
+		}
+		if !dag.predecessorsSatisfied(node) {
+			continue
+		}
+
+		cond := &compiledCondition{program: node.compiledProgram, dependencies: node.dependencies}
+		if ok, err := cond.run(state); err == nil && ok {
+			hints = append(hints, node)
+		}
+	}
+	return hints
+}
+
+// Recompile recompiles every node's condition and rebuilds the dependency
+// index. Nodes that fail to compile keep no program and are reported by ID.
+func (dag *MacroDAG) Recompile() map[string]error {
+	dag.mu.Lock()
+	defer dag.mu.Unlock()
+
+	failures := make(map[string]error)
+	dag.depIndex = make(map[string][]string)
+	for id, node := range dag.nodes {
+		if err := node.compile(); err != nil {
+			node.compiledProgram = nil
+			node.dependencies = nil
+			node.condition, node.fallback = nil, nil
+			failures[id] = err
+			continue
+		}
+		dag.indexNode(node)
+	}
+	return failures
+}
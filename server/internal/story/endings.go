@@ -0,0 +1,32 @@
+package story
+
+// Ending tiers, from most to least commonly reached
+const (
+	EndingTierCommon = "common"
+	EndingTierGood   = "good"
+	EndingTierSecret = "secret"
+)
+
+// NormalizeEndingTier maps unknown or empty tiers to common
+func NormalizeEndingTier(tier string) string {
+	switch tier {
+	case EndingTierGood, EndingTierSecret:
+		return tier
+	default:
+		return EndingTierCommon
+	}
+}
+
+// GetEndingNodes returns all ending nodes
+func (dag *MacroDAG) GetEndingNodes() []*PlotNode {
+	dag.mu.RLock()
+	defer dag.mu.RUnlock()
+
+	endings := make([]*PlotNode, 0)
+	for _, node := range dag.nodes {
+		if node.IsEnding {
+			endings = append(endings, node)
+		}
+	}
+	return endings
+}
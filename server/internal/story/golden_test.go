@@ -0,0 +1,91 @@
+package story
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qninhdt/world-card-ai-2/server/internal/agents"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata/golden")
+
+// goldenDAG returns a DAG exercising arcs, deadlines, endings and pruning
+func goldenDAG(t *testing.T) *MacroDAG {
+	dag := NewMacroDAG()
+	if err := dag.AddArc(&Arc{ID: "rebellion", Title: "The Rebellion", Theme: "uprising"}); err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := []*PlotNode{
+		{
+			ID:              "spark",
+			PlotDescription: "A riot breaks out in the market",
+			Condition:       "stats.anger > 60",
+			Calls:           []agents.FunctionCall{{Name: "add_tag", Params: map[string]interface{}{"tag_id": "unrest"}}},
+			ArcID:           "rebellion",
+			Deadline:        &SoftDeadline{Week: 4, Action: "loosen", FallbackCondition: "true"},
+			IsFired:         true,
+		},
+		{ID: "crown", PlotDescription: "You take the throne", Condition: "tags.unrest", IsEnding: true, EndingTier: "good", ArcID: "rebellion", ExclusiveGroup: "fate"},
+		{ID: "exile", PlotDescription: "You flee the city", Condition: "stats.health < 20", IsEnding: true, EndingTier: "bad", ExclusiveGroup: "fate", IsPruned: true},
+	}
+	for _, node := range nodes {
+		if node.Calls == nil {
+			node.Calls = []agents.FunctionCall{}
+		}
+		node.PredecessorIDs = []string{}
+		node.SuccessorIDs = []string{}
+		if err := dag.AddNode(node); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dag.AddEdge("spark", "crown")
+	dag.AddEdge("spark", "exile")
+	return dag
+}
+
+// TestGoldenMacroDAG tests that the DAG keeps its saved form
+func TestGoldenMacroDAG(t *testing.T) {
+	got, err := json.MarshalIndent(goldenDAG(t), "", "  ")
+	if err != nil {
+		t.Fatalf("Failed to marshal DAG: %v", err)
+	}
+
+	path := filepath.Join("testdata", "golden", "macro_dag.json")
+	if *updateGolden {
+		if err := os.WriteFile(path, append(got, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+	want = bytes.TrimSpace(want)
+	if !bytes.Equal(got, want) {
+		t.Errorf("DAG does not match %s (run go test -update if the change is intended)\ngot:\n%s", path, got)
+	}
+
+	decoded := NewMacroDAG()
+	if err := json.Unmarshal(want, decoded); err != nil {
+		t.Fatalf("Failed to unmarshal DAG: %v", err)
+	}
+	if node := decoded.GetNode("spark"); node == nil || node.Deadline == nil || node.Deadline.Action != "loosen" {
+		t.Errorf("Expected spark deadline to survive a round trip, got %+v", node)
+	}
+	if decoded.GetArc("rebellion") == nil {
+		t.Error("Expected rebellion arc to survive a round trip")
+	}
+
+	again, err := json.MarshalIndent(decoded, "", "  ")
+	if err != nil {
+		t.Fatalf("Failed to re-marshal DAG: %v", err)
+	}
+	if !bytes.Equal(again, want) {
+		t.Errorf("DAG changed after a round trip\ngot:\n%s", again)
+	}
+}
@@ -0,0 +1,71 @@
+package story
+
+// predecessorsSatisfied reports whether a node's predecessors allow it to
+// fire: every predecessor is fired or pruned, and at least one has fired.
+// Roots are always satisfied. Caller must hold dag.mu.
+func (dag *MacroDAG) predecessorsSatisfied(node *PlotNode) bool {
+	if len(node.PredecessorIDs) == 0 {
+		return true
+	}
+
+	anyFired := false
+	for _, predID := range node.PredecessorIDs {
+		pred := dag.nodes[predID]
+		if pred == nil {
+			return false
+		}
+		if pred.IsFired {
+			anyFired = true
+		} else if !pred.IsPruned {
+			return false
+		}
+	}
+	return anyFired
+}
+
+// pruneExclusiveSiblings prunes every unfired node sharing the fired node's
+// exclusive group, then cascades to descendants left with only pruned
+// predecessors. Returns the IDs pruned. Caller must hold dag.mu.
+func (dag *MacroDAG) pruneExclusiveSiblings(fired *PlotNode) []string {
+	if fired.ExclusiveGroup == "" {
+		return nil
+	}
+
+	pruned := make([]string, 0)
+	queue := make([]*PlotNode, 0)
+	for _, node := range dag.nodes {
+		if node.ID == fired.ID || node.IsFired || node.IsPruned {
+			continue
+		}
+		if node.ExclusiveGroup == fired.ExclusiveGroup {
+			node.IsPruned = true
+			pruned = append(pruned, node.ID)
+			queue = append(queue, node)
+		}
+	}
+
+	// A descendant is abandoned once all of its predecessors are pruned
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, succID := range current.SuccessorIDs {
+			succ := dag.nodes[succID]
+			if succ == nil || succ.IsFired || succ.IsPruned {
+				continue
+			}
+			allPruned := true
+			for _, predID := range succ.PredecessorIDs {
+				if pred := dag.nodes[predID]; pred == nil || !pred.IsPruned {
+					allPruned = false
+					break
+				}
+			}
+			if allPruned {
+				succ.IsPruned = true
+				pruned = append(pruned, succ.ID)
+				queue = append(queue, succ)
+			}
+		}
+	}
+	return pruned
+}
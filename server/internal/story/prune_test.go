@@ -0,0 +1,84 @@
+package story
+
+import "testing"
+
+// buildBranchingDAG creates root -> {north, south} (exclusive) -> ...
+func buildBranchingDAG() *MacroDAG {
+	dag := NewMacroDAG()
+	dag.AddNode(&PlotNode{ID: "root"})
+	dag.AddNode(&PlotNode{ID: "north", ExclusiveGroup: "path"})
+	dag.AddNode(&PlotNode{ID: "south", ExclusiveGroup: "path"})
+	dag.AddNode(&PlotNode{ID: "south_deep"})
+	dag.AddNode(&PlotNode{ID: "finale"})
+	dag.AddEdge("root", "north")
+	dag.AddEdge("root", "south")
+	dag.AddEdge("south", "south_deep")
+	dag.AddEdge("north", "finale")
+	dag.AddEdge("south", "finale")
+	return dag
+}
+
+// TestFireNodePrunesExclusiveSiblings tests sibling and descendant pruning
+func TestFireNodePrunesExclusiveSiblings(t *testing.T) {
+	dag := buildBranchingDAG()
+	dag.FireNode("root")
+	dag.FireNode("north")
+
+	if !dag.GetNode("south").IsPruned {
+		t.Error("Expected south to be pruned")
+	}
+	if !dag.GetNode("south_deep").IsPruned {
+		t.Error("Expected south_deep to be pruned")
+	}
+	if dag.GetNode("finale").IsPruned {
+		t.Error("Convergence node must survive while one branch is alive")
+	}
+
+	nodes, err := dag.GetActivatableNodes(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("GetActivatableNodes failed: %v", err)
+	}
+	if len(nodes) != 1 || nodes[0].ID != "finale" {
+		t.Errorf("Expected only finale activatable, got %v", nodes)
+	}
+
+	for _, next := range dag.GetWriterContext()["next_nodes"].([]map[string]interface{}) {
+		if next["id"] == "south" || next["id"] == "south_deep" {
+			t.Errorf("Pruned node %v leaked into Writer context", next["id"])
+		}
+	}
+
+	if _, err := dag.FireNode("south"); err == nil {
+		t.Error("Expected error firing a pruned node")
+	}
+}
+
+// TestPartialResetClearsPruning tests that resurrection restores branches
+func TestPartialResetClearsPruning(t *testing.T) {
+	dag := buildBranchingDAG()
+	dag.FireNode("root")
+	dag.FireNode("north")
+	dag.PartialReset()
+
+	if dag.GetNode("south").IsPruned {
+		t.Error("Expected pruning to be cleared by PartialReset")
+	}
+
+	// Endings lost to a branch come back too
+	dag = NewMacroDAG()
+	dag.AddNode(&PlotNode{ID: "root"})
+	dag.AddNode(&PlotNode{ID: "crown", ExclusiveGroup: "fate", IsEnding: true})
+	dag.AddNode(&PlotNode{ID: "exile", ExclusiveGroup: "fate", IsEnding: true})
+	dag.AddEdge("root", "crown")
+	dag.AddEdge("root", "exile")
+	dag.FireNode("root")
+	dag.FireNode("crown")
+	dag.PartialReset()
+
+	if dag.GetNode("exile").IsPruned {
+		t.Error("Expected a pruned ending to be restored by PartialReset")
+	}
+	if !dag.GetNode("crown").IsFired {
+		t.Error("Expected a fired ending to stay fired")
+	}
+}
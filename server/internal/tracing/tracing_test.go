@@ -0,0 +1,97 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a provider that keeps ended spans in memory
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestMiddleware(t *testing.T) {
+	recorder := recordSpans(t)
+
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/games/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "game.Load")
+		span.End()
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/games/abc", nil)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	child, server := spans[0], spans[1]
+	if server.Name() != "GET /games/{id}" {
+		t.Errorf("Expected span named by route pattern, got %q", server.Name())
+	}
+	if child.Name() != "game.Load" || child.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("Expected game.Load as a child of the request span, got %q", child.Name())
+	}
+	status := false
+	for _, attr := range server.Attributes() {
+		if attr.Key == "http.response.status_code" && attr.Value.AsInt64() == http.StatusTeapot {
+			status = true
+		}
+	}
+	if !status {
+		t.Errorf("Expected the response status on the request span, got %v", server.Attributes())
+	}
+}
+
+func TestEnd(t *testing.T) {
+	recorder := recordSpans(t)
+
+	_, span := Start(context.Background(), "ok")
+	var err error
+	End(span, &err)
+
+	_, span = Start(context.Background(), "failed")
+	err = errors.New("boom")
+	End(span, &err)
+
+	spans := recorder.Ended()
+	if spans[0].Status().Code == codes.Error {
+		t.Error("Expected a span without error not to fail")
+	}
+	if spans[1].Status().Code != codes.Error || len(spans[1].Events()) == 0 {
+		t.Error("Expected the error to be recorded on the span")
+	}
+}
+
+func TestSetupFromEnv(t *testing.T) {
+	t.Setenv("TRACING_EXPORTER", "")
+	shutdown, err := SetupFromEnv(context.Background())
+	if err != nil {
+		t.Fatalf("Expected tracing off to set up, got %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("Expected shutdown to succeed, got %v", err)
+	}
+
+	t.Setenv("TRACING_EXPORTER", "zipkin")
+	if _, err := SetupFromEnv(context.Background()); err == nil {
+		t.Error("Expected an unknown exporter to be rejected")
+	}
+}
@@ -3,6 +3,7 @@ package validation
 import (
 	"fmt"
 	"regexp"
+	"strings"
 )
 
 // ValidateGameID validates game ID format
@@ -49,3 +50,153 @@ func ValidateDelta(delta float64) error {
 	}
 	return nil
 }
+
+// ValidateOrgID validates organization ID format
+func ValidateOrgID(id string) error {
+	if len(id) == 0 || len(id) > 64 {
+		return fmt.Errorf("organization ID must be 1-64 characters")
+	}
+
+	matched, _ := regexp.MatchString(`^[a-zA-Z0-9_-]+$`, id)
+	if !matched {
+		return fmt.Errorf("organization ID can only contain alphanumeric characters, hyphens, and underscores")
+	}
+
+	return nil
+}
+
+// ValidateName validates a display name (organizations, API keys)
+func ValidateName(name string) error {
+	if len(strings.TrimSpace(name)) == 0 || len(name) > 100 {
+		return fmt.Errorf("name must be 1-100 characters")
+	}
+	return nil
+}
+
+// ValidateUserID validates a user ID added to an organization
+func ValidateUserID(id string) error {
+	if len(id) == 0 || len(id) > 128 {
+		return fmt.Errorf("user ID must be 1-128 characters")
+	}
+
+	matched, _ := regexp.MatchString(`^[a-zA-Z0-9_.@-]+$`, id)
+	if !matched {
+		return fmt.Errorf("user ID can only contain alphanumeric characters and . _ @ -")
+	}
+
+	return nil
+}
+
+// ValidateUsername validates a username chosen at signup
+func ValidateUsername(username string) error {
+	if len(username) < 3 || len(username) > 32 {
+		return fmt.Errorf("username must be 3-32 characters")
+	}
+
+	matched, _ := regexp.MatchString(`^[a-zA-Z0-9_.-]+$`, username)
+	if !matched {
+		return fmt.Errorf("username can only contain alphanumeric characters and . _ -")
+	}
+
+	return nil
+}
+
+// ValidatePassword validates a password chosen at signup. bcrypt only reads
+// the first 72 bytes, so longer passwords are refused.
+func ValidatePassword(password string) error {
+	if len(password) < 8 || len(password) > 72 {
+		return fmt.Errorf("password must be 8-72 bytes")
+	}
+	return nil
+}
+
+// ValidateLanguage validates a BCP 47 language tag such as "en" or "pt-BR"
+func ValidateLanguage(lang string) error {
+	matched, _ := regexp.MatchString(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8}){0,2}$`, lang)
+	if !matched {
+		return fmt.Errorf("language must be a language tag such as 'en' or 'pt-BR'")
+	}
+	return nil
+}
+
+// ValidateContentRating validates a content rating
+func ValidateContentRating(rating string) error {
+	if rating != "everyone" && rating != "teen" && rating != "mature" {
+		return fmt.Errorf("content rating must be 'everyone', 'teen' or 'mature'")
+	}
+	return nil
+}
+
+// ValidateHintLevel validates a hint level
+func ValidateHintLevel(level string) error {
+	if level != "none" && level != "normal" {
+		return fmt.Errorf("hint level must be 'none' or 'normal'")
+	}
+	return nil
+}
+
+// ValidateOrgRole validates an organization role
+func ValidateOrgRole(role string) error {
+	if role != "owner" && role != "admin" && role != "member" {
+		return fmt.Errorf("role must be 'owner', 'admin' or 'member'")
+	}
+	return nil
+}
+
+// ValidateJobID validates a generation job ID
+func ValidateJobID(id string) error {
+	if len(id) == 0 || len(id) > 64 {
+		return fmt.Errorf("job ID must be 1-64 characters")
+	}
+
+	matched, _ := regexp.MatchString(`^[a-zA-Z0-9_-]+$`, id)
+	if !matched {
+		return fmt.Errorf("job ID can only contain alphanumeric characters, hyphens, and underscores")
+	}
+
+	return nil
+}
+
+// ValidateSessionID validates a login session ID
+func ValidateSessionID(id string) error {
+	if len(id) == 0 || len(id) > 64 {
+		return fmt.Errorf("session ID must be 1-64 characters")
+	}
+
+	matched, _ := regexp.MatchString(`^[a-zA-Z0-9_-]+$`, id)
+	if !matched {
+		return fmt.Errorf("session ID can only contain alphanumeric characters, hyphens, and underscores")
+	}
+
+	return nil
+}
+
+// ValidateWorldPrompt validates a world generation prompt
+func ValidateWorldPrompt(prompt string) error {
+	if len(strings.TrimSpace(prompt)) == 0 || len(prompt) > 2000 {
+		return fmt.Errorf("prompt must be 1-2000 characters")
+	}
+	return nil
+}
+
+// ValidateDraftID validates a world draft ID
+func ValidateDraftID(id string) error {
+	if len(id) == 0 || len(id) > 64 {
+		return fmt.Errorf("draft ID must be 1-64 characters")
+	}
+
+	matched, _ := regexp.MatchString(`^[a-zA-Z0-9_-]+$`, id)
+	if !matched {
+		return fmt.Errorf("draft ID can only contain alphanumeric characters, hyphens, and underscores")
+	}
+
+	return nil
+}
+
+// ValidateRefinement validates a message refining a world draft
+func ValidateRefinement(message string) error {
+	if len(strings.TrimSpace(message)) == 0 || len(message) > 1000 {
+		return fmt.Errorf("message must be 1-1000 characters")
+	}
+	return nil
+}